@@ -0,0 +1,360 @@
+// Command datactl saves, fetches and lists items on a data service server,
+// migrates items between storage types, replays the request journal and
+// checks the server's health.
+//
+// Usage:
+//
+//	datactl [flags] save [file]      save a file, or stdin without one
+//	datactl [flags] get <id>         write an item's payload to stdout
+//	datactl [flags] list             list items
+//	datactl [flags] health           check that the server is healthy
+//	datactl [flags] migrate [-dry-run] [-rate n] [-tenant t] [-wait] <from> <to>
+//	                                 copy every item from one storage type to another
+//	datactl [flags] migrate status   report the progress of the last migration
+//	datactl [flags] migrate cancel   stop the running migration
+//	datactl [flags] replay [-dry-run] [-tenant t] <from> <to>
+//	                                 write back items saved between two RFC 3339
+//	                                 times that the server no longer holds
+//
+// Migrating and replaying need an admin key not bound to a tenant. With -wait, datactl
+// reports progress until the migration finishes, within -timeout.
+//
+// The server URL and token default to $DATACTL_SERVER and $DATACTL_TOKEN.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"interview-task/pkg/client"
+)
+
+// labelFlags collects repeated -label key=value flags
+type labelFlags map[string]string
+
+func (l labelFlags) String() string {
+	pairs := make([]string, 0, len(l))
+	for key, value := range l {
+		pairs = append(pairs, key+"="+value)
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
+
+func (l labelFlags) Set(value string) error {
+	key, val, ok := strings.Cut(value, "=")
+	if !ok || key == "" {
+		return fmt.Errorf("labels are key=value, got %q", value)
+	}
+	l[key] = val
+	return nil
+}
+
+type options struct {
+	server      string
+	token       string
+	tenant      string
+	output      string
+	storageType string
+	contentType string
+	source      string
+	ttl         time.Duration
+	timeout     time.Duration
+	labels      labelFlags
+}
+
+func main() {
+	opts := options{labels: labelFlags{}}
+	flags := flag.NewFlagSet("datactl", flag.ExitOnError)
+	flags.StringVar(&opts.server, "server", envOr("DATACTL_SERVER", "http://localhost:8080"), "server URL")
+	flags.StringVar(&opts.token, "token", os.Getenv("DATACTL_TOKEN"), "API key")
+	flags.StringVar(&opts.tenant, "tenant", "", "tenant to address; defaults to the key's or the server's")
+	flags.StringVar(&opts.output, "output", "text", "output format: text or json")
+	flags.StringVar(&opts.storageType, "storage-type", "file", "storage type: file, database or log")
+	flags.StringVar(&opts.contentType, "content-type", "", "content type of saved data; sniffed when empty")
+	flags.StringVar(&opts.source, "source", "", "source recorded with saved data, or to list by")
+	flags.DurationVar(&opts.ttl, "ttl", 0, "how long saved data lives; zero uses the server default")
+	flags.DurationVar(&opts.timeout, "timeout", 30*time.Second, "timeout of the whole command")
+	flags.Var(opts.labels, "label", "key=value label to save with, or to list by; repeatable")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "usage: datactl [flags] save [file] | get <id> | list | health | migrate [status | cancel | <from> <to>] | replay <from> <to>\n\nflags:\n")
+		flags.PrintDefaults()
+	}
+	flags.Parse(os.Args[1:])
+	if flags.NArg() == 0 {
+		flags.Usage()
+		os.Exit(2)
+	}
+	if opts.output != "text" && opts.output != "json" {
+		fmt.Fprintf(os.Stderr, "datactl: unknown output format %q\n", opts.output)
+		os.Exit(2)
+	}
+
+	if err := run(opts, flags.Arg(0), flags.Args()[1:]); err != nil {
+		fmt.Fprintf(os.Stderr, "datactl: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func run(opts options, command string, args []string) error {
+	clientOpts := []client.Option{client.WithTenant(opts.tenant)}
+	if opts.token != "" {
+		clientOpts = append(clientOpts, client.WithAPIKey(opts.token))
+	}
+	c, err := client.New(opts.server, clientOpts...)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), opts.timeout)
+	defer cancel()
+
+	switch command {
+	case "save":
+		return save(ctx, c, opts, args)
+	case "get":
+		if len(args) != 1 {
+			return errors.New("get takes an item ID")
+		}
+		item, err := c.Get(ctx, opts.storageType, args[0])
+		if err != nil {
+			return err
+		}
+		if opts.output == "json" {
+			return printJSON(item)
+		}
+		_, err = os.Stdout.Write(item.Data)
+		return err
+	case "list":
+		return list(ctx, c, opts)
+	case "migrate":
+		return migrate(ctx, c, opts, args)
+	case "replay":
+		return replay(ctx, c, opts, args)
+	case "health":
+		if err := c.Health(ctx); err != nil {
+			return err
+		}
+		if opts.output == "json" {
+			return printJSON(map[string]string{"status": "healthy"})
+		}
+		fmt.Println("healthy")
+		return nil
+	default:
+		return fmt.Errorf("unknown command %q", command)
+	}
+}
+
+func save(ctx context.Context, c *client.Client, opts options, args []string) error {
+	var data []byte
+	var err error
+	switch len(args) {
+	case 0:
+		data, err = io.ReadAll(os.Stdin)
+	case 1:
+		data, err = os.ReadFile(args[0])
+	default:
+		return errors.New("save takes at most one file")
+	}
+	if err != nil {
+		return err
+	}
+
+	result, err := c.Save(ctx, &client.SaveRequest{
+		Data:        data,
+		StorageType: opts.storageType,
+		ContentType: opts.contentType,
+		TTL:         opts.ttl,
+		Labels:      opts.labels,
+		Source:      opts.source,
+	})
+	if err != nil {
+		return err
+	}
+	if opts.output == "json" {
+		return printJSON(result)
+	}
+	fmt.Println(result.ID)
+	if result.Accepted {
+		fmt.Fprintln(os.Stderr, "datactl: the backend is unavailable; the server will save the data when it recovers")
+	}
+	return nil
+}
+
+func list(ctx context.Context, c *client.Client, opts options) error {
+	items, err := c.List(ctx, opts.storageType, &client.ListOptions{
+		Labels:      opts.labels,
+		Source:      opts.source,
+		ContentType: opts.contentType,
+	})
+	if err != nil {
+		return err
+	}
+	if opts.output == "json" {
+		return printJSON(items)
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tSIZE\tCONTENT TYPE\tMODIFIED\tLABELS")
+	for _, item := range items {
+		modified := "-"
+		if !item.ModifiedAt.IsZero() {
+			modified = item.ModifiedAt.Local().Format(time.DateTime)
+		}
+		fmt.Fprintf(w, "%s\t%d\t%s\t%s\t%s\n", item.ID, item.Size, item.ContentType, modified, labelFlags(item.Labels))
+	}
+	return w.Flush()
+}
+
+// tenantFlags collects repeated -tenant flags of migrate
+type tenantFlags []string
+
+func (t *tenantFlags) String() string { return strings.Join(*t, ",") }
+
+func (t *tenantFlags) Set(value string) error {
+	*t = append(*t, value)
+	return nil
+}
+
+func migrate(ctx context.Context, c *client.Client, opts options, args []string) error {
+	var status *client.MigrationStatus
+	var err error
+	switch {
+	case len(args) == 1 && args[0] == "status":
+		status, err = c.Migration(ctx)
+	case len(args) == 1 && args[0] == "cancel":
+		status, err = c.CancelMigration(ctx)
+	default:
+		var tenants tenantFlags
+		var wait bool
+		req := &client.MigrationRequest{}
+		flags := flag.NewFlagSet("migrate", flag.ContinueOnError)
+		flags.BoolVar(&req.DryRun, "dry-run", false, "count what would be copied without writing")
+		flags.IntVar(&req.RateLimit, "rate", 0, "most keys copied per second; zero doesn't throttle")
+		flags.Var(&tenants, "tenant", "tenant to migrate; repeatable, all tenants without one")
+		flags.BoolVar(&wait, "wait", false, "report progress until the migration finishes")
+		if err := flags.Parse(args); err != nil {
+			return err
+		}
+		if flags.NArg() != 2 {
+			return errors.New("migrate takes a source and a target storage type, or status or cancel")
+		}
+		req.From, req.To, req.Tenants = flags.Arg(0), flags.Arg(1), tenants
+		if status, err = c.StartMigration(ctx, req); err != nil {
+			return err
+		}
+		if wait {
+			status, err = waitForMigration(ctx, c, status)
+		}
+	}
+	if err != nil {
+		return err
+	}
+	if opts.output == "json" {
+		return printJSON(status)
+	}
+	printMigration(status)
+	if status.Failed > 0 || status.State == "failed" {
+		return errors.New("migration had failures")
+	}
+	return nil
+}
+
+// waitForMigration polls a running migration, reporting progress on stderr
+func waitForMigration(ctx context.Context, c *client.Client, status *client.MigrationStatus) (*client.MigrationStatus, error) {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for status.Running() {
+		select {
+		case <-ctx.Done():
+			return nil, fmt.Errorf("migration still running: %w", ctx.Err())
+		case <-ticker.C:
+		}
+		var err error
+		if status, err = c.Migration(ctx); err != nil {
+			return nil, err
+		}
+		fmt.Fprintf(os.Stderr, "migrated %d/%d keys\n", status.Scanned, status.Total)
+	}
+	return status, nil
+}
+
+func printMigration(status *client.MigrationStatus) {
+	if status.Request != nil {
+		mode := ""
+		if status.Request.DryRun {
+			mode = " (dry run)"
+		}
+		fmt.Printf("%s -> %s%s: %s\n", status.Request.From, status.Request.To, mode, status.State)
+	} else {
+		fmt.Println(status.State)
+	}
+	fmt.Printf("scanned %d/%d, copied %d, skipped %d, failed %d\n", status.Scanned, status.Total, status.Copied, status.Skipped, status.Failed)
+	for _, failure := range status.Failures {
+		fmt.Printf("  %s/%s: %s\n", failure.Tenant, failure.Key, failure.Error)
+	}
+	if status.Error != "" {
+		fmt.Println("error:", status.Error)
+	}
+}
+
+func replay(ctx context.Context, c *client.Client, opts options, args []string) error {
+	var tenants tenantFlags
+	req := &client.JournalReplayRequest{}
+	flags := flag.NewFlagSet("replay", flag.ContinueOnError)
+	flags.BoolVar(&req.DryRun, "dry-run", false, "count what would be replayed without writing")
+	flags.Var(&tenants, "tenant", "tenant to replay; repeatable, all tenants without one")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 2 {
+		return errors.New("replay takes a start and an end time")
+	}
+	var err error
+	if req.From, err = time.Parse(time.RFC3339, flags.Arg(0)); err != nil {
+		return fmt.Errorf("invalid start time: %w", err)
+	}
+	if req.To, err = time.Parse(time.RFC3339, flags.Arg(1)); err != nil {
+		return fmt.Errorf("invalid end time: %w", err)
+	}
+	req.Tenants = tenants
+	report, err := c.ReplayJournal(ctx, req)
+	if err != nil {
+		return err
+	}
+	if opts.output == "json" {
+		return printJSON(report)
+	}
+	mode := ""
+	if req.DryRun {
+		mode = " (dry run)"
+	}
+	fmt.Printf("scanned %d, replayed %d%s, present %d, removed %d, failed %d\n", report.Scanned, report.Replayed, mode, report.Present, report.Removed, report.Failed)
+	for _, failure := range report.Failures {
+		fmt.Printf("  %s/%s/%s: %s\n", failure.Tenant, failure.StorageType, failure.ItemID, failure.Error)
+	}
+	if report.Failed > 0 {
+		return errors.New("replay had failures")
+	}
+	return nil
+}
+
+func printJSON(v any) error {
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(v)
+}
+
+func envOr(name, fallback string) string {
+	if value := os.Getenv(name); value != "" {
+		return value
+	}
+	return fallback
+}
@@ -0,0 +1,49 @@
+// Command server runs the data service with the configuration read from the
+// JSON file named by $CONFIG_FILE, or the defaults without one. It shuts
+// down gracefully on SIGINT or SIGTERM.
+//
+// "server migrate" applies the pending database schema migrations and exits.
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"interview-task/server"
+)
+
+func main() {
+	// Load configuration
+	cfg, err := server.LoadConfig(os.Getenv("CONFIG_FILE"))
+	if err != nil {
+		log.Fatal("Failed to load configuration:", err)
+	}
+
+	if len(os.Args) > 1 {
+		if os.Args[1] != "migrate" || len(os.Args) > 2 {
+			log.Fatal("usage: server [migrate]")
+		}
+		version, err := server.MigrateDatabase(cfg)
+		if err != nil {
+			log.Fatal("Failed to migrate database schema:", err)
+		}
+		fmt.Printf("Database schema at version %d\n", version)
+		return
+	}
+
+	// Initialize server with all dependencies
+	srv, err := server.New(cfg)
+	if err != nil {
+		log.Fatal("Failed to initialize server:", err)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	if err := srv.Run(ctx); err != nil {
+		log.Fatal("Server stopped:", err)
+	}
+}
@@ -0,0 +1,76 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"interview-task/internal/config"
+)
+
+// APIVersion - IMPLEMENTS one version of the HTTP API. Its routes are served
+// under the version's path prefix, and once it is deprecated responses carry
+// Deprecation and Sunset headers (RFC 9745, RFC 8594) with a link to the
+// successor. After the sunset its routes answer 410 Gone.
+type APIVersion struct {
+	name      string
+	prefix    string
+	policy    config.APIVersionPolicy
+	successor *APIVersion
+	mux       *http.ServeMux
+	bulkheads *Bulkheads
+}
+
+func NewAPIVersion(mux *http.ServeMux, name string, policy config.APIVersionPolicy, successor *APIVersion, bulkheads *Bulkheads) *APIVersion {
+	return &APIVersion{name: name, prefix: config.APIVersionPrefixes[name], policy: policy, successor: successor, mux: mux, bulkheads: bulkheads}
+}
+
+// HandleFunc registers handler for a pattern such as "GET /data/{id}" under
+// the version's prefix. Handlers see the path without the prefix, so every
+// version shares endpoint-scoped validation, idempotency keys and bulkheads.
+func (v *APIVersion) HandleFunc(pattern string, handler http.HandlerFunc) {
+	handler = v.bulkheads.Wrap(pattern, handler)
+	method, path, ok := strings.Cut(pattern, " ")
+	if !ok {
+		method, path = "", pattern
+	}
+	pattern = strings.TrimSpace(method + " " + v.prefix + path)
+	v.mux.HandleFunc(pattern, func(w http.ResponseWriter, r *http.Request) {
+		if v.prefix != "" {
+			r = stripPathPrefix(r, strings.TrimPrefix(r.URL.Path, v.prefix))
+		}
+		if !v.annotate(w, r, time.Now()) {
+			return
+		}
+		handler(w, r)
+	})
+}
+
+// annotate adds the version's deprecation headers to a response, or answers
+// 410 Gone and returns false once the version is sunset
+func (v *APIVersion) annotate(w http.ResponseWriter, r *http.Request, now time.Time) bool {
+	successor := ""
+	if v.successor != nil {
+		successor = v.successor.prefix + r.URL.Path
+	}
+	if !v.policy.Sunset.IsZero() && !now.Before(v.policy.Sunset) {
+		message := fmt.Sprintf("API version %s was retired on %s", v.name, v.policy.Sunset.UTC().Format(time.DateOnly))
+		if successor != "" {
+			message += "; use " + successor
+		}
+		http.Error(w, message, http.StatusGone)
+		return false
+	}
+	if !v.policy.Sunset.IsZero() {
+		w.Header().Set("Sunset", v.policy.Sunset.UTC().Format(http.TimeFormat))
+	}
+	if !v.policy.Deprecated.IsZero() && !now.Before(v.policy.Deprecated) {
+		w.Header().Set("Deprecation", "@"+strconv.FormatInt(v.policy.Deprecated.Unix(), 10))
+		if successor != "" {
+			w.Header().Add("Link", "<"+successor+`>; rel="successor-version"`)
+		}
+	}
+	return true
+}
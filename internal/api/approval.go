@@ -0,0 +1,75 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"interview-task/internal/service"
+)
+
+// ApprovalHandler exposes the approval workflow to admins
+type ApprovalHandler struct {
+	manager *service.ApprovalManager
+}
+
+func NewApprovalHandler(manager *service.ApprovalManager) *ApprovalHandler {
+	return &ApprovalHandler{manager: manager}
+}
+
+// HandleRequest accepts {"operation": "...", "params": {...}}
+func (h *ApprovalHandler) HandleRequest(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		Operation string            `json:"operation"`
+		Params    map[string]string `json:"params"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		http.Error(w, "Invalid JSON format", http.StatusBadRequest)
+		return
+	}
+
+	pending, err := h.manager.Request(r.Context(), body.Operation, body.Params)
+	if err != nil {
+		http.Error(w, err.Error(), statusForError(err))
+		return
+	}
+	writeJSON(w, http.StatusAccepted, pending)
+}
+
+func (h *ApprovalHandler) HandleList(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]any{"operations": h.manager.List()})
+}
+
+func (h *ApprovalHandler) HandleApprove(w http.ResponseWriter, r *http.Request) {
+	pending, err := h.manager.Approve(r.Context(), r.PathValue("id"))
+	if pending == nil {
+		http.Error(w, err.Error(), approvalStatus(err))
+		return
+	}
+	// A failed execution still reports what happened
+	code := http.StatusOK
+	if err != nil {
+		code = http.StatusInternalServerError
+	}
+	writeJSON(w, code, pending)
+}
+
+func (h *ApprovalHandler) HandleReject(w http.ResponseWriter, r *http.Request) {
+	pending, err := h.manager.Reject(r.Context(), r.PathValue("id"))
+	if err != nil {
+		http.Error(w, err.Error(), approvalStatus(err))
+		return
+	}
+	writeJSON(w, http.StatusOK, pending)
+}
+
+func approvalStatus(err error) int {
+	switch {
+	case errors.Is(err, service.ErrSelfApproval):
+		return http.StatusForbidden
+	case errors.Is(err, service.ErrApprovalConflict):
+		return http.StatusConflict
+	default:
+		return statusForError(err)
+	}
+}
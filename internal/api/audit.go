@@ -0,0 +1,64 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"interview-task/internal/service"
+)
+
+// AuditHandler serves the auditor query endpoint
+type AuditHandler struct {
+	sink service.AuditSink
+}
+
+func NewAuditHandler(sink service.AuditSink) *AuditHandler {
+	return &AuditHandler{sink: sink}
+}
+
+// HandleQuery returns audit events filtered by ?actor=, ?tenant=, ?action=, ?item_id=,
+// ?request_id=, ?since=, ?until= (RFC 3339) and ?limit=
+func (h *AuditHandler) HandleQuery(w http.ResponseWriter, r *http.Request) {
+	params := r.URL.Query()
+	query := service.AuditQuery{
+		Actor:     params.Get("actor"),
+		Tenant:    params.Get("tenant"),
+		Action:    params.Get("action"),
+		ItemID:    params.Get("item_id"),
+		RequestID: params.Get("request_id"),
+	}
+
+	var err error
+	if v := params.Get("since"); v != "" {
+		if query.Since, err = time.Parse(time.RFC3339, v); err != nil {
+			http.Error(w, "invalid since timestamp", http.StatusBadRequest)
+			return
+		}
+	}
+	if v := params.Get("until"); v != "" {
+		if query.Until, err = time.Parse(time.RFC3339, v); err != nil {
+			http.Error(w, "invalid until timestamp", http.StatusBadRequest)
+			return
+		}
+	}
+	if v := params.Get("limit"); v != "" {
+		if query.Limit, err = strconv.Atoi(v); err != nil || query.Limit < 0 {
+			http.Error(w, "invalid limit", http.StatusBadRequest)
+			return
+		}
+	}
+
+	events, err := h.sink.Query(query)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if events == nil {
+		events = []service.AuditEvent{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{"events": events})
+}
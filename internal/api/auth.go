@@ -0,0 +1,166 @@
+package api
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"interview-task/internal/config"
+	"interview-task/internal/logging"
+	"interview-task/internal/service"
+)
+
+// Authenticator - IMPLEMENTS API key authentication as middleware. Keys
+// are sent as-is, or as AWS Signature Version 4 secrets by S3 clients.
+// Pre-signed URLs act as the key that requested them.
+type Authenticator struct {
+	// Keyed by SHA-256 of the API key so lookups don't compare raw secrets
+	principals map[[sha256.Size]byte]*service.Principal
+	// Keyed by key name, which S3 clients send as their access key ID
+	credentials map[string]*sigV4Credential
+	// managed are the keys managed through the admin API, looked up after
+	// the configured ones; they can't sign S3 requests
+	managed     *service.KeyManager
+	presigner   *Presigner
+	publicPaths map[string]bool
+	// publicObjects serves /objects/ without a key
+	publicObjects bool
+}
+
+// NewAuthenticator builds an authenticator from configured and managed
+// keys. With no keys configured and key management disabled every request
+// runs as the anonymous principal.
+func NewAuthenticator(keys []config.APIKeyConfig, managed *service.KeyManager, presigner *Presigner) (*Authenticator, error) {
+	principals := make(map[[sha256.Size]byte]*service.Principal, len(keys))
+	credentials := make(map[string]*sigV4Credential, len(keys))
+	for _, key := range keys {
+		if key.Key == "" || key.Name == "" {
+			return nil, fmt.Errorf("api key entries need both key and name")
+		}
+		hash := sha256.Sum256([]byte(key.Key))
+		if _, exists := principals[hash]; exists {
+			return nil, fmt.Errorf("duplicate api key for %s", key.Name)
+		}
+		if key.Tenant != "" {
+			if err := service.ValidateTenant(key.Tenant); err != nil {
+				return nil, fmt.Errorf("api key %s: %w", key.Name, err)
+			}
+		}
+		if _, exists := credentials[key.Name]; exists {
+			return nil, fmt.Errorf("duplicate api key name %s", key.Name)
+		}
+		if key.RateLimit < 0 || key.Burst < 0 {
+			return nil, fmt.Errorf("api key %s: rate_limit and burst must not be negative", key.Name)
+		}
+		principal := &service.Principal{Name: key.Name, Roles: key.Roles, Tenant: key.Tenant, RateLimit: key.RateLimit, Burst: key.Burst}
+		principals[hash] = principal
+		credentials[key.Name] = &sigV4Credential{secret: key.Key, principal: principal}
+	}
+
+	return &Authenticator{
+		principals:  principals,
+		credentials: credentials,
+		managed:     managed,
+		presigner:   presigner,
+		publicPaths: map[string]bool{"/health": true, "/readyz": true},
+	}, nil
+}
+
+func (a *Authenticator) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if len(a.principals) == 0 && a.managed == nil || a.publicPaths[r.URL.Path] || a.publicObjects && isObjectPath(r.URL.Path) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if isPresigned(r) {
+			principal, err := a.verifyPresigned(r)
+			if err != nil {
+				logging.Printf("Rejected pre-signed request for %s: %v", r.URL.Path, err)
+				http.Error(w, "Forbidden", http.StatusForbidden)
+				return
+			}
+			next.ServeHTTP(w, r.WithContext(service.WithPrincipal(r.Context(), principal)))
+			return
+		}
+
+		if isSigV4(r) {
+			principal, err := verifySigV4(r, a.credentials, time.Now())
+			if err != nil {
+				logging.Printf("Rejected signed request for %s: %v", r.URL.Path, err)
+				http.Error(w, "Forbidden", http.StatusForbidden)
+				return
+			}
+			next.ServeHTTP(w, r.WithContext(service.WithPrincipal(r.Context(), principal)))
+			return
+		}
+
+		hash := sha256.Sum256([]byte(apiKeyFromRequest(r)))
+		principal, ok := a.principals[hash]
+		if !ok {
+			principal, ok = a.managed.Lookup(hash)
+		}
+		if !ok {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			if strings.Contains(r.Header.Get("Accept"), "text/html") {
+				// Makes browsers prompt for a key, such as for the admin UI
+				w.Header().Add("WWW-Authenticate", `Basic realm="data service", charset="UTF-8"`)
+			}
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(service.WithPrincipal(r.Context(), principal)))
+	})
+}
+
+// ServeObjectsPublicly lets requests for stored objects through without a
+// key, as the anonymous principal
+func (a *Authenticator) ServeObjectsPublicly() {
+	a.publicObjects = true
+}
+
+// verifyPresigned checks a pre-signed URL and returns the current principal
+// of the key it acts as
+func (a *Authenticator) verifyPresigned(r *http.Request) (*service.Principal, error) {
+	name, err := a.presigner.verify(r, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	if credential, ok := a.credentials[name]; ok {
+		return credential.principal, nil
+	}
+	if principal, ok := a.managed.LookupName(name); ok {
+		return principal, nil
+	}
+	return nil, fmt.Errorf("unknown key %s", name)
+}
+
+// apiKeyFromRequest accepts an X-API-Key header, a bearer token, or basic
+// auth with the key as password, which browsers can send
+func apiKeyFromRequest(r *http.Request) string {
+	if key := r.Header.Get("X-API-Key"); key != "" {
+		return key
+	}
+	if token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok {
+		return strings.TrimSpace(token)
+	}
+	if _, password, ok := r.BasicAuth(); ok {
+		return password
+	}
+	return ""
+}
+
+// RequireRole only lets authenticated callers holding role through. The
+// anonymous principal never holds a role, so these routes stay closed while
+// authentication is disabled.
+func RequireRole(role string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !service.PrincipalFromContext(r.Context()).HasRole(role) {
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		}
+		next(w, r)
+	}
+}
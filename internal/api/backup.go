@@ -0,0 +1,88 @@
+package api
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"interview-task/internal/logging"
+	"interview-task/internal/service"
+)
+
+// BackupHandler exposes backups and restores to administrators. Both cover
+// every tenant, so keys bound to a single tenant may not use them.
+type BackupHandler struct {
+	backups *service.BackupManager
+}
+
+func NewBackupHandler(backups *service.BackupManager) *BackupHandler {
+	return &BackupHandler{backups: backups}
+}
+
+// HandleBackup streams an archive, restricted by repeated storage_type and
+// tenant query parameters
+func (h *BackupHandler) HandleBackup(w http.ResponseWriter, r *http.Request) {
+	if !requireGlobalKey(w, r) {
+		return
+	}
+	query := r.URL.Query()
+	filter := service.BackupFilter{StorageTypes: query["storage_type"], Tenants: query["tenant"]}
+	name := "backup-" + time.Now().UTC().Format("20060102T150405Z") + ".tar.gz"
+	out := &attachmentWriter{w: w, contentType: "application/gzip", name: name}
+	manifest, err := h.backups.Backup(r.Context(), out, filter)
+	switch {
+	case err != nil && !out.started:
+		http.Error(w, err.Error(), statusForError(err))
+	case err != nil:
+		// Too late for an error status; cutting the response short leaves
+		// the client an archive without its manifest
+		logging.Printf("Backup failed while streaming: %v", err)
+		panic(http.ErrAbortHandler)
+	default:
+		logging.Printf("Backed up %d keys", len(manifest.Entries))
+	}
+}
+
+// attachmentWriter sends a download's headers with its first bytes, so
+// errors found before then still get a status
+type attachmentWriter struct {
+	w           http.ResponseWriter
+	contentType string
+	name        string
+	started     bool
+}
+
+func (a *attachmentWriter) Write(p []byte) (int, error) {
+	if !a.started {
+		a.started = true
+		a.w.Header().Set("Content-Type", a.contentType)
+		a.w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", a.name))
+		a.w.WriteHeader(http.StatusOK)
+	}
+	return a.w.Write(p)
+}
+
+// HandleRestore restores the archive in the body, or with ?backup=<key> one
+// shipped by the scheduled job
+func (h *BackupHandler) HandleRestore(w http.ResponseWriter, r *http.Request) {
+	if !requireGlobalKey(w, r) {
+		return
+	}
+	var archive io.Reader = r.Body
+	if key := r.URL.Query().Get("backup"); key != "" {
+		data, err := h.backups.Shipped(key)
+		if err != nil {
+			http.Error(w, err.Error(), statusForError(err))
+			return
+		}
+		archive = bytes.NewReader(data)
+	}
+	report, err := h.backups.Restore(r.Context(), archive)
+	if err != nil {
+		writeJSON(w, statusForError(err), report)
+		return
+	}
+	writeJSON(w, http.StatusOK, report)
+}
@@ -0,0 +1,26 @@
+package api
+
+import (
+	"net/http"
+
+	"interview-task/internal/service"
+)
+
+// HandleSaveBatch saves the items of a BatchSaveRequest. Atomic batches
+// fail as a whole with the status of the failing item; other batches
+// report each item's outcome.
+func (h *HTTPHandler) HandleSaveBatch(w http.ResponseWriter, r *http.Request) {
+	var batch service.BatchSaveRequest
+	if codec, err := h.decode(r, &batch); err != nil {
+		writeDecodeError(w, codec, err, http.StatusBadRequest)
+		return
+	}
+
+	ctx := service.WithEndpoint(r.Context(), r.URL.Path)
+	results, err := h.dataService.SaveBatch(ctx, &batch)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+	h.respond(w, r, http.StatusOK, map[string]any{"items": results})
+}
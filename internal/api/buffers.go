@@ -0,0 +1,54 @@
+package api
+
+import (
+	"bytes"
+	"net/http"
+	"strconv"
+	"sync"
+)
+
+// maxPooledBuffer is the largest buffer returned to the pool. A rare huge
+// request would otherwise keep its memory alive for as long as the buffer
+// stays pooled.
+const maxPooledBuffer = 1 << 20
+
+// bufferPool - IMPLEMENTS reuse of the buffers request bodies are read into
+// and responses are encoded into, so the hot path doesn't allocate a new one
+// per request
+var bufferPool = sync.Pool{
+	New: func() any { return new(bytes.Buffer) },
+}
+
+func getBuffer() *bytes.Buffer {
+	return bufferPool.Get().(*bytes.Buffer)
+}
+
+// putBuffer returns a buffer to the pool. Nothing may use its bytes after.
+func putBuffer(buf *bytes.Buffer) {
+	if buf.Cap() > maxPooledBuffer {
+		return
+	}
+	buf.Reset()
+	bufferPool.Put(buf)
+}
+
+// writeJSON sends value as a JSON response with the given status
+func writeJSON(w http.ResponseWriter, code int, value any) {
+	writeEncoded(w, code, JSONCodec{}, value)
+}
+
+// writeEncoded sends value encoded by codec. It is encoded into a pooled
+// buffer first, so an encoding failure is still reported as a 500 and the
+// response carries its Content-Length.
+func writeEncoded(w http.ResponseWriter, code int, codec Codec, value any) {
+	buf := getBuffer()
+	defer putBuffer(buf)
+	if err := codec.Encode(buf, value); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", codec.MediaType())
+	w.Header().Set("Content-Length", strconv.Itoa(buf.Len()))
+	w.WriteHeader(code)
+	w.Write(buf.Bytes())
+}
@@ -0,0 +1,129 @@
+package api
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"interview-task/internal/config"
+	"interview-task/internal/metrics"
+	"interview-task/internal/service"
+	"interview-task/internal/storage"
+)
+
+// saveBody is a typical small save, with a base64 encoded payload
+var saveBody = []byte(`{"data":"aGVsbG8sIHdvcmxkIQ==","storage_type":"file"}`)
+
+// newBenchHandler builds a handler over file storage in a temporary
+// directory, with every optional feature left at its default
+func newBenchHandler(b *testing.B) *HTTPHandler {
+	b.Helper()
+	dir := b.TempDir()
+	// State files the defaults keep relative to the working directory, such
+	// as quota usage, land there too
+	b.Chdir(dir)
+	cfg := config.NewConfiguration()
+	cfg.DataDir = dir
+	cfg.AuditSink = "none"
+	cfg.Spool.Dir = filepath.Join(dir, "spool")
+
+	database, err := storage.NewDatabaseConnection(cfg.DatabaseHost, cfg.DatabasePort,
+		cfg.DatabaseUser, cfg.DatabasePass, cfg.DatabaseName, cfg.DatabasePool)
+	if err != nil {
+		b.Fatal(err)
+	}
+	factory, err := storage.NewStorageFactory(dir)
+	if err != nil {
+		b.Fatal(err)
+	}
+	m := metrics.NewMetrics()
+	events := service.NewEventBus(cfg.Events, m)
+	deps := service.Deps{
+		Factory:     factory,
+		Validator:   service.NewRequestValidator(cfg.ContentTypePolicy),
+		Audit:       &service.NopAuditSink{},
+		Expiry:      cfg.Expiry,
+		SoftDelete:  cfg.SoftDelete,
+		Events:      events,
+		Maintenance: service.NewMaintenance(cfg.Maintenance),
+		Outbox:      service.NewOutbox(cfg.Outbox, database, events, m),
+	}
+	must := func(err error) {
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+	deps.Derivations, err = service.NewDerivationRegistryFromConfig(cfg.Derivations)
+	must(err)
+	deps.Extractor, err = service.NewMetadataExtractor(cfg.MetadataRules)
+	must(err)
+	deps.Quotas, err = service.NewQuotaManager(cfg.Quotas)
+	must(err)
+	deps.Watermarks, err = service.NewWatermarkTracker(filepath.Join(dir, "watermarks.json"))
+	must(err)
+	deps.Holds, err = service.NewTenantHolds(filepath.Join(dir, "legal-holds.json"))
+	must(err)
+	deps.Spool, err = service.NewSpool(cfg.Spool)
+	must(err)
+	deps.Locks, err = service.NewItemLocks(cfg.Locking, database)
+	must(err)
+	deps.Fields, err = service.NewFieldEncryptor(cfg.FieldEncryption)
+	must(err)
+	deps.Access, err = service.NewStorageAccess(cfg.StorageAccess)
+	must(err)
+	deps.Budgets, err = service.NewBudgets(cfg.Timeouts)
+	must(err)
+	deps.Index, err = service.NewItemIndex(cfg.Index, database)
+	must(err)
+	return NewHTTPHandler(service.NewDataService(deps))
+}
+
+func BenchmarkWriteJSON(b *testing.B) {
+	response := &SaveResponse{
+		Message: "Data saved successfully",
+		Status:  "success",
+		ID:      "0123456789abcdef",
+		SHA256:  storage.PayloadSHA256([]byte("hello, world!")),
+	}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		writeJSON(httptest.NewRecorder(), http.StatusOK, response)
+	}
+}
+
+func BenchmarkHandleSaveData(b *testing.B) {
+	h := newBenchHandler(b)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		r := httptest.NewRequest(http.MethodPost, "/v1/save-data", bytes.NewReader(saveBody))
+		r.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		h.HandleSaveData(w, r)
+		if w.Code != http.StatusOK {
+			b.Fatalf("save returned %d: %s", w.Code, w.Body)
+		}
+	}
+}
+
+func BenchmarkIdempotencyWrap(b *testing.B) {
+	h := newBenchHandler(b)
+	wrapped := NewIdempotencyStore(time.Hour).Wrap(h.HandleSaveData)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		r := httptest.NewRequest(http.MethodPost, "/v1/save-data", bytes.NewReader(saveBody))
+		r.Header.Set("Content-Type", "application/json")
+		// A new key each time, so every request is handled rather than replayed
+		r.Header.Set(HeaderIdempotencyKey, strconv.Itoa(i))
+		w := httptest.NewRecorder()
+		wrapped(w, r)
+		if w.Code != http.StatusOK {
+			b.Fatalf("save returned %d: %s", w.Code, w.Body)
+		}
+	}
+}
@@ -0,0 +1,94 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"interview-task/internal/config"
+	"interview-task/internal/metrics"
+)
+
+// Bulkheads - IMPLEMENTS per-route concurrency limits, so one heavy
+// endpoint can't starve the others. Every API version of a route shares
+// its bulkhead.
+type Bulkheads struct {
+	// slots are the semaphores of the configured routes
+	slots      map[string]chan struct{}
+	retryAfter time.Duration
+	metrics    *metrics.Metrics
+
+	mu      sync.Mutex
+	matched map[string]bool
+}
+
+func NewBulkheads(config config.LoadSheddingConfig, metrics *metrics.Metrics) (*Bulkheads, error) {
+	slots := make(map[string]chan struct{}, len(config.Bulkheads))
+	for route, limit := range config.Bulkheads {
+		if limit <= 0 {
+			return nil, fmt.Errorf("bulkhead for %s must allow at least one request", route)
+		}
+		slots[route] = make(chan struct{}, limit)
+	}
+	metrics.Describe("bulkhead_rejected_total", "Requests rejected by a route's concurrency limit")
+	return &Bulkheads{
+		slots:      slots,
+		retryAfter: max(time.Duration(config.RetryAfter), time.Second),
+		metrics:    metrics,
+		matched:    make(map[string]bool),
+	}, nil
+}
+
+// Wrap limits handler by the bulkhead of a route pattern such as
+// "POST /import", configured for the pattern or its path
+func (b *Bulkheads) Wrap(pattern string, handler http.HandlerFunc) http.HandlerFunc {
+	if b == nil {
+		return handler
+	}
+	route := pattern
+	slots, ok := b.slots[route]
+	if !ok {
+		_, route, _ = strings.Cut(pattern, " ")
+		if route == "" {
+			route = pattern
+		}
+		if slots, ok = b.slots[route]; !ok {
+			return handler
+		}
+	}
+	b.mu.Lock()
+	b.matched[route] = true
+	b.mu.Unlock()
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case slots <- struct{}{}:
+		default:
+			b.metrics.Add("bulkhead_rejected_total", 1, "route", route)
+			w.Header().Set("Retry-After", strconv.Itoa(int(b.retryAfter/time.Second)))
+			http.Error(w, "Too many concurrent requests for "+route+", retry later", http.StatusTooManyRequests)
+			return
+		}
+		defer func() { <-slots }()
+		handler(w, r)
+	}
+}
+
+// Unmatched returns the configured routes no handler was wrapped for,
+// which are most likely misspelt
+func (b *Bulkheads) Unmatched() []string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	var routes []string
+	for route := range b.slots {
+		if !b.matched[route] {
+			routes = append(routes, route)
+		}
+	}
+	slices.Sort(routes)
+	return routes
+}
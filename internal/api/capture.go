@@ -0,0 +1,294 @@
+package api
+
+import (
+	"encoding/base64"
+	"errors"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"interview-task/internal/config"
+	"interview-task/internal/service"
+)
+
+// captureOmittedHeaders carry credentials, so they are left out of captures
+var captureOmittedHeaders = []string{"Authorization", "Proxy-Authorization", "X-Api-Key", "Cookie", "Set-Cookie", "X-Amz-Security-Token"}
+
+// captureOmittedParams are signatures of pre-signed and query-signed URLs
+var captureOmittedParams = []string{presignSignatureParam, "X-Amz-Signature"}
+
+// CapturedBody is the start of a request or response body
+type CapturedBody struct {
+	Data string `json:"data"`
+	// Encoding is "base64" for a body that isn't UTF-8 text
+	Encoding string `json:"encoding,omitempty"`
+	// Size is the whole body's, of which Data holds up to max_body_bytes
+	Size      int64 `json:"size"`
+	Truncated bool  `json:"truncated,omitempty"`
+}
+
+// Capture is one recorded exchange. Personal data in bodies, the query and
+// headers is masked.
+type Capture struct {
+	Time            time.Time    `json:"time"`
+	RequestID       string       `json:"request_id"`
+	Tenant          string       `json:"tenant"`
+	Principal       string       `json:"principal"`
+	Method          string       `json:"method"`
+	Path            string       `json:"path"`
+	Query           string       `json:"query,omitempty"`
+	Route           string       `json:"route,omitempty"`
+	Status          int          `json:"status"`
+	DurationMillis  int64        `json:"duration_ms"`
+	RequestHeaders  http.Header  `json:"request_headers"`
+	Request         CapturedBody `json:"request"`
+	ResponseHeaders http.Header  `json:"response_headers"`
+	Response        CapturedBody `json:"response"`
+}
+
+// BodyCapture - IMPLEMENTS sampled request and response body capture as
+// middleware, kept in a ring buffer for GET /admin/captures. Bodies are
+// recorded as far as the handler read them, so a payload it rejected part
+// way is cut short there.
+type BodyCapture struct {
+	config config.BodyCaptureConfig
+	pii    *service.PIIScanner
+
+	mu sync.Mutex
+	// ring holds the captures, next is where the next one goes
+	ring  []Capture
+	next  int
+	count int
+}
+
+// NewBodyCapture returns nil while both sample rates are zero. pii masks
+// personal data in what is kept.
+func NewBodyCapture(config config.BodyCaptureConfig, pii *service.PIIScanner) (*BodyCapture, error) {
+	if config.SampleRate == 0 && config.ErrorSampleRate == 0 {
+		return nil, nil
+	}
+	if config.SampleRate < 0 || config.SampleRate > 1 || config.ErrorSampleRate < 0 || config.ErrorSampleRate > 1 {
+		return nil, errors.New("sample rates must be between 0 and 1")
+	}
+	if config.MaxBodyBytes <= 0 || config.Capacity <= 0 {
+		return nil, errors.New("max_body_bytes and capacity must be positive")
+	}
+	return &BodyCapture{config: config, pii: pii, ring: make([]Capture, config.Capacity)}, nil
+}
+
+// Middleware runs right before routing, so it sees the decompressed bodies
+// and the matched route
+func (c *BodyCapture) Middleware(next http.Handler) http.Handler {
+	if c == nil {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		sampled := rand.Float64() < c.config.SampleRate
+		if !sampled && c.config.ErrorSampleRate == 0 {
+			next.ServeHTTP(w, r)
+			return
+		}
+		request := &capturingReader{ReadCloser: r.Body, limit: c.config.MaxBodyBytes}
+		r.Body = request
+		response := &capturingWriter{ResponseWriter: w, limit: c.config.MaxBodyBytes}
+		started := time.Now()
+		next.ServeHTTP(response, r)
+		if response.status == 0 {
+			response.status = http.StatusOK
+		}
+		if !sampled && (response.status < 400 || rand.Float64() >= c.config.ErrorSampleRate) {
+			return
+		}
+
+		ctx := r.Context()
+		query := r.URL.Query()
+		for _, param := range captureOmittedParams {
+			query.Del(param)
+		}
+		c.add(Capture{
+			Time:            started.UTC(),
+			RequestID:       service.RequestIDFromContext(ctx),
+			Tenant:          service.TenantFromContext(ctx),
+			Principal:       service.PrincipalFromContext(ctx).Name,
+			Method:          r.Method,
+			Path:            r.URL.Path,
+			Query:           c.query(query),
+			Route:           r.Pattern,
+			Status:          response.status,
+			DurationMillis:  time.Since(started).Milliseconds(),
+			RequestHeaders:  c.headers(r.Header),
+			Request:         c.body(request.data, request.size),
+			ResponseHeaders: c.headers(w.Header()),
+			Response:        c.body(response.data, response.size),
+		})
+	})
+}
+
+// query formats query parameters unescaped, so that personal data in them
+// is recognized and masked
+func (c *BodyCapture) query(query url.Values) string {
+	encoded := query.Encode()
+	if unescaped, err := url.QueryUnescape(encoded); err == nil {
+		encoded = unescaped
+	}
+	return string(c.pii.Redact([]byte(encoded)))
+}
+
+// headers copies headers without credentials, masking personal data
+func (c *BodyCapture) headers(header http.Header) http.Header {
+	captured := header.Clone()
+	for _, name := range captureOmittedHeaders {
+		captured.Del(name)
+	}
+	for name, values := range captured {
+		for i, value := range values {
+			values[i] = string(c.pii.Redact([]byte(value)))
+		}
+		captured[name] = values
+	}
+	return captured
+}
+
+// body masks personal data in a text body; other bodies are kept as base64
+func (c *BodyCapture) body(data []byte, size int64) CapturedBody {
+	truncated := size > int64(len(data))
+	// A body cut off within a character is still text
+	text := data
+	for trim := 0; truncated && trim < utf8.UTFMax && len(text) > 0 && !utf8.Valid(text); trim++ {
+		text = text[:len(text)-1]
+	}
+	if utf8.Valid(text) {
+		return CapturedBody{Data: string(c.pii.Redact(text)), Size: size, Truncated: truncated}
+	}
+	return CapturedBody{Data: base64.StdEncoding.EncodeToString(data), Encoding: "base64", Size: size, Truncated: truncated}
+}
+
+func (c *BodyCapture) add(capture Capture) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.ring[c.next] = capture
+	c.next = (c.next + 1) % len(c.ring)
+	c.count = min(c.count+1, len(c.ring))
+}
+
+// Captures returns the captures of one tenant, or every tenant when tenant
+// is empty, with a status of at least minStatus, most recent first
+func (c *BodyCapture) Captures(tenant string, minStatus, limit int) []Capture {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	captures := []Capture{}
+	for i := 1; i <= c.count; i++ {
+		capture := c.ring[(c.next-i+len(c.ring))%len(c.ring)]
+		if (tenant != "" && capture.Tenant != tenant) || capture.Status < minStatus {
+			continue
+		}
+		if limit > 0 && len(captures) == limit {
+			break
+		}
+		captures = append(captures, capture)
+	}
+	return captures
+}
+
+// Clear drops every capture
+func (c *BodyCapture) Clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	clear(c.ring)
+	c.next, c.count = 0, 0
+}
+
+// HandleList returns captures filtered by ?tenant=, ?min_status= and
+// ?limit=. Keys bound to a tenant only see their own tenant.
+func (c *BodyCapture) HandleList(w http.ResponseWriter, r *http.Request) {
+	if c == nil {
+		http.Error(w, "body capture is disabled", http.StatusNotFound)
+		return
+	}
+	params := r.URL.Query()
+	tenant := params.Get("tenant")
+	if bound := service.PrincipalFromContext(r.Context()).Tenant; bound != "" {
+		tenant = bound
+	}
+	minStatus, limit := 0, 100
+	for name, value := range map[string]*int{"min_status": &minStatus, "limit": &limit} {
+		if v := params.Get(name); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 0 {
+				http.Error(w, "invalid "+name, http.StatusBadRequest)
+				return
+			}
+			*value = n
+		}
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"captures": c.Captures(tenant, minStatus, limit)})
+}
+
+// HandleClear drops every capture, such as once a problem is understood
+func (c *BodyCapture) HandleClear(w http.ResponseWriter, r *http.Request) {
+	if c == nil {
+		http.Error(w, "body capture is disabled", http.StatusNotFound)
+		return
+	}
+	if !requireGlobalKey(w, r) {
+		return
+	}
+	c.Clear()
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// capturingReader keeps the start of a request body as it is read
+type capturingReader struct {
+	io.ReadCloser
+	limit int
+	data  []byte
+	size  int64
+}
+
+func (c *capturingReader) Read(p []byte) (int, error) {
+	n, err := c.ReadCloser.Read(p)
+	c.size += int64(n)
+	if room := c.limit - len(c.data); room > 0 {
+		c.data = append(c.data, p[:min(n, room)]...)
+	}
+	return n, err
+}
+
+// capturingWriter notes the status of a response and keeps the start of
+// its body
+type capturingWriter struct {
+	http.ResponseWriter
+	limit  int
+	status int
+	data   []byte
+	size   int64
+}
+
+// Unwrap gives http.ResponseController access to the connection's writer
+func (c *capturingWriter) Unwrap() http.ResponseWriter {
+	return c.ResponseWriter
+}
+
+func (c *capturingWriter) WriteHeader(status int) {
+	if c.status == 0 {
+		c.status = status
+	}
+	c.ResponseWriter.WriteHeader(status)
+}
+
+func (c *capturingWriter) Write(data []byte) (int, error) {
+	if c.status == 0 {
+		c.status = http.StatusOK
+	}
+	n, err := c.ResponseWriter.Write(data)
+	c.size += int64(n)
+	if room := c.limit - len(c.data); room > 0 {
+		c.data = append(c.data, data[:min(n, room)]...)
+	}
+	return n, err
+}
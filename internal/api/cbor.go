@@ -0,0 +1,335 @@
+package api
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+)
+
+// CBOR major types (RFC 8949)
+const (
+	cborUnsigned = 0
+	cborNegative = 1
+	cborBytes    = 2
+	cborText     = 3
+	cborArray    = 4
+	cborMap      = 5
+	cborTag      = 6
+	cborSimple   = 7
+)
+
+// cborIndefinite is the additional information of indefinite-length items
+const cborIndefinite = 31
+
+var (
+	errCBORTruncated = errors.New("truncated CBOR document")
+	// errCBORBreak is what reading the "break" ending an indefinite-length
+	// item returns; anywhere else it is malformed input
+	errCBORBreak = errors.New("unexpected CBOR break")
+)
+
+// CBORCodec - IMPLEMENTS CBOR bodies, the same way MsgpackCodec does
+// MessagePack: values are encoded as their JSON encoding describes them,
+// and clients send payloads as byte strings. Tags are ignored.
+type CBORCodec struct{}
+
+func (CBORCodec) Name() string        { return "CBOR" }
+func (CBORCodec) MediaType() string   { return "application/cbor" }
+func (CBORCodec) Supports(v any) bool { return true }
+
+func (CBORCodec) Encode(w io.Writer, v any) error {
+	tree, err := toGeneric(v)
+	if err != nil {
+		return err
+	}
+	encoded, err := appendCBOR(nil, tree)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(encoded)
+	return err
+}
+
+func (CBORCodec) Decode(r io.Reader, v any) error {
+	return decodeBuffered(r, func(data []byte) error {
+		d := &cborDecoder{data: data}
+		tree, err := d.value(0)
+		if err != nil {
+			return err
+		}
+		if len(d.data) > 0 {
+			return fmt.Errorf("unexpected data after CBOR document")
+		}
+		return fromGeneric(tree, v)
+	})
+}
+
+// appendCBORHead appends the initial byte of an item and its argument in
+// the shortest form
+func appendCBORHead(buf []byte, major byte, n uint64) []byte {
+	major <<= 5
+	switch {
+	case n < 24:
+		return append(buf, major|byte(n))
+	case n <= math.MaxUint8:
+		return append(buf, major|24, byte(n))
+	case n <= math.MaxUint16:
+		return binary.BigEndian.AppendUint16(append(buf, major|25), uint16(n))
+	case n <= math.MaxUint32:
+		return binary.BigEndian.AppendUint32(append(buf, major|26), uint32(n))
+	default:
+		return binary.BigEndian.AppendUint64(append(buf, major|27), n)
+	}
+}
+
+func appendCBOR(buf []byte, value any) ([]byte, error) {
+	switch v := value.(type) {
+	case nil:
+		return append(buf, 0xf6), nil
+	case bool:
+		if v {
+			return append(buf, 0xf5), nil
+		}
+		return append(buf, 0xf4), nil
+	case json.Number:
+		n, err := genericNumber(v)
+		if err != nil {
+			return nil, err
+		}
+		switch n := n.(type) {
+		case int64:
+			if n < 0 {
+				return appendCBORHead(buf, cborNegative, uint64(-1-n)), nil
+			}
+			return appendCBORHead(buf, cborUnsigned, uint64(n)), nil
+		case uint64:
+			return appendCBORHead(buf, cborUnsigned, n), nil
+		default:
+			return binary.BigEndian.AppendUint64(append(buf, 0xfb), math.Float64bits(n.(float64))), nil
+		}
+	case string:
+		buf = appendCBORHead(buf, cborText, uint64(len(v)))
+		return append(buf, v...), nil
+	case []any:
+		buf = appendCBORHead(buf, cborArray, uint64(len(v)))
+		var err error
+		for _, item := range v {
+			if buf, err = appendCBOR(buf, item); err != nil {
+				return nil, err
+			}
+		}
+		return buf, nil
+	case map[string]any:
+		buf = appendCBORHead(buf, cborMap, uint64(len(v)))
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		var err error
+		for _, key := range keys {
+			buf = appendCBORHead(buf, cborText, uint64(len(key)))
+			buf = append(buf, key...)
+			if buf, err = appendCBOR(buf, v[key]); err != nil {
+				return nil, err
+			}
+		}
+		return buf, nil
+	default:
+		return nil, fmt.Errorf("cannot encode %T as CBOR", value)
+	}
+}
+
+// cborDecoder decodes a CBOR document into a generic tree
+type cborDecoder struct {
+	data []byte
+}
+
+func (d *cborDecoder) take(n uint64) ([]byte, error) {
+	if n > uint64(len(d.data)) {
+		return nil, errCBORTruncated
+	}
+	taken := d.data[:n]
+	d.data = d.data[n:]
+	return taken, nil
+}
+
+// head reads the initial byte of an item and its argument. For
+// indefinite-length items it returns indefinite set and no argument.
+func (d *cborDecoder) head() (major byte, info byte, n uint64, err error) {
+	b, err := d.take(1)
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	major, info = b[0]>>5, b[0]&0x1f
+	switch {
+	case info < 24:
+		return major, info, uint64(info), nil
+	case info <= 27:
+		arg, err := d.take(1 << (info - 24))
+		if err != nil {
+			return 0, 0, 0, err
+		}
+		for _, c := range arg {
+			n = n<<8 | uint64(c)
+		}
+		return major, info, n, nil
+	case info == cborIndefinite:
+		if major == cborSimple {
+			return 0, 0, 0, errCBORBreak
+		}
+		return major, info, 0, nil
+	default:
+		return 0, 0, 0, fmt.Errorf("malformed CBOR item 0x%02x", b[0])
+	}
+}
+
+func (d *cborDecoder) value(depth int) (any, error) {
+	if depth > maxCodecDepth {
+		return nil, fmt.Errorf("CBOR document is nested too deeply")
+	}
+	major, info, n, err := d.head()
+	if err != nil {
+		return nil, err
+	}
+	indefinite := info == cborIndefinite
+	if indefinite && (major < cborBytes || major > cborMap) {
+		return nil, fmt.Errorf("malformed indefinite-length CBOR item")
+	}
+
+	switch major {
+	case cborUnsigned:
+		return n, nil
+	case cborNegative:
+		if n > math.MaxInt64 {
+			return nil, fmt.Errorf("CBOR integer is out of range")
+		}
+		return -1 - int64(n), nil
+	case cborBytes, cborText:
+		chunks := [][]byte{}
+		if indefinite {
+			// An indefinite string is a series of definite chunks of its
+			// own major type, ended by a break
+			for {
+				chunkMajor, chunkInfo, size, err := d.head()
+				if errors.Is(err, errCBORBreak) {
+					break
+				}
+				if err != nil {
+					return nil, err
+				}
+				if chunkMajor != major || chunkInfo == cborIndefinite {
+					return nil, fmt.Errorf("malformed indefinite-length CBOR string")
+				}
+				chunk, err := d.take(size)
+				if err != nil {
+					return nil, err
+				}
+				chunks = append(chunks, chunk)
+			}
+		} else {
+			chunk, err := d.take(n)
+			if err != nil {
+				return nil, err
+			}
+			chunks = append(chunks, chunk)
+		}
+		var joined []byte
+		for _, chunk := range chunks {
+			joined = append(joined, chunk...)
+		}
+		if major == cborText {
+			return string(joined), nil
+		}
+		return append([]byte{}, joined...), nil
+	case cborArray:
+		if !indefinite && n > uint64(len(d.data)) {
+			return nil, errCBORTruncated
+		}
+		items := []any{}
+		for i := uint64(0); indefinite || i < n; i++ {
+			item, err := d.value(depth + 1)
+			if indefinite && errors.Is(err, errCBORBreak) {
+				break
+			}
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, item)
+		}
+		return items, nil
+	case cborMap:
+		if !indefinite && n > uint64(len(d.data)) {
+			return nil, errCBORTruncated
+		}
+		fields := make(map[string]any)
+		for i := uint64(0); indefinite || i < n; i++ {
+			key, err := d.value(depth + 1)
+			if indefinite && errors.Is(err, errCBORBreak) {
+				break
+			}
+			if err != nil {
+				return nil, err
+			}
+			name, ok := key.(string)
+			if !ok {
+				return nil, fmt.Errorf("CBOR map keys must be text strings")
+			}
+			if fields[name], err = d.value(depth + 1); err != nil {
+				return nil, err
+			}
+		}
+		return fields, nil
+	case cborTag:
+		return d.value(depth + 1)
+	default:
+		return d.simple(info, n)
+	}
+}
+
+// simple decodes the booleans, null, undefined and floats of major type 7
+func (d *cborDecoder) simple(info byte, n uint64) (any, error) {
+	switch info {
+	case 20:
+		return false, nil
+	case 21:
+		return true, nil
+	case 22, 23:
+		return nil, nil
+	case 25:
+		return halfToFloat(uint16(n)), nil
+	case 26:
+		return float64(math.Float32frombits(uint32(n))), nil
+	case 27:
+		return math.Float64frombits(n), nil
+	default:
+		return nil, fmt.Errorf("unsupported CBOR simple value %d", n)
+	}
+}
+
+// halfToFloat converts an IEEE 754 half-precision float
+func halfToFloat(h uint16) float64 {
+	exponent := int(h>>10) & 0x1f
+	mantissa := float64(h & 0x3ff)
+	var f float64
+	switch exponent {
+	case 0:
+		f = math.Ldexp(mantissa, -24)
+	case 31:
+		if mantissa == 0 {
+			f = math.Inf(1)
+		} else {
+			f = math.NaN()
+		}
+	default:
+		f = math.Ldexp(mantissa+1024, exponent-25)
+	}
+	if h&0x8000 != 0 {
+		return -f
+	}
+	return f
+}
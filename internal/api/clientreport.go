@@ -0,0 +1,197 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"interview-task/internal/logging"
+	"interview-task/internal/service"
+)
+
+// Client-side failure kinds SDKs may report
+var clientReportKinds = []string{"timeout", "connection", "checksum_mismatch", "decode", "other"}
+
+// maxClientReportMessage bounds the free-text part of a report
+const maxClientReportMessage = 1024
+
+// ClientReport is a failure observed by a client, tied to the request ID the
+// server returned or the client sent. Tenant, Reporter and the server
+// outcome are filled in by the server.
+type ClientReport struct {
+	RequestID  string    `json:"request_id"`
+	Kind       string    `json:"kind"`
+	Operation  string    `json:"operation,omitempty"`
+	ItemID     string    `json:"item_id,omitempty"`
+	Message    string    `json:"message,omitempty"`
+	SDK        string    `json:"sdk,omitempty"`
+	OccurredAt time.Time `json:"occurred_at"`
+
+	ReceivedAt time.Time `json:"received_at"`
+	Tenant     string    `json:"tenant"`
+	Reporter   string    `json:"reporter"`
+	// ServerOutcome is the audited outcome of the reported request:
+	// "success" marks a failure only the client saw, empty means the
+	// request never reached an audited mutation
+	ServerOutcome string `json:"server_outcome,omitempty"`
+}
+
+// ClientReportSummary aggregates client reports since the server started
+type ClientReportSummary struct {
+	Total  int            `json:"total"`
+	ByKind map[string]int `json:"by_kind"`
+	// ServerSucceeded counts reports for requests the server completed
+	// successfully
+	ServerSucceeded int            `json:"server_succeeded"`
+	Reports         []ClientReport `json:"reports"`
+}
+
+// ClientReportCollector - IMPLEMENTS the client error feedback loop. Reports
+// are matched against the audit trail and the most recent ones kept in
+// memory; the counters reset on restart.
+type ClientReportCollector struct {
+	audit  service.AuditSink
+	retain int
+
+	mu              sync.Mutex
+	reports         []ClientReport
+	byTenant        map[string]map[string]int
+	serverSucceeded map[string]int
+}
+
+func NewClientReportCollector(audit service.AuditSink, retain int) *ClientReportCollector {
+	return &ClientReportCollector{
+		audit:           audit,
+		retain:          retain,
+		byTenant:        make(map[string]map[string]int),
+		serverSucceeded: make(map[string]int),
+	}
+}
+
+func validateClientReport(report *ClientReport) error {
+	if !requestIDPattern.MatchString(report.RequestID) {
+		return fmt.Errorf("invalid request_id: %q", report.RequestID)
+	}
+	if !slices.Contains(clientReportKinds, report.Kind) {
+		return fmt.Errorf("kind must be one of %v", clientReportKinds)
+	}
+	if len(report.Message) > maxClientReportMessage {
+		report.Message = report.Message[:maxClientReportMessage]
+	}
+	return nil
+}
+
+// Record stores a report and returns it with the server-side fields set
+func (c *ClientReportCollector) Record(report ClientReport) ClientReport {
+	events, err := c.audit.Query(service.AuditQuery{Tenant: report.Tenant, RequestID: report.RequestID})
+	if err != nil {
+		logging.Printf("Failed to look up request %s for client report: %v", report.RequestID, err)
+	}
+	for _, event := range events {
+		// A failure anywhere in the request outweighs earlier successes
+		if report.ServerOutcome != "failure" {
+			report.ServerOutcome = event.Outcome
+		}
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	counts := c.byTenant[report.Tenant]
+	if counts == nil {
+		counts = make(map[string]int)
+		c.byTenant[report.Tenant] = counts
+	}
+	counts[report.Kind]++
+	if report.ServerOutcome == "success" {
+		c.serverSucceeded[report.Tenant]++
+	}
+	c.reports = append(c.reports, report)
+	if len(c.reports) > c.retain {
+		c.reports = slices.Delete(c.reports, 0, len(c.reports)-c.retain)
+	}
+	return report
+}
+
+// Summary aggregates reports for one tenant, or every tenant when tenant is
+// empty, with the most recent reports first
+func (c *ClientReportCollector) Summary(tenant, kind string, limit int) ClientReportSummary {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	summary := ClientReportSummary{ByKind: make(map[string]int), Reports: []ClientReport{}}
+	for t, counts := range c.byTenant {
+		if tenant != "" && t != tenant {
+			continue
+		}
+		for k, n := range counts {
+			summary.ByKind[k] += n
+			summary.Total += n
+		}
+		summary.ServerSucceeded += c.serverSucceeded[t]
+	}
+	for i := len(c.reports) - 1; i >= 0; i-- {
+		report := c.reports[i]
+		if (tenant != "" && report.Tenant != tenant) || (kind != "" && report.Kind != kind) {
+			continue
+		}
+		if limit > 0 && len(summary.Reports) == limit {
+			break
+		}
+		summary.Reports = append(summary.Reports, report)
+	}
+	return summary
+}
+
+type ClientReportHandler struct {
+	collector *ClientReportCollector
+}
+
+func NewClientReportHandler(collector *ClientReportCollector) *ClientReportHandler {
+	return &ClientReportHandler{collector: collector}
+}
+
+// HandleReport accepts a ClientReport from any caller allowed to use the API
+func (h *ClientReportHandler) HandleReport(w http.ResponseWriter, r *http.Request) {
+	var report ClientReport
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 64<<10)).Decode(&report); err != nil {
+		http.Error(w, "Invalid JSON format", http.StatusBadRequest)
+		return
+	}
+	if err := validateClientReport(&report); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	ctx := r.Context()
+	report.ReceivedAt = time.Now().UTC()
+	if report.OccurredAt.IsZero() {
+		report.OccurredAt = report.ReceivedAt
+	}
+	report.Tenant = service.TenantFromContext(ctx)
+	report.Reporter = service.PrincipalFromContext(ctx).Name
+	report.ServerOutcome = ""
+	writeJSON(w, http.StatusAccepted, h.collector.Record(report))
+}
+
+// HandleSummary returns aggregated reports filtered by ?tenant=, ?kind= and
+// ?limit=. Keys bound to a tenant only see their own tenant.
+func (h *ClientReportHandler) HandleSummary(w http.ResponseWriter, r *http.Request) {
+	params := r.URL.Query()
+	tenant := params.Get("tenant")
+	if bound := service.PrincipalFromContext(r.Context()).Tenant; bound != "" {
+		tenant = bound
+	}
+	limit := 100
+	if v := params.Get("limit"); v != "" {
+		var err error
+		if limit, err = strconv.Atoi(v); err != nil || limit < 0 {
+			http.Error(w, "invalid limit", http.StatusBadRequest)
+			return
+		}
+	}
+	writeJSON(w, http.StatusOK, h.collector.Summary(tenant, params.Get("kind"), limit))
+}
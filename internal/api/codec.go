@@ -0,0 +1,246 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"interview-task/internal/service"
+)
+
+// maxCodecDepth bounds the nesting of decoded msgpack and CBOR documents
+const maxCodecDepth = 64
+
+// ErrUnsupportedMediaType is returned for bodies in a format the endpoint
+// can't decode
+var ErrUnsupportedMediaType = errors.New("unsupported media type")
+
+// Codec encodes and decodes request and response bodies in one media type
+type Codec interface {
+	// Name is the format's name in error messages, such as "JSON"
+	Name() string
+	MediaType() string
+	// Supports reports whether values like v can be encoded and decoded;
+	// schema-bound formats only support their message types
+	Supports(v any) bool
+	Encode(w io.Writer, v any) error
+	Decode(r io.Reader, v any) error
+}
+
+// CodecRegistry - IMPLEMENTS content negotiation over the registered codecs.
+// JSON is the default: requests in unregistered media types are decoded as
+// JSON, as they were before other codecs existed.
+type CodecRegistry struct {
+	byMediaType map[string]Codec
+	fallback    Codec
+}
+
+func NewCodecRegistry(fallback Codec) *CodecRegistry {
+	r := &CodecRegistry{byMediaType: make(map[string]Codec), fallback: fallback}
+	r.Register(fallback)
+	return r
+}
+
+// DefaultCodecs returns the registry of the formats the data API speaks
+func DefaultCodecs() *CodecRegistry {
+	r := NewCodecRegistry(JSONCodec{})
+	r.Register(ProtobufCodec{}, "application/protobuf")
+	r.Register(MsgpackCodec{}, "application/x-msgpack", "application/vnd.msgpack")
+	r.Register(CBORCodec{})
+	return r
+}
+
+// Register adds a codec under its media type and any aliases
+func (r *CodecRegistry) Register(codec Codec, aliases ...string) {
+	r.byMediaType[codec.MediaType()] = codec
+	for _, alias := range aliases {
+		r.byMediaType[alias] = codec
+	}
+}
+
+// ForRequest returns the codec of a request's Content-Type
+func (r *CodecRegistry) ForRequest(req *http.Request) Codec {
+	return r.ForContentType(req.Header.Get("Content-Type"))
+}
+
+// ForContentType returns the codec of a Content-Type header value
+func (r *CodecRegistry) ForContentType(contentType string) Codec {
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	if codec, ok := r.byMediaType[mediaType]; ok {
+		return codec
+	}
+	return r.fallback
+}
+
+// ForResponse returns the codec the client prefers in Accept among those
+// that can encode v. Wildcards select the default.
+func (r *CodecRegistry) ForResponse(req *http.Request, v any) Codec {
+	best, bestQ := r.fallback, 0.0
+	for _, part := range strings.Split(req.Header.Get("Accept"), ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		q := 1.0
+		if value, ok := params["q"]; ok {
+			if q, err = strconv.ParseFloat(value, 64); err != nil {
+				continue
+			}
+		}
+		codec, ok := r.byMediaType[mediaType]
+		if ok && q > bestQ && codec.Supports(v) {
+			best, bestQ = codec, q
+		}
+	}
+	return best
+}
+
+// decode reads a request body in the codec of its Content-Type
+func (h *HTTPHandler) decode(r *http.Request, v any) (Codec, error) {
+	codec := h.codecs.ForRequest(r)
+	if !codec.Supports(v) {
+		return codec, fmt.Errorf("%w: %s bodies are not supported on %s", ErrUnsupportedMediaType, codec.Name(), r.URL.Path)
+	}
+	return codec, codec.Decode(r.Body, v)
+}
+
+// writeDecodeError reports a body decode failed on. Malformed bodies get
+// status, which differs between endpoints for historical reasons.
+func writeDecodeError(w http.ResponseWriter, codec Codec, err error, status int) {
+	if errors.Is(err, ErrUnsupportedMediaType) {
+		http.Error(w, err.Error(), http.StatusUnsupportedMediaType)
+		return
+	}
+	http.Error(w, "Invalid "+codec.Name()+" format", status)
+}
+
+// respond sends v in the codec negotiated from the request's Accept header
+func (h *HTTPHandler) respond(w http.ResponseWriter, r *http.Request, code int, v any) {
+	w.Header().Add("Vary", "Accept")
+	writeEncoded(w, code, h.codecs.ForResponse(r, v), v)
+}
+
+// JSONCodec is the default codec
+type JSONCodec struct{}
+
+func (JSONCodec) Name() string        { return "JSON" }
+func (JSONCodec) MediaType() string   { return "application/json" }
+func (JSONCodec) Supports(v any) bool { return true }
+
+func (JSONCodec) Encode(w io.Writer, v any) error {
+	return json.NewEncoder(w).Encode(v)
+}
+
+// Decode parses the body straight from the reader, without buffering it
+func (JSONCodec) Decode(r io.Reader, v any) error {
+	return json.NewDecoder(r).Decode(v)
+}
+
+// protoUnmarshaler is implemented by request messages
+type protoUnmarshaler interface {
+	UnmarshalProto(data []byte) error
+}
+
+// protoMessageOf returns the protobuf message for v, wrapping the service
+// types that have a protobuf encoding
+func protoMessageOf(v any) any {
+	switch v := v.(type) {
+	case *service.SaveRequest:
+		return (*saveRequestMessage)(v)
+	case *service.StoredItem:
+		return (*storedItemMessage)(v)
+	}
+	return v
+}
+
+// ProtobufCodec encodes the messages of proto/datastore.proto
+type ProtobufCodec struct{}
+
+func (ProtobufCodec) Name() string      { return "protobuf" }
+func (ProtobufCodec) MediaType() string { return MediaTypeProtobuf }
+
+func (ProtobufCodec) Supports(v any) bool {
+	switch protoMessageOf(v).(type) {
+	case protoMarshaler, protoUnmarshaler:
+		return true
+	}
+	return false
+}
+
+func (ProtobufCodec) Encode(w io.Writer, v any) error {
+	message, ok := protoMessageOf(v).(protoMarshaler)
+	if !ok {
+		return fmt.Errorf("%T has no protobuf encoding", v)
+	}
+	_, err := w.Write(message.MarshalProto())
+	return err
+}
+
+func (ProtobufCodec) Decode(r io.Reader, v any) error {
+	message, ok := protoMessageOf(v).(protoUnmarshaler)
+	if !ok {
+		return fmt.Errorf("%T has no protobuf decoding", v)
+	}
+	return decodeBuffered(r, message.UnmarshalProto)
+}
+
+// decodeBuffered reads a whole body into a pooled buffer for a decoder that
+// needs all of it. The decoder must not keep references to the bytes.
+func decodeBuffered(r io.Reader, decode func(data []byte) error) error {
+	body := getBuffer()
+	defer putBuffer(body)
+	if _, err := body.ReadFrom(r); err != nil {
+		return err
+	}
+	return decode(body.Bytes())
+}
+
+// toGeneric converts v to the tree of maps, slices, strings, json.Numbers,
+// bools and nils of its JSON encoding. Codecs of other formats encode that
+// tree, so they honour JSON field names and custom marshalers.
+func toGeneric(v any) (any, error) {
+	encoded, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(encoded))
+	decoder.UseNumber()
+	var tree any
+	if err := decoder.Decode(&tree); err != nil {
+		return nil, err
+	}
+	return tree, nil
+}
+
+// fromGeneric stores a decoded tree in v through its JSON decoding. Binary
+// strings in the tree become base64, as []byte fields expect in JSON.
+func fromGeneric(tree any, v any) error {
+	encoded, err := json.Marshal(tree)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(encoded, v)
+}
+
+// genericNumber returns a json.Number as an int64, uint64 or float64,
+// whichever holds it exactly
+func genericNumber(n json.Number) (any, error) {
+	if i, err := strconv.ParseInt(string(n), 10, 64); err == nil {
+		return i, nil
+	}
+	if u, err := strconv.ParseUint(string(n), 10, 64); err == nil {
+		return u, nil
+	}
+	f, err := strconv.ParseFloat(string(n), 64)
+	if err != nil || math.IsInf(f, 0) {
+		return nil, fmt.Errorf("number %s is out of range", n)
+	}
+	return f, nil
+}
@@ -0,0 +1,239 @@
+package api
+
+import (
+	"compress/gzip"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"interview-task/internal/config"
+)
+
+// compressor is a pooled gzip or zlib writer
+type compressor interface {
+	io.WriteCloser
+	Flush() error
+	Reset(w io.Writer)
+}
+
+// Compression - IMPLEMENTS Content-Encoding negotiation. Request bodies
+// sent with Content-Encoding gzip or deflate are decompressed; responses
+// are compressed with the encoding the client prefers in Accept-Encoding.
+type Compression struct {
+	config config.CompressionConfig
+	pools  map[string]*sync.Pool
+}
+
+func NewCompression(config config.CompressionConfig) *Compression {
+	level := config.Level
+	return &Compression{
+		config: config,
+		pools: map[string]*sync.Pool{
+			"gzip": {New: func() any {
+				w, _ := gzip.NewWriterLevel(io.Discard, level)
+				return w
+			}},
+			"deflate": {New: func() any {
+				w, _ := zlib.NewWriterLevel(io.Discard, level)
+				return w
+			}},
+		},
+	}
+}
+
+func (c *Compression) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := c.decompressBody(w, r); err != nil {
+			http.Error(w, err.Error(), http.StatusUnsupportedMediaType)
+			return
+		}
+		if !c.compresses(r.URL.Path) || r.Method == http.MethodHead {
+			next.ServeHTTP(w, r)
+			return
+		}
+		w.Header().Add("Vary", "Accept-Encoding")
+		encoding := negotiateEncoding(r.Header.Get("Accept-Encoding"))
+		if encoding == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		cw := &compressWriter{ResponseWriter: w, compression: c, encoding: encoding}
+		defer cw.finish()
+		next.ServeHTTP(cw, r)
+	})
+}
+
+// decompressBody replaces a compressed request body with its decompressed
+// content
+func (c *Compression) decompressBody(w http.ResponseWriter, r *http.Request) error {
+	encoding := strings.ToLower(strings.TrimSpace(r.Header.Get("Content-Encoding")))
+	var body io.ReadCloser
+	var err error
+	switch encoding {
+	case "", "identity":
+		return nil
+	case "aws-chunked":
+		// S3 upload framing rather than compression; the S3 API decodes it
+		return nil
+	case "gzip", "x-gzip":
+		body, err = gzip.NewReader(r.Body)
+	case "deflate":
+		body, err = zlib.NewReader(r.Body)
+	default:
+		return fmt.Errorf("unsupported Content-Encoding %q", encoding)
+	}
+	if err != nil {
+		return fmt.Errorf("invalid %s request body: %w", encoding, err)
+	}
+	r.Body = http.MaxBytesReader(w, body, c.config.MaxRequestBytes)
+	r.Header.Del("Content-Encoding")
+	r.Header.Del("Content-Length")
+	r.ContentLength = -1
+	return nil
+}
+
+// compresses reports whether responses on path are compressed
+func (c *Compression) compresses(path string) bool {
+	enabled, longest := c.config.Enabled, -1
+	for prefix, on := range c.config.Routes {
+		if strings.HasPrefix(path, prefix) && len(prefix) > longest {
+			enabled, longest = on, len(prefix)
+		}
+	}
+	return enabled
+}
+
+// negotiateEncoding picks gzip or deflate from an Accept-Encoding header,
+// preferring gzip when both are equally acceptable, or "" for neither
+func negotiateEncoding(header string) string {
+	weights := make(map[string]float64)
+	for _, part := range strings.Split(header, ",") {
+		name, params, _ := strings.Cut(part, ";")
+		q := 1.0
+		if value, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			parsed, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				continue
+			}
+			q = parsed
+		}
+		weights[strings.ToLower(strings.TrimSpace(name))] = q
+	}
+	best, bestQ := "", 0.0
+	for _, name := range []string{"gzip", "deflate"} {
+		q, ok := weights[name]
+		if !ok {
+			q = weights["*"]
+		}
+		if q > bestQ {
+			best, bestQ = name, q
+		}
+	}
+	return best
+}
+
+// compressWriter holds back the start of a response until it is known to
+// reach MinSize, then compresses it. Smaller responses are sent as they are.
+type compressWriter struct {
+	http.ResponseWriter
+	compression *Compression
+	encoding    string
+
+	status  int
+	pending []byte
+	started bool
+	writer  compressor
+}
+
+// Unwrap gives http.ResponseController access to the connection's writer
+func (cw *compressWriter) Unwrap() http.ResponseWriter {
+	return cw.ResponseWriter
+}
+
+func (cw *compressWriter) WriteHeader(status int) {
+	if cw.status == 0 {
+		cw.status = status
+	}
+}
+
+func (cw *compressWriter) Write(data []byte) (int, error) {
+	if cw.status == 0 {
+		cw.status = http.StatusOK
+	}
+	if !cw.started {
+		cw.pending = append(cw.pending, data...)
+		if len(cw.pending) < cw.compression.config.MinSize {
+			return len(data), nil
+		}
+		return len(data), cw.start(true)
+	}
+	if cw.writer != nil {
+		return cw.writer.Write(data)
+	}
+	return cw.ResponseWriter.Write(data)
+}
+
+// Flush starts compressing even a short response, as streamed responses
+// flush long before their total size is known
+func (cw *compressWriter) Flush() {
+	if cw.status == 0 {
+		cw.status = http.StatusOK
+	}
+	if !cw.started {
+		cw.start(true)
+	}
+	if cw.writer != nil {
+		cw.writer.Flush()
+	}
+	if flusher, ok := cw.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// start sends the header and what was held back, compressed if requested
+// and the handler hasn't encoded the response itself
+func (cw *compressWriter) start(compress bool) error {
+	cw.started = true
+	header := cw.Header()
+	noBody := cw.status < 200 || cw.status == http.StatusNoContent || cw.status == http.StatusNotModified
+	if compress && !noBody && header.Get("Content-Encoding") == "" {
+		header.Del("Content-Length")
+		header.Set("Content-Encoding", cw.encoding)
+		cw.writer = cw.compression.pools[cw.encoding].Get().(compressor)
+		cw.writer.Reset(cw.ResponseWriter)
+	}
+	cw.ResponseWriter.WriteHeader(cw.status)
+	pending := cw.pending
+	cw.pending = nil
+	if len(pending) == 0 {
+		return nil
+	}
+	var err error
+	if cw.writer != nil {
+		_, err = cw.writer.Write(pending)
+	} else {
+		_, err = cw.ResponseWriter.Write(pending)
+	}
+	return err
+}
+
+// finish sends a response that stayed below MinSize, or ends the
+// compressed stream
+func (cw *compressWriter) finish() {
+	if !cw.started {
+		if cw.status == 0 {
+			// Nothing was written; net/http sends its own empty 200
+			return
+		}
+		cw.start(false)
+	}
+	if cw.writer != nil {
+		cw.writer.Close()
+		cw.writer.Reset(io.Discard)
+		cw.compression.pools[cw.encoding].Put(cw.writer)
+	}
+}
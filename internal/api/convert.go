@@ -0,0 +1,301 @@
+package api
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// PayloadConverter - IMPLEMENTS Strategy Pattern for download formats
+type PayloadConverter interface {
+	ContentType() string
+	// Convert reads a JSON document from payload and writes it to w
+	Convert(w io.Writer, payload io.Reader) error
+}
+
+// NewPayloadConverter returns the converter for a ?format= value
+func NewPayloadConverter(format string) (PayloadConverter, error) {
+	switch format {
+	case "json":
+		return &JSONConverter{}, nil
+	case "yaml":
+		return &YAMLConverter{}, nil
+	case "csv":
+		return &CSVConverter{}, nil
+	default:
+		return nil, fmt.Errorf("unsupported format: %s", format)
+	}
+}
+
+// JSONConverter passes JSON payloads through unchanged
+type JSONConverter struct{}
+
+func (c *JSONConverter) ContentType() string {
+	return "application/json"
+}
+
+func (c *JSONConverter) Convert(w io.Writer, payload io.Reader) error {
+	_, err := io.Copy(w, payload)
+	return err
+}
+
+// YAMLConverter renders JSON payloads as block-style YAML. Top-level arrays
+// are streamed one element at a time.
+type YAMLConverter struct{}
+
+func (c *YAMLConverter) ContentType() string {
+	return "application/yaml"
+}
+
+func (c *YAMLConverter) Convert(w io.Writer, payload io.Reader) error {
+	out := bufio.NewWriter(w)
+	dec, isArray, err := newJSONStream(payload)
+	if err != nil {
+		return err
+	}
+
+	if !isArray {
+		var value any
+		if err := dec.Decode(&value); err != nil {
+			return fmt.Errorf("failed to decode payload: %w", err)
+		}
+		writeYAML(out, value, 0, false)
+		return out.Flush()
+	}
+
+	empty := true
+	for dec.More() {
+		var value any
+		if err := dec.Decode(&value); err != nil {
+			return fmt.Errorf("failed to decode array element: %w", err)
+		}
+		empty = false
+		out.WriteString("- ")
+		writeYAML(out, value, 2, true)
+	}
+	if empty {
+		out.WriteString("[]\n")
+	}
+	return out.Flush()
+}
+
+// writeYAML emits value at the given indent. When inline is set the first
+// line continues the current one (after "- ") instead of being indented.
+func writeYAML(w *bufio.Writer, value any, indent int, inline bool) {
+	pad := strings.Repeat(" ", indent)
+	firstPad := pad
+	if inline {
+		firstPad = ""
+	}
+
+	switch v := value.(type) {
+	case map[string]any:
+		if len(v) == 0 {
+			w.WriteString(firstPad + "{}\n")
+			return
+		}
+		for i, key := range sortedKeys(v) {
+			if i == 0 {
+				w.WriteString(firstPad)
+			} else {
+				w.WriteString(pad)
+			}
+			w.WriteString(yamlScalar(key) + ":")
+			writeYAMLChild(w, v[key], indent)
+		}
+	case []any:
+		if len(v) == 0 {
+			w.WriteString(firstPad + "[]\n")
+			return
+		}
+		for i, elem := range v {
+			if i == 0 {
+				w.WriteString(firstPad)
+			} else {
+				w.WriteString(pad)
+			}
+			w.WriteString("- ")
+			writeYAML(w, elem, indent+2, true)
+		}
+	default:
+		w.WriteString(firstPad + yamlScalar(v) + "\n")
+	}
+}
+
+// writeYAMLChild writes a mapping value after its "key:" prefix
+func writeYAMLChild(w *bufio.Writer, value any, indent int) {
+	switch v := value.(type) {
+	case map[string]any:
+		if len(v) > 0 {
+			w.WriteString("\n")
+			writeYAML(w, v, indent+2, false)
+			return
+		}
+	case []any:
+		if len(v) > 0 {
+			w.WriteString("\n")
+			writeYAML(w, v, indent+2, false)
+			return
+		}
+	}
+	w.WriteString(" ")
+	writeYAML(w, value, 0, true)
+}
+
+var plainYAMLString = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_ ./-]*$`)
+
+// yamlScalar quotes strings that YAML would otherwise read as another type
+func yamlScalar(value any) string {
+	switch v := value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		if v {
+			return "true"
+		}
+		return "false"
+	case json.Number:
+		return v.String()
+	case string:
+		switch strings.ToLower(v) {
+		case "true", "false", "yes", "no", "on", "off", "null", "y", "n":
+			return jsonString(v)
+		}
+		if plainYAMLString.MatchString(v) && !strings.HasSuffix(v, " ") {
+			return v
+		}
+		return jsonString(v)
+	default:
+		return jsonString(fmt.Sprint(v))
+	}
+}
+
+// jsonString produces a double-quoted string, which is also valid YAML
+func jsonString(s string) string {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	enc.Encode(s)
+	return strings.TrimSuffix(buf.String(), "\n")
+}
+
+// CSVConverter renders an object or an array of objects as CSV rows. The
+// header is taken from the first object; nested values are JSON-encoded.
+type CSVConverter struct{}
+
+func (c *CSVConverter) ContentType() string {
+	return "text/csv"
+}
+
+func (c *CSVConverter) Convert(w io.Writer, payload io.Reader) error {
+	out := csv.NewWriter(w)
+	dec, isArray, err := newJSONStream(payload)
+	if err != nil {
+		return err
+	}
+
+	var header []string
+	writeRow := func(value any) error {
+		record, ok := value.(map[string]any)
+		if !ok {
+			return fmt.Errorf("csv conversion requires objects, got %T", value)
+		}
+		if header == nil {
+			header = sortedKeys(record)
+			if err := out.Write(header); err != nil {
+				return err
+			}
+		}
+		row := make([]string, len(header))
+		for i, column := range header {
+			row[i] = csvCell(record[column])
+		}
+		return out.Write(row)
+	}
+
+	if !isArray {
+		var value any
+		if err := dec.Decode(&value); err != nil {
+			return fmt.Errorf("failed to decode payload: %w", err)
+		}
+		if err := writeRow(value); err != nil {
+			return err
+		}
+	}
+	for isArray && dec.More() {
+		var value any
+		if err := dec.Decode(&value); err != nil {
+			return fmt.Errorf("failed to decode array element: %w", err)
+		}
+		if err := writeRow(value); err != nil {
+			return err
+		}
+		// Flush per row so large arrays stream to the client
+		out.Flush()
+	}
+
+	out.Flush()
+	return out.Error()
+}
+
+func csvCell(value any) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case json.Number:
+		return v.String()
+	case bool:
+		if v {
+			return "true"
+		}
+		return "false"
+	default:
+		encoded, _ := json.Marshal(v)
+		return string(encoded)
+	}
+}
+
+// newJSONStream returns a decoder over payload. When the document is an
+// array its opening bracket is consumed so callers can stream the elements.
+func newJSONStream(payload io.Reader) (*json.Decoder, bool, error) {
+	reader := bufio.NewReader(payload)
+	isArray := false
+	for {
+		b, err := reader.ReadByte()
+		if err != nil {
+			return nil, false, fmt.Errorf("failed to read payload: %w", err)
+		}
+		if b == ' ' || b == '\t' || b == '\n' || b == '\r' {
+			continue
+		}
+		isArray = b == '['
+		reader.UnreadByte()
+		break
+	}
+
+	dec := json.NewDecoder(reader)
+	dec.UseNumber()
+	if isArray {
+		if _, err := dec.Token(); err != nil {
+			return nil, false, fmt.Errorf("failed to decode payload: %w", err)
+		}
+	}
+	return dec, isArray, nil
+}
+
+func sortedKeys(m map[string]any) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
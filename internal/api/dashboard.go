@@ -0,0 +1,38 @@
+package api
+
+import (
+	_ "embed"
+	"net/http"
+	"time"
+)
+
+// dashboardPage is the admin UI, a single page rendering GET
+// /admin/dashboard
+//
+//go:embed ui/dashboard.html
+var dashboardPage []byte
+
+// DashboardHandler serves the admin UI and the data it shows. Each panel is
+// read on every request; one that fails shows its error and leaves the
+// others intact.
+type DashboardHandler struct {
+	panels map[string]StatusSection
+}
+
+func NewDashboardHandler(panels map[string]StatusSection) *DashboardHandler {
+	return &DashboardHandler{panels: panels}
+}
+
+func (h *DashboardHandler) HandlePage(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Content-Security-Policy", "default-src 'self'; style-src 'unsafe-inline'; script-src 'unsafe-inline'")
+	w.Write(dashboardPage)
+}
+
+func (h *DashboardHandler) HandleData(w http.ResponseWriter, r *http.Request) {
+	data := map[string]any{"generated_at": time.Now().UTC()}
+	readSections(r.Context(), h.panels, data)
+	w.Header().Set("Cache-Control", "no-store")
+	writeJSON(w, http.StatusOK, data)
+}
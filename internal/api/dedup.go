@@ -0,0 +1,38 @@
+package api
+
+import (
+	"net/http"
+
+	"interview-task/internal/storage"
+)
+
+// DedupHandler exposes blob garbage collection to administrators. It spans
+// every tenant, so keys bound to a single tenant may not use it.
+type DedupHandler struct {
+	dedup   *storage.Deduplicator
+	factory *storage.ConcreteStorageFactory
+}
+
+func NewDedupHandler(dedup *storage.Deduplicator, factory *storage.ConcreteStorageFactory) *DedupHandler {
+	return &DedupHandler{dedup: dedup, factory: factory}
+}
+
+// HandleCollect runs garbage collection and returns its stats
+func (h *DedupHandler) HandleCollect(w http.ResponseWriter, r *http.Request) {
+	if !requireGlobalKey(w, r) {
+		return
+	}
+	stats, err := h.dedup.Collect(r.Context(), h.factory)
+	if err != nil {
+		http.Error(w, err.Error(), statusForError(err))
+		return
+	}
+	writeJSON(w, http.StatusOK, stats)
+}
+
+func (h *DedupHandler) HandleStatus(w http.ResponseWriter, r *http.Request) {
+	if !requireGlobalKey(w, r) {
+		return
+	}
+	writeJSON(w, http.StatusOK, h.dedup.LastCollection())
+}
@@ -0,0 +1,17 @@
+package api
+
+import (
+	"net/http"
+)
+
+func (h *HTTPHandler) HandleGetDerived(w http.ResponseWriter, r *http.Request) {
+	derived, derivation, err := h.dataService.GetDerived(r.Context(),
+		r.URL.Query().Get("storage_type"), r.PathValue("id"), r.PathValue("name"))
+	if err != nil {
+		http.Error(w, err.Error(), statusForError(err))
+		return
+	}
+
+	w.Header().Set("Content-Type", derivation.ContentType())
+	w.Write(derived)
+}
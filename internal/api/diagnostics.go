@@ -0,0 +1,69 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"expvar"
+	"net"
+	"net/http"
+	"net/http/pprof"
+	"runtime"
+	"sync"
+
+	"interview-task/internal/config"
+	"interview-task/internal/logging"
+)
+
+// publishRuntimeVars adds runtime counters to expvar's memstats and
+// cmdline, once per process as expvar names can't be published twice
+var publishRuntimeVars = sync.OnceFunc(func() {
+	expvar.Publish("goroutines", expvar.Func(func() any { return runtime.NumGoroutine() }))
+	expvar.Publish("cgo_calls", expvar.Func(func() any { return runtime.NumCgoCall() }))
+	expvar.Publish("gomaxprocs", expvar.Func(func() any { return runtime.GOMAXPROCS(0) }))
+	expvar.Publish("go_version", expvar.Func(func() any { return runtime.Version() }))
+})
+
+// DiagnosticsServer - IMPLEMENTS pprof profiles under /debug/pprof/ and
+// expvar variables at /debug/vars, served on the HTTP API or on a port of
+// their own
+type DiagnosticsServer struct {
+	config config.DiagnosticsConfig
+	mux    *http.ServeMux
+	server *http.Server
+}
+
+func NewDiagnosticsServer(config config.DiagnosticsConfig) *DiagnosticsServer {
+	publishRuntimeVars()
+	s := &DiagnosticsServer{config: config, mux: http.NewServeMux()}
+	// The server exists from the start, so a Shutdown before Serve stops it
+	s.server = &http.Server{Addr: net.JoinHostPort(config.Host, config.Port), Handler: s.mux}
+	// Index also serves the named profiles, such as /debug/pprof/heap
+	s.mux.HandleFunc("GET /debug/pprof/", pprof.Index)
+	s.mux.HandleFunc("GET /debug/pprof/cmdline", pprof.Cmdline)
+	s.mux.HandleFunc("GET /debug/pprof/profile", pprof.Profile)
+	s.mux.HandleFunc("GET /debug/pprof/symbol", pprof.Symbol)
+	s.mux.HandleFunc("POST /debug/pprof/symbol", pprof.Symbol)
+	s.mux.HandleFunc("GET /debug/pprof/trace", pprof.Trace)
+	s.mux.Handle("GET /debug/vars", expvar.Handler())
+	return s
+}
+
+func (s *DiagnosticsServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+// Serve listens on the configured host and port until Shutdown
+func (s *DiagnosticsServer) Serve() error {
+	logging.Printf("Diagnostics starting on %s", s.server.Addr)
+	err := s.server.ListenAndServe()
+	if errors.Is(err, http.ErrServerClosed) {
+		return nil
+	}
+	return err
+}
+
+// Shutdown stops accepting requests and waits for those in progress, such
+// as a CPU profile being taken
+func (s *DiagnosticsServer) Shutdown(ctx context.Context) error {
+	return s.server.Shutdown(ctx)
+}
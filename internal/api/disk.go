@@ -0,0 +1,36 @@
+package api
+
+import (
+	"net/http"
+
+	"interview-task/internal/storage"
+)
+
+// ReadinessHandler serves /readyz, which load balancers use to stop sending
+// traffic to an instance that can't accept writes or is draining
+type ReadinessHandler struct {
+	disk    *storage.DiskChecker
+	drainer *Drainer
+}
+
+func NewReadinessHandler(disk *storage.DiskChecker, drainer *Drainer) *ReadinessHandler {
+	return &ReadinessHandler{disk: disk, drainer: drainer}
+}
+
+func (h *ReadinessHandler) HandleReady(w http.ResponseWriter, r *http.Request) {
+	checks := map[string]string{"disk": "ok"}
+	status := http.StatusOK
+	if err := h.disk.Check(); err != nil {
+		checks["disk"] = err.Error()
+		status = http.StatusServiceUnavailable
+	}
+	if h.drainer.Draining() {
+		checks["drain"] = "draining"
+		status = http.StatusServiceUnavailable
+	}
+	state := "ready"
+	if status != http.StatusOK {
+		state = "not_ready"
+	}
+	writeJSON(w, status, map[string]any{"status": state, "checks": checks})
+}
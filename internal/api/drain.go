@@ -0,0 +1,82 @@
+package api
+
+import (
+	"net/http"
+	"sync"
+	"time"
+
+	"interview-task/internal/config"
+	"interview-task/internal/logging"
+	"interview-task/internal/service"
+)
+
+// DrainStatus reports drain mode
+type DrainStatus struct {
+	Draining   bool      `json:"draining"`
+	Since      time.Time `json:"since,omitzero"`
+	ShutdownAt time.Time `json:"shutdown_at,omitzero"`
+}
+
+// Drainer - IMPLEMENTS drain mode for rolling deploys. Once started, /readyz
+// reports not ready so load balancers stop routing new traffic, while
+// requests that still arrive are served. After the configured delay Done
+// is closed and the server shuts down gracefully, finishing in-flight
+// requests and queued webhook deliveries before the process exits.
+type Drainer struct {
+	delay time.Duration
+	done  chan struct{}
+
+	mu     sync.Mutex
+	status DrainStatus
+}
+
+func NewDrainer(config config.DrainConfig) *Drainer {
+	return &Drainer{delay: time.Duration(config.Delay), done: make(chan struct{})}
+}
+
+// Drain starts drain mode. Draining again keeps the original schedule.
+func (d *Drainer) Drain() DrainStatus {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if !d.status.Draining {
+		now := time.Now().UTC()
+		d.status = DrainStatus{Draining: true, Since: now, ShutdownAt: now.Add(d.delay)}
+		time.AfterFunc(d.delay, func() { close(d.done) })
+	}
+	return d.status
+}
+
+func (d *Drainer) Status() DrainStatus {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.status
+}
+
+// Draining reports whether drain mode was started
+func (d *Drainer) Draining() bool {
+	return d.Status().Draining
+}
+
+// Done is closed when the drain delay has passed and the server should
+// shut down
+func (d *Drainer) Done() <-chan struct{} {
+	return d.done
+}
+
+// HandleDrain starts drain mode. It stops the whole instance, so keys
+// bound to a single tenant may not use it.
+func (d *Drainer) HandleDrain(w http.ResponseWriter, r *http.Request) {
+	if !requireGlobalKey(w, r) {
+		return
+	}
+	status := d.Drain()
+	logging.Printf("Draining at the request of %s; shutting down at %s", service.PrincipalFromContext(r.Context()).Name, status.ShutdownAt.Format(time.RFC3339))
+	writeJSON(w, http.StatusAccepted, status)
+}
+
+func (d *Drainer) HandleStatus(w http.ResponseWriter, r *http.Request) {
+	if !requireGlobalKey(w, r) {
+		return
+	}
+	writeJSON(w, http.StatusOK, d.Status())
+}
@@ -0,0 +1,43 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"interview-task/internal/service"
+)
+
+// ErasureHandler exposes data subject erasure to administrators. An erasure
+// covers the request's tenant only.
+type ErasureHandler struct {
+	eraser *service.SubjectEraser
+}
+
+func NewErasureHandler(eraser *service.SubjectEraser) *ErasureHandler {
+	return &ErasureHandler{eraser: eraser}
+}
+
+// HandleErase takes an ErasureRequest and returns the signed report
+func (h *ErasureHandler) HandleErase(w http.ResponseWriter, r *http.Request) {
+	var req service.ErasureRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON format", http.StatusBadRequest)
+		return
+	}
+	report, err := h.eraser.Erase(r.Context(), req)
+	if err != nil {
+		http.Error(w, err.Error(), statusForError(err))
+		return
+	}
+	writeJSON(w, http.StatusOK, report)
+}
+
+// HandleKey returns the public key verifying erasure reports
+func (h *ErasureHandler) HandleKey(w http.ResponseWriter, r *http.Request) {
+	key, err := h.eraser.PublicKey()
+	if err != nil {
+		http.Error(w, err.Error(), statusForError(err))
+		return
+	}
+	writeJSON(w, http.StatusOK, key)
+}
@@ -0,0 +1,294 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"net/http"
+	"runtime/debug"
+	"sync"
+	"time"
+
+	"interview-task/internal/config"
+	"interview-task/internal/logging"
+	"interview-task/internal/metrics"
+	"interview-task/internal/service"
+)
+
+// reportedBodyLimit is how much of a 5xx response body becomes the
+// report's message
+const reportedBodyLimit = 1024
+
+// ErrorEvent is a server error with the context of the request that hit it
+type ErrorEvent struct {
+	ID   string    `json:"id"`
+	Time time.Time `json:"time"`
+	// Kind is "panic" or "error", a 5xx response
+	Kind    string `json:"kind"`
+	Message string `json:"message"`
+	// Stack is the goroutine's stack at a panic
+	Stack       string `json:"stack,omitempty"`
+	Status      int    `json:"status"`
+	Method      string `json:"method"`
+	Path        string `json:"path"`
+	Route       string `json:"route,omitempty"`
+	RequestID   string `json:"request_id,omitempty"`
+	TraceID     string `json:"trace_id,omitempty"`
+	Tenant      string `json:"tenant,omitempty"`
+	Principal   string `json:"principal,omitempty"`
+	ClientIP    string `json:"client_ip,omitempty"`
+	UserAgent   string `json:"user_agent,omitempty"`
+	Environment string `json:"environment,omitempty"`
+	Release     string `json:"release,omitempty"`
+}
+
+// ErrorReporter sends error events to an error tracker. Report is called
+// from a single background goroutine.
+type ErrorReporter interface {
+	Report(ctx context.Context, event *ErrorEvent) error
+}
+
+// ErrorReporterFactory builds a reporter from its settings section of the
+// error_reporting config, nil when it has none
+type ErrorReporterFactory func(settings json.RawMessage) (ErrorReporter, error)
+
+var (
+	errorReportersMu sync.RWMutex
+	errorReporters   = map[string]ErrorReporterFactory{
+		"sentry": newSentryReporter,
+		"http":   newHTTPErrorReporter,
+	}
+)
+
+// RegisterErrorReporter makes a reporter available to error_reporting.
+// Registering a name again replaces it.
+func RegisterErrorReporter(name string, factory ErrorReporterFactory) {
+	errorReportersMu.Lock()
+	defer errorReportersMu.Unlock()
+	errorReporters[name] = factory
+}
+
+// ErrorReports - IMPLEMENTS error reporting as middleware. Panics are
+// recovered into 500 responses; those and other 5xx responses are sampled
+// and queued for a background goroutine to report, so a slow tracker
+// never holds up requests.
+type ErrorReports struct {
+	reporter ErrorReporter
+	config   config.ErrorReportingConfig
+	metrics  *metrics.Metrics
+	queue    chan *ErrorEvent
+	done     chan struct{}
+}
+
+// NewErrorReports returns nil without a reporter
+func NewErrorReports(config config.ErrorReportingConfig, metrics *metrics.Metrics) (*ErrorReports, error) {
+	if config.Reporter == "" {
+		return nil, nil
+	}
+	if config.SampleRate < 0 || config.SampleRate > 1 {
+		return nil, errors.New("sample_rate must be between 0 and 1")
+	}
+	errorReportersMu.RLock()
+	factory, ok := errorReporters[config.Reporter]
+	errorReportersMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown error reporter %q", config.Reporter)
+	}
+	reporter, err := factory(config.Settings)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", config.Reporter, err)
+	}
+	metrics.Describe("error_reports_total", "Server errors by whether their report was sent, failed or dropped")
+	e := &ErrorReports{
+		reporter: reporter,
+		config:   config,
+		metrics:  metrics,
+		queue:    make(chan *ErrorEvent, max(config.QueueSize, 1)),
+		done:     make(chan struct{}),
+	}
+	go e.run()
+	return e, nil
+}
+
+func (e *ErrorReports) run() {
+	defer close(e.done)
+	for event := range e.queue {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		err := e.reporter.Report(ctx, event)
+		cancel()
+		if err != nil {
+			logging.Printf("Failed to report error %s: %v", event.ID, err)
+			e.metrics.Add("error_reports_total", 1, "outcome", "failed")
+			continue
+		}
+		e.metrics.Add("error_reports_total", 1, "outcome", "sent")
+	}
+}
+
+// Shutdown sends the queued reports. Requests must have stopped.
+func (e *ErrorReports) Shutdown() {
+	if e == nil {
+		return
+	}
+	close(e.queue)
+	<-e.done
+}
+
+// Middleware runs right before routing, so the request carries its
+// principal and tenant and the response is recorded after the handler
+// wrote it
+func (e *ErrorReports) Middleware(next http.Handler) http.Handler {
+	if e == nil {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		recorder := &errorRecorder{ResponseWriter: w}
+		defer func() {
+			if recovered := recover(); recovered != nil {
+				if recovered == http.ErrAbortHandler {
+					panic(recovered)
+				}
+				stack := string(debug.Stack())
+				logging.Printf("Recovered from panic serving %s %s: %v\n%s", r.Method, r.URL.Path, recovered, stack)
+				if recorder.status == 0 {
+					http.Error(recorder, "Internal Server Error", http.StatusInternalServerError)
+				}
+				e.report(r, "panic", fmt.Sprint(recovered), stack, http.StatusInternalServerError)
+				return
+			}
+			if recorder.status >= 500 {
+				e.report(r, "error", string(bytes.TrimSpace(recorder.body)), "", recorder.status)
+			}
+		}()
+		next.ServeHTTP(recorder, r)
+	})
+}
+
+// report samples an event and queues it, dropping it when the queue is full
+func (e *ErrorReports) report(r *http.Request, kind, message, stack string, status int) {
+	if e.config.SampleRate < 1 && rand.Float64() >= e.config.SampleRate {
+		return
+	}
+	ctx := r.Context()
+	id, err := service.NewItemID()
+	if err != nil {
+		logging.Printf("Failed to report error: %v", err)
+		return
+	}
+	event := &ErrorEvent{
+		ID:          id,
+		Time:        time.Now().UTC(),
+		Kind:        kind,
+		Message:     message,
+		Stack:       stack,
+		Status:      status,
+		Method:      r.Method,
+		Path:        r.URL.Path,
+		Route:       r.Pattern,
+		RequestID:   service.RequestIDFromContext(ctx),
+		TraceID:     traceID(r),
+		Tenant:      service.TenantFromContext(ctx),
+		Principal:   service.PrincipalFromContext(ctx).Name,
+		UserAgent:   r.UserAgent(),
+		Environment: e.config.Environment,
+		Release:     e.config.Release,
+	}
+	if addr, ok := service.ClientIPFromContext(ctx); ok {
+		event.ClientIP = addr.String()
+	}
+	select {
+	case e.queue <- event:
+	default:
+		e.metrics.Add("error_reports_total", 1, "outcome", "dropped")
+	}
+}
+
+// errorRecorder notes the status of a response and keeps the start of a
+// 5xx body as the error's message
+type errorRecorder struct {
+	http.ResponseWriter
+	status int
+	body   []byte
+}
+
+// Unwrap gives http.ResponseController access to the connection's writer
+func (e *errorRecorder) Unwrap() http.ResponseWriter {
+	return e.ResponseWriter
+}
+
+func (e *errorRecorder) WriteHeader(status int) {
+	if e.status == 0 {
+		e.status = status
+	}
+	e.ResponseWriter.WriteHeader(status)
+}
+
+func (e *errorRecorder) Write(data []byte) (int, error) {
+	if e.status == 0 {
+		e.status = http.StatusOK
+	}
+	if e.status >= 500 && len(e.body) < reportedBodyLimit {
+		e.body = append(e.body, data[:min(len(data), reportedBodyLimit-len(e.body))]...)
+	}
+	return e.ResponseWriter.Write(data)
+}
+
+// httpErrorReporter posts events as JSON, for trackers without a reporter
+// of their own
+type httpErrorReporter struct {
+	url     string
+	headers map[string]string
+	client  *http.Client
+}
+
+// httpErrorReporterSettings is the settings section of the http reporter
+type httpErrorReporterSettings struct {
+	URL string `json:"url"`
+	// Headers are added to every request, such as for authentication
+	Headers map[string]string `json:"headers"`
+}
+
+func newHTTPErrorReporter(raw json.RawMessage) (ErrorReporter, error) {
+	var settings httpErrorReporterSettings
+	if len(raw) > 0 {
+		if err := json.Unmarshal(raw, &settings); err != nil {
+			return nil, fmt.Errorf("invalid settings: %w", err)
+		}
+	}
+	if settings.URL == "" {
+		return nil, errors.New("url is required")
+	}
+	return &httpErrorReporter{url: settings.URL, headers: settings.Headers, client: &http.Client{}}, nil
+}
+
+func (h *httpErrorReporter) Report(ctx context.Context, event *ErrorEvent) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, h.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for name, value := range h.headers {
+		req.Header.Set(name, value)
+	}
+	return sendReport(h.client, req)
+}
+
+// sendReport sends a report request, failing on a non-2xx response
+func sendReport(client *http.Client, req *http.Request) error {
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("tracker responded %s", resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,82 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"interview-task/internal/service"
+)
+
+// EventHandler streams storage events as Server-Sent Events
+type EventHandler struct {
+	bus *service.EventBus
+}
+
+func NewEventHandler(bus *service.EventBus) *EventHandler {
+	return &EventHandler{bus: bus}
+}
+
+// HandleStream serves GET /events. Callers receive their own tenant's
+// events; admins may pass ?tenant= for another tenant, or * for all.
+// ?storage_type= and ?type= (comma-separated actions) narrow the stream.
+func (h *EventHandler) HandleStream(w http.ResponseWriter, r *http.Request) {
+	params := r.URL.Query()
+	filter := service.EventFilter{Tenant: service.TenantFromContext(r.Context()), StorageType: params.Get("storage_type")}
+	if tenant := params.Get("tenant"); tenant != "" && tenant != filter.Tenant {
+		if !service.PrincipalFromContext(r.Context()).HasRole(service.RoleAdmin) {
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		}
+		filter.Tenant = tenant
+	}
+	if types := params.Get("type"); types != "" {
+		filter.Types = make(map[string]bool)
+		for _, action := range strings.Split(types, ",") {
+			if !service.StorageEventActions[action] {
+				http.Error(w, fmt.Sprintf("unknown event type: %s", action), http.StatusBadRequest)
+				return
+			}
+			filter.Types[action] = true
+		}
+	}
+
+	sub, err := h.bus.Subscribe(filter)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+	defer h.bus.Unsubscribe(sub)
+
+	// The stream outlives any server write timeout
+	controller := http.NewResponseController(w)
+	controller.SetWriteDeadline(time.Time{})
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprint(w, ": connected\n\n")
+	controller.Flush()
+
+	heartbeat := time.NewTicker(max(h.bus.Heartbeat(), time.Second))
+	defer heartbeat.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-heartbeat.C:
+			fmt.Fprint(w, ": ping\n\n")
+		case event, ok := <-sub.Events():
+			if !ok {
+				return
+			}
+			data, _ := json.Marshal(event)
+			fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", event.Seq, event.Type, data)
+		}
+		if err := controller.Flush(); err != nil {
+			return
+		}
+	}
+}
@@ -0,0 +1,215 @@
+package api
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/base64"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"maps"
+	"mime"
+	"net/http"
+	"slices"
+	"time"
+
+	"interview-task/internal/logging"
+	"interview-task/internal/service"
+)
+
+// itemExporter writes the items of an export in one format
+type itemExporter interface {
+	write(item service.ItemSummary, data []byte) error
+	close() error
+}
+
+// HandleExport streams the items matching the filters of GET /data as
+// ?format=ndjson (the default), csv or zip. NDJSON and CSV exports can be
+// imported again with POST /import; a zip holds each payload as a file
+// named after its item.
+func (h *HTTPHandler) HandleExport(w http.ResponseWriter, r *http.Request) {
+	params := r.URL.Query()
+	filter, err := itemFilterFromQuery(params)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	format := params.Get("format")
+	if format == "" {
+		format = "ndjson"
+	}
+	contentTypes := map[string]string{"ndjson": "application/x-ndjson", "csv": "text/csv", "zip": "application/zip"}
+	contentType, ok := contentTypes[format]
+	if !ok {
+		http.Error(w, fmt.Sprintf("unsupported export format: %q", format), http.StatusBadRequest)
+		return
+	}
+
+	storageType := params.Get("storage_type")
+	ctx := service.WithEndpoint(r.Context(), r.URL.Path)
+	export, err := h.dataService.ExportItems(ctx, storageType, filter)
+	if err != nil {
+		http.Error(w, err.Error(), statusForError(err))
+		return
+	}
+
+	name := "export-" + time.Now().UTC().Format("20060102T150405Z") + "." + format
+	out := &attachmentWriter{w: w, contentType: contentType, name: name}
+	var exporter itemExporter
+	switch format {
+	case "ndjson":
+		exporter = &ndjsonExporter{encoder: json.NewEncoder(out), storageType: storageType}
+	case "csv":
+		exporter = newCSVExporter(out, storageType, export.Items)
+	case "zip":
+		exporter = &zipExporter{archive: zip.NewWriter(out)}
+	}
+	if err := writeExport(ctx, export, exporter); err != nil {
+		// Too late for an error status once streaming started; cutting the
+		// response short leaves the client a truncated file
+		logging.Printf("Export failed: %v", err)
+		if !out.started {
+			http.Error(w, err.Error(), statusForError(err))
+			return
+		}
+		panic(http.ErrAbortHandler)
+	}
+	if !out.started {
+		// An empty export still gets its headers
+		out.Write(nil)
+	}
+}
+
+func writeExport(ctx context.Context, export *service.ItemExport, exporter itemExporter) error {
+	for {
+		item, data, err := export.Next(ctx)
+		if err == io.EOF {
+			return exporter.close()
+		}
+		if err != nil {
+			return fmt.Errorf("failed to export %s: %w", item.ID, err)
+		}
+		if err := exporter.write(item, data); err != nil {
+			return err
+		}
+	}
+}
+
+// exportRecord is an NDJSON line, a SaveRequest with the item's ID and
+// checksum added
+type exportRecord struct {
+	ID          string            `json:"id"`
+	Data        []byte            `json:"data"`
+	StorageType string            `json:"storage_type"`
+	ContentType string            `json:"content_type,omitempty"`
+	SHA256      string            `json:"sha256"`
+	Labels      map[string]string `json:"labels,omitempty"`
+	Source      string            `json:"source,omitempty"`
+	ExpiresAt   time.Time         `json:"expires_at,omitzero"`
+}
+
+type ndjsonExporter struct {
+	encoder     *json.Encoder
+	storageType string
+}
+
+func (n *ndjsonExporter) write(item service.ItemSummary, data []byte) error {
+	return n.encoder.Encode(exportRecord{
+		ID:          item.ID,
+		Data:        data,
+		StorageType: n.storageType,
+		ContentType: item.ContentType,
+		SHA256:      item.SHA256,
+		Labels:      item.Labels,
+		Source:      item.Source,
+		ExpiresAt:   item.ExpiresAt,
+	})
+}
+
+func (n *ndjsonExporter) close() error { return nil }
+
+// csvExporter writes the columns POST /import reads, with a label.<name>
+// column for every label of the exported items
+type csvExporter struct {
+	writer      *csv.Writer
+	storageType string
+	labels      []string
+	header      bool
+}
+
+func newCSVExporter(w io.Writer, storageType string, items []service.ItemSummary) *csvExporter {
+	labels := make(map[string]bool)
+	for _, item := range items {
+		for key := range item.Labels {
+			labels[key] = true
+		}
+	}
+	return &csvExporter{writer: csv.NewWriter(w), storageType: storageType, labels: slices.Sorted(maps.Keys(labels))}
+}
+
+func (c *csvExporter) writeHeader() error {
+	c.header = true
+	row := []string{"id", "storage_type", "content_type", "source", "sha256", "data_base64"}
+	for _, key := range c.labels {
+		row = append(row, "label."+key)
+	}
+	return c.writer.Write(row)
+}
+
+func (c *csvExporter) write(item service.ItemSummary, data []byte) error {
+	if !c.header {
+		if err := c.writeHeader(); err != nil {
+			return err
+		}
+	}
+	row := []string{item.ID, c.storageType, item.ContentType, item.Source, item.SHA256, base64.StdEncoding.EncodeToString(data)}
+	for _, key := range c.labels {
+		row = append(row, item.Labels[key])
+	}
+	return c.writer.Write(row)
+}
+
+func (c *csvExporter) close() error {
+	if !c.header {
+		if err := c.writeHeader(); err != nil {
+			return err
+		}
+	}
+	c.writer.Flush()
+	return c.writer.Error()
+}
+
+// zipExporter writes each payload as a file named after its item, with an
+// extension for its content type when one is known
+type zipExporter struct {
+	archive *zip.Writer
+}
+
+// preferredExtensions overrides the first of mime.ExtensionsByType, which is
+// alphabetical
+var preferredExtensions = map[string]string{
+	"text/plain": ".txt",
+	"image/jpeg": ".jpg",
+	"text/html":  ".html",
+}
+
+func (z *zipExporter) write(item service.ItemSummary, data []byte) error {
+	name := item.ID
+	mediaType, _, _ := mime.ParseMediaType(item.ContentType)
+	if extension, ok := preferredExtensions[mediaType]; ok {
+		name += extension
+	} else if extensions, _ := mime.ExtensionsByType(mediaType); len(extensions) > 0 {
+		name += extensions[0]
+	}
+	file, err := z.archive.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Deflate, Modified: item.ModifiedAt})
+	if err != nil {
+		return err
+	}
+	_, err = file.Write(data)
+	return err
+}
+
+func (z *zipExporter) close() error {
+	return z.archive.Close()
+}
@@ -0,0 +1,63 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+
+	"interview-task/internal/service"
+)
+
+// GCHandler exposes garbage collection of orphaned records to
+// administrators. A collection spans every tenant, so keys bound to a
+// single tenant may not use it.
+type GCHandler struct {
+	collector *service.GarbageCollector
+}
+
+func NewGCHandler(collector *service.GarbageCollector) *GCHandler {
+	return &GCHandler{collector: collector}
+}
+
+// gcRequest is the body of POST /admin/gc, which may be empty
+type gcRequest struct {
+	DryRun bool `json:"dry_run"`
+}
+
+func (h *GCHandler) HandleStart(w http.ResponseWriter, r *http.Request) {
+	if !requireGlobalKey(w, r) {
+		return
+	}
+	var req gcRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		http.Error(w, "Invalid JSON format", http.StatusBadRequest)
+		return
+	}
+	report, err := h.collector.Collect(req.DryRun)
+	if err != nil {
+		http.Error(w, err.Error(), statusForError(err))
+		return
+	}
+	writeJSON(w, http.StatusAccepted, report)
+}
+
+// HandleReport returns the running or last collection's report
+func (h *GCHandler) HandleReport(w http.ResponseWriter, r *http.Request) {
+	if !requireGlobalKey(w, r) {
+		return
+	}
+	writeJSON(w, http.StatusOK, h.collector.Report())
+}
+
+func (h *GCHandler) HandleCancel(w http.ResponseWriter, r *http.Request) {
+	if !requireGlobalKey(w, r) {
+		return
+	}
+	report, err := h.collector.Cancel()
+	if err != nil {
+		http.Error(w, err.Error(), statusForError(err))
+		return
+	}
+	writeJSON(w, http.StatusOK, report)
+}
@@ -0,0 +1,53 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"interview-task/internal/service"
+)
+
+// GeneratorHandler exposes the generator to administrators
+type GeneratorHandler struct {
+	generator *service.Generator
+}
+
+func NewGeneratorHandler(generator *service.Generator) *GeneratorHandler {
+	return &GeneratorHandler{generator: generator}
+}
+
+func (h *GeneratorHandler) HandleStart(w http.ResponseWriter, r *http.Request) {
+	var req service.GenerateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON format", http.StatusBadRequest)
+		return
+	}
+	job, err := h.generator.Start(r.Context(), req)
+	if err != nil {
+		http.Error(w, err.Error(), statusForError(err))
+		return
+	}
+	writeJSON(w, http.StatusAccepted, job)
+}
+
+func (h *GeneratorHandler) HandleStatus(w http.ResponseWriter, r *http.Request) {
+	job, err := h.generator.Job(r.Context(), r.PathValue("batch"))
+	if err != nil {
+		http.Error(w, err.Error(), statusForError(err))
+		return
+	}
+	writeJSON(w, http.StatusOK, job)
+}
+
+func (h *GeneratorHandler) HandlePurge(w http.ResponseWriter, r *http.Request) {
+	result, err := h.generator.Purge(r.Context(), r.URL.Query().Get("storage_type"), r.PathValue("batch"))
+	if result == nil {
+		http.Error(w, err.Error(), statusForError(err))
+		return
+	}
+	code := http.StatusOK
+	if err != nil {
+		code = http.StatusInternalServerError
+	}
+	writeJSON(w, code, result)
+}
@@ -0,0 +1,609 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// maxGraphQLDepth bounds the nesting of queries and input values
+const maxGraphQLDepth = 32
+
+// The GraphQL support covers what the data API schema needs: queries and
+// mutations with arguments, variables, aliases and nested selections.
+// Fragments, directives and introspection are not supported.
+
+// gqlOperation is the operation of a GraphQL document that gets executed
+type gqlOperation struct {
+	kind       string
+	name       string
+	variables  map[string]gqlValue
+	selections []*gqlField
+}
+
+// gqlField is a field in a selection set
+type gqlField struct {
+	alias      string
+	name       string
+	args       map[string]gqlValue
+	selections []*gqlField
+}
+
+func (f *gqlField) key() string {
+	if f.alias != "" {
+		return f.alias
+	}
+	return f.name
+}
+
+// gqlValue is an input value as written in the document: a literal, or a
+// gqlVariable, or lists and objects of values
+type gqlValue any
+
+type gqlVariable string
+
+// gqlEnum is an enum literal such as ASC
+type gqlEnum string
+
+// gqlParser - IMPLEMENTS a recursive descent parser for GraphQL documents
+type gqlParser struct {
+	src   string
+	pos   int
+	depth int
+}
+
+func (p *gqlParser) errorf(format string, args ...any) error {
+	return fmt.Errorf("syntax error at offset %d: %s", p.pos, fmt.Sprintf(format, args...))
+}
+
+// skip moves past whitespace, commas and comments
+func (p *gqlParser) skip() {
+	for p.pos < len(p.src) {
+		switch c := p.src[p.pos]; {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == ',':
+			p.pos++
+		case c == '#':
+			for p.pos < len(p.src) && p.src[p.pos] != '\n' {
+				p.pos++
+			}
+		case strings.HasPrefix(p.src[p.pos:], "\uFEFF"):
+			p.pos += len("\uFEFF")
+		default:
+			return
+		}
+	}
+}
+
+func (p *gqlParser) peek() byte {
+	p.skip()
+	if p.pos >= len(p.src) {
+		return 0
+	}
+	return p.src[p.pos]
+}
+
+// accept consumes c if it is next
+func (p *gqlParser) accept(c byte) bool {
+	if p.peek() == c {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *gqlParser) expect(c byte) error {
+	if !p.accept(c) {
+		return p.errorf("expected %q", c)
+	}
+	return nil
+}
+
+func isNameStart(c byte) bool {
+	return c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
+
+func (p *gqlParser) name() (string, error) {
+	if !isNameStart(p.peek()) {
+		return "", p.errorf("expected a name")
+	}
+	start := p.pos
+	for p.pos < len(p.src) && (isNameStart(p.src[p.pos]) || p.src[p.pos] >= '0' && p.src[p.pos] <= '9') {
+		p.pos++
+	}
+	return p.src[start:p.pos], nil
+}
+
+// parseGraphQL parses a document and returns the operation to execute: the
+// one named operationName, or the only one
+func parseGraphQL(src, operationName string) (*gqlOperation, error) {
+	p := &gqlParser{src: src}
+	var operations []*gqlOperation
+	for p.peek() != 0 {
+		operation, err := p.operation()
+		if err != nil {
+			return nil, err
+		}
+		operations = append(operations, operation)
+	}
+	switch {
+	case len(operations) == 0:
+		return nil, fmt.Errorf("document contains no operation")
+	case operationName != "":
+		for _, operation := range operations {
+			if operation.name == operationName {
+				return operation, nil
+			}
+		}
+		return nil, fmt.Errorf("unknown operation %q", operationName)
+	case len(operations) > 1:
+		return nil, fmt.Errorf("operationName is required for documents with several operations")
+	default:
+		return operations[0], nil
+	}
+}
+
+func (p *gqlParser) operation() (*gqlOperation, error) {
+	operation := &gqlOperation{kind: "query", variables: make(map[string]gqlValue)}
+	if p.peek() != '{' {
+		kind, err := p.name()
+		if err != nil {
+			return nil, err
+		}
+		switch kind {
+		case "query", "mutation":
+			operation.kind = kind
+		case "fragment":
+			return nil, fmt.Errorf("fragments are not supported")
+		default:
+			return nil, fmt.Errorf("unsupported operation type %q", kind)
+		}
+		if isNameStart(p.peek()) {
+			if operation.name, err = p.name(); err != nil {
+				return nil, err
+			}
+		}
+		if p.accept('(') {
+			for !p.accept(')') {
+				if err := p.variableDefinition(operation); err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+	if p.peek() == '@' {
+		return nil, fmt.Errorf("directives are not supported")
+	}
+	selections, err := p.selectionSet()
+	if err != nil {
+		return nil, err
+	}
+	operation.selections = selections
+	return operation, nil
+}
+
+// variableDefinition parses "$name: Type = default". Types aren't checked;
+// resolvers check the values they get.
+func (p *gqlParser) variableDefinition(operation *gqlOperation) error {
+	if err := p.expect('$'); err != nil {
+		return err
+	}
+	name, err := p.name()
+	if err != nil {
+		return err
+	}
+	if err := p.expect(':'); err != nil {
+		return err
+	}
+	if err := p.skipType(); err != nil {
+		return err
+	}
+	operation.variables[name] = nil
+	if p.accept('=') {
+		if operation.variables[name], err = p.value(true); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (p *gqlParser) skipType() error {
+	if p.accept('[') {
+		if err := p.skipType(); err != nil {
+			return err
+		}
+		if err := p.expect(']'); err != nil {
+			return err
+		}
+	} else if _, err := p.name(); err != nil {
+		return err
+	}
+	p.accept('!')
+	return nil
+}
+
+func (p *gqlParser) selectionSet() ([]*gqlField, error) {
+	if err := p.expect('{'); err != nil {
+		return nil, err
+	}
+	if p.depth++; p.depth > maxGraphQLDepth {
+		return nil, fmt.Errorf("query is nested too deeply")
+	}
+	defer func() { p.depth-- }()
+
+	var fields []*gqlField
+	for !p.accept('}') {
+		if p.peek() == '.' {
+			return nil, fmt.Errorf("fragments are not supported")
+		}
+		field, err := p.field()
+		if err != nil {
+			return nil, err
+		}
+		fields = append(fields, field)
+	}
+	if len(fields) == 0 {
+		return nil, p.errorf("empty selection set")
+	}
+	return fields, nil
+}
+
+func (p *gqlParser) field() (*gqlField, error) {
+	name, err := p.name()
+	if err != nil {
+		return nil, err
+	}
+	field := &gqlField{name: name, args: make(map[string]gqlValue)}
+	if p.accept(':') {
+		field.alias = name
+		if field.name, err = p.name(); err != nil {
+			return nil, err
+		}
+	}
+	if p.accept('(') {
+		for !p.accept(')') {
+			arg, err := p.name()
+			if err != nil {
+				return nil, err
+			}
+			if err := p.expect(':'); err != nil {
+				return nil, err
+			}
+			if field.args[arg], err = p.value(false); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if p.peek() == '@' {
+		return nil, fmt.Errorf("directives are not supported")
+	}
+	if p.peek() == '{' {
+		if field.selections, err = p.selectionSet(); err != nil {
+			return nil, err
+		}
+	}
+	return field, nil
+}
+
+// value parses an input value; constant ones can't contain variables
+func (p *gqlParser) value(constant bool) (gqlValue, error) {
+	if p.depth++; p.depth > maxGraphQLDepth {
+		return nil, fmt.Errorf("input value is nested too deeply")
+	}
+	defer func() { p.depth-- }()
+
+	switch c := p.peek(); {
+	case c == '$' && !constant:
+		p.pos++
+		name, err := p.name()
+		return gqlVariable(name), err
+	case c == '"':
+		return p.string()
+	case c == '-' || c >= '0' && c <= '9':
+		return p.number()
+	case c == '[':
+		p.pos++
+		list := []gqlValue{}
+		for !p.accept(']') {
+			item, err := p.value(constant)
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, item)
+		}
+		return list, nil
+	case c == '{':
+		p.pos++
+		object := map[string]gqlValue{}
+		for !p.accept('}') {
+			name, err := p.name()
+			if err != nil {
+				return nil, err
+			}
+			if err := p.expect(':'); err != nil {
+				return nil, err
+			}
+			if object[name], err = p.value(constant); err != nil {
+				return nil, err
+			}
+		}
+		return object, nil
+	case isNameStart(c):
+		name, _ := p.name()
+		switch name {
+		case "true":
+			return true, nil
+		case "false":
+			return false, nil
+		case "null":
+			return nil, nil
+		}
+		return gqlEnum(name), nil
+	default:
+		return nil, p.errorf("expected a value")
+	}
+}
+
+func (p *gqlParser) number() (gqlValue, error) {
+	start := p.pos
+	if p.src[p.pos] == '-' {
+		p.pos++
+	}
+	isFloat := false
+	for p.pos < len(p.src) {
+		c := p.src[p.pos]
+		if c == '.' || c == 'e' || c == 'E' || (c == '+' || c == '-') && isFloat {
+			isFloat = true
+		} else if c < '0' || c > '9' {
+			break
+		}
+		p.pos++
+	}
+	text := p.src[start:p.pos]
+	if !isFloat {
+		if n, err := strconv.ParseInt(text, 10, 64); err == nil {
+			return n, nil
+		}
+	}
+	f, err := strconv.ParseFloat(text, 64)
+	if err != nil {
+		return nil, p.errorf("invalid number %q", text)
+	}
+	return f, nil
+}
+
+func (p *gqlParser) string() (gqlValue, error) {
+	if strings.HasPrefix(p.src[p.pos:], `"""`) {
+		return nil, p.errorf("block strings are not supported")
+	}
+	p.pos++
+	var b strings.Builder
+	for p.pos < len(p.src) {
+		c := p.src[p.pos]
+		switch {
+		case c == '"':
+			p.pos++
+			return b.String(), nil
+		case c == '\n' || c == '\r':
+			return nil, p.errorf("unterminated string")
+		case c == '\\' && p.pos+1 < len(p.src):
+			escape := p.src[p.pos+1]
+			p.pos += 2
+			switch escape {
+			case '"', '\\', '/':
+				b.WriteByte(escape)
+			case 'b':
+				b.WriteByte('\b')
+			case 'f':
+				b.WriteByte('\f')
+			case 'n':
+				b.WriteByte('\n')
+			case 'r':
+				b.WriteByte('\r')
+			case 't':
+				b.WriteByte('\t')
+			case 'u':
+				if p.pos+4 > len(p.src) {
+					return nil, p.errorf("invalid unicode escape")
+				}
+				r, err := strconv.ParseUint(p.src[p.pos:p.pos+4], 16, 32)
+				if err != nil {
+					return nil, p.errorf("invalid unicode escape")
+				}
+				b.WriteRune(rune(r))
+				p.pos += 4
+			default:
+				return nil, p.errorf("invalid escape \\%c", escape)
+			}
+		default:
+			r, size := utf8.DecodeRuneInString(p.src[p.pos:])
+			b.WriteRune(r)
+			p.pos += size
+		}
+	}
+	return nil, p.errorf("unterminated string")
+}
+
+// gqlResolver computes a field from its arguments
+type gqlResolver func(ctx context.Context, args map[string]any) (any, error)
+
+// gqlObject is a resolved object: its fields' values, or resolvers for the
+// fields that are only computed when selected
+type gqlObject map[string]any
+
+// gqlError is an error in the response, with the path of the field it
+// occurred on
+type gqlError struct {
+	Message string `json:"message"`
+	Path    []any  `json:"path,omitempty"`
+}
+
+// gqlResult is a selection's result, keeping the fields in query order as
+// GraphQL requires
+type gqlResult struct {
+	keys   []string
+	values map[string]any
+}
+
+func (r *gqlResult) set(key string, value any) {
+	if _, ok := r.values[key]; !ok {
+		r.keys = append(r.keys, key)
+	}
+	r.values[key] = value
+}
+
+func (r *gqlResult) MarshalJSON() ([]byte, error) {
+	var b strings.Builder
+	b.WriteByte('{')
+	for i, key := range r.keys {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		encodedKey, _ := json.Marshal(key)
+		value, err := json.Marshal(r.values[key])
+		if err != nil {
+			return nil, err
+		}
+		b.Write(encodedKey)
+		b.WriteByte(':')
+		b.Write(value)
+	}
+	b.WriteByte('}')
+	return []byte(b.String()), nil
+}
+
+// gqlExecutor - IMPLEMENTS execution of an operation against resolved
+// objects. Field errors are collected and leave the field null.
+type gqlExecutor struct {
+	variables map[string]any
+	errors    []gqlError
+}
+
+func (e *gqlExecutor) selectFields(ctx context.Context, object gqlObject, fields []*gqlField, path []any) *gqlResult {
+	result := &gqlResult{values: make(map[string]any)}
+	for _, field := range fields {
+		fieldPath := append(append([]any{}, path...), field.key())
+		value, err := e.resolve(ctx, object, field)
+		if err == nil {
+			value, err = e.complete(ctx, value, field, fieldPath)
+		}
+		if err != nil {
+			e.errors = append(e.errors, gqlError{Message: err.Error(), Path: fieldPath})
+			value = nil
+		}
+		result.set(field.key(), value)
+	}
+	return result
+}
+
+func (e *gqlExecutor) resolve(ctx context.Context, object gqlObject, field *gqlField) (any, error) {
+	value, ok := object[field.name]
+	if !ok {
+		return nil, fmt.Errorf("cannot query field %q on type %v", field.name, object["__typename"])
+	}
+	resolver, ok := value.(gqlResolver)
+	if !ok {
+		return value, nil
+	}
+	args := make(map[string]any, len(field.args))
+	for name, arg := range field.args {
+		resolved, err := e.inputValue(arg)
+		if err != nil {
+			return nil, err
+		}
+		args[name] = resolved
+	}
+	return resolver(ctx, args)
+}
+
+// complete applies the field's selection set to an object value, or to
+// each object of a list
+func (e *gqlExecutor) complete(ctx context.Context, value any, field *gqlField, path []any) (any, error) {
+	switch v := value.(type) {
+	case gqlObject:
+		if field.selections == nil {
+			return nil, fmt.Errorf("field %q of type %v must have a selection of subfields", field.name, v["__typename"])
+		}
+		return e.selectFields(ctx, v, field.selections, path), nil
+	case []gqlObject:
+		results := make([]any, len(v))
+		for i, item := range v {
+			var err error
+			if results[i], err = e.complete(ctx, item, field, append(path, i)); err != nil {
+				return nil, err
+			}
+		}
+		return results, nil
+	default:
+		if field.selections != nil {
+			return nil, fmt.Errorf("field %q is a scalar and has no subfields", field.name)
+		}
+		return value, nil
+	}
+}
+
+// inputValue substitutes variables into an argument
+func (e *gqlExecutor) inputValue(value gqlValue) (any, error) {
+	switch v := value.(type) {
+	case gqlVariable:
+		resolved, ok := e.variables[string(v)]
+		if !ok {
+			return nil, fmt.Errorf("variable $%s is not defined", v)
+		}
+		return resolved, nil
+	case gqlEnum:
+		return string(v), nil
+	case []gqlValue:
+		list := make([]any, len(v))
+		for i, item := range v {
+			var err error
+			if list[i], err = e.inputValue(item); err != nil {
+				return nil, err
+			}
+		}
+		return list, nil
+	case map[string]gqlValue:
+		object := make(map[string]any, len(v))
+		for name, item := range v {
+			var err error
+			if object[name], err = e.inputValue(item); err != nil {
+				return nil, err
+			}
+		}
+		return object, nil
+	default:
+		return v, nil
+	}
+}
+
+// executeGraphQL runs an operation against the query or mutation root. It
+// returns the data and any field errors.
+func executeGraphQL(ctx context.Context, operation *gqlOperation, root gqlObject, variables map[string]any) (*gqlResult, []gqlError) {
+	e := &gqlExecutor{variables: make(map[string]any)}
+	for name, defaultValue := range operation.variables {
+		if value, ok := variables[name]; ok {
+			e.variables[name] = value
+		} else {
+			e.variables[name] = defaultValue
+		}
+	}
+	return e.selectFields(ctx, root, operation.selections, nil), e.errors
+}
+
+// gqlArgString returns a string argument; required ones must be set
+func gqlArgString(args map[string]any, name string, required bool) (string, error) {
+	value, ok := args[name]
+	if !ok || value == nil {
+		if required {
+			return "", fmt.Errorf("argument %q is required", name)
+		}
+		return "", nil
+	}
+	s, ok := value.(string)
+	if !ok {
+		return "", fmt.Errorf("argument %q must be a string", name)
+	}
+	return s, nil
+}
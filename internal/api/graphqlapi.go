@@ -0,0 +1,337 @@
+package api
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"time"
+
+	"interview-task/internal/config"
+	"interview-task/internal/service"
+	"interview-task/internal/storage"
+)
+
+// maxGraphQLRequestBytes bounds GraphQL request bodies; payloads saved
+// through the API count towards it
+const maxGraphQLRequestBytes = 16 << 20
+
+// graphQLSchema documents the schema the resolvers below implement. It is
+// served at GET /graphql/schema for client code generators.
+const graphQLSchema = `type Query {
+  item(id: ID!, storageType: String!): Item
+  items(storageType: String!, labels: [LabelInput!], source: String, contentType: String): [Item!]!
+}
+
+type Mutation {
+  saveData(input: SaveDataInput!): SaveDataResult!
+}
+
+type Item {
+  id: ID!
+  size: Int!
+  contentType: String
+  sha256: String
+  labels: [Label!]!
+  source: String
+  "RFC 3339 timestamp, null for items that don't expire"
+  expiresAt: String
+  "Base64 payload, loaded only when selected"
+  data: String!
+}
+
+type Label {
+  key: String!
+  value: String!
+}
+
+input LabelInput {
+  key: String!
+  value: String!
+}
+
+input SaveDataInput {
+  storageType: String!
+  "Base64 payload"
+  data: String!
+  contentType: String
+  "Go duration such as 24h"
+  ttl: String
+  labels: [LabelInput!]
+  source: String
+}
+
+type SaveDataResult {
+  id: ID!
+  sha256: String!
+  "success, or accepted for a save spooled during an outage"
+  status: String!
+}
+`
+
+// GraphQLRequest is the body of POST /graphql
+type GraphQLRequest struct {
+	Query         string         `json:"query"`
+	OperationName string         `json:"operationName"`
+	Variables     map[string]any `json:"variables"`
+}
+
+// GraphQLHandler - IMPLEMENTS the /graphql endpoint over DataService, for
+// clients that prefer querying items and their metadata in one request to
+// the REST filters. Field errors are reported in "errors" next to the
+// partial data, as GraphQL clients expect, so responses are 200 unless the
+// request can't be parsed.
+type GraphQLHandler struct {
+	service *service.DataService
+}
+
+func NewGraphQLHandler(service *service.DataService) *GraphQLHandler {
+	return &GraphQLHandler{service: service}
+}
+
+// HandleQuery executes a POSTed operation, or a query passed in ?query= on
+// GET. Mutations are only accepted over POST.
+func (h *GraphQLHandler) HandleQuery(w http.ResponseWriter, r *http.Request) {
+	var req GraphQLRequest
+	if r.Method == http.MethodGet {
+		req.Query = r.URL.Query().Get("query")
+		req.OperationName = r.URL.Query().Get("operationName")
+		if variables := r.URL.Query().Get("variables"); variables != "" {
+			if err := json.Unmarshal([]byte(variables), &req.Variables); err != nil {
+				writeGraphQLError(w, http.StatusBadRequest, "variables must be a JSON object")
+				return
+			}
+		}
+	} else {
+		r.Body = http.MaxBytesReader(w, r.Body, maxGraphQLRequestBytes)
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeGraphQLError(w, http.StatusBadRequest, "Invalid JSON format")
+			return
+		}
+	}
+
+	operation, err := parseGraphQL(req.Query, req.OperationName)
+	if err != nil {
+		writeGraphQLError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	var root gqlObject
+	switch {
+	case operation.kind == "mutation" && r.Method != http.MethodPost:
+		writeGraphQLError(w, http.StatusMethodNotAllowed, "mutations must be sent with POST")
+		return
+	case operation.kind == "mutation":
+		root = h.mutationRoot()
+	default:
+		root = h.queryRoot()
+	}
+
+	ctx := service.WithEndpoint(r.Context(), r.URL.Path)
+	data, fieldErrors := executeGraphQL(ctx, operation, root, req.Variables)
+	response := map[string]any{"data": data}
+	if len(fieldErrors) > 0 {
+		response["errors"] = fieldErrors
+	}
+	writeJSON(w, http.StatusOK, response)
+}
+
+// HandleSchema serves the schema in GraphQL SDL
+func (h *GraphQLHandler) HandleSchema(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Write([]byte(graphQLSchema))
+}
+
+func writeGraphQLError(w http.ResponseWriter, code int, message string) {
+	writeJSON(w, code, map[string]any{"errors": []gqlError{{Message: message}}})
+}
+
+func (h *GraphQLHandler) queryRoot() gqlObject {
+	return gqlObject{
+		"__typename": "Query",
+		"item": gqlResolver(func(ctx context.Context, args map[string]any) (any, error) {
+			id, storageType, err := gqlItemArgs(args)
+			if err != nil {
+				return nil, err
+			}
+			summary, err := h.service.DescribeItem(ctx, storageType, id)
+			if errors.Is(err, storage.ErrNotFound) {
+				return nil, nil
+			}
+			if err != nil {
+				return nil, err
+			}
+			return h.itemObject(storageType, *summary), nil
+		}),
+		"items": gqlResolver(func(ctx context.Context, args map[string]any) (any, error) {
+			storageType, err := gqlArgString(args, "storageType", true)
+			if err != nil {
+				return nil, err
+			}
+			var filter service.ItemFilter
+			if filter.Labels, err = gqlLabels(args["labels"]); err != nil {
+				return nil, err
+			}
+			if filter.Source, err = gqlArgString(args, "source", false); err != nil {
+				return nil, err
+			}
+			if filter.ContentType, err = gqlArgString(args, "contentType", false); err != nil {
+				return nil, err
+			}
+			summaries, err := h.service.FindItems(ctx, storageType, filter)
+			if err != nil {
+				return nil, err
+			}
+			items := make([]gqlObject, len(summaries))
+			for i, summary := range summaries {
+				items[i] = h.itemObject(storageType, summary)
+			}
+			return items, nil
+		}),
+	}
+}
+
+func (h *GraphQLHandler) mutationRoot() gqlObject {
+	return gqlObject{
+		"__typename": "Mutation",
+		"saveData": gqlResolver(func(ctx context.Context, args map[string]any) (any, error) {
+			input, ok := args["input"].(map[string]any)
+			if !ok {
+				return nil, fmt.Errorf("argument \"input\" is required")
+			}
+			req, err := gqlSaveRequest(input)
+			if err != nil {
+				return nil, err
+			}
+			id, err := h.service.SaveData(ctx, req)
+			status := "success"
+			if errors.Is(err, service.ErrSpooled) {
+				status = "accepted"
+			} else if err != nil {
+				return nil, err
+			}
+			return gqlObject{
+				"__typename": "SaveDataResult",
+				"id":         id,
+				"sha256":     storage.PayloadSHA256(req.Data),
+				"status":     status,
+			}, nil
+		}),
+	}
+}
+
+// itemObject resolves an Item. Its payload is only loaded when selected.
+func (h *GraphQLHandler) itemObject(storageType string, summary service.ItemSummary) gqlObject {
+	keys := make([]string, 0, len(summary.Labels))
+	for key := range summary.Labels {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	labels := make([]gqlObject, 0, len(keys))
+	for _, key := range keys {
+		labels = append(labels, gqlObject{"__typename": "Label", "key": key, "value": summary.Labels[key]})
+	}
+	var expiresAt any
+	if !summary.ExpiresAt.IsZero() {
+		expiresAt = summary.ExpiresAt.UTC().Format(time.RFC3339Nano)
+	}
+	return gqlObject{
+		"__typename":  "Item",
+		"id":          summary.ID,
+		"size":        summary.Size,
+		"contentType": gqlNullable(summary.ContentType),
+		"sha256":      gqlNullable(summary.SHA256),
+		"labels":      labels,
+		"source":      gqlNullable(summary.Source),
+		"expiresAt":   expiresAt,
+		"data": gqlResolver(func(ctx context.Context, args map[string]any) (any, error) {
+			item, err := h.service.GetData(ctx, storageType, summary.ID)
+			if err != nil {
+				return nil, err
+			}
+			return base64.StdEncoding.EncodeToString(item.Data), nil
+		}),
+	}
+}
+
+// gqlNullable returns null for empty strings
+func gqlNullable(s string) any {
+	if s == "" {
+		return nil
+	}
+	return s
+}
+
+func gqlItemArgs(args map[string]any) (id, storageType string, err error) {
+	if id, err = gqlArgString(args, "id", true); err != nil {
+		return "", "", err
+	}
+	storageType, err = gqlArgString(args, "storageType", true)
+	return id, storageType, err
+}
+
+// gqlLabels converts a [LabelInput!] value to a label map
+func gqlLabels(value any) (map[string]string, error) {
+	if value == nil {
+		return nil, nil
+	}
+	list, ok := value.([]any)
+	if !ok {
+		// A single input object is coerced to a list of one
+		list = []any{value}
+	}
+	labels := make(map[string]string, len(list))
+	for _, item := range list {
+		object, ok := item.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("labels must be objects with a key and a value")
+		}
+		key, err := gqlArgString(object, "key", true)
+		if err != nil {
+			return nil, err
+		}
+		if labels[key], err = gqlArgString(object, "value", true); err != nil {
+			return nil, err
+		}
+	}
+	return labels, nil
+}
+
+// gqlSaveRequest converts a SaveDataInput to the service's SaveRequest
+func gqlSaveRequest(input map[string]any) (*service.SaveRequest, error) {
+	req := &service.SaveRequest{}
+	var err error
+	if req.StorageType, err = gqlArgString(input, "storageType", true); err != nil {
+		return nil, err
+	}
+	encoded, err := gqlArgString(input, "data", true)
+	if err != nil {
+		return nil, err
+	}
+	if req.Data, err = base64.StdEncoding.DecodeString(encoded); err != nil {
+		return nil, fmt.Errorf("%w: data must be base64: %w", service.ErrValidation, err)
+	}
+	if req.ContentType, err = gqlArgString(input, "contentType", false); err != nil {
+		return nil, err
+	}
+	ttl, err := gqlArgString(input, "ttl", false)
+	if err != nil {
+		return nil, err
+	}
+	if ttl != "" {
+		d, err := time.ParseDuration(ttl)
+		if err != nil {
+			return nil, fmt.Errorf("%w: invalid ttl: %w", service.ErrValidation, err)
+		}
+		req.TTL = config.Duration(d)
+	}
+	if req.Labels, err = gqlLabels(input["labels"]); err != nil {
+		return nil, err
+	}
+	if req.Source, err = gqlArgString(input, "source", false); err != nil {
+		return nil, err
+	}
+	return req, nil
+}
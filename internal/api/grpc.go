@@ -0,0 +1,346 @@
+package api
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"interview-task/internal/config"
+	"interview-task/internal/logging"
+	"interview-task/internal/metrics"
+	"interview-task/internal/service"
+	"interview-task/internal/storage"
+)
+
+// gRPC status codes
+const (
+	grpcOK                = 0
+	grpcInvalidArgument   = 3
+	grpcDeadlineExceeded  = 4
+	grpcNotFound          = 5
+	grpcPermissionDenied  = 7
+	grpcResourceExhausted = 8
+	grpcAborted           = 10
+	grpcUnimplemented     = 12
+	grpcInternal          = 13
+	grpcUnavailable       = 14
+)
+
+const (
+	grpcServicePathPrefix = "/datastore.v1.DataService/"
+	// grpcMessageHeaderBytes is the size of the compressed flag and length
+	// preceding each message
+	grpcMessageHeaderBytes = 5
+)
+
+// grpcError is a failed call with its gRPC status code
+type grpcError struct {
+	code    int
+	message string
+}
+
+func (e *grpcError) Error() string {
+	return e.message
+}
+
+// grpcCodeForError maps service errors to gRPC codes along the lines of
+// their HTTP statuses
+func grpcCodeForError(err error) int {
+	var callErr *grpcError
+	if errors.As(err, &callErr) {
+		return callErr.code
+	}
+	switch statusForError(err) {
+	case http.StatusBadRequest:
+		return grpcInvalidArgument
+	case http.StatusNotFound:
+		return grpcNotFound
+	case http.StatusForbidden:
+		return grpcPermissionDenied
+	case http.StatusConflict:
+		return grpcAborted
+	case http.StatusTooManyRequests, http.StatusInsufficientStorage:
+		return grpcResourceExhausted
+	case http.StatusServiceUnavailable:
+		return grpcUnavailable
+	case http.StatusGatewayTimeout:
+		return grpcDeadlineExceeded
+	default:
+		return grpcInternal
+	}
+}
+
+// grpcMethod handles the request message of one unary method
+type grpcMethod func(ctx context.Context, request []byte) (protoMarshaler, error)
+
+// protoMarshaler is implemented by response messages
+type protoMarshaler interface {
+	MarshalProto() []byte
+}
+
+// GRPCServer - IMPLEMENTS the datastore.v1.DataService gRPC service of
+// proto/datastore.proto over the same DataService as the HTTP API. Calls
+// are unary and uncompressed. It is an http.Handler served over cleartext
+// HTTP/2, so the HTTP middleware runs on calls too: authentication,
+// tenancy, request IDs and load shedding behave as on HTTP, and clients map
+// the HTTP statuses of rejected calls to gRPC codes.
+type GRPCServer struct {
+	service *service.DataService
+	config  config.GRPCConfig
+	metrics *metrics.Metrics
+	methods map[string]grpcMethod
+	server  *http.Server
+}
+
+func NewGRPCServer(service *service.DataService, config config.GRPCConfig, metrics *metrics.Metrics) *GRPCServer {
+	metrics.Describe("grpc_requests_total", "gRPC calls by method and status code")
+	var protocols http.Protocols
+	protocols.SetUnencryptedHTTP2(true)
+	// The server exists from the start, so a Shutdown before Serve stops it
+	g := &GRPCServer{service: service, config: config, metrics: metrics,
+		server: &http.Server{Addr: ":" + config.Port, Protocols: &protocols}}
+	g.methods = map[string]grpcMethod{
+		"SaveData":   g.saveData,
+		"GetData":    g.getData,
+		"ListData":   g.listData,
+		"DeleteData": g.deleteData,
+	}
+	return g
+}
+
+// Serve listens on the configured port until Shutdown
+func (g *GRPCServer) Serve(handler http.Handler) error {
+	g.server.Handler = handler
+	logging.Printf("gRPC server starting on :%s", g.config.Port)
+	err := g.server.ListenAndServe()
+	if errors.Is(err, http.ErrServerClosed) {
+		return nil
+	}
+	return err
+}
+
+// Shutdown stops accepting calls and waits for those in progress
+func (g *GRPCServer) Shutdown(ctx context.Context) error {
+	return g.server.Shutdown(ctx)
+}
+
+func (g *GRPCServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.ProtoMajor != 2 || !strings.HasPrefix(r.Header.Get("Content-Type"), "application/grpc") {
+		http.Error(w, "gRPC requires HTTP/2 and an application/grpc content type", http.StatusUnsupportedMediaType)
+		return
+	}
+	start := time.Now()
+	name, _ := strings.CutPrefix(r.URL.Path, grpcServicePathPrefix)
+	response, err := g.call(r, name)
+	code := grpcCodeForError(err)
+	if err == nil {
+		code = grpcOK
+	}
+	g.metrics.Add("grpc_requests_total", 1, "method", name, "code", strconv.Itoa(code))
+	logging.Printf("gRPC %s: code %d in %s", r.URL.Path, code, time.Since(start).Round(time.Microsecond))
+
+	w.Header().Set("Content-Type", "application/grpc")
+	if err != nil {
+		// A trailers-only response: the status goes in the headers
+		w.Header().Set("Grpc-Status", strconv.Itoa(code))
+		w.Header().Set("Grpc-Message", grpcEncodeMessage(err.Error()))
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+	message := response.MarshalProto()
+	frame := make([]byte, grpcMessageHeaderBytes, grpcMessageHeaderBytes+len(message))
+	binary.BigEndian.PutUint32(frame[1:], uint32(len(message)))
+	w.WriteHeader(http.StatusOK)
+	w.Write(append(frame, message...))
+	w.Header().Set(http.TrailerPrefix+"Grpc-Status", strconv.Itoa(grpcOK))
+}
+
+// call reads the request message of a unary call and runs its method
+func (g *GRPCServer) call(r *http.Request, name string) (protoMarshaler, error) {
+	method, ok := g.methods[name]
+	if !ok || !strings.HasPrefix(r.URL.Path, grpcServicePathPrefix) {
+		return nil, &grpcError{grpcUnimplemented, "unknown method " + r.URL.Path}
+	}
+	if encoding := r.Header.Get("Grpc-Encoding"); encoding != "" && encoding != "identity" {
+		return nil, &grpcError{grpcUnimplemented, "compressed messages are not supported"}
+	}
+
+	var header [grpcMessageHeaderBytes]byte
+	if _, err := io.ReadFull(r.Body, header[:]); err != nil {
+		return nil, &grpcError{grpcInvalidArgument, "missing request message"}
+	}
+	if header[0] != 0 {
+		return nil, &grpcError{grpcUnimplemented, "compressed messages are not supported"}
+	}
+	size := binary.BigEndian.Uint32(header[1:])
+	if int64(size) > int64(g.config.MaxMessageBytes) {
+		return nil, &grpcError{grpcResourceExhausted, fmt.Sprintf("request message exceeds %d bytes", g.config.MaxMessageBytes)}
+	}
+	message := make([]byte, size)
+	if _, err := io.ReadFull(r.Body, message); err != nil {
+		return nil, &grpcError{grpcInvalidArgument, "truncated request message"}
+	}
+	return method(service.WithEndpoint(r.Context(), r.URL.Path), message)
+}
+
+// grpcEncodeMessage percent-encodes a status message as the gRPC protocol
+// requires
+func grpcEncodeMessage(message string) string {
+	var b strings.Builder
+	for i := 0; i < len(message); i++ {
+		if c := message[i]; c < 0x20 || c > 0x7e || c == '%' {
+			fmt.Fprintf(&b, "%%%02X", c)
+		} else {
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
+
+func (g *GRPCServer) saveData(ctx context.Context, request []byte) (protoMarshaler, error) {
+	var req service.SaveRequest
+	if err := (*saveRequestMessage)(&req).UnmarshalProto(request); err != nil {
+		return nil, &grpcError{grpcInvalidArgument, err.Error()}
+	}
+	id, err := g.service.SaveData(ctx, &req)
+	if errors.Is(err, service.ErrSpooled) {
+		return &SaveResponse{ID: id, Message: "Backend unavailable, data will be saved when it recovers", SHA256: storage.PayloadSHA256(req.Data), Status: "accepted"}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &SaveResponse{ID: id, Message: "Data saved successfully", SHA256: storage.PayloadSHA256(req.Data), Status: "success"}, nil
+}
+
+func (g *GRPCServer) getData(ctx context.Context, request []byte) (protoMarshaler, error) {
+	var req ItemRequest
+	if err := req.UnmarshalProto(request); err != nil {
+		return nil, &grpcError{grpcInvalidArgument, err.Error()}
+	}
+	item, err := g.service.GetData(ctx, req.StorageType, req.ID)
+	if err != nil {
+		return nil, err
+	}
+	return (*storedItemMessage)(item), nil
+}
+
+func (g *GRPCServer) listData(ctx context.Context, request []byte) (protoMarshaler, error) {
+	var req ListDataRequest
+	if err := req.UnmarshalProto(request); err != nil {
+		return nil, &grpcError{grpcInvalidArgument, err.Error()}
+	}
+	items, err := g.service.FindItems(ctx, req.StorageType, req.Filter)
+	if err != nil {
+		return nil, err
+	}
+	return ItemSummaries(items), nil
+}
+
+func (g *GRPCServer) deleteData(ctx context.Context, request []byte) (protoMarshaler, error) {
+	var req ItemRequest
+	if err := req.UnmarshalProto(request); err != nil {
+		return nil, &grpcError{grpcInvalidArgument, err.Error()}
+	}
+	if err := g.service.DeleteData(ctx, req.StorageType, req.ID); err != nil {
+		return nil, err
+	}
+	return emptyMessage{}, nil
+}
+
+// ItemRequest names an item, as datastore.v1.GetDataRequest and
+// DeleteDataRequest do
+type ItemRequest struct {
+	ID          string
+	StorageType string
+}
+
+func (req *ItemRequest) UnmarshalProto(data []byte) error {
+	fields := &protoFields{data: data}
+	for fields.next() {
+		var err error
+		switch fields.number {
+		case 1:
+			req.ID, err = fields.string()
+		case 2:
+			req.StorageType, err = fields.string()
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return fields.err
+}
+
+// ListDataRequest is a datastore.v1.ListDataRequest
+type ListDataRequest struct {
+	StorageType string
+	Filter      service.ItemFilter
+}
+
+func (req *ListDataRequest) UnmarshalProto(data []byte) error {
+	fields := &protoFields{data: data}
+	for fields.next() {
+		var err error
+		switch fields.number {
+		case 1:
+			req.StorageType, err = fields.string()
+		case 2:
+			err = fields.mapEntry(&req.Filter.Labels)
+		case 3:
+			req.Filter.Source, err = fields.string()
+		case 4:
+			req.Filter.ContentType, err = fields.string()
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return fields.err
+}
+
+// storedItemMessage is a StoredItem in its datastore.v1.GetDataResponse
+// encoding
+type storedItemMessage service.StoredItem
+
+// MarshalProto encodes a datastore.v1.GetDataResponse
+func (item *storedItemMessage) MarshalProto() []byte {
+	var buf []byte
+	buf = appendProtoBytes(buf, 1, item.Data)
+	buf = appendProtoString(buf, 2, item.ContentType)
+	buf = appendProtoString(buf, 3, item.SHA256)
+	return buf
+}
+
+// ItemSummaries encodes as a datastore.v1.ListDataResponse
+type ItemSummaries []service.ItemSummary
+
+func (items ItemSummaries) MarshalProto() []byte {
+	var buf []byte
+	for _, item := range items {
+		var encoded []byte
+		encoded = appendProtoString(encoded, 1, item.ID)
+		encoded = appendProtoVarint(encoded, 2, uint64(item.Size))
+		encoded = appendProtoString(encoded, 3, item.ContentType)
+		encoded = appendProtoString(encoded, 4, item.SHA256)
+		encoded = appendProtoMap(encoded, 5, item.Labels)
+		encoded = appendProtoString(encoded, 6, item.Source)
+		if !item.ExpiresAt.IsZero() {
+			encoded = appendProtoString(encoded, 7, item.ExpiresAt.UTC().Format(time.RFC3339Nano))
+		}
+		buf = binary.AppendUvarint(buf, 1<<3|wireBytes)
+		buf = binary.AppendUvarint(buf, uint64(len(encoded)))
+		buf = append(buf, encoded...)
+	}
+	return buf
+}
+
+// emptyMessage is a response without fields
+type emptyMessage struct{}
+
+func (emptyMessage) MarshalProto() []byte { return nil }
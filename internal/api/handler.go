@@ -0,0 +1,210 @@
+// Package api serves the data service over HTTP, gRPC, GraphQL and the S3
+// protocol, consumes NATS and MQTT, and holds the middleware and codecs
+// they share.
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"interview-task/internal/logging"
+	"interview-task/internal/service"
+	"interview-task/internal/storage"
+)
+
+// HTTPHandler - IMPLEMENTS Single Responsibility and Dependency Injection
+type HTTPHandler struct {
+	dataService *service.DataService
+	codecs      *CodecRegistry
+}
+
+func NewHTTPHandler(dataService *service.DataService) *HTTPHandler {
+	return &HTTPHandler{dataService: dataService, codecs: DefaultCodecs()}
+}
+
+func (h *HTTPHandler) HandleSaveData(w http.ResponseWriter, r *http.Request) {
+	// Validate HTTP method
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	defer r.Body.Close()
+	var req service.SaveRequest
+	if codec, err := h.decode(r, &req); err != nil {
+		writeDecodeError(w, codec, err, http.StatusInternalServerError)
+		return
+	}
+	if err := service.ChecksumsFromHeaders(r, &req); err != nil {
+		http.Error(w, err.Error(), statusForError(err))
+		return
+	}
+
+	// Process request
+	ctx := service.WithEndpoint(r.Context(), r.URL.Path)
+	if value := r.URL.Query().Get("dry_run"); value != "" {
+		dryRun, err := strconv.ParseBool(value)
+		if err != nil {
+			// A mistyped flag mustn't turn a dry run into a save
+			http.Error(w, "dry_run must be true or false", http.StatusBadRequest)
+			return
+		}
+		if dryRun {
+			// Every check runs but nothing is stored
+			plan, err := h.dataService.PlanSave(ctx, &req)
+			if err != nil {
+				writeServiceError(w, err)
+				return
+			}
+			h.respond(w, r, http.StatusOK, plan)
+			return
+		}
+	}
+	id, err := h.dataService.SaveData(ctx, &req)
+	if errors.Is(err, service.ErrSpooled) {
+		h.respond(w, r, http.StatusAccepted, &SaveResponse{
+			Message: "Backend unavailable, data will be saved when it recovers",
+			Status:  "accepted",
+			ID:      id,
+			SHA256:  storage.PayloadSHA256(req.Data),
+		})
+		return
+	}
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	// Send structured response
+	w.Header().Set("ETag", service.ETag(1, storage.PayloadSHA256(req.Data)))
+	h.respond(w, r, http.StatusOK, &SaveResponse{
+		Message: "Data saved successfully",
+		Status:  "success",
+		ID:      id,
+		SHA256:  storage.PayloadSHA256(req.Data),
+	})
+}
+
+// HandleGetData returns a stored payload, optionally converted to another
+// format via ?format=json|yaml|csv
+func (h *HTTPHandler) HandleGetData(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	var converter PayloadConverter
+	if format := query.Get("format"); format != "" {
+		var err error
+		converter, err = NewPayloadConverter(format)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	var item *service.StoredItem
+	var err error
+	if asOf := query.Get("as_of"); asOf != "" {
+		// Time-travel read against the item's version history
+		at, parseErr := time.Parse(time.RFC3339, asOf)
+		if parseErr != nil {
+			http.Error(w, "as_of must be an RFC 3339 timestamp", http.StatusBadRequest)
+			return
+		}
+		item, err = h.dataService.GetDataAsOf(r.Context(), query.Get("storage_type"), r.PathValue("id"), at)
+	} else {
+		item, err = h.dataService.GetData(r.Context(), query.Get("storage_type"), r.PathValue("id"))
+	}
+	if err != nil {
+		http.Error(w, err.Error(), statusForError(err))
+		return
+	}
+
+	data := item.Data
+	if converter == nil {
+		w.Header().Set("Content-Type", item.ContentType)
+		w.Header().Set("X-Content-Type-Options", "nosniff")
+		w.Header().Set(service.HeaderContentSHA256, item.SHA256)
+		if item.ETag != "" {
+			w.Header().Set("ETag", item.ETag)
+		}
+
+		w.Write(data)
+		return
+	}
+
+	if !json.Valid(data) {
+		http.Error(w, "payload is not structured JSON and cannot be converted", http.StatusUnprocessableEntity)
+		return
+	}
+	w.Header().Set("Content-Type", converter.ContentType())
+	if err := converter.Convert(w, bytes.NewReader(data)); err != nil {
+		// Headers are already sent, so the best we can do is log and stop
+		logging.Printf("Failed to convert payload %s: %v", r.PathValue("id"), err)
+	}
+}
+
+func (h *HTTPHandler) HandleDeleteData(w http.ResponseWriter, r *http.Request) {
+	ctx := service.WithIfMatch(r.Context(), r.Header.Get(service.HeaderIfMatch))
+	err := h.dataService.DeleteData(ctx, r.URL.Query().Get("storage_type"), r.PathValue("id"))
+	if err != nil {
+		http.Error(w, err.Error(), statusForError(err))
+		return
+	}
+
+	h.respond(w, r, http.StatusOK, map[string]string{
+		"message": "Data deleted successfully",
+		"status":  "success",
+	})
+}
+
+// writeServiceError reports a service error, including field-level details
+// when a payload failed schema validation
+func writeServiceError(w http.ResponseWriter, err error) {
+	var schemaErr *service.SchemaValidationError
+	if errors.As(err, &schemaErr) {
+		writeJSON(w, statusForError(err), map[string]any{
+			"error":  service.ErrValidation.Error(),
+			"fields": schemaErr.Fields,
+		})
+		return
+	}
+	http.Error(w, err.Error(), statusForError(err))
+}
+
+// statusForError maps service errors onto HTTP status codes
+func statusForError(err error) int {
+	var quotaErr *service.QuotaError
+	switch {
+	case errors.As(err, &quotaErr) && quotaErr.Limit == "bytes":
+		return http.StatusInsufficientStorage
+	case errors.As(err, &quotaErr):
+		return http.StatusTooManyRequests
+	case errors.Is(err, service.ErrStorageTypeForbidden):
+		return http.StatusForbidden
+	case errors.Is(err, service.ErrInfected):
+		return http.StatusUnprocessableEntity
+	case errors.Is(err, service.ErrScannerUnavailable):
+		// Checked ahead of validation, as hook vetoes and storage that
+		// can't be opened are validation errors
+		return http.StatusServiceUnavailable
+	case errors.Is(err, service.ErrValidation):
+		return http.StatusBadRequest
+	case errors.Is(err, storage.ErrNotFound), errors.Is(err, service.ErrKeyNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, storage.ErrConflict), errors.Is(err, service.ErrLockTimeout), errors.Is(err, storage.ErrKeyConflict), errors.Is(err, service.ErrLegalHold):
+		return http.StatusConflict
+	case errors.Is(err, service.ErrPreconditionFailed):
+		return http.StatusPreconditionFailed
+	case errors.Is(err, storage.ErrInsufficientStorage):
+		return http.StatusInsufficientStorage
+	case errors.Is(err, storage.ErrStorageUnavailable), errors.Is(err, storage.ErrPoolTimeout), errors.Is(err, service.ErrMaintenance):
+		return http.StatusServiceUnavailable
+	case errors.Is(err, service.ErrTimeout):
+		return http.StatusGatewayTimeout
+	default:
+		return http.StatusInternalServerError
+	}
+}
@@ -0,0 +1,182 @@
+package api
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"interview-task/internal/service"
+)
+
+// HeaderIdempotencyKey lets clients retry a save safely
+const HeaderIdempotencyKey = "Idempotency-Key"
+
+// idempotentResponse is the stored outcome of the first request with a key
+type idempotentResponse struct {
+	fingerprint [32]byte
+	pending     bool
+	status      int
+	header      http.Header
+	body        []byte
+	expires     time.Time
+}
+
+// IdempotencyStore - IMPLEMENTS Idempotency-Key handling. The first response
+// for a key is stored and replayed to retries within the window, so a
+// client that timed out can retry without saving twice. Responses live in
+// memory; a restart forgets them.
+type IdempotencyStore struct {
+	window time.Duration
+
+	mu        sync.Mutex
+	responses map[string]*idempotentResponse
+	lastSweep time.Time
+}
+
+func NewIdempotencyStore(window time.Duration) *IdempotencyStore {
+	return &IdempotencyStore{window: window, responses: make(map[string]*idempotentResponse)}
+}
+
+// Wrap makes next idempotent for requests carrying an Idempotency-Key.
+// Keys are scoped to the caller and tenant, and a key reused for a different
+// request body is rejected. Server errors aren't stored so they can be
+// retried.
+func (s *IdempotencyStore) Wrap(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get(HeaderIdempotencyKey)
+		if key == "" || s.window <= 0 {
+			next(w, r)
+			return
+		}
+		if len(key) > 255 {
+			http.Error(w, "Idempotency-Key is too long", http.StatusBadRequest)
+			return
+		}
+
+		// The body is buffered so it can be both fingerprinted and handled
+		body := getBuffer()
+		defer putBuffer(body)
+		if _, err := body.ReadFrom(r.Body); err != nil {
+			http.Error(w, "Failed to read body", http.StatusBadRequest)
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body.Bytes()))
+
+		ctx := r.Context()
+		scope := service.TenantFromContext(ctx) + "\x00" + service.PrincipalFromContext(ctx).Name + "\x00" + key
+		hash := sha256.New()
+		// The query is part of the request, so a dry run and the save after
+		// it can't share a key
+		io.WriteString(hash, r.Method+" "+r.URL.Path+"?"+r.URL.RawQuery+"\x00")
+		hash.Write(body.Bytes())
+		var fingerprint [32]byte
+		hash.Sum(fingerprint[:0])
+
+		stored, replay := s.claim(scope, fingerprint)
+		switch {
+		case stored == nil:
+		case stored.fingerprint != fingerprint:
+			http.Error(w, "Idempotency-Key was already used for a different request", http.StatusUnprocessableEntity)
+			return
+		case stored.pending:
+			http.Error(w, "A request with this Idempotency-Key is still in progress", http.StatusConflict)
+			return
+		case replay:
+			for name, values := range stored.header {
+				w.Header()[name] = values
+			}
+			w.Header().Set("Idempotent-Replayed", "true")
+			w.WriteHeader(stored.status)
+			w.Write(stored.body)
+			return
+		}
+
+		recorder := &responseRecorder{ResponseWriter: w, status: http.StatusOK}
+		completed := false
+		defer func() {
+			// A panicking handler releases the key as a server error does,
+			// while the panic goes on to the recovery middleware
+			if !completed {
+				s.release(scope)
+			}
+		}()
+		next(recorder, r)
+		completed = true
+		s.complete(scope, fingerprint, recorder)
+	}
+}
+
+// claim returns the stored response for scope and whether it can be replayed.
+// When nothing is stored it reserves the key and returns nil.
+func (s *IdempotencyStore) claim(scope string, fingerprint [32]byte) (*idempotentResponse, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	s.sweepLocked(now)
+	if stored, ok := s.responses[scope]; ok && now.Before(stored.expires) {
+		return stored, !stored.pending
+	}
+	s.responses[scope] = &idempotentResponse{
+		fingerprint: fingerprint,
+		pending:     true,
+		expires:     now.Add(s.window),
+	}
+	return nil, false
+}
+
+func (s *IdempotencyStore) complete(scope string, fingerprint [32]byte, recorder *responseRecorder) {
+	if recorder.status >= http.StatusInternalServerError {
+		s.release(scope)
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.responses[scope] = &idempotentResponse{
+		fingerprint: fingerprint,
+		status:      recorder.status,
+		header:      recorder.Header().Clone(),
+		body:        recorder.body.Bytes(),
+		expires:     time.Now().Add(s.window),
+	}
+}
+
+// release forgets a key reserved by claim, so the request can be retried
+func (s *IdempotencyStore) release(scope string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.responses, scope)
+}
+
+// sweepLocked drops expired responses at most once a minute; callers hold s.mu
+func (s *IdempotencyStore) sweepLocked(now time.Time) {
+	if now.Sub(s.lastSweep) < time.Minute {
+		return
+	}
+	s.lastSweep = now
+	for scope, stored := range s.responses {
+		if !stored.pending && now.After(stored.expires) {
+			delete(s.responses, scope)
+		}
+	}
+}
+
+// responseRecorder passes a response through while keeping a copy of it
+type responseRecorder struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (r *responseRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *responseRecorder) Write(data []byte) (int, error) {
+	r.body.Write(data)
+	return r.ResponseWriter.Write(data)
+}
@@ -0,0 +1,68 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// serveIdempotent sends a POST with an Idempotency-Key through handler
+func serveIdempotent(handler http.HandlerFunc, key string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(http.MethodPost, "/v1/save-data", strings.NewReader(`{"data":"eA=="}`))
+	r.Header.Set(HeaderIdempotencyKey, key)
+	w := httptest.NewRecorder()
+	handler(w, r)
+	return w
+}
+
+func TestIdempotencyReplaysResponse(t *testing.T) {
+	calls := 0
+	wrapped := NewIdempotencyStore(time.Hour).Wrap(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.WriteHeader(http.StatusCreated)
+	})
+	serveIdempotent(wrapped, "k")
+	w := serveIdempotent(wrapped, "k")
+	if w.Code != http.StatusCreated || w.Header().Get("Idempotent-Replayed") != "true" {
+		t.Fatalf("retry got %d, replayed %q", w.Code, w.Header().Get("Idempotent-Replayed"))
+	}
+	if calls != 1 {
+		t.Fatalf("handler ran %d times, want 1", calls)
+	}
+}
+
+func TestIdempotencyReleasesKeyAfterServerError(t *testing.T) {
+	status := http.StatusInternalServerError
+	wrapped := NewIdempotencyStore(time.Hour).Wrap(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+	})
+	serveIdempotent(wrapped, "k")
+	status = http.StatusOK
+	if w := serveIdempotent(wrapped, "k"); w.Code != http.StatusOK || w.Header().Get("Idempotent-Replayed") != "" {
+		t.Fatalf("retry after a server error got %d, replayed %q", w.Code, w.Header().Get("Idempotent-Replayed"))
+	}
+}
+
+func TestIdempotencyReleasesKeyAfterPanic(t *testing.T) {
+	panics := true
+	wrapped := NewIdempotencyStore(time.Hour).Wrap(func(w http.ResponseWriter, r *http.Request) {
+		if panics {
+			panic("handler failed")
+		}
+		w.WriteHeader(http.StatusOK)
+	})
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Fatal("the handler's panic was swallowed")
+			}
+		}()
+		serveIdempotent(wrapped, "k")
+	}()
+	panics = false
+	if w := serveIdempotent(wrapped, "k"); w.Code != http.StatusOK {
+		t.Fatalf("retry after a panic got %d: %s", w.Code, w.Body)
+	}
+}
@@ -0,0 +1,246 @@
+package api
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"interview-task/internal/config"
+	"interview-task/internal/service"
+)
+
+// maxImportConcurrency caps the concurrency a request may ask for
+const maxImportConcurrency = 64
+
+// ImportHandler serves POST /import: a streamed file of records, each saved
+// as a SaveRequest. Records are saved as they are read, so a failing record
+// doesn't stop the ones after it.
+type ImportHandler struct {
+	dataService *service.DataService
+	concurrency int
+}
+
+func NewImportHandler(dataService *service.DataService, concurrency int) *ImportHandler {
+	return &ImportHandler{dataService: dataService, concurrency: concurrency}
+}
+
+// HandleImport reads NDJSON or CSV, chosen by ?format= or the Content-Type.
+// ?storage_type= applies to records without one and ?concurrency= lowers
+// the number of saves in flight.
+func (h *ImportHandler) HandleImport(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+	query := r.URL.Query()
+	concurrency := h.concurrency
+	if value := query.Get("concurrency"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 1 || n > maxImportConcurrency {
+			http.Error(w, fmt.Sprintf("concurrency must be 1 to %d", maxImportConcurrency), http.StatusBadRequest)
+			return
+		}
+		concurrency = min(n, concurrency)
+	}
+
+	storageType := query.Get("storage_type")
+	var records service.ImportReader
+	switch format := importFormat(r); format {
+	case "ndjson":
+		records = newNDJSONRecords(r.Body, storageType)
+	case "csv":
+		var err error
+		if records, err = newCSVRecords(r.Body, storageType); err != nil {
+			http.Error(w, err.Error(), statusForError(err))
+			return
+		}
+	default:
+		http.Error(w, fmt.Sprintf("%v: import accepts NDJSON or CSV, not %q", ErrUnsupportedMediaType, format), http.StatusUnsupportedMediaType)
+		return
+	}
+
+	ctx := service.WithEndpoint(r.Context(), r.URL.Path)
+	report, err := h.dataService.Import(ctx, records, concurrency)
+	if err != nil {
+		writeJSON(w, statusForError(err), report)
+		return
+	}
+	writeJSON(w, http.StatusOK, report)
+}
+
+// importFormat names the format of an import body
+func importFormat(r *http.Request) string {
+	if format := r.URL.Query().Get("format"); format != "" {
+		return strings.ToLower(format)
+	}
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	switch mediaType {
+	case "", "application/x-ndjson", "application/ndjson", "application/jsonl", "application/json-lines":
+		return "ndjson"
+	case "text/csv", "application/csv":
+		return "csv"
+	}
+	return mediaType
+}
+
+// ndjsonRecords reads one SaveRequest per line, as POST /save-data accepts
+// it in JSON. Blank lines are skipped.
+type ndjsonRecords struct {
+	reader      *bufio.Reader
+	storageType string
+	line        int
+}
+
+func newNDJSONRecords(body io.Reader, storageType string) *ndjsonRecords {
+	return &ndjsonRecords{reader: bufio.NewReader(body), storageType: storageType}
+}
+
+func (n *ndjsonRecords) Next() (service.ImportRecord, error) {
+	for {
+		text, err := n.reader.ReadBytes('\n')
+		if len(text) == 0 && err != nil {
+			return service.ImportRecord{}, importReadError(err)
+		}
+		n.line++
+		text = bytes.TrimSpace(text)
+		if len(text) == 0 {
+			continue
+		}
+		record := service.ImportRecord{Line: n.line, Request: &service.SaveRequest{}}
+		if err := json.Unmarshal(text, record.Request); err != nil {
+			record.Err = fmt.Errorf("%w: invalid JSON: %w", service.ErrValidation, err)
+		} else if record.Request.StorageType == "" {
+			record.Request.StorageType = n.storageType
+		}
+		return record, nil
+	}
+}
+
+// csvRecords reads one SaveRequest per row. The header row names the
+// columns: data holds the payload as text or data_base64 as base64, and
+// storage_type, content_type, ttl, source, sha256 and label.<name> columns
+// are optional. An id column, as GET /export writes, is ignored; saves get
+// new IDs.
+type csvRecords struct {
+	reader      *csv.Reader
+	storageType string
+	columns     []string
+}
+
+func newCSVRecords(body io.Reader, storageType string) (*csvRecords, error) {
+	reader := csv.NewReader(body)
+	reader.FieldsPerRecord = 0
+	reader.ReuseRecord = true
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("%w: failed to read the CSV header: %w", service.ErrValidation, err)
+	}
+	columns := make([]string, len(header))
+	hasData := false
+	for i, column := range header {
+		column = strings.TrimSpace(column)
+		switch {
+		case column == "data", column == "data_base64":
+			if hasData {
+				return nil, fmt.Errorf("%w: CSV has more than one data column", service.ErrValidation)
+			}
+			hasData = true
+		case column == "storage_type", column == "content_type", column == "ttl", column == "source", column == "sha256", column == "id":
+		case strings.HasPrefix(column, "label.") && len(column) > len("label."):
+		default:
+			return nil, fmt.Errorf("%w: unknown CSV column %q", service.ErrValidation, column)
+		}
+		columns[i] = column
+	}
+	if !hasData {
+		return nil, fmt.Errorf("%w: CSV needs a data or data_base64 column", service.ErrValidation)
+	}
+	return &csvRecords{reader: reader, storageType: storageType, columns: columns}, nil
+}
+
+func (c *csvRecords) Next() (service.ImportRecord, error) {
+	fields, err := c.reader.Read()
+	var parseErr *csv.ParseError
+	if errors.As(err, &parseErr) {
+		// The reader carries on with the next row
+		return service.ImportRecord{Line: parseErr.StartLine, Err: fmt.Errorf("%w: %w", service.ErrValidation, parseErr.Err)}, nil
+	}
+	if err != nil {
+		return service.ImportRecord{}, importReadError(err)
+	}
+	line, _ := c.reader.FieldPos(0)
+	record := service.ImportRecord{Line: line, Request: &service.SaveRequest{StorageType: c.storageType}}
+	if record.Err = c.parse(fields, record.Request); record.Err != nil {
+		record.Err = fmt.Errorf("%w: %w", service.ErrValidation, record.Err)
+	}
+	return record, nil
+}
+
+func (c *csvRecords) parse(fields []string, req *service.SaveRequest) error {
+	for i, value := range fields {
+		switch column := c.columns[i]; column {
+		case "data":
+			req.Data = []byte(value)
+		case "data_base64":
+			data, err := base64.StdEncoding.DecodeString(value)
+			if err != nil {
+				return fmt.Errorf("invalid data_base64: %w", err)
+			}
+			req.Data = data
+		case "storage_type":
+			if value != "" {
+				req.StorageType = value
+			}
+		case "content_type":
+			req.ContentType = value
+		case "ttl":
+			if value == "" {
+				continue
+			}
+			ttl, err := time.ParseDuration(value)
+			if err != nil {
+				return fmt.Errorf("invalid ttl: %w", err)
+			}
+			req.TTL = config.Duration(ttl)
+		case "source":
+			req.Source = value
+		case "sha256":
+			if value == "" {
+				continue
+			}
+			sum, err := hex.DecodeString(value)
+			if err != nil || len(sum) != sha256.Size {
+				return fmt.Errorf("invalid sha256: %q", value)
+			}
+			req.ExpectedSHA256 = sum
+		case "id":
+		default:
+			if value == "" {
+				continue
+			}
+			if req.Labels == nil {
+				req.Labels = make(map[string]string)
+			}
+			req.Labels[strings.TrimPrefix(column, "label.")] = value
+		}
+	}
+	return nil
+}
+
+// importReadError passes io.EOF through and marks a body that couldn't be
+// read, such as one over the request size limit, as invalid
+func importReadError(err error) error {
+	if err == io.EOF {
+		return err
+	}
+	return fmt.Errorf("%w: failed to read the import: %w", service.ErrValidation, err)
+}
@@ -0,0 +1,45 @@
+package api
+
+import (
+	"net/http"
+
+	"interview-task/internal/service"
+)
+
+// IndexHandler exposes the metadata index to administrators. Rebuilds and
+// recounts span every tenant, so keys bound to a single tenant may not use
+// them.
+type IndexHandler struct {
+	rebuilder   *service.IndexRebuilder
+	dataService *service.DataService
+}
+
+func NewIndexHandler(rebuilder *service.IndexRebuilder, dataService *service.DataService) *IndexHandler {
+	return &IndexHandler{rebuilder: rebuilder, dataService: dataService}
+}
+
+// HandleRebuild rebuilds the index from storage and returns its stats
+func (h *IndexHandler) HandleRebuild(w http.ResponseWriter, r *http.Request) {
+	if !requireGlobalKey(w, r) {
+		return
+	}
+	stats, err := h.rebuilder.Rebuild(r.Context())
+	if err != nil {
+		http.Error(w, err.Error(), statusForError(err))
+		return
+	}
+	writeJSON(w, http.StatusOK, stats)
+}
+
+// HandleRecount recounts quota usage from the index
+func (h *IndexHandler) HandleRecount(w http.ResponseWriter, r *http.Request) {
+	if !requireGlobalKey(w, r) {
+		return
+	}
+	usage, err := h.dataService.RecountUsage()
+	if err != nil {
+		http.Error(w, err.Error(), statusForError(err))
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"usage": usage})
+}
@@ -0,0 +1,102 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+
+	"interview-task/internal/logging"
+	"interview-task/internal/metrics"
+	"interview-task/internal/service"
+	"interview-task/internal/storage"
+)
+
+// ingestOutcome is what a queue consumer does with a message once the
+// ingester has processed it
+type ingestOutcome int
+
+const (
+	// ingestAck commits the message: it was saved, or spooled for saving
+	ingestAck ingestOutcome = iota
+	// ingestRetry leaves the message for redelivery after a transient
+	// failure
+	ingestRetry
+	// ingestReject drops a message that can never be saved
+	ingestReject
+)
+
+func (o ingestOutcome) String() string {
+	switch o {
+	case ingestAck:
+		return "saved"
+	case ingestRetry:
+		return "retried"
+	default:
+		return "rejected"
+	}
+}
+
+// ingestPrincipal is the actor recorded for saves consumed from queues
+var ingestPrincipal = &service.Principal{Name: "queue-ingest"}
+
+// Ingester - IMPLEMENTS saving messages consumed from queues through the
+// same DataService as HTTP saves, so validation, quotas, auditing and
+// events apply alike. Consumers acknowledge a message only after the save
+// it carries has completed.
+type Ingester struct {
+	service *service.DataService
+	codecs  *CodecRegistry
+	metrics *metrics.Metrics
+}
+
+func NewIngester(service *service.DataService, metrics *metrics.Metrics) *Ingester {
+	metrics.Describe("ingest_messages_total", "Messages consumed from queues by source and outcome")
+	return &Ingester{service: service, codecs: DefaultCodecs(), metrics: metrics}
+}
+
+// IngestEncoded saves a message whose body is a SaveRequest in the codec of
+// contentType, as POST /save-data accepts it
+func (in *Ingester) IngestEncoded(ctx context.Context, source, tenant, contentType string, body []byte) ingestOutcome {
+	var req service.SaveRequest
+	codec := in.codecs.ForContentType(contentType)
+	if !codec.Supports(&req) {
+		return in.finish(source, ErrUnsupportedMediaType)
+	}
+	if err := codec.Decode(bytes.NewReader(body), &req); err != nil {
+		return in.finish(source, fmt.Errorf("%w: %w", service.ErrValidation, err))
+	}
+	return in.Ingest(ctx, source, tenant, &req)
+}
+
+// Ingest saves req for tenant
+func (in *Ingester) Ingest(ctx context.Context, source, tenant string, req *service.SaveRequest) ingestOutcome {
+	if err := service.ValidateTenant(tenant); err != nil {
+		return in.finish(source, fmt.Errorf("%w: %w", service.ErrValidation, err))
+	}
+	requestID, _ := service.NewItemID()
+	ctx = service.WithRequestID(service.WithPrincipal(service.WithTenant(ctx, tenant), ingestPrincipal), requestID)
+	_, err := in.service.SaveData(ctx, req)
+	if errors.Is(err, service.ErrSpooled) {
+		err = nil
+	}
+	return in.finish(source, err)
+}
+
+// finish records a message's outcome. Invalid messages are rejected, as
+// redelivering them can't help; anything else that failed is retried.
+func (in *Ingester) finish(source string, err error) ingestOutcome {
+	outcome := ingestAck
+	switch {
+	case err == nil:
+	case errors.Is(err, service.ErrValidation), errors.Is(err, ErrUnsupportedMediaType), errors.Is(err, storage.ErrConflict):
+		outcome = ingestReject
+	default:
+		outcome = ingestRetry
+	}
+	if err != nil {
+		logging.Printf("Message from %s %s: %v", source, outcome, err)
+	}
+	in.metrics.Add("ingest_messages_total", 1, "source", source, "outcome", outcome.String())
+	return outcome
+}
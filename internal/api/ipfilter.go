@@ -0,0 +1,178 @@
+package api
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+
+	"interview-task/internal/service"
+)
+
+// IPFilter - IMPLEMENTS CIDR-based allow/deny rules as middleware
+type IPFilter struct {
+	allowed []netip.Prefix
+	denied  []netip.Prefix
+	trusted []netip.Prefix
+	// header is where trusted proxies report the client, in canonical form
+	header string
+}
+
+func NewIPFilter(allowed, denied, trustedProxies []string, clientIPHeader string) (*IPFilter, error) {
+	header := http.CanonicalHeaderKey(clientIPHeader)
+	switch header {
+	case "X-Forwarded-For", "Forwarded", "X-Real-Ip":
+	default:
+		return nil, fmt.Errorf("unsupported client IP header %q", clientIPHeader)
+	}
+	allowedPrefixes, err := parsePrefixes(allowed)
+	if err != nil {
+		return nil, fmt.Errorf("invalid allowed CIDR: %w", err)
+	}
+	deniedPrefixes, err := parsePrefixes(denied)
+	if err != nil {
+		return nil, fmt.Errorf("invalid denied CIDR: %w", err)
+	}
+	trustedPrefixes, err := parsePrefixes(trustedProxies)
+	if err != nil {
+		return nil, fmt.Errorf("invalid trusted proxy CIDR: %w", err)
+	}
+
+	return &IPFilter{
+		allowed: allowedPrefixes,
+		denied:  deniedPrefixes,
+		trusted: trustedPrefixes,
+		header:  header,
+	}, nil
+}
+
+// Allows reports whether the address passes the configured rules
+func (f *IPFilter) Allows(addr netip.Addr) bool {
+	if containsAddr(f.denied, addr) {
+		return false
+	}
+	if len(f.allowed) == 0 {
+		return true
+	}
+	return containsAddr(f.allowed, addr)
+}
+
+func (f *IPFilter) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		addr, ok := clientIP(r, f.trusted, f.header)
+		if !ok || !f.Allows(addr) {
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(service.WithClientIP(r.Context(), addr)))
+	})
+}
+
+// clientIP resolves the originating client address. The client IP header is
+// only consulted when the direct peer is a trusted proxy, and is walked from
+// the right so that a client cannot spoof its address by prepending entries.
+// Peers on a Unix socket are on this host, so they count as loopback.
+func clientIP(r *http.Request, trusted []netip.Prefix, header string) (netip.Addr, bool) {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	if local, ok := r.Context().Value(http.LocalAddrContextKey).(net.Addr); ok && local.Network() == "unix" {
+		host = "127.0.0.1"
+	}
+	addr, err := netip.ParseAddr(host)
+	if err != nil {
+		return netip.Addr{}, false
+	}
+	addr = addr.Unmap()
+
+	if !containsAddr(trusted, addr) {
+		return addr, true
+	}
+
+	hops := forwardedHops(r, header)
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop, err := parseHop(hops[i])
+		if err != nil {
+			// A malformed hop means the chain can't be trusted past this point
+			return addr, true
+		}
+		addr = hop.Unmap()
+		if !containsAddr(trusted, addr) {
+			return addr, true
+		}
+	}
+	return addr, true
+}
+
+// forwardedHops returns the addresses a client IP header lists, from the
+// client to the closest proxy. Forwarded elements without a for= parameter
+// come back empty so the walk stops there.
+func forwardedHops(r *http.Request, header string) []string {
+	values := r.Header.Values(header)
+	switch header {
+	case "Forwarded":
+		var hops []string
+		for _, element := range strings.Split(strings.Join(values, ","), ",") {
+			hop := ""
+			for _, pair := range strings.Split(element, ";") {
+				key, value, _ := strings.Cut(strings.TrimSpace(pair), "=")
+				if strings.EqualFold(key, "for") {
+					hop = strings.Trim(value, `"`)
+				}
+			}
+			hops = append(hops, hop)
+		}
+		return hops
+	case "X-Real-Ip":
+		// Only the closest proxy's value can be trusted
+		if len(values) == 0 {
+			return nil
+		}
+		return values[len(values)-1:]
+	default:
+		return strings.Split(strings.Join(values, ","), ",")
+	}
+}
+
+// parseHop parses an address from a client IP header, which may carry a
+// port and, for IPv6, brackets. Obfuscated and "unknown" hops fail.
+func parseHop(hop string) (netip.Addr, error) {
+	hop = strings.TrimSpace(hop)
+	if addrPort, err := netip.ParseAddrPort(hop); err == nil {
+		return addrPort.Addr(), nil
+	}
+	return netip.ParseAddr(strings.TrimSuffix(strings.TrimPrefix(hop, "["), "]"))
+}
+
+func parsePrefixes(cidrs []string) ([]netip.Prefix, error) {
+	prefixes := make([]netip.Prefix, 0, len(cidrs))
+	for _, cidr := range cidrs {
+		cidr = strings.TrimSpace(cidr)
+		// Accept bare addresses as single-host ranges
+		if !strings.Contains(cidr, "/") {
+			addr, err := netip.ParseAddr(cidr)
+			if err != nil {
+				return nil, err
+			}
+			prefixes = append(prefixes, netip.PrefixFrom(addr.Unmap(), addr.Unmap().BitLen()))
+			continue
+		}
+		prefix, err := netip.ParsePrefix(cidr)
+		if err != nil {
+			return nil, err
+		}
+		prefixes = append(prefixes, prefix.Masked())
+	}
+	return prefixes, nil
+}
+
+func containsAddr(prefixes []netip.Prefix, addr netip.Addr) bool {
+	for _, prefix := range prefixes {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
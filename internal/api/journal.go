@@ -0,0 +1,47 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"interview-task/internal/service"
+)
+
+// JournalHandler exposes replays of the request journal to administrators.
+// A replay may write back any tenant's data, so keys bound to a single
+// tenant may not use it.
+type JournalHandler struct {
+	journal *service.Journal
+	service *service.DataService
+}
+
+func NewJournalHandler(journal *service.Journal, service *service.DataService) *JournalHandler {
+	return &JournalHandler{journal: journal, service: service}
+}
+
+// HandleReplay accepts a JournalReplayRequest and replays it before
+// responding
+func (h *JournalHandler) HandleReplay(w http.ResponseWriter, r *http.Request) {
+	if !requireGlobalKey(w, r) {
+		return
+	}
+	if h.journal == nil {
+		http.Error(w, "Journal is disabled", http.StatusNotFound)
+		return
+	}
+	var req service.JournalReplayRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON format", http.StatusBadRequest)
+		return
+	}
+	report, err := h.journal.Replay(r.Context(), h.service, req)
+	if report == nil {
+		http.Error(w, err.Error(), statusForError(err))
+		return
+	}
+	code := http.StatusOK
+	if err != nil {
+		code = http.StatusInternalServerError
+	}
+	writeJSON(w, code, report)
+}
@@ -0,0 +1,59 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"interview-task/internal/service"
+)
+
+// KeyRotationHandler exposes master key rotation to administrators. A
+// rotation re-encrypts every tenant's data, so keys bound to a single
+// tenant may not use it.
+type KeyRotationHandler struct {
+	rotator *service.KeyRotator
+}
+
+func NewKeyRotationHandler(rotator *service.KeyRotator) *KeyRotationHandler {
+	return &KeyRotationHandler{rotator: rotator}
+}
+
+// HandleStart accepts a KeyRotationRequest; an empty body rotates
+// everything
+func (h *KeyRotationHandler) HandleStart(w http.ResponseWriter, r *http.Request) {
+	if !requireGlobalKey(w, r) {
+		return
+	}
+	var req service.KeyRotationRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid JSON format", http.StatusBadRequest)
+			return
+		}
+	}
+	status, err := h.rotator.Start(req)
+	if err != nil {
+		http.Error(w, err.Error(), statusForError(err))
+		return
+	}
+	writeJSON(w, http.StatusAccepted, status)
+}
+
+func (h *KeyRotationHandler) HandleStatus(w http.ResponseWriter, r *http.Request) {
+	if !requireGlobalKey(w, r) {
+		return
+	}
+	writeJSON(w, http.StatusOK, h.rotator.Status())
+}
+
+func (h *KeyRotationHandler) HandleCancel(w http.ResponseWriter, r *http.Request) {
+	if !requireGlobalKey(w, r) {
+		return
+	}
+	status, err := h.rotator.Cancel()
+	if err != nil {
+		http.Error(w, err.Error(), statusForError(err))
+		return
+	}
+	writeJSON(w, http.StatusOK, status)
+}
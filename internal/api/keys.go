@@ -0,0 +1,106 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"interview-task/internal/logging"
+	"interview-task/internal/service"
+)
+
+// KeyHandler manages API keys. Keys may act on any tenant, so keys bound
+// to a single tenant may not manage them.
+type KeyHandler struct {
+	keys *service.KeyManager
+}
+
+func NewKeyHandler(keys *service.KeyManager) *KeyHandler {
+	return &KeyHandler{keys: keys}
+}
+
+// enabled reports key management being off as 404
+func (h *KeyHandler) enabled(w http.ResponseWriter, r *http.Request) bool {
+	if h.keys == nil {
+		http.Error(w, "key management is disabled", http.StatusNotFound)
+		return false
+	}
+	return requireGlobalKey(w, r)
+}
+
+func (h *KeyHandler) HandleList(w http.ResponseWriter, r *http.Request) {
+	if !h.enabled(w, r) {
+		return
+	}
+	keys, err := h.keys.List()
+	if err != nil {
+		http.Error(w, err.Error(), statusForError(err))
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"keys": keys})
+}
+
+func (h *KeyHandler) HandleGet(w http.ResponseWriter, r *http.Request) {
+	if !h.enabled(w, r) {
+		return
+	}
+	key, err := h.keys.Get(r.PathValue("name"))
+	if err != nil {
+		http.Error(w, err.Error(), statusForError(err))
+		return
+	}
+	writeJSON(w, http.StatusOK, key)
+}
+
+// HandleCreate takes {"name": ..., "roles": [...], "tenant": ...,
+// "rate_limit": ..., "burst": ..., "quota": {...}} and returns the new key,
+// whose secret is never shown again
+func (h *KeyHandler) HandleCreate(w http.ResponseWriter, r *http.Request) {
+	if !h.enabled(w, r) {
+		return
+	}
+	var key service.ManagedKey
+	if err := json.NewDecoder(r.Body).Decode(&key); err != nil {
+		http.Error(w, "Invalid JSON format", http.StatusBadRequest)
+		return
+	}
+	created, err := h.keys.Create(key)
+	if err != nil {
+		http.Error(w, err.Error(), statusForError(err))
+		return
+	}
+	logging.Printf("API key %s created by %s", created.Name, service.PrincipalFromContext(r.Context()).Name)
+	writeJSON(w, http.StatusCreated, created)
+}
+
+// HandleUpdate replaces a key's roles, tenant and limits
+func (h *KeyHandler) HandleUpdate(w http.ResponseWriter, r *http.Request) {
+	if !h.enabled(w, r) {
+		return
+	}
+	var key service.ManagedKey
+	if err := json.NewDecoder(r.Body).Decode(&key); err != nil {
+		http.Error(w, "Invalid JSON format", http.StatusBadRequest)
+		return
+	}
+	key.Name = r.PathValue("name")
+	updated, err := h.keys.Update(key)
+	if err != nil {
+		http.Error(w, err.Error(), statusForError(err))
+		return
+	}
+	logging.Printf("API key %s updated by %s", updated.Name, service.PrincipalFromContext(r.Context()).Name)
+	writeJSON(w, http.StatusOK, updated)
+}
+
+func (h *KeyHandler) HandleDelete(w http.ResponseWriter, r *http.Request) {
+	if !h.enabled(w, r) {
+		return
+	}
+	name := r.PathValue("name")
+	if err := h.keys.Delete(name); err != nil {
+		http.Error(w, err.Error(), statusForError(err))
+		return
+	}
+	logging.Printf("API key %s revoked by %s", name, service.PrincipalFromContext(r.Context()).Name)
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,44 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"interview-task/internal/service"
+)
+
+// HandleListData lists items filtered by ?label=key:value (repeatable, all
+// must match), ?source= and ?content_type=
+func (h *HTTPHandler) HandleListData(w http.ResponseWriter, r *http.Request) {
+	params := r.URL.Query()
+	filter, err := itemFilterFromQuery(params)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	items, err := h.dataService.FindItems(r.Context(), params.Get("storage_type"), filter)
+	if err != nil {
+		http.Error(w, err.Error(), statusForError(err))
+		return
+	}
+	h.respond(w, r, http.StatusOK, map[string]any{"items": items})
+}
+
+// itemFilterFromQuery reads the ?label=, ?source= and ?content_type= filters
+func itemFilterFromQuery(params url.Values) (service.ItemFilter, error) {
+	filter := service.ItemFilter{Source: params.Get("source"), ContentType: params.Get("content_type")}
+	for _, label := range params["label"] {
+		key, value, ok := strings.Cut(label, ":")
+		if !ok {
+			return filter, fmt.Errorf("label filter must be key:value, got %q", label)
+		}
+		if filter.Labels == nil {
+			filter.Labels = make(map[string]string)
+		}
+		filter.Labels[key] = value
+	}
+	return filter, nil
+}
@@ -0,0 +1,93 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"interview-task/internal/service"
+)
+
+// LegalHoldHandler lets administrators place and lift legal holds. Holds on
+// whole tenants need a key not bound to a tenant, so a tenant's own admins
+// can't lift them.
+type LegalHoldHandler struct {
+	service *service.DataService
+	holds   *service.TenantHolds
+}
+
+func NewLegalHoldHandler(service *service.DataService, holds *service.TenantHolds) *LegalHoldHandler {
+	return &LegalHoldHandler{service: service, holds: holds}
+}
+
+// legalHoldRequest is the body of the PUT endpoints
+type legalHoldRequest struct {
+	Reason string `json:"reason"`
+}
+
+// HandleList returns the holds on every tenant
+func (h *LegalHoldHandler) HandleList(w http.ResponseWriter, r *http.Request) {
+	if !requireGlobalKey(w, r) {
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"tenants": h.holds.List()})
+}
+
+func (h *LegalHoldHandler) HandleTenantStatus(w http.ResponseWriter, r *http.Request) {
+	if !requireGlobalKey(w, r) {
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{
+		"tenant":     service.TenantFromContext(r.Context()),
+		"legal_hold": h.service.TenantLegalHold(r.Context()),
+	})
+}
+
+func (h *LegalHoldHandler) HandlePlaceTenant(w http.ResponseWriter, r *http.Request) {
+	if !requireGlobalKey(w, r) {
+		return
+	}
+	var req legalHoldRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON format", http.StatusBadRequest)
+		return
+	}
+	hold, err := h.service.PlaceTenantLegalHold(r.Context(), req.Reason)
+	if err != nil {
+		http.Error(w, err.Error(), statusForError(err))
+		return
+	}
+	writeJSON(w, http.StatusOK, hold)
+}
+
+func (h *LegalHoldHandler) HandleLiftTenant(w http.ResponseWriter, r *http.Request) {
+	if !requireGlobalKey(w, r) {
+		return
+	}
+	if err := h.service.LiftTenantLegalHold(r.Context()); err != nil {
+		http.Error(w, err.Error(), statusForError(err))
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *LegalHoldHandler) HandlePlaceItem(w http.ResponseWriter, r *http.Request) {
+	var req legalHoldRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON format", http.StatusBadRequest)
+		return
+	}
+	hold, err := h.service.PlaceLegalHold(r.Context(), r.URL.Query().Get("storage_type"), r.PathValue("id"), req.Reason)
+	if err != nil {
+		http.Error(w, err.Error(), statusForError(err))
+		return
+	}
+	writeJSON(w, http.StatusOK, hold)
+}
+
+func (h *LegalHoldHandler) HandleLiftItem(w http.ResponseWriter, r *http.Request) {
+	if err := h.service.LiftLegalHold(r.Context(), r.URL.Query().Get("storage_type"), r.PathValue("id")); err != nil {
+		http.Error(w, err.Error(), statusForError(err))
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,45 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"interview-task/internal/config"
+	"interview-task/internal/metrics"
+)
+
+// serveAfterShutdown fails unless serve, called after shutdown, returns at
+// once instead of listening on
+func serveAfterShutdown(t *testing.T, shutdown func(context.Context) error, serve func() error) {
+	t.Helper()
+	if err := shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	served := make(chan error, 1)
+	go func() { served <- serve() }()
+	select {
+	case err := <-served:
+		if err != nil {
+			t.Fatalf("Serve after Shutdown returned %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Serve after Shutdown kept listening")
+	}
+}
+
+func TestGRPCServeAfterShutdown(t *testing.T) {
+	g := NewGRPCServer(nil, config.GRPCConfig{Port: "0"}, metrics.NewMetrics())
+	serveAfterShutdown(t, g.Shutdown, func() error { return g.Serve(http.NotFoundHandler()) })
+}
+
+func TestS3ServeAfterShutdown(t *testing.T) {
+	s := NewS3Server(nil, config.S3Config{Port: "0"})
+	serveAfterShutdown(t, s.Shutdown, func() error { return s.Serve(http.NotFoundHandler()) })
+}
+
+func TestDiagnosticsServeAfterShutdown(t *testing.T) {
+	s := NewDiagnosticsServer(config.DiagnosticsConfig{Host: "127.0.0.1", Port: "0"})
+	serveAfterShutdown(t, s.Shutdown, s.Serve)
+}
@@ -0,0 +1,134 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"interview-task/internal/config"
+	"interview-task/internal/metrics"
+)
+
+// loadSheddingExempt are the probes, which must answer while overloaded, and
+// event streams, which would hold a slot for as long as they are open
+var loadSheddingExempt = map[string]bool{"/health": true, "/readyz": true, "/events": true, "/v1/events": true}
+
+// LoadStats is the current load, served on /stats
+type LoadStats struct {
+	InFlight    int   `json:"in_flight"`
+	Queued      int   `json:"queued"`
+	MaxInFlight int   `json:"max_in_flight"`
+	MaxQueue    int   `json:"max_queue"`
+	Rejected    int64 `json:"rejected"`
+	TimedOut    int64 `json:"timed_out"`
+}
+
+// LoadShedder - IMPLEMENTS backpressure. Requests take a slot to run; the
+// queue is bounded so latency can't grow without limit under overload.
+type LoadShedder struct {
+	config config.LoadSheddingConfig
+	slots  chan struct{}
+
+	mu    sync.Mutex
+	stats LoadStats
+}
+
+func NewLoadShedder(config config.LoadSheddingConfig, metrics *metrics.Metrics) *LoadShedder {
+	s := &LoadShedder{
+		config: config,
+		slots:  make(chan struct{}, max(config.MaxInFlight, 0)),
+		stats:  LoadStats{MaxInFlight: config.MaxInFlight, MaxQueue: config.MaxQueue},
+	}
+	metrics.GaugeFunc("in_flight_requests", "Requests being handled", func() int64 {
+		return int64(s.Stats().InFlight)
+	})
+	metrics.GaugeFunc("queued_requests", "Requests waiting for a slot", func() int64 {
+		return int64(s.Stats().Queued)
+	})
+	metrics.CounterFunc("requests_rejected_total", "Requests rejected because the queue was full", func() int64 {
+		return s.Stats().Rejected
+	})
+	metrics.CounterFunc("requests_queue_timeouts_total", "Requests that waited too long for a slot", func() int64 {
+		return s.Stats().TimedOut
+	})
+	return s
+}
+
+func (s *LoadShedder) Stats() LoadStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.stats
+}
+
+func (s *LoadShedder) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if s.config.MaxInFlight <= 0 || loadSheddingExempt[r.URL.Path] {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if status := s.acquire(r.Context()); status != http.StatusOK {
+			w.Header().Set("Retry-After", strconv.Itoa(int(max(time.Duration(s.config.RetryAfter), time.Second)/time.Second)))
+			http.Error(w, "Server is overloaded, retry later", status)
+			return
+		}
+		defer s.release()
+		next.ServeHTTP(w, r)
+	})
+}
+
+// acquire takes a slot, queueing for one if needed. It returns the status
+// to reject the request with, or 200 once it holds a slot.
+func (s *LoadShedder) acquire(ctx context.Context) int {
+	select {
+	case s.slots <- struct{}{}:
+		s.mu.Lock()
+		s.stats.InFlight++
+		s.mu.Unlock()
+		return http.StatusOK
+	default:
+	}
+
+	s.mu.Lock()
+	if s.stats.Queued >= s.config.MaxQueue {
+		s.stats.Rejected++
+		s.mu.Unlock()
+		return http.StatusTooManyRequests
+	}
+	s.stats.Queued++
+	s.mu.Unlock()
+
+	var timeout <-chan time.Time
+	if s.config.QueueTimeout > 0 {
+		timer := time.NewTimer(time.Duration(s.config.QueueTimeout))
+		defer timer.Stop()
+		timeout = timer.C
+	}
+	status := http.StatusOK
+	select {
+	case s.slots <- struct{}{}:
+	case <-timeout:
+		status = http.StatusServiceUnavailable
+	case <-ctx.Done():
+		// The client gave up; nobody reads the response
+		status = http.StatusServiceUnavailable
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.stats.Queued--
+	if status == http.StatusOK {
+		s.stats.InFlight++
+	} else {
+		s.stats.TimedOut++
+	}
+	return status
+}
+
+func (s *LoadShedder) release() {
+	s.mu.Lock()
+	s.stats.InFlight--
+	s.mu.Unlock()
+	<-s.slots
+}
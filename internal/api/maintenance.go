@@ -0,0 +1,44 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"interview-task/internal/logging"
+	"interview-task/internal/service"
+)
+
+// MaintenanceHandler reports and toggles maintenance mode. It applies to
+// every tenant, so keys bound to a single tenant may not use it.
+type MaintenanceHandler struct {
+	maintenance *service.Maintenance
+}
+
+func NewMaintenanceHandler(maintenance *service.Maintenance) *MaintenanceHandler {
+	return &MaintenanceHandler{maintenance: maintenance}
+}
+
+func (h *MaintenanceHandler) HandleStatus(w http.ResponseWriter, r *http.Request) {
+	if !requireGlobalKey(w, r) {
+		return
+	}
+	writeJSON(w, http.StatusOK, h.maintenance.Status())
+}
+
+// HandleSet takes {"enabled": true, "message": "..."}
+func (h *MaintenanceHandler) HandleSet(w http.ResponseWriter, r *http.Request) {
+	if !requireGlobalKey(w, r) {
+		return
+	}
+	var req struct {
+		Enabled *bool  `json:"enabled"`
+		Message string `json:"message"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Enabled == nil {
+		http.Error(w, "Invalid JSON format: enabled is required", http.StatusBadRequest)
+		return
+	}
+	status := h.maintenance.Set(*req.Enabled, req.Message)
+	logging.Printf("Maintenance mode set to %t by %s", status.Enabled, service.PrincipalFromContext(r.Context()).Name)
+	writeJSON(w, http.StatusOK, status)
+}
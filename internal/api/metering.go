@@ -0,0 +1,138 @@
+package api
+
+import (
+	"encoding/csv"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"interview-task/internal/service"
+)
+
+// MeterUsage adds every request, with the bytes of its body and response,
+// to the caller's usage. It runs after authentication and tenant
+// resolution, so probes that bypass them are metered to the anonymous
+// principal.
+func MeterUsage(meter *service.Meter) Middleware {
+	return func(next http.Handler) http.Handler {
+		if meter == nil {
+			return next
+		}
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			body := &countingReader{ReadCloser: r.Body}
+			r.Body = body
+			counter := &countingWriter{ResponseWriter: w}
+			next.ServeHTTP(counter, r)
+			ctx := r.Context()
+			meter.Record(service.TenantFromContext(ctx), service.PrincipalFromContext(ctx).Name, body.n, counter.n)
+		})
+	}
+}
+
+// countingReader counts the bytes read from a request body
+type countingReader struct {
+	io.ReadCloser
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.ReadCloser.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// countingWriter counts the bytes written to a response
+type countingWriter struct {
+	http.ResponseWriter
+	n int64
+}
+
+// Unwrap gives http.ResponseController access to the connection's writer
+func (c *countingWriter) Unwrap() http.ResponseWriter {
+	return c.ResponseWriter
+}
+
+func (c *countingWriter) Write(data []byte) (int, error) {
+	n, err := c.ResponseWriter.Write(data)
+	c.n += int64(n)
+	return n, err
+}
+
+// UsageHandler serves metered usage. Administrators see every key's;
+// other keys only their own.
+type UsageHandler struct {
+	meter *service.Meter
+}
+
+func NewUsageHandler(meter *service.Meter) *UsageHandler {
+	return &UsageHandler{meter: meter}
+}
+
+// HandleUsage returns daily aggregates filtered by ?from= and ?to= (dates,
+// inclusive), ?tenant= and ?key=, as JSON or, with ?format=csv, as a CSV
+// attachment for chargeback
+func (h *UsageHandler) HandleUsage(w http.ResponseWriter, r *http.Request) {
+	if h.meter == nil {
+		http.Error(w, "metering is disabled", http.StatusNotFound)
+		return
+	}
+	principal := service.PrincipalFromContext(r.Context())
+	if !principal.Authenticated() {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	params := r.URL.Query()
+	query := service.MeterQuery{
+		From:   params.Get("from"),
+		To:     params.Get("to"),
+		Tenant: params.Get("tenant"),
+		Key:    params.Get("key"),
+	}
+	for _, date := range []string{query.From, query.To} {
+		if _, err := time.Parse(time.DateOnly, date); date != "" && err != nil {
+			http.Error(w, "from and to must be dates such as 2026-01-31", http.StatusBadRequest)
+			return
+		}
+	}
+	if !principal.HasRole(service.RoleAdmin) {
+		if query.Key != "" && query.Key != principal.Name {
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		}
+		query.Key = principal.Name
+	}
+
+	records := h.meter.Query(query)
+	switch params.Get("format") {
+	case "", "json":
+		if records == nil {
+			records = []service.MeterRecord{}
+		}
+		writeJSON(w, http.StatusOK, map[string]any{"usage": records})
+	case "csv":
+		writeUsageCSV(w, records)
+	default:
+		http.Error(w, "format must be json or csv", http.StatusBadRequest)
+	}
+}
+
+func writeUsageCSV(w http.ResponseWriter, records []service.MeterRecord) {
+	w.Header().Set("Content-Type", "text/csv")
+	w.Header().Set("Content-Disposition", `attachment; filename="usage.csv"`)
+	out := csv.NewWriter(w)
+	out.Write([]string{"date", "tenant", "key", "requests", "bytes_in", "bytes_out", "bytes_stored"})
+	for _, record := range records {
+		out.Write([]string{
+			record.Date,
+			record.Tenant,
+			record.Key,
+			strconv.FormatInt(record.Requests, 10),
+			strconv.FormatInt(record.BytesIn, 10),
+			strconv.FormatInt(record.BytesOut, 10),
+			strconv.FormatInt(record.BytesStored, 10),
+		})
+	}
+	out.Flush()
+}
@@ -0,0 +1,16 @@
+package api
+
+import (
+	"net/http"
+)
+
+// Middleware wraps an http.Handler with cross-cutting behavior
+type Middleware func(http.Handler) http.Handler
+
+// ChainMiddleware applies middleware so that the first one listed runs first
+func ChainMiddleware(handler http.Handler, middleware ...Middleware) http.Handler {
+	for i := len(middleware) - 1; i >= 0; i-- {
+		handler = middleware[i](handler)
+	}
+	return handler
+}
@@ -0,0 +1,56 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"interview-task/internal/service"
+)
+
+// MigrationHandler exposes migrations between storage types to
+// administrators. A migration copies every tenant's data, so keys bound to
+// a single tenant may not use it.
+type MigrationHandler struct {
+	migrator *service.Migrator
+}
+
+func NewMigrationHandler(migrator *service.Migrator) *MigrationHandler {
+	return &MigrationHandler{migrator: migrator}
+}
+
+// HandleStart accepts a MigrationRequest
+func (h *MigrationHandler) HandleStart(w http.ResponseWriter, r *http.Request) {
+	if !requireGlobalKey(w, r) {
+		return
+	}
+	var req service.MigrationRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON format", http.StatusBadRequest)
+		return
+	}
+	status, err := h.migrator.Start(req)
+	if err != nil {
+		http.Error(w, err.Error(), statusForError(err))
+		return
+	}
+	writeJSON(w, http.StatusAccepted, status)
+}
+
+func (h *MigrationHandler) HandleStatus(w http.ResponseWriter, r *http.Request) {
+	if !requireGlobalKey(w, r) {
+		return
+	}
+	writeJSON(w, http.StatusOK, h.migrator.Status())
+}
+
+func (h *MigrationHandler) HandleCancel(w http.ResponseWriter, r *http.Request) {
+	if !requireGlobalKey(w, r) {
+		return
+	}
+	status, err := h.migrator.Cancel()
+	if err != nil {
+		http.Error(w, err.Error(), statusForError(err))
+		return
+	}
+	writeJSON(w, http.StatusOK, status)
+}
@@ -0,0 +1,381 @@
+package api
+
+import (
+	"bufio"
+	"cmp"
+	"context"
+	"crypto/tls"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"interview-task/internal/config"
+	"interview-task/internal/logging"
+	"interview-task/internal/service"
+)
+
+// matchTopic reports whether topic matches filter. Wildcards don't match
+// topics beginning with $, which are reserved for brokers.
+func matchTopic(filter, topic string) bool {
+	if strings.HasPrefix(topic, "$") && !strings.HasPrefix(filter, "$") {
+		return false
+	}
+	filterLevels, topicLevels := strings.Split(filter, "/"), strings.Split(topic, "/")
+	for i, level := range filterLevels {
+		switch {
+		case level == "#":
+			return true
+		case i >= len(topicLevels):
+			return false
+		case level != "+" && level != topicLevels[i]:
+			return false
+		}
+	}
+	return len(filterLevels) == len(topicLevels)
+}
+
+// MQTT 3.1.1 control packet types
+const (
+	mqttConnect     = 1
+	mqttConnAck     = 2
+	mqttPublish     = 3
+	mqttPubAck      = 4
+	mqttSubscribe   = 8
+	mqttSubAck      = 9
+	mqttPingReq     = 12
+	mqttPingResp    = 13
+	mqttDisconnect  = 14
+	mqttMaxPacket   = 16 << 20
+	mqttMaxBackoff  = time.Minute
+	mqttSubscribeID = 1
+)
+
+// errRedeliver ends a session so the broker redelivers an unacknowledged
+// message
+var errRedeliver = errors.New("message left for redelivery")
+
+// MQTTBridge - IMPLEMENTS an MQTT 3.1.1 subscriber that saves the messages
+// of the configured topics through the Ingester, speaking the protocol
+// directly. Message payloads are saved as they are, with the storage type,
+// labels and TTL of the matching route and the topic as their source.
+type MQTTBridge struct {
+	config   config.MQTTConfig
+	ingester *Ingester
+	cancel   context.CancelFunc
+	done     chan struct{}
+}
+
+func NewMQTTBridge(config config.MQTTConfig, ingester *Ingester) *MQTTBridge {
+	return &MQTTBridge{config: config, ingester: ingester}
+}
+
+func (b *MQTTBridge) Start() {
+	if b.config.Broker == "" {
+		return
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	b.cancel, b.done = cancel, make(chan struct{})
+	go b.run(ctx, b.done)
+}
+
+// run keeps a session open until shutdown, reconnecting with backoff
+func (b *MQTTBridge) run(ctx context.Context, done chan struct{}) {
+	defer close(done)
+	backoff := time.Second
+	for {
+		connected, err := b.session(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		delay := backoff
+		switch {
+		case errors.Is(err, errRedeliver):
+			delay, backoff = time.Duration(b.config.RetryDelay), time.Second
+		case connected:
+			delay, backoff = time.Second, time.Second
+		}
+		logging.Printf("MQTT bridge disconnected, reconnecting in %s: %v", delay, err)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(delay):
+		}
+		backoff = min(2*backoff, mqttMaxBackoff)
+	}
+}
+
+// session connects, subscribes and saves messages until the connection
+// fails or a message must be redelivered
+func (b *MQTTBridge) session(ctx context.Context) (connected bool, err error) {
+	conn, err := dialMQTT(ctx, &b.config)
+	if err != nil {
+		return false, err
+	}
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+	defer conn.Close()
+
+	var subscribe []byte
+	subscribe = binary.BigEndian.AppendUint16(subscribe, mqttSubscribeID)
+	for _, route := range b.config.Routes {
+		subscribe = appendMQTTString(subscribe, route.Topic)
+		subscribe = append(subscribe, byte(b.config.QoS))
+	}
+	if err := conn.write(mqttSubscribe<<4|0x02, subscribe); err != nil {
+		return true, err
+	}
+
+	keepAlive := time.Duration(b.config.KeepAlive)
+	var pinger *time.Timer
+	pinger = time.AfterFunc(keepAlive/2, func() {
+		if conn.write(mqttPingReq<<4, nil) == nil {
+			pinger.Reset(keepAlive / 2)
+		}
+	})
+	defer pinger.Stop()
+
+	for {
+		// The broker answers pings, so silence beyond the keep-alive
+		// means the connection is gone
+		conn.SetReadDeadline(time.Now().Add(keepAlive * 3 / 2))
+		header, body, err := conn.read()
+		if err != nil {
+			return true, err
+		}
+		switch header >> 4 {
+		case mqttSubAck:
+			if len(body) < 3 {
+				return true, fmt.Errorf("malformed SUBACK")
+			}
+			for i, code := range body[2:] {
+				if code == 0x80 && i < len(b.config.Routes) {
+					logging.Printf("MQTT broker refused subscription to %s", b.config.Routes[i].Topic)
+				}
+			}
+		case mqttPublish:
+			if err := b.receive(ctx, conn, header, body); err != nil {
+				return true, err
+			}
+		}
+	}
+}
+
+// receive saves a PUBLISH and acknowledges it once saved
+func (b *MQTTBridge) receive(ctx context.Context, conn *mqttConn, header byte, body []byte) error {
+	qos := int(header>>1) & 0x03
+	topic, rest, err := readMQTTString(body)
+	if err != nil {
+		return err
+	}
+	var packetID uint16
+	if qos > 0 {
+		if len(rest) < 2 {
+			return fmt.Errorf("malformed PUBLISH")
+		}
+		packetID, rest = binary.BigEndian.Uint16(rest), rest[2:]
+	}
+
+	outcome := ingestReject
+	if route := b.route(topic); route != nil {
+		req := &service.SaveRequest{
+			Data:        rest,
+			StorageType: route.StorageType,
+			ContentType: route.ContentType,
+			TTL:         route.TTL,
+			Labels:      routeLabels(route, topic),
+			Source:      topic,
+		}
+		tenant := cmp.Or(b.config.Tenant, service.DefaultTenant)
+		outcome = b.ingester.Ingest(ctx, "mqtt", tenant, req)
+	}
+	if qos == 0 {
+		return nil
+	}
+	if outcome == ingestRetry {
+		return errRedeliver
+	}
+	return conn.write(mqttPubAck<<4, binary.BigEndian.AppendUint16(nil, packetID))
+}
+
+func (b *MQTTBridge) route(topic string) *config.MQTTRoute {
+	for i := range b.config.Routes {
+		if matchTopic(b.config.Routes[i].Topic, topic) {
+			return &b.config.Routes[i]
+		}
+	}
+	return nil
+}
+
+// routeLabels returns the route's labels with {n} placeholders filled in from
+// the topic's levels
+func routeLabels(r *config.MQTTRoute, topic string) map[string]string {
+	if len(r.Labels) == 0 {
+		return nil
+	}
+	levels := strings.Split(topic, "/")
+	labels := make(map[string]string, len(r.Labels))
+	for key, value := range r.Labels {
+		if inner, ok := strings.CutPrefix(value, "{"); ok && strings.HasSuffix(inner, "}") {
+			if n, err := strconv.Atoi(strings.TrimSuffix(inner, "}")); err == nil && n >= 0 && n < len(levels) {
+				value = levels[n]
+			}
+		}
+		labels[key] = value
+	}
+	return labels
+}
+
+func (b *MQTTBridge) Shutdown() {
+	if b.cancel != nil {
+		b.cancel()
+		<-b.done
+	}
+}
+
+// mqttConn is an MQTT client connection. Writes are serialized since pings
+// are sent from a timer.
+type mqttConn struct {
+	net.Conn
+	r  *bufio.Reader
+	mu sync.Mutex
+}
+
+// dialMQTT connects and waits for the broker to accept the session
+func dialMQTT(ctx context.Context, config *config.MQTTConfig) (*mqttConn, error) {
+	u, err := url.Parse(config.Broker)
+	if err != nil {
+		return nil, err
+	}
+	dialer := &net.Dialer{Timeout: 10 * time.Second}
+	var conn net.Conn
+	if u.Scheme == "ssl" {
+		conn, err = (&tls.Dialer{NetDialer: dialer}).DialContext(ctx, "tcp", u.Host)
+	} else {
+		conn, err = dialer.DialContext(ctx, "tcp", u.Host)
+	}
+	if err != nil {
+		return nil, err
+	}
+	c := &mqttConn{Conn: conn, r: bufio.NewReader(conn)}
+
+	// A persistent session lets the broker redeliver unacknowledged QoS 1
+	// messages after a reconnect
+	flags := byte(0x02)
+	if config.QoS > 0 {
+		flags = 0
+	}
+	var connect []byte
+	connect = appendMQTTString(connect, "MQTT")
+	connect = append(connect, 4)
+	if config.Username != "" {
+		flags |= 0x80
+	}
+	if config.Password != "" {
+		flags |= 0x40
+	}
+	connect = append(connect, flags)
+	connect = binary.BigEndian.AppendUint16(connect, uint16(time.Duration(config.KeepAlive)/time.Second))
+	connect = appendMQTTString(connect, config.ClientID)
+	if config.Username != "" {
+		connect = appendMQTTString(connect, config.Username)
+	}
+	if config.Password != "" {
+		connect = appendMQTTString(connect, config.Password)
+	}
+
+	conn.SetDeadline(time.Now().Add(10 * time.Second))
+	if err := c.write(mqttConnect<<4, connect); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	header, body, err := c.read()
+	if err == nil && (header>>4 != mqttConnAck || len(body) != 2) {
+		err = fmt.Errorf("unexpected MQTT packet type %d", header>>4)
+	}
+	if err == nil && body[1] != 0 {
+		err = fmt.Errorf("MQTT broker refused connection with return code %d", body[1])
+	}
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	conn.SetDeadline(time.Time{})
+	return c, nil
+}
+
+// write sends a packet with its remaining length
+func (c *mqttConn) write(header byte, body []byte) error {
+	packet := []byte{header}
+	for n := len(body); ; {
+		digit := byte(n % 128)
+		if n /= 128; n > 0 {
+			digit |= 0x80
+		}
+		packet = append(packet, digit)
+		if n == 0 {
+			break
+		}
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	_, err := c.Conn.Write(append(packet, body...))
+	return err
+}
+
+// read receives a packet
+func (c *mqttConn) read() (header byte, body []byte, err error) {
+	if header, err = c.r.ReadByte(); err != nil {
+		return 0, nil, err
+	}
+	length, multiplier := 0, 1
+	for i := 0; ; i++ {
+		digit, err := c.r.ReadByte()
+		if err != nil {
+			return 0, nil, err
+		}
+		length += int(digit&0x7f) * multiplier
+		if digit&0x80 == 0 {
+			break
+		}
+		if multiplier *= 128; i == 3 {
+			return 0, nil, fmt.Errorf("malformed MQTT remaining length")
+		}
+	}
+	if length > mqttMaxPacket {
+		return 0, nil, fmt.Errorf("MQTT packet of %d bytes exceeds %d", length, mqttMaxPacket)
+	}
+	body = make([]byte, length)
+	if _, err := io.ReadFull(c.r, body); err != nil {
+		return 0, nil, err
+	}
+	return header, body, nil
+}
+
+func (c *mqttConn) Close() error {
+	// A clean disconnect keeps the broker from publishing the will
+	c.write(mqttDisconnect<<4, nil)
+	return c.Conn.Close()
+}
+
+func appendMQTTString(buf []byte, s string) []byte {
+	buf = binary.BigEndian.AppendUint16(buf, uint16(len(s)))
+	return append(buf, s...)
+}
+
+func readMQTTString(data []byte) (string, []byte, error) {
+	if len(data) < 2 {
+		return "", nil, fmt.Errorf("malformed MQTT string")
+	}
+	n := int(binary.BigEndian.Uint16(data))
+	if len(data) < 2+n {
+		return "", nil, fmt.Errorf("malformed MQTT string")
+	}
+	return string(data[2 : 2+n]), data[2+n:], nil
+}
@@ -0,0 +1,310 @@
+package api
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+)
+
+var errMsgpackTruncated = errors.New("truncated msgpack document")
+
+// MsgpackCodec - IMPLEMENTS MessagePack bodies. Values are encoded as their
+// JSON encoding describes them. Clients send payloads as bin, though []byte
+// fields also take base64 strings; responses carry them as base64 strings,
+// as in JSON.
+type MsgpackCodec struct{}
+
+func (MsgpackCodec) Name() string        { return "msgpack" }
+func (MsgpackCodec) MediaType() string   { return "application/msgpack" }
+func (MsgpackCodec) Supports(v any) bool { return true }
+
+func (MsgpackCodec) Encode(w io.Writer, v any) error {
+	tree, err := toGeneric(v)
+	if err != nil {
+		return err
+	}
+	encoded, err := appendMsgpack(nil, tree)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(encoded)
+	return err
+}
+
+func (MsgpackCodec) Decode(r io.Reader, v any) error {
+	return decodeBuffered(r, func(data []byte) error {
+		d := &msgpackDecoder{data: data}
+		tree, err := d.value(0)
+		if err != nil {
+			return err
+		}
+		if len(d.data) > 0 {
+			return fmt.Errorf("unexpected data after msgpack document")
+		}
+		return fromGeneric(tree, v)
+	})
+}
+
+func appendMsgpack(buf []byte, value any) ([]byte, error) {
+	switch v := value.(type) {
+	case nil:
+		return append(buf, 0xc0), nil
+	case bool:
+		if v {
+			return append(buf, 0xc3), nil
+		}
+		return append(buf, 0xc2), nil
+	case json.Number:
+		n, err := genericNumber(v)
+		if err != nil {
+			return nil, err
+		}
+		switch n := n.(type) {
+		case int64:
+			return appendMsgpackInt(buf, n), nil
+		case uint64:
+			return binary.BigEndian.AppendUint64(append(buf, 0xcf), n), nil
+		default:
+			return binary.BigEndian.AppendUint64(append(buf, 0xcb), math.Float64bits(n.(float64))), nil
+		}
+	case string:
+		buf = appendMsgpackHeader(buf, len(v), 0xa0, 32, 0xd9)
+		return append(buf, v...), nil
+	case []any:
+		buf = appendMsgpackHeader(buf, len(v), 0x90, 16, 0)
+		var err error
+		for _, item := range v {
+			if buf, err = appendMsgpack(buf, item); err != nil {
+				return nil, err
+			}
+		}
+		return buf, nil
+	case map[string]any:
+		buf = appendMsgpackHeader(buf, len(v), 0x80, 16, 0)
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		var err error
+		for _, key := range keys {
+			if buf, err = appendMsgpack(buf, key); err != nil {
+				return nil, err
+			}
+			if buf, err = appendMsgpack(buf, v[key]); err != nil {
+				return nil, err
+			}
+		}
+		return buf, nil
+	default:
+		return nil, fmt.Errorf("cannot encode %T as msgpack", value)
+	}
+}
+
+func appendMsgpackInt(buf []byte, n int64) []byte {
+	switch {
+	case n >= 0 && n < 128, n < 0 && n >= -32:
+		return append(buf, byte(n))
+	case n >= 0 && n <= math.MaxUint8:
+		return append(buf, 0xcc, byte(n))
+	case n >= 0 && n <= math.MaxUint16:
+		return binary.BigEndian.AppendUint16(append(buf, 0xcd), uint16(n))
+	case n >= 0 && n <= math.MaxUint32:
+		return binary.BigEndian.AppendUint32(append(buf, 0xce), uint32(n))
+	case n >= 0:
+		return binary.BigEndian.AppendUint64(append(buf, 0xcf), uint64(n))
+	case n >= math.MinInt8:
+		return append(buf, 0xd0, byte(n))
+	case n >= math.MinInt16:
+		return binary.BigEndian.AppendUint16(append(buf, 0xd1), uint16(n))
+	case n >= math.MinInt32:
+		return binary.BigEndian.AppendUint32(append(buf, 0xd2), uint32(n))
+	default:
+		return binary.BigEndian.AppendUint64(append(buf, 0xd3), uint64(n))
+	}
+}
+
+// appendMsgpackHeader appends the header of a string, array or map. Short
+// ones fit their length into the fix type; sized types follow in the order
+// 8, 16, 32 bit from sized8, or 16, 32 bit when sized8 is 0.
+func appendMsgpackHeader(buf []byte, n int, fix byte, fixLimit int, sized8 byte) []byte {
+	switch {
+	case n < fixLimit:
+		return append(buf, fix|byte(n))
+	case sized8 != 0 && n <= math.MaxUint8:
+		return append(buf, sized8, byte(n))
+	}
+	// str16 follows str8; array16 and map16 have their own codes
+	sized16 := sized8 + 1
+	switch fix {
+	case 0x90:
+		sized16 = 0xdc
+	case 0x80:
+		sized16 = 0xde
+	}
+	if n <= math.MaxUint16 {
+		return binary.BigEndian.AppendUint16(append(buf, sized16), uint16(n))
+	}
+	return binary.BigEndian.AppendUint32(append(buf, sized16+1), uint32(n))
+}
+
+// msgpackDecoder decodes a msgpack document into a generic tree
+type msgpackDecoder struct {
+	data []byte
+}
+
+func (d *msgpackDecoder) take(n int) ([]byte, error) {
+	if n < 0 || n > len(d.data) {
+		return nil, errMsgpackTruncated
+	}
+	taken := d.data[:n]
+	d.data = d.data[n:]
+	return taken, nil
+}
+
+// uint reads a big-endian unsigned integer of size bytes
+func (d *msgpackDecoder) uint(size int) (uint64, error) {
+	b, err := d.take(size)
+	if err != nil {
+		return 0, err
+	}
+	var n uint64
+	for _, c := range b {
+		n = n<<8 | uint64(c)
+	}
+	return n, nil
+}
+
+func (d *msgpackDecoder) value(depth int) (any, error) {
+	if depth > maxCodecDepth {
+		return nil, fmt.Errorf("msgpack document is nested too deeply")
+	}
+	b, err := d.take(1)
+	if err != nil {
+		return nil, err
+	}
+	c := b[0]
+	switch {
+	case c <= 0x7f:
+		return int64(c), nil
+	case c >= 0xe0:
+		return int64(int8(c)), nil
+	case c&0xe0 == 0xa0:
+		return d.str(int(c & 0x1f))
+	case c&0xf0 == 0x90:
+		return d.array(int(c&0x0f), depth)
+	case c&0xf0 == 0x80:
+		return d.mapping(int(c&0x0f), depth)
+	}
+
+	if size, ok := msgpackSized[c]; ok {
+		return d.sized(c, size, depth)
+	}
+	switch c {
+	case 0xc0:
+		return nil, nil
+	case 0xc2:
+		return false, nil
+	case 0xc3:
+		return true, nil
+	case 0xcc, 0xcd, 0xce, 0xcf:
+		return d.uint(1 << (c - 0xcc))
+	case 0xd0, 0xd1, 0xd2, 0xd3:
+		size := 1 << (c - 0xd0)
+		n, err := d.uint(size)
+		if err != nil {
+			return nil, err
+		}
+		// Sign-extend from the encoded width
+		shift := 64 - 8*size
+		return int64(n<<shift) >> shift, nil
+	case 0xca:
+		n, err := d.uint(4)
+		return float64(math.Float32frombits(uint32(n))), err
+	case 0xcb:
+		n, err := d.uint(8)
+		return math.Float64frombits(n), err
+	default:
+		return nil, fmt.Errorf("unsupported msgpack type 0x%02x", c)
+	}
+}
+
+// msgpackSized maps the str, bin, array and map types to the byte size of
+// their length
+var msgpackSized = map[byte]int{
+	0xd9: 1, 0xda: 2, 0xdb: 4,
+	0xc4: 1, 0xc5: 2, 0xc6: 4,
+	0xdc: 2, 0xdd: 4,
+	0xde: 2, 0xdf: 4,
+}
+
+// sized reads a value whose length precedes it in size bytes
+func (d *msgpackDecoder) sized(c byte, size, depth int) (any, error) {
+	n, err := d.uint(size)
+	if err != nil {
+		return nil, err
+	}
+	if n > uint64(len(d.data)) {
+		// Every element takes at least a byte
+		return nil, errMsgpackTruncated
+	}
+	switch c {
+	case 0xd9, 0xda, 0xdb:
+		return d.str(int(n))
+	case 0xc4, 0xc5, 0xc6:
+		bin, err := d.take(int(n))
+		return append([]byte(nil), bin...), err
+	case 0xdc, 0xdd:
+		return d.array(int(n), depth)
+	default:
+		return d.mapping(int(n), depth)
+	}
+}
+
+func (d *msgpackDecoder) str(n int) (any, error) {
+	b, err := d.take(n)
+	if err != nil {
+		return nil, err
+	}
+	return string(b), nil
+}
+
+func (d *msgpackDecoder) array(n, depth int) (any, error) {
+	if n > len(d.data) {
+		return nil, errMsgpackTruncated
+	}
+	items := make([]any, n)
+	for i := range items {
+		item, err := d.value(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		items[i] = item
+	}
+	return items, nil
+}
+
+func (d *msgpackDecoder) mapping(n, depth int) (any, error) {
+	if n > len(d.data) {
+		return nil, errMsgpackTruncated
+	}
+	fields := make(map[string]any, n)
+	for range n {
+		key, err := d.value(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		name, ok := key.(string)
+		if !ok {
+			return nil, fmt.Errorf("msgpack map keys must be strings")
+		}
+		if fields[name], err = d.value(depth + 1); err != nil {
+			return nil, err
+		}
+	}
+	return fields, nil
+}
@@ -0,0 +1,353 @@
+package api
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/textproto"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"interview-task/internal/config"
+	"interview-task/internal/logging"
+	"interview-task/internal/service"
+)
+
+const (
+	// natsPullExpiry is how long a pull request waits for messages
+	natsPullExpiry = 30 * time.Second
+	natsMaxBackoff = 30 * time.Second
+	// natsMaxLine bounds protocol lines, of which INFO is the longest
+	natsMaxLine = 32 << 10
+)
+
+// NATSConsumer - IMPLEMENTS queue ingestion from a JetStream pull consumer,
+// speaking the NATS client protocol directly. Message bodies are
+// SaveRequests in any codec the save API accepts, chosen by their
+// Content-Type header. Each message is acknowledged after its save
+// completes, negatively acknowledged for redelivery after a transient
+// failure, and terminated when it is invalid, so JetStream's delivery
+// policy applies to failures.
+type NATSConsumer struct {
+	config       config.QueueIngestConfig
+	tenantHeader string
+	ingester     *Ingester
+	cancel       context.CancelFunc
+	done         chan struct{}
+}
+
+func NewNATSConsumer(config config.QueueIngestConfig, tenantHeader string, ingester *Ingester) *NATSConsumer {
+	return &NATSConsumer{config: config, tenantHeader: tenantHeader, ingester: ingester}
+}
+
+func (q *NATSConsumer) Start() {
+	if q.config.URL == "" {
+		return
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	q.cancel, q.done = cancel, make(chan struct{})
+	go q.run(ctx, q.done)
+}
+
+// run consumes until shutdown, reconnecting with backoff
+func (q *NATSConsumer) run(ctx context.Context, done chan struct{}) {
+	defer close(done)
+	backoff := time.Second
+	for {
+		connected, err := q.consume(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		if connected {
+			backoff = time.Second
+		}
+		logging.Printf("NATS consumer disconnected, reconnecting in %s: %v", backoff, err)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+		backoff = min(2*backoff, natsMaxBackoff)
+	}
+}
+
+// consume pulls and processes messages over one connection until it fails
+func (q *NATSConsumer) consume(ctx context.Context) (connected bool, err error) {
+	conn, err := dialNATS(ctx, q.config.URL, q.config.Token)
+	if err != nil {
+		return false, err
+	}
+	// Closing the connection unblocks the read loop on shutdown
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+	defer conn.Close()
+
+	inboxID, _ := service.NewItemID()
+	inbox := "_INBOX." + inboxID
+	if err := conn.subscribe(inbox+".*", "1"); err != nil {
+		return true, err
+	}
+	logging.Printf("Consuming from NATS stream %s, consumer %s", q.config.Stream, q.config.Consumer)
+
+	pulls, pending := 0, 0
+	pull := func() error {
+		pulls++
+		pending = q.config.Batch
+		request, _ := json.Marshal(map[string]any{"batch": q.config.Batch, "expires": natsPullExpiry})
+		return conn.publish("$JS.API.CONSUMER.MSG.NEXT."+q.config.Stream+"."+q.config.Consumer, inbox+"."+strconv.Itoa(pulls), request)
+	}
+	if err := pull(); err != nil {
+		return true, err
+	}
+	for {
+		msg, err := conn.next()
+		if err != nil {
+			return true, err
+		}
+		if msg.status != 0 {
+			// The current pull ended without filling the batch: it
+			// expired, or was cut short. Statuses of earlier pulls are
+			// stale.
+			if msg.subject != inbox+"."+strconv.Itoa(pulls) {
+				continue
+			}
+			if msg.status != http.StatusNotFound && msg.status != http.StatusRequestTimeout {
+				logging.Printf("NATS pull ended with status %d %s", msg.status, msg.description)
+			}
+			if err := pull(); err != nil {
+				return true, err
+			}
+			continue
+		}
+
+		acknowledgement := q.ack(ctx, msg)
+		if msg.reply != "" {
+			if err := conn.publish(msg.reply, "", acknowledgement); err != nil {
+				return true, err
+			}
+		}
+		if pending--; pending == 0 {
+			if err := pull(); err != nil {
+				return true, err
+			}
+		}
+	}
+}
+
+// ack ingests a message and returns the acknowledgement to reply with
+func (q *NATSConsumer) ack(ctx context.Context, msg *natsMsg) []byte {
+	tenant := q.config.Tenant
+	if value := msg.header.Get(q.tenantHeader); value != "" {
+		tenant = value
+	}
+	if tenant == "" {
+		tenant = service.DefaultTenant
+	}
+	switch q.ingester.IngestEncoded(ctx, "nats", tenant, msg.header.Get("Content-Type"), msg.data) {
+	case ingestAck:
+		return []byte("+ACK")
+	case ingestRetry:
+		return fmt.Appendf(nil, `-NAK {"delay":%d}`, time.Duration(q.config.RetryDelay))
+	default:
+		return []byte("+TERM")
+	}
+}
+
+func (q *NATSConsumer) Shutdown() {
+	if q.cancel != nil {
+		q.cancel()
+		<-q.done
+	}
+}
+
+// natsConn is a NATS client connection
+type natsConn struct {
+	net.Conn
+	r *bufio.Reader
+	w *bufio.Writer
+}
+
+// natsMsg is a delivered message. JetStream reports the end of a pull as a
+// message with a status and no data.
+type natsMsg struct {
+	subject     string
+	reply       string
+	header      http.Header
+	status      int
+	description string
+	data        []byte
+}
+
+// dialNATS connects and authenticates, returning once the server has
+// answered a PING
+func dialNATS(ctx context.Context, rawURL, token string) (*natsConn, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	dialer := &net.Dialer{Timeout: 10 * time.Second}
+	var conn net.Conn
+	if u.Scheme == "tls" {
+		conn, err = (&tls.Dialer{NetDialer: dialer}).DialContext(ctx, "tcp", u.Host)
+	} else {
+		conn, err = dialer.DialContext(ctx, "tcp", u.Host)
+	}
+	if err != nil {
+		return nil, err
+	}
+	c := &natsConn{Conn: conn, r: bufio.NewReaderSize(conn, natsMaxLine), w: bufio.NewWriter(conn)}
+
+	conn.SetDeadline(time.Now().Add(10 * time.Second))
+	line, err := c.readLine()
+	if err != nil || !strings.HasPrefix(line, "INFO ") {
+		conn.Close()
+		return nil, fmt.Errorf("unexpected NATS greeting %q: %v", line, err)
+	}
+	options := map[string]any{
+		"verbose": false, "pedantic": false, "headers": true, "no_responders": true,
+		"name": "interview-task", "lang": "go", "version": "1.0.0", "protocol": 1,
+	}
+	if u.User != nil {
+		options["user"] = u.User.Username()
+		options["pass"], _ = u.User.Password()
+	} else if token != "" {
+		options["auth_token"] = token
+	}
+	encoded, _ := json.Marshal(options)
+	fmt.Fprintf(c.w, "CONNECT %s\r\nPING\r\n", encoded)
+	if err := c.w.Flush(); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	for {
+		line, err := c.readLine()
+		if err != nil {
+			conn.Close()
+			return nil, err
+		}
+		if line == "PONG" {
+			break
+		}
+		if strings.HasPrefix(line, "-ERR") {
+			conn.Close()
+			return nil, fmt.Errorf("NATS server refused connection: %s", line)
+		}
+	}
+	conn.SetDeadline(time.Time{})
+	return c, nil
+}
+
+func (c *natsConn) readLine() (string, error) {
+	line, err := c.r.ReadSlice('\n')
+	if errors.Is(err, bufio.ErrBufferFull) {
+		return "", fmt.Errorf("NATS protocol line too long")
+	}
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(string(line), "\r\n"), nil
+}
+
+func (c *natsConn) subscribe(subject, sid string) error {
+	fmt.Fprintf(c.w, "SUB %s %s\r\n", subject, sid)
+	return c.w.Flush()
+}
+
+func (c *natsConn) publish(subject, reply string, data []byte) error {
+	if reply != "" {
+		fmt.Fprintf(c.w, "PUB %s %s %d\r\n", subject, reply, len(data))
+	} else {
+		fmt.Fprintf(c.w, "PUB %s %d\r\n", subject, len(data))
+	}
+	c.w.Write(data)
+	c.w.WriteString("\r\n")
+	return c.w.Flush()
+}
+
+// next returns the next message, answering server PINGs meanwhile
+func (c *natsConn) next() (*natsMsg, error) {
+	for {
+		line, err := c.readLine()
+		if err != nil {
+			return nil, err
+		}
+		op, args, _ := strings.Cut(line, " ")
+		switch strings.ToUpper(op) {
+		case "PING":
+			c.w.WriteString("PONG\r\n")
+			if err := c.w.Flush(); err != nil {
+				return nil, err
+			}
+		case "MSG", "HMSG":
+			return c.readMsg(strings.ToUpper(op) == "HMSG", strings.Fields(args))
+		case "-ERR":
+			return nil, fmt.Errorf("NATS error: %s", args)
+		}
+	}
+}
+
+// readMsg reads the payload of "MSG subject sid [reply] size" or
+// "HMSG subject sid [reply] header-size total-size"
+func (c *natsConn) readMsg(hasHeader bool, args []string) (*natsMsg, error) {
+	sizes := 1
+	if hasHeader {
+		sizes = 2
+	}
+	if len(args) != 2+sizes && len(args) != 3+sizes {
+		return nil, fmt.Errorf("malformed NATS message arguments %q", args)
+	}
+	msg := &natsMsg{subject: args[0], header: http.Header{}}
+	if len(args) == 3+sizes {
+		msg.reply = args[2]
+	}
+	headerSize := 0
+	total, err := strconv.Atoi(args[len(args)-1])
+	if err == nil && hasHeader {
+		headerSize, err = strconv.Atoi(args[len(args)-2])
+	}
+	if err != nil || total < 0 || headerSize < 0 || headerSize > total {
+		return nil, fmt.Errorf("malformed NATS message sizes %q", args)
+	}
+
+	payload := make([]byte, total+2)
+	if _, err := io.ReadFull(c.r, payload); err != nil {
+		return nil, err
+	}
+	if hasHeader {
+		if err := msg.parseHeader(payload[:headerSize]); err != nil {
+			return nil, err
+		}
+	}
+	msg.data = payload[headerSize:total]
+	return msg, nil
+}
+
+// parseHeader parses "NATS/1.0 [status [description]]" followed by MIME
+// header lines
+func (msg *natsMsg) parseHeader(block []byte) error {
+	r := textproto.NewReader(bufio.NewReader(bytes.NewReader(block)))
+	version, err := r.ReadLine()
+	if err != nil || !strings.HasPrefix(version, "NATS/1.0") {
+		return fmt.Errorf("malformed NATS message header")
+	}
+	if status := strings.TrimSpace(strings.TrimPrefix(version, "NATS/1.0")); status != "" {
+		code, description, _ := strings.Cut(status, " ")
+		msg.status, _ = strconv.Atoi(code)
+		msg.description = description
+	}
+	header, err := r.ReadMIMEHeader()
+	if err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("malformed NATS message header: %w", err)
+	}
+	msg.header = http.Header(header)
+	return nil
+}
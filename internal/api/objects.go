@@ -0,0 +1,54 @@
+package api
+
+import (
+	"bytes"
+	"net/http"
+	"strings"
+
+	"interview-task/internal/config"
+	"interview-task/internal/service"
+)
+
+// objectStorageType is the storage type whose items are served as objects
+const objectStorageType = "file"
+
+// ObjectHandler serves the file storage type's items as plain HTTP
+// resources, for a CDN to cache. Conditional and range requests are
+// answered from the ETag and Last-Modified headers.
+type ObjectHandler struct {
+	service *service.DataService
+	config  config.ObjectServingConfig
+}
+
+func NewObjectHandler(service *service.DataService, config config.ObjectServingConfig) *ObjectHandler {
+	return &ObjectHandler{service: service, config: config}
+}
+
+// HandleGet serves GET and HEAD of /objects/{id}
+func (h *ObjectHandler) HandleGet(w http.ResponseWriter, r *http.Request) {
+	item, err := h.service.GetData(r.Context(), objectStorageType, r.PathValue("id"))
+	if err != nil {
+		http.Error(w, err.Error(), statusForError(err))
+		return
+	}
+	w.Header().Set("Content-Type", item.ContentType)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set(service.HeaderContentSHA256, item.SHA256)
+	if item.ETag != "" {
+		w.Header().Set("ETag", item.ETag)
+	}
+	if h.config.CacheControl != "" {
+		w.Header().Set("Cache-Control", h.config.CacheControl)
+	}
+	http.ServeContent(w, r, "", item.ModifiedAt, bytes.NewReader(item.Data))
+}
+
+// isObjectPath reports whether a request path is served by ObjectHandler,
+// with or without a tenant prefix
+func isObjectPath(path string) bool {
+	if rest, ok := strings.CutPrefix(path, "/tenants/"); ok {
+		_, path, _ = strings.Cut(rest, "/")
+		path = "/" + path
+	}
+	return strings.HasPrefix(path, "/objects/")
+}
@@ -0,0 +1,226 @@
+package api
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"interview-task/internal/config"
+	"interview-task/internal/service"
+	"interview-task/internal/storage"
+)
+
+// Query parameters of pre-signed URLs. The signature covers the method,
+// the path and every other query parameter.
+const (
+	presignKeyParam       = "X-Presign-Key"
+	presignExpiresParam   = "X-Presign-Expires"
+	presignSignatureParam = "X-Presign-Signature"
+)
+
+// minPresignSecret is the shortest secret accepted for signing URLs
+const minPresignSecret = 32
+
+// errPresignDisabled is returned without a secret
+var errPresignDisabled = fmt.Errorf("%w: pre-signed URLs need presign.secret_file", storage.ErrConflict)
+
+// Presigner - IMPLEMENTS time-limited signed URLs for downloading or
+// uploading a single item. A URL acts as the API key that requested it,
+// which is looked up again on every use, so deleting the key revokes its
+// URLs.
+type Presigner struct {
+	secret []byte
+}
+
+// NewPresigner returns nil without a secret
+func NewPresigner(config config.PresignConfig) (*Presigner, error) {
+	if config.SecretFile == "" {
+		return nil, nil
+	}
+	secret, err := os.ReadFile(config.SecretFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read presign secret: %w", err)
+	}
+	secret = []byte(strings.TrimSpace(string(secret)))
+	if len(secret) < minPresignSecret {
+		return nil, fmt.Errorf("presign secret must be at least %d bytes", minPresignSecret)
+	}
+	return &Presigner{secret: secret}, nil
+}
+
+// signature signs a request's method, path and query, less any signature
+func (p *Presigner) signature(method, path string, query url.Values) string {
+	signed := make(url.Values, len(query))
+	for key, values := range query {
+		if key != presignSignatureParam {
+			signed[key] = values
+		}
+	}
+	mac := hmac.New(sha256.New, p.secret)
+	mac.Write([]byte(method + "\n" + path + "\n" + canonicalQuery(signed)))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Presign returns the path and query of a URL letting anyone holding it
+// send the request as key until expires
+func (p *Presigner) Presign(method, path string, query url.Values, key string, expires time.Time) string {
+	query.Set(presignKeyParam, key)
+	query.Set(presignExpiresParam, strconv.FormatInt(expires.Unix(), 10))
+	query.Set(presignSignatureParam, p.signature(method, path, query))
+	return path + "?" + query.Encode()
+}
+
+// isPresigned reports whether a request carries a URL signature
+func isPresigned(r *http.Request) bool {
+	return r.URL.Query().Has(presignSignatureParam)
+}
+
+// verify checks a pre-signed request and returns the name of the key it
+// acts as
+func (p *Presigner) verify(r *http.Request, now time.Time) (string, error) {
+	if p == nil {
+		return "", errors.New("pre-signed URLs are disabled")
+	}
+	query := r.URL.Query()
+	expires, err := strconv.ParseInt(query.Get(presignExpiresParam), 10, 64)
+	if err != nil {
+		return "", errors.New("malformed expiry")
+	}
+	if now.After(time.Unix(expires, 0)) {
+		return "", errors.New("the URL has expired")
+	}
+	want := p.signature(r.Method, r.URL.Path, query)
+	if !hmac.Equal([]byte(want), []byte(query.Get(presignSignatureParam))) {
+		return "", errors.New("signature mismatch")
+	}
+	return query.Get(presignKeyParam), nil
+}
+
+// PresignHandler issues pre-signed URLs and receives the uploads they
+// allow
+type PresignHandler struct {
+	presigner *Presigner
+	service   *service.DataService
+	config    config.PresignConfig
+}
+
+func NewPresignHandler(presigner *Presigner, service *service.DataService, config config.PresignConfig) *PresignHandler {
+	return &PresignHandler{presigner: presigner, service: service, config: config}
+}
+
+// presignRequest is the body of POST /presign. Uploads without an ID get a
+// new one.
+type presignRequest struct {
+	// Method is GET to download the item or PUT to upload it
+	Method      string          `json:"method"`
+	ID          string          `json:"id"`
+	StorageType string          `json:"storage_type"`
+	ExpiresIn   config.Duration `json:"expires_in"`
+}
+
+// presignResponse carries a URL relative to the service's base URL
+type presignResponse struct {
+	Method    string    `json:"method"`
+	ID        string    `json:"id"`
+	URL       string    `json:"url"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// HandlePresign issues a URL acting as the caller's key for one item of
+// the request's tenant
+func (h *PresignHandler) HandlePresign(w http.ResponseWriter, r *http.Request) {
+	if h.presigner == nil {
+		http.Error(w, errPresignDisabled.Error(), statusForError(errPresignDisabled))
+		return
+	}
+	var req presignRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON format", http.StatusBadRequest)
+		return
+	}
+
+	expiresIn := time.Duration(req.ExpiresIn)
+	if expiresIn == 0 {
+		expiresIn = time.Duration(h.config.DefaultExpiry)
+	}
+	if expiresIn < 0 || expiresIn > time.Duration(h.config.MaxExpiry) {
+		http.Error(w, fmt.Sprintf("expires_in must be between 0 and %s", time.Duration(h.config.MaxExpiry)), http.StatusBadRequest)
+		return
+	}
+	if req.ID == "" && req.Method == http.MethodPut {
+		id, err := service.NewItemID()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		req.ID = id
+	}
+	if err := service.ValidateItemID(req.ID); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	path := "/tenants/" + service.TenantFromContext(r.Context()) + "/" + config.APIVersionV1 + "/data/" + req.ID
+	switch req.Method {
+	case http.MethodGet:
+	case http.MethodPut:
+		path += "/content"
+	default:
+		http.Error(w, "method must be GET or PUT", http.StatusBadRequest)
+		return
+	}
+	query := url.Values{}
+	if req.StorageType != "" {
+		query.Set("storage_type", req.StorageType)
+	}
+	expiresAt := time.Now().Add(expiresIn).Truncate(time.Second).UTC()
+	writeJSON(w, http.StatusOK, presignResponse{
+		Method:    req.Method,
+		ID:        req.ID,
+		URL:       h.presigner.Presign(req.Method, path, query, service.PrincipalFromContext(r.Context()).Name, expiresAt),
+		ExpiresAt: expiresAt,
+	})
+}
+
+// HandleUpload stores the raw request body as the item, creating it or
+// adding a version. The Content-Type header becomes its content type.
+func (h *PresignHandler) HandleUpload(w http.ResponseWriter, r *http.Request) {
+	data, err := io.ReadAll(http.MaxBytesReader(w, r.Body, h.config.MaxUploadBytes))
+	var tooLarge *http.MaxBytesError
+	switch {
+	case errors.As(err, &tooLarge):
+		http.Error(w, fmt.Sprintf("uploads are limited to %d bytes", h.config.MaxUploadBytes), http.StatusRequestEntityTooLarge)
+		return
+	case err != nil:
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	req := &service.SaveRequest{Data: data, StorageType: r.URL.Query().Get("storage_type"), ContentType: r.Header.Get("Content-Type")}
+	if err := service.ChecksumsFromHeaders(r, req); err != nil {
+		http.Error(w, err.Error(), statusForError(err))
+		return
+	}
+	id := r.PathValue("id")
+	ctx := service.WithEndpoint(r.Context(), r.URL.Path)
+	if err := h.service.PutData(ctx, id, req); err != nil && !errors.Is(err, service.ErrSpooled) {
+		writeServiceError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{
+		"message": "Data saved successfully",
+		"status":  "success",
+		"id":      id,
+		"sha256":  storage.PayloadSHA256(data),
+	})
+}
@@ -0,0 +1,226 @@
+package api
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"interview-task/internal/config"
+	"interview-task/internal/service"
+)
+
+// MediaTypeProtobuf is the media type of the save API's protobuf encoding,
+// whose messages are defined in proto/datastore.proto
+const MediaTypeProtobuf = "application/x-protobuf"
+
+// Protobuf wire types
+const (
+	wireVarint = 0
+	wireI64    = 1
+	wireBytes  = 2
+	wireI32    = 5
+)
+
+var errTruncated = errors.New("truncated protobuf message")
+
+// SaveResponse is the body answering a save
+type SaveResponse struct {
+	ID      string `json:"id"`
+	Message string `json:"message"`
+	SHA256  string `json:"sha256"`
+	Status  string `json:"status"`
+}
+
+// protoFields - IMPLEMENTS iteration over the fields of an encoded protobuf
+// message. It covers the wire format only; the message types are decoded by
+// hand, as the server has no generated code.
+type protoFields struct {
+	data []byte
+	err  error
+
+	number int
+	wire   int
+	varint uint64
+	bytes  []byte
+}
+
+// next reads the next field, returning false at the end of the message or
+// on malformed input, which is then reported by err
+func (p *protoFields) next() bool {
+	if len(p.data) == 0 || p.err != nil {
+		return false
+	}
+	tag, n := binary.Uvarint(p.data)
+	if n <= 0 {
+		p.err = errTruncated
+		return false
+	}
+	p.data = p.data[n:]
+	p.number, p.wire = int(tag>>3), int(tag&7)
+	if p.number == 0 {
+		p.err = fmt.Errorf("invalid protobuf field number 0")
+		return false
+	}
+	switch p.wire {
+	case wireVarint:
+		p.varint, n = binary.Uvarint(p.data)
+		if n <= 0 {
+			p.err = errTruncated
+			return false
+		}
+	case wireI64, wireI32:
+		n = 8
+		if p.wire == wireI32 {
+			n = 4
+		}
+	case wireBytes:
+		size, m := binary.Uvarint(p.data)
+		if m <= 0 || size > uint64(len(p.data)-m) {
+			p.err = errTruncated
+			return false
+		}
+		p.bytes = p.data[m : m+int(size)]
+		n = m + int(size)
+	default:
+		p.err = fmt.Errorf("unsupported protobuf wire type %d", p.wire)
+		return false
+	}
+	if n > len(p.data) {
+		p.err = errTruncated
+		return false
+	}
+	p.data = p.data[n:]
+	return true
+}
+
+// string returns the current field as a string, checking its wire type
+func (p *protoFields) string() (string, error) {
+	if p.wire != wireBytes {
+		return "", fmt.Errorf("protobuf field %d is not length-delimited", p.number)
+	}
+	return string(p.bytes), nil
+}
+
+// appendProtoBytes appends a length-delimited field, omitting empty ones as
+// proto3 does
+func appendProtoBytes(buf []byte, number int, value []byte) []byte {
+	if len(value) == 0 {
+		return buf
+	}
+	buf = binary.AppendUvarint(buf, uint64(number)<<3|wireBytes)
+	buf = binary.AppendUvarint(buf, uint64(len(value)))
+	return append(buf, value...)
+}
+
+func appendProtoString(buf []byte, number int, value string) []byte {
+	return appendProtoBytes(buf, number, []byte(value))
+}
+
+// appendProtoVarint appends a varint field, omitting zero as proto3 does
+func appendProtoVarint(buf []byte, number int, value uint64) []byte {
+	if value == 0 {
+		return buf
+	}
+	buf = binary.AppendUvarint(buf, uint64(number)<<3|wireVarint)
+	return binary.AppendUvarint(buf, value)
+}
+
+// appendProtoMap appends a map<string, string> field, in key order so the
+// encoding is deterministic
+func appendProtoMap(buf []byte, number int, values map[string]string) []byte {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		var entry []byte
+		entry = appendProtoString(entry, 1, key)
+		entry = appendProtoString(entry, 2, values[key])
+		// An entry is written even when empty, or the key would be lost
+		buf = binary.AppendUvarint(buf, uint64(number)<<3|wireBytes)
+		buf = binary.AppendUvarint(buf, uint64(len(entry)))
+		buf = append(buf, entry...)
+	}
+	return buf
+}
+
+// saveRequestMessage is a SaveRequest in its datastore.v1.SaveRequest
+// encoding
+type saveRequestMessage service.SaveRequest
+
+// UnmarshalProto decodes a datastore.v1.SaveRequest. Unknown fields are
+// skipped, so producers may use a newer schema.
+func (req *saveRequestMessage) UnmarshalProto(data []byte) error {
+	fields := &protoFields{data: data}
+	for fields.next() {
+		var err error
+		switch fields.number {
+		case 1:
+			if _, err = fields.string(); err == nil {
+				req.Data = append([]byte(nil), fields.bytes...)
+			}
+		case 2:
+			req.StorageType, err = fields.string()
+		case 3:
+			req.ContentType, err = fields.string()
+		case 4:
+			var ttl string
+			if ttl, err = fields.string(); err == nil && ttl != "" {
+				var d time.Duration
+				if d, err = time.ParseDuration(ttl); err == nil {
+					req.TTL = config.Duration(d)
+				}
+			}
+		case 5:
+			err = fields.mapEntry(&req.Labels)
+		case 6:
+			req.Source, err = fields.string()
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return fields.err
+}
+
+// mapEntry adds the current field, an entry of a map<string, string>, to m
+func (p *protoFields) mapEntry(m *map[string]string) error {
+	if _, err := p.string(); err != nil {
+		return err
+	}
+	var key, value string
+	var err error
+	entry := &protoFields{data: p.bytes}
+	for entry.next() {
+		switch entry.number {
+		case 1:
+			key, err = entry.string()
+		case 2:
+			value, err = entry.string()
+		}
+		if err != nil {
+			return err
+		}
+	}
+	if entry.err != nil {
+		return entry.err
+	}
+	if *m == nil {
+		*m = make(map[string]string)
+	}
+	(*m)[key] = value
+	return nil
+}
+
+// MarshalProto encodes a datastore.v1.SaveResponse
+func (resp *SaveResponse) MarshalProto() []byte {
+	var buf []byte
+	buf = appendProtoString(buf, 1, resp.ID)
+	buf = appendProtoString(buf, 2, resp.Message)
+	buf = appendProtoString(buf, 3, resp.SHA256)
+	buf = appendProtoString(buf, 4, resp.Status)
+	return buf
+}
@@ -0,0 +1,20 @@
+package api
+
+import (
+	"net/http"
+
+	"interview-task/internal/service"
+)
+
+// QuotaHandler exposes usage to administrators
+type QuotaHandler struct {
+	quotas *service.QuotaManager
+}
+
+func NewQuotaHandler(quotas *service.QuotaManager) *QuotaHandler {
+	return &QuotaHandler{quotas: quotas}
+}
+
+func (h *QuotaHandler) HandleUsage(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]any{"usage": h.quotas.Report()})
+}
@@ -0,0 +1,141 @@
+package api
+
+import (
+	"container/list"
+	"math"
+	"net/http"
+	"net/netip"
+	"strconv"
+	"sync"
+	"time"
+
+	"interview-task/internal/metrics"
+	"interview-task/internal/service"
+)
+
+// maxBuckets is how many keys, and how many client addresses, are tracked
+// before the least recently used bucket is dropped
+const maxBuckets = 10000
+
+// ipv6ClientBits is the prefix anonymous IPv6 callers are limited by, as a
+// single host is usually handed a whole /64
+const ipv6ClientBits = 64
+
+// RateLimiter - IMPLEMENTS per-key rate limiting with token buckets. Limits
+// come from the caller's principal, so a changed managed key is limited
+// anew from its next request. Requests without a key are limited per client
+// address, or for IPv6 per /64.
+type RateLimiter struct {
+	metrics        *metrics.Metrics
+	anonymousRate  float64
+	anonymousBurst int
+
+	mu      sync.Mutex
+	buckets *bucketSet
+	// clients are the anonymous callers' buckets, by client address
+	clients *bucketSet
+}
+
+// tokenBucket refills at the key's rate up to its burst
+type tokenBucket struct {
+	key    string
+	tokens float64
+	last   time.Time
+}
+
+// bucketSet holds up to maxBuckets buckets, dropping the least recently
+// used for a new one. A dropped bucket starts out full when it comes back.
+type bucketSet struct {
+	lru     *list.List
+	entries map[string]*list.Element
+}
+
+func newBucketSet() *bucketSet {
+	return &bucketSet{lru: list.New(), entries: make(map[string]*list.Element)}
+}
+
+// get returns key's bucket, adding a full one if there is none; the caller
+// must hold the limiter's mu
+func (b *bucketSet) get(key string, burst float64, now time.Time) *tokenBucket {
+	if element, ok := b.entries[key]; ok {
+		b.lru.MoveToFront(element)
+		return element.Value.(*tokenBucket)
+	}
+	if b.lru.Len() >= maxBuckets {
+		oldest := b.lru.Remove(b.lru.Back()).(*tokenBucket)
+		delete(b.entries, oldest.key)
+	}
+	bucket := &tokenBucket{key: key, tokens: burst, last: now}
+	b.entries[key] = b.lru.PushFront(bucket)
+	return bucket
+}
+
+func NewRateLimiter(metrics *metrics.Metrics, anonymousRate float64, anonymousBurst int) *RateLimiter {
+	metrics.Describe("rate_limited_total", "Requests rejected by a key's or client address's rate limit")
+	return &RateLimiter{
+		metrics:        metrics,
+		anonymousRate:  anonymousRate,
+		anonymousBurst: anonymousBurst,
+		buckets:        newBucketSet(),
+		clients:        newBucketSet(),
+	}
+}
+
+func (l *RateLimiter) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key, rate, burst, buckets := l.limitFor(r)
+		if rate <= 0 || loadSheddingExempt[r.URL.Path] {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if wait := l.take(buckets, key, rate, burst); wait > 0 {
+			l.metrics.Add("rate_limited_total", 1)
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+			http.Error(w, "Rate limit exceeded, retry later", http.StatusTooManyRequests)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// limitFor returns the bucket a request spends from and its limits: the
+// key's own, or for anonymous callers their client address's
+func (l *RateLimiter) limitFor(r *http.Request) (string, float64, int, *bucketSet) {
+	principal := service.PrincipalFromContext(r.Context())
+	if principal.Authenticated() {
+		return principal.Name, principal.RateLimit, principal.Burst, l.buckets
+	}
+	addr, _ := service.ClientIPFromContext(r.Context())
+	return clientKey(addr), l.anonymousRate, l.anonymousBurst, l.clients
+}
+
+// clientKey is the bucket key of a client address: the address itself, or
+// its /64 for IPv6, so a host can't skip the limit by changing addresses
+func clientKey(addr netip.Addr) string {
+	if addr.Is6() && !addr.Is4In6() {
+		prefix, _ := addr.Prefix(ipv6ClientBits)
+		return prefix.String()
+	}
+	return addr.Unmap().String()
+}
+
+// take spends a token of a bucket, or returns how long until one is
+// available
+func (l *RateLimiter) take(buckets *bucketSet, key string, rate float64, burstLimit int) time.Duration {
+	burst := float64(burstLimit)
+	if burst < 1 {
+		burst = max(math.Ceil(rate), 1)
+	}
+	now := time.Now()
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	bucket := buckets.get(key, burst, now)
+	bucket.tokens = min(bucket.tokens+now.Sub(bucket.last).Seconds()*rate, burst)
+	bucket.last = now
+	if bucket.tokens < 1 {
+		return time.Duration((1 - bucket.tokens) / rate * float64(time.Second))
+	}
+	bucket.tokens--
+	return 0
+}
@@ -0,0 +1,50 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"strconv"
+	"testing"
+	"time"
+
+	"interview-task/internal/metrics"
+	"interview-task/internal/service"
+)
+
+// serveFrom sends an anonymous request from addr through handler
+func serveFrom(handler http.Handler, addr string) int {
+	r := httptest.NewRequest(http.MethodGet, "/v1/data", nil)
+	r = r.WithContext(service.WithClientIP(r.Context(), netip.MustParseAddr(addr)))
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	return w.Code
+}
+
+func TestRateLimitGroupsIPv6ByPrefix(t *testing.T) {
+	handler := NewRateLimiter(metrics.NewMetrics(), 0.001, 1).Middleware(http.NotFoundHandler())
+
+	if code := serveFrom(handler, "2001:db8:1:2::1"); code == http.StatusTooManyRequests {
+		t.Fatal("first request was rate limited")
+	}
+	if code := serveFrom(handler, "2001:db8:1:2::2"); code != http.StatusTooManyRequests {
+		t.Fatalf("request from the same /64 returned %d, want 429", code)
+	}
+	if code := serveFrom(handler, "2001:db8:1:3::1"); code == http.StatusTooManyRequests {
+		t.Fatal("request from another /64 was rate limited")
+	}
+}
+
+func TestRateLimitBucketsBounded(t *testing.T) {
+	l := NewRateLimiter(metrics.NewMetrics(), 1, 1)
+	for i := range maxBuckets + 10 {
+		l.take(l.clients, strconv.Itoa(i), 1, 1)
+	}
+	if n := len(l.clients.entries); n != maxBuckets {
+		t.Fatalf("%d client buckets tracked, want %d", n, maxBuckets)
+	}
+	// The most recently used survive
+	if wait := l.take(l.clients, strconv.Itoa(maxBuckets+9), 1, 1); wait <= 0 || wait > time.Second {
+		t.Fatalf("recent client's bucket was dropped, wait %v", wait)
+	}
+}
@@ -0,0 +1,38 @@
+package api
+
+import (
+	"net/http"
+
+	"interview-task/internal/service"
+)
+
+// ReindexHandler exposes the re-index job to administrators
+type ReindexHandler struct {
+	reindexer *service.Reindexer
+}
+
+func NewReindexHandler(reindexer *service.Reindexer) *ReindexHandler {
+	return &ReindexHandler{reindexer: reindexer}
+}
+
+func (h *ReindexHandler) HandleStart(w http.ResponseWriter, r *http.Request) {
+	status, err := h.reindexer.Start(service.TenantFromContext(r.Context()), r.URL.Query().Get("storage_type"))
+	if err != nil {
+		code := statusForError(err)
+		if status.State == service.ReindexRunning {
+			code = http.StatusConflict
+		}
+		http.Error(w, err.Error(), code)
+		return
+	}
+	writeJSON(w, http.StatusAccepted, status)
+}
+
+func (h *ReindexHandler) HandleStatus(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, h.reindexer.Status())
+}
+
+func (h *ReindexHandler) HandleCancel(w http.ResponseWriter, r *http.Request) {
+	h.reindexer.Cancel()
+	writeJSON(w, http.StatusOK, h.reindexer.Status())
+}
@@ -0,0 +1,34 @@
+package api
+
+import (
+	"net/http"
+	"regexp"
+
+	"interview-task/internal/service"
+)
+
+// HeaderRequestID correlates a request across client, server logs and the
+// audit trail
+const HeaderRequestID = "X-Request-ID"
+
+// Client-supplied request IDs are kept only when they are short and safe to
+// log
+var requestIDPattern = regexp.MustCompile(`^[A-Za-z0-9._:-]{1,128}$`)
+
+// RequestIDMiddleware tags every request with an ID, keeping one supplied by
+// the client, and echoes it in the response
+func RequestIDMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(HeaderRequestID)
+		if !requestIDPattern.MatchString(id) {
+			generated, err := service.NewItemID()
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			id = generated
+		}
+		w.Header().Set(HeaderRequestID, id)
+		next.ServeHTTP(w, r.WithContext(service.WithRequestID(r.Context(), id)))
+	})
+}
@@ -0,0 +1,56 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"interview-task/internal/config"
+	"interview-task/internal/service"
+)
+
+// ReshardHandler exposes resharding to administrators. Resharding moves
+// every tenant's data, so keys bound to a single tenant may not use it.
+type ReshardHandler struct {
+	resharder *service.Resharder
+}
+
+func NewReshardHandler(resharder *service.Resharder) *ReshardHandler {
+	return &ReshardHandler{resharder: resharder}
+}
+
+// HandleStart accepts the target ShardMapConfig
+func (h *ReshardHandler) HandleStart(w http.ResponseWriter, r *http.Request) {
+	if !requireGlobalKey(w, r) {
+		return
+	}
+	var config config.ShardMapConfig
+	if err := json.NewDecoder(r.Body).Decode(&config); err != nil {
+		http.Error(w, "Invalid JSON format", http.StatusBadRequest)
+		return
+	}
+	status, err := h.resharder.Start(config)
+	if err != nil {
+		http.Error(w, err.Error(), statusForError(err))
+		return
+	}
+	writeJSON(w, http.StatusAccepted, status)
+}
+
+func (h *ReshardHandler) HandleStatus(w http.ResponseWriter, r *http.Request) {
+	if !requireGlobalKey(w, r) {
+		return
+	}
+	writeJSON(w, http.StatusOK, h.resharder.Status())
+}
+
+func (h *ReshardHandler) HandleAbort(w http.ResponseWriter, r *http.Request) {
+	if !requireGlobalKey(w, r) {
+		return
+	}
+	status, err := h.resharder.Abort()
+	if err != nil {
+		http.Error(w, err.Error(), statusForError(err))
+		return
+	}
+	writeJSON(w, http.StatusAccepted, status)
+}
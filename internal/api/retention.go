@@ -0,0 +1,62 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+
+	"interview-task/internal/service"
+)
+
+// RetentionHandler exposes retention runs to administrators. A run spans
+// every tenant, so keys bound to a single tenant may not use it.
+type RetentionHandler struct {
+	enforcer *service.RetentionEnforcer
+}
+
+func NewRetentionHandler(enforcer *service.RetentionEnforcer) *RetentionHandler {
+	return &RetentionHandler{enforcer: enforcer}
+}
+
+// retentionRequest is the body of POST /admin/retention, which may be empty
+type retentionRequest struct {
+	DryRun bool `json:"dry_run"`
+}
+
+func (h *RetentionHandler) HandleStart(w http.ResponseWriter, r *http.Request) {
+	if !requireGlobalKey(w, r) {
+		return
+	}
+	var req retentionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		http.Error(w, "Invalid JSON format", http.StatusBadRequest)
+		return
+	}
+	report, err := h.enforcer.Enforce(req.DryRun)
+	if err != nil {
+		http.Error(w, err.Error(), statusForError(err))
+		return
+	}
+	writeJSON(w, http.StatusAccepted, report)
+}
+
+// HandleReport returns the running or last run's report
+func (h *RetentionHandler) HandleReport(w http.ResponseWriter, r *http.Request) {
+	if !requireGlobalKey(w, r) {
+		return
+	}
+	writeJSON(w, http.StatusOK, h.enforcer.Report())
+}
+
+func (h *RetentionHandler) HandleCancel(w http.ResponseWriter, r *http.Request) {
+	if !requireGlobalKey(w, r) {
+		return
+	}
+	report, err := h.enforcer.Cancel()
+	if err != nil {
+		http.Error(w, err.Error(), statusForError(err))
+		return
+	}
+	writeJSON(w, http.StatusOK, report)
+}
@@ -0,0 +1,599 @@
+package api
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"interview-task/internal/config"
+	"interview-task/internal/logging"
+	"interview-task/internal/service"
+	"interview-task/internal/storage"
+)
+
+// Objects are items whose ID derives from the object key. The key and the
+// MD5 S3 clients expect as an ETag are kept in the item's labels, which
+// bounds keys to the label value size.
+const (
+	s3KeyLabel     = "s3.key"
+	s3ETagLabel    = "s3.etag"
+	s3Namespace    = "http://s3.amazonaws.com/doc/2006-03-01/"
+	s3MaxListKeys  = 1000
+	s3Region       = "us-east-1"
+	s3StorageClass = "STANDARD"
+)
+
+// Subresources this facade doesn't implement; requests for them fail
+// instead of being mistaken for plain object or bucket operations
+var s3UnsupportedSubresources = []string{
+	"acl", "cors", "delete", "lifecycle", "policy", "tagging", "uploads", "uploadId", "partNumber", "versionId", "versions",
+}
+
+// S3Server - IMPLEMENTS a minimal S3-compatible API over DataService, so S3
+// SDKs and tools such as rclone can use the service as a target. Buckets
+// are storage types and exist in every tenant; requests are signed with an
+// API key's name as the access key ID and the key as the secret.
+type S3Server struct {
+	service *service.DataService
+	config  config.S3Config
+	mux     *http.ServeMux
+	server  *http.Server
+}
+
+func NewS3Server(service *service.DataService, config config.S3Config) *S3Server {
+	// The server exists from the start, so a Shutdown before Serve stops it
+	s := &S3Server{service: service, config: config, mux: http.NewServeMux(),
+		server: &http.Server{Addr: ":" + config.Port}}
+	s.mux.HandleFunc("GET /{$}", s.listBuckets)
+	s.mux.HandleFunc("GET /{bucket}", s.getBucket)
+	s.mux.HandleFunc("PUT /{bucket}", s.createBucket)
+	s.mux.HandleFunc("DELETE /{bucket}", s.deleteBucket)
+	s.mux.HandleFunc("GET /{bucket}/{key...}", s.getObject)
+	s.mux.HandleFunc("PUT /{bucket}/{key...}", s.putObject)
+	s.mux.HandleFunc("DELETE /{bucket}/{key...}", s.deleteObject)
+	return s
+}
+
+// Serve listens on the configured port until Shutdown
+func (s *S3Server) Serve(handler http.Handler) error {
+	s.server.Handler = handler
+	logging.Printf("S3 API starting on :%s", s.config.Port)
+	err := s.server.ListenAndServe()
+	if errors.Is(err, http.ErrServerClosed) {
+		return nil
+	}
+	return err
+}
+
+// Shutdown stops accepting requests and waits for those in progress
+func (s *S3Server) Shutdown(ctx context.Context) error {
+	return s.server.Shutdown(ctx)
+}
+
+func (s *S3Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("X-Amz-Request-Id", service.RequestIDFromContext(r.Context()))
+	query := r.URL.Query()
+	for _, name := range s3UnsupportedSubresources {
+		if query.Has(name) {
+			s3Error(w, r, http.StatusNotImplemented, "NotImplemented", "The "+name+" subresource is not supported")
+			return
+		}
+	}
+	if _, pattern := s.mux.Handler(r); pattern == "" {
+		s3Error(w, r, http.StatusNotImplemented, "NotImplemented", "This operation is not supported")
+		return
+	}
+	s.mux.ServeHTTP(w, r)
+}
+
+// s3ObjectID derives the item ID an object key is stored under
+func s3ObjectID(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:16])
+}
+
+func (s *S3Server) listBuckets(w http.ResponseWriter, r *http.Request) {
+	type bucket struct {
+		Name         string `xml:"Name"`
+		CreationDate string `xml:"CreationDate"`
+	}
+	result := struct {
+		XMLName xml.Name `xml:"ListAllMyBucketsResult"`
+		XMLNS   string   `xml:"xmlns,attr"`
+		Owner   string   `xml:"Owner>ID"`
+		Buckets []bucket `xml:"Buckets>Bucket"`
+	}{XMLNS: s3Namespace, Owner: service.TenantFromContext(r.Context())}
+	for _, name := range storage.StorageTypes() {
+		result.Buckets = append(result.Buckets, bucket{Name: name, CreationDate: time.Unix(0, 0).UTC().Format(time.RFC3339)})
+	}
+	s3WriteXML(w, result)
+}
+
+// bucketExists answers for a bucket name, failing the request when there is
+// no such storage type
+func (s *S3Server) bucketExists(w http.ResponseWriter, r *http.Request) bool {
+	if !slices.Contains(storage.StorageTypes(), r.PathValue("bucket")) {
+		s3Error(w, r, http.StatusNotFound, "NoSuchBucket", "The specified bucket does not exist")
+		return false
+	}
+	return true
+}
+
+// createBucket accepts buckets that already exist, as every storage type
+// does; new ones can't be created
+func (s *S3Server) createBucket(w http.ResponseWriter, r *http.Request) {
+	if !slices.Contains(storage.StorageTypes(), r.PathValue("bucket")) {
+		s3Error(w, r, http.StatusNotImplemented, "NotImplemented", "Buckets are the storage types "+strings.Join(storage.StorageTypes(), ", "))
+		return
+	}
+	w.Header().Set("Location", "/"+r.PathValue("bucket"))
+	w.WriteHeader(http.StatusOK)
+}
+
+func (s *S3Server) deleteBucket(w http.ResponseWriter, r *http.Request) {
+	if s.bucketExists(w, r) {
+		s3Error(w, r, http.StatusConflict, "BucketNotEmpty", "Buckets are storage types and can't be deleted")
+	}
+}
+
+func (s *S3Server) getBucket(w http.ResponseWriter, r *http.Request) {
+	if !s.bucketExists(w, r) {
+		return
+	}
+	query := r.URL.Query()
+	switch {
+	case query.Has("location"):
+		s3WriteXML(w, struct {
+			XMLName xml.Name `xml:"LocationConstraint"`
+			XMLNS   string   `xml:"xmlns,attr"`
+		}{XMLNS: s3Namespace})
+	case query.Has("versioning"):
+		s3WriteXML(w, struct {
+			XMLName xml.Name `xml:"VersioningConfiguration"`
+			XMLNS   string   `xml:"xmlns,attr"`
+		}{XMLNS: s3Namespace})
+	case r.Method == http.MethodHead:
+		w.Header().Set("X-Amz-Bucket-Region", s3Region)
+		w.WriteHeader(http.StatusOK)
+	default:
+		s.listObjects(w, r)
+	}
+}
+
+type s3Object struct {
+	Key          string `xml:"Key"`
+	LastModified string `xml:"LastModified"`
+	ETag         string `xml:"ETag"`
+	Size         int    `xml:"Size"`
+	StorageClass string `xml:"StorageClass"`
+}
+
+type s3CommonPrefix struct {
+	Prefix string `xml:"Prefix"`
+}
+
+type s3ListResult struct {
+	XMLName               xml.Name         `xml:"ListBucketResult"`
+	XMLNS                 string           `xml:"xmlns,attr"`
+	Name                  string           `xml:"Name"`
+	Prefix                string           `xml:"Prefix"`
+	Delimiter             string           `xml:"Delimiter,omitempty"`
+	EncodingType          string           `xml:"EncodingType,omitempty"`
+	MaxKeys               int              `xml:"MaxKeys"`
+	IsTruncated           bool             `xml:"IsTruncated"`
+	KeyCount              int              `xml:"KeyCount,omitempty"`
+	Marker                *string          `xml:"Marker"`
+	NextMarker            string           `xml:"NextMarker,omitempty"`
+	StartAfter            string           `xml:"StartAfter,omitempty"`
+	ContinuationToken     string           `xml:"ContinuationToken,omitempty"`
+	NextContinuationToken string           `xml:"NextContinuationToken,omitempty"`
+	Contents              []s3Object       `xml:"Contents"`
+	CommonPrefixes        []s3CommonPrefix `xml:"CommonPrefixes"`
+}
+
+// listObjects answers ListObjectsV2, or the original ListObjects without
+// list-type=2. Keys sharing a prefix up to the delimiter are rolled up into
+// common prefixes, and pages resume after the last key or prefix returned.
+// Every item is read to list a bucket, which suits the modest buckets this
+// facade is meant for.
+func (s *S3Server) listObjects(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	v2 := query.Get("list-type") == "2"
+	prefix, delimiter := query.Get("prefix"), query.Get("delimiter")
+	maxKeys := s3MaxListKeys
+	if value := query.Get("max-keys"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 0 {
+			s3Error(w, r, http.StatusBadRequest, "InvalidArgument", "max-keys must be a non-negative integer")
+			return
+		}
+		maxKeys = min(n, s3MaxListKeys)
+	}
+	after := query.Get("marker")
+	if v2 {
+		after = query.Get("start-after")
+		if token := query.Get("continuation-token"); token != "" {
+			decoded, err := base64.RawURLEncoding.DecodeString(token)
+			if err != nil {
+				s3Error(w, r, http.StatusBadRequest, "InvalidArgument", "The continuation token is not valid")
+				return
+			}
+			after = string(decoded)
+		}
+	}
+
+	items, err := s.service.FindItems(r.Context(), r.PathValue("bucket"), service.ItemFilter{})
+	if err != nil {
+		s3ServiceError(w, r, err)
+		return
+	}
+	objects := make(map[string]service.ItemSummary, len(items))
+	keys := make([]string, 0, len(items))
+	// Resuming after a common prefix skips every key under it
+	skipPrefix := ""
+	if delimiter != "" && strings.HasPrefix(after, prefix) && strings.HasSuffix(after, delimiter) {
+		skipPrefix = after
+	}
+	for _, item := range items {
+		key, ok := item.Labels[s3KeyLabel]
+		if skipPrefix != "" && strings.HasPrefix(key, skipPrefix) {
+			continue
+		}
+		if ok && strings.HasPrefix(key, prefix) && key > after {
+			objects[key] = item
+			keys = append(keys, key)
+		}
+	}
+	slices.Sort(keys)
+
+	encode := func(s string) string { return s }
+	result := s3ListResult{
+		XMLNS:        s3Namespace,
+		Name:         r.PathValue("bucket"),
+		Prefix:       prefix,
+		Delimiter:    delimiter,
+		EncodingType: query.Get("encoding-type"),
+		MaxKeys:      maxKeys,
+	}
+	if result.EncodingType == "url" {
+		encode = url.QueryEscape
+		result.Prefix = encode(prefix)
+		result.Delimiter = encode(delimiter)
+	}
+	last := ""
+	for _, key := range keys {
+		commonPrefix := ""
+		if delimiter != "" {
+			if i := strings.Index(key[len(prefix):], delimiter); i >= 0 {
+				commonPrefix = key[:len(prefix)+i+len(delimiter)]
+			}
+		}
+		if commonPrefix != "" && commonPrefix == last {
+			continue
+		}
+		if len(result.Contents)+len(result.CommonPrefixes) == maxKeys {
+			result.IsTruncated = true
+			break
+		}
+		if commonPrefix != "" {
+			result.CommonPrefixes = append(result.CommonPrefixes, s3CommonPrefix{Prefix: encode(commonPrefix)})
+			last = commonPrefix
+			continue
+		}
+		item := objects[key]
+		result.Contents = append(result.Contents, s3Object{
+			Key:          encode(key),
+			LastModified: item.ModifiedAt.Format(time.RFC3339),
+			ETag:         `"` + item.Labels[s3ETagLabel] + `"`,
+			Size:         item.Size,
+			StorageClass: s3StorageClass,
+		})
+		last = key
+	}
+	if v2 {
+		result.KeyCount = len(result.Contents) + len(result.CommonPrefixes)
+		result.StartAfter = query.Get("start-after")
+		result.ContinuationToken = query.Get("continuation-token")
+		if result.IsTruncated {
+			result.NextContinuationToken = base64.RawURLEncoding.EncodeToString([]byte(last))
+		}
+	} else {
+		marker := query.Get("marker")
+		result.Marker = &marker
+		if result.IsTruncated && delimiter != "" {
+			result.NextMarker = encode(last)
+		}
+	}
+	s3WriteXML(w, result)
+}
+
+// findObject loads the summary of an object, failing the request when the
+// item isn't one stored under key
+func (s *S3Server) findObject(w http.ResponseWriter, r *http.Request, bucket, key string) (*service.ItemSummary, bool) {
+	summary, err := s.service.DescribeItem(r.Context(), bucket, s3ObjectID(key))
+	if err == nil && summary.Labels[s3KeyLabel] != key {
+		err = storage.ErrNotFound
+	}
+	if err != nil {
+		s3ServiceError(w, r, err)
+		return nil, false
+	}
+	return summary, true
+}
+
+// getObject serves GET and HEAD, including range and conditional requests
+func (s *S3Server) getObject(w http.ResponseWriter, r *http.Request) {
+	bucket, key := r.PathValue("bucket"), r.PathValue("key")
+	if key == "" {
+		s.getBucket(w, r)
+		return
+	}
+	if !s.bucketExists(w, r) {
+		return
+	}
+	summary, ok := s.findObject(w, r, bucket, key)
+	if !ok {
+		return
+	}
+	content := io.ReadSeeker(bytes.NewReader(nil))
+	if r.Method != http.MethodHead {
+		item, err := s.service.GetData(r.Context(), bucket, summary.ID)
+		if err != nil {
+			s3ServiceError(w, r, err)
+			return
+		}
+		content = bytes.NewReader(item.Data)
+	}
+	w.Header().Set("ETag", `"`+summary.Labels[s3ETagLabel]+`"`)
+	w.Header().Set("Content-Type", summary.ContentType)
+	if r.Method == http.MethodHead {
+		w.Header().Set("Last-Modified", summary.ModifiedAt.Format(http.TimeFormat))
+		w.Header().Set("Content-Length", strconv.Itoa(summary.Size))
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+	http.ServeContent(w, r, "", summary.ModifiedAt, content)
+}
+
+func (s *S3Server) putObject(w http.ResponseWriter, r *http.Request) {
+	bucket, key := r.PathValue("bucket"), r.PathValue("key")
+	if key == "" {
+		s.createBucket(w, r)
+		return
+	}
+	if !s.bucketExists(w, r) {
+		return
+	}
+	if len(key) > service.MaxLabelValueSize {
+		s3Error(w, r, http.StatusBadRequest, "KeyTooLongError", fmt.Sprintf("Keys are limited to %d bytes", service.MaxLabelValueSize))
+		return
+	}
+
+	req := &service.SaveRequest{StorageType: bucket, ContentType: r.Header.Get("Content-Type")}
+	if source := r.Header.Get("X-Amz-Copy-Source"); source != "" {
+		if !s.copySource(w, r, source, req) {
+			return
+		}
+	} else {
+		data, ok := s.readObject(w, r)
+		if !ok {
+			return
+		}
+		req.Data = data
+		if err := service.ChecksumsFromHeaders(r, req); err != nil {
+			s3Error(w, r, http.StatusBadRequest, "InvalidDigest", err.Error())
+			return
+		}
+	}
+	if req.ContentType == "binary/octet-stream" {
+		req.ContentType = "application/octet-stream"
+	}
+	sum := md5.Sum(req.Data)
+	etag := hex.EncodeToString(sum[:])
+	req.Labels = map[string]string{s3KeyLabel: key, s3ETagLabel: etag}
+
+	if err := s.service.PutData(r.Context(), s3ObjectID(key), req); err != nil && !errors.Is(err, service.ErrSpooled) {
+		s3ServiceError(w, r, err)
+		return
+	}
+	w.Header().Set("ETag", `"`+etag+`"`)
+	if r.Header.Get("X-Amz-Copy-Source") != "" {
+		s3WriteXML(w, struct {
+			XMLName      xml.Name `xml:"CopyObjectResult"`
+			XMLNS        string   `xml:"xmlns,attr"`
+			LastModified string   `xml:"LastModified"`
+			ETag         string   `xml:"ETag"`
+		}{XMLNS: s3Namespace, LastModified: time.Now().UTC().Format(time.RFC3339), ETag: `"` + etag + `"`})
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+// readObject reads an upload, decoding the aws-chunked framing clients use
+// for unsigned streaming payloads. Signed streaming payloads aren't
+// supported; clients fall back to signing the whole payload.
+func (s *S3Server) readObject(w http.ResponseWriter, r *http.Request) ([]byte, bool) {
+	body := io.Reader(http.MaxBytesReader(w, r.Body, s.config.MaxObjectBytes))
+	switch payload := r.Header.Get("X-Amz-Content-Sha256"); {
+	case payload == sigV4StreamingUnsignedPayload:
+		body = newAWSChunkedReader(body)
+	case strings.HasPrefix(payload, "STREAMING-"):
+		s3Error(w, r, http.StatusNotImplemented, "NotImplemented", "Signed streaming payloads are not supported")
+		return nil, false
+	}
+	data, err := io.ReadAll(body)
+	var tooLarge *http.MaxBytesError
+	switch {
+	case errors.As(err, &tooLarge):
+		s3Error(w, r, http.StatusBadRequest, "EntityTooLarge", fmt.Sprintf("Objects are limited to %d bytes", s.config.MaxObjectBytes))
+		return nil, false
+	case errors.Is(err, ErrPayloadHashMismatch):
+		s3Error(w, r, http.StatusBadRequest, "XAmzContentSHA256Mismatch", err.Error())
+		return nil, false
+	case err != nil:
+		s3Error(w, r, http.StatusBadRequest, "IncompleteBody", err.Error())
+		return nil, false
+	}
+	return data, true
+}
+
+// copySource loads the object named by X-Amz-Copy-Source into req. Its
+// content type carries over unless the copy replaces it.
+func (s *S3Server) copySource(w http.ResponseWriter, r *http.Request, source string, req *service.SaveRequest) bool {
+	source, err := url.PathUnescape(source)
+	bucket, key, _ := strings.Cut(strings.TrimPrefix(source, "/"), "/")
+	if err != nil || key == "" {
+		s3Error(w, r, http.StatusBadRequest, "InvalidArgument", "Copy Source must name a bucket and key")
+		return false
+	}
+	if !slices.Contains(storage.StorageTypes(), bucket) {
+		s3Error(w, r, http.StatusNotFound, "NoSuchBucket", "The specified bucket does not exist")
+		return false
+	}
+	summary, ok := s.findObject(w, r, bucket, key)
+	if !ok {
+		return false
+	}
+	item, err := s.service.GetData(r.Context(), bucket, summary.ID)
+	if err != nil {
+		s3ServiceError(w, r, err)
+		return false
+	}
+	req.Data = item.Data
+	if r.Header.Get("X-Amz-Metadata-Directive") != "REPLACE" {
+		req.ContentType = item.ContentType
+	}
+	return true
+}
+
+// deleteObject succeeds whether or not the object exists, as S3 does
+func (s *S3Server) deleteObject(w http.ResponseWriter, r *http.Request) {
+	bucket, key := r.PathValue("bucket"), r.PathValue("key")
+	if key == "" {
+		s.deleteBucket(w, r)
+		return
+	}
+	if !s.bucketExists(w, r) {
+		return
+	}
+	summary, err := s.service.DescribeItem(r.Context(), bucket, s3ObjectID(key))
+	if err == nil && summary.Labels[s3KeyLabel] == key {
+		err = s.service.DeleteData(r.Context(), bucket, summary.ID)
+	}
+	if err != nil && !errors.Is(err, storage.ErrNotFound) {
+		s3ServiceError(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func s3WriteXML(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/xml")
+	io.WriteString(w, xml.Header)
+	if err := xml.NewEncoder(w).Encode(v); err != nil {
+		logging.Printf("Failed to write S3 response: %v", err)
+	}
+}
+
+// s3Error writes an error in the shape S3 clients parse
+func s3Error(w http.ResponseWriter, r *http.Request, status int, code, message string) {
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(status)
+	if r.Method == http.MethodHead {
+		return
+	}
+	io.WriteString(w, xml.Header)
+	xml.NewEncoder(w).Encode(struct {
+		XMLName   xml.Name `xml:"Error"`
+		Code      string   `xml:"Code"`
+		Message   string   `xml:"Message"`
+		Resource  string   `xml:"Resource"`
+		RequestID string   `xml:"RequestId"`
+	}{Code: code, Message: message, Resource: r.URL.Path, RequestID: service.RequestIDFromContext(r.Context())})
+}
+
+// s3ServiceError maps a DataService error onto an S3 error code
+func s3ServiceError(w http.ResponseWriter, r *http.Request, err error) {
+	status, code := statusForError(err), "InternalError"
+	switch {
+	case errors.Is(err, service.ErrChecksumMismatch):
+		code = "BadDigest"
+	case status == http.StatusBadRequest:
+		code = "InvalidArgument"
+	case status == http.StatusForbidden:
+		code = "AccessDenied"
+	case status == http.StatusNotFound:
+		code = "NoSuchKey"
+	case status == http.StatusConflict:
+		code = "OperationAborted"
+	case status == http.StatusTooManyRequests, status == http.StatusServiceUnavailable:
+		// S3 clients back off and retry on SlowDown
+		status, code = http.StatusServiceUnavailable, "SlowDown"
+	case status == http.StatusInsufficientStorage:
+		code = "QuotaExceeded"
+	}
+	if status == http.StatusInternalServerError {
+		logging.Printf("S3 %s %s failed: %v", r.Method, r.URL.Path, err)
+	}
+	s3Error(w, r, status, code, err.Error())
+}
+
+// awsChunkedReader decodes an aws-chunked body: hex-sized chunks, each
+// followed by CRLF, ending with a zero-size chunk and optional trailers
+type awsChunkedReader struct {
+	reader    *bufio.Reader
+	remaining int64
+	done      bool
+}
+
+func newAWSChunkedReader(r io.Reader) *awsChunkedReader {
+	return &awsChunkedReader{reader: bufio.NewReader(r)}
+}
+
+func (c *awsChunkedReader) Read(p []byte) (int, error) {
+	for c.remaining == 0 {
+		if c.done {
+			return 0, io.EOF
+		}
+		line, err := c.reader.ReadString('\n')
+		if err != nil {
+			return 0, fmt.Errorf("reading chunk header: %w", err)
+		}
+		sizeField, _, _ := strings.Cut(strings.TrimSpace(line), ";")
+		size, err := strconv.ParseInt(sizeField, 16, 64)
+		if err != nil || size < 0 {
+			return 0, fmt.Errorf("invalid chunk size %q", sizeField)
+		}
+		if size == 0 {
+			// Trailing checksums aren't verified
+			c.done = true
+			continue
+		}
+		c.remaining = size
+	}
+	n, err := c.reader.Read(p[:min(int64(len(p)), c.remaining)])
+	c.remaining -= int64(n)
+	if c.remaining == 0 && err == nil {
+		if _, err := c.reader.Discard(2); err != nil {
+			return n, fmt.Errorf("reading chunk end: %w", err)
+		}
+	}
+	if errors.Is(err, io.EOF) {
+		err = io.ErrUnexpectedEOF
+	}
+	return n, err
+}
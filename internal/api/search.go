@@ -0,0 +1,59 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"interview-task/internal/service"
+)
+
+// HandleSearch ranks items against ?q=, most relevant first. Results are
+// narrowed by ?field.<name>=value (repeatable) and the ?label=, ?source=
+// and ?content_type= filters of HandleListData; ?content=true also searches
+// the payloads of text and JSON items. Pages are chosen with ?limit= and
+// ?offset=.
+func (h *HTTPHandler) HandleSearch(w http.ResponseWriter, r *http.Request) {
+	params := r.URL.Query()
+	filter, err := itemFilterFromQuery(params)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	query := service.SearchQuery{Text: params.Get("q"), Filter: filter, Limit: service.DefaultSearchLimit}
+	for name, values := range params {
+		field, ok := strings.CutPrefix(name, "field.")
+		if !ok || field == "" {
+			continue
+		}
+		if query.Fields == nil {
+			query.Fields = make(map[string]string)
+		}
+		query.Fields[field] = values[0]
+	}
+	if v := params.Get("content"); v != "" {
+		if query.Content, err = strconv.ParseBool(v); err != nil {
+			http.Error(w, "invalid content", http.StatusBadRequest)
+			return
+		}
+	}
+	if v := params.Get("limit"); v != "" {
+		if query.Limit, err = strconv.Atoi(v); err != nil {
+			http.Error(w, "invalid limit", http.StatusBadRequest)
+			return
+		}
+	}
+	if v := params.Get("offset"); v != "" {
+		if query.Offset, err = strconv.Atoi(v); err != nil {
+			http.Error(w, "invalid offset", http.StatusBadRequest)
+			return
+		}
+	}
+
+	result, err := h.dataService.Search(r.Context(), params.Get("storage_type"), query)
+	if err != nil {
+		http.Error(w, err.Error(), statusForError(err))
+		return
+	}
+	h.respond(w, r, http.StatusOK, result)
+}
@@ -0,0 +1,146 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// sentryClient identifies the service to Sentry
+const sentryClient = "interview-task/1.0"
+
+// sentryReporter sends events to Sentry's envelope endpoint, as described
+// at https://develop.sentry.dev/sdk/envelopes/
+type sentryReporter struct {
+	dsn      string
+	endpoint string
+	key      string
+	client   *http.Client
+}
+
+// sentrySettings is the settings section of the sentry reporter
+type sentrySettings struct {
+	// DSN is the project's client key URL,
+	// https://<key>@<host>/<project>
+	DSN string `json:"dsn"`
+}
+
+func newSentryReporter(raw json.RawMessage) (ErrorReporter, error) {
+	var settings sentrySettings
+	if len(raw) > 0 {
+		if err := json.Unmarshal(raw, &settings); err != nil {
+			return nil, fmt.Errorf("invalid settings: %w", err)
+		}
+	}
+	dsn, err := url.Parse(settings.DSN)
+	if err != nil || dsn.User == nil || dsn.User.Username() == "" || dsn.Host == "" {
+		return nil, errors.New("dsn must be https://<key>@<host>/<project>")
+	}
+	// Sentry instances may be served under a path, which precedes the
+	// project ID
+	prefix, project := "", strings.TrimPrefix(dsn.Path, "/")
+	if i := strings.LastIndex(project, "/"); i >= 0 {
+		prefix, project = "/"+project[:i], project[i+1:]
+	}
+	if project == "" {
+		return nil, errors.New("dsn has no project ID")
+	}
+	return &sentryReporter{
+		dsn:      settings.DSN,
+		endpoint: fmt.Sprintf("%s://%s%s/api/%s/envelope/", dsn.Scheme, dsn.Host, prefix, project),
+		key:      dsn.User.Username(),
+		client:   &http.Client{},
+	}, nil
+}
+
+// sentryEvent is the subset of Sentry's event payload the service fills in
+type sentryEvent struct {
+	EventID     string            `json:"event_id"`
+	Timestamp   string            `json:"timestamp"`
+	Platform    string            `json:"platform"`
+	Level       string            `json:"level"`
+	Logger      string            `json:"logger"`
+	Message     string            `json:"message"`
+	Environment string            `json:"environment,omitempty"`
+	Release     string            `json:"release,omitempty"`
+	Transaction string            `json:"transaction,omitempty"`
+	Tags        map[string]string `json:"tags"`
+	User        sentryUser        `json:"user"`
+	Request     sentryRequest     `json:"request"`
+	Contexts    map[string]any    `json:"contexts,omitempty"`
+	Extra       map[string]string `json:"extra,omitempty"`
+}
+
+type sentryUser struct {
+	ID        string `json:"id,omitempty"`
+	IPAddress string `json:"ip_address,omitempty"`
+}
+
+type sentryRequest struct {
+	Method  string            `json:"method"`
+	URL     string            `json:"url"`
+	Headers map[string]string `json:"headers,omitempty"`
+}
+
+func (s *sentryReporter) Report(ctx context.Context, event *ErrorEvent) error {
+	level := "error"
+	if event.Kind == "panic" {
+		level = "fatal"
+	}
+	payload := sentryEvent{
+		EventID:     event.ID,
+		Timestamp:   event.Time.Format(time.RFC3339Nano),
+		Platform:    "go",
+		Level:       level,
+		Logger:      "http",
+		Message:     event.Message,
+		Environment: event.Environment,
+		Release:     event.Release,
+		Transaction: event.Route,
+		Tags: map[string]string{
+			"kind":   event.Kind,
+			"status": fmt.Sprint(event.Status),
+			"tenant": event.Tenant,
+		},
+		User:    sentryUser{ID: event.Principal, IPAddress: event.ClientIP},
+		Request: sentryRequest{Method: event.Method, URL: event.Path},
+		Extra:   map[string]string{"request_id": event.RequestID},
+	}
+	if event.UserAgent != "" {
+		payload.Request.Headers = map[string]string{"User-Agent": event.UserAgent}
+	}
+	if event.TraceID != "" {
+		payload.Contexts = map[string]any{"trace": map[string]string{"trace_id": event.TraceID}}
+	}
+	if event.Stack != "" {
+		payload.Extra["stack"] = event.Stack
+	}
+
+	item, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	header, err := json.Marshal(map[string]string{"event_id": event.ID, "dsn": s.dsn, "sent_at": time.Now().UTC().Format(time.RFC3339Nano)})
+	if err != nil {
+		return err
+	}
+	var envelope bytes.Buffer
+	envelope.Write(header)
+	fmt.Fprintf(&envelope, "\n{\"type\":\"event\",\"length\":%d}\n", len(item))
+	envelope.Write(item)
+	envelope.WriteByte('\n')
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.endpoint, &envelope)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-sentry-envelope")
+	req.Header.Set("X-Sentry-Auth", fmt.Sprintf("Sentry sentry_version=7, sentry_key=%s, sentry_client=%s", s.key, sentryClient))
+	return sendReport(s.client, req)
+}
@@ -0,0 +1,178 @@
+package api
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"interview-task/internal/service"
+)
+
+// AWS Signature Version 4, which S3 clients sign requests with
+const (
+	sigV4Algorithm  = "AWS4-HMAC-SHA256"
+	sigV4TimeFormat = "20060102T150405Z"
+	// sigV4MaxSkew is how far a signature's time may be from the server's
+	sigV4MaxSkew = 15 * time.Minute
+	// sigV4StreamingUnsignedPayload is the X-Amz-Content-Sha256 of unsigned
+	// aws-chunked uploads
+	sigV4StreamingUnsignedPayload = "STREAMING-UNSIGNED-PAYLOAD-TRAILER"
+)
+
+// ErrPayloadHashMismatch is returned when reading a signed body that
+// doesn't match the hash it was signed with
+var ErrPayloadHashMismatch = errors.New("payload does not match X-Amz-Content-Sha256")
+
+// sigV4Credential is an API key used as an AWS secret access key. Its
+// access key ID is the key's name.
+type sigV4Credential struct {
+	secret    string
+	principal *service.Principal
+}
+
+// isSigV4 reports whether a request is signed with Signature Version 4
+func isSigV4(r *http.Request) bool {
+	return strings.HasPrefix(r.Header.Get("Authorization"), sigV4Algorithm+" ")
+}
+
+// verifySigV4 authenticates a request signed in its Authorization header.
+// A body signed by its hash is verified as it is read: reading it fails
+// with ErrPayloadHashMismatch at the end if it was altered.
+func verifySigV4(r *http.Request, credentials map[string]*sigV4Credential, now time.Time) (*service.Principal, error) {
+	params := make(map[string]string)
+	for _, part := range strings.Split(strings.TrimPrefix(r.Header.Get("Authorization"), sigV4Algorithm+" "), ",") {
+		key, value, _ := strings.Cut(strings.TrimSpace(part), "=")
+		params[key] = value
+	}
+	scope := strings.Split(params["Credential"], "/")
+	if len(scope) != 5 || scope[4] != "aws4_request" || params["SignedHeaders"] == "" || params["Signature"] == "" {
+		return nil, fmt.Errorf("malformed authorization header")
+	}
+	credential, ok := credentials[scope[0]]
+	if !ok {
+		return nil, fmt.Errorf("unknown access key")
+	}
+
+	amzDate := r.Header.Get("X-Amz-Date")
+	signedAt, err := time.Parse(sigV4TimeFormat, amzDate)
+	if err != nil || !strings.HasPrefix(amzDate, scope[1]) {
+		return nil, fmt.Errorf("missing or invalid X-Amz-Date")
+	}
+	if skew := now.Sub(signedAt); skew > sigV4MaxSkew || skew < -sigV4MaxSkew {
+		return nil, fmt.Errorf("request time is too far from the server's")
+	}
+	signedHeaders := strings.Split(params["SignedHeaders"], ";")
+	if !slices.Contains(signedHeaders, "host") {
+		return nil, fmt.Errorf("the host header must be signed")
+	}
+	payloadHash := r.Header.Get("X-Amz-Content-Sha256")
+	if payloadHash == "" {
+		return nil, fmt.Errorf("missing X-Amz-Content-Sha256")
+	}
+
+	canonical := strings.Join([]string{
+		r.Method,
+		awsURIEncode(r.URL.Path, false),
+		canonicalQuery(r.URL.Query()),
+		canonicalHeaders(r, signedHeaders),
+		params["SignedHeaders"],
+		payloadHash,
+	}, "\n")
+	canonicalHash := sha256.Sum256([]byte(canonical))
+	stringToSign := sigV4Algorithm + "\n" + amzDate + "\n" + strings.Join(scope[1:], "/") + "\n" + hex.EncodeToString(canonicalHash[:])
+
+	key := []byte("AWS4" + credential.secret)
+	for _, part := range scope[1:] {
+		key = hmacSHA256(key, part)
+	}
+	expected := hex.EncodeToString(hmacSHA256(key, stringToSign))
+	if !hmac.Equal([]byte(expected), []byte(params["Signature"])) {
+		return nil, fmt.Errorf("signature does not match")
+	}
+
+	if sum, err := hex.DecodeString(payloadHash); err == nil && len(sum) == sha256.Size {
+		r.Body = &hashVerifyingReader{reader: r.Body, hash: sha256.New(), expected: sum}
+	}
+	return credential.principal, nil
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// canonicalHeaders lists the signed headers as "name:value" lines
+func canonicalHeaders(r *http.Request, names []string) string {
+	var b strings.Builder
+	for _, name := range names {
+		values := r.Header.Values(name)
+		if name == "host" {
+			values = []string{r.Host}
+		}
+		for i, value := range values {
+			values[i] = strings.Join(strings.Fields(value), " ")
+		}
+		b.WriteString(name + ":" + strings.Join(values, ",") + "\n")
+	}
+	return b.String()
+}
+
+// canonicalQuery sorts and encodes query parameters as signed
+func canonicalQuery(query url.Values) string {
+	var pairs []string
+	for key, values := range query {
+		for _, value := range values {
+			pairs = append(pairs, awsURIEncode(key, true)+"="+awsURIEncode(value, true))
+		}
+	}
+	slices.Sort(pairs)
+	return strings.Join(pairs, "&")
+}
+
+// awsURIEncode percent-encodes everything but unreserved characters, and
+// slashes unless encodeSlash is set
+func awsURIEncode(s string, encodeSlash bool) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c >= 'A' && c <= 'Z', c >= 'a' && c <= 'z', c >= '0' && c <= '9',
+			c == '-', c == '_', c == '.', c == '~', c == '/' && !encodeSlash:
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+// hashVerifyingReader fails at the end of a body that doesn't hash to the
+// expected SHA-256
+type hashVerifyingReader struct {
+	reader   io.ReadCloser
+	hash     hash.Hash
+	expected []byte
+}
+
+func (h *hashVerifyingReader) Read(p []byte) (int, error) {
+	n, err := h.reader.Read(p)
+	h.hash.Write(p[:n])
+	if errors.Is(err, io.EOF) && !hmac.Equal(h.hash.Sum(nil), h.expected) {
+		return n, ErrPayloadHashMismatch
+	}
+	return n, err
+}
+
+func (h *hashVerifyingReader) Close() error {
+	return h.reader.Close()
+}
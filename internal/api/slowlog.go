@@ -0,0 +1,95 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"interview-task/internal/config"
+	"interview-task/internal/logging"
+	"interview-task/internal/service"
+)
+
+// traceparentPattern matches a W3C Trace Context header, whose second field
+// is the trace ID
+var traceparentPattern = regexp.MustCompile(`^[0-9a-f]{2}-([0-9a-f]{32})-[0-9a-f]{16}-[0-9a-f]{2}$`)
+
+// SlowLog warns about requests over a latency or size budget, to make
+// hotspots visible without tracing. It runs right before routing, so it
+// sees the matched route and times the handler alone.
+func SlowLog(config config.SlowLogConfig) Middleware {
+	return func(next http.Handler) http.Handler {
+		if config.Duration <= 0 && config.PayloadBytes <= 0 {
+			return next
+		}
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx, notes := service.WithRequestNotes(r.Context())
+			r = r.WithContext(ctx)
+			body := &countingReader{ReadCloser: r.Body}
+			r.Body = body
+			counter := &countingWriter{ResponseWriter: w}
+			recorder := &statusRecorder{ResponseWriter: counter}
+			started := time.Now()
+			next.ServeHTTP(recorder, r)
+			elapsed := time.Since(started)
+
+			var exceeded []string
+			if config.Duration > 0 && elapsed > time.Duration(config.Duration) {
+				exceeded = append(exceeded, "latency")
+			}
+			if config.PayloadBytes > 0 && max(body.n, counter.n) > config.PayloadBytes {
+				exceeded = append(exceeded, "size")
+			}
+			if len(exceeded) == 0 {
+				return
+			}
+			if recorder.status == 0 {
+				recorder.status = http.StatusOK
+			}
+			route := r.Pattern
+			if route == "" {
+				route = r.Method + " " + r.URL.Path
+			}
+			fields := []string{
+				"budget", strings.Join(exceeded, ","),
+				"request_id", service.RequestIDFromContext(ctx),
+				"trace_id", traceID(r),
+				"route", route,
+				"backend", strings.Join(notes.Backends(), ","),
+				"status", strconv.Itoa(recorder.status),
+				"duration", elapsed.Round(time.Microsecond).String(),
+				"request_bytes", strconv.FormatInt(body.n, 10),
+				"response_bytes", strconv.FormatInt(counter.n, 10),
+			}
+			logging.Printf("WARN request over budget: %s", logfmt(fields))
+		})
+	}
+}
+
+// traceID returns the trace ID of a W3C traceparent header, if any
+func traceID(r *http.Request) string {
+	if match := traceparentPattern.FindStringSubmatch(r.Header.Get("traceparent")); match != nil {
+		return match[1]
+	}
+	return ""
+}
+
+// logfmt formats key/value pairs as key=value, quoting values that need
+// it and leaving out empty ones
+func logfmt(fields []string) string {
+	pairs := make([]string, 0, len(fields)/2)
+	for i := 0; i+1 < len(fields); i += 2 {
+		value := fields[i+1]
+		if value == "" {
+			continue
+		}
+		if quoted := strconv.Quote(value); strings.ContainsAny(value, " =") || quoted[1:len(quoted)-1] != value {
+			value = quoted
+		}
+		pairs = append(pairs, fmt.Sprintf("%s=%s", fields[i], value))
+	}
+	return strings.Join(pairs, " ")
+}
@@ -0,0 +1,77 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"interview-task/internal/service"
+)
+
+// SnapshotHandler exposes snapshots to administrators. They cover every
+// tenant, so keys bound to a single tenant may not use them.
+type SnapshotHandler struct {
+	snapshots *service.SnapshotManager
+}
+
+func NewSnapshotHandler(snapshots *service.SnapshotManager) *SnapshotHandler {
+	return &SnapshotHandler{snapshots: snapshots}
+}
+
+// snapshotRequest is the body of POST /admin/snapshots
+type snapshotRequest struct {
+	Name string `json:"name"`
+}
+
+func (h *SnapshotHandler) HandleCreate(w http.ResponseWriter, r *http.Request) {
+	if !requireGlobalKey(w, r) {
+		return
+	}
+	var req snapshotRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON format", http.StatusBadRequest)
+		return
+	}
+	snapshot, err := h.snapshots.Create(r.Context(), req.Name)
+	if err != nil {
+		http.Error(w, err.Error(), statusForError(err))
+		return
+	}
+	writeJSON(w, http.StatusCreated, snapshot)
+}
+
+func (h *SnapshotHandler) HandleList(w http.ResponseWriter, r *http.Request) {
+	if !requireGlobalKey(w, r) {
+		return
+	}
+	snapshots, err := h.snapshots.List()
+	if err != nil {
+		http.Error(w, err.Error(), statusForError(err))
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"snapshots": snapshots})
+}
+
+func (h *SnapshotHandler) HandleDelete(w http.ResponseWriter, r *http.Request) {
+	if !requireGlobalKey(w, r) {
+		return
+	}
+	if err := h.snapshots.Delete(r.PathValue("name")); err != nil {
+		http.Error(w, err.Error(), statusForError(err))
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// HandleRestore returns the service to a snapshot, which needs maintenance
+// mode
+func (h *SnapshotHandler) HandleRestore(w http.ResponseWriter, r *http.Request) {
+	if !requireGlobalKey(w, r) {
+		return
+	}
+	report, err := h.snapshots.Restore(r.Context(), r.PathValue("name"))
+	if err != nil {
+		writeJSON(w, statusForError(err), report)
+		return
+	}
+	writeJSON(w, http.StatusOK, report)
+}
@@ -0,0 +1,87 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+
+	"interview-task/internal/logging"
+	"interview-task/internal/metrics"
+)
+
+// StatusSection reads one section of /stats or the admin dashboard
+type StatusSection func(ctx context.Context) (any, error)
+
+// readSections adds every section to data. One that fails reports its
+// error and leaves the others intact.
+func readSections(ctx context.Context, sections map[string]StatusSection, data map[string]any) {
+	for name, section := range sections {
+		value, err := section(ctx)
+		if err != nil {
+			logging.Printf("Status section %s failed: %v", name, err)
+			value = map[string]string{"error": err.Error()}
+		}
+		data[name] = value
+	}
+}
+
+// StatsHandler serves /stats, a JSON snapshot of the server's state for
+// operators without a Prometheus to scrape /metrics
+type StatsHandler struct {
+	started  time.Time
+	sections map[string]StatusSection
+}
+
+func NewStatsHandler(sections map[string]StatusSection) *StatsHandler {
+	return &StatsHandler{started: time.Now(), sections: sections}
+}
+
+func (h *StatsHandler) HandleStats(w http.ResponseWriter, r *http.Request) {
+	data := map[string]any{
+		"started_at":     h.started.UTC(),
+		"uptime_seconds": int64(time.Since(h.started).Seconds()),
+	}
+	readSections(r.Context(), h.sections, data)
+	writeJSON(w, http.StatusOK, data)
+}
+
+// CountRequests counts responses by status code in http_requests_total
+func CountRequests(metrics *metrics.Metrics) Middleware {
+	metrics.Describe("http_requests_total", "HTTP responses by status code")
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			recorder := &statusRecorder{ResponseWriter: w}
+			next.ServeHTTP(recorder, r)
+			if recorder.status == 0 {
+				recorder.status = http.StatusOK
+			}
+			metrics.Add("http_requests_total", 1, "code", strconv.Itoa(recorder.status))
+		})
+	}
+}
+
+// statusRecorder notes the status of a response passing through it
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+// Unwrap gives http.ResponseController access to the connection's writer
+func (s *statusRecorder) Unwrap() http.ResponseWriter {
+	return s.ResponseWriter
+}
+
+func (s *statusRecorder) WriteHeader(status int) {
+	if s.status == 0 {
+		s.status = status
+	}
+	s.ResponseWriter.WriteHeader(status)
+}
+
+func (s *statusRecorder) Write(data []byte) (int, error) {
+	if s.status == 0 {
+		s.status = http.StatusOK
+	}
+	return s.ResponseWriter.Write(data)
+}
@@ -0,0 +1,76 @@
+package api
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+
+	"interview-task/internal/service"
+)
+
+// TenantResolver - IMPLEMENTS tenant resolution as middleware. The tenant is
+// taken from a /tenants/{tenant}/ path prefix, then the tenant header, then
+// the tenant bound to the caller's API key.
+type TenantResolver struct {
+	header  string
+	require bool
+}
+
+func NewTenantResolver(header string, require bool) *TenantResolver {
+	return &TenantResolver{header: header, require: require}
+}
+
+func (t *TenantResolver) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tenant := ""
+		if rest, ok := strings.CutPrefix(r.URL.Path, "/tenants/"); ok {
+			var path string
+			tenant, path, _ = strings.Cut(rest, "/")
+			r = stripPathPrefix(r, "/"+path)
+		}
+		if tenant == "" && t.header != "" {
+			tenant = r.Header.Get(t.header)
+		}
+
+		bound := service.PrincipalFromContext(r.Context()).Tenant
+		switch {
+		case tenant == "" && bound != "":
+			tenant = bound
+		case bound != "" && tenant != bound:
+			http.Error(w, "Forbidden: key is bound to another tenant", http.StatusForbidden)
+			return
+		case tenant == "" && t.require:
+			http.Error(w, "tenant is required", http.StatusBadRequest)
+			return
+		case tenant == "":
+			tenant = service.DefaultTenant
+		}
+
+		if err := service.ValidateTenant(tenant); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(service.WithTenant(r.Context(), tenant)))
+	})
+}
+
+// stripPathPrefix returns a shallow copy of r addressed to path
+func stripPathPrefix(r *http.Request, path string) *http.Request {
+	r2 := new(http.Request)
+	*r2 = *r
+	r2.URL = new(url.URL)
+	*r2.URL = *r.URL
+	r2.URL.Path = path
+	r2.URL.RawPath = ""
+	return r2
+}
+
+// requireGlobalKey refuses keys bound to a tenant for operations that span
+// every tenant
+func requireGlobalKey(w http.ResponseWriter, r *http.Request) bool {
+	if service.PrincipalFromContext(r.Context()).Tenant != "" {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return false
+	}
+	return true
+}
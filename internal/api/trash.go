@@ -0,0 +1,39 @@
+package api
+
+import (
+	"net/http"
+
+	"interview-task/internal/service"
+)
+
+// TrashHandler lets administrators inspect the trash and restore items.
+// Purging needs a second admin through the purge_trash operation.
+type TrashHandler struct {
+	service *service.DataService
+}
+
+func NewTrashHandler(service *service.DataService) *TrashHandler {
+	return &TrashHandler{service: service}
+}
+
+func (h *TrashHandler) HandleList(w http.ResponseWriter, r *http.Request) {
+	entries, err := h.service.ListTrash(r.Context(), r.URL.Query().Get("storage_type"))
+	if err != nil {
+		http.Error(w, err.Error(), statusForError(err))
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"items": entries})
+}
+
+func (h *TrashHandler) HandleRestore(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	if err := h.service.RestoreItem(r.Context(), r.URL.Query().Get("storage_type"), id); err != nil {
+		http.Error(w, err.Error(), statusForError(err))
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{
+		"message": "Data restored successfully",
+		"status":  "success",
+		"id":      id,
+	})
+}
@@ -0,0 +1,14 @@
+package api
+
+import (
+	"net/http"
+)
+
+func (h *HTTPHandler) HandleVerify(w http.ResponseWriter, r *http.Request) {
+	report, err := h.dataService.VerifyItem(r.Context(), r.URL.Query().Get("storage_type"), r.PathValue("id"))
+	if err != nil {
+		http.Error(w, err.Error(), statusForError(err))
+		return
+	}
+	writeJSON(w, http.StatusOK, report)
+}
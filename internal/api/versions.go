@@ -0,0 +1,37 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"interview-task/internal/service"
+	"interview-task/internal/storage"
+)
+
+func (h *HTTPHandler) HandleUpdateData(w http.ResponseWriter, r *http.Request) {
+	var req service.SaveRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON format", http.StatusBadRequest)
+		return
+	}
+	if err := service.ChecksumsFromHeaders(r, &req); err != nil {
+		http.Error(w, err.Error(), statusForError(err))
+		return
+	}
+
+	ctx := service.WithIfMatch(service.WithEndpoint(r.Context(), r.URL.Path), r.Header.Get(service.HeaderIfMatch))
+	version, err := h.dataService.UpdateData(ctx, r.PathValue("id"), &req)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+	w.Header().Set("ETag", service.ETag(version, storage.PayloadSHA256(req.Data)))
+
+	writeJSON(w, http.StatusOK, map[string]any{
+		"message": "Data updated successfully",
+		"status":  "success",
+		"id":      r.PathValue("id"),
+		"version": version,
+		"sha256":  storage.PayloadSHA256(req.Data),
+	})
+}
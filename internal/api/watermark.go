@@ -0,0 +1,20 @@
+package api
+
+import (
+	"net/http"
+
+	"interview-task/internal/service"
+)
+
+// WatermarkHandler serves the requesting tenant's watermark
+type WatermarkHandler struct {
+	tracker *service.WatermarkTracker
+}
+
+func NewWatermarkHandler(tracker *service.WatermarkTracker) *WatermarkHandler {
+	return &WatermarkHandler{tracker: tracker}
+}
+
+func (h *WatermarkHandler) HandleGet(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, h.tracker.Get(service.TenantFromContext(r.Context())))
+}
@@ -0,0 +1,40 @@
+package config
+
+import (
+	"fmt"
+	"time"
+)
+
+// Versions of the HTTP API. Unprefixed paths are the legacy API, kept as an
+// alias of v1 while clients migrate to /v1.
+const (
+	APIVersionLegacy = "legacy"
+	APIVersionV1     = "v1"
+)
+
+// APIVersionPrefixes are the path prefixes each version is served under
+var APIVersionPrefixes = map[string]string{APIVersionLegacy: "", APIVersionV1: "/v1"}
+
+// APIVersionPolicy schedules the retirement of an API version
+type APIVersionPolicy struct {
+	// Deprecated is when clients were asked to move to the successor; zero
+	// while the version is current
+	Deprecated time.Time `json:"deprecated"`
+	// Sunset is when the version stops being served; zero keeps it
+	Sunset time.Time `json:"sunset"`
+}
+
+// APIVersionPolicies are keyed by version name
+type APIVersionPolicies map[string]APIVersionPolicy
+
+func (p APIVersionPolicies) Validate() error {
+	for name, policy := range p {
+		if _, ok := APIVersionPrefixes[name]; !ok {
+			return fmt.Errorf("unknown API version %q", name)
+		}
+		if !policy.Sunset.IsZero() && policy.Sunset.Before(policy.Deprecated) {
+			return fmt.Errorf("API version %s is sunset before it is deprecated", name)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,24 @@
+package config
+
+// APIKeyConfig describes one API key accepted by the server
+type APIKeyConfig struct {
+	Key   string   `json:"key"`
+	Name  string   `json:"name"`
+	Roles []string `json:"roles"`
+	// Tenant binds the key to a single tenant; empty allows any tenant
+	Tenant string `json:"tenant"`
+	// RateLimit caps the key's requests per second, with bursts of up to
+	// Burst; zero is unlimited
+	RateLimit float64 `json:"rate_limit"`
+	Burst     int     `json:"burst"`
+}
+
+// KeyManagementConfig lets administrators create, change and revoke API
+// keys through the admin API. Managed keys are kept in the database and
+// accepted alongside the configured ones.
+type KeyManagementConfig struct {
+	Enabled bool `json:"enabled"`
+	// RefreshInterval is how often keys changed by other instances are
+	// picked up
+	RefreshInterval Duration `json:"refresh_interval"`
+}
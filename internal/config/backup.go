@@ -0,0 +1,13 @@
+package config
+
+// BackupConfig schedules backups shipped to a secondary storage type. Each
+// backup is one archive saved as an item of Tenant, which backups leave out.
+type BackupConfig struct {
+	// Interval is how often a backup is taken; zero leaves backups to
+	// GET /admin/backup
+	Interval    Duration `json:"interval"`
+	StorageType string   `json:"storage_type"`
+	Tenant      string   `json:"tenant"`
+	// Keep is how many archives are kept, the oldest deleted first
+	Keep int `json:"keep"`
+}
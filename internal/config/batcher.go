@@ -0,0 +1,11 @@
+package config
+
+// WriteBatchingConfig groups concurrent database saves into bulk inserts.
+// A batch is flushed once it holds MaxItems or MaxBytes, or FlushInterval
+// after its first save, whichever comes first.
+type WriteBatchingConfig struct {
+	Enabled       bool     `json:"enabled"`
+	MaxItems      int      `json:"max_items"`
+	MaxBytes      int      `json:"max_bytes"`
+	FlushInterval Duration `json:"flush_interval"`
+}
@@ -0,0 +1,14 @@
+package config
+
+// CacheConfig sizes the in-memory read cache. Each server instance has its
+// own cache, so with several instances behind a load balancer TTL bounds
+// how stale a read can be.
+type CacheConfig struct {
+	Enabled bool     `json:"enabled"`
+	TTL     Duration `json:"ttl"`
+	// MaxBytes bounds the cached payloads; the least recently used ones
+	// are evicted first
+	MaxBytes int64 `json:"max_bytes"`
+	// MaxItemBytes keeps large payloads from evicting many small ones
+	MaxItemBytes int64 `json:"max_item_bytes"`
+}
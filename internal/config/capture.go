@@ -0,0 +1,15 @@
+package config
+
+// BodyCaptureConfig records a sample of request and response bodies in
+// memory, with personal data masked, for debugging malformed payloads
+type BodyCaptureConfig struct {
+	// SampleRate is the share of requests captured, from 0 to 1
+	SampleRate float64 `json:"sample_rate"`
+	// ErrorSampleRate is the share of 4xx and 5xx responses captured,
+	// typically higher. Capture is disabled while both rates are zero.
+	ErrorSampleRate float64 `json:"error_sample_rate"`
+	// MaxBodyBytes is how much of each body is kept
+	MaxBodyBytes int `json:"max_body_bytes"`
+	// Capacity is how many captures are kept, the oldest dropped first
+	Capacity int `json:"capacity"`
+}
@@ -0,0 +1,30 @@
+package config
+
+import (
+	"compress/gzip"
+	"fmt"
+)
+
+// CompressionConfig controls gzip/deflate compression of responses.
+// Compressed request bodies are always accepted.
+type CompressionConfig struct {
+	// Enabled compresses responses of every route not turned off in Routes
+	Enabled bool `json:"enabled"`
+	// Routes turns response compression on or off for the paths starting
+	// with each prefix, overriding Enabled; the longest prefix wins
+	Routes map[string]bool `json:"routes"`
+	// MinSize is the smallest response body worth compressing
+	MinSize int `json:"min_size"`
+	// Level is the compression level, 1 (fastest) to 9 (smallest)
+	Level int `json:"level"`
+	// MaxRequestBytes bounds decompressed request bodies, so a small
+	// compressed body can't expand without limit
+	MaxRequestBytes int64 `json:"max_request_bytes"`
+}
+
+func (c CompressionConfig) Validate() error {
+	if c.Level < gzip.BestSpeed || c.Level > gzip.BestCompression {
+		return fmt.Errorf("compression level must be between %d and %d", gzip.BestSpeed, gzip.BestCompression)
+	}
+	return nil
+}
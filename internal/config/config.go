@@ -0,0 +1,406 @@
+// Package config holds the server configuration, read from JSON on top of
+// the defaults, and the settings of each component.
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Configuration - IMPLEMENTS Configuration Management
+type Configuration struct {
+	Port string `json:"port"`
+	// Listen replaces Port with a Unix socket or a socket from systemd
+	Listen ListenConfig `json:"listen"`
+
+	DatabaseHost string `json:"database_host"`
+	DatabasePort int    `json:"database_port"`
+	DatabaseUser string `json:"database_user"`
+	DatabasePass string `json:"database_pass"`
+	DatabaseName string `json:"database_name"`
+	// DatabasePool sizes the pool of database connections
+	DatabasePool DatabasePoolConfig `json:"database_pool"`
+	// DatabaseAutoMigrate applies pending schema migrations at startup;
+	// without it they are applied with "server migrate"
+	DatabaseAutoMigrate bool `json:"database_auto_migrate"`
+
+	// DataDir is where file storage keeps its tenants directory. Relative
+	// paths of the files and directories the server keeps its state in, such
+	// as the audit log and quota usage, are resolved against it on load.
+	DataDir string `json:"data_dir"`
+	// FileLayout spreads each tenant's files over subdirectories
+	FileLayout FileLayout `json:"file_layout"`
+	// MinFreeDiskBytes is the free space below which file-based storage
+	// stops accepting writes; zero disables the check
+	MinFreeDiskBytes int64 `json:"min_free_disk_bytes"`
+
+	// IP rules applied before any handler runs. Denied ranges win over
+	// allowed ones; an empty allow list admits every address not denied.
+	AllowedCIDRs []string `json:"allowed_cidrs"`
+	DeniedCIDRs  []string `json:"denied_cidrs"`
+	// TrustedProxies lists the CIDRs whose ClientIPHeader is honored when
+	// resolving the client address.
+	TrustedProxies []string `json:"trusted_proxies"`
+	// ClientIPHeader names the header trusted proxies report the client in:
+	// X-Forwarded-For, Forwarded or X-Real-IP
+	ClientIPHeader string `json:"client_ip_header"`
+	// AnonymousRateLimit caps the requests per second of each client
+	// address sending no API key, with bursts of up to AnonymousBurst; zero
+	// is unlimited
+	AnonymousRateLimit float64 `json:"anonymous_rate_limit"`
+	AnonymousBurst     int     `json:"anonymous_burst"`
+
+	// APIKeys enables authentication when non-empty
+	APIKeys []APIKeyConfig `json:"api_keys"`
+
+	// TenantHeader names the header carrying the tenant ID. Requests may
+	// also address a tenant with a /tenants/{tenant}/ path prefix, and keys
+	// bound to a tenant can't act for any other.
+	TenantHeader  string `json:"tenant_header"`
+	RequireTenant bool   `json:"require_tenant"`
+
+	// AuditSink selects where data mutations are recorded: "file",
+	// "database" or "none"
+	AuditSink string `json:"audit_sink"`
+	AuditFile string `json:"audit_file"`
+
+	// JournalFile journals every save and update with its full request,
+	// so writes can be replayed; empty disables the journal
+	JournalFile string `json:"journal_file"`
+
+	// Derivations are named projections served at /data/{id}/derived/{name}
+	Derivations []DerivationConfig `json:"derivations"`
+
+	// MetadataRules extract searchable fields from JSON payloads. Changing
+	// them marks existing metadata stale until the re-index job has run.
+	MetadataRules        []MetadataRuleConfig `json:"metadata_rules"`
+	ReindexRatePerSecond int                  `json:"reindex_rate_per_second"`
+	ReindexStateFile     string               `json:"reindex_state_file"`
+
+	// Index records every item's metadata apart from its storage backend
+	Index IndexConfig `json:"index"`
+
+	// ApprovalTTL is how long a destructive admin operation waits for a
+	// second admin's approval before expiring
+	ApprovalTTL Duration `json:"approval_ttl"`
+
+	// IdempotencyWindow is how long the response to a save carrying an
+	// Idempotency-Key is replayed to retries; zero disables replays
+	IdempotencyWindow Duration `json:"idempotency_window"`
+
+	Quotas QuotaConfig `json:"quotas"`
+
+	// PayloadSchemas validate JSON payloads per storage type or endpoint
+	PayloadSchemas []PayloadSchemaConfig `json:"payload_schemas"`
+	// Validators is the request validation pipeline, run in order after the
+	// structural checks. Unset, it enforces PayloadSchemas only.
+	Validators []ValidatorConfig `json:"validators"`
+	// ContentTypePolicy decides whether payloads must declare a content
+	// type and which types are refused outright
+	ContentTypePolicy ContentTypePolicy `json:"content_type_policy"`
+
+	// Generator defines the synthetic data templates for POST /admin/generate
+	Generator GeneratorConfig `json:"generator"`
+
+	// ShardStateFile persists the ID-to-shard mapping changed through
+	// /admin/reshard; without it everything stays in the root shard
+	ShardStateFile string `json:"shard_state_file"`
+
+	// WatermarkFile persists the per-tenant change counters
+	WatermarkFile string `json:"watermark_file"`
+
+	// LegalHoldFile persists the legal holds placed on whole tenants
+	LegalHoldFile string `json:"legal_hold_file"`
+
+	// Dedup stores identical payloads once per tenant and storage type
+	Dedup DedupConfig `json:"dedup"`
+
+	// LogStorage configures segment rotation of the "log" storage type
+	LogStorage LogStorageConfig `json:"log_storage"`
+
+	// StorageBackends holds the config sections of storage backends, keyed
+	// by storage type: FileBackendConfig for "file", and whatever backends
+	// added with storage.Register take
+	StorageBackends map[string]json.RawMessage `json:"storage_backends"`
+
+	// StorageDecorators wraps each storage type's backend in a chain of
+	// decorators, the first entry outermost
+	StorageDecorators map[string][]DecoratorConfig `json:"storage_decorators"`
+	// AllowFaultInjection permits chaos decorators, for resilience testing
+	// in staging; leave it off in production
+	AllowFaultInjection bool `json:"allow_fault_injection"`
+
+	// Spool accepts saves while their backend is down and writes them once
+	// it recovers
+	Spool SpoolConfig `json:"spool"`
+
+	// WriteBatching groups concurrent database saves into bulk inserts
+	WriteBatching WriteBatchingConfig `json:"write_batching"`
+
+	// Cache serves repeated reads from memory
+	Cache CacheConfig `json:"cache"`
+
+	// Shadow repeats writes on candidate backends and compares the results
+	Shadow ShadowConfig `json:"shadow"`
+	// Hedging asks the copies of replicated storage types one after another
+	Hedging HedgingConfig `json:"hedging"`
+	// Replication writes items to several backends with quorum
+	Replication ReplicationConfig `json:"replication"`
+	// Tiering moves old items to a cold storage type
+	Tiering TieringConfig `json:"tiering"`
+	// GC removes orphaned records and flags missing ones
+	GC GCConfig `json:"gc"`
+	// Retention deletes or archives items past their retention rules
+	Retention RetentionConfig `json:"retention"`
+
+	// LoadShedding rejects requests once too many are in flight
+	LoadShedding LoadSheddingConfig `json:"load_shedding"`
+
+	// Compression negotiates gzip/deflate for request and response bodies
+	Compression CompressionConfig `json:"compression"`
+
+	// GRPC serves the data service over gRPC on a second port
+	GRPC GRPCConfig `json:"grpc"`
+	// S3 serves an S3-compatible API on a third port
+	S3 S3Config `json:"s3"`
+	// Diagnostics serves pprof and expvar, on the HTTP API or a port of
+	// their own
+	Diagnostics DiagnosticsConfig `json:"diagnostics"`
+
+	// Events streams storage events to subscribers of /events
+	Events EventsConfig `json:"events"`
+
+	// Webhooks notify external endpoints of completed saves
+	Webhooks WebhookConfig `json:"webhooks"`
+
+	// QueueIngest saves SaveRequests consumed from NATS JetStream
+	QueueIngest QueueIngestConfig `json:"queue_ingest"`
+
+	// MQTT saves messages published by devices on the configured topics
+	MQTT MQTTConfig `json:"mqtt"`
+
+	// APIVersions schedules deprecation and sunset of API versions, keyed
+	// by "v1" or "legacy" for the unprefixed paths
+	APIVersions APIVersionPolicies `json:"api_versions"`
+
+	Expiry     ExpiryConfig     `json:"expiry"`
+	SoftDelete SoftDeleteConfig `json:"soft_delete"`
+
+	// Backup ships scheduled backups to a secondary storage type
+	Backup BackupConfig `json:"backup"`
+	// Snapshots are named copies of the whole service state
+	Snapshots SnapshotConfig `json:"snapshots"`
+	// Maintenance starts the server refusing writes
+	Maintenance MaintenanceConfig `json:"maintenance"`
+	// Drain times the shutdown started by POST /admin/drain
+	Drain DrainConfig `json:"drain"`
+	// Outbox publishes the events of database saves transactionally
+	Outbox OutboxConfig `json:"outbox"`
+	// LeaderElection picks the instance that runs scheduled jobs
+	LeaderElection LeaderElectionConfig `json:"leader_election"`
+	// Locking serializes writes to the same item
+	Locking LockingConfig `json:"locking"`
+	// Metering aggregates usage per tenant and API key
+	Metering MeteringConfig `json:"metering"`
+	// KeyManagement manages API keys in the database
+	KeyManagement KeyManagementConfig `json:"key_management"`
+	// PII scans payloads for personal data
+	PII PIIConfig `json:"pii"`
+	// Erasure deletes or anonymizes a data subject's items on request
+	Erasure ErasureConfig `json:"erasure"`
+	// Presign issues signed URLs for single downloads and uploads
+	Presign PresignConfig `json:"presign"`
+	// Objects serves stored files with caching headers under /objects/
+	Objects ObjectServingConfig `json:"objects"`
+	// FieldEncryption encrypts selected values of JSON payloads
+	FieldEncryption FieldEncryptionConfig `json:"field_encryption"`
+	// Scanning submits payloads to a virus scanner
+	Scanning ScanningConfig `json:"scanning"`
+	// StorageAccess limits the storage types API keys may use
+	StorageAccess []StorageAccessRule `json:"storage_access"`
+	// Timeouts are deadlines for data operations
+	Timeouts TimeoutConfig `json:"timeouts"`
+	// SlowLog warns about requests over a latency or size budget
+	SlowLog SlowLogConfig `json:"slow_log"`
+	// ErrorReporting sends server errors to an error tracker
+	ErrorReporting ErrorReportingConfig `json:"error_reporting"`
+	// BodyCapture keeps sampled request and response bodies for
+	// GET /admin/captures
+	BodyCapture BodyCaptureConfig `json:"body_capture"`
+
+	// ClientReportRetention is how many client reports are kept in memory
+	// for GET /admin/client-reports
+	ClientReportRetention int `json:"client_report_retention"`
+
+	// ImportConcurrency is the most records of one POST /import saved at
+	// once
+	ImportConcurrency int `json:"import_concurrency"`
+}
+
+func NewConfiguration() *Configuration {
+	return &Configuration{
+		Port:         "8080",
+		DatabaseHost: "localhost",
+		DatabasePort: 5432,
+		DatabaseUser: "admin",
+		DatabasePass: "password123",
+		DatabaseName: "app_database",
+		DatabasePool: DatabasePoolConfig{
+			MaxOpenConns:    10,
+			MaxIdleConns:    2,
+			ConnMaxLifetime: Duration(30 * time.Minute),
+			ConnMaxIdleTime: Duration(5 * time.Minute),
+			WaitTimeout:     Duration(5 * time.Second),
+		},
+		DatabaseAutoMigrate: true,
+
+		DataDir:          ".",
+		MinFreeDiskBytes: 100 << 20,
+		ClientIPHeader:   "X-Forwarded-For",
+		TenantHeader:     "X-Tenant-ID",
+		AuditSink:        "file",
+		AuditFile:        "audit.log",
+
+		ReindexRatePerSecond: 100,
+		ReindexStateFile:     "reindex-state.json",
+		Index:                IndexConfig{File: "item-index.jsonl"},
+		ApprovalTTL:          Duration(time.Hour),
+		IdempotencyWindow:    Duration(24 * time.Hour),
+		Quotas:               QuotaConfig{UsageFile: "usage.json", FlushInterval: Duration(time.Second)},
+		WatermarkFile:        "watermarks.json",
+		LegalHoldFile:        "legal-holds.json",
+		ErrorReporting:       ErrorReportingConfig{SampleRate: 1, QueueSize: 100},
+		BodyCapture:          BodyCaptureConfig{MaxBodyBytes: 4096, Capacity: 200},
+		ShardStateFile:       "shards.json",
+		ContentTypePolicy:    ContentTypePolicy{Denied: DefaultDeniedContentTypes},
+		Generator:            GeneratorConfig{RatePerSecond: 100, MaxCount: 100000},
+		Dedup:                DedupConfig{MinSize: 1024, GCInterval: Duration(time.Hour)},
+		LogStorage:           LogStorageConfig{MaxSegmentSize: 64 << 20, MaxSegmentAge: Duration(24 * time.Hour)},
+		Spool:                SpoolConfig{Dir: "spool", MaxBytes: 1 << 30, ReplayInterval: Duration(10 * time.Second)},
+		WriteBatching: WriteBatchingConfig{
+			MaxItems:      500,
+			MaxBytes:      4 << 20,
+			FlushInterval: Duration(5 * time.Millisecond),
+		},
+		Cache: CacheConfig{
+			TTL:          Duration(time.Minute),
+			MaxBytes:     64 << 20,
+			MaxItemBytes: 1 << 20,
+		},
+		Shadow:      ShadowConfig{Workers: 4, QueueSize: 1000},
+		Hedging:     HedgingConfig{Delay: Duration(50 * time.Millisecond)},
+		Replication: ReplicationConfig{Workers: 4, QueueSize: 1000},
+		Tiering:     TieringConfig{After: Duration(30 * 24 * time.Hour), Interval: Duration(time.Hour)},
+		GC:          GCConfig{Interval: Duration(24 * time.Hour), DryRun: true},
+		Retention:   RetentionConfig{Interval: Duration(24 * time.Hour)},
+		LoadShedding: LoadSheddingConfig{
+			MaxInFlight:  256,
+			MaxQueue:     512,
+			QueueTimeout: Duration(2 * time.Second),
+			RetryAfter:   Duration(time.Second),
+		},
+		Compression: CompressionConfig{
+			MinSize:         1024,
+			Level:           6,
+			MaxRequestBytes: 64 << 20,
+		},
+		GRPC:        GRPCConfig{MaxMessageBytes: 4 << 20},
+		S3:          S3Config{MaxObjectBytes: 64 << 20},
+		Diagnostics: DiagnosticsConfig{Host: "127.0.0.1"},
+		Events:      EventsConfig{MaxSubscribers: 100, BufferSize: 64, Heartbeat: Duration(15 * time.Second)},
+		Webhooks: WebhookConfig{
+			MaxAttempts:    8,
+			InitialBackoff: Duration(time.Second),
+			MaxBackoff:     Duration(5 * time.Minute),
+			Timeout:        Duration(10 * time.Second),
+			Workers:        4,
+			QueueSize:      10000,
+			DeadLetterFile: "webhooks-dead-letter.jsonl",
+		},
+		QueueIngest: QueueIngestConfig{Batch: 16, RetryDelay: Duration(5 * time.Second)},
+		MQTT: MQTTConfig{
+			ClientID:   "interview-task",
+			KeepAlive:  Duration(time.Minute),
+			QoS:        1,
+			RetryDelay: Duration(5 * time.Second),
+		},
+		Expiry:     ExpiryConfig{ReapInterval: Duration(time.Minute)},
+		SoftDelete: SoftDeleteConfig{GracePeriod: Duration(7 * 24 * time.Hour), PurgeInterval: Duration(time.Hour)},
+		Backup:     BackupConfig{Tenant: "backups", Keep: 7},
+		Snapshots:  SnapshotConfig{Dir: "snapshots"},
+		Objects:    ObjectServingConfig{CacheControl: "public, max-age=3600"},
+		Presign:    PresignConfig{DefaultExpiry: Duration(15 * time.Minute), MaxExpiry: Duration(24 * time.Hour), MaxUploadBytes: 64 << 20},
+		Drain:      DrainConfig{Delay: Duration(10 * time.Second), Timeout: Duration(30 * time.Second)},
+		Outbox:     OutboxConfig{Interval: Duration(time.Second), BatchSize: 100},
+		LeaderElection: LeaderElectionConfig{
+			Name:          "background-jobs",
+			LeaseDuration: Duration(15 * time.Second),
+			RenewInterval: Duration(5 * time.Second),
+		},
+		Locking:       LockingConfig{Timeout: Duration(5 * time.Second), LeaseDuration: Duration(30 * time.Second)},
+		Metering:      MeteringConfig{FlushInterval: Duration(time.Minute), RetentionDays: 400},
+		KeyManagement: KeyManagementConfig{RefreshInterval: Duration(30 * time.Second)},
+		Scanning:      ScanningConfig{Timeout: Duration(30 * time.Second), QueueSize: 1000},
+
+		ClientReportRetention: 1000,
+		ImportConcurrency:     8,
+	}
+}
+
+// LoadConfiguration reads a JSON config file on top of the defaults. An empty
+// path returns the defaults unchanged.
+func LoadConfiguration(path string) (*Configuration, error) {
+	config := NewConfiguration()
+	if path == "" {
+		config.resolveStatePaths()
+		return config, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file: %w", err)
+	}
+	if err := json.Unmarshal(data, config); err != nil {
+		return nil, fmt.Errorf("failed to parse config file: %w", err)
+	}
+	config.resolveStatePaths()
+	return config, nil
+}
+
+// resolveStatePaths makes relative state paths relative to DataDir, so the
+// server's state stays together wherever it is started from
+func (c *Configuration) resolveStatePaths() {
+	paths := []*string{
+		&c.AuditFile, &c.JournalFile, &c.ReindexStateFile, &c.ShardStateFile,
+		&c.WatermarkFile, &c.LegalHoldFile, &c.Index.File, &c.Quotas.UsageFile,
+		&c.Metering.File, &c.Webhooks.DeadLetterFile, &c.Spool.Dir, &c.Snapshots.Dir,
+	}
+	for _, path := range paths {
+		if *path != "" && !filepath.IsAbs(*path) {
+			*path = filepath.Join(c.DataDir, *path)
+		}
+	}
+}
+
+// Duration is a time.Duration read from config as a string such as "90s"
+type Duration time.Duration
+
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var text string
+	if err := json.Unmarshal(data, &text); err != nil {
+		return fmt.Errorf("duration must be a string like \"30s\": %w", err)
+	}
+	parsed, err := time.ParseDuration(text)
+	if err != nil {
+		return err
+	}
+	*d = Duration(parsed)
+	return nil
+}
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
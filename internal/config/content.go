@@ -0,0 +1,16 @@
+package config
+
+// ContentTypePolicy decides how a payload's media type is determined.
+// Payloads without a declared content type are sniffed unless Require is
+// set; types listed in Denied are rejected whether declared or sniffed.
+type ContentTypePolicy struct {
+	Require bool     `json:"require"`
+	Denied  []string `json:"denied"`
+}
+
+// DefaultDeniedContentTypes rejects native executables
+var DefaultDeniedContentTypes = []string{
+	"application/x-executable",
+	"application/vnd.microsoft.portable-executable",
+	"application/x-mach-binary",
+}
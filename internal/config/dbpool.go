@@ -0,0 +1,16 @@
+package config
+
+// DatabasePoolConfig sizes the database connection pool
+type DatabasePoolConfig struct {
+	// MaxOpenConns caps connections in use or idle
+	MaxOpenConns int `json:"max_open_conns"`
+	// MaxIdleConns is how many released connections are kept for reuse
+	MaxIdleConns int `json:"max_idle_conns"`
+	// ConnMaxLifetime and ConnMaxIdleTime retire connections so they are
+	// reestablished, such as after a failover; zero keeps them forever
+	ConnMaxLifetime Duration `json:"conn_max_lifetime"`
+	ConnMaxIdleTime Duration `json:"conn_max_idle_time"`
+	// WaitTimeout is how long a query waits for a connection when all of
+	// them are in use
+	WaitTimeout Duration `json:"wait_timeout"`
+}
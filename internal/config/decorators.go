@@ -0,0 +1,43 @@
+package config
+
+// DecoratorConfig adds one decorator to a storage type's chain. Type selects
+// a built-in ("retry", "circuit_breaker", "encryption", "compression",
+// "chaos") or a decorator registered with RegisterDecorator; Disabled keeps
+// the entry in the config without applying it. "metrics" is deprecated: it
+// is still accepted, with a warning logged, but does nothing, since every
+// backend call is counted anyway.
+type DecoratorConfig struct {
+	Type     string `json:"type"`
+	Disabled bool   `json:"disabled"`
+	// MaxAttempts and Backoff configure retry; the backoff doubles after
+	// every attempt
+	MaxAttempts int      `json:"max_attempts"`
+	Backoff     Duration `json:"backoff"`
+	// FailureThreshold consecutive failures open the circuit breaker, which
+	// fails fast for OpenFor before letting a trial call through
+	FailureThreshold int      `json:"failure_threshold"`
+	OpenFor          Duration `json:"open_for"`
+	// MasterKeys are encryption's master keys by version, each a reference
+	// "env:VAR" or "file:path" to a hex-encoded 32-byte AES-256 key, or
+	// "scheme:ref" of a key provider registered with RegisterKeyProvider,
+	// such as a KMS. Every payload is sealed with a data key of its own,
+	// wrapped by the KeyVersion master key; older versions stay to unwrap
+	// payloads until they are rotated.
+	MasterKeys map[string]string `json:"master_keys"`
+	KeyVersion string            `json:"key_version"`
+	// KeyFile holds a hex-encoded 32-byte AES-256 key, master key version
+	// "1" of configs predating MasterKeys. Payloads sealed with it directly,
+	// before envelope encryption, are read with it too.
+	KeyFile string `json:"key_file"`
+	// MinBytes is the smallest payload compression compresses
+	MinBytes int `json:"min_bytes"`
+	// FailureRate, DelayRate and PartialWriteRate are the chances, from 0
+	// to 1, that chaos fails a call, delays it by up to MaxDelay, or saves
+	// part of a payload before failing
+	FailureRate      float64  `json:"failure_rate"`
+	DelayRate        float64  `json:"delay_rate"`
+	MaxDelay         Duration `json:"max_delay"`
+	PartialWriteRate float64  `json:"partial_write_rate"`
+	// Params carries settings for registered decorators
+	Params map[string]string `json:"params"`
+}
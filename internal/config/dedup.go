@@ -0,0 +1,13 @@
+package config
+
+// DedupConfig enables content-addressed storage of payloads. Quotas still
+// charge every item for its full size.
+type DedupConfig struct {
+	Enabled bool `json:"enabled"`
+	// MinSize is the smallest payload stored as a shared blob; smaller ones
+	// are stored inline since a reference would save little
+	MinSize int `json:"min_size"`
+	// GCInterval is how often unreferenced blobs are removed; zero leaves
+	// collection to POST /admin/dedup/gc
+	GCInterval Duration `json:"gc_interval"`
+}
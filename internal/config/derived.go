@@ -0,0 +1,13 @@
+package config
+
+// DerivationConfig declares a named projection over JSON payloads
+type DerivationConfig struct {
+	Name string `json:"name"`
+	// Path selects the input for the template; defaults to the whole payload
+	Path string `json:"path"`
+	// Template is a text/template applied to the selected value. Without a
+	// template the selection is returned as JSON.
+	Template string `json:"template"`
+	// Eager derivations are materialized on save, others on first read
+	Eager bool `json:"eager"`
+}
@@ -0,0 +1,12 @@
+package config
+
+// DiagnosticsConfig exposes pprof profiles and expvar variables, to take
+// heap and CPU profiles in production without recompiling
+type DiagnosticsConfig struct {
+	// Enabled serves them under /debug/ on the HTTP API to admin keys
+	Enabled bool `json:"enabled"`
+	// Port serves them on a listener of their own instead, without
+	// authentication, bound to Host
+	Port string `json:"port"`
+	Host string `json:"host"`
+}
@@ -0,0 +1,11 @@
+package config
+
+// DrainConfig times drain mode, started with POST /admin/drain before a
+// rolling deploy replaces the instance
+type DrainConfig struct {
+	// Delay is how long /readyz reports not ready before the server shuts
+	// down, long enough for load balancers to stop routing to it
+	Delay Duration `json:"delay"`
+	// Timeout caps the wait for queued webhook deliveries at shutdown
+	Timeout Duration `json:"timeout"`
+}
@@ -0,0 +1,11 @@
+package config
+
+// ErasureConfig enables erasing a data subject's items on request
+type ErasureConfig struct {
+	// SigningKeyFile holds the hex-encoded 32-byte Ed25519 seed signing
+	// erasure reports; erasure is unavailable without it
+	SigningKeyFile string `json:"signing_key_file"`
+	// Mask replaces the subject's identifiers in anonymized payloads,
+	// "[ERASED]" by default
+	Mask string `json:"mask"`
+}
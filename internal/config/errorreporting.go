@@ -0,0 +1,19 @@
+package config
+
+import "encoding/json"
+
+// ErrorReportingConfig sends 5xx responses and panics to an error tracker
+type ErrorReportingConfig struct {
+	// Reporter is "sentry", "http" or one registered with
+	// server.RegisterErrorReporter; empty disables error reporting
+	Reporter string `json:"reporter"`
+	// Settings is the reporter's own section, such as sentry's dsn or
+	// http's url
+	Settings json.RawMessage `json:"settings"`
+	// SampleRate is the share of errors reported, from 0 to 1
+	SampleRate  float64 `json:"sample_rate"`
+	Environment string  `json:"environment"`
+	Release     string  `json:"release"`
+	// QueueSize bounds the reports waiting to be sent; more are dropped
+	QueueSize int `json:"queue_size"`
+}
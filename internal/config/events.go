@@ -0,0 +1,13 @@
+package config
+
+// EventsConfig tunes the storage event stream on /events
+type EventsConfig struct {
+	// MaxSubscribers bounds concurrent streams
+	MaxSubscribers int `json:"max_subscribers"`
+	// BufferSize is how many events a subscriber can fall behind by before
+	// events are dropped for it
+	BufferSize int `json:"buffer_size"`
+	// Heartbeat is how often idle streams get a comment, keeping proxies
+	// from closing them
+	Heartbeat Duration `json:"heartbeat"`
+}
@@ -0,0 +1,35 @@
+package config
+
+import (
+	"fmt"
+	"time"
+)
+
+// ExpiryConfig sets how long items live. A save may ask for its own TTL;
+// without one DefaultTTL applies, and zero keeps items forever.
+type ExpiryConfig struct {
+	DefaultTTL Duration `json:"default_ttl"`
+	// MaxTTL caps requested TTLs; zero allows any
+	MaxTTL Duration `json:"max_ttl"`
+	// ReapInterval is how often expired items are deleted. Until then they
+	// are already hidden from reads.
+	ReapInterval Duration `json:"reap_interval"`
+}
+
+// ExpiresAt resolves the expiry of an item saved now with the requested TTL.
+// The zero time means the item never expires.
+func (c ExpiryConfig) ExpiresAt(requested Duration, now time.Time) (time.Time, error) {
+	ttl := requested
+	switch {
+	case ttl < 0:
+		return time.Time{}, fmt.Errorf("ttl cannot be negative")
+	case ttl == 0:
+		ttl = c.DefaultTTL
+	case c.MaxTTL > 0 && ttl > c.MaxTTL:
+		return time.Time{}, fmt.Errorf("ttl cannot exceed %s", time.Duration(c.MaxTTL))
+	}
+	if ttl == 0 {
+		return time.Time{}, nil
+	}
+	return now.Add(time.Duration(ttl)).UTC(), nil
+}
@@ -0,0 +1,14 @@
+package config
+
+// FieldEncryptionConfig encrypts selected values of JSON payloads before
+// they are stored, so they stay protected from anyone reading the backend
+type FieldEncryptionConfig struct {
+	// Paths select the values to encrypt, such as "$.ssn" or
+	// "$.cards[*].number"; empty disables field encryption
+	Paths []string `json:"paths"`
+	// KeyFile holds the hex-encoded 32-byte AES-256 key
+	KeyFile string `json:"key_file"`
+	// Roles may read the values decrypted; other callers get them
+	// encrypted. Empty allows administrators only.
+	Roles []string `json:"roles"`
+}
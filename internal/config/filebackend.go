@@ -0,0 +1,10 @@
+package config
+
+// FileBackendConfig is the storage_backends section of the "file" storage
+// type
+type FileBackendConfig struct {
+	// Volumes maps shard names to directories, typically mount points of
+	// other disks, the shards' items are kept on instead of the data
+	// directory. Shards without a volume stay in the data directory.
+	Volumes map[string]string `json:"volumes"`
+}
@@ -0,0 +1,33 @@
+package config
+
+import (
+	"fmt"
+)
+
+// RotationLayouts maps FileLayout rotations to their dated folders
+var RotationLayouts = map[string]string{
+	"daily":   "2006/01/02",
+	"monthly": "2006/01",
+}
+
+// FileLayout decides where file storage keeps each key, so no directory
+// grows to millions of entries. Files written before a layout change stay
+// readable in the flat layout; other layout changes need the files moved.
+type FileLayout struct {
+	// HashLevels nests files under that many levels of two hex digits taken
+	// from a hash of the item ID; zero keeps them in one directory
+	HashLevels int `json:"hash_levels"`
+	// Rotation rolls new items into dated folders: "", "daily" or "monthly".
+	// Items stay in the folder they were first written to.
+	Rotation string `json:"rotation"`
+}
+
+func (l FileLayout) Validate() error {
+	if l.HashLevels < 0 || l.HashLevels > 4 {
+		return fmt.Errorf("hash_levels must be between 0 and 4")
+	}
+	if _, ok := RotationLayouts[l.Rotation]; l.Rotation != "" && !ok {
+		return fmt.Errorf("unsupported rotation: %s", l.Rotation)
+	}
+	return nil
+}
@@ -0,0 +1,11 @@
+package config
+
+// GCConfig schedules the garbage collector reconciling every storage type's
+// keys with the items recorded in it
+type GCConfig struct {
+	// Interval is how often it runs; zero leaves it to POST /admin/gc
+	Interval Duration `json:"interval"`
+	// DryRun makes scheduled runs report what they would remove without
+	// removing anything
+	DryRun bool `json:"dry_run"`
+}
@@ -0,0 +1,43 @@
+package config
+
+import (
+	"fmt"
+)
+
+// GeneratorTemplate describes the synthetic items to produce. Shape is
+// "json" (an object with the given fields), "text" or "binary"; payloads
+// are padded to a random size between MinSize and MaxSize.
+type GeneratorTemplate struct {
+	Shape   string `json:"shape"`
+	MinSize int    `json:"min_size"`
+	MaxSize int    `json:"max_size"`
+	// Fields maps field names to "string", "int", "float", "bool" or "time"
+	Fields map[string]string `json:"fields"`
+	Tags   map[string]string `json:"tags"`
+}
+
+// GeneratorConfig holds the named templates POST /admin/generate can use
+type GeneratorConfig struct {
+	Templates     map[string]GeneratorTemplate `json:"templates"`
+	RatePerSecond int                          `json:"rate_per_second"`
+	MaxCount      int                          `json:"max_count"`
+}
+
+func (t *GeneratorTemplate) Validate() error {
+	switch t.Shape {
+	case "json", "text", "binary":
+	default:
+		return fmt.Errorf("unknown shape %q", t.Shape)
+	}
+	if t.MinSize < 0 || t.MaxSize < t.MinSize {
+		return fmt.Errorf("invalid size range %d-%d", t.MinSize, t.MaxSize)
+	}
+	for field, kind := range t.Fields {
+		switch kind {
+		case "string", "int", "float", "bool", "time":
+		default:
+			return fmt.Errorf("field %s has unknown type %q", field, kind)
+		}
+	}
+	return nil
+}
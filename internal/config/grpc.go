@@ -0,0 +1,9 @@
+package config
+
+// GRPCConfig serves the data service over gRPC on a second port
+type GRPCConfig struct {
+	// Port is where the gRPC server listens; empty disables it
+	Port string `json:"port"`
+	// MaxMessageBytes bounds request messages
+	MaxMessageBytes int `json:"max_message_bytes"`
+}
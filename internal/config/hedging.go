@@ -0,0 +1,33 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+)
+
+// HedgingConfig hedges reads of replicated storage types: instead of asking
+// every copy at once, a read goes to the first copy that isn't stale and is
+// repeated on the next one when it isn't answered within Delay. The copies
+// are those of Replication.Replicas.
+type HedgingConfig struct {
+	Enabled bool `json:"enabled"`
+	// Delay is how long a read waits for an answer before it is hedged
+	Delay Duration `json:"delay"`
+}
+
+// UnmarshalJSON rejects the replicas hedging used to take, so a config
+// written for them doesn't silently stop hedging
+func (c *HedgingConfig) UnmarshalJSON(data []byte) error {
+	type plain HedgingConfig
+	fields := struct {
+		*plain
+		Replicas json.RawMessage `json:"replicas"`
+	}{plain: (*plain)(c)}
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return err
+	}
+	if fields.Replicas != nil {
+		return errors.New(`hedging.replicas is no longer supported: list the copies in replication.replicas and set hedging.enabled`)
+	}
+	return nil
+}
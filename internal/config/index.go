@@ -0,0 +1,11 @@
+package config
+
+// IndexConfig keeps an index of every item's metadata apart from the
+// storage backends, so lists, searches and usage recounts don't read each
+// item's metadata from its backend
+type IndexConfig struct {
+	// Backend holds the index: "database", "file" for an embedded one
+	// kept in File, or empty for none
+	Backend string `json:"backend"`
+	File    string `json:"file"`
+}
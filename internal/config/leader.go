@@ -0,0 +1,19 @@
+package config
+
+// LeaderElectionConfig elects one instance of a multi-instance deployment
+// to run the scheduled background jobs: TTL reaping, trash purging and
+// scheduled backups. Instances take turns holding a lease; without a
+// backend every instance runs them.
+type LeaderElectionConfig struct {
+	// Backend holds the lease: "database" or empty to disable election
+	Backend string `json:"backend"`
+	// Name identifies the lease, so deployments sharing a backend can
+	// elect separately
+	Name string `json:"name"`
+	// Identity names this instance; it defaults to the hostname and PID
+	Identity string `json:"identity"`
+	// LeaseDuration is how long a leader that stops renewing keeps the
+	// lease; RenewInterval is how often the lease is renewed or sought
+	LeaseDuration Duration `json:"lease_duration"`
+	RenewInterval Duration `json:"renew_interval"`
+}
@@ -0,0 +1,19 @@
+package config
+
+// ListenConfig serves the HTTP API on a Unix domain socket, or on a socket
+// inherited from systemd, instead of the TCP port
+type ListenConfig struct {
+	// Systemd takes the listening socket passed by systemd socket
+	// activation. The socket outlives restarts of the service, so clients
+	// queue rather than fail while it restarts. Started without one, the
+	// server falls back to the other settings.
+	Systemd bool `json:"systemd"`
+	// UnixSocket is the path of a Unix domain socket to listen on; a stale
+	// socket left at the path is replaced
+	UnixSocket string `json:"unix_socket"`
+	// SocketMode is the octal permission of the socket, such as "0660"
+	SocketMode string `json:"socket_mode"`
+	// SocketUser and SocketGroup own the socket, by name or numeric ID
+	SocketUser  string `json:"socket_user"`
+	SocketGroup string `json:"socket_group"`
+}
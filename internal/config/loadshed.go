@@ -0,0 +1,19 @@
+package config
+
+// LoadSheddingConfig bounds the requests the server works on at once.
+// Requests beyond MaxInFlight queue; once the queue is full they are
+// rejected with 429, and those queued longer than QueueTimeout with 503.
+type LoadSheddingConfig struct {
+	// MaxInFlight is how many requests are handled concurrently; zero
+	// disables load shedding
+	MaxInFlight  int      `json:"max_in_flight"`
+	MaxQueue     int      `json:"max_queue"`
+	QueueTimeout Duration `json:"queue_timeout"`
+	// RetryAfter is the back-off suggested to rejected clients
+	RetryAfter Duration `json:"retry_after"`
+	// Bulkheads cap the concurrent requests of single routes, keyed by the
+	// route without its version prefix, such as "/import" or, for one
+	// method, "PUT /data/{id}". Requests over a cap are rejected with 429
+	// without queueing. They apply even with MaxInFlight zero.
+	Bulkheads map[string]int `json:"bulkheads"`
+}
@@ -0,0 +1,15 @@
+package config
+
+// LockingConfig serializes concurrent writes to the same item, so saves,
+// updates and deletes of one ID don't interleave
+type LockingConfig struct {
+	// Backend holds the locks: "local" within this instance, "database"
+	// across instances sharing a database, or empty for no locking, in
+	// which case only writes with If-Match lock, within this instance
+	Backend string `json:"backend"`
+	// Timeout is how long a write waits for another to release the item
+	Timeout Duration `json:"timeout"`
+	// LeaseDuration bounds how long a database lock outlives a crashed
+	// holder. Holders renew it every third of it while they write.
+	LeaseDuration Duration `json:"lease_duration"`
+}
@@ -0,0 +1,12 @@
+package config
+
+// LogStorageConfig sets when the "log" storage type starts a new segment.
+// Whichever limit is reached first rotates the segment.
+type LogStorageConfig struct {
+	// MaxSegmentSize is the size in bytes a segment may grow to
+	MaxSegmentSize int64 `json:"max_segment_size"`
+	// MaxSegmentAge is how long a segment stays open for appends
+	MaxSegmentAge Duration `json:"max_segment_age"`
+	// Compress gzips segments once they are rotated
+	Compress bool `json:"compress"`
+}
@@ -0,0 +1,10 @@
+package config
+
+// MaintenanceConfig starts the server in maintenance mode, where writes are
+// refused while reads keep working. PUT /admin/maintenance toggles it at
+// runtime.
+type MaintenanceConfig struct {
+	Enabled bool `json:"enabled"`
+	// Message is returned to refused writes; empty uses a generic one
+	Message string `json:"message"`
+}
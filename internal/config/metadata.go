@@ -0,0 +1,7 @@
+package config
+
+// MetadataRuleConfig extracts a searchable field from JSON payloads
+type MetadataRuleConfig struct {
+	Field string `json:"field"`
+	Path  string `json:"path"`
+}
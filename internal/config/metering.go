@@ -0,0 +1,14 @@
+package config
+
+// MeteringConfig meters requests, bytes transferred and bytes stored per
+// tenant and API key, in daily aggregates for chargeback
+type MeteringConfig struct {
+	Enabled bool `json:"enabled"`
+	// File keeps the aggregates across restarts
+	File string `json:"file"`
+	// FlushInterval is how often the aggregates are written to File and
+	// stored bytes are sampled
+	FlushInterval Duration `json:"flush_interval"`
+	// RetentionDays drops older aggregates; zero keeps them all
+	RetentionDays int `json:"retention_days"`
+}
@@ -0,0 +1,87 @@
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// MQTTConfig bridges MQTT topics to saves, for devices that can't speak
+// HTTP
+type MQTTConfig struct {
+	// Broker is tcp://host:1883 or ssl://host:8883; empty disables the
+	// bridge
+	Broker string `json:"broker"`
+	// ClientID identifies the session to the broker and must be unique
+	// among the instances of the service
+	ClientID string `json:"client_id"`
+	Username string `json:"username"`
+	Password string `json:"password"`
+	// KeepAlive is the ping interval agreed with the broker
+	KeepAlive Duration `json:"keep_alive"`
+	// QoS 1 keeps a persistent session and acknowledges each message once
+	// it is saved, so the broker redelivers messages the bridge failed on;
+	// QoS 0 is fire and forget
+	QoS int `json:"qos"`
+	// Tenant receives the saves
+	Tenant string `json:"tenant"`
+	// RetryDelay is how long the bridge waits to reconnect after a
+	// transient failure, which has the broker redeliver the message
+	RetryDelay Duration    `json:"retry_delay"`
+	Routes     []MQTTRoute `json:"routes"`
+}
+
+// MQTTRoute saves the messages of the topics matching Topic. The first
+// matching route applies.
+type MQTTRoute struct {
+	// Topic is a filter, which may contain + and # wildcards
+	Topic       string `json:"topic"`
+	StorageType string `json:"storage_type"`
+	// ContentType is declared on saved items; empty sniffs it
+	ContentType string `json:"content_type"`
+	// Labels are set on saved items. A value {n} is replaced with the
+	// topic's n-th level, counting from 0, so devices/+/temperature can
+	// label items with the device name from {1}.
+	Labels map[string]string `json:"labels"`
+	TTL    Duration          `json:"ttl"`
+}
+
+func (c *MQTTConfig) Validate() error {
+	if c.Broker == "" {
+		return nil
+	}
+	u, err := url.Parse(c.Broker)
+	if err != nil || (u.Scheme != "tcp" && u.Scheme != "ssl") || u.Host == "" {
+		return fmt.Errorf("invalid MQTT broker: %q", c.Broker)
+	}
+	keepAlive := time.Duration(c.KeepAlive)
+	if keepAlive < time.Second || keepAlive > 65535*time.Second {
+		return fmt.Errorf("keep_alive must be between 1s and 65535s")
+	}
+	if c.QoS != 0 && c.QoS != 1 {
+		return fmt.Errorf("qos must be 0 or 1")
+	}
+	if len(c.Routes) == 0 {
+		return fmt.Errorf("at least one route is required")
+	}
+	for _, route := range c.Routes {
+		if err := validateTopicFilter(route.Topic); err != nil {
+			return err
+		}
+		if route.StorageType == "" {
+			return fmt.Errorf("route %s has no storage type", route.Topic)
+		}
+	}
+	return nil
+}
+
+func validateTopicFilter(filter string) error {
+	levels := strings.Split(filter, "/")
+	for i, level := range levels {
+		if filter == "" || strings.ContainsAny(level, "+#") && len(level) > 1 || level == "#" && i != len(levels)-1 {
+			return fmt.Errorf("invalid MQTT topic filter: %q", filter)
+		}
+	}
+	return nil
+}
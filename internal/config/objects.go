@@ -0,0 +1,13 @@
+package config
+
+// ObjectServingConfig serves the items of the file storage type under
+// /objects/{id} with HTTP caching headers, so a CDN can pull from the
+// service directly
+type ObjectServingConfig struct {
+	Enabled bool `json:"enabled"`
+	// Public serves objects without an API key: anyone who knows an item's
+	// ID can read it
+	Public bool `json:"public"`
+	// CacheControl is sent with every object
+	CacheControl string `json:"cache_control"`
+}
@@ -0,0 +1,13 @@
+package config
+
+// OutboxConfig enables the transactional outbox. Saves to storage types
+// that support transactions, such as the database, stage their event in the
+// same transaction, and a dispatcher publishes staged events to event
+// streams and webhooks.
+type OutboxConfig struct {
+	Enabled bool `json:"enabled"`
+	// Interval is how often the dispatcher looks for staged events
+	Interval Duration `json:"interval"`
+	// BatchSize is the most events published per pass
+	BatchSize int `json:"batch_size"`
+}
@@ -0,0 +1,24 @@
+package config
+
+// PIIConfig scans JSON payloads for personal data before they are saved
+type PIIConfig struct {
+	// Action is taken on a payload containing personal data: "reject" it,
+	// "mask" the matches, or "tag" the item with a pii label. Empty
+	// disables scanning.
+	Action string `json:"action"`
+	// Detectors are the built-in patterns to look for: "email",
+	// "card_number" and "national_id"; empty looks for all of them
+	Detectors []string `json:"detectors"`
+	// Patterns adds regular expressions, keyed by the name reported for
+	// their matches
+	Patterns map[string]string `json:"patterns"`
+	// Mask replaces each match, "[REDACTED]" by default
+	Mask string `json:"mask"`
+}
+
+// MaskingConfig returns the config with action "mask", for redacting the
+// same personal data outside of saves
+func (c PIIConfig) MaskingConfig() PIIConfig {
+	c.Action = "mask"
+	return c
+}
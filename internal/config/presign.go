@@ -0,0 +1,16 @@
+package config
+
+// PresignConfig enables time-limited signed URLs, which let clients download
+// or upload a single item without carrying an API key
+type PresignConfig struct {
+	// SecretFile holds the secret, at least 32 bytes, signing the URLs;
+	// signed URLs are unavailable without it. Every instance needs the same
+	// secret.
+	SecretFile string `json:"secret_file"`
+	// DefaultExpiry applies when a URL is requested without an expiry
+	DefaultExpiry Duration `json:"default_expiry"`
+	// MaxExpiry bounds the expiry clients may ask for
+	MaxExpiry Duration `json:"max_expiry"`
+	// MaxUploadBytes bounds the bodies of PUT /data/{id}/content
+	MaxUploadBytes int64 `json:"max_upload_bytes"`
+}
@@ -0,0 +1,19 @@
+package config
+
+// QuotaLimits caps stored bytes and objects; zero means unlimited
+type QuotaLimits struct {
+	MaxBytes   int64 `json:"max_bytes"`
+	MaxObjects int64 `json:"max_objects"`
+}
+
+// QuotaConfig sets default limits per tenant and per API key, with
+// overrides keyed by tenant ID or key name
+type QuotaConfig struct {
+	Tenant    QuotaLimits            `json:"tenant"`
+	Key       QuotaLimits            `json:"key"`
+	Tenants   map[string]QuotaLimits `json:"tenants"`
+	Keys      map[string]QuotaLimits `json:"keys"`
+	UsageFile string                 `json:"usage_file"`
+	// FlushInterval is how often changed usage is written to UsageFile
+	FlushInterval Duration `json:"flush_interval"`
+}
@@ -0,0 +1,16 @@
+package config
+
+// ReplicationConfig keeps copies of storage types' items on replica
+// backends, written with quorum and repaired in the background
+type ReplicationConfig struct {
+	// Replicas maps a storage type to the storage types its items are
+	// copied to
+	Replicas map[string][]string `json:"replicas"`
+	// WriteQuorum is how many copies, the primary's included, a write must
+	// reach to succeed; zero is a majority
+	WriteQuorum int `json:"write_quorum"`
+	// Workers repair copies from a queue of QueueSize repairs; repairs that
+	// don't fit are dropped and counted
+	Workers   int `json:"workers"`
+	QueueSize int `json:"queue_size"`
+}
@@ -0,0 +1,31 @@
+package config
+
+// RetentionConfig deletes or archives items kept longer than their
+// retention rules allow
+type RetentionConfig struct {
+	// Interval is how often the rules are enforced; zero leaves it to
+	// POST /admin/retention
+	Interval Duration `json:"interval"`
+	// DryRun makes scheduled runs report what they would remove without
+	// removing anything
+	DryRun bool `json:"dry_run"`
+	// Rules are matched in order; the first one matching an item decides
+	// how long it is kept
+	Rules []RetentionRuleConfig `json:"rules"`
+}
+
+// RetentionRuleConfig keeps the items it selects for MaxAge after they were
+// first saved. Empty selectors match every item.
+type RetentionRuleConfig struct {
+	Name        string `json:"name"`
+	Tenant      string `json:"tenant"`
+	StorageType string `json:"storage_type"`
+	// Tags select items carrying every key and value as a label or as a
+	// tag of generated data
+	Tags   map[string]string `json:"tags"`
+	MaxAge Duration          `json:"max_age"`
+	// Action is "delete", the default, or "archive", which moves items to
+	// ArchiveStorageType first
+	Action             string `json:"action"`
+	ArchiveStorageType string `json:"archive_storage_type"`
+}
@@ -0,0 +1,20 @@
+package config
+
+import (
+	"fmt"
+)
+
+// S3Config sets up the S3-compatible API
+type S3Config struct {
+	// Port is where the S3 API listens; empty disables it
+	Port string `json:"port"`
+	// MaxObjectBytes bounds uploaded objects
+	MaxObjectBytes int64 `json:"max_object_bytes"`
+}
+
+func (c *S3Config) Validate() error {
+	if c.Port != "" && c.MaxObjectBytes <= 0 {
+		return fmt.Errorf("max_object_bytes must be positive")
+	}
+	return nil
+}
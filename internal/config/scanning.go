@@ -0,0 +1,21 @@
+package config
+
+// ScanningConfig submits payloads to a virus scanner before they are stored
+type ScanningConfig struct {
+	// Mode "block" scans every payload before it is stored, rejecting
+	// infected ones and failing saves while the scanner can't be reached;
+	// "flag" stores payloads and labels them with the outcome of a scan in
+	// the background. Empty disables scanning.
+	Mode string `json:"mode"`
+	// Engine is "clamav", streaming payloads to clamd at Address, or
+	// "http", posting them to URL
+	Engine string `json:"engine"`
+	// Address is clamd's socket, "unix:/path/to/clamd.sock" or "host:port"
+	Address string `json:"address"`
+	URL     string `json:"url"`
+	// Timeout bounds one scan
+	Timeout Duration `json:"timeout"`
+	// QueueSize bounds the payloads waiting for a background scan; saves
+	// finding it full are scanned before they return
+	QueueSize int `json:"queue_size"`
+}
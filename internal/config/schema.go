@@ -0,0 +1,14 @@
+package config
+
+import (
+	"encoding/json"
+)
+
+// PayloadSchemaConfig attaches a JSON Schema to payloads saved to a storage
+// type and/or through an endpoint. Empty selectors match everything.
+type PayloadSchemaConfig struct {
+	StorageType string          `json:"storage_type"`
+	Endpoint    string          `json:"endpoint"`
+	Schema      json.RawMessage `json:"schema"`
+	SchemaFile  string          `json:"schema_file"`
+}
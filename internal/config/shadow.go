@@ -0,0 +1,13 @@
+package config
+
+// ShadowConfig mirrors writes to candidate backends, to validate a backend
+// against production traffic before cutting over to it
+type ShadowConfig struct {
+	// Targets maps a storage type to the candidate storage type its saves
+	// and deletes are repeated on
+	Targets map[string]string `json:"targets"`
+	// Workers write to the candidates from a queue of QueueSize writes;
+	// writes that don't fit are dropped and counted
+	Workers   int `json:"workers"`
+	QueueSize int `json:"queue_size"`
+}
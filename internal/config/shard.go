@@ -0,0 +1,18 @@
+package config
+
+// ShardMapConfig selects and configures a mapper. Type "single" (the
+// default) keeps everything in the root shard, the layout used before
+// sharding; "hash_ring" spreads IDs over Shards by consistent hashing;
+// "range" assigns contiguous ID ranges.
+type ShardMapConfig struct {
+	Type         string       `json:"type"`
+	Shards       []string     `json:"shards,omitempty"`
+	VirtualNodes int          `json:"virtual_nodes,omitempty"`
+	Ranges       []ShardRange `json:"ranges,omitempty"`
+}
+
+// ShardRange maps IDs from Start (inclusive) up to the next range's start
+type ShardRange struct {
+	Start string `json:"start"`
+	Shard string `json:"shard"`
+}
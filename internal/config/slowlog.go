@@ -0,0 +1,11 @@
+package config
+
+// SlowLogConfig sets budgets above which a request is logged as a warning,
+// with its request ID, route, backend, duration and sizes. Zero disables a
+// budget.
+type SlowLogConfig struct {
+	// Duration is the longest a request may take
+	Duration Duration `json:"duration"`
+	// PayloadBytes is the largest request or response body
+	PayloadBytes int64 `json:"payload_bytes"`
+}
@@ -0,0 +1,8 @@
+package config
+
+// SnapshotConfig keeps named snapshots of every storage type and the
+// metadata index, to restore the service to as a whole
+type SnapshotConfig struct {
+	// Dir holds a directory per snapshot
+	Dir string `json:"dir"`
+}
@@ -0,0 +1,15 @@
+package config
+
+// SpoolConfig turns on spooling of saves that fail because their backend is
+// down
+type SpoolConfig struct {
+	Enabled bool `json:"enabled"`
+	// Dir should be on a different disk than the file storage, so the spool
+	// survives the outages it is meant to bridge
+	Dir string `json:"dir"`
+	// MaxBytes caps the payload bytes waiting in the spool; saves failing
+	// while it is full fail as they would without a spool
+	MaxBytes int64 `json:"max_bytes"`
+	// ReplayInterval is how often spooled saves are retried
+	ReplayInterval Duration `json:"replay_interval"`
+}
@@ -0,0 +1,14 @@
+package config
+
+// StorageAccessRule limits the storage types of the API keys it names and
+// of keys holding one of its roles; a rule naming neither applies to every
+// key. A storage type is permitted unless a matching rule denies it or
+// matching rules allow only others.
+type StorageAccessRule struct {
+	Keys  []string `json:"keys"`
+	Roles []string `json:"roles"`
+	// Allow lists the storage types permitted; empty permits every one not
+	// denied
+	Allow []string `json:"allow"`
+	Deny  []string `json:"deny"`
+}
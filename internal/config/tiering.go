@@ -0,0 +1,15 @@
+package config
+
+// TieringConfig moves items that haven't changed for a while from their
+// storage type, the hot tier, to a cheaper one, the cold tier. Items are
+// read from whichever tier holds them.
+type TieringConfig struct {
+	// ColdTiers maps a storage type to the storage type its cold items are
+	// moved to. A cold tier should serve no clients of its own, as they
+	// would see the items moved there.
+	ColdTiers map[string]string `json:"cold_tiers"`
+	// After is how long an item stays unchanged before it is moved
+	After Duration `json:"after"`
+	// Interval is how often the transition job runs; zero disables it
+	Interval Duration `json:"interval"`
+}
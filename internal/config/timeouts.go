@@ -0,0 +1,13 @@
+package config
+
+// TimeoutConfig gives data operations deadlines. An operation still running
+// at its deadline fails with 504; as backends can't be interrupted, its
+// writes may still complete.
+type TimeoutConfig struct {
+	// Operations are deadlines by operation: "save", "update", "get",
+	// "delete", "list" and "search"
+	Operations map[string]Duration `json:"operations"`
+	// StorageTypes are deadlines by storage type, such as a generous one
+	// for a remote backend. They take precedence over Operations.
+	StorageTypes map[string]Duration `json:"storage_types"`
+}
@@ -0,0 +1,13 @@
+package config
+
+// SoftDeleteConfig turns deletes into moves to the trash. Trashed items keep
+// counting against quotas until they are purged.
+type SoftDeleteConfig struct {
+	Enabled bool `json:"enabled"`
+	// GracePeriod is how long a trashed item can be restored before the
+	// purge job removes it
+	GracePeriod Duration `json:"grace_period"`
+	// PurgeInterval is how often the purge job runs; zero leaves purging to
+	// the purge_trash admin operation
+	PurgeInterval Duration `json:"purge_interval"`
+}
@@ -0,0 +1,14 @@
+package config
+
+// ValidatorConfig adds one validator to the pipeline. Type selects a built-in
+// ("size", "schema", "content_type") or a validator registered with
+// RegisterValidator; StorageTypes restricts it to those storage types.
+type ValidatorConfig struct {
+	Type         string                `json:"type"`
+	StorageTypes []string              `json:"storage_types"`
+	MaxBytes     int                   `json:"max_bytes"`
+	ContentTypes []string              `json:"content_types"`
+	Schemas      []PayloadSchemaConfig `json:"schemas"`
+	// Params carries settings for registered validators
+	Params map[string]string `json:"params"`
+}
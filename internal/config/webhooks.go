@@ -0,0 +1,51 @@
+package config
+
+import (
+	"fmt"
+	"net/url"
+)
+
+// WebhookConfig configures outbound notifications of completed saves
+type WebhookConfig struct {
+	Endpoints []WebhookEndpoint `json:"endpoints"`
+	// MaxAttempts is how often a delivery is tried before it is
+	// dead-lettered
+	MaxAttempts int `json:"max_attempts"`
+	// Retries back off exponentially from InitialBackoff up to MaxBackoff
+	InitialBackoff Duration `json:"initial_backoff"`
+	MaxBackoff     Duration `json:"max_backoff"`
+	// Timeout bounds each delivery attempt
+	Timeout Duration `json:"timeout"`
+	// Workers deliver concurrently from a queue of QueueSize deliveries;
+	// deliveries that don't fit are dead-lettered
+	Workers   int `json:"workers"`
+	QueueSize int `json:"queue_size"`
+	// DeadLetterFile receives deliveries that failed for good, one JSON
+	// object per line
+	DeadLetterFile string `json:"dead_letter_file"`
+}
+
+// WebhookEndpoint is a URL notified of saves. Empty filters match all.
+type WebhookEndpoint struct {
+	URL string `json:"url"`
+	// Secret signs deliveries in the X-Webhook-Signature header
+	Secret       string   `json:"secret"`
+	Tenants      []string `json:"tenants"`
+	StorageTypes []string `json:"storage_types"`
+}
+
+func (c *WebhookConfig) Validate() error {
+	for _, endpoint := range c.Endpoints {
+		u, err := url.Parse(endpoint.URL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("invalid webhook URL: %q", endpoint.URL)
+		}
+		if endpoint.Secret == "" {
+			return fmt.Errorf("webhook %s has no secret", endpoint.URL)
+		}
+	}
+	if len(c.Endpoints) > 0 && (c.MaxAttempts < 1 || c.Workers < 1 || c.QueueSize < 1) {
+		return fmt.Errorf("max_attempts, workers and queue_size must be positive")
+	}
+	return nil
+}
@@ -0,0 +1,26 @@
+// Package logging holds the logger the internal packages report failures
+// and background job progress to. The server points it at its own logger.
+package logging
+
+import (
+	"fmt"
+	"log"
+	"sync/atomic"
+)
+
+var logger atomic.Pointer[log.Logger]
+
+func init() {
+	logger.Store(log.Default())
+}
+
+// SetLogger makes the internal packages log to l. The logger is shared by
+// every server in the process.
+func SetLogger(l *log.Logger) {
+	logger.Store(l)
+}
+
+// Printf logs to the current logger, in the manner of log.Printf
+func Printf(format string, v ...any) {
+	logger.Load().Output(2, fmt.Sprintf(format, v...))
+}
@@ -0,0 +1,172 @@
+// Package metrics counts server events for Prometheus scrapes.
+package metrics
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Metrics - IMPLEMENTS a minimal counter registry served in the Prometheus
+// text format at /metrics. Values owned by other components are read when
+// scraped.
+type Metrics struct {
+	mu       sync.Mutex
+	help     map[string]string
+	counters map[string]map[string]*counter
+	funcs    map[string]metricFunc
+}
+
+// counter is one labelled series of a counter
+type counter struct {
+	labels []string
+	value  int64
+}
+
+// Sample is the value of one series of a counter
+type Sample struct {
+	Labels map[string]string `json:"labels,omitempty"`
+	Value  int64             `json:"value"`
+}
+
+// metricFunc is a metric read from its owner at scrape time
+type metricFunc struct {
+	kind string
+	read func() int64
+}
+
+func NewMetrics() *Metrics {
+	return &Metrics{
+		help:     make(map[string]string),
+		counters: make(map[string]map[string]*counter),
+		funcs:    make(map[string]metricFunc),
+	}
+}
+
+// Describe sets the help text of a counter, which is exported at zero until
+// it is first incremented
+func (m *Metrics) Describe(name, help string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.help[name] = help
+	if m.counters[name] == nil {
+		m.counters[name] = make(map[string]*counter)
+	}
+}
+
+// Add increments a counter. labels are name/value pairs.
+func (m *Metrics) Add(name string, delta int64, labels ...string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	series := m.counters[name]
+	if series == nil {
+		series = make(map[string]*counter)
+		m.counters[name] = series
+	}
+	key := formatLabels(labels)
+	if series[key] == nil {
+		series[key] = &counter{labels: slices.Clone(labels)}
+	}
+	series[key].value += delta
+}
+
+// Samples returns every series of a metric, for reading it back in
+// process. Metrics read from their owner have a single series.
+func (m *Metrics) Samples(name string) []Sample {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if f, ok := m.funcs[name]; ok {
+		return []Sample{{Value: f.read()}}
+	}
+	series := m.counters[name]
+	keys := make([]string, 0, len(series))
+	for key := range series {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	samples := make([]Sample, 0, len(keys))
+	for _, key := range keys {
+		sample := Sample{Value: series[key].value}
+		labels := series[key].labels
+		if len(labels) > 0 {
+			sample.Labels = make(map[string]string, len(labels)/2)
+		}
+		for i := 0; i+1 < len(labels); i += 2 {
+			sample.Labels[labels[i]] = labels[i+1]
+		}
+		samples = append(samples, sample)
+	}
+	return samples
+}
+
+// GaugeFunc exports a gauge whose value is read on every scrape
+func (m *Metrics) GaugeFunc(name, help string, read func() int64) {
+	m.registerFunc(name, help, "gauge", read)
+}
+
+// CounterFunc exports a counter kept by its owner, read on every scrape
+func (m *Metrics) CounterFunc(name, help string, read func() int64) {
+	m.registerFunc(name, help, "counter", read)
+}
+
+func (m *Metrics) registerFunc(name, help, kind string, read func() int64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.help[name] = help
+	m.funcs[name] = metricFunc{kind: kind, read: read}
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func formatLabels(labels []string) string {
+	if len(labels) == 0 {
+		return ""
+	}
+	pairs := make([]string, 0, len(labels)/2)
+	for i := 0; i+1 < len(labels); i += 2 {
+		pairs = append(pairs, fmt.Sprintf(`%s="%s"`, labels[i], labelEscaper.Replace(labels[i+1])))
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+func (m *Metrics) HandleMetrics(w http.ResponseWriter, r *http.Request) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	names := make([]string, 0, len(m.counters)+len(m.funcs))
+	for name := range m.counters {
+		names = append(names, name)
+	}
+	for name := range m.funcs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	for _, name := range names {
+		if help := m.help[name]; help != "" {
+			fmt.Fprintf(w, "# HELP %s %s\n", name, help)
+		}
+		if f, ok := m.funcs[name]; ok {
+			fmt.Fprintf(w, "# TYPE %s %s\n%s %d\n", name, f.kind, name, f.read())
+			continue
+		}
+		fmt.Fprintf(w, "# TYPE %s counter\n", name)
+		series := m.counters[name]
+		if len(series) == 0 {
+			fmt.Fprintf(w, "%s 0\n", name)
+			continue
+		}
+		labels := make([]string, 0, len(series))
+		for label := range series {
+			labels = append(labels, label)
+		}
+		sort.Strings(labels)
+		for _, label := range labels {
+			fmt.Fprintf(w, "%s%s %d\n", name, label, series[label].value)
+		}
+	}
+}
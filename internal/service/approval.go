@@ -0,0 +1,303 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"interview-task/internal/logging"
+	"interview-task/internal/storage"
+)
+
+// Approval states of a pending operation
+const (
+	ApprovalPending  = "pending"
+	ApprovalApproved = "approved"
+	ApprovalExecuted = "executed"
+	ApprovalFailed   = "failed"
+	ApprovalRejected = "rejected"
+	ApprovalExpired  = "expired"
+)
+
+// Audit actions recorded for the approval workflow
+const (
+	AuditActionApprovalRequest = "approval_request"
+	AuditActionApprovalGrant   = "approval_grant"
+	AuditActionApprovalReject  = "approval_reject"
+)
+
+var (
+	ErrApprovalConflict = errors.New("operation is not pending approval")
+	ErrSelfApproval     = errors.New("operation must be approved by a different admin")
+)
+
+// AdminOperation is a destructive action that needs a second admin's approval
+type AdminOperation func(ctx context.Context, params map[string]string) (any, error)
+
+// PendingOperation tracks an operation through the approval workflow
+type PendingOperation struct {
+	ID          string            `json:"id"`
+	Operation   string            `json:"operation"`
+	Params      map[string]string `json:"params"`
+	Tenant      string            `json:"tenant"`
+	RequestedBy string            `json:"requested_by"`
+	RequestedAt time.Time         `json:"requested_at"`
+	ExpiresAt   time.Time         `json:"expires_at"`
+	State       string            `json:"state"`
+	DecidedBy   string            `json:"decided_by,omitempty"`
+	DecidedAt   time.Time         `json:"decided_at,omitempty"`
+	Result      any               `json:"result,omitempty"`
+	Error       string            `json:"error,omitempty"`
+}
+
+// ApprovalManager - IMPLEMENTS dual control for destructive admin operations.
+// Pending operations live in memory, so a restart discards them rather than
+// leaving stale approvals around.
+type ApprovalManager struct {
+	ttl   time.Duration
+	audit AuditSink
+
+	mu         sync.Mutex
+	operations map[string]AdminOperation
+	pending    map[string]*PendingOperation
+}
+
+func NewApprovalManager(ttl time.Duration, audit AuditSink) *ApprovalManager {
+	return &ApprovalManager{
+		ttl:        ttl,
+		audit:      audit,
+		operations: make(map[string]AdminOperation),
+		pending:    make(map[string]*PendingOperation),
+	}
+}
+
+// Register makes an operation available through the approval workflow
+func (m *ApprovalManager) Register(name string, operation AdminOperation) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.operations[name] = operation
+}
+
+// Request records an operation as pending approval
+func (m *ApprovalManager) Request(ctx context.Context, operation string, params map[string]string) (*PendingOperation, error) {
+	m.mu.Lock()
+	_, known := m.operations[operation]
+	m.mu.Unlock()
+	if !known {
+		return nil, fmt.Errorf("%w: unknown operation %s", ErrValidation, operation)
+	}
+
+	id, err := NewItemID()
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now().UTC()
+	pending := &PendingOperation{
+		ID:          id,
+		Operation:   operation,
+		Params:      params,
+		Tenant:      TenantFromContext(ctx),
+		RequestedBy: PrincipalFromContext(ctx).Name,
+		RequestedAt: now,
+		ExpiresAt:   now.Add(m.ttl),
+		State:       ApprovalPending,
+	}
+
+	m.mu.Lock()
+	m.pending[id] = pending
+	snapshot := *pending
+	m.mu.Unlock()
+
+	m.record(ctx, AuditActionApprovalRequest, &snapshot, nil)
+	return &snapshot, nil
+}
+
+// Approve executes a pending operation on behalf of a second admin
+func (m *ApprovalManager) Approve(ctx context.Context, id string) (*PendingOperation, error) {
+	pending, operation, err := m.decide(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	// Run in the tenant the operation was requested for
+	result, err := operation(WithTenant(ctx, pending.Tenant), pending.Params)
+
+	m.mu.Lock()
+	pending.Result = result
+	pending.State = ApprovalExecuted
+	if err != nil {
+		pending.State = ApprovalFailed
+		pending.Error = err.Error()
+	}
+	snapshot := *pending
+	m.mu.Unlock()
+
+	m.record(ctx, AuditActionApprovalGrant, &snapshot, err)
+	return &snapshot, err
+}
+
+// Reject closes a pending operation without running it. Any admin may
+// reject, including the requester withdrawing their own request.
+func (m *ApprovalManager) Reject(ctx context.Context, id string) (*PendingOperation, error) {
+	m.mu.Lock()
+	pending, ok := m.pending[id]
+	if !ok {
+		m.mu.Unlock()
+		return nil, fmt.Errorf("operation %s: %w", id, storage.ErrNotFound)
+	}
+	if err := m.expireLocked(pending); err != nil {
+		m.mu.Unlock()
+		return nil, err
+	}
+	pending.State = ApprovalRejected
+	pending.DecidedBy = PrincipalFromContext(ctx).Name
+	pending.DecidedAt = time.Now().UTC()
+	snapshot := *pending
+	m.mu.Unlock()
+
+	m.record(ctx, AuditActionApprovalReject, &snapshot, nil)
+	return &snapshot, nil
+}
+
+// decide claims a pending operation for execution by the calling admin
+func (m *ApprovalManager) decide(ctx context.Context, id string) (*PendingOperation, AdminOperation, error) {
+	approver := PrincipalFromContext(ctx)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	pending, ok := m.pending[id]
+	if !ok {
+		return nil, nil, fmt.Errorf("operation %s: %w", id, storage.ErrNotFound)
+	}
+	if err := m.expireLocked(pending); err != nil {
+		return nil, nil, err
+	}
+	if approver.Name == pending.RequestedBy {
+		return nil, nil, ErrSelfApproval
+	}
+	if approver.Tenant != "" && approver.Tenant != pending.Tenant {
+		return nil, nil, fmt.Errorf("operation %s: %w", id, storage.ErrNotFound)
+	}
+
+	// Mark it decided before releasing the lock so it can't run twice
+	pending.State = ApprovalApproved
+	pending.DecidedBy = approver.Name
+	pending.DecidedAt = time.Now().UTC()
+	return pending, m.operations[pending.Operation], nil
+}
+
+// expireLocked fails unless the operation is still pending; callers hold m.mu
+func (m *ApprovalManager) expireLocked(pending *PendingOperation) error {
+	if pending.State == ApprovalPending && time.Now().After(pending.ExpiresAt) {
+		pending.State = ApprovalExpired
+	}
+	if pending.State != ApprovalPending {
+		return fmt.Errorf("%w: %s is %s", ErrApprovalConflict, pending.ID, pending.State)
+	}
+	return nil
+}
+
+// List returns all known operations, newest first
+func (m *ApprovalManager) List() []PendingOperation {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	operations := make([]PendingOperation, 0, len(m.pending))
+	for _, pending := range m.pending {
+		m.expireLocked(pending)
+		operations = append(operations, *pending)
+	}
+	sort.Slice(operations, func(i, j int) bool {
+		return operations[i].RequestedAt.After(operations[j].RequestedAt)
+	})
+	return operations
+}
+
+func (m *ApprovalManager) record(ctx context.Context, action string, pending *PendingOperation, err error) {
+	event := AuditEvent{
+		Time:        time.Now().UTC(),
+		Actor:       PrincipalFromContext(ctx).Name,
+		Tenant:      pending.Tenant,
+		RequestID:   RequestIDFromContext(ctx),
+		Action:      action,
+		StorageType: pending.Params["storage_type"],
+		ItemID:      pending.ID,
+		Detail:      pending.Operation,
+		Outcome:     "success",
+	}
+	if addr, ok := ClientIPFromContext(ctx); ok {
+		event.ClientIP = addr.String()
+	}
+	if err != nil {
+		event.Outcome = "failure"
+		event.Error = err.Error()
+	}
+	if err := m.audit.Append(event); err != nil {
+		logging.Printf("Failed to record audit event: %v", err)
+	}
+}
+
+// BulkDeleteOperation deletes a comma-separated list of item IDs
+func BulkDeleteOperation(service *DataService) AdminOperation {
+	return func(ctx context.Context, params map[string]string) (any, error) {
+		if params["ids"] == "" {
+			return nil, fmt.Errorf("%w: ids are required", ErrValidation)
+		}
+		return deleteItems(ctx, service, params["storage_type"], strings.Split(params["ids"], ","))
+	}
+}
+
+// DeleteTenantOperation deletes every item a tenant holds in a storage type
+func DeleteTenantOperation(service *DataService) AdminOperation {
+	return func(ctx context.Context, params map[string]string) (any, error) {
+		tenant := params["tenant"]
+		if err := ValidateTenant(tenant); err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrValidation, err)
+		}
+		if bound := PrincipalFromContext(ctx).Tenant; bound != "" && bound != tenant {
+			return nil, fmt.Errorf("%w: key is bound to another tenant", ErrValidation)
+		}
+
+		ctx = WithTenant(ctx, tenant)
+		ids, err := service.ListItems(ctx, params["storage_type"])
+		if err != nil {
+			return nil, err
+		}
+		return deleteItems(ctx, service, params["storage_type"], ids)
+	}
+}
+
+// BulkResult summarizes a multi-item operation
+type BulkResult struct {
+	Succeeded int               `json:"succeeded"`
+	Failed    map[string]string `json:"failed,omitempty"`
+}
+
+func deleteItems(ctx context.Context, service *DataService, storageType string, ids []string) (*BulkResult, error) {
+	return applyToItems(ids, "deletes", func(id string) error {
+		return service.DeleteData(ctx, storageType, id)
+	})
+}
+
+// applyToItems runs fn for every ID, collecting failures instead of stopping
+// at the first one
+func applyToItems(ids []string, plural string, fn func(id string) error) (*BulkResult, error) {
+	result := &BulkResult{}
+	for _, id := range ids {
+		if err := fn(strings.TrimSpace(id)); err != nil {
+			if result.Failed == nil {
+				result.Failed = make(map[string]string)
+			}
+			result.Failed[id] = err.Error()
+			continue
+		}
+		result.Succeeded++
+	}
+	if len(result.Failed) > 0 {
+		return result, fmt.Errorf("%d of %d %s failed", len(result.Failed), len(ids), plural)
+	}
+	return result, nil
+}
@@ -0,0 +1,276 @@
+package service
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"interview-task/internal/config"
+	"interview-task/internal/logging"
+	"interview-task/internal/storage"
+)
+
+// Audit actions recorded for data mutations
+const (
+	AuditActionSave   = "save"
+	AuditActionDelete = "delete"
+	AuditActionUpdate = "update"
+)
+
+// AuditEvent records a single data mutation and its outcome
+type AuditEvent struct {
+	Time          time.Time `json:"time"`
+	Actor         string    `json:"actor"`
+	Tenant        string    `json:"tenant"`
+	ClientIP      string    `json:"client_ip,omitempty"`
+	RequestID     string    `json:"request_id,omitempty"`
+	Action        string    `json:"action"`
+	StorageType   string    `json:"storage_type"`
+	ItemID        string    `json:"item_id,omitempty"`
+	Detail        string    `json:"detail,omitempty"`
+	PayloadSHA256 string    `json:"payload_sha256,omitempty"`
+	Size          int       `json:"size"`
+	Outcome       string    `json:"outcome"`
+	Error         string    `json:"error,omitempty"`
+}
+
+// AuditQuery filters audit events; zero values match everything
+type AuditQuery struct {
+	Actor     string
+	Tenant    string
+	Action    string
+	ItemID    string
+	RequestID string
+	Since     time.Time
+	Until     time.Time
+	Limit     int
+}
+
+func (q *AuditQuery) Matches(event *AuditEvent) bool {
+	if q.Actor != "" && event.Actor != q.Actor {
+		return false
+	}
+	if q.Tenant != "" && event.Tenant != q.Tenant {
+		return false
+	}
+	if q.Action != "" && event.Action != q.Action {
+		return false
+	}
+	if q.ItemID != "" && event.ItemID != q.ItemID {
+		return false
+	}
+	if q.RequestID != "" && event.RequestID != q.RequestID {
+		return false
+	}
+	if !q.Since.IsZero() && event.Time.Before(q.Since) {
+		return false
+	}
+	if !q.Until.IsZero() && !event.Time.Before(q.Until) {
+		return false
+	}
+	return true
+}
+
+// AuditSink is an append-only store for audit events, kept separate from
+// the application log
+type AuditSink interface {
+	Append(event AuditEvent) error
+	Query(query AuditQuery) ([]AuditEvent, error)
+	Close() error
+}
+
+// NewAuditSink creates the sink selected in the configuration
+func NewAuditSink(config *config.Configuration, database *storage.DatabaseConnection) (AuditSink, error) {
+	switch config.AuditSink {
+	case "file":
+		return NewFileAuditSink(config.AuditFile)
+	case "database":
+		if database == nil {
+			return nil, fmt.Errorf("database connection not available")
+		}
+		return &DatabaseAuditSink{db: database}, nil
+	case "none", "":
+		return &NopAuditSink{}, nil
+	default:
+		return nil, fmt.Errorf("unsupported audit sink: %s", config.AuditSink)
+	}
+}
+
+// recordAudit appends an event for a mutation and publishes successful
+// ones. Audit failures are logged rather than failing a mutation that
+// already happened.
+func (ds *DataService) recordAudit(ctx context.Context, action, storageType, id string, data []byte, err error) {
+	event := ds.appendAudit(ctx, action, storageType, id, data, err)
+	if err == nil && StorageEventActions[action] {
+		ds.events.Publish(event.storageEvent())
+	}
+}
+
+// appendAudit appends an event for a mutation without publishing it, for
+// mutations whose event was staged in the outbox
+func (ds *DataService) appendAudit(ctx context.Context, action, storageType, id string, data []byte, err error) AuditEvent {
+	event := newAuditEvent(ctx, action, storageType, id, data, err)
+	if err := ds.audit.Append(event); err != nil {
+		logging.Printf("Failed to record audit event: %v", err)
+	}
+	return event
+}
+
+func newAuditEvent(ctx context.Context, action, storageType, id string, data []byte, err error) AuditEvent {
+	event := AuditEvent{
+		Time:        time.Now().UTC(),
+		Actor:       PrincipalFromContext(ctx).Name,
+		Tenant:      TenantFromContext(ctx),
+		RequestID:   RequestIDFromContext(ctx),
+		Action:      action,
+		StorageType: storageType,
+		ItemID:      id,
+		Detail:      auditDetailFromContext(ctx),
+		Size:        len(data),
+		Outcome:     "success",
+	}
+	if addr, ok := ClientIPFromContext(ctx); ok {
+		event.ClientIP = addr.String()
+	}
+	if len(data) > 0 {
+		event.PayloadSHA256 = storage.PayloadSHA256(data)
+	}
+	if err != nil {
+		event.Outcome = "failure"
+		event.Error = err.Error()
+	}
+	return event
+}
+
+// storageEvent is the event published for a successful mutation
+func (e *AuditEvent) storageEvent() StorageEvent {
+	return StorageEvent{
+		Time:          e.Time,
+		Type:          e.Action,
+		Tenant:        e.Tenant,
+		StorageType:   e.StorageType,
+		ItemID:        e.ItemID,
+		Size:          e.Size,
+		PayloadSHA256: e.PayloadSHA256,
+		RequestID:     e.RequestID,
+	}
+}
+
+// FileAuditSink appends events as JSON lines to a dedicated file
+type FileAuditSink struct {
+	mu   sync.Mutex
+	path string
+	file *os.File
+}
+
+func NewFileAuditSink(path string) (*FileAuditSink, error) {
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open audit file: %w", err)
+	}
+	return &FileAuditSink{path: path, file: file}, nil
+}
+
+func (s *FileAuditSink) Append(event AuditEvent) error {
+	line, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to encode audit event: %w", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, err := s.file.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("failed to write audit event: %w", err)
+	}
+	return s.file.Sync()
+}
+
+func (s *FileAuditSink) Query(query AuditQuery) ([]AuditEvent, error) {
+	// Hold the lock so a concurrent append can't be read half-written
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	file, err := os.Open(s.path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open audit file: %w", err)
+	}
+	defer file.Close()
+
+	var events []AuditEvent
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var event AuditEvent
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+			return nil, fmt.Errorf("corrupt audit record: %w", err)
+		}
+		if query.Matches(&event) {
+			events = append(events, event)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read audit file: %w", err)
+	}
+	return limitAuditEvents(events, query.Limit), nil
+}
+
+func (s *FileAuditSink) Close() error {
+	return s.file.Close()
+}
+
+// DatabaseAuditSink stores events in the audit table of the database
+type DatabaseAuditSink struct {
+	db *storage.DatabaseConnection
+}
+
+func (s *DatabaseAuditSink) Append(event AuditEvent) error {
+	record, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to encode audit event: %w", err)
+	}
+	return s.db.AppendAudit(record)
+}
+
+func (s *DatabaseAuditSink) Query(query AuditQuery) ([]AuditEvent, error) {
+	records, err := s.db.AuditRecords()
+	if err != nil {
+		return nil, err
+	}
+	var events []AuditEvent
+	for _, record := range records {
+		var event AuditEvent
+		if err := json.Unmarshal(record, &event); err != nil {
+			return nil, fmt.Errorf("failed to decode audit event: %w", err)
+		}
+		if query.Matches(&event) {
+			events = append(events, event)
+		}
+	}
+	return limitAuditEvents(events, query.Limit), nil
+}
+
+func (s *DatabaseAuditSink) Close() error {
+	// The connection is owned and closed by the server
+	return nil
+}
+
+// NopAuditSink discards events when auditing is disabled
+type NopAuditSink struct{}
+
+func (s *NopAuditSink) Append(event AuditEvent) error { return nil }
+
+func (s *NopAuditSink) Query(query AuditQuery) ([]AuditEvent, error) { return nil, nil }
+
+func (s *NopAuditSink) Close() error { return nil }
+
+// limitAuditEvents keeps the most recent events when a limit is set
+func limitAuditEvents(events []AuditEvent, limit int) []AuditEvent {
+	if limit > 0 && len(events) > limit {
+		return events[len(events)-limit:]
+	}
+	return events
+}
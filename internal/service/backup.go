@@ -0,0 +1,397 @@
+package service
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"time"
+
+	"interview-task/internal/config"
+	"interview-task/internal/logging"
+	"interview-task/internal/metrics"
+	"interview-task/internal/storage"
+)
+
+// Backups are gzipped tar archives. Every key is an entry named
+// items/<storage type>/<tenant>/<key> carrying its checksum as a PAX record,
+// and manifest.json, the last entry, lists them all.
+const (
+	BackupFormatVersion = 1
+
+	backupManifestName   = "manifest.json"
+	backupItemsDir       = "items/"
+	backupChecksumRecord = "DATASERVICE.sha256"
+	backupKeyPrefix      = "backup-"
+	backupKeySuffix      = ".tar.gz"
+)
+
+// maxRestoreEntryBytes bounds the payload of one archive entry
+const maxRestoreEntryBytes = 1 << 30
+
+// BackupEntry is a key in a backup archive
+type BackupEntry struct {
+	StorageType string `json:"storage_type"`
+	Tenant      string `json:"tenant"`
+	Key         string `json:"key"`
+	Size        int    `json:"size"`
+	SHA256      string `json:"sha256"`
+}
+
+// BackupManifest describes what a backup archive holds
+type BackupManifest struct {
+	FormatVersion int           `json:"format_version"`
+	CreatedAt     time.Time     `json:"created_at"`
+	Entries       []BackupEntry `json:"entries"`
+}
+
+// BackupFilter restricts a backup; empty fields back up everything
+type BackupFilter struct {
+	StorageTypes []string
+	Tenants      []string
+}
+
+// RestoreFailure is an archive entry that couldn't be restored
+type RestoreFailure struct {
+	Entry string `json:"entry"`
+	Error string `json:"error"`
+}
+
+// RestoreReport sums up a restore. Complete is set when the archive held
+// its manifest and every entry the manifest lists was restored.
+type RestoreReport struct {
+	Restored int              `json:"restored"`
+	Failed   int              `json:"failed"`
+	Failures []RestoreFailure `json:"failures,omitempty"`
+	Complete bool             `json:"complete"`
+	Error    string           `json:"error,omitempty"`
+}
+
+// BackupManager - IMPLEMENTS backing up and restoring every tenant's keys
+// as stored, below deduplication, like the Migrator, and the scheduled job
+// shipping backups to a secondary storage type
+type BackupManager struct {
+	factory *storage.ConcreteStorageFactory
+	config  config.BackupConfig
+	metrics *metrics.Metrics
+	leader  *LeaderElector
+
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+func NewBackupManager(factory *storage.ConcreteStorageFactory, config config.BackupConfig, metrics *metrics.Metrics, leader *LeaderElector) (*BackupManager, error) {
+	if config.Interval > 0 || config.StorageType != "" {
+		if !slices.Contains(storage.StorageTypes(), config.StorageType) {
+			return nil, fmt.Errorf("unknown storage type: %q", config.StorageType)
+		}
+		if err := ValidateTenant(config.Tenant); err != nil {
+			return nil, err
+		}
+	}
+	metrics.Describe("backups_total", "Scheduled backups shipped")
+	metrics.Describe("backup_failures_total", "Scheduled backups that failed")
+	return &BackupManager{factory: factory, config: config, metrics: metrics, leader: leader}, nil
+}
+
+// Backup writes an archive of the keys selected by filter to w. Unless
+// named in the filter, the tenant scheduled backups are shipped to is left
+// out. Nothing is written when the filter is invalid.
+func (b *BackupManager) Backup(ctx context.Context, w io.Writer, filter BackupFilter) (*BackupManifest, error) {
+	storageTypes := filter.StorageTypes
+	if len(storageTypes) == 0 {
+		storageTypes = storage.StorageTypes()
+	}
+	for _, storageType := range storageTypes {
+		if !slices.Contains(storage.StorageTypes(), storageType) {
+			return nil, fmt.Errorf("%w: unknown storage type %q", ErrValidation, storageType)
+		}
+	}
+	for _, tenant := range filter.Tenants {
+		if err := ValidateTenant(tenant); err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrValidation, err)
+		}
+	}
+
+	gz := gzip.NewWriter(w)
+	archive := tar.NewWriter(gz)
+	manifest := &BackupManifest{FormatVersion: BackupFormatVersion, CreatedAt: time.Now().UTC(), Entries: []BackupEntry{}}
+	for _, storageType := range storageTypes {
+		tenants, err := b.factory.Tenants(storageType)
+		if err != nil {
+			return nil, err
+		}
+		slices.Sort(tenants)
+		for _, tenant := range tenants {
+			if len(filter.Tenants) > 0 && !slices.Contains(filter.Tenants, tenant) ||
+				len(filter.Tenants) == 0 && tenant == b.config.Tenant {
+				continue
+			}
+			if err := b.backupTenant(ctx, archive, manifest, storageType, tenant); err != nil {
+				return nil, fmt.Errorf("failed to back up %s of tenant %s: %w", storageType, tenant, err)
+			}
+		}
+	}
+
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	header := &tar.Header{Name: backupManifestName, Mode: 0644, Size: int64(len(data)), ModTime: manifest.CreatedAt}
+	if err := archive.WriteHeader(header); err != nil {
+		return nil, err
+	}
+	if _, err := archive.Write(data); err != nil {
+		return nil, err
+	}
+	if err := archive.Close(); err != nil {
+		return nil, err
+	}
+	return manifest, gz.Close()
+}
+
+func (b *BackupManager) backupTenant(ctx context.Context, archive *tar.Writer, manifest *BackupManifest, storageType, tenant string) error {
+	source, err := b.factory.Sharded(tenant, storageType)
+	if err != nil {
+		return err
+	}
+	keys, err := source.List()
+	if err != nil {
+		return err
+	}
+	slices.Sort(keys)
+	for _, key := range keys {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		data, err := source.Load(key)
+		if errors.Is(err, storage.ErrNotFound) {
+			// Deleted since it was listed
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("failed to load %s: %w", key, err)
+		}
+		entry := BackupEntry{StorageType: storageType, Tenant: tenant, Key: key, Size: len(data), SHA256: storage.PayloadSHA256(data)}
+		header := &tar.Header{
+			Name:       backupItemsDir + storageType + "/" + tenant + "/" + key,
+			Mode:       0644,
+			Size:       int64(len(data)),
+			ModTime:    manifest.CreatedAt,
+			Format:     tar.FormatPAX,
+			PAXRecords: map[string]string{backupChecksumRecord: entry.SHA256},
+		}
+		if err := archive.WriteHeader(header); err != nil {
+			return err
+		}
+		if _, err := archive.Write(data); err != nil {
+			return err
+		}
+		manifest.Entries = append(manifest.Entries, entry)
+	}
+	return nil
+}
+
+// Restore saves every key of an archive written by Backup, replacing keys
+// stored under the same name. Entries are verified by checksum as they are
+// read; one that fails is reported and skipped. A corrupt or truncated
+// archive stops the restore with the entries before it restored.
+func (b *BackupManager) Restore(ctx context.Context, r io.Reader) (RestoreReport, error) {
+	var report RestoreReport
+	err := b.restore(ctx, r, &report)
+	if err != nil {
+		report.Error = err.Error()
+	}
+	return report, err
+}
+
+func (b *BackupManager) restore(ctx context.Context, r io.Reader, report *RestoreReport) error {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return fmt.Errorf("%w: archive is not gzipped: %w", ErrValidation, err)
+	}
+	archive := tar.NewReader(gz)
+
+	var manifest *BackupManifest
+	restored := make(map[BackupEntry]bool)
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		header, err := archive.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("%w: archive is corrupt: %w", ErrValidation, err)
+		}
+		if header.Name == backupManifestName {
+			manifest = &BackupManifest{}
+			if err := json.NewDecoder(archive).Decode(manifest); err != nil {
+				return fmt.Errorf("%w: invalid manifest: %w", ErrValidation, err)
+			}
+			if manifest.FormatVersion != BackupFormatVersion {
+				return fmt.Errorf("%w: unsupported backup format version %d", ErrValidation, manifest.FormatVersion)
+			}
+			continue
+		}
+		entry, err := b.restoreEntry(archive, header)
+		if err != nil {
+			report.Failed++
+			if len(report.Failures) < maxMigrationFailures {
+				report.Failures = append(report.Failures, RestoreFailure{Entry: header.Name, Error: err.Error()})
+			}
+			logging.Printf("Failed to restore %s: %v", header.Name, err)
+			continue
+		}
+		report.Restored++
+		restored[entry] = true
+	}
+
+	report.Complete = manifest != nil && report.Failed == 0
+	if manifest != nil {
+		for _, entry := range manifest.Entries {
+			report.Complete = report.Complete && restored[entry]
+		}
+	}
+	return nil
+}
+
+// restoreEntry verifies and saves one archive entry
+func (b *BackupManager) restoreEntry(archive *tar.Reader, header *tar.Header) (BackupEntry, error) {
+	path, ok := strings.CutPrefix(header.Name, backupItemsDir)
+	parts := strings.SplitN(path, "/", 3)
+	if !ok || len(parts) != 3 || parts[2] == "" || header.Typeflag != tar.TypeReg {
+		return BackupEntry{}, fmt.Errorf("%w: unexpected entry", ErrValidation)
+	}
+	entry := BackupEntry{StorageType: parts[0], Tenant: parts[1], Key: parts[2]}
+	if !slices.Contains(storage.StorageTypes(), entry.StorageType) {
+		return entry, fmt.Errorf("%w: unknown storage type %q", ErrValidation, entry.StorageType)
+	}
+	if err := ValidateTenant(entry.Tenant); err != nil {
+		return entry, fmt.Errorf("%w: %w", ErrValidation, err)
+	}
+	if header.Size > maxRestoreEntryBytes {
+		return entry, fmt.Errorf("%w: entry exceeds %d bytes", ErrValidation, maxRestoreEntryBytes)
+	}
+
+	data, err := io.ReadAll(archive)
+	if err != nil {
+		return entry, err
+	}
+	entry.Size, entry.SHA256 = len(data), storage.PayloadSHA256(data)
+	if want := header.PAXRecords[backupChecksumRecord]; want != "" && want != entry.SHA256 {
+		return entry, fmt.Errorf("%w: entry differs from the archived checksum", ErrChecksumMismatch)
+	}
+	target, err := b.factory.Sharded(entry.Tenant, entry.StorageType)
+	if err != nil {
+		return entry, err
+	}
+	if err := target.Save(entry.Key, data); err != nil {
+		return entry, fmt.Errorf("failed to save: %w", err)
+	}
+	b.factory.Invalidate(entry.Tenant, entry.StorageType, entry.Key)
+	return entry, nil
+}
+
+// Shipped returns an archive shipped by the scheduled job
+func (b *BackupManager) Shipped(key string) ([]byte, error) {
+	if !strings.HasPrefix(key, backupKeyPrefix) || !strings.HasSuffix(key, backupKeySuffix) {
+		return nil, fmt.Errorf("%w: %q is not a backup", ErrValidation, key)
+	}
+	if b.config.StorageType == "" {
+		return nil, fmt.Errorf("%w: scheduled backups are not configured", ErrValidation)
+	}
+	store, err := b.factory.CreateStorage(b.config.Tenant, b.config.StorageType)
+	if err != nil {
+		return nil, err
+	}
+	return store.Load(key)
+}
+
+// Start ships a backup every interval until Shutdown
+func (b *BackupManager) Start() {
+	if b.config.Interval <= 0 {
+		return
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	b.cancel, b.done = cancel, make(chan struct{})
+	go b.run(ctx, b.done)
+}
+
+func (b *BackupManager) run(ctx context.Context, done chan struct{}) {
+	defer close(done)
+	ticker := time.NewTicker(time.Duration(b.config.Interval))
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		// Only the elected instance runs scheduled jobs
+		if !b.leader.IsLeader() {
+			continue
+		}
+		key, err := b.Ship(ctx)
+		switch {
+		case errors.Is(err, context.Canceled):
+		case err != nil:
+			b.metrics.Add("backup_failures_total", 1)
+			logging.Printf("Scheduled backup failed: %v", err)
+		default:
+			b.metrics.Add("backups_total", 1)
+			logging.Printf("Shipped backup %s to %s", key, b.config.StorageType)
+		}
+	}
+}
+
+// Ship backs up everything to the configured storage type and deletes the
+// oldest backups beyond the number kept
+func (b *BackupManager) Ship(ctx context.Context) (string, error) {
+	var archive bytes.Buffer
+	if _, err := b.Backup(ctx, &archive, BackupFilter{}); err != nil {
+		return "", err
+	}
+	store, err := b.factory.CreateStorage(b.config.Tenant, b.config.StorageType)
+	if err != nil {
+		return "", err
+	}
+	key := backupKeyPrefix + time.Now().UTC().Format("20060102T150405Z") + backupKeySuffix
+	if err := store.Save(key, archive.Bytes()); err != nil {
+		return "", fmt.Errorf("failed to save %s: %w", key, err)
+	}
+	if b.config.Keep <= 0 {
+		return key, nil
+	}
+
+	keys, err := store.List()
+	if err != nil {
+		return key, fmt.Errorf("failed to list backups: %w", err)
+	}
+	keys = slices.DeleteFunc(keys, func(key string) bool {
+		return !strings.HasPrefix(key, backupKeyPrefix) || !strings.HasSuffix(key, backupKeySuffix)
+	})
+	// Timestamped keys sort oldest first
+	slices.Sort(keys)
+	for len(keys) > b.config.Keep {
+		if err := store.Delete(keys[0]); err != nil && !errors.Is(err, storage.ErrNotFound) {
+			return key, fmt.Errorf("failed to delete backup %s: %w", keys[0], err)
+		}
+		keys = keys[1:]
+	}
+	return key, nil
+}
+
+func (b *BackupManager) Shutdown() {
+	if b.cancel != nil {
+		b.cancel()
+		<-b.done
+	}
+}
@@ -0,0 +1,186 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"interview-task/internal/logging"
+	"interview-task/internal/storage"
+)
+
+// maxBatchItems bounds the items of one batch save
+const maxBatchItems = 1000
+
+// BatchSaveRequest saves several items in one call. Items without a
+// storage type use the batch's.
+type BatchSaveRequest struct {
+	StorageType string `json:"storage_type"`
+	// Atomic saves every item or none. The database storage type commits
+	// them in one transaction; other storage types save them one by one
+	// and delete the saved ones again when an item fails.
+	Atomic bool          `json:"atomic"`
+	Items  []SaveRequest `json:"items"`
+}
+
+// BatchItemResult reports one item of a batch, in request order
+type BatchItemResult struct {
+	ID     string `json:"id,omitempty"`
+	SHA256 string `json:"sha256,omitempty"`
+	// Spooled items are saved once their backend recovers
+	Spooled bool   `json:"spooled,omitempty"`
+	Error   string `json:"error,omitempty"`
+}
+
+// savedItem is an item written by an atomic batch that isn't final yet
+type savedItem struct {
+	id   string
+	size int64
+}
+
+// SaveBatch saves a batch of items. Without Atomic every item succeeds or
+// fails on its own; with it the error of the first failing item fails the
+// whole batch.
+func (ds *DataService) SaveBatch(ctx context.Context, batch *BatchSaveRequest) ([]BatchItemResult, error) {
+	if err := ds.maintenance.check(); err != nil {
+		return nil, err
+	}
+	if len(batch.Items) == 0 || len(batch.Items) > maxBatchItems {
+		return nil, fmt.Errorf("%w: a batch holds 1 to %d items", ErrValidation, maxBatchItems)
+	}
+	for i := range batch.Items {
+		item := &batch.Items[i]
+		if item.StorageType == "" {
+			item.StorageType = batch.StorageType
+		}
+		if batch.Atomic && item.StorageType != batch.StorageType {
+			return nil, fmt.Errorf("%w: item %d: atomic batches use a single storage type", ErrValidation, i)
+		}
+	}
+	// Items of other batches are checked as they are saved
+	if batch.Atomic {
+		if err := ds.checkAccess(ctx, batch.StorageType); err != nil {
+			return nil, err
+		}
+	}
+
+	if !batch.Atomic {
+		results := make([]BatchItemResult, len(batch.Items))
+		for i := range batch.Items {
+			id, err := ds.SaveData(ctx, &batch.Items[i])
+			if err != nil && !errors.Is(err, ErrSpooled) {
+				results[i].Error = err.Error()
+				continue
+			}
+			results[i] = BatchItemResult{ID: id, SHA256: storage.PayloadSHA256(batch.Items[i].Data), Spooled: err != nil}
+		}
+		return results, nil
+	}
+
+	ids := make([]string, len(batch.Items))
+	for i := range batch.Items {
+		id, err := NewItemID()
+		if err != nil {
+			return nil, err
+		}
+		ids[i] = id
+		if err := ds.hooks.beforeSave(ctx, AuditActionSave, id, &batch.Items[i]); err != nil {
+			return nil, fmt.Errorf("%w: item %d: %w", ErrValidation, i, err)
+		}
+		if err := ds.validator.ValidateRequest(ctx, &batch.Items[i]); err != nil {
+			return nil, fmt.Errorf("%w: item %d: %w", ErrValidation, i, err)
+		}
+		if err := ds.sealFields(&batch.Items[i]); err != nil {
+			return nil, fmt.Errorf("item %d: %w", i, err)
+		}
+	}
+	saved, staged, err := ds.saveAtomically(ctx, batch, ids)
+	for i, item := range saved {
+		if staged {
+			ds.appendAudit(ctx, AuditActionSave, batch.StorageType, item.id, batch.Items[i].Data, err)
+		} else {
+			ds.recordAudit(ctx, AuditActionSave, batch.StorageType, item.id, batch.Items[i].Data, err)
+		}
+		ds.hooks.afterSave(ctx, AuditActionSave, item.id, &batch.Items[i], err)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]BatchItemResult, len(saved))
+	for i, item := range saved {
+		results[i] = BatchItemResult{ID: item.id, SHA256: storage.PayloadSHA256(batch.Items[i].Data)}
+		ds.watermarks.Advance(TenantFromContext(ctx), AuditActionSave)
+	}
+	return results, nil
+}
+
+// saveAtomically writes every item of a validated batch under ids, or on
+// failure leaves none of them stored. It returns the items written before
+// the outcome was known, so they can be audited either way, and whether
+// their events were staged in the outbox.
+func (ds *DataService) saveAtomically(ctx context.Context, batch *BatchSaveRequest, ids []string) ([]savedItem, bool, error) {
+	tenant := TenantFromContext(ctx)
+	var store storage.StorageInterface
+	var tx *storage.StorageTx
+	err := fmt.Errorf("%w: %s", storage.ErrNotTransactional, batch.StorageType)
+	if factory, ok := ds.factory.(storage.TransactionalFactory); ok {
+		tx, err = factory.Begin(tenant, batch.StorageType)
+	}
+	switch {
+	case err == nil:
+		store = tx
+	case errors.Is(err, storage.ErrNotTransactional):
+		store, err = ds.factory.CreateStorage(tenant, batch.StorageType)
+	}
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to create storage: %w", err)
+	}
+	staged := tx != nil && ds.outbox.enabled()
+
+	var saved []savedItem
+	fail := func(err error) ([]savedItem, bool, error) {
+		if tx != nil {
+			tx.Rollback()
+		} else {
+			ds.compensate(ctx, store, batch.StorageType, saved)
+		}
+		owner := PrincipalFromContext(ctx).Name
+		for _, item := range saved {
+			ds.quotas.Release(tenant, owner, item.size, 1)
+		}
+		return saved, staged, err
+	}
+
+	for i, id := range ids {
+		if err := ds.saveItem(ctx, store, id, &batch.Items[i]); err != nil {
+			return fail(fmt.Errorf("item %d: %w", i, err))
+		}
+		saved = append(saved, savedItem{id: id, size: int64(len(batch.Items[i].Data))})
+		if staged {
+			event := newAuditEvent(ctx, AuditActionSave, batch.StorageType, id, batch.Items[i].Data, nil)
+			if err := ds.outbox.stage(tx, event.storageEvent()); err != nil {
+				return fail(fmt.Errorf("item %d: %w", i, err))
+			}
+		}
+	}
+	if tx != nil {
+		if err := tx.Commit(); err != nil {
+			return fail(err)
+		}
+	}
+	return saved, staged, nil
+}
+
+// compensate deletes the items a failed batch already saved to storage that
+// can't roll back. It is best effort: an item that can't be deleted is
+// logged and left behind.
+func (ds *DataService) compensate(ctx context.Context, store storage.StorageInterface, storageType string, saved []savedItem) {
+	for _, item := range saved {
+		if err := store.Delete(item.id); err != nil && !errors.Is(err, storage.ErrNotFound) {
+			logging.Printf("Failed to undo save of %s after a failed batch: %v", item.id, err)
+			continue
+		}
+		ds.deleteLinkedItems(ctx, store, storageType, item.id)
+	}
+}
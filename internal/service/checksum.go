@@ -0,0 +1,60 @@
+package service
+
+import (
+	"bytes"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+var ErrChecksumMismatch = errors.New("checksum mismatch")
+
+// Headers clients may send to have the decoded payload verified before it
+// is stored. Content-MD5 is base64 as in RFC 1864; the SHA-256 header takes
+// hex or base64.
+const (
+	HeaderContentMD5    = "Content-MD5"
+	HeaderContentSHA256 = "X-Content-SHA256"
+)
+
+// ChecksumsFromHeaders copies client-provided checksums onto the request
+func ChecksumsFromHeaders(r *http.Request, req *SaveRequest) error {
+	if value := r.Header.Get(HeaderContentMD5); value != "" {
+		sum, err := base64.StdEncoding.DecodeString(value)
+		if err != nil || len(sum) != md5.Size {
+			return fmt.Errorf("%w: malformed %s header", ErrValidation, HeaderContentMD5)
+		}
+		req.ExpectedMD5 = sum
+	}
+	if value := r.Header.Get(HeaderContentSHA256); value != "" {
+		sum, err := hex.DecodeString(value)
+		if err != nil {
+			sum, err = base64.StdEncoding.DecodeString(value)
+		}
+		if err != nil || len(sum) != sha256.Size {
+			return fmt.Errorf("%w: malformed %s header", ErrValidation, HeaderContentSHA256)
+		}
+		req.ExpectedSHA256 = sum
+	}
+	return nil
+}
+
+// verifyChecksums rejects payloads that don't match the checksums the client
+// computed, which means they were corrupted on the way
+func verifyChecksums(req *SaveRequest) error {
+	if req.ExpectedMD5 != nil {
+		if sum := md5.Sum(req.Data); !bytes.Equal(sum[:], req.ExpectedMD5) {
+			return fmt.Errorf("%w: payload does not match %s", ErrChecksumMismatch, HeaderContentMD5)
+		}
+	}
+	if req.ExpectedSHA256 != nil {
+		if sum := sha256.Sum256(req.Data); !bytes.Equal(sum[:], req.ExpectedSHA256) {
+			return fmt.Errorf("%w: payload does not match %s", ErrChecksumMismatch, HeaderContentSHA256)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,96 @@
+package service
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/http"
+	"slices"
+	"strings"
+
+	"interview-task/internal/config"
+)
+
+// executableSignatures maps magic numbers to the media types reported for
+// them; http.DetectContentType knows none of these
+var executableSignatures = []struct {
+	magic     []byte
+	mediaType string
+}{
+	{[]byte("\x7fELF"), "application/x-executable"},
+	{[]byte("MZ"), "application/vnd.microsoft.portable-executable"},
+	{[]byte("\xfe\xed\xfa\xce"), "application/x-mach-binary"},
+	{[]byte("\xfe\xed\xfa\xcf"), "application/x-mach-binary"},
+	{[]byte("\xce\xfa\xed\xfe"), "application/x-mach-binary"},
+	{[]byte("\xcf\xfa\xed\xfe"), "application/x-mach-binary"},
+}
+
+// detectContentType sniffs a payload's media type, without parameters. JSON
+// is recognized explicitly since http.DetectContentType reports it as text.
+func detectContentType(data []byte) string {
+	for _, signature := range executableSignatures {
+		if bytes.HasPrefix(data, signature.magic) {
+			return signature.mediaType
+		}
+	}
+	if json.Valid(data) {
+		return "application/json"
+	}
+	mediaType, _, err := mime.ParseMediaType(http.DetectContentType(data))
+	if err != nil {
+		return "application/octet-stream"
+	}
+	return mediaType
+}
+
+// contentTypePolicy enforces a ContentTypePolicy on saved payloads
+type contentTypePolicy config.ContentTypePolicy
+
+// resolveContentType fills in req.ContentType, normalizing a declared type
+// or sniffing one, and enforces the policy
+func (p *contentTypePolicy) resolveContentType(req *SaveRequest) error {
+	sniffed := detectContentType(req.Data)
+	if p.denies(sniffed) {
+		return fmt.Errorf("content type %s is not allowed", sniffed)
+	}
+
+	if req.ContentType == "" {
+		if p.Require {
+			return fmt.Errorf("content type must be declared")
+		}
+		req.ContentType = sniffed
+		return nil
+	}
+
+	mediaType, params, err := mime.ParseMediaType(req.ContentType)
+	if err != nil {
+		return fmt.Errorf("invalid content type %q", req.ContentType)
+	}
+	if p.denies(mediaType) {
+		return fmt.Errorf("content type %s is not allowed", mediaType)
+	}
+	req.ContentType = mime.FormatMediaType(mediaType, params)
+	return nil
+}
+
+func (p *contentTypePolicy) denies(mediaType string) bool {
+	return slices.ContainsFunc(p.Denied, func(pattern string) bool {
+		return matchesMediaType(pattern, mediaType)
+	})
+}
+
+// matchesMediaType compares a media type, ignoring parameters, against a
+// pattern such as "image/png", "image/*" or "*/*"
+func matchesMediaType(pattern, contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	pattern = strings.ToLower(pattern)
+	if pattern == "*/*" || pattern == mediaType {
+		return true
+	}
+	prefix, ok := strings.CutSuffix(pattern, "/*")
+	return ok && strings.HasPrefix(mediaType, prefix+"/")
+}
@@ -0,0 +1,123 @@
+package service
+
+import (
+	"context"
+	"net/netip"
+	"slices"
+	"sync"
+)
+
+// Request-scoped values set by middleware and read by the service layer
+type contextKey int
+
+const (
+	principalKey contextKey = iota
+	clientIPKey
+	tenantKey
+	endpointKey
+	requestIDKey
+	lockedItemKey
+	ifMatchKey
+	auditDetailKey
+	requestNotesKey
+)
+
+func WithPrincipal(ctx context.Context, principal *Principal) context.Context {
+	return context.WithValue(ctx, principalKey, principal)
+}
+
+// PrincipalFromContext returns the authenticated caller, or the anonymous
+// principal when authentication is disabled
+func PrincipalFromContext(ctx context.Context) *Principal {
+	if principal, ok := ctx.Value(principalKey).(*Principal); ok {
+		return principal
+	}
+	return anonymousPrincipal
+}
+
+func WithClientIP(ctx context.Context, addr netip.Addr) context.Context {
+	return context.WithValue(ctx, clientIPKey, addr)
+}
+
+func ClientIPFromContext(ctx context.Context) (netip.Addr, bool) {
+	addr, ok := ctx.Value(clientIPKey).(netip.Addr)
+	return addr, ok
+}
+
+func WithTenant(ctx context.Context, tenant string) context.Context {
+	return context.WithValue(ctx, tenantKey, tenant)
+}
+
+// TenantFromContext returns the tenant a request acts for, falling back to
+// the default tenant outside of HTTP requests
+func TenantFromContext(ctx context.Context) string {
+	if tenant, ok := ctx.Value(tenantKey).(string); ok {
+		return tenant
+	}
+	return DefaultTenant
+}
+
+// WithEndpoint records the route a request arrived on, so validation rules
+// can be scoped per endpoint
+func WithEndpoint(ctx context.Context, endpoint string) context.Context {
+	return context.WithValue(ctx, endpointKey, endpoint)
+}
+
+func endpointFromContext(ctx context.Context) string {
+	endpoint, _ := ctx.Value(endpointKey).(string)
+	return endpoint
+}
+
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey, id)
+}
+
+func RequestIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey).(string)
+	return id
+}
+
+// withAuditDetail explains the mutations of a background job in their
+// audit events
+func withAuditDetail(ctx context.Context, detail string) context.Context {
+	return context.WithValue(ctx, auditDetailKey, detail)
+}
+
+func auditDetailFromContext(ctx context.Context) string {
+	detail, _ := ctx.Value(auditDetailKey).(string)
+	return detail
+}
+
+// RequestNotes collects what the service layer learns while handling a
+// request, for logging it afterwards
+type RequestNotes struct {
+	mu       sync.Mutex
+	backends []string
+}
+
+// WithRequestNotes starts collecting notes on the request ctx belongs to
+func WithRequestNotes(ctx context.Context) (context.Context, *RequestNotes) {
+	notes := &RequestNotes{}
+	return context.WithValue(ctx, requestNotesKey, notes), notes
+}
+
+// Backends returns the storage types the request used, in order
+func (n *RequestNotes) Backends() []string {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return slices.Clone(n.backends)
+}
+
+// noteBackend records a storage type used by the request, if notes are
+// being collected
+func noteBackend(ctx context.Context, storageType string) {
+	notes, ok := ctx.Value(requestNotesKey).(*RequestNotes)
+	if !ok || storageType == "" {
+		return
+	}
+	notes.mu.Lock()
+	defer notes.mu.Unlock()
+	if !slices.Contains(notes.backends, storageType) {
+		notes.backends = append(notes.backends, storageType)
+	}
+}
@@ -0,0 +1,221 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"sync"
+	"text/template"
+
+	"interview-task/internal/config"
+	"interview-task/internal/logging"
+	"interview-task/internal/storage"
+)
+
+// Derivation is a compiled DerivationConfig
+type Derivation struct {
+	Name     string
+	Eager    bool
+	path     *JSONPath
+	template *template.Template
+}
+
+var derivationName = regexp.MustCompile(`^[a-z0-9_-]{1,64}$`)
+
+func NewDerivation(config config.DerivationConfig) (*Derivation, error) {
+	// Reserved names would collide with the service's own linked records
+	if !derivationName.MatchString(config.Name) || isReservedLinkName(config.Name) {
+		return nil, fmt.Errorf("invalid derivation name: %q", config.Name)
+	}
+
+	expr := config.Path
+	if expr == "" {
+		expr = "$"
+	}
+	path, err := ParseJSONPath(expr)
+	if err != nil {
+		return nil, err
+	}
+
+	derivation := &Derivation{Name: config.Name, Eager: config.Eager, path: path}
+	if config.Template != "" {
+		tmpl, err := template.New(config.Name).
+			Funcs(template.FuncMap{"json": templateJSON}).
+			Option("missingkey=zero").
+			Parse(config.Template)
+		if err != nil {
+			return nil, fmt.Errorf("invalid template for derivation %s: %w", config.Name, err)
+		}
+		derivation.template = tmpl
+	}
+	return derivation, nil
+}
+
+// ContentType describes the bytes produced by Compute
+func (d *Derivation) ContentType() string {
+	if d.template != nil {
+		return "text/plain; charset=utf-8"
+	}
+	return "application/json"
+}
+
+// Compute applies the derivation to a JSON payload
+func (d *Derivation) Compute(payload []byte) ([]byte, error) {
+	var doc any
+	if err := json.Unmarshal(payload, &doc); err != nil {
+		return nil, fmt.Errorf("payload is not structured JSON: %w", err)
+	}
+
+	selected := d.path.Select(doc)
+	var input any = selected
+	if d.path.Definite() {
+		input = nil
+		if len(selected) == 1 {
+			input = selected[0]
+		}
+	}
+
+	if d.template == nil {
+		return json.Marshal(input)
+	}
+	var buf bytes.Buffer
+	if err := d.template.Execute(&buf, input); err != nil {
+		return nil, fmt.Errorf("failed to render derivation %s: %w", d.Name, err)
+	}
+	return buf.Bytes(), nil
+}
+
+func templateJSON(value any) (string, error) {
+	encoded, err := json.Marshal(value)
+	return string(encoded), err
+}
+
+// DerivationRegistry holds the derivations known to the service
+type DerivationRegistry struct {
+	mu          sync.RWMutex
+	derivations map[string]*Derivation
+}
+
+func NewDerivationRegistry() *DerivationRegistry {
+	return &DerivationRegistry{derivations: make(map[string]*Derivation)}
+}
+
+// NewDerivationRegistryFromConfig compiles and registers configured derivations
+func NewDerivationRegistryFromConfig(configs []config.DerivationConfig) (*DerivationRegistry, error) {
+	registry := NewDerivationRegistry()
+	for _, config := range configs {
+		derivation, err := NewDerivation(config)
+		if err != nil {
+			return nil, err
+		}
+		if err := registry.Register(derivation); err != nil {
+			return nil, err
+		}
+	}
+	return registry, nil
+}
+
+func (r *DerivationRegistry) Register(derivation *Derivation) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, exists := r.derivations[derivation.Name]; exists {
+		return fmt.Errorf("derivation %s already registered", derivation.Name)
+	}
+	r.derivations[derivation.Name] = derivation
+	return nil
+}
+
+func (r *DerivationRegistry) Get(name string) (*Derivation, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	derivation, ok := r.derivations[name]
+	return derivation, ok
+}
+
+func (r *DerivationRegistry) All() []*Derivation {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	all := make([]*Derivation, 0, len(r.derivations))
+	for _, derivation := range r.derivations {
+		all = append(all, derivation)
+	}
+	return all
+}
+
+// derivedItemID links a derived item to its source item in the same backend
+func derivedItemID(id, name string) string {
+	return id + "~" + name
+}
+
+// materializeEager stores every eager derivation of a freshly saved payload.
+// Payloads that aren't JSON simply have nothing to derive.
+func (ds *DataService) materializeEager(storage storage.StorageInterface, id string, payload []byte) {
+	if !json.Valid(payload) {
+		return
+	}
+	for _, derivation := range ds.derivations.All() {
+		if !derivation.Eager {
+			continue
+		}
+		derived, err := derivation.Compute(payload)
+		if err == nil {
+			err = storage.Save(derivedItemID(id, derivation.Name), derived)
+		}
+		if err != nil {
+			logging.Printf("Failed to materialize derivation %s for %s: %v", derivation.Name, id, err)
+		}
+	}
+}
+
+// deleteDerived removes materialized derivations along with their source
+func (ds *DataService) deleteDerived(store storage.StorageInterface, id string) {
+	for _, derivation := range ds.derivations.All() {
+		err := store.Delete(derivedItemID(id, derivation.Name))
+		if err != nil && !errors.Is(err, storage.ErrNotFound) {
+			logging.Printf("Failed to delete derivation %s for %s: %v", derivation.Name, id, err)
+		}
+	}
+}
+
+// GetDerived returns a derived item, computing and storing it on first access
+func (ds *DataService) GetDerived(ctx context.Context, storageType, id, name string) ([]byte, *Derivation, error) {
+	if err := ds.validator.ValidateID(id); err != nil {
+		return nil, nil, fmt.Errorf("%w: %w", ErrValidation, err)
+	}
+	derivation, ok := ds.derivations.Get(name)
+	if !ok {
+		return nil, nil, fmt.Errorf("unknown derivation %s: %w", name, storage.ErrNotFound)
+	}
+
+	store, err := ds.openStorage(ctx, storageType)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w: %w", ErrValidation, err)
+	}
+	if err := ds.checkAvailable(store, id); err != nil {
+		return nil, nil, err
+	}
+
+	derived, err := store.Load(derivedItemID(id, name))
+	if err == nil {
+		return derived, derivation, nil
+	}
+	if !errors.Is(err, storage.ErrNotFound) {
+		return nil, nil, fmt.Errorf("failed to load derived item: %w", err)
+	}
+
+	payload, err := store.Load(id)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to load data: %w", err)
+	}
+	derived, err = derivation.Compute(payload)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w: %w", ErrValidation, err)
+	}
+	if err := store.Save(derivedItemID(id, name), derived); err != nil {
+		logging.Printf("Failed to materialize derivation %s for %s: %v", name, id, err)
+	}
+	return derived, derivation, nil
+}
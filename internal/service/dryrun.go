@@ -0,0 +1,64 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"interview-task/internal/storage"
+)
+
+// SavePlan is what a save would do, as reported by a dry run
+type SavePlan struct {
+	ID          string    `json:"id"`
+	Tenant      string    `json:"tenant"`
+	StorageType string    `json:"storage_type"`
+	ContentType string    `json:"content_type"`
+	Size        int64     `json:"size"`
+	SHA256      string    `json:"sha256"`
+	ExpiresAt   time.Time `json:"expires_at,omitzero"`
+}
+
+// PlanSave runs the checks of SaveData (before-save hooks, validation,
+// the storage type and quotas) and reports what it would store, without
+// writing, reserving or auditing anything. The ID is freshly generated
+// and isn't held for a later save.
+func (ds *DataService) PlanSave(ctx context.Context, req *SaveRequest) (*SavePlan, error) {
+	if err := ds.maintenance.check(); err != nil {
+		return nil, err
+	}
+	if err := ds.checkAccess(ctx, req.StorageType); err != nil {
+		return nil, err
+	}
+	id, err := NewItemID()
+	if err != nil {
+		return nil, err
+	}
+	if err := ds.hooks.beforeSave(ctx, AuditActionSave, id, req); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrValidation, err)
+	}
+	if err := ds.validator.ValidateRequest(ctx, req); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrValidation, err)
+	}
+	tenant := TenantFromContext(ctx)
+	if _, err := ds.factory.CreateStorage(tenant, req.StorageType); err != nil {
+		return nil, fmt.Errorf("failed to create storage: %w", err)
+	}
+	expiresAt, err := ds.expiry.ExpiresAt(req.TTL, time.Now())
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrValidation, err)
+	}
+	size := int64(len(req.Data))
+	if err := ds.quotas.Check(tenant, PrincipalFromContext(ctx).Name, size, 1); err != nil {
+		return nil, err
+	}
+	return &SavePlan{
+		ID:          id,
+		Tenant:      tenant,
+		StorageType: req.StorageType,
+		ContentType: req.ContentType,
+		Size:        size,
+		SHA256:      storage.PayloadSHA256(req.Data),
+		ExpiresAt:   expiresAt,
+	}, nil
+}
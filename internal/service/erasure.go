@@ -0,0 +1,449 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"interview-task/internal/config"
+	"interview-task/internal/logging"
+	"interview-task/internal/storage"
+)
+
+// Audit actions recorded for a data subject's items
+const (
+	AuditActionErase     = "erase"
+	AuditActionAnonymize = "anonymize"
+)
+
+// Erasure actions
+const (
+	ErasureDelete    = "delete"
+	ErasureAnonymize = "anonymize"
+)
+
+// What an erasure did about one of the subject's items
+const (
+	ErasureActionDeleted    = "deleted"
+	ErasureActionAnonymized = "anonymized"
+	ErasureActionFailed     = "failed"
+	// ErasureActionHeld is an item kept by a legal hold
+	ErasureActionHeld = "held"
+)
+
+const (
+	defaultErasureMask = "[ERASED]"
+	// ErasureSignatureAlgorithm signs erasure reports
+	ErasureSignatureAlgorithm = "ed25519"
+)
+
+// erasureNotes tell compliance records what an erasure doesn't reach
+var erasureNotes = []string{
+	"backups and snapshots taken before the erasure still hold the items until they are rotated out",
+	"values sealed by field encryption can't be matched or anonymized",
+}
+
+// errErasureDisabled is returned without a signing key
+var errErasureDisabled = fmt.Errorf("%w: subject erasure needs erasure.signing_key_file", storage.ErrConflict)
+
+// ErasureRequest names a data subject by identifiers stored with their
+// items. An item is the subject's when any of them matches.
+type ErasureRequest struct {
+	// Tags are labels, or tags of generated data, identifying the subject
+	Tags map[string]string `json:"tags,omitempty"`
+	// Fields are values of the fields extracted by the metadata rules
+	Fields map[string]string `json:"fields,omitempty"`
+	// Action is "delete", the default, or "anonymize", which masks the
+	// subject's identifiers in JSON payloads and drops earlier versions.
+	// Other payloads, and items in the trash, are deleted.
+	Action string `json:"action,omitempty"`
+}
+
+// ErasedItem is one of the subject's items. SHA256 is its payload's
+// checksum before the erasure.
+type ErasedItem struct {
+	StorageType string `json:"storage_type"`
+	ID          string `json:"id"`
+	Action      string `json:"action"`
+	SHA256      string `json:"sha256,omitempty"`
+	Error       string `json:"error,omitempty"`
+}
+
+// ErasureReport records an erasure for compliance. Complete is set when
+// every item of the subject was erased.
+type ErasureReport struct {
+	ID          string         `json:"id"`
+	Tenant      string         `json:"tenant"`
+	Request     ErasureRequest `json:"request"`
+	RequestedBy string         `json:"requested_by"`
+	StartedAt   time.Time      `json:"started_at"`
+	CompletedAt time.Time      `json:"completed_at"`
+	Scanned     int            `json:"scanned"`
+	Deleted     int            `json:"deleted"`
+	Anonymized  int            `json:"anonymized"`
+	Failed      int            `json:"failed"`
+	Held        int            `json:"held"`
+	Items       []ErasedItem   `json:"items"`
+	Complete    bool           `json:"complete"`
+	Notes       []string       `json:"notes"`
+}
+
+// SignedErasureReport carries a report as signed: Signature is the
+// base64-encoded signature of the exact bytes of Report
+type SignedErasureReport struct {
+	Report    json.RawMessage `json:"report"`
+	Algorithm string          `json:"algorithm"`
+	KeyID     string          `json:"key_id"`
+	Signature string          `json:"signature"`
+}
+
+// ErasureKey is the public key verifying erasure reports
+type ErasureKey struct {
+	Algorithm string `json:"algorithm"`
+	KeyID     string `json:"key_id"`
+	PublicKey string `json:"public_key"`
+}
+
+// SubjectEraser - IMPLEMENTS erasing a data subject's items in every
+// storage type of a tenant, with a signed report of what was erased. Each
+// erasure reads the metadata of every item of the tenant.
+type SubjectEraser struct {
+	service *DataService
+	factory storage.TenantLister
+	key     ed25519.PrivateKey
+	keyID   string
+	mask    string
+}
+
+// NewSubjectEraser returns nil without a signing key
+func NewSubjectEraser(service *DataService, factory storage.TenantLister, config config.ErasureConfig) (*SubjectEraser, error) {
+	if config.SigningKeyFile == "" {
+		return nil, nil
+	}
+	encoded, err := os.ReadFile(config.SigningKeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read erasure signing key: %w", err)
+	}
+	seed, err := hex.DecodeString(strings.TrimSpace(string(encoded)))
+	if err != nil || len(seed) != ed25519.SeedSize {
+		return nil, errors.New("erasure signing key must be a hex-encoded 32-byte seed")
+	}
+	key := ed25519.NewKeyFromSeed(seed)
+	sum := sha256.Sum256(key.Public().(ed25519.PublicKey))
+	e := &SubjectEraser{service: service, factory: factory, key: key, keyID: hex.EncodeToString(sum[:8]), mask: config.Mask}
+	if e.mask == "" {
+		e.mask = defaultErasureMask
+	}
+	return e, nil
+}
+
+// PublicKey returns the key verifying reports
+func (e *SubjectEraser) PublicKey() (ErasureKey, error) {
+	if e == nil {
+		return ErasureKey{}, errErasureDisabled
+	}
+	return ErasureKey{
+		Algorithm: ErasureSignatureAlgorithm,
+		KeyID:     e.keyID,
+		PublicKey: hex.EncodeToString(e.key.Public().(ed25519.PublicKey)),
+	}, nil
+}
+
+// Erase deletes or anonymizes the subject's items in every storage type of
+// the request's tenant, trash included, and returns the signed report.
+// Items that fail are reported and the rest still erased.
+func (e *SubjectEraser) Erase(ctx context.Context, req ErasureRequest) (*SignedErasureReport, error) {
+	if e == nil {
+		return nil, errErasureDisabled
+	}
+	switch req.Action {
+	case "":
+		req.Action = ErasureDelete
+	case ErasureDelete, ErasureAnonymize:
+	default:
+		return nil, fmt.Errorf("%w: unsupported erasure action %q", ErrValidation, req.Action)
+	}
+	identifiers := subjectIdentifiers(req)
+	if len(identifiers) == 0 {
+		return nil, fmt.Errorf("%w: an erasure needs tags or fields identifying the subject", ErrValidation)
+	}
+	if err := e.service.maintenance.check(); err != nil {
+		return nil, err
+	}
+	id, err := NewItemID()
+	if err != nil {
+		return nil, err
+	}
+
+	tenant := TenantFromContext(ctx)
+	report := &ErasureReport{
+		ID:          id,
+		Tenant:      tenant,
+		Request:     req,
+		RequestedBy: PrincipalFromContext(ctx).Name,
+		StartedAt:   time.Now().UTC(),
+		Items:       []ErasedItem{},
+		Notes:       erasureNotes,
+	}
+	ctx = withAuditDetail(ctx, "erasure "+report.ID)
+	cold := make(map[string]bool)
+	for _, storageType := range storage.StorageTypes() {
+		if tier := storage.ColdTier(e.factory, storageType); tier != "" {
+			cold[tier] = true
+		}
+	}
+	for _, storageType := range storage.StorageTypes() {
+		if cold[storageType] {
+			continue
+		}
+		tenants, err := e.factory.Tenants(storageType)
+		if err != nil {
+			return nil, err
+		}
+		if !slices.Contains(tenants, tenant) {
+			continue
+		}
+		if err := e.eraseIn(ctx, storageType, req, identifiers, report); err != nil {
+			return nil, fmt.Errorf("failed to erase from %s: %w", storageType, err)
+		}
+	}
+	report.Complete = report.Failed == 0 && report.Held == 0
+	report.CompletedAt = time.Now().UTC()
+	logging.Printf("Erasure %s of tenant %s deleted %d items, anonymized %d and failed %d",
+		report.ID, tenant, report.Deleted, report.Anonymized, report.Failed)
+	return e.sign(report)
+}
+
+func (e *SubjectEraser) eraseIn(ctx context.Context, storageType string, req ErasureRequest, identifiers []string, report *ErasureReport) error {
+	ids, err := e.service.ListItems(ctx, storageType)
+	if err != nil {
+		return err
+	}
+	store, err := e.factory.CreateStorage(TenantFromContext(ctx), storageType)
+	if err != nil {
+		return err
+	}
+	for _, id := range ids {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		report.Scanned++
+		record, err := e.service.loadMetadata(store, id)
+		if err != nil || !subjectMatches(req, record) {
+			continue
+		}
+
+		item := ErasedItem{StorageType: storageType, ID: id, Action: ErasureActionDeleted, SHA256: record.SHA256}
+		// A legal hold outweighs the erasure; the item stays and the report
+		// is incomplete
+		if e.service.legalHold(ctx, record) != nil {
+			item.Action = ErasureActionHeld
+			report.Held++
+			report.Items = append(report.Items, item)
+			continue
+		}
+		anonymized := false
+		if req.Action == ErasureAnonymize && record.DeletedAt.IsZero() {
+			anonymized, err = e.service.anonymizeItem(ctx, storageType, id, identifiers, e.mask)
+		}
+		if err == nil && !anonymized {
+			err = e.service.deleteItem(ctx, AuditActionErase, storageType, id)
+		}
+		switch {
+		case errors.Is(err, storage.ErrNotFound):
+			// Deleted since it was listed
+			continue
+		case err != nil:
+			item.Action, item.Error = ErasureActionFailed, err.Error()
+			report.Failed++
+			logging.Printf("Failed to erase %s of tenant %s: %v", id, report.Tenant, err)
+		case anonymized:
+			item.Action = ErasureActionAnonymized
+			report.Anonymized++
+		default:
+			report.Deleted++
+		}
+		report.Items = append(report.Items, item)
+	}
+	return nil
+}
+
+func (e *SubjectEraser) sign(report *ErasureReport) (*SignedErasureReport, error) {
+	encoded, err := json.Marshal(report)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode erasure report: %w", err)
+	}
+	return &SignedErasureReport{
+		Report:    encoded,
+		Algorithm: ErasureSignatureAlgorithm,
+		KeyID:     e.keyID,
+		Signature: base64.StdEncoding.EncodeToString(ed25519.Sign(e.key, encoded)),
+	}, nil
+}
+
+// subjectIdentifiers are the values identifying the subject, longest first
+// so that masking one doesn't split another
+func subjectIdentifiers(req ErasureRequest) []string {
+	var identifiers []string
+	for _, value := range slices.Concat(slices.Collect(maps.Values(req.Tags)), slices.Collect(maps.Values(req.Fields))) {
+		if value != "" && !slices.Contains(identifiers, value) {
+			identifiers = append(identifiers, value)
+		}
+	}
+	slices.SortFunc(identifiers, func(a, b string) int {
+		if len(a) != len(b) {
+			return len(b) - len(a)
+		}
+		return strings.Compare(a, b)
+	})
+	return identifiers
+}
+
+// subjectMatches reports whether any of the request's tags or fields
+// identifies the item
+func subjectMatches(req ErasureRequest, record *ItemMetadata) bool {
+	for key, value := range req.Tags {
+		if label, ok := record.Labels[key]; ok && label == value {
+			return true
+		}
+		if tag, ok := record.Tags[key]; ok && tag == value {
+			return true
+		}
+	}
+	for name, value := range req.Fields {
+		if field, ok := record.Fields[name]; ok && fieldText(field) == value {
+			return true
+		}
+	}
+	return false
+}
+
+// anonymizeItem masks the identifiers in an item's JSON payload, labels and
+// source, and drops its earlier versions and derivations. It reports false,
+// leaving the item as it is, when the payload isn't JSON.
+func (ds *DataService) anonymizeItem(ctx context.Context, storageType, id string, identifiers []string, mask string) (anonymized bool, err error) {
+	if err := ds.maintenance.check(); err != nil {
+		return false, err
+	}
+	var data []byte
+	defer func() {
+		if anonymized || err != nil {
+			ds.recordAudit(ctx, AuditActionAnonymize, storageType, id, data, err)
+		}
+	}()
+
+	ctx, unlock, err := ds.lockItem(ctx, storageType, id)
+	if err != nil {
+		return false, err
+	}
+	defer unlock()
+	store, err := ds.openStorage(ctx, storageType)
+	if err != nil {
+		return false, fmt.Errorf("%w: %w", ErrValidation, err)
+	}
+	current, err := store.Load(id)
+	if err != nil {
+		return false, fmt.Errorf("failed to load data: %w", err)
+	}
+	record, err := ds.loadMetadata(store, id)
+	if err != nil {
+		return false, err
+	}
+	if err := ds.checkLegalHold(ctx, id, record); err != nil {
+		return false, err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(current))
+	decoder.UseNumber()
+	var doc any
+	if err := decoder.Decode(&doc); err != nil {
+		return false, nil
+	}
+	if data, err = json.Marshal(maskIdentifiers(doc, identifiers, mask)); err != nil {
+		return false, fmt.Errorf("failed to encode payload: %w", err)
+	}
+
+	versions, err := ds.loadVersions(store, id)
+	if err != nil {
+		return false, err
+	}
+	base := *record
+	base.Labels = maps.Clone(record.Labels)
+	maps.DeleteFunc(base.Labels, func(_, value string) bool { return containsAny(value, identifiers) })
+	base.Tags = maps.Clone(record.Tags)
+	maps.DeleteFunc(base.Tags, func(_, value string) bool { return containsAny(value, identifiers) })
+	if containsAny(base.Source, identifiers) {
+		base.Source = mask
+	}
+	base.ModifiedAt = time.Now().UTC()
+
+	// Deleting first removes the copies a cold tier may hold
+	ds.deleteVersions(store, id)
+	for _, key := range []string{id, metadataItemID(id)} {
+		if err := store.Delete(key); err != nil && !errors.Is(err, storage.ErrNotFound) {
+			return false, fmt.Errorf("failed to delete %s: %w", key, err)
+		}
+	}
+	if err := store.Save(id, data); err != nil {
+		return false, fmt.Errorf("failed to save data: %w", err)
+	}
+	latest := versions[len(versions)-1].Version
+	if err := ds.saveVersions(store, id, []VersionEntry{newVersionEntry(latest+1, data, record.ContentType)}); err != nil {
+		logging.Printf("Failed to record version %d of %s: %v", latest+1, id, err)
+	}
+	if err := ds.indexMetadata(ctx, store, storageType, id, data, base); err != nil {
+		logging.Printf("Failed to index metadata for %s: %v", id, err)
+	}
+	ds.deleteDerived(store, id)
+	ds.materializeEager(store, id, data)
+
+	if released := storedBytes(versions) - int64(len(data)); released > 0 {
+		ds.quotas.Release(TenantFromContext(ctx), record.Owner, released, 0)
+	}
+	ds.watermarks.Advance(TenantFromContext(ctx), AuditActionAnonymize)
+	return true, nil
+}
+
+// maskIdentifiers replaces every identifier in the strings and numbers of
+// a JSON document
+func maskIdentifiers(value any, identifiers []string, mask string) any {
+	switch v := value.(type) {
+	case map[string]any:
+		for key, item := range v {
+			v[key] = maskIdentifiers(item, identifiers, mask)
+		}
+	case []any:
+		for i, item := range v {
+			v[i] = maskIdentifiers(item, identifiers, mask)
+		}
+	case string:
+		for _, identifier := range identifiers {
+			v = strings.ReplaceAll(v, identifier, mask)
+		}
+		return v
+	case json.Number:
+		if containsAny(string(v), identifiers) {
+			return mask
+		}
+	}
+	return value
+}
+
+func containsAny(text string, identifiers []string) bool {
+	for _, identifier := range identifiers {
+		if strings.Contains(text, identifier) {
+			return true
+		}
+	}
+	return false
+}
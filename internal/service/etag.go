@@ -0,0 +1,54 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrPreconditionFailed is returned when a write's If-Match doesn't match
+// the item's current ETag
+var ErrPreconditionFailed = errors.New("precondition failed")
+
+// HeaderIfMatch carries the ETags a conditional update or delete expects
+const HeaderIfMatch = "If-Match"
+
+// ETag is the entity tag of an item's version. It names the version and
+// its payload, so it changes on every write and an item deleted and saved
+// again doesn't take its old tags.
+func ETag(version int, sha256 string) string {
+	return fmt.Sprintf(`"%d-%.16s"`, version, sha256)
+}
+
+// ETag is the entity tag of the item at this version
+func (v *VersionEntry) ETag() string {
+	return ETag(v.Version, v.SHA256)
+}
+
+// WithIfMatch makes the writes made with ctx conditional on the If-Match
+// header value ifMatch; an empty value makes them unconditional
+func WithIfMatch(ctx context.Context, ifMatch string) context.Context {
+	return context.WithValue(ctx, ifMatchKey, ifMatch)
+}
+
+func ifMatchFromContext(ctx context.Context) string {
+	ifMatch, _ := ctx.Value(ifMatchKey).(string)
+	return ifMatch
+}
+
+// checkIfMatch fails with ErrPreconditionFailed unless ifMatch is empty or
+// names the item's current version. "*" matches any version; weak tags
+// never match, as If-Match compares strongly.
+func checkIfMatch(ifMatch, id string, current *VersionEntry) error {
+	if ifMatch == "" {
+		return nil
+	}
+	etag := current.ETag()
+	for _, tag := range strings.Split(ifMatch, ",") {
+		if tag = strings.TrimSpace(tag); tag == "*" || tag == etag {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: %s is at %s", ErrPreconditionFailed, id, etag)
+}
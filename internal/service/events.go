@@ -0,0 +1,159 @@
+package service
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"interview-task/internal/config"
+	"interview-task/internal/metrics"
+)
+
+// StorageEventActions are the audited actions published as events; reads
+// such as verification aren't
+var StorageEventActions = map[string]bool{
+	AuditActionSave:      true,
+	AuditActionUpdate:    true,
+	AuditActionDelete:    true,
+	AuditActionExpire:    true,
+	AuditActionRetention: true,
+	AuditActionErase:     true,
+	AuditActionAnonymize: true,
+	AuditActionUndelete:  true,
+	AuditActionPurge:     true,
+}
+
+// StorageEvent is a completed mutation of a stored item
+type StorageEvent struct {
+	Seq           uint64    `json:"seq"`
+	Time          time.Time `json:"time"`
+	Type          string    `json:"type"`
+	Tenant        string    `json:"tenant"`
+	StorageType   string    `json:"storage_type"`
+	ItemID        string    `json:"item_id"`
+	Size          int       `json:"size"`
+	PayloadSHA256 string    `json:"payload_sha256,omitempty"`
+	RequestID     string    `json:"request_id,omitempty"`
+}
+
+// EventFilter selects events; zero values match everything
+type EventFilter struct {
+	// Tenant is "*" for events of every tenant
+	Tenant      string
+	StorageType string
+	Types       map[string]bool
+}
+
+func (f *EventFilter) Matches(event *StorageEvent) bool {
+	if f.Tenant != "*" && event.Tenant != f.Tenant {
+		return false
+	}
+	if f.StorageType != "" && event.StorageType != f.StorageType {
+		return false
+	}
+	return len(f.Types) == 0 || f.Types[event.Type]
+}
+
+// EventSubscription receives matching events until it is cancelled
+type EventSubscription struct {
+	filter EventFilter
+	events chan StorageEvent
+}
+
+// Events delivers the subscriber's events until the bus closes
+func (s *EventSubscription) Events() <-chan StorageEvent {
+	return s.events
+}
+
+// EventBus - IMPLEMENTS in-process publish/subscribe of storage events.
+// Publishing never blocks a mutation: a subscriber that falls behind by a
+// full buffer misses events, which are counted in events_dropped_total.
+type EventBus struct {
+	config  config.EventsConfig
+	metrics *metrics.Metrics
+
+	mu          sync.Mutex
+	seq         uint64
+	subscribers map[*EventSubscription]bool
+	listeners   []func(StorageEvent)
+	closed      bool
+}
+
+func NewEventBus(config config.EventsConfig, metrics *metrics.Metrics) *EventBus {
+	b := &EventBus{config: config, metrics: metrics, subscribers: make(map[*EventSubscription]bool)}
+	metrics.Describe("events_published_total", "Storage events published by type")
+	metrics.Describe("events_dropped_total", "Storage events dropped for subscribers that fell behind")
+	metrics.GaugeFunc("event_subscribers", "Open event streams", func() int64 {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		return int64(len(b.subscribers))
+	})
+	return b
+}
+
+// Publish delivers an event to the matching subscribers
+func (b *EventBus) Publish(event StorageEvent) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.seq++
+	event.Seq = b.seq
+	b.metrics.Add("events_published_total", 1, "type", event.Type)
+	for _, listener := range b.listeners {
+		listener(event)
+	}
+	for sub := range b.subscribers {
+		if !sub.filter.Matches(&event) {
+			continue
+		}
+		select {
+		case sub.events <- event:
+		default:
+			b.metrics.Add("events_dropped_total", 1)
+		}
+	}
+}
+
+// Subscribe registers a subscriber; it fails once MaxSubscribers are open
+func (b *EventBus) Subscribe(filter EventFilter) (*EventSubscription, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		return nil, fmt.Errorf("server is shutting down")
+	}
+	if len(b.subscribers) >= b.config.MaxSubscribers {
+		return nil, fmt.Errorf("too many event subscribers")
+	}
+	sub := &EventSubscription{filter: filter, events: make(chan StorageEvent, b.config.BufferSize)}
+	b.subscribers[sub] = true
+	return sub, nil
+}
+
+// Listen registers an internal consumer that receives every event. It is
+// called synchronously while publishing, so it must not block.
+func (b *EventBus) Listen(listener func(StorageEvent)) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.listeners = append(b.listeners, listener)
+}
+
+// Heartbeat is how often idle streams are pinged
+func (b *EventBus) Heartbeat() time.Duration {
+	return time.Duration(b.config.Heartbeat)
+}
+
+func (b *EventBus) Unsubscribe(sub *EventSubscription) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	delete(b.subscribers, sub)
+}
+
+// Close ends every stream, which would otherwise hold up server shutdown
+func (b *EventBus) Close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.closed = true
+	for sub := range b.subscribers {
+		close(sub.events)
+		delete(b.subscribers, sub)
+	}
+}
@@ -0,0 +1,149 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"interview-task/internal/logging"
+	"interview-task/internal/metrics"
+	"interview-task/internal/storage"
+)
+
+// AuditActionExpire records items deleted by the expiry reaper
+const AuditActionExpire = "expire"
+
+func (m *ItemMetadata) expired(now time.Time) bool {
+	return !m.ExpiresAt.IsZero() && !now.Before(m.ExpiresAt)
+}
+
+// unavailable returns ErrNotFound for items that are still stored but
+// already gone for clients: expired ones the reaper hasn't deleted yet and
+// soft-deleted ones
+func (m *ItemMetadata) unavailable(id string, now time.Time) error {
+	switch {
+	case !m.DeletedAt.IsZero():
+		return fmt.Errorf("%s has been deleted: %w", id, storage.ErrNotFound)
+	case m.expired(now):
+		return fmt.Errorf("%s has expired: %w", id, storage.ErrNotFound)
+	}
+	return nil
+}
+
+// checkAvailable loads an item's metadata to reject unavailable items
+func (ds *DataService) checkAvailable(storage storage.StorageInterface, id string) error {
+	record, err := ds.loadMetadata(storage, id)
+	if err != nil {
+		return nil
+	}
+	return record.unavailable(id, time.Now())
+}
+
+// reaperPrincipal is the actor recorded for expiry deletions
+var reaperPrincipal = &Principal{Name: "expiry-reaper"}
+
+// ExpiryReaper - IMPLEMENTS the background job deleting expired items. Each
+// run reads the metadata of every item of every tenant.
+type ExpiryReaper struct {
+	service  *DataService
+	factory  storage.TenantLister
+	interval time.Duration
+	metrics  *metrics.Metrics
+	leader   *LeaderElector
+
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+func NewExpiryReaper(service *DataService, factory storage.TenantLister, interval time.Duration, metrics *metrics.Metrics, leader *LeaderElector) *ExpiryReaper {
+	metrics.Describe("items_expired_total", "Items deleted after their TTL passed")
+	metrics.Describe("expiry_reaper_failures_total", "Expired items the reaper failed to delete")
+	return &ExpiryReaper{service: service, factory: factory, interval: interval, metrics: metrics, leader: leader}
+}
+
+// Start runs the reaper every interval until Shutdown
+func (r *ExpiryReaper) Start() {
+	if r.interval <= 0 {
+		return
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	r.cancel, r.done = cancel, make(chan struct{})
+	go r.run(ctx, r.done)
+}
+
+func (r *ExpiryReaper) run(ctx context.Context, done chan struct{}) {
+	defer close(done)
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		// Only the elected instance runs scheduled jobs
+		if !r.leader.IsLeader() {
+			continue
+		}
+		if err := r.Reap(ctx); err != nil && !errors.Is(err, context.Canceled) {
+			logging.Printf("Expiry reaper failed: %v", err)
+		}
+	}
+}
+
+// Reap deletes every item whose expiry has passed
+func (r *ExpiryReaper) Reap(ctx context.Context) error {
+	if r.service.maintenance.Enabled() {
+		// Expired items are deleted once maintenance is over
+		return nil
+	}
+	ctx = WithPrincipal(ctx, reaperPrincipal)
+	return storage.EachTenant(r.factory, func(tenant, storageType string) error {
+		return r.reapTenant(WithTenant(ctx, tenant), tenant, storageType)
+	})
+}
+
+func (r *ExpiryReaper) reapTenant(ctx context.Context, tenant, storageType string) error {
+	ids, err := r.service.ListItems(ctx, storageType)
+	if err != nil {
+		return err
+	}
+	store, err := r.factory.CreateStorage(tenant, storageType)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	for _, id := range ids {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		// Soft-deleted items are left to the trash purger
+		record, err := r.service.loadMetadata(store, id)
+		if err != nil || !record.expired(now) || !record.DeletedAt.IsZero() {
+			continue
+		}
+		// Held items expire once the hold is lifted
+		if r.service.legalHold(ctx, record) != nil {
+			continue
+		}
+		err = r.service.deleteItem(ctx, AuditActionExpire, storageType, id)
+		switch {
+		case errors.Is(err, storage.ErrNotFound):
+		case err != nil:
+			r.metrics.Add("expiry_reaper_failures_total", 1, "storage_type", storageType)
+			logging.Printf("Failed to delete expired item %s: %v", id, err)
+		default:
+			r.metrics.Add("items_expired_total", 1, "tenant", tenant, "storage_type", storageType)
+		}
+	}
+	return nil
+}
+
+func (r *ExpiryReaper) Shutdown() {
+	if r.cancel != nil {
+		r.cancel()
+		<-r.done
+	}
+}
@@ -0,0 +1,36 @@
+package service_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"interview-task/internal/config"
+	"interview-task/internal/metrics"
+	"interview-task/internal/service"
+	"interview-task/pkg/storagetest"
+)
+
+func TestExpiryReaperWalksServiceFactory(t *testing.T) {
+	factory := storagetest.NewFakeFactory()
+	ds := newTestService(t, config.NewConfiguration(), factory)
+	ctx := context.Background()
+	const id = "0123456789abcdef0123456789abcdef"
+
+	req := &service.SaveRequest{Data: []byte("x"), StorageType: "file", TTL: config.Duration(time.Millisecond)}
+	if err := ds.PutData(ctx, id, req); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(5 * time.Millisecond)
+	reaper := service.NewExpiryReaper(ds, factory, 0, metrics.NewMetrics(), nil)
+	if err := reaper.Reap(ctx); err != nil {
+		t.Fatal(err)
+	}
+	keys, err := factory.Storage(service.DefaultTenant, "file").List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(keys) != 0 {
+		t.Fatalf("%d keys left after reaping an expired item: %v", len(keys), keys)
+	}
+}
@@ -0,0 +1,55 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"io"
+
+	"interview-task/internal/storage"
+)
+
+// ItemExport yields the items an export selected with their payloads. They
+// are loaded one at a time, so an export holds no more than one payload in
+// memory however large it is.
+type ItemExport struct {
+	// Items lists the selected items in ID order, without payloads
+	Items []ItemSummary
+
+	ds          *DataService
+	storageType string
+	next        int
+}
+
+// ExportItems selects the tenant's available items matching the filter
+func (ds *DataService) ExportItems(ctx context.Context, storageType string, filter ItemFilter) (*ItemExport, error) {
+	items, err := ds.FindItems(ctx, storageType, filter)
+	if err != nil {
+		return nil, err
+	}
+	return &ItemExport{Items: items, ds: ds, storageType: storageType}, nil
+}
+
+// Next returns the next item and its payload, and io.EOF after the last.
+// Items deleted since the export began are left out.
+func (e *ItemExport) Next(ctx context.Context) (ItemSummary, []byte, error) {
+	for e.next < len(e.Items) {
+		if err := ctx.Err(); err != nil {
+			return ItemSummary{}, nil, err
+		}
+		item := e.Items[e.next]
+		e.next++
+		stored, err := e.ds.GetData(ctx, e.storageType, item.ID)
+		if errors.Is(err, storage.ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return item, nil, err
+		}
+		if item.ContentType == "" {
+			item.ContentType = stored.ContentType
+		}
+		item.Size, item.SHA256 = len(stored.Data), stored.SHA256
+		return item, stored.Data, nil
+	}
+	return ItemSummary{}, nil, io.EOF
+}
@@ -0,0 +1,211 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+
+	"interview-task/internal/config"
+	"interview-task/internal/storage"
+)
+
+// encryptedFieldPrefix marks a value sealed by the field encryptor. The
+// rest is the base64 of the nonce and the sealed JSON encoding of the
+// value, so any JSON value round-trips.
+const encryptedFieldPrefix = "enc:v1:"
+
+// FieldEncryptor - IMPLEMENTS field-level encryption. The values the
+// configured paths select in JSON payloads are sealed with AES-256-GCM
+// after a save is validated, and opened again on read for callers holding
+// one of the configured roles. Other payloads are stored as they are.
+type FieldEncryptor struct {
+	paths []*JSONPath
+	roles []string
+	aead  cipher.AEAD
+}
+
+// NewFieldEncryptor returns nil without paths
+func NewFieldEncryptor(config config.FieldEncryptionConfig) (*FieldEncryptor, error) {
+	if len(config.Paths) == 0 {
+		return nil, nil
+	}
+	e := &FieldEncryptor{roles: config.Roles}
+	if len(e.roles) == 0 {
+		e.roles = []string{RoleAdmin}
+	}
+	for _, expr := range config.Paths {
+		path, err := ParseJSONPath(expr)
+		if err != nil {
+			return nil, err
+		}
+		e.paths = append(e.paths, path)
+	}
+
+	if config.KeyFile == "" {
+		return nil, errors.New("key_file is required")
+	}
+	encoded, err := os.ReadFile(config.KeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read key: %w", err)
+	}
+	key, err := hex.DecodeString(strings.TrimSpace(string(encoded)))
+	if err != nil || len(key) != 32 {
+		return nil, errors.New("key_file must hold a hex-encoded 32-byte key")
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	if e.aead, err = cipher.NewGCM(block); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+// decodeJSON decodes a JSON payload keeping numbers as written; ok is false
+// for anything else
+func decodeJSON(data []byte) (value any, ok bool) {
+	if !json.Valid(data) {
+		return nil, false
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	if err := decoder.Decode(&value); err != nil {
+		return nil, false
+	}
+	return value, true
+}
+
+// Seal encrypts the selected values of a JSON payload, leaving values
+// sealed already as they are, such as when a journaled save is replayed.
+// The payload is re-encoded only if anything was sealed.
+func (e *FieldEncryptor) Seal(data []byte) ([]byte, error) {
+	if e == nil {
+		return data, nil
+	}
+	doc, ok := decodeJSON(data)
+	if !ok {
+		return data, nil
+	}
+	var sealed bool
+	var sealErr error
+	for _, path := range e.paths {
+		doc = path.Replace(doc, func(value any) any {
+			if s, ok := value.(string); ok && strings.HasPrefix(s, encryptedFieldPrefix) {
+				return value
+			}
+			plaintext, err := json.Marshal(value)
+			if err != nil {
+				sealErr = err
+				return value
+			}
+			nonce := make([]byte, e.aead.NonceSize())
+			if _, err := rand.Read(nonce); err != nil {
+				sealErr = err
+				return value
+			}
+			sealed = true
+			return encryptedFieldPrefix + base64.StdEncoding.EncodeToString(e.aead.Seal(nonce, nonce, plaintext, nil))
+		})
+	}
+	if sealErr != nil {
+		return nil, fmt.Errorf("failed to encrypt field: %w", sealErr)
+	}
+	if !sealed {
+		return data, nil
+	}
+	return json.Marshal(doc)
+}
+
+// Open decrypts every sealed value of a JSON payload, wherever it is, so
+// values sealed under paths since removed from the config are still read
+func (e *FieldEncryptor) Open(data []byte) ([]byte, error) {
+	if e == nil || !bytes.Contains(data, []byte(encryptedFieldPrefix)) {
+		return data, nil
+	}
+	doc, ok := decodeJSON(data)
+	if !ok {
+		return data, nil
+	}
+	doc, err := e.open(doc)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(doc)
+}
+
+func (e *FieldEncryptor) open(value any) (any, error) {
+	var err error
+	switch v := value.(type) {
+	case map[string]any:
+		for key, child := range v {
+			if v[key], err = e.open(child); err != nil {
+				return nil, err
+			}
+		}
+	case []any:
+		for i, child := range v {
+			if v[i], err = e.open(child); err != nil {
+				return nil, err
+			}
+		}
+	case string:
+		encoded, ok := strings.CutPrefix(v, encryptedFieldPrefix)
+		if !ok {
+			return value, nil
+		}
+		sealed, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil || len(sealed) < e.aead.NonceSize() {
+			return nil, errors.New("failed to decrypt field: malformed value")
+		}
+		plaintext, err := e.aead.Open(nil, sealed[:e.aead.NonceSize()], sealed[e.aead.NonceSize():], nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decrypt field: %w", err)
+		}
+		value, _ = decodeJSON(plaintext)
+	}
+	return value, nil
+}
+
+// authorized reports whether the caller may read fields decrypted
+func (e *FieldEncryptor) authorized(ctx context.Context) bool {
+	principal := PrincipalFromContext(ctx)
+	return slices.ContainsFunc(e.roles, principal.HasRole)
+}
+
+// sealFields encrypts the selected fields of a validated save, before it is
+// stored, audited or journaled
+func (ds *DataService) sealFields(req *SaveRequest) error {
+	data, err := ds.fields.Seal(req.Data)
+	if err != nil {
+		return err
+	}
+	req.Data = data
+	return nil
+}
+
+// openFields decrypts the fields of an item read by an authorized caller;
+// other callers get them sealed
+func (ds *DataService) openFields(ctx context.Context, item *StoredItem) error {
+	if ds.fields == nil || !ds.fields.authorized(ctx) {
+		return nil
+	}
+	data, err := ds.fields.Open(item.Data)
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(data, item.Data) {
+		item.Data, item.SHA256 = data, storage.PayloadSHA256(data)
+	}
+	return nil
+}
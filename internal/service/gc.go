@@ -0,0 +1,542 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"interview-task/internal/config"
+	"interview-task/internal/logging"
+	"interview-task/internal/metrics"
+	"interview-task/internal/storage"
+)
+
+// Garbage collection states
+const (
+	GCIdle      = "idle"
+	GCRunning   = "running"
+	GCCompleted = "completed"
+	GCCancelled = "cancelled"
+	GCFailed    = "failed"
+)
+
+// Garbage collection finding kinds. Orphans are removed; missing records are
+// only flagged, as nothing is left to restore them from.
+const (
+	GCOrphanedRecord  = "orphaned_record"
+	GCOrphanedVersion = "orphaned_version"
+	GCOrphanedBlob    = "orphaned_blob"
+	GCMissingPayload  = "missing_payload"
+	GCMissingVersion  = "missing_version"
+	GCMissingBlob     = "missing_blob"
+)
+
+// What garbage collection did about a finding
+const (
+	GCActionRemoved     = "removed"
+	GCActionWouldRemove = "would_remove"
+	GCActionFlagged     = "flagged"
+	GCActionFailed      = "failed"
+)
+
+// maxGCFindings bounds the findings a report lists
+const maxGCFindings = 1000
+
+// GCFinding is a stored key that doesn't match the items recorded next to
+// it. For missing records, Key is the record pointing at what is missing
+// and Detail names it.
+type GCFinding struct {
+	StorageType string `json:"storage_type"`
+	Tenant      string `json:"tenant"`
+	Key         string `json:"key"`
+	Kind        string `json:"kind"`
+	Action      string `json:"action"`
+	Detail      string `json:"detail,omitempty"`
+	Error       string `json:"error,omitempty"`
+}
+
+// GCReport reports a garbage collection's progress and findings. Orphaned
+// counts the orphans found, whether or not they were removed.
+type GCReport struct {
+	State      string      `json:"state"`
+	DryRun     bool        `json:"dry_run"`
+	Scanned    int         `json:"scanned"`
+	Orphaned   int         `json:"orphaned"`
+	Removed    int         `json:"removed"`
+	Missing    int         `json:"missing"`
+	Failed     int         `json:"failed"`
+	Findings   []GCFinding `json:"findings,omitempty"`
+	Error      string      `json:"error,omitempty"`
+	StartedAt  time.Time   `json:"started_at"`
+	FinishedAt time.Time   `json:"finished_at"`
+}
+
+// GarbageCollector - IMPLEMENTS reconciling every tenant's stored keys with
+// the items recorded in them. Linked records whose item is gone, retained
+// versions the history doesn't list and blobs nothing references are
+// orphans and removed; metadata without its payload, and versions and blobs
+// that are referenced but gone, are flagged. Keys are read from the primary
+// backend below deduplication; replicas are left to read repair.
+type GarbageCollector struct {
+	service *DataService
+	factory storage.TenantLister
+	dedup   *storage.Deduplicator
+	config  config.GCConfig
+	metrics *metrics.Metrics
+	leader  *LeaderElector
+
+	mu     sync.Mutex
+	report GCReport
+	cancel context.CancelFunc
+	done   chan struct{}
+
+	stop    context.CancelFunc
+	stopped chan struct{}
+}
+
+func NewGarbageCollector(service *DataService, factory storage.TenantLister, dedup *storage.Deduplicator, config config.GCConfig, metrics *metrics.Metrics, leader *LeaderElector) *GarbageCollector {
+	metrics.Describe("gc_findings_total", "Garbage collection findings by kind and what was done about them")
+	return &GarbageCollector{
+		service: service,
+		factory: factory,
+		dedup:   dedup,
+		config:  config,
+		metrics: metrics,
+		leader:  leader,
+		report:  GCReport{State: GCIdle},
+	}
+}
+
+// Start runs a collection every interval until Shutdown
+func (g *GarbageCollector) Start() {
+	if g.config.Interval <= 0 {
+		return
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	g.stop, g.stopped = cancel, make(chan struct{})
+	go g.schedule(ctx, g.stopped)
+}
+
+func (g *GarbageCollector) schedule(ctx context.Context, done chan struct{}) {
+	defer close(done)
+	ticker := time.NewTicker(time.Duration(g.config.Interval))
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		// Only the elected instance runs scheduled jobs
+		if !g.leader.IsLeader() || g.service.maintenance.Enabled() {
+			continue
+		}
+		if _, err := g.Collect(g.config.DryRun); err != nil && !errors.Is(err, storage.ErrConflict) {
+			logging.Printf("Garbage collection failed to start: %v", err)
+		}
+	}
+}
+
+// Collect starts a collection in the background. A dry run reports what it
+// would remove without removing anything.
+func (g *GarbageCollector) Collect(dryRun bool) (GCReport, error) {
+	if !dryRun {
+		if err := g.service.maintenance.check(); err != nil {
+			return GCReport{}, err
+		}
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.cancel != nil {
+		return g.report, fmt.Errorf("%w: a garbage collection is already running", storage.ErrConflict)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	g.report = GCReport{State: GCRunning, DryRun: dryRun, StartedAt: time.Now().UTC()}
+	g.cancel = cancel
+	g.done = make(chan struct{})
+	go g.run(ctx, dryRun, g.done)
+	return g.report, nil
+}
+
+func (g *GarbageCollector) run(ctx context.Context, dryRun bool, done chan struct{}) {
+	defer close(done)
+	err := g.collect(ctx, dryRun)
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	switch {
+	case err == nil:
+		g.report.State = GCCompleted
+		logging.Printf("Garbage collection found %d orphans, removed %d, and flagged %d missing records",
+			g.report.Orphaned, g.report.Removed, g.report.Missing)
+	case errors.Is(err, context.Canceled):
+		g.report.State = GCCancelled
+	default:
+		logging.Printf("Garbage collection failed: %v", err)
+		g.report.State = GCFailed
+		g.report.Error = err.Error()
+	}
+	g.report.FinishedAt = time.Now().UTC()
+	g.cancel = nil
+}
+
+func (g *GarbageCollector) collect(ctx context.Context, dryRun bool) error {
+	// Blobs saved from here on are spared, as their references may be
+	// written after the keys are listed
+	if !dryRun {
+		g.dedup.BeginSweep()
+		defer g.dedup.EndSweep()
+	}
+	for _, storageType := range storage.StorageTypes() {
+		tenants, err := g.factory.Tenants(storageType)
+		if err != nil {
+			return err
+		}
+		for _, tenant := range tenants {
+			if err := g.collectTenant(WithTenant(ctx, tenant), tenant, storageType, dryRun); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// tiers returns the storage type whose items a storage type holds, which
+// differs for a cold tier, and the other tiers holding the same items
+func (g *GarbageCollector) tiers(storageType string) (string, []string) {
+	if cold := storage.ColdTier(g.factory, storageType); cold != "" {
+		return storageType, []string{cold}
+	}
+	var hot []string
+	for _, candidate := range storage.StorageTypes() {
+		if storage.ColdTier(g.factory, candidate) == storageType {
+			hot = append(hot, candidate)
+		}
+	}
+	if len(hot) == 0 {
+		return storageType, nil
+	}
+	return hot[0], hot
+}
+
+// gcScope is a tenant's storage type being collected
+type gcScope struct {
+	ctx         context.Context
+	tenant      string
+	storageType string
+	// lockType is the storage type items are locked under
+	lockType string
+	dryRun   bool
+	raw      storage.StorageInterface
+	// tiers holds the raw storage of every tier of the items, raw included
+	tiers []storage.StorageInterface
+}
+
+func (s *gcScope) finding(key, kind, detail string) GCFinding {
+	return GCFinding{StorageType: s.storageType, Tenant: s.tenant, Key: key, Kind: kind, Detail: detail}
+}
+
+func (g *GarbageCollector) collectTenant(ctx context.Context, tenant, storageType string, dryRun bool) error {
+	lockType, others := g.tiers(storageType)
+	raw, err := storage.RawStorage(g.factory, tenant, storageType)
+	if err != nil {
+		return err
+	}
+	scope := &gcScope{ctx: ctx, tenant: tenant, storageType: storageType, lockType: lockType, dryRun: dryRun, raw: raw, tiers: []storage.StorageInterface{raw}}
+
+	keys, err := raw.List()
+	if err != nil {
+		return fmt.Errorf("failed to list %s of tenant %s: %w", storageType, tenant, err)
+	}
+	slices.Sort(keys)
+	stored := make(map[string]bool, len(keys))
+	present := make(map[string]bool, len(keys))
+	for _, key := range keys {
+		stored[key], present[key] = true, true
+	}
+	// An item's records may be in any of its tiers
+	for _, other := range others {
+		tier, err := storage.RawStorage(g.factory, tenant, other)
+		if err != nil {
+			return err
+		}
+		otherKeys, err := tier.List()
+		if err != nil {
+			return fmt.Errorf("failed to list %s of tenant %s: %w", other, tenant, err)
+		}
+		for _, key := range otherKeys {
+			present[key] = true
+		}
+		scope.tiers = append(scope.tiers, tier)
+	}
+
+	var blobs []string
+	var items []string
+	versions := make(map[string][]int)
+	refs := make(map[string]string)
+	unreadable := false
+	for _, key := range keys {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		g.scanned()
+		if sum, ok := storage.BlobSum(key); ok {
+			blobs = append(blobs, sum)
+			continue
+		}
+		id, link, linked := strings.Cut(key, "~")
+		switch {
+		case !linked:
+			items = append(items, id)
+		case present[id]:
+		case present[metadataItemID(id)]:
+			// The item is recorded but its payload is gone
+			if link == "metadata" {
+				g.record(scope.finding(key, GCMissingPayload, "payload "+id+" is gone"), GCActionFlagged, nil)
+			}
+		default:
+			removed := g.remove(scope, id, key, GCOrphanedRecord, func() (bool, error) {
+				return g.recordsGone(scope, id)
+			})
+			if removed {
+				continue
+			}
+		}
+		isVersion := linked && storage.VersionLinkName.MatchString(link)
+		if isVersion {
+			version, _ := strconv.Atoi(link[1:])
+			versions[id] = append(versions[id], version)
+		}
+		// Only payloads are deduplicated
+		if linked && !isVersion {
+			continue
+		}
+		data, err := raw.Load(key)
+		switch {
+		case errors.Is(err, storage.ErrNotFound):
+		case err != nil:
+			// Its reference is unknown, so no blob can be removed safely
+			logging.Printf("Garbage collection failed to read %s of tenant %s from %s: %v", key, tenant, storageType, err)
+			unreadable = true
+		default:
+			if sum, ok := storage.BlobRef(data); ok {
+				refs[key] = sum
+			}
+		}
+	}
+
+	for _, id := range items {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if stored[versionsItemID(id)] {
+			g.collectVersions(scope, id, versions[id], present, refs)
+		}
+	}
+
+	held := make(map[string]bool, len(blobs))
+	for _, sum := range blobs {
+		held[sum] = true
+	}
+	referenced := make(map[string]bool, len(refs))
+	for _, key := range slices.Sorted(maps.Keys(refs)) {
+		sum := refs[key]
+		referenced[sum] = true
+		if !held[sum] {
+			g.record(scope.finding(key, GCMissingBlob, "blob "+sum+" is gone"), GCActionFlagged, nil)
+		}
+	}
+	if unreadable {
+		return nil
+	}
+	for _, sum := range blobs {
+		if referenced[sum] {
+			continue
+		}
+		finding := scope.finding(sum+"~blob", GCOrphanedBlob, "")
+		if dryRun {
+			g.record(finding, GCActionWouldRemove, nil)
+			continue
+		}
+		removed, err := g.dedup.RemoveBlob(raw, sum)
+		switch {
+		case err != nil:
+			g.record(finding, GCActionFailed, err)
+		case removed:
+			g.record(finding, GCActionRemoved, nil)
+		}
+	}
+	return nil
+}
+
+// collectVersions compares the retained versions of an item with its
+// history. The references of versions it removes are dropped from refs.
+func (g *GarbageCollector) collectVersions(scope *gcScope, id string, stored []int, present map[string]bool, refs map[string]string) {
+	history, err := g.service.loadVersions(scope.raw, id)
+	if err != nil {
+		logging.Printf("Garbage collection skipped the versions of %s of tenant %s: %v", id, scope.tenant, err)
+		return
+	}
+	retained := retainedVersions(history)
+	for _, version := range stored {
+		if retained[version] {
+			continue
+		}
+		key := versionPayloadID(id, version)
+		removed := g.remove(scope, id, key, GCOrphanedVersion, func() (bool, error) {
+			history, err := g.service.loadVersions(scope.raw, id)
+			if err != nil {
+				return false, err
+			}
+			return !retainedVersions(history)[version], nil
+		})
+		if removed {
+			delete(refs, key)
+		}
+	}
+	for _, entry := range history[:len(history)-1] {
+		if !present[versionPayloadID(id, entry.Version)] {
+			g.record(scope.finding(versionsItemID(id), GCMissingVersion, "version "+strconv.Itoa(entry.Version)+" is gone"), GCActionFlagged, nil)
+		}
+	}
+}
+
+// retainedVersions are the versions of a history stored next to the
+// current payload
+func retainedVersions(history []VersionEntry) map[int]bool {
+	retained := make(map[int]bool, len(history))
+	for _, entry := range history[:len(history)-1] {
+		retained[entry.Version] = true
+	}
+	return retained
+}
+
+// recordsGone reports whether neither the payload nor the metadata of an
+// item is in any tier
+func (g *GarbageCollector) recordsGone(scope *gcScope, id string) (bool, error) {
+	for _, tier := range scope.tiers {
+		for _, key := range []string{id, metadataItemID(id)} {
+			_, err := tier.Load(key)
+			if err == nil {
+				return false, nil
+			}
+			if !errors.Is(err, storage.ErrNotFound) {
+				return false, err
+			}
+		}
+	}
+	return true, nil
+}
+
+// remove deletes an orphaned key with its item locked, unless orphaned finds
+// that it is no orphan any more, and reports whether it was deleted
+func (g *GarbageCollector) remove(scope *gcScope, id, key, kind string, orphaned func() (bool, error)) bool {
+	finding := scope.finding(key, kind, "")
+	if scope.dryRun {
+		g.record(finding, GCActionWouldRemove, nil)
+		return false
+	}
+	removed, err := g.removeLocked(scope, id, key, orphaned)
+	switch {
+	case err != nil:
+		g.record(finding, GCActionFailed, err)
+	case removed:
+		storage.Invalidate(g.factory, scope.tenant, scope.storageType, key)
+		g.record(finding, GCActionRemoved, nil)
+	}
+	return removed
+}
+
+func (g *GarbageCollector) removeLocked(scope *gcScope, id, key string, orphaned func() (bool, error)) (bool, error) {
+	_, unlock, err := g.service.lockItem(scope.ctx, scope.lockType, id)
+	if err != nil {
+		return false, err
+	}
+	defer unlock()
+	if ok, err := orphaned(); err != nil || !ok {
+		return false, err
+	}
+	err = scope.raw.Delete(key)
+	if errors.Is(err, storage.ErrNotFound) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (g *GarbageCollector) scanned() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.report.Scanned++
+}
+
+func (g *GarbageCollector) record(finding GCFinding, action string, err error) {
+	finding.Action = action
+	if err != nil {
+		finding.Error = err.Error()
+	}
+	g.metrics.Add("gc_findings_total", 1, "kind", finding.Kind, "action", action)
+	if action == GCActionFailed {
+		logging.Printf("Garbage collection failed to remove %s of tenant %s from %s: %v", finding.Key, finding.Tenant, finding.StorageType, err)
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	switch finding.Kind {
+	case GCMissingPayload, GCMissingVersion, GCMissingBlob:
+		g.report.Missing++
+	default:
+		g.report.Orphaned++
+	}
+	switch action {
+	case GCActionRemoved:
+		g.report.Removed++
+	case GCActionFailed:
+		g.report.Failed++
+	}
+	if len(g.report.Findings) < maxGCFindings {
+		g.report.Findings = append(g.report.Findings, finding)
+	}
+}
+
+// Report returns the running or last collection's report
+func (g *GarbageCollector) Report() GCReport {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	report := g.report
+	report.Findings = slices.Clone(report.Findings)
+	return report
+}
+
+// Cancel stops a running collection; what it removed stays removed
+func (g *GarbageCollector) Cancel() (GCReport, error) {
+	g.mu.Lock()
+	cancel, done := g.cancel, g.done
+	g.mu.Unlock()
+	if cancel == nil {
+		return g.Report(), fmt.Errorf("%w: no garbage collection is running", storage.ErrConflict)
+	}
+	cancel()
+	<-done
+	return g.Report(), nil
+}
+
+// Shutdown stops scheduling and cancels a running collection
+func (g *GarbageCollector) Shutdown() {
+	if g.stop != nil {
+		g.stop()
+		<-g.stopped
+	}
+	g.mu.Lock()
+	cancel, done := g.cancel, g.done
+	g.mu.Unlock()
+	if cancel != nil {
+		cancel()
+		<-done
+	}
+}
@@ -0,0 +1,265 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/rand/v2"
+	"sync"
+	"time"
+
+	"interview-task/internal/config"
+	"interview-task/internal/logging"
+	"interview-task/internal/storage"
+)
+
+// Generate job states
+const (
+	GenerateRunning   = "running"
+	GenerateCompleted = "completed"
+	GenerateCancelled = "cancelled"
+)
+
+// GeneratorBatchTag marks every generated item with its batch ID so a whole
+// batch can be purged at once
+const GeneratorBatchTag = "generator_batch"
+
+// GenerateRequest starts a batch. RatePerSecond overrides the configured
+// default; zero keeps it.
+type GenerateRequest struct {
+	Template      string `json:"template"`
+	Count         int    `json:"count"`
+	StorageType   string `json:"storage_type"`
+	RatePerSecond int    `json:"rate_per_second"`
+}
+
+// GenerateJob reports a batch's progress
+type GenerateJob struct {
+	Batch       string    `json:"batch"`
+	Tenant      string    `json:"tenant"`
+	Template    string    `json:"template"`
+	StorageType string    `json:"storage_type"`
+	State       string    `json:"state"`
+	Requested   int       `json:"requested"`
+	Generated   int       `json:"generated"`
+	Failed      int       `json:"failed"`
+	LastError   string    `json:"last_error,omitempty"`
+	StartedAt   time.Time `json:"started_at"`
+	FinishedAt  time.Time `json:"finished_at"`
+}
+
+// Generator - IMPLEMENTS throttled synthetic data generation for load tests
+// and demo environments. Items go through DataService, so quotas, metadata
+// and auditing apply to them like to any other item.
+type Generator struct {
+	service *DataService
+	config  config.GeneratorConfig
+
+	mu   sync.Mutex
+	jobs map[string]*GenerateJob
+	// stops cancels a running batch and waits for it to finish
+	stops map[string]func()
+}
+
+func NewGenerator(service *DataService, config config.GeneratorConfig) (*Generator, error) {
+	for name, template := range config.Templates {
+		if err := template.Validate(); err != nil {
+			return nil, fmt.Errorf("generator template %s: %w", name, err)
+		}
+	}
+	return &Generator{
+		service: service,
+		config:  config,
+		jobs:    make(map[string]*GenerateJob),
+		stops:   make(map[string]func()),
+	}, nil
+}
+
+// Start launches a batch in the caller's tenant. The job outlives the request
+// but keeps its principal, so generated items are owned by the caller.
+func (g *Generator) Start(ctx context.Context, req GenerateRequest) (GenerateJob, error) {
+	template, ok := g.config.Templates[req.Template]
+	if !ok {
+		return GenerateJob{}, fmt.Errorf("%w: unknown template %q", ErrValidation, req.Template)
+	}
+	if req.Count <= 0 || (g.config.MaxCount > 0 && req.Count > g.config.MaxCount) {
+		return GenerateJob{}, fmt.Errorf("%w: count must be between 1 and %d", ErrValidation, g.config.MaxCount)
+	}
+	rate := g.config.RatePerSecond
+	if req.RatePerSecond > 0 {
+		rate = req.RatePerSecond
+	}
+
+	batch, err := NewItemID()
+	if err != nil {
+		return GenerateJob{}, err
+	}
+	job := &GenerateJob{
+		Batch:       batch,
+		Tenant:      TenantFromContext(ctx),
+		Template:    req.Template,
+		StorageType: req.StorageType,
+		State:       GenerateRunning,
+		Requested:   req.Count,
+		StartedAt:   time.Now().UTC(),
+	}
+
+	ctx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	done := make(chan struct{})
+	g.mu.Lock()
+	g.jobs[batch] = job
+	g.stops[batch] = func() { cancel(); <-done }
+	snapshot := *job
+	g.mu.Unlock()
+
+	go g.run(ctx, done, job, template, rate)
+	return snapshot, nil
+}
+
+func (g *Generator) run(ctx context.Context, done chan struct{}, job *GenerateJob, template config.GeneratorTemplate, rate int) {
+	defer close(done)
+
+	var throttle <-chan time.Time
+	if rate > 0 {
+		ticker := time.NewTicker(time.Second / time.Duration(rate))
+		defer ticker.Stop()
+		throttle = ticker.C
+	}
+
+	tags := map[string]string{GeneratorBatchTag: job.Batch}
+	for key, value := range template.Tags {
+		tags[key] = value
+	}
+
+	state := GenerateCompleted
+	for range job.Requested {
+		if throttle != nil {
+			select {
+			case <-ctx.Done():
+			case <-throttle:
+			}
+		}
+		if ctx.Err() != nil {
+			state = GenerateCancelled
+			break
+		}
+
+		req := &SaveRequest{Data: generatePayload(&template), StorageType: job.StorageType, Tags: tags}
+		_, err := g.service.SaveData(ctx, req)
+
+		g.mu.Lock()
+		if err != nil {
+			job.Failed++
+			job.LastError = err.Error()
+		} else {
+			job.Generated++
+		}
+		g.mu.Unlock()
+	}
+
+	g.mu.Lock()
+	job.State = state
+	job.FinishedAt = time.Now().UTC()
+	delete(g.stops, job.Batch)
+	g.mu.Unlock()
+	logging.Printf("Generator batch %s %s: %d generated, %d failed", job.Batch, state, job.Generated, job.Failed)
+}
+
+// generatePayload produces one synthetic item from a template
+func generatePayload(t *config.GeneratorTemplate) []byte {
+	size := t.MinSize
+	if t.MaxSize > t.MinSize {
+		size += rand.IntN(t.MaxSize - t.MinSize + 1)
+	}
+
+	switch t.Shape {
+	case "binary":
+		data := make([]byte, size)
+		for i := range data {
+			data[i] = byte(rand.UintN(256))
+		}
+		return data
+	case "text":
+		return []byte(randomText(size))
+	}
+
+	doc := make(map[string]any, len(t.Fields)+1)
+	for field, kind := range t.Fields {
+		switch kind {
+		case "string":
+			doc[field] = randomText(12)
+		case "int":
+			doc[field] = rand.IntN(1_000_000)
+		case "float":
+			doc[field] = rand.Float64() * 1000
+		case "bool":
+			doc[field] = rand.IntN(2) == 1
+		case "time":
+			doc[field] = time.Now().Add(-time.Duration(rand.Int64N(int64(30 * 24 * time.Hour)))).UTC()
+		}
+	}
+	data, _ := json.Marshal(doc)
+	// Pad with a filler field to reach the chosen size
+	if pad := size - len(data) - len(`,"padding":""`); pad > 0 {
+		doc["padding"] = randomText(pad)
+		data, _ = json.Marshal(doc)
+	}
+	return data
+}
+
+func randomText(n int) string {
+	const letters = "abcdefghijklmnopqrstuvwxyz "
+	text := make([]byte, n)
+	for i := range text {
+		text[i] = letters[rand.IntN(len(letters))]
+	}
+	return string(text)
+}
+
+// Job returns a batch of the caller's tenant
+func (g *Generator) Job(ctx context.Context, batch string) (GenerateJob, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	job, ok := g.jobs[batch]
+	if !ok || job.Tenant != TenantFromContext(ctx) {
+		return GenerateJob{}, fmt.Errorf("batch %s: %w", batch, storage.ErrNotFound)
+	}
+	return *job, nil
+}
+
+// Purge stops a batch if it is still running and deletes every item it
+// generated. Batches from before a restart are found by their tag alone,
+// so storageType must then be given explicitly.
+func (g *Generator) Purge(ctx context.Context, storageType, batch string) (*BulkResult, error) {
+	var stop func()
+	g.mu.Lock()
+	if job, ok := g.jobs[batch]; ok && job.Tenant == TenantFromContext(ctx) {
+		if storageType == "" {
+			storageType = job.StorageType
+		}
+		stop = g.stops[batch]
+	}
+	g.mu.Unlock()
+	if stop != nil {
+		stop()
+	}
+
+	ids, err := g.service.ItemsWithTag(ctx, storageType, GeneratorBatchTag, batch)
+	if err != nil {
+		return nil, err
+	}
+	return deleteItems(ctx, g.service, storageType, ids)
+}
+
+// Shutdown cancels running batches and waits for them to stop
+func (g *Generator) Shutdown() {
+	g.mu.Lock()
+	stops := make([]func(), 0, len(g.stops))
+	for _, stop := range g.stops {
+		stops = append(stops, stop)
+	}
+	g.mu.Unlock()
+	for _, stop := range stops {
+		stop()
+	}
+}
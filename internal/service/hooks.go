@@ -0,0 +1,49 @@
+package service
+
+import "context"
+
+// BeforeSaveHook runs before a save or update of item id is validated, so
+// changes it makes to req, such as added labels or tags, are validated and
+// stored with the item. Returning an error vetoes the save, failing it as a
+// validation error.
+type BeforeSaveHook func(ctx context.Context, action, id string, req *SaveRequest) error
+
+// AfterSaveHook runs once a save or update of item id is stored
+type AfterSaveHook func(ctx context.Context, action, id string, req *SaveRequest)
+
+// ErrorHook runs when a save or update of item id fails, vetoes included
+type ErrorHook func(ctx context.Context, action, id string, req *SaveRequest, err error)
+
+// Hooks - IMPLEMENTS Open/Closed Principle. Custom logic runs around saves
+// without changing the service; each list runs in order. The action is
+// AuditActionSave or AuditActionUpdate. Saves accepted into the spool run
+// their after-save or error hooks once they are replayed.
+type Hooks struct {
+	BeforeSave []BeforeSaveHook
+	AfterSave  []AfterSaveHook
+	OnError    []ErrorHook
+}
+
+// beforeSave runs the before-save hooks in order, returning the first veto
+func (h Hooks) beforeSave(ctx context.Context, action, id string, req *SaveRequest) error {
+	for _, hook := range h.BeforeSave {
+		if err := hook(ctx, action, id, req); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// afterSave runs the after-save hooks on success and the error hooks
+// otherwise
+func (h Hooks) afterSave(ctx context.Context, action, id string, req *SaveRequest, err error) {
+	if err != nil {
+		for _, hook := range h.OnError {
+			hook(ctx, action, id, req, err)
+		}
+		return
+	}
+	for _, hook := range h.AfterSave {
+		hook(ctx, action, id, req)
+	}
+}
@@ -0,0 +1,106 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"io"
+	"slices"
+	"sync"
+)
+
+// maxImportFailures bounds the failed records an import report lists
+const maxImportFailures = 1000
+
+// ImportRecord is one record of an import. Err is set for a record that
+// couldn't be parsed; Line is where the record starts, for reporting.
+type ImportRecord struct {
+	Line    int
+	Request *SaveRequest
+	Err     error
+}
+
+// ImportReader yields the records of an import in order and io.EOF after
+// the last one. Any other error ends the import.
+type ImportReader interface {
+	Next() (ImportRecord, error)
+}
+
+// ImportFailure is a record that couldn't be parsed or saved
+type ImportFailure struct {
+	Line  int    `json:"line"`
+	Error string `json:"error"`
+}
+
+// ImportReport sums up an import. Succeeded includes the spooled records.
+type ImportReport struct {
+	Succeeded int `json:"succeeded"`
+	Spooled   int `json:"spooled,omitempty"`
+	Failed    int `json:"failed"`
+	// Failures lists failed records by line, up to a limit
+	Failures []ImportFailure `json:"failures,omitempty"`
+	Error    string          `json:"error,omitempty"`
+}
+
+func (r *ImportReport) fail(line int, err error) {
+	r.Failed++
+	if len(r.Failures) < maxImportFailures {
+		r.Failures = append(r.Failures, ImportFailure{Line: line, Error: err.Error()})
+	}
+}
+
+// Import saves every record of records as a SaveData call would, with at
+// most concurrency saves in flight. Records succeed or fail on their own;
+// the error returned is the reader's, with the records before it processed,
+// or a MaintenanceError before any record is read.
+func (ds *DataService) Import(ctx context.Context, records ImportReader, concurrency int) (*ImportReport, error) {
+	if err := ds.maintenance.check(); err != nil {
+		return &ImportReport{Error: err.Error()}, err
+	}
+	report := &ImportReport{}
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	slots := make(chan struct{}, max(concurrency, 1))
+
+	var err error
+	for ctx.Err() == nil {
+		var record ImportRecord
+		if record, err = records.Next(); err != nil {
+			break
+		}
+		if record.Err != nil {
+			mu.Lock()
+			report.fail(record.Line, record.Err)
+			mu.Unlock()
+			continue
+		}
+		slots <- struct{}{}
+		wg.Go(func() {
+			defer func() { <-slots }()
+			_, err := ds.SaveData(ctx, record.Request)
+			mu.Lock()
+			defer mu.Unlock()
+			switch {
+			case errors.Is(err, ErrSpooled):
+				report.Succeeded++
+				report.Spooled++
+			case err != nil:
+				report.fail(record.Line, err)
+			default:
+				report.Succeeded++
+			}
+		})
+	}
+	wg.Wait()
+
+	slices.SortFunc(report.Failures, func(a, b ImportFailure) int { return a.Line - b.Line })
+	if err == io.EOF {
+		err = nil
+	}
+	if err == nil {
+		err = ctx.Err()
+	}
+	if err != nil {
+		report.Error = err.Error()
+	}
+	return report, err
+}
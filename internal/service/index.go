@@ -0,0 +1,656 @@
+package service
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"interview-task/internal/config"
+	"interview-task/internal/logging"
+	"interview-task/internal/storage"
+)
+
+// ItemIndex - IMPLEMENTS the metadata index: the size, checksum, content
+// type, owner, labels, timestamps and location of every item, kept in the
+// database or an embedded file apart from the storage backends. Items are
+// indexed whenever their metadata is written; items stored while the index
+// was disabled are added by a rebuild.
+type ItemIndex struct {
+	store indexStore
+}
+
+// indexStore holds the index records
+type indexStore interface {
+	Put(record storage.IndexRecord) error
+	Delete(tenant, storageType, id string) error
+	Get(tenant, storageType, id string) (*storage.IndexRecord, error)
+	// List returns a tenant's records in a storage type sorted by ID
+	List(tenant, storageType string) ([]storage.IndexRecord, error)
+	Tenants(storageType string) ([]string, error)
+	Close() error
+}
+
+func NewItemIndex(config config.IndexConfig, db *storage.DatabaseConnection) (*ItemIndex, error) {
+	switch config.Backend {
+	case "":
+		return nil, nil
+	case "database":
+		if db == nil {
+			return nil, errors.New("database connection not available")
+		}
+		return &ItemIndex{store: &databaseIndexStore{db: db}}, nil
+	case "file":
+		store, err := openFileIndexStore(config.File)
+		if err != nil {
+			return nil, err
+		}
+		return &ItemIndex{store: store}, nil
+	default:
+		return nil, fmt.Errorf("unsupported index backend: %s", config.Backend)
+	}
+}
+
+// records returns every record of the index, by storage type, tenant and ID
+func (x *ItemIndex) records() ([]storage.IndexRecord, error) {
+	var records []storage.IndexRecord
+	for _, storageType := range storage.StorageTypes() {
+		tenants, err := x.store.Tenants(storageType)
+		if err != nil {
+			return nil, err
+		}
+		for _, tenant := range tenants {
+			list, err := x.store.List(tenant, storageType)
+			if err != nil {
+				return nil, err
+			}
+			records = append(records, list...)
+		}
+	}
+	return records, nil
+}
+
+// replace makes the index hold exactly the given records
+func (x *ItemIndex) replace(records []storage.IndexRecord) error {
+	keep := make(map[[3]string]bool, len(records))
+	for _, record := range records {
+		keep[[3]string{record.Tenant, record.StorageType, record.ID}] = true
+	}
+	current, err := x.records()
+	if err != nil {
+		return err
+	}
+	for _, record := range current {
+		if keep[[3]string{record.Tenant, record.StorageType, record.ID}] {
+			continue
+		}
+		if err := x.store.Delete(record.Tenant, record.StorageType, record.ID); err != nil && !errors.Is(err, storage.ErrNotFound) {
+			return err
+		}
+	}
+	for _, record := range records {
+		if err := x.store.Put(record); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// errIndexDisabled is returned by operations that need the index
+var errIndexDisabled = fmt.Errorf("%w: the metadata index is disabled", storage.ErrConflict)
+
+func (x *ItemIndex) Close() error {
+	if x == nil {
+		return nil
+	}
+	return x.store.Close()
+}
+
+// relocate records that an item's records moved to location
+func (x *ItemIndex) relocate(tenant, storageType, id, location string) error {
+	record, err := x.store.Get(tenant, storageType, id)
+	if errors.Is(err, storage.ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	record.Location = location
+	return x.store.Put(*record)
+}
+
+// databaseIndexStore keeps the index in the item_index table
+type databaseIndexStore struct {
+	db *storage.DatabaseConnection
+}
+
+func (s *databaseIndexStore) Put(record storage.IndexRecord) error {
+	return s.db.PutIndexRecord(record)
+}
+
+func (s *databaseIndexStore) Delete(tenant, storageType, id string) error {
+	return s.db.DeleteIndexRecord(tenant, storageType, id)
+}
+
+func (s *databaseIndexStore) Get(tenant, storageType, id string) (*storage.IndexRecord, error) {
+	return s.db.GetIndexRecord(tenant, storageType, id)
+}
+
+func (s *databaseIndexStore) List(tenant, storageType string) ([]storage.IndexRecord, error) {
+	return s.db.ListIndexRecords(tenant, storageType)
+}
+
+func (s *databaseIndexStore) Tenants(storageType string) ([]string, error) {
+	return s.db.IndexedTenants(storageType)
+}
+
+func (s *databaseIndexStore) Close() error {
+	// The connection is owned and closed by the server
+	return nil
+}
+
+// indexID identifies a record of the embedded index
+type indexID struct {
+	tenant, storageType, id string
+}
+
+// fileIndexChange is a line of the embedded index file
+type fileIndexChange struct {
+	Record  storage.IndexRecord `json:"record"`
+	Deleted bool                `json:"deleted,omitempty"`
+}
+
+// fileIndexStore keeps the index in memory and appends every change to a
+// file, which is compacted when it is opened. The index can be rebuilt, so
+// changes aren't synced to disk.
+type fileIndexStore struct {
+	mu      sync.RWMutex
+	file    *os.File
+	records map[indexID]storage.IndexRecord
+}
+
+func openFileIndexStore(path string) (*fileIndexStore, error) {
+	s := &fileIndexStore{records: make(map[indexID]storage.IndexRecord)}
+	if err := s.load(path); err != nil {
+		return nil, err
+	}
+
+	// Compacting drops replaced and deleted records
+	var compacted []byte
+	for _, record := range s.records {
+		line, err := json.Marshal(fileIndexChange{Record: record})
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode index record: %w", err)
+		}
+		compacted = append(append(compacted, line...), '\n')
+	}
+	if err := storage.WriteFileAtomic(path, compacted); err != nil {
+		return nil, fmt.Errorf("failed to compact index file: %w", err)
+	}
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open index file: %w", err)
+	}
+	s.file = file
+	return s, nil
+}
+
+// load replays the index file. A torn last line, left by a crash while it
+// was appended, is dropped.
+func (s *fileIndexStore) load(path string) error {
+	file, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to open index file: %w", err)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	var torn error
+	for scanner.Scan() {
+		if torn != nil {
+			return torn
+		}
+		var change fileIndexChange
+		if err := json.Unmarshal(scanner.Bytes(), &change); err != nil {
+			torn = fmt.Errorf("corrupt index record: %w", err)
+			continue
+		}
+		s.apply(change)
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("failed to read index file: %w", err)
+	}
+	if torn != nil {
+		logging.Printf("Dropping the torn last record of the index file: %v", torn)
+	}
+	return nil
+}
+
+func (s *fileIndexStore) apply(change fileIndexChange) {
+	record := change.Record
+	id := indexID{record.Tenant, record.StorageType, record.ID}
+	if change.Deleted {
+		delete(s.records, id)
+	} else {
+		s.records[id] = record
+	}
+}
+
+func (s *fileIndexStore) append(change fileIndexChange) error {
+	line, err := json.Marshal(change)
+	if err != nil {
+		return fmt.Errorf("failed to encode index record: %w", err)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, err := s.file.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("failed to write index record: %w", err)
+	}
+	s.apply(change)
+	return nil
+}
+
+func (s *fileIndexStore) Put(record storage.IndexRecord) error {
+	return s.append(fileIndexChange{Record: record})
+}
+
+func (s *fileIndexStore) Delete(tenant, storageType, id string) error {
+	if _, err := s.Get(tenant, storageType, id); err != nil {
+		return err
+	}
+	return s.append(fileIndexChange{Record: storage.IndexRecord{Tenant: tenant, StorageType: storageType, ID: id}, Deleted: true})
+}
+
+func (s *fileIndexStore) Get(tenant, storageType, id string) (*storage.IndexRecord, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	record, ok := s.records[indexID{tenant, storageType, id}]
+	if !ok {
+		return nil, storage.ErrNotFound
+	}
+	return &record, nil
+}
+
+func (s *fileIndexStore) List(tenant, storageType string) ([]storage.IndexRecord, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var records []storage.IndexRecord
+	for id, record := range s.records {
+		if id.tenant == tenant && id.storageType == storageType {
+			records = append(records, record)
+		}
+	}
+	slices.SortFunc(records, func(a, b storage.IndexRecord) int { return strings.Compare(a.ID, b.ID) })
+	return records, nil
+}
+
+func (s *fileIndexStore) Tenants(storageType string) ([]string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	seen := make(map[string]bool)
+	for id := range s.records {
+		if id.storageType == storageType {
+			seen[id.tenant] = true
+		}
+	}
+	return slices.Sorted(maps.Keys(seen)), nil
+}
+
+func (s *fileIndexStore) Close() error {
+	return s.file.Close()
+}
+
+// afterCommit runs fn once store's writes are final: at once, or when the
+// transaction store belongs to commits
+func afterCommit(store storage.StorageInterface, fn func()) {
+	if tx, ok := store.(*storage.StorageTx); ok {
+		tx.AfterCommit(fn)
+		return
+	}
+	fn()
+}
+
+// location names where a storage type keeps an item
+func (ds *DataService) location(storageType, id string) string {
+	if locator, ok := ds.factory.(storage.Locator); ok {
+		return locator.Location(storageType, id)
+	}
+	return storageType
+}
+
+// indexRecord describes an item for the index from its metadata and
+// version history
+func (ds *DataService) indexRecord(ctx context.Context, store storage.StorageInterface, storageType, id string, record *ItemMetadata) storage.IndexRecord {
+	entry := storage.IndexRecord{
+		Tenant:      TenantFromContext(ctx),
+		StorageType: storageType,
+		ID:          id,
+		Size:        int64(record.Size),
+		StoredBytes: int64(record.Size),
+		SHA256:      record.SHA256,
+		ContentType: record.ContentType,
+		Owner:       record.Owner,
+		Labels:      record.Labels,
+		Source:      record.Source,
+		Fields:      record.Fields,
+		Location:    ds.location(storageType, id),
+		CreatedAt:   record.IndexedAt,
+		ModifiedAt:  record.ModifiedAt,
+		ExpiresAt:   record.ExpiresAt,
+		DeletedAt:   record.DeletedAt,
+	}
+	if versions, err := ds.loadVersions(store, id); err == nil {
+		entry.CreatedAt = versions[0].CreatedAt
+		entry.StoredBytes = storedBytes(versions)
+	}
+	return entry
+}
+
+// indexItem records an item's metadata in the index once it is written.
+// Failures are logged; a rebuild repairs the index.
+func (ds *DataService) indexItem(ctx context.Context, store storage.StorageInterface, storageType, id string, record *ItemMetadata) {
+	if ds.index == nil {
+		return
+	}
+	entry := ds.indexRecord(ctx, store, storageType, id, record)
+	afterCommit(store, func() {
+		if err := ds.index.store.Put(entry); err != nil {
+			logging.Printf("Failed to index %s: %v", id, err)
+		}
+	})
+}
+
+// unindexItem removes a deleted item from the index
+func (ds *DataService) unindexItem(ctx context.Context, store storage.StorageInterface, storageType, id string) {
+	if ds.index == nil {
+		return
+	}
+	tenant := TenantFromContext(ctx)
+	afterCommit(store, func() {
+		err := ds.index.store.Delete(tenant, storageType, id)
+		if err != nil && !errors.Is(err, storage.ErrNotFound) {
+			logging.Printf("Failed to remove %s from the index: %v", id, err)
+		}
+	})
+}
+
+// indexedMetadata is the metadata an index record carries
+func indexedMetadata(record storage.IndexRecord) *ItemMetadata {
+	return &ItemMetadata{
+		Owner:       record.Owner,
+		Size:        int(record.Size),
+		Labels:      record.Labels,
+		Source:      record.Source,
+		Fields:      record.Fields,
+		ContentType: record.ContentType,
+		SHA256:      record.SHA256,
+		ExpiresAt:   record.ExpiresAt,
+		ModifiedAt:  record.ModifiedAt,
+		DeletedAt:   record.DeletedAt,
+	}
+}
+
+// findIndexed is findItems served from the index
+func (ds *DataService) findIndexed(ctx context.Context, storageType string, filter ItemFilter) ([]ItemSummary, error) {
+	if _, err := ds.openStorage(ctx, storageType); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrValidation, err)
+	}
+	records, err := ds.index.store.List(TenantFromContext(ctx), storageType)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list items: %w", err)
+	}
+
+	now := time.Now()
+	items := []ItemSummary{}
+	for _, record := range records {
+		metadata := indexedMetadata(record)
+		if metadata.unavailable(record.ID, now) != nil || !filter.Matches(metadata) {
+			continue
+		}
+		items = append(items, summarizeItem(record.ID, metadata))
+	}
+	return items, nil
+}
+
+// RecountUsage replaces the quota usage of every tenant and key with what
+// the index records, correcting drift such as from items changed outside
+// the service. Writes during a recount may be miscounted.
+func (ds *DataService) RecountUsage() (map[string]UsageReport, error) {
+	if ds.index == nil {
+		return nil, errIndexDisabled
+	}
+	usage := make(map[string]Usage)
+	add := func(scope string, record storage.IndexRecord) {
+		current := usage[scope]
+		current.Bytes += record.StoredBytes
+		current.Objects++
+		usage[scope] = current
+	}
+	for _, storageType := range storage.StorageTypes() {
+		tenants, err := ds.index.store.Tenants(storageType)
+		if err != nil {
+			return nil, err
+		}
+		for _, tenant := range tenants {
+			records, err := ds.index.store.List(tenant, storageType)
+			if err != nil {
+				return nil, err
+			}
+			for _, record := range records {
+				add(tenantScope(tenant), record)
+				if record.Owner != "" {
+					add(keyScope(record.Owner), record)
+					add(ownerScope(tenant, record.Owner), record)
+				}
+			}
+		}
+	}
+	ds.quotas.Recount(usage)
+	return ds.quotas.Report(), nil
+}
+
+// IndexRebuildStats reports a rebuild of the metadata index
+type IndexRebuildStats struct {
+	StartedAt  time.Time `json:"started_at"`
+	FinishedAt time.Time `json:"finished_at"`
+	// Scanned counts stored items, Removed the records of items that are
+	// gone
+	Scanned int `json:"scanned"`
+	Indexed int `json:"indexed"`
+	Removed int `json:"removed"`
+	Failed  int `json:"failed"`
+}
+
+// IndexRebuilder - IMPLEMENTS rebuilding the metadata index from storage.
+// Every stored item is indexed again, with items locked, and the records of
+// items that are gone are removed. Cold tiers are indexed with the storage
+// type whose items they hold.
+type IndexRebuilder struct {
+	service *DataService
+	factory storage.TenantLister
+
+	mu      sync.Mutex
+	running bool
+}
+
+func NewIndexRebuilder(service *DataService, factory storage.TenantLister) *IndexRebuilder {
+	return &IndexRebuilder{service: service, factory: factory}
+}
+
+func (r *IndexRebuilder) Rebuild(ctx context.Context) (IndexRebuildStats, error) {
+	if r.service.index == nil {
+		return IndexRebuildStats{}, errIndexDisabled
+	}
+	r.mu.Lock()
+	if r.running {
+		r.mu.Unlock()
+		return IndexRebuildStats{}, fmt.Errorf("%w: the index is already being rebuilt", storage.ErrConflict)
+	}
+	r.running = true
+	r.mu.Unlock()
+	defer func() {
+		r.mu.Lock()
+		r.running = false
+		r.mu.Unlock()
+	}()
+
+	stats := IndexRebuildStats{StartedAt: time.Now().UTC()}
+	err := r.rebuild(ctx, &stats)
+	stats.FinishedAt = time.Now().UTC()
+	return stats, err
+}
+
+func (r *IndexRebuilder) rebuild(ctx context.Context, stats *IndexRebuildStats) error {
+	cold := make(map[string]bool)
+	for _, storageType := range storage.StorageTypes() {
+		if tier := storage.ColdTier(r.factory, storageType); tier != "" {
+			cold[tier] = true
+		}
+	}
+	for _, storageType := range storage.StorageTypes() {
+		if cold[storageType] {
+			continue
+		}
+		stored, err := r.factory.Tenants(storageType)
+		if err != nil {
+			return err
+		}
+		indexed, err := r.service.index.store.Tenants(storageType)
+		if err != nil {
+			return err
+		}
+		tenants := slices.Compact(slices.Sorted(slices.Values(append(stored, indexed...))))
+		for _, tenant := range tenants {
+			if err := r.rebuildTenant(WithTenant(ctx, tenant), tenant, storageType, stats); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (r *IndexRebuilder) rebuildTenant(ctx context.Context, tenant, storageType string, stats *IndexRebuildStats) error {
+	store, err := r.factory.CreateStorage(tenant, storageType)
+	if err != nil {
+		return err
+	}
+	keys, err := store.List()
+	if err != nil {
+		return fmt.Errorf("failed to list %s of tenant %s: %w", storageType, tenant, err)
+	}
+	// Items of a tiered storage type are located in whichever tier holds
+	// them
+	var hot map[string]bool
+	coldTier := storage.ColdTier(r.factory, storageType)
+	if coldTier != "" {
+		hotKeys, err := r.factory.(storage.TieredFactory).HotKeys(tenant, storageType)
+		if err != nil {
+			return err
+		}
+		hot = make(map[string]bool, len(hotKeys))
+		for _, key := range hotKeys {
+			hot[key] = true
+		}
+	}
+
+	seen := make(map[string]bool)
+	for _, id := range keys {
+		if isLinkedItemID(id) {
+			continue
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		seen[id] = true
+		stats.Scanned++
+		location := ""
+		if coldTier != "" && !hot[id] {
+			location = r.service.location(coldTier, id)
+		}
+		indexed, err := r.reindex(ctx, store, storageType, id, location)
+		switch {
+		case err != nil:
+			stats.Failed++
+			logging.Printf("Failed to index %s of tenant %s in %s: %v", id, tenant, storageType, err)
+		case indexed:
+			stats.Indexed++
+		}
+	}
+
+	records, err := r.service.index.store.List(tenant, storageType)
+	if err != nil {
+		return err
+	}
+	for _, record := range records {
+		if seen[record.ID] {
+			continue
+		}
+		removed, err := r.remove(ctx, store, storageType, record.ID)
+		switch {
+		case err != nil:
+			stats.Failed++
+			logging.Printf("Failed to remove %s of tenant %s in %s from the index: %v", record.ID, tenant, storageType, err)
+		case removed:
+			stats.Removed++
+		}
+	}
+	return nil
+}
+
+// reindex indexes one item with it locked. Items without metadata are
+// described from their payload.
+func (r *IndexRebuilder) reindex(ctx context.Context, store storage.StorageInterface, storageType, id, location string) (bool, error) {
+	ctx, unlock, err := r.service.lockItem(ctx, storageType, id)
+	if err != nil {
+		return false, err
+	}
+	defer unlock()
+	record, err := r.service.loadMetadata(store, id)
+	if errors.Is(err, storage.ErrNotFound) {
+		payload, err := store.Load(id)
+		if errors.Is(err, storage.ErrNotFound) {
+			// Deleted since it was listed
+			return false, nil
+		}
+		if err != nil {
+			return false, err
+		}
+		record = &ItemMetadata{Size: len(payload), SHA256: storage.PayloadSHA256(payload)}
+	} else if err != nil {
+		return false, err
+	}
+	entry := r.service.indexRecord(ctx, store, storageType, id, record)
+	if location != "" {
+		entry.Location = location
+	}
+	return true, r.service.index.store.Put(entry)
+}
+
+// remove drops the record of an item that wasn't listed, with the item
+// locked, unless it has been saved since
+func (r *IndexRebuilder) remove(ctx context.Context, store storage.StorageInterface, storageType, id string) (bool, error) {
+	ctx, unlock, err := r.service.lockItem(ctx, storageType, id)
+	if err != nil {
+		return false, err
+	}
+	defer unlock()
+	if _, err := store.Load(id); !errors.Is(err, storage.ErrNotFound) {
+		return false, err
+	}
+	err = r.service.index.store.Delete(TenantFromContext(ctx), storageType, id)
+	if errors.Is(err, storage.ErrNotFound) {
+		return false, nil
+	}
+	return err == nil, err
+}
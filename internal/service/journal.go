@@ -0,0 +1,346 @@
+package service
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"sync"
+	"time"
+
+	"interview-task/internal/logging"
+	"interview-task/internal/storage"
+)
+
+const maxReplayFailures = 100
+
+// JournalEntry is a journaled save, update or removal. Saves and updates
+// carry the full request so they can be replayed; removals only name the
+// item.
+type JournalEntry struct {
+	Time          time.Time  `json:"time"`
+	RequestID     string     `json:"request_id,omitempty"`
+	Endpoint      string     `json:"endpoint,omitempty"`
+	ClientIP      string     `json:"client_ip,omitempty"`
+	Tenant        string     `json:"tenant"`
+	Principal     *Principal `json:"principal,omitempty"`
+	Action        string     `json:"action"`
+	StorageType   string     `json:"storage_type"`
+	ItemID        string     `json:"item_id"`
+	PayloadSHA256 string     `json:"payload_sha256,omitempty"`
+	// Outcome is "success" or "failure"
+	Outcome string       `json:"outcome"`
+	Error   string       `json:"error,omitempty"`
+	Request *SaveRequest `json:"request,omitempty"`
+	// Tags aren't part of the request's JSON
+	Tags map[string]string `json:"tags,omitempty"`
+}
+
+// JournalReplayRequest selects the journaled writes to replay
+type JournalReplayRequest struct {
+	From time.Time `json:"from"`
+	To   time.Time `json:"to"`
+	// Tenants restricts the replay; empty replays every tenant
+	Tenants []string `json:"tenants,omitempty"`
+	// DryRun counts what would be replayed without writing anything
+	DryRun bool `json:"dry_run,omitempty"`
+}
+
+// JournalReplayReport is the outcome of a replay. Replayed counts items
+// that would be written during a dry run.
+type JournalReplayReport struct {
+	Request *JournalReplayRequest `json:"request"`
+	// Scanned is the number of items written in the range
+	Scanned  int `json:"scanned"`
+	Replayed int `json:"replayed"`
+	// Present items are still stored and left as they are
+	Present int `json:"present"`
+	// Removed items were deleted, expired or purged since
+	Removed  int             `json:"removed"`
+	Failed   int             `json:"failed"`
+	Failures []ReplayFailure `json:"failures,omitempty"`
+}
+
+// ReplayFailure is an item that couldn't be checked or written back
+type ReplayFailure struct {
+	Tenant      string `json:"tenant"`
+	StorageType string `json:"storage_type"`
+	ItemID      string `json:"item_id"`
+	Error       string `json:"error"`
+}
+
+// journalKey identifies an item across tenants and storage types
+type journalKey struct {
+	tenant, storageType, id string
+}
+
+// Journal - IMPLEMENTS the request replay journal. Every save and update
+// is appended with its full request and outcome to a JSON lines file, and
+// every removal published on the event bus is noted, so writes a bad
+// deployment dropped can be replayed against the current backend.
+type Journal struct {
+	mu   sync.Mutex
+	path string
+	file *os.File
+}
+
+// NewJournal opens the journal at path; an empty path disables journaling
+func NewJournal(path string) (*Journal, error) {
+	if path == "" {
+		return nil, nil
+	}
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open journal: %w", err)
+	}
+	return &Journal{path: path, file: file}, nil
+}
+
+// Hooks records every save and update, successful or not
+func (j *Journal) Hooks() Hooks {
+	if j == nil {
+		return Hooks{}
+	}
+	return Hooks{
+		AfterSave: []AfterSaveHook{func(ctx context.Context, action, id string, req *SaveRequest) {
+			j.record(ctx, action, id, req, nil)
+		}},
+		OnError: []ErrorHook{j.record},
+	}
+}
+
+// Listen notes removals and undeletes published on bus
+func (j *Journal) Listen(bus *EventBus) {
+	if j == nil {
+		return
+	}
+	bus.Listen(func(event StorageEvent) {
+		switch event.Type {
+		// The journaled payloads of an anonymized item hold the subject's
+		// data, so replays treat it as removed
+		case AuditActionDelete, AuditActionExpire, AuditActionRetention, AuditActionErase, AuditActionAnonymize, AuditActionPurge, AuditActionUndelete:
+		default:
+			return
+		}
+		// Listeners run while the event is published, so removals aren't
+		// synced; the next save's sync makes them durable
+		j.append(JournalEntry{
+			Time:        event.Time,
+			RequestID:   event.RequestID,
+			Tenant:      event.Tenant,
+			Action:      event.Type,
+			StorageType: event.StorageType,
+			ItemID:      event.ItemID,
+			Outcome:     "success",
+		}, false)
+	})
+}
+
+func (j *Journal) record(ctx context.Context, action, id string, req *SaveRequest, err error) {
+	entry := JournalEntry{
+		Time:          time.Now().UTC(),
+		RequestID:     RequestIDFromContext(ctx),
+		Endpoint:      endpointFromContext(ctx),
+		Tenant:        TenantFromContext(ctx),
+		Principal:     PrincipalFromContext(ctx),
+		Action:        action,
+		StorageType:   req.StorageType,
+		ItemID:        id,
+		PayloadSHA256: storage.PayloadSHA256(req.Data),
+		Outcome:       "success",
+		Request:       req,
+		Tags:          req.Tags,
+	}
+	if addr, ok := ClientIPFromContext(ctx); ok {
+		entry.ClientIP = addr.String()
+	}
+	if err != nil {
+		entry.Outcome = "failure"
+		entry.Error = err.Error()
+	}
+	j.append(entry, true)
+}
+
+// append writes an entry, logging rather than failing a write that
+// already happened
+func (j *Journal) append(entry JournalEntry, sync bool) {
+	line, err := json.Marshal(entry)
+	if err != nil {
+		logging.Printf("Failed to encode journal entry: %v", err)
+		return
+	}
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if _, err := j.file.Write(append(line, '\n')); err != nil {
+		logging.Printf("Failed to write journal entry: %v", err)
+		return
+	}
+	if sync {
+		if err := j.file.Sync(); err != nil {
+			logging.Printf("Failed to sync journal: %v", err)
+		}
+	}
+}
+
+// Replay writes back the items saved or updated in the request's range
+// that the current backend no longer holds. Each is written as its last
+// journaled payload, by the original principal under the original request
+// ID; items removed since aren't brought back.
+func (j *Journal) Replay(ctx context.Context, ds *DataService, req JournalReplayRequest) (*JournalReplayReport, error) {
+	if j == nil {
+		return nil, errors.New("journal is disabled")
+	}
+	if req.From.IsZero() || req.To.IsZero() {
+		return nil, fmt.Errorf("%w: from and to are required", ErrValidation)
+	}
+	if !req.To.After(req.From) {
+		return nil, fmt.Errorf("%w: to must be after from", ErrValidation)
+	}
+	if err := ds.maintenance.check(); err != nil {
+		return nil, err
+	}
+
+	keys, latest, err := j.scan(req)
+	if err != nil {
+		return nil, err
+	}
+	report := &JournalReplayReport{Request: &req, Scanned: len(keys)}
+	for _, key := range keys {
+		if err := ctx.Err(); err != nil {
+			return report, err
+		}
+		entry := latest[key]
+		if entry.Request == nil {
+			report.Removed++
+			continue
+		}
+		err := j.replay(ctx, ds, entry, req.DryRun)
+		switch {
+		case errors.Is(err, errItemPresent):
+			report.Present++
+		case err != nil:
+			report.Failed++
+			if len(report.Failures) < maxReplayFailures {
+				report.Failures = append(report.Failures, ReplayFailure{Tenant: key.tenant, StorageType: key.storageType, ItemID: key.id, Error: err.Error()})
+			}
+		default:
+			report.Replayed++
+		}
+	}
+	logging.Printf("Journal replay of %s to %s: replayed %d, present %d, removed %d, failed %d",
+		req.From.Format(time.RFC3339), req.To.Format(time.RFC3339), report.Replayed, report.Present, report.Removed, report.Failed)
+	return report, nil
+}
+
+var errItemPresent = errors.New("item is present")
+
+// scan reads the journal, returning the items successfully written in the
+// range in journal order, and each one's last state from the start of the
+// range on: the last saved payload, or an entry without a request if it
+// was removed afterwards
+func (j *Journal) scan(req JournalReplayRequest) ([]journalKey, map[journalKey]*JournalEntry, error) {
+	// Hold the lock so a concurrent append can't be read half-written
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	file, err := os.Open(j.path)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to open journal: %w", err)
+	}
+	defer file.Close()
+
+	var keys []journalKey
+	payloads := map[journalKey]*JournalEntry{}
+	removals := map[journalKey]*JournalEntry{}
+	// Lines hold whole payloads, so they aren't bounded like a scanner's
+	reader := bufio.NewReader(file)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if errors.Is(err, io.EOF) && len(line) == 0 {
+			break
+		}
+		if err != nil && !errors.Is(err, io.EOF) {
+			return nil, nil, fmt.Errorf("failed to read journal: %w", err)
+		}
+		var entry JournalEntry
+		if err := json.Unmarshal(line, &entry); err != nil {
+			return nil, nil, fmt.Errorf("corrupt journal entry: %w", err)
+		}
+		if entry.Outcome != "success" || entry.Time.Before(req.From) {
+			continue
+		}
+		if len(req.Tenants) > 0 && !slices.Contains(req.Tenants, entry.Tenant) {
+			continue
+		}
+		key := journalKey{entry.Tenant, entry.StorageType, entry.ItemID}
+		switch entry.Action {
+		case AuditActionSave, AuditActionUpdate:
+			if _, seen := payloads[key]; !seen && !entry.Time.After(req.To) {
+				keys = append(keys, key)
+			}
+			payloads[key] = &entry
+			delete(removals, key)
+		case AuditActionUndelete:
+			// The item is back as it was last saved
+			delete(removals, key)
+		default:
+			removals[key] = &entry
+		}
+	}
+
+	latest := map[journalKey]*JournalEntry{}
+	for _, key := range keys {
+		latest[key] = payloads[key]
+		if removal, ok := removals[key]; ok {
+			latest[key] = removal
+		}
+	}
+	return keys, latest, nil
+}
+
+// replay writes an entry's payload back unless the item is still stored
+func (j *Journal) replay(ctx context.Context, ds *DataService, entry *JournalEntry, dryRun bool) error {
+	principal := *entry.Principal
+	ctx = WithEndpoint(WithPrincipal(WithTenant(ctx, entry.Tenant), &principal), "journal_replay")
+	if entry.RequestID != "" {
+		ctx = WithRequestID(ctx, entry.RequestID)
+	}
+	// The item stays locked from the existence check to the write
+	ctx, unlock, err := ds.lockItem(ctx, entry.StorageType, entry.ItemID)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	store, err := ds.factory.CreateStorage(entry.Tenant, entry.StorageType)
+	if err != nil {
+		return fmt.Errorf("failed to create storage: %w", err)
+	}
+	if _, err := store.Load(entry.ItemID); err == nil {
+		return errItemPresent
+	} else if !errors.Is(err, storage.ErrNotFound) {
+		return err
+	}
+	if dryRun {
+		return nil
+	}
+
+	req := *entry.Request
+	req.Tags = entry.Tags
+	if err := ds.createItem(ctx, entry.ItemID, &req); err != nil && !errors.Is(err, ErrSpooled) {
+		return err
+	}
+	return nil
+}
+
+func (j *Journal) Close() error {
+	if j == nil {
+		return nil
+	}
+	return j.file.Close()
+}
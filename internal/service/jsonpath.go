@@ -0,0 +1,156 @@
+package service
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// JSONPath is a compiled selector supporting the common subset of JSONPath:
+// $.field, $['field'], $[0] and the * wildcard for both forms
+type JSONPath struct {
+	expr  string
+	steps []pathStep
+}
+
+type pathStep struct {
+	key      string
+	index    int
+	isIndex  bool
+	wildcard bool
+}
+
+func ParseJSONPath(expr string) (*JSONPath, error) {
+	if !strings.HasPrefix(expr, "$") {
+		return nil, fmt.Errorf("jsonpath %q must start with $", expr)
+	}
+
+	path := &JSONPath{expr: expr}
+	rest := expr[1:]
+	for rest != "" {
+		switch rest[0] {
+		case '.':
+			rest = rest[1:]
+			if strings.HasPrefix(rest, "*") {
+				path.steps = append(path.steps, pathStep{wildcard: true})
+				rest = rest[1:]
+				continue
+			}
+			end := strings.IndexAny(rest, ".[")
+			if end == -1 {
+				end = len(rest)
+			}
+			if end == 0 {
+				return nil, fmt.Errorf("jsonpath %q has an empty field name", expr)
+			}
+			path.steps = append(path.steps, pathStep{key: rest[:end]})
+			rest = rest[end:]
+		case '[':
+			end := strings.IndexByte(rest, ']')
+			if end == -1 {
+				return nil, fmt.Errorf("jsonpath %q has an unclosed bracket", expr)
+			}
+			step, err := parseBracketStep(rest[1:end])
+			if err != nil {
+				return nil, fmt.Errorf("jsonpath %q: %w", expr, err)
+			}
+			path.steps = append(path.steps, step)
+			rest = rest[end+1:]
+		default:
+			return nil, fmt.Errorf("jsonpath %q has unexpected character %q", expr, rest[0])
+		}
+	}
+	return path, nil
+}
+
+func parseBracketStep(inner string) (pathStep, error) {
+	inner = strings.TrimSpace(inner)
+	switch {
+	case inner == "*":
+		return pathStep{wildcard: true}, nil
+	case len(inner) >= 2 && (inner[0] == '\'' || inner[0] == '"') && inner[len(inner)-1] == inner[0]:
+		return pathStep{key: inner[1 : len(inner)-1]}, nil
+	default:
+		index, err := strconv.Atoi(inner)
+		if err != nil || index < 0 {
+			return pathStep{}, fmt.Errorf("invalid index %q", inner)
+		}
+		return pathStep{index: index, isIndex: true}, nil
+	}
+}
+
+func (p *JSONPath) String() string {
+	return p.expr
+}
+
+// Definite reports whether the path selects at most one value
+func (p *JSONPath) Definite() bool {
+	for _, step := range p.steps {
+		if step.wildcard {
+			return false
+		}
+	}
+	return true
+}
+
+// Select returns every value in doc matched by the path
+func (p *JSONPath) Select(doc any) []any {
+	current := []any{doc}
+	for _, step := range p.steps {
+		var next []any
+		for _, value := range current {
+			switch v := value.(type) {
+			case map[string]any:
+				if step.wildcard {
+					for _, key := range slices.Sorted(maps.Keys(v)) {
+						next = append(next, v[key])
+					}
+				} else if child, ok := v[step.key]; ok && !step.isIndex {
+					next = append(next, child)
+				}
+			case []any:
+				if step.wildcard {
+					next = append(next, v...)
+				} else if step.isIndex && step.index < len(v) {
+					next = append(next, v[step.index])
+				}
+			}
+		}
+		current = next
+	}
+	return current
+}
+
+// Replace sets every value in doc matched by the path to fn of it, and
+// returns doc, which is itself replaced for the path $
+func (p *JSONPath) Replace(doc any, fn func(value any) any) any {
+	return replaceSteps(doc, p.steps, fn)
+}
+
+func replaceSteps(value any, steps []pathStep, fn func(any) any) any {
+	if len(steps) == 0 {
+		return fn(value)
+	}
+	step, rest := steps[0], steps[1:]
+	switch v := value.(type) {
+	case map[string]any:
+		if step.wildcard {
+			for key, child := range v {
+				v[key] = replaceSteps(child, rest, fn)
+			}
+		} else if child, ok := v[step.key]; ok && !step.isIndex {
+			v[step.key] = replaceSteps(child, rest, fn)
+		}
+	case []any:
+		if step.wildcard {
+			for i, child := range v {
+				v[i] = replaceSteps(child, rest, fn)
+			}
+		} else if step.isIndex && step.index < len(v) {
+			v[step.index] = replaceSteps(v[step.index], rest, fn)
+		}
+	}
+	return value
+}
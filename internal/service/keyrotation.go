@@ -0,0 +1,277 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"interview-task/internal/logging"
+	"interview-task/internal/storage"
+)
+
+// Key rotation job states
+const (
+	KeyRotationIdle      = "idle"
+	KeyRotationRunning   = "running"
+	KeyRotationCompleted = "completed"
+	KeyRotationCancelled = "cancelled"
+	KeyRotationFailed    = "failed"
+)
+
+// KeyRotationRequest selects what a key rotation re-encrypts
+type KeyRotationRequest struct {
+	// StorageTypes restricts the rotation; empty rotates every storage type
+	// with encryption
+	StorageTypes []string `json:"storage_types,omitempty"`
+	// Tenants restricts the rotation; empty rotates every tenant
+	Tenants []string `json:"tenants,omitempty"`
+	// RateLimit is the most keys rotated per second; zero doesn't throttle
+	RateLimit int `json:"rate_limit,omitempty"`
+}
+
+// KeyRotationFailure is a key that couldn't be re-encrypted
+type KeyRotationFailure struct {
+	StorageType string `json:"storage_type"`
+	Tenant      string `json:"tenant"`
+	Key         string `json:"key"`
+	Error       string `json:"error"`
+}
+
+// KeyRotationStatus reports a key rotation's progress. Total grows as each
+// storage type's tenants are listed.
+type KeyRotationStatus struct {
+	State   string              `json:"state"`
+	Request *KeyRotationRequest `json:"request,omitempty"`
+	Total   int                 `json:"total"`
+	Scanned int                 `json:"scanned"`
+	// Rewrapped keys were brought to the current master key version;
+	// skipped ones were on it already
+	Rewrapped  int                  `json:"rewrapped"`
+	Skipped    int                  `json:"skipped"`
+	Failed     int                  `json:"failed"`
+	Failures   []KeyRotationFailure `json:"failures,omitempty"`
+	Error      string               `json:"error,omitempty"`
+	StartedAt  time.Time            `json:"started_at"`
+	FinishedAt time.Time            `json:"finished_at"`
+}
+
+// KeyRotator - IMPLEMENTS master key rotation for the encryption decorator.
+// Once a new master key version is configured as current, the rotation
+// wraps the data key of every payload sealed under an older version with
+// it, below deduplication so versions, metadata and blobs are covered, and
+// records the version in each item's metadata. Payloads aren't decrypted,
+// only their data keys; older master keys can be removed once it completes.
+type KeyRotator struct {
+	factory *storage.ConcreteStorageFactory
+	service *DataService
+
+	mu     sync.Mutex
+	status KeyRotationStatus
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+func NewKeyRotator(factory *storage.ConcreteStorageFactory, service *DataService) *KeyRotator {
+	return &KeyRotator{factory: factory, service: service, status: KeyRotationStatus{State: KeyRotationIdle}}
+}
+
+// keyVersion is the master key version payloads of a storage type are
+// encrypted with now, empty without encryption
+func (ds *DataService) keyVersion(storageType string) string {
+	if factory, ok := ds.factory.(storage.KeyVersioner); ok {
+		return factory.KeyVersion(storageType)
+	}
+	return ""
+}
+
+// recordKeyVersion notes in an item's metadata that its payload is sealed
+// under the current master key version
+func (ds *DataService) recordKeyVersion(ctx context.Context, storageType, id string) error {
+	ctx, unlock, err := ds.lockItem(ctx, storageType, id)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	store, err := ds.factory.CreateStorage(TenantFromContext(ctx), storageType)
+	if err != nil {
+		return err
+	}
+	record, err := ds.loadMetadata(store, id)
+	if errors.Is(err, storage.ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if version := ds.keyVersion(storageType); record.KeyVersion != version {
+		record.KeyVersion = version
+		return ds.saveMetadata(ctx, store, storageType, id, record)
+	}
+	return nil
+}
+
+// Start rotates in the background
+func (r *KeyRotator) Start(req KeyRotationRequest) (KeyRotationStatus, error) {
+	if req.RateLimit < 0 {
+		return KeyRotationStatus{}, fmt.Errorf("%w: rate_limit must not be negative", ErrValidation)
+	}
+	encrypted := slices.DeleteFunc(storage.StorageTypes(), func(storageType string) bool {
+		return r.factory.KeyVersion(storageType) == ""
+	})
+	if len(req.StorageTypes) == 0 {
+		req.StorageTypes = encrypted
+	}
+	if len(req.StorageTypes) == 0 {
+		return KeyRotationStatus{}, fmt.Errorf("%w: no storage type is encrypted", ErrValidation)
+	}
+	for _, storageType := range req.StorageTypes {
+		if !slices.Contains(encrypted, storageType) {
+			return KeyRotationStatus{}, fmt.Errorf("%w: storage type %q is not encrypted", ErrValidation, storageType)
+		}
+	}
+	for _, tenant := range req.Tenants {
+		if err := ValidateTenant(tenant); err != nil {
+			return KeyRotationStatus{}, fmt.Errorf("%w: %w", ErrValidation, err)
+		}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.cancel != nil {
+		return r.status, fmt.Errorf("%w: a key rotation is already running", storage.ErrConflict)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	r.status = KeyRotationStatus{State: KeyRotationRunning, Request: &req, StartedAt: time.Now().UTC()}
+	r.cancel = cancel
+	r.done = make(chan struct{})
+	go r.run(ctx, req, r.done)
+	return r.status, nil
+}
+
+func (r *KeyRotator) run(ctx context.Context, req KeyRotationRequest, done chan struct{}) {
+	defer close(done)
+	err := r.rotate(ctx, req)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	switch {
+	case err == nil:
+		r.status.State = KeyRotationCompleted
+	case errors.Is(err, context.Canceled):
+		r.status.State = KeyRotationCancelled
+	default:
+		logging.Printf("Key rotation failed: %v", err)
+		r.status.State = KeyRotationFailed
+		r.status.Error = err.Error()
+	}
+	r.status.FinishedAt = time.Now().UTC()
+	r.cancel = nil
+}
+
+func (r *KeyRotator) rotate(ctx context.Context, req KeyRotationRequest) error {
+	var throttle <-chan time.Time
+	if req.RateLimit > 0 {
+		ticker := time.NewTicker(time.Second / time.Duration(req.RateLimit))
+		defer ticker.Stop()
+		throttle = ticker.C
+	}
+	for _, storageType := range req.StorageTypes {
+		tenants := req.Tenants
+		if len(tenants) == 0 {
+			var err error
+			if tenants, err = r.factory.Tenants(storageType); err != nil {
+				return err
+			}
+		}
+		for _, tenant := range tenants {
+			store, err := r.factory.Sharded(tenant, storageType)
+			if err != nil {
+				return err
+			}
+			keys, err := store.List()
+			if err != nil {
+				return fmt.Errorf("failed to list %s of tenant %s: %w", storageType, tenant, err)
+			}
+			r.mu.Lock()
+			r.status.Total += len(keys)
+			r.mu.Unlock()
+
+			tenantCtx := WithTenant(ctx, tenant)
+			for _, key := range keys {
+				if throttle != nil {
+					select {
+					case <-ctx.Done():
+					case <-throttle:
+					}
+				}
+				if err := ctx.Err(); err != nil {
+					return err
+				}
+				rewrapped, err := store.Rewrap(key)
+				if errors.Is(err, storage.ErrNotFound) {
+					// Deleted since it was listed
+					err = nil
+				}
+				if err == nil && !isLinkedItemID(key) {
+					err = r.service.recordKeyVersion(tenantCtx, storageType, key)
+				}
+				r.record(storageType, tenant, key, rewrapped, err)
+			}
+		}
+	}
+	return nil
+}
+
+func (r *KeyRotator) record(storageType, tenant, key string, rewrapped bool, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.status.Scanned++
+	switch {
+	case err != nil:
+		r.status.Failed++
+		if len(r.status.Failures) < maxMigrationFailures {
+			r.status.Failures = append(r.status.Failures, KeyRotationFailure{StorageType: storageType, Tenant: tenant, Key: key, Error: err.Error()})
+		}
+		logging.Printf("Failed to rotate the key of %s of tenant %s in %s: %v", key, tenant, storageType, err)
+	case rewrapped:
+		r.status.Rewrapped++
+	default:
+		r.status.Skipped++
+	}
+}
+
+func (r *KeyRotator) Status() KeyRotationStatus {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	status := r.status
+	status.Failures = slices.Clone(status.Failures)
+	return status
+}
+
+// Cancel stops a running rotation. Keys rotated so far stay rotated;
+// running the rotation again skips them.
+func (r *KeyRotator) Cancel() (KeyRotationStatus, error) {
+	r.mu.Lock()
+	cancel, done := r.cancel, r.done
+	r.mu.Unlock()
+	if cancel == nil {
+		return r.Status(), fmt.Errorf("%w: no key rotation is running", storage.ErrConflict)
+	}
+	cancel()
+	<-done
+	return r.Status(), nil
+}
+
+// Shutdown cancels a running rotation
+func (r *KeyRotator) Shutdown() {
+	r.mu.Lock()
+	cancel, done := r.cancel, r.done
+	r.mu.Unlock()
+	if cancel != nil {
+		cancel()
+		<-done
+	}
+}
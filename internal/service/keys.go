@@ -0,0 +1,321 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"sync"
+	"time"
+
+	"interview-task/internal/config"
+	"interview-task/internal/logging"
+	"interview-task/internal/storage"
+)
+
+// ErrKeyNotFound is returned for a managed API key that doesn't exist
+var ErrKeyNotFound = errors.New("api key not found")
+
+var keyNamePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_.@-]{0,127}$`)
+
+// knownRoles are the roles a managed key may be granted
+var knownRoles = []string{RoleAdmin, RoleAuditor}
+
+// ManagedKey is an API key managed through the admin API. The key itself
+// is only returned when it is created.
+type ManagedKey struct {
+	Name   string   `json:"name"`
+	Roles  []string `json:"roles"`
+	Tenant string   `json:"tenant,omitempty"`
+	// RateLimit caps the key's requests per second, with bursts of up to
+	// Burst; zero is unlimited
+	RateLimit float64 `json:"rate_limit,omitempty"`
+	Burst     int     `json:"burst,omitempty"`
+	// Quota overrides the default key quota when set
+	Quota     *config.QuotaLimits `json:"quota,omitempty"`
+	CreatedAt time.Time           `json:"created_at"`
+	UpdatedAt time.Time           `json:"updated_at"`
+}
+
+// CreatedKey is a new managed key with its secret
+type CreatedKey struct {
+	ManagedKey
+	Key string `json:"key"`
+}
+
+// KeyManager - IMPLEMENTS API key management. Keys live in the database and
+// are cached in memory for authentication; the cache is reloaded after
+// every change on this instance and every refresh interval for changes on
+// others.
+type KeyManager struct {
+	config config.KeyManagementConfig
+	db     *storage.DatabaseConnection
+	quotas *QuotaManager
+	// reserved are the names of configured keys, which managed keys may
+	// not take
+	reserved map[string]bool
+
+	mu         sync.RWMutex
+	principals map[[sha256.Size]byte]*Principal
+
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// NewKeyManager returns nil when key management is disabled. A database
+// that can't be reached yet leaves the cache empty until the next refresh.
+func NewKeyManager(config config.KeyManagementConfig, db *storage.DatabaseConnection, quotas *QuotaManager, configured []config.APIKeyConfig) (*KeyManager, error) {
+	if !config.Enabled {
+		return nil, nil
+	}
+	if db == nil {
+		return nil, errors.New("database connection not available")
+	}
+	m := &KeyManager{config: config, db: db, quotas: quotas, reserved: make(map[string]bool)}
+	for _, key := range configured {
+		m.reserved[key.Name] = true
+	}
+	if err := m.refresh(); err != nil {
+		logging.Printf("Failed to load API keys: %v", err)
+	}
+	return m, nil
+}
+
+// Lookup returns the principal of the managed key with the given SHA-256
+func (m *KeyManager) Lookup(hash [sha256.Size]byte) (*Principal, bool) {
+	if m == nil {
+		return nil, false
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	principal, ok := m.principals[hash]
+	return principal, ok
+}
+
+// LookupName returns the principal of the managed key with the given name
+func (m *KeyManager) LookupName(name string) (*Principal, bool) {
+	if m == nil {
+		return nil, false
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	for _, principal := range m.principals {
+		if principal.Name == name {
+			return principal, true
+		}
+	}
+	return nil, false
+}
+
+// refresh reloads the cache and the managed quotas from the database
+func (m *KeyManager) refresh() error {
+	records, err := m.db.ListAPIKeys()
+	if err != nil {
+		return err
+	}
+	principals := make(map[[sha256.Size]byte]*Principal, len(records))
+	limits := make(map[string]config.QuotaLimits)
+	for _, record := range records {
+		principals[record.KeySHA256] = &Principal{
+			Name:      record.Name,
+			Roles:     record.Roles,
+			Tenant:    record.Tenant,
+			RateLimit: record.RateLimit,
+			Burst:     record.Burst,
+		}
+		if record.MaxBytes > 0 || record.MaxObjects > 0 {
+			limits[record.Name] = config.QuotaLimits{MaxBytes: record.MaxBytes, MaxObjects: record.MaxObjects}
+		}
+	}
+	m.mu.Lock()
+	m.principals = principals
+	m.mu.Unlock()
+	m.quotas.SetManagedLimits(limits)
+	return nil
+}
+
+// changed reloads the cache after a change, which was made whether or not
+// the reload succeeds
+func (m *KeyManager) changed() {
+	if err := m.refresh(); err != nil {
+		logging.Printf("Failed to reload API keys: %v", err)
+	}
+}
+
+func (m *KeyManager) List() ([]ManagedKey, error) {
+	records, err := m.db.ListAPIKeys()
+	if err != nil {
+		return nil, err
+	}
+	keys := make([]ManagedKey, len(records))
+	for i, record := range records {
+		keys[i] = managedKey(&record)
+	}
+	return keys, nil
+}
+
+func (m *KeyManager) Get(name string) (*ManagedKey, error) {
+	record, err := m.db.GetAPIKey(name)
+	if errors.Is(err, storage.ErrNotFound) {
+		return nil, fmt.Errorf("%w: %s", ErrKeyNotFound, name)
+	}
+	if err != nil {
+		return nil, err
+	}
+	key := managedKey(record)
+	return &key, nil
+}
+
+// Create adds a key with a new random secret
+func (m *KeyManager) Create(key ManagedKey) (*CreatedKey, error) {
+	if err := m.validate(&key); err != nil {
+		return nil, err
+	}
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, fmt.Errorf("failed to generate api key: %w", err)
+	}
+	created := &CreatedKey{Key: hex.EncodeToString(secret)}
+
+	record := apiKeyRecord(&key)
+	record.KeySHA256 = sha256.Sum256([]byte(created.Key))
+	record.CreatedAt = time.Now().UTC()
+	record.UpdatedAt = record.CreatedAt
+	if err := m.db.InsertAPIKey(record); err != nil {
+		return nil, err
+	}
+	m.changed()
+	created.ManagedKey = managedKey(&record)
+	return created, nil
+}
+
+// Update replaces the roles, tenant and limits of the key named in key,
+// keeping its secret
+func (m *KeyManager) Update(key ManagedKey) (*ManagedKey, error) {
+	if err := m.validate(&key); err != nil {
+		return nil, err
+	}
+	record := apiKeyRecord(&key)
+	record.UpdatedAt = time.Now().UTC()
+	updated, err := m.db.UpdateAPIKey(record)
+	if errors.Is(err, storage.ErrNotFound) {
+		return nil, fmt.Errorf("%w: %s", ErrKeyNotFound, key.Name)
+	}
+	if err != nil {
+		return nil, err
+	}
+	m.changed()
+	result := managedKey(updated)
+	return &result, nil
+}
+
+// Delete revokes the key named name
+func (m *KeyManager) Delete(name string) error {
+	err := m.db.DeleteAPIKey(name)
+	if errors.Is(err, storage.ErrNotFound) {
+		return fmt.Errorf("%w: %s", ErrKeyNotFound, name)
+	}
+	if err != nil {
+		return err
+	}
+	m.changed()
+	return nil
+}
+
+func (m *KeyManager) validate(key *ManagedKey) error {
+	if !keyNamePattern.MatchString(key.Name) {
+		return fmt.Errorf("%w: invalid key name: %q", ErrValidation, key.Name)
+	}
+	if m.reserved[key.Name] {
+		return fmt.Errorf("%w: %s is a configured key", ErrValidation, key.Name)
+	}
+	for _, role := range key.Roles {
+		if !slices.Contains(knownRoles, role) {
+			return fmt.Errorf("%w: unknown role: %q", ErrValidation, role)
+		}
+	}
+	if key.Tenant != "" {
+		if err := ValidateTenant(key.Tenant); err != nil {
+			return fmt.Errorf("%w: %w", ErrValidation, err)
+		}
+	}
+	if key.RateLimit < 0 || key.Burst < 0 {
+		return fmt.Errorf("%w: rate_limit and burst must not be negative", ErrValidation)
+	}
+	if key.Quota != nil && (key.Quota.MaxBytes < 0 || key.Quota.MaxObjects < 0) {
+		return fmt.Errorf("%w: quota limits must not be negative", ErrValidation)
+	}
+	return nil
+}
+
+func apiKeyRecord(key *ManagedKey) storage.APIKeyRecord {
+	record := storage.APIKeyRecord{
+		Name:      key.Name,
+		Roles:     key.Roles,
+		Tenant:    key.Tenant,
+		RateLimit: key.RateLimit,
+		Burst:     key.Burst,
+	}
+	if key.Quota != nil {
+		record.MaxBytes, record.MaxObjects = key.Quota.MaxBytes, key.Quota.MaxObjects
+	}
+	return record
+}
+
+func managedKey(record *storage.APIKeyRecord) ManagedKey {
+	key := ManagedKey{
+		Name:      record.Name,
+		Roles:     record.Roles,
+		Tenant:    record.Tenant,
+		RateLimit: record.RateLimit,
+		Burst:     record.Burst,
+		CreatedAt: record.CreatedAt,
+		UpdatedAt: record.UpdatedAt,
+	}
+	if key.Roles == nil {
+		key.Roles = []string{}
+	}
+	if record.MaxBytes > 0 || record.MaxObjects > 0 {
+		key.Quota = &config.QuotaLimits{MaxBytes: record.MaxBytes, MaxObjects: record.MaxObjects}
+	}
+	return key
+}
+
+// Start reloads the keys every refresh interval until Shutdown
+func (m *KeyManager) Start() {
+	if m == nil || m.config.RefreshInterval <= 0 {
+		return
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	m.cancel, m.done = cancel, make(chan struct{})
+	go m.run(ctx, m.done)
+}
+
+func (m *KeyManager) run(ctx context.Context, done chan struct{}) {
+	defer close(done)
+	ticker := time.NewTicker(time.Duration(m.config.RefreshInterval))
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		// A failed refresh keeps the keys loaded last
+		if err := m.refresh(); err != nil {
+			logging.Printf("Failed to refresh API keys: %v", err)
+		}
+	}
+}
+
+func (m *KeyManager) Shutdown() {
+	if m == nil || m.cancel == nil {
+		return
+	}
+	m.cancel()
+	<-m.done
+}
@@ -0,0 +1,142 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"time"
+
+	"interview-task/internal/storage"
+)
+
+// Limits on client-supplied labels, which are stored with every item's
+// metadata
+const (
+	maxLabels         = 64
+	MaxLabelValueSize = 256
+	maxSourceSize     = 256
+)
+
+var labelKeyPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_.-]{0,62}$`)
+
+func validateLabels(labels map[string]string, source string) error {
+	if len(labels) > maxLabels {
+		return fmt.Errorf("at most %d labels are allowed", maxLabels)
+	}
+	for key, value := range labels {
+		if !labelKeyPattern.MatchString(key) {
+			return fmt.Errorf("invalid label key: %q", key)
+		}
+		if len(value) > MaxLabelValueSize {
+			return fmt.Errorf("label %s exceeds %d bytes", key, MaxLabelValueSize)
+		}
+	}
+	if len(source) > maxSourceSize {
+		return fmt.Errorf("source exceeds %d bytes", maxSourceSize)
+	}
+	return nil
+}
+
+// ItemFilter selects items by their metadata; zero values match everything
+type ItemFilter struct {
+	Labels map[string]string
+	Source string
+	// ContentType is a media type pattern such as "image/*"
+	ContentType string
+}
+
+func (f *ItemFilter) Matches(record *ItemMetadata) bool {
+	for key, value := range f.Labels {
+		if actual, ok := record.Labels[key]; !ok || actual != value {
+			return false
+		}
+	}
+	if f.Source != "" && record.Source != f.Source {
+		return false
+	}
+	if f.ContentType != "" && !matchesMediaType(f.ContentType, record.ContentType) {
+		return false
+	}
+	return true
+}
+
+// ItemSummary describes a stored item without its payload
+type ItemSummary struct {
+	ID          string            `json:"id"`
+	Size        int               `json:"size"`
+	ContentType string            `json:"content_type,omitempty"`
+	SHA256      string            `json:"sha256,omitempty"`
+	Labels      map[string]string `json:"labels,omitempty"`
+	Source      string            `json:"source,omitempty"`
+	ExpiresAt   time.Time         `json:"expires_at,omitzero"`
+	ModifiedAt  time.Time         `json:"modified_at,omitzero"`
+}
+
+// findItems returns the tenant's available items whose metadata matches the
+// filter, sorted by ID. Items without metadata only match an empty filter.
+// With the metadata index enabled, they are found in the index.
+func (ds *DataService) findItems(ctx context.Context, storageType string, filter ItemFilter) ([]ItemSummary, error) {
+	if ds.index != nil {
+		return ds.findIndexed(ctx, storageType, filter)
+	}
+	ids, err := ds.ListItems(ctx, storageType)
+	if err != nil {
+		return nil, err
+	}
+	storage, err := ds.openStorage(ctx, storageType)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrValidation, err)
+	}
+
+	now := time.Now()
+	items := []ItemSummary{}
+	for _, id := range ids {
+		record, err := ds.loadMetadata(storage, id)
+		if err != nil {
+			record = &ItemMetadata{}
+		}
+		if record.unavailable(id, now) != nil || !filter.Matches(record) {
+			continue
+		}
+		items = append(items, summarizeItem(id, record))
+	}
+	return items, nil
+}
+
+// DescribeItem returns the summary of an available item. Items saved without
+// metadata are described from their payload.
+func (ds *DataService) DescribeItem(ctx context.Context, storageType, id string) (*ItemSummary, error) {
+	if err := ds.validator.ValidateID(id); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrValidation, err)
+	}
+	store, err := ds.openStorage(ctx, storageType)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrValidation, err)
+	}
+
+	record, err := ds.loadMetadata(store, id)
+	if err != nil {
+		data, err := store.Load(id)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load data: %w", err)
+		}
+		record = &ItemMetadata{Size: len(data), SHA256: storage.PayloadSHA256(data)}
+	} else if err := record.unavailable(id, time.Now()); err != nil {
+		return nil, err
+	}
+	summary := summarizeItem(id, record)
+	return &summary, nil
+}
+
+func summarizeItem(id string, record *ItemMetadata) ItemSummary {
+	return ItemSummary{
+		ID:          id,
+		Size:        record.Size,
+		ContentType: record.ContentType,
+		SHA256:      record.SHA256,
+		Labels:      record.Labels,
+		Source:      record.Source,
+		ExpiresAt:   record.ExpiresAt,
+		ModifiedAt:  record.ModifiedAt,
+	}
+}
@@ -0,0 +1,145 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sync/atomic"
+	"time"
+
+	"interview-task/internal/config"
+	"interview-task/internal/logging"
+	"interview-task/internal/metrics"
+	"interview-task/internal/storage"
+)
+
+// LeaderElector - IMPLEMENTS leader election for scheduled jobs. The
+// instance holding the lease is leader; it renews the lease every renew
+// interval and gives it up at shutdown. A leader that can't renew steps
+// down once its last lease would have expired, so two instances never both
+// believe they lead. A nil LeaderElector always leads, for single-instance
+// deployments.
+type LeaderElector struct {
+	config   config.LeaderElectionConfig
+	db       *storage.DatabaseConnection
+	identity string
+	// leaseUntil is when this instance's lease runs out, in Unix
+	// nanoseconds; zero while another instance leads
+	leaseUntil atomic.Int64
+
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// NewLeaderElector returns nil without a backend
+func NewLeaderElector(config config.LeaderElectionConfig, db *storage.DatabaseConnection, metrics *metrics.Metrics) (*LeaderElector, error) {
+	switch config.Backend {
+	case "":
+		return nil, nil
+	case "database":
+		if db == nil {
+			return nil, errors.New("database connection not available")
+		}
+	default:
+		return nil, fmt.Errorf("unsupported leader election backend: %s", config.Backend)
+	}
+	if config.Name == "" {
+		return nil, errors.New("leader election needs a lease name")
+	}
+	if config.RenewInterval <= 0 || config.RenewInterval >= config.LeaseDuration {
+		return nil, errors.New("renew_interval must be positive and shorter than lease_duration")
+	}
+
+	e := &LeaderElector{config: config, db: db, identity: config.Identity}
+	if e.identity == "" {
+		hostname, err := os.Hostname()
+		if err != nil {
+			return nil, fmt.Errorf("failed to name instance: %w", err)
+		}
+		e.identity = fmt.Sprintf("%s-%d", hostname, os.Getpid())
+	}
+	metrics.GaugeFunc("leader", "Whether this instance runs the scheduled jobs", func() int64 {
+		if e.IsLeader() {
+			return 1
+		}
+		return 0
+	})
+	return e, nil
+}
+
+// IsLeader reports whether scheduled jobs should run on this instance
+func (e *LeaderElector) IsLeader() bool {
+	if e == nil {
+		return true
+	}
+	return time.Now().UnixNano() < e.leaseUntil.Load()
+}
+
+// Start seeks the lease at once, then renews or seeks it every renew
+// interval until Shutdown
+func (e *LeaderElector) Start() {
+	if e == nil {
+		return
+	}
+	e.elect()
+	ctx, cancel := context.WithCancel(context.Background())
+	e.cancel, e.done = cancel, make(chan struct{})
+	go e.run(ctx, e.done)
+}
+
+func (e *LeaderElector) run(ctx context.Context, done chan struct{}) {
+	defer close(done)
+	ticker := time.NewTicker(time.Duration(e.config.RenewInterval))
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		e.elect()
+	}
+}
+
+// elect takes or renews the lease. An error leaves the current lease to run
+// out rather than stepping down at once.
+func (e *LeaderElector) elect() {
+	wasLeader := e.IsLeader()
+	// The lease counts from before the request, so it never outlives the
+	// backend's copy
+	start := time.Now()
+	acquired, err := e.db.AcquireLease(e.config.Name, e.identity, time.Duration(e.config.LeaseDuration))
+	switch {
+	case err != nil:
+		logging.Printf("Failed to renew %s lease: %v", e.config.Name, err)
+	case acquired:
+		e.leaseUntil.Store(start.Add(time.Duration(e.config.LeaseDuration)).UnixNano())
+	default:
+		e.leaseUntil.Store(0)
+	}
+	if isLeader := e.IsLeader(); isLeader != wasLeader {
+		if isLeader {
+			logging.Printf("Instance %s is now leader of %s", e.identity, e.config.Name)
+		} else {
+			logging.Printf("Instance %s is no longer leader of %s", e.identity, e.config.Name)
+		}
+	}
+}
+
+// Shutdown stops renewing and releases the lease, so another instance can
+// take over without waiting for it to expire
+func (e *LeaderElector) Shutdown() {
+	if e == nil || e.cancel == nil {
+		return
+	}
+	e.cancel()
+	<-e.done
+	if !e.IsLeader() {
+		return
+	}
+	e.leaseUntil.Store(0)
+	if err := e.db.ReleaseLease(e.config.Name, e.identity); err != nil {
+		logging.Printf("Failed to release %s lease: %v", e.config.Name, err)
+	}
+}
@@ -0,0 +1,254 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"interview-task/internal/storage"
+)
+
+// Audit actions recorded for legal holds
+const (
+	AuditActionLegalHold     = "legal_hold"
+	AuditActionLegalHoldLift = "legal_hold_lift"
+)
+
+// ErrLegalHold is returned for removals of items under legal hold
+var ErrLegalHold = errors.New("under legal hold")
+
+// LegalHold keeps an item, or every item of a tenant, from being deleted,
+// purged, expired, removed by retention or erased until an admin lifts it
+type LegalHold struct {
+	Reason string    `json:"reason"`
+	HeldBy string    `json:"held_by"`
+	HeldAt time.Time `json:"held_at"`
+}
+
+func newLegalHold(ctx context.Context, reason string) (*LegalHold, error) {
+	reason = strings.TrimSpace(reason)
+	if reason == "" {
+		return nil, fmt.Errorf("%w: a legal hold needs a reason", ErrValidation)
+	}
+	return &LegalHold{Reason: reason, HeldBy: PrincipalFromContext(ctx).Name, HeldAt: time.Now().UTC()}, nil
+}
+
+// TenantHolds - IMPLEMENTS legal holds on whole tenants, persisted to a file
+// so they survive restarts. Holds on single items are kept in their
+// metadata.
+type TenantHolds struct {
+	file string
+
+	mu    sync.Mutex
+	holds map[string]LegalHold
+}
+
+func NewTenantHolds(file string) (*TenantHolds, error) {
+	h := &TenantHolds{file: file, holds: make(map[string]LegalHold)}
+	if file == "" {
+		return h, nil
+	}
+
+	data, err := os.ReadFile(file)
+	if errors.Is(err, os.ErrNotExist) {
+		return h, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read legal hold file: %w", err)
+	}
+	if err := json.Unmarshal(data, &h.holds); err != nil {
+		return nil, fmt.Errorf("failed to parse legal hold file: %w", err)
+	}
+	return h, nil
+}
+
+// Get returns the tenant's hold, or nil
+func (h *TenantHolds) Get(tenant string) *LegalHold {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if hold, ok := h.holds[tenant]; ok {
+		return &hold
+	}
+	return nil
+}
+
+// List returns every tenant's hold, keyed by tenant
+func (h *TenantHolds) List() map[string]LegalHold {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return maps.Clone(h.holds)
+}
+
+// set places or, with a nil hold, lifts the tenant's hold. A change that
+// can't be persisted is undone, as a hold must not silently vanish on
+// restart.
+func (h *TenantHolds) set(tenant string, hold *LegalHold) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	previous, held := h.holds[tenant]
+	if hold == nil {
+		delete(h.holds, tenant)
+	} else {
+		h.holds[tenant] = *hold
+	}
+	if err := h.persistLocked(); err != nil {
+		if held {
+			h.holds[tenant] = previous
+		} else {
+			delete(h.holds, tenant)
+		}
+		return err
+	}
+	return nil
+}
+
+func (h *TenantHolds) persistLocked() error {
+	if h.file == "" {
+		return nil
+	}
+	data, err := json.Marshal(h.holds)
+	if err == nil {
+		err = storage.WriteFileAtomic(h.file, data)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to persist legal holds: %w", err)
+	}
+	return nil
+}
+
+// legalHold returns the hold keeping an item from being removed: its
+// tenant's, or its own. Nil when the item may be removed.
+func (ds *DataService) legalHold(ctx context.Context, record *ItemMetadata) *LegalHold {
+	if hold := ds.holds.Get(TenantFromContext(ctx)); hold != nil {
+		return hold
+	}
+	return record.LegalHold
+}
+
+// checkLegalHold fails with ErrLegalHold for items under legal hold
+func (ds *DataService) checkLegalHold(ctx context.Context, id string, record *ItemMetadata) error {
+	if hold := ds.legalHold(ctx, record); hold != nil {
+		return fmt.Errorf("%s is %w: %s", id, ErrLegalHold, hold.Reason)
+	}
+	return nil
+}
+
+// PlaceLegalHold puts an item, in or out of the trash, under legal hold.
+// Placing it again replaces the reason.
+func (ds *DataService) PlaceLegalHold(ctx context.Context, storageType, id, reason string) (hold *LegalHold, err error) {
+	if err := ds.maintenance.check(); err != nil {
+		return nil, err
+	}
+	ctx = withAuditDetail(ctx, reason)
+	defer func() {
+		ds.recordAudit(ctx, AuditActionLegalHold, storageType, id, nil, err)
+	}()
+
+	if err := ds.validator.ValidateID(id); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrValidation, err)
+	}
+	if hold, err = newLegalHold(ctx, reason); err != nil {
+		return nil, err
+	}
+	err = ds.updateLegalHold(ctx, storageType, id, func(record *ItemMetadata) error {
+		record.LegalHold = hold
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return hold, nil
+}
+
+// LiftLegalHold releases an item's own hold. A hold on its tenant still
+// applies.
+func (ds *DataService) LiftLegalHold(ctx context.Context, storageType, id string) (err error) {
+	if err := ds.maintenance.check(); err != nil {
+		return err
+	}
+	defer func() {
+		ds.recordAudit(ctx, AuditActionLegalHoldLift, storageType, id, nil, err)
+	}()
+
+	if err := ds.validator.ValidateID(id); err != nil {
+		return fmt.Errorf("%w: %w", ErrValidation, err)
+	}
+	return ds.updateLegalHold(ctx, storageType, id, func(record *ItemMetadata) error {
+		if record.LegalHold == nil {
+			return fmt.Errorf("%s is not under legal hold: %w", id, storage.ErrNotFound)
+		}
+		record.LegalHold = nil
+		return nil
+	})
+}
+
+// updateLegalHold changes the hold in an item's metadata under its lock.
+// Items stored without metadata get a record holding just the hold.
+func (ds *DataService) updateLegalHold(ctx context.Context, storageType, id string, change func(*ItemMetadata) error) error {
+	ctx, unlock, err := ds.lockItem(ctx, storageType, id)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	store, err := ds.openStorage(ctx, storageType)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrValidation, err)
+	}
+
+	if _, err := store.Load(id); err != nil {
+		return fmt.Errorf("failed to load data: %w", err)
+	}
+	record, err := ds.loadMetadata(store, id)
+	switch {
+	case errors.Is(err, storage.ErrNotFound):
+		record = &ItemMetadata{}
+	case err != nil:
+		return err
+	}
+	if err := change(record); err != nil {
+		return err
+	}
+	return ds.saveMetadata(ctx, store, storageType, id, record)
+}
+
+// TenantLegalHold returns the hold on the request's tenant, or nil
+func (ds *DataService) TenantLegalHold(ctx context.Context) *LegalHold {
+	return ds.holds.Get(TenantFromContext(ctx))
+}
+
+// PlaceTenantLegalHold puts every item of the request's tenant under legal
+// hold, including items saved later. Placing it again replaces the reason.
+func (ds *DataService) PlaceTenantLegalHold(ctx context.Context, reason string) (hold *LegalHold, err error) {
+	ctx = withAuditDetail(ctx, reason)
+	defer func() {
+		ds.recordAudit(ctx, AuditActionLegalHold, "", "", nil, err)
+	}()
+
+	if hold, err = newLegalHold(ctx, reason); err != nil {
+		return nil, err
+	}
+	if err := ds.holds.set(TenantFromContext(ctx), hold); err != nil {
+		return nil, err
+	}
+	return hold, nil
+}
+
+// LiftTenantLegalHold releases the hold on the request's tenant. Holds on
+// single items still apply.
+func (ds *DataService) LiftTenantLegalHold(ctx context.Context) (err error) {
+	defer func() {
+		ds.recordAudit(ctx, AuditActionLegalHoldLift, "", "", nil, err)
+	}()
+
+	tenant := TenantFromContext(ctx)
+	if ds.holds.Get(tenant) == nil {
+		return fmt.Errorf("tenant %s is not under legal hold: %w", tenant, storage.ErrNotFound)
+	}
+	return ds.holds.set(tenant, nil)
+}
@@ -0,0 +1,255 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"interview-task/internal/config"
+	"interview-task/internal/logging"
+	"interview-task/internal/storage"
+)
+
+// ErrLockTimeout is returned when another write held an item for longer
+// than the lock timeout
+var ErrLockTimeout = errors.New("timed out waiting for another write to the item")
+
+// keyLocker - IMPLEMENTS Strategy Pattern for per-item locks. Lock blocks
+// until key is free or ctx is done, and returns the function releasing it.
+// lost is called if the lock is lost while still held.
+type keyLocker interface {
+	Lock(ctx context.Context, key string, lost func()) (unlock func(), err error)
+}
+
+// ItemLocks serializes writes to the same item, so concurrent saves,
+// updates and deletes of one ID don't interleave their payload, version
+// and metadata writes
+type ItemLocks struct {
+	locker  keyLocker
+	timeout time.Duration
+}
+
+// NewItemLocks returns nil when locking is disabled
+func NewItemLocks(config config.LockingConfig, db *storage.DatabaseConnection) (*ItemLocks, error) {
+	locks := &ItemLocks{timeout: time.Duration(config.Timeout)}
+	switch config.Backend {
+	case "":
+		return nil, nil
+	case "local":
+		locks.locker = &localKeyLocker{keys: make(map[string]*localKeyLock)}
+	case "database":
+		if db == nil {
+			return nil, errors.New("database connection not available")
+		}
+		if config.LeaseDuration <= 0 {
+			return nil, errors.New("database locks need a lease_duration")
+		}
+		locks.locker = &databaseKeyLocker{db: db, lease: time.Duration(config.LeaseDuration)}
+	default:
+		return nil, fmt.Errorf("unsupported locking backend: %s", config.Backend)
+	}
+	if locks.timeout <= 0 {
+		return nil, errors.New("locking needs a timeout")
+	}
+	return locks, nil
+}
+
+// localKeyLocker locks keys within this instance. A key's lock is dropped
+// once nobody holds or waits for it.
+type localKeyLocker struct {
+	mu   sync.Mutex
+	keys map[string]*localKeyLock
+}
+
+type localKeyLock struct {
+	held  chan struct{}
+	users int
+}
+
+func (l *localKeyLocker) Lock(ctx context.Context, key string, _ func()) (func(), error) {
+	l.mu.Lock()
+	lock, ok := l.keys[key]
+	if !ok {
+		lock = &localKeyLock{held: make(chan struct{}, 1)}
+		l.keys[key] = lock
+	}
+	lock.users++
+	l.mu.Unlock()
+
+	select {
+	case lock.held <- struct{}{}:
+		return func() {
+			<-lock.held
+			l.release(key, lock)
+		}, nil
+	case <-ctx.Done():
+		l.release(key, lock)
+		return nil, ctx.Err()
+	}
+}
+
+func (l *localKeyLocker) release(key string, lock *localKeyLock) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if lock.users--; lock.users == 0 {
+		delete(l.keys, key)
+	}
+}
+
+// databaseKeyLocker locks keys across instances with leases in the
+// database, polled until free. Holders renew their lease every third of its
+// duration, so a write outlasting it keeps the item; a lease held by a
+// crashed instance is free again once it expires. A holder whose lease
+// expired and was taken loses the lock.
+type databaseKeyLocker struct {
+	db    *storage.DatabaseConnection
+	lease time.Duration
+}
+
+func (l *databaseKeyLocker) Lock(ctx context.Context, key string, lost func()) (func(), error) {
+	// Every acquisition holds the lease under its own token, so it is
+	// never mistaken for a renewal
+	token, err := NewItemID()
+	if err != nil {
+		return nil, err
+	}
+	name := "item:" + key
+	wait := 5 * time.Millisecond
+	for {
+		acquired, err := l.db.AcquireLease(name, token, l.lease)
+		if err != nil {
+			return nil, fmt.Errorf("failed to lock item: %w", err)
+		}
+		if acquired {
+			return l.hold(name, token, lost), nil
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(wait):
+		}
+		wait = min(2*wait, 100*time.Millisecond)
+	}
+}
+
+// hold renews a lease until the returned unlock stops renewing it and
+// releases it, calling lost if another write takes it first
+func (l *databaseKeyLocker) hold(name, token string, lost func()) func() {
+	stop, done := make(chan struct{}), make(chan struct{})
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(l.lease / 3)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+			}
+			acquired, err := l.db.AcquireLease(name, token, l.lease)
+			switch {
+			case err != nil:
+				// The lease is still held until it expires, so the next
+				// renewal may succeed in time
+				logging.Printf("Failed to renew lock %s: %v", name, err)
+			case !acquired:
+				logging.Printf("Lock %s expired and was taken by another write", name)
+				lost()
+				return
+			}
+		}
+	}()
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			close(stop)
+			<-done
+			// An unreleased lease expires on its own
+			_ = l.db.ReleaseLease(name, token)
+		})
+	}
+}
+
+// conditionalLockTimeout is how long a conditional write waits for another
+// to the same item when locking is disabled
+const conditionalLockTimeout = 5 * time.Second
+
+// newConditionalLocks returns the locks conditional writes take when
+// locking is disabled, so their If-Match check and write are atomic within
+// this instance
+func newConditionalLocks() *ItemLocks {
+	return &ItemLocks{
+		locker:  &localKeyLocker{keys: make(map[string]*localKeyLock)},
+		timeout: conditionalLockTimeout,
+	}
+}
+
+// lockItem locks an item of the tenant in ctx for a write, waiting at most
+// the lock timeout. The returned context marks the item as held, so nested
+// writes to it, such as PutData updating an item, don't lock it again. If
+// the lock is lost, the context is cancelled with ErrLockTimeout as its
+// cause, and storage opened with it fails further writes. Without locking
+// only conditional writes lock, within this instance.
+func (ds *DataService) lockItem(ctx context.Context, storageType, id string) (context.Context, func(), error) {
+	locks := ds.locks
+	if locks == nil {
+		if ifMatchFromContext(ctx) == "" {
+			return ctx, func() {}, nil
+		}
+		locks = ds.conditional
+	}
+	key := TenantFromContext(ctx) + "/" + storageType + "/" + id
+	if held, _ := ctx.Value(lockedItemKey).(string); held == key {
+		return ctx, func() {}, nil
+	}
+
+	held, release := context.WithCancelCause(ctx)
+	lockCtx, cancel := context.WithTimeout(ctx, locks.timeout)
+	defer cancel()
+	unlock, err := locks.locker.Lock(lockCtx, key, func() {
+		release(fmt.Errorf("%w: %s was taken by another write", ErrLockTimeout, id))
+	})
+	if err != nil {
+		release(nil)
+		if errors.Is(err, context.DeadlineExceeded) && ctx.Err() == nil {
+			return ctx, nil, fmt.Errorf("%w: %s", ErrLockTimeout, id)
+		}
+		return ctx, nil, err
+	}
+	return context.WithValue(held, lockedItemKey, key), func() {
+		unlock()
+		release(nil)
+	}, nil
+}
+
+// lockLost returns the error a write to an item held in ctx fails with once
+// its lock is lost, or nil while it is held
+func lockLost(ctx context.Context) error {
+	if err := context.Cause(ctx); errors.Is(err, ErrLockTimeout) {
+		return err
+	}
+	return nil
+}
+
+// lockedStorage fails writes once the item lock they were made under is
+// lost, as another write may hold the item by then
+type lockedStorage struct {
+	storage.StorageInterface
+	ctx context.Context
+}
+
+func (s *lockedStorage) Save(key string, data []byte) error {
+	if err := lockLost(s.ctx); err != nil {
+		return err
+	}
+	return s.StorageInterface.Save(key, data)
+}
+
+func (s *lockedStorage) Delete(key string) error {
+	if err := lockLost(s.ctx); err != nil {
+		return err
+	}
+	return s.StorageInterface.Delete(key)
+}
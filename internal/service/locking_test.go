@@ -0,0 +1,111 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"interview-task/internal/config"
+	"interview-task/internal/storage"
+)
+
+func TestDatabaseLockOutlivesLease(t *testing.T) {
+	db, err := storage.NewDatabaseConnection("localhost", 5432, "test", "test", "test", config.NewConfiguration().DatabasePool)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lease := 60 * time.Millisecond
+	locker := &databaseKeyLocker{db: db, lease: lease}
+
+	unlock, err := locker.Lock(context.Background(), "item", func() { t.Error("the lock was lost") })
+	if err != nil {
+		t.Fatal(err)
+	}
+	// A write running past the lease still holds the item
+	time.Sleep(3 * lease)
+	ctx, cancel := context.WithTimeout(context.Background(), lease/2)
+	defer cancel()
+	if _, err := locker.Lock(ctx, "item", func() {}); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("second Lock returned %v while the first write held the item", err)
+	}
+
+	unlock()
+	unlock2, err := locker.Lock(context.Background(), "item", func() {})
+	if err != nil {
+		t.Fatalf("Lock after unlock returned %v", err)
+	}
+	unlock2()
+}
+
+func TestDatabaseLockLost(t *testing.T) {
+	db, err := storage.NewDatabaseConnection("localhost", 5432, "test", "test", "test", config.NewConfiguration().DatabasePool)
+	if err != nil {
+		t.Fatal(err)
+	}
+	locker := &databaseKeyLocker{db: db, lease: 30 * time.Millisecond}
+	// Another write took the lease after it expired
+	if acquired, err := db.AcquireLease("item:item", "other", time.Minute); err != nil || !acquired {
+		t.Fatalf("AcquireLease = %v, %v", acquired, err)
+	}
+
+	lost := make(chan struct{})
+	unlock := locker.hold("item:item", "mine", func() { close(lost) })
+	defer unlock()
+	select {
+	case <-lost:
+	case <-time.After(time.Second):
+		t.Fatal("the holder wasn't told it lost the lock")
+	}
+}
+
+// takenLocker grants every lock, keeping its lost callback so a test can
+// take the lock away
+type takenLocker struct{ lost func() }
+
+func (l *takenLocker) Lock(_ context.Context, _ string, lost func()) (func(), error) {
+	l.lost = lost
+	return func() {}, nil
+}
+
+// nopFactory serves storage accepting every call
+type nopFactory struct{}
+
+func (nopFactory) CreateStorage(string, string) (storage.StorageInterface, error) {
+	return nopStorage{}, nil
+}
+
+type nopStorage struct{}
+
+func (nopStorage) Save(string, []byte) error   { return nil }
+func (nopStorage) Load(string) ([]byte, error) { return nil, storage.ErrNotFound }
+func (nopStorage) Delete(string) error         { return nil }
+func (nopStorage) List() ([]string, error)     { return nil, nil }
+
+func TestLostLockFailsWrites(t *testing.T) {
+	locker := &takenLocker{}
+	ds := &DataService{factory: nopFactory{}, locks: &ItemLocks{locker: locker, timeout: time.Second}}
+	ctx, unlock, err := ds.lockItem(context.Background(), "file", "id")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer unlock()
+	store, err := ds.openStorage(ctx, "file")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Save("id", []byte("x")); err != nil {
+		t.Fatalf("Save while the lock is held returned %v", err)
+	}
+
+	locker.lost()
+	if cause := context.Cause(ctx); !errors.Is(cause, ErrLockTimeout) {
+		t.Fatalf("context of a lost lock ended with %v, want ErrLockTimeout", cause)
+	}
+	if err := store.Save("id", []byte("x")); !errors.Is(err, ErrLockTimeout) {
+		t.Fatalf("Save after the lock was lost returned %v, want ErrLockTimeout", err)
+	}
+	if err := store.Delete("id"); !errors.Is(err, ErrLockTimeout) {
+		t.Fatalf("Delete after the lock was lost returned %v, want ErrLockTimeout", err)
+	}
+}
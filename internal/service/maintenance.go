@@ -0,0 +1,89 @@
+package service
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"interview-task/internal/config"
+)
+
+// ErrMaintenance is returned for writes refused in maintenance mode
+var ErrMaintenance = errors.New("the service is in maintenance mode")
+
+// MaintenanceError carries the operator's message with ErrMaintenance
+type MaintenanceError struct {
+	Message string
+}
+
+func (e *MaintenanceError) Error() string {
+	if e.Message == "" {
+		return ErrMaintenance.Error()
+	}
+	return ErrMaintenance.Error() + ": " + e.Message
+}
+
+func (e *MaintenanceError) Is(target error) bool {
+	return target == ErrMaintenance
+}
+
+// MaintenanceStatus reports the maintenance mode
+type MaintenanceStatus struct {
+	Enabled bool      `json:"enabled"`
+	Message string    `json:"message,omitempty"`
+	Since   time.Time `json:"since,omitzero"`
+}
+
+// Maintenance - IMPLEMENTS a read-only switch for the data service. While
+// enabled, saves, updates, deletes and restores fail with ErrMaintenance,
+// and the jobs that write in the background skip their runs, so backends
+// can be migrated safely. Reads are unaffected.
+type Maintenance struct {
+	mu     sync.Mutex
+	status MaintenanceStatus
+}
+
+func NewMaintenance(config config.MaintenanceConfig) *Maintenance {
+	m := &Maintenance{}
+	m.Set(config.Enabled, config.Message)
+	return m
+}
+
+// Set turns maintenance mode on or off. Enabling it again only replaces
+// the message.
+func (m *Maintenance) Set(enabled bool, message string) MaintenanceStatus {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	switch {
+	case !enabled:
+		m.status = MaintenanceStatus{}
+	case !m.status.Enabled:
+		m.status = MaintenanceStatus{Enabled: true, Message: message, Since: time.Now().UTC()}
+	default:
+		m.status.Message = message
+	}
+	return m.status
+}
+
+func (m *Maintenance) Status() MaintenanceStatus {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.status
+}
+
+// Enabled reports whether writes are refused. A nil Maintenance never
+// refuses them.
+func (m *Maintenance) Enabled() bool {
+	return m != nil && m.Status().Enabled
+}
+
+// check fails with a MaintenanceError while maintenance mode is on
+func (m *Maintenance) check() error {
+	if m == nil {
+		return nil
+	}
+	if status := m.Status(); status.Enabled {
+		return &MaintenanceError{Message: status.Message}
+	}
+	return nil
+}
@@ -0,0 +1,248 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"interview-task/internal/config"
+	"interview-task/internal/logging"
+	"interview-task/internal/storage"
+)
+
+// ItemMetadata is stored next to each item as a linked record
+type ItemMetadata struct {
+	// RulesVersion identifies the extraction rules that produced Fields, so
+	// re-indexing can tell which records are stale
+	RulesVersion string         `json:"rules_version"`
+	Fields       map[string]any `json:"fields"`
+	IndexedAt    time.Time      `json:"indexed_at"`
+	// Owner is the API key that saved the item, for quota accounting
+	Owner string `json:"owner"`
+	Size  int    `json:"size"`
+	// Tags are carried over from the save and kept across updates
+	Tags map[string]string `json:"tags,omitempty"`
+	// Labels and Source come from the client; an update replaces them only
+	// when it sets them
+	Labels      map[string]string `json:"labels,omitempty"`
+	Source      string            `json:"source,omitempty"`
+	ContentType string            `json:"content_type,omitempty"`
+	SHA256      string            `json:"sha256"`
+	// KeyVersion is the master key version the storage type's encryption
+	// sealed the payload under; empty when it isn't encrypted
+	KeyVersion string `json:"key_version,omitempty"`
+	// ExpiresAt is when the item is deleted; zero keeps it forever
+	ExpiresAt time.Time `json:"expires_at,omitzero"`
+	// ModifiedAt is when the current payload was saved
+	ModifiedAt time.Time `json:"modified_at,omitzero"`
+	// DeletedAt marks a soft-deleted item kept in the trash until purged
+	DeletedAt time.Time `json:"deleted_at,omitzero"`
+	DeletedBy string    `json:"deleted_by,omitempty"`
+	// LegalHold keeps the item from being removed until it is lifted
+	LegalHold *LegalHold `json:"legal_hold,omitempty"`
+}
+
+// MetadataExtractor - IMPLEMENTS metadata extraction from configured rules
+type MetadataExtractor struct {
+	fields  []string
+	paths   []*JSONPath
+	version string
+}
+
+func NewMetadataExtractor(rules []config.MetadataRuleConfig) (*MetadataExtractor, error) {
+	extractor := &MetadataExtractor{}
+	seen := make(map[string]bool, len(rules))
+	for _, rule := range rules {
+		if rule.Field == "" {
+			return nil, fmt.Errorf("metadata rule for %q has no field name", rule.Path)
+		}
+		if seen[rule.Field] {
+			return nil, fmt.Errorf("duplicate metadata field: %s", rule.Field)
+		}
+		seen[rule.Field] = true
+
+		path, err := ParseJSONPath(rule.Path)
+		if err != nil {
+			return nil, err
+		}
+		extractor.fields = append(extractor.fields, rule.Field)
+		extractor.paths = append(extractor.paths, path)
+	}
+
+	// The version only depends on the rule set, not on its order in config
+	sorted := append([]config.MetadataRuleConfig(nil), rules...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Field < sorted[j].Field })
+	encoded, _ := json.Marshal(sorted)
+	sum := sha256.Sum256(encoded)
+	extractor.version = hex.EncodeToString(sum[:6])
+	return extractor, nil
+}
+
+func (e *MetadataExtractor) Version() string {
+	return e.version
+}
+
+// Extract evaluates every rule against payload. Payloads that aren't JSON
+// produce no fields.
+func (e *MetadataExtractor) Extract(payload []byte) map[string]any {
+	fields := make(map[string]any)
+	if len(e.paths) == 0 {
+		return fields
+	}
+
+	var doc any
+	if err := json.Unmarshal(payload, &doc); err != nil {
+		return fields
+	}
+	for i, path := range e.paths {
+		selected := path.Select(doc)
+		switch {
+		case len(selected) == 0:
+			continue
+		case path.Definite():
+			fields[e.fields[i]] = selected[0]
+		default:
+			fields[e.fields[i]] = selected
+		}
+	}
+	return fields
+}
+
+// metadataItemID links the metadata record to its item
+func metadataItemID(id string) string {
+	return id + "~metadata"
+}
+
+// isLinkedItemID reports whether a stored key belongs to another item
+// (derivations, metadata, versions) rather than being an item itself
+func isLinkedItemID(id string) bool {
+	return strings.Contains(id, "~")
+}
+
+// indexMetadata extracts and stores metadata for an item's payload. Fields
+// that don't come from the payload (owner, tags, content type) are taken
+// from base.
+func (ds *DataService) indexMetadata(ctx context.Context, store storage.StorageInterface, storageType, id string, payload []byte, base ItemMetadata) error {
+	record := base
+	record.RulesVersion = ds.extractor.Version()
+	record.Fields = ds.extractor.Extract(payload)
+	record.IndexedAt = time.Now().UTC()
+	record.Size = len(payload)
+	record.SHA256 = storage.PayloadSHA256(payload)
+	return ds.saveMetadata(ctx, store, storageType, id, &record)
+}
+
+// saveMetadata stores an item's metadata record and indexes it
+func (ds *DataService) saveMetadata(ctx context.Context, store storage.StorageInterface, storageType, id string, record *ItemMetadata) error {
+	encoded, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("failed to encode metadata: %w", err)
+	}
+	if err := store.Save(metadataItemID(id), encoded); err != nil {
+		return fmt.Errorf("failed to save metadata: %w", err)
+	}
+	ds.indexItem(ctx, store, storageType, id, record)
+	return nil
+}
+
+// loadMetadata returns the stored metadata record, or ErrNotFound
+func (ds *DataService) loadMetadata(storage storage.StorageInterface, id string) (*ItemMetadata, error) {
+	encoded, err := storage.Load(metadataItemID(id))
+	if err != nil {
+		return nil, err
+	}
+	var record ItemMetadata
+	if err := json.Unmarshal(encoded, &record); err != nil {
+		return nil, fmt.Errorf("corrupt metadata for %s: %w", id, err)
+	}
+	return &record, nil
+}
+
+// deleteLinkedItems removes every record linked to a deleted item
+func (ds *DataService) deleteLinkedItems(ctx context.Context, store storage.StorageInterface, storageType, id string) {
+	ds.deleteDerived(store, id)
+	ds.deleteVersions(store, id)
+	err := store.Delete(metadataItemID(id))
+	if err != nil && !errors.Is(err, storage.ErrNotFound) {
+		logging.Printf("Failed to delete metadata for %s: %v", id, err)
+	}
+	ds.unindexItem(ctx, store, storageType, id)
+}
+
+// ListItems returns the sorted IDs of the tenant's items in a storage type
+func (ds *DataService) ListItems(ctx context.Context, storageType string) ([]string, error) {
+	storage, err := ds.openStorage(ctx, storageType)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrValidation, err)
+	}
+
+	keys, err := storage.List()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list items: %w", err)
+	}
+	ids := keys[:0]
+	for _, key := range keys {
+		if !isLinkedItemID(key) {
+			ids = append(ids, key)
+		}
+	}
+	sort.Strings(ids)
+	return ids, nil
+}
+
+// ItemsWithTag returns the sorted IDs of the tenant's items carrying the tag
+func (ds *DataService) ItemsWithTag(ctx context.Context, storageType, key, value string) ([]string, error) {
+	ids, err := ds.ListItems(ctx, storageType)
+	if err != nil {
+		return nil, err
+	}
+	storage, err := ds.openStorage(ctx, storageType)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrValidation, err)
+	}
+
+	tagged := ids[:0]
+	for _, id := range ids {
+		record, err := ds.loadMetadata(storage, id)
+		if err == nil && record.Tags[key] == value {
+			tagged = append(tagged, id)
+		}
+	}
+	return tagged, nil
+}
+
+// ReindexItem regenerates an item's metadata when it was produced by older
+// extraction rules. The payload itself is never rewritten.
+func (ds *DataService) ReindexItem(ctx context.Context, storageType, id string) (bool, error) {
+	store, err := ds.openStorage(ctx, storageType)
+	if err != nil {
+		return false, fmt.Errorf("%w: %w", ErrValidation, err)
+	}
+
+	record, err := ds.loadMetadata(store, id)
+	if err != nil && !errors.Is(err, storage.ErrNotFound) {
+		return false, err
+	}
+	if record != nil && record.RulesVersion == ds.extractor.Version() {
+		return false, nil
+	}
+
+	payload, err := store.Load(id)
+	if err != nil {
+		return false, fmt.Errorf("failed to load data: %w", err)
+	}
+	base := ItemMetadata{}
+	if record != nil {
+		base = *record
+	}
+	if err := ds.indexMetadata(ctx, store, storageType, id, payload, base); err != nil {
+		return false, err
+	}
+	return true, nil
+}
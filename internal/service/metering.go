@@ -0,0 +1,224 @@
+package service
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"sync"
+	"time"
+
+	"interview-task/internal/config"
+	"interview-task/internal/logging"
+	"interview-task/internal/storage"
+)
+
+// meterDateLayout names the UTC day of an aggregate
+const meterDateLayout = time.DateOnly
+
+// MeterRecord is one API key's usage of one tenant on one UTC day
+type MeterRecord struct {
+	Date     string `json:"date"`
+	Tenant   string `json:"tenant"`
+	Key      string `json:"key"`
+	Requests int64  `json:"requests"`
+	// BytesIn and BytesOut count request and response bodies as the
+	// handlers read and wrote them, before compression
+	BytesIn  int64 `json:"bytes_in"`
+	BytesOut int64 `json:"bytes_out"`
+	// BytesStored is the most the key stored in the tenant that day, as
+	// sampled every flush
+	BytesStored int64 `json:"bytes_stored"`
+}
+
+// MeterQuery selects aggregates; zero values match everything. From and To
+// are inclusive dates.
+type MeterQuery struct {
+	From   string
+	To     string
+	Tenant string
+	Key    string
+}
+
+// meterKey identifies an aggregate
+type meterKey struct {
+	date, tenant, key string
+}
+
+// Meter - IMPLEMENTS usage metering for chargeback. Requests and bytes
+// transferred are added up per tenant, API key and day as they are served;
+// bytes stored are sampled from the quota manager's usage. The aggregates
+// are written to a file every flush interval and at shutdown.
+type Meter struct {
+	config config.MeteringConfig
+	quotas *QuotaManager
+
+	mu      sync.Mutex
+	records map[meterKey]*MeterRecord
+
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// NewMeter loads the persisted aggregates; it returns nil when metering is
+// disabled
+func NewMeter(config config.MeteringConfig, quotas *QuotaManager) (*Meter, error) {
+	if !config.Enabled {
+		return nil, nil
+	}
+	m := &Meter{config: config, quotas: quotas, records: make(map[meterKey]*MeterRecord)}
+	if config.File == "" {
+		return m, nil
+	}
+
+	data, err := os.ReadFile(config.File)
+	if errors.Is(err, os.ErrNotExist) {
+		return m, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read metering file: %w", err)
+	}
+	var records []*MeterRecord
+	if err := json.Unmarshal(data, &records); err != nil {
+		return nil, fmt.Errorf("failed to parse metering file: %w", err)
+	}
+	for _, record := range records {
+		m.records[meterKey{record.Date, record.Tenant, record.Key}] = record
+	}
+	return m, nil
+}
+
+// recordLocked returns the mutable aggregate for key on date; callers hold
+// m.mu
+func (m *Meter) recordLocked(date, tenant, key string) *MeterRecord {
+	k := meterKey{date, tenant, key}
+	record, ok := m.records[k]
+	if !ok {
+		record = &MeterRecord{Date: date, Tenant: tenant, Key: key}
+		m.records[k] = record
+	}
+	return record
+}
+
+// Record adds a served request to today's aggregate for the tenant and key
+func (m *Meter) Record(tenant, key string, bytesIn, bytesOut int64) {
+	if m == nil {
+		return
+	}
+	date := time.Now().UTC().Format(meterDateLayout)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	record := m.recordLocked(date, tenant, key)
+	record.Requests++
+	record.BytesIn += bytesIn
+	record.BytesOut += bytesOut
+}
+
+// sample raises today's stored bytes to what each key stores now
+func (m *Meter) sample() {
+	owners := m.quotas.OwnerUsage()
+	date := time.Now().UTC().Format(meterDateLayout)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, owner := range owners {
+		if owner.Usage.Bytes == 0 {
+			continue
+		}
+		record := m.recordLocked(date, owner.Tenant, owner.Key)
+		record.BytesStored = max(record.BytesStored, owner.Usage.Bytes)
+	}
+}
+
+// Query returns the matching aggregates ordered by date, tenant and key.
+// Today's stored bytes are sampled first, so they are current.
+func (m *Meter) Query(query MeterQuery) []MeterRecord {
+	if m == nil {
+		return nil
+	}
+	m.sample()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var records []MeterRecord
+	for k, record := range m.records {
+		if query.From != "" && k.date < query.From || query.To != "" && k.date > query.To {
+			continue
+		}
+		if query.Tenant != "" && k.tenant != query.Tenant || query.Key != "" && k.key != query.Key {
+			continue
+		}
+		records = append(records, *record)
+	}
+	slices.SortFunc(records, func(a, b MeterRecord) int {
+		return cmp.Or(cmp.Compare(a.Date, b.Date), cmp.Compare(a.Tenant, b.Tenant), cmp.Compare(a.Key, b.Key))
+	})
+	return records
+}
+
+// Start flushes the aggregates every flush interval until Shutdown
+func (m *Meter) Start() {
+	if m == nil || m.config.FlushInterval <= 0 {
+		return
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	m.cancel, m.done = cancel, make(chan struct{})
+	go m.run(ctx, m.done)
+}
+
+func (m *Meter) run(ctx context.Context, done chan struct{}) {
+	defer close(done)
+	ticker := time.NewTicker(time.Duration(m.config.FlushInterval))
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		m.flush()
+	}
+}
+
+// flush samples stored bytes, drops aggregates past retention and writes
+// the rest to the metering file
+func (m *Meter) flush() {
+	m.sample()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.config.RetentionDays > 0 {
+		cutoff := time.Now().UTC().AddDate(0, 0, -m.config.RetentionDays).Format(meterDateLayout)
+		for k := range m.records {
+			if k.date < cutoff {
+				delete(m.records, k)
+			}
+		}
+	}
+	if m.config.File == "" {
+		return
+	}
+	records := make([]*MeterRecord, 0, len(m.records))
+	for _, record := range m.records {
+		records = append(records, record)
+	}
+	data, err := json.Marshal(records)
+	if err == nil {
+		err = storage.WriteFileAtomic(m.config.File, data)
+	}
+	if err != nil {
+		logging.Printf("Failed to persist metering: %v", err)
+	}
+}
+
+// Shutdown stops flushing, then writes the aggregates a last time
+func (m *Meter) Shutdown() {
+	if m == nil {
+		return
+	}
+	if m.cancel != nil {
+		m.cancel()
+		<-m.done
+	}
+	m.flush()
+}
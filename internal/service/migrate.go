@@ -0,0 +1,277 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"interview-task/internal/logging"
+	"interview-task/internal/storage"
+)
+
+// Migration job states
+const (
+	MigrationIdle      = "idle"
+	MigrationRunning   = "running"
+	MigrationCompleted = "completed"
+	MigrationCancelled = "cancelled"
+	MigrationFailed    = "failed"
+)
+
+// maxMigrationFailures bounds the failed keys a status lists
+const maxMigrationFailures = 100
+
+// MigrationRequest selects what a migration copies and how
+type MigrationRequest struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+	// Tenants restricts the migration; empty migrates every tenant
+	Tenants []string `json:"tenants,omitempty"`
+	// RateLimit is the most keys copied per second; zero doesn't throttle
+	RateLimit int `json:"rate_limit,omitempty"`
+	// DryRun counts what would be copied without writing anything
+	DryRun bool `json:"dry_run,omitempty"`
+}
+
+// MigrationFailure is a key that couldn't be copied or verified
+type MigrationFailure struct {
+	Tenant string `json:"tenant"`
+	Key    string `json:"key"`
+	Error  string `json:"error"`
+}
+
+// MigrationStatus reports a migration's progress. Total is known once every
+// tenant's keys are listed; Copied counts keys that would be copied during
+// a dry run.
+type MigrationStatus struct {
+	State   string            `json:"state"`
+	Request *MigrationRequest `json:"request,omitempty"`
+	Total   int               `json:"total"`
+	Scanned int               `json:"scanned"`
+	Copied  int               `json:"copied"`
+	// Skipped keys already held the same payload in the target
+	Skipped    int                `json:"skipped"`
+	Failed     int                `json:"failed"`
+	Failures   []MigrationFailure `json:"failures,omitempty"`
+	Error      string             `json:"error,omitempty"`
+	StartedAt  time.Time          `json:"started_at"`
+	FinishedAt time.Time          `json:"finished_at"`
+}
+
+// Migrator - IMPLEMENTS copying every tenant's items from one storage type
+// to another. Keys are copied as stored, below deduplication, so versions,
+// metadata and deduplicated blobs come along. Every copy is read back and
+// verified by checksum. The source is left intact; clients switch storage
+// types once the migration completes.
+type Migrator struct {
+	factory *storage.ConcreteStorageFactory
+
+	mu     sync.Mutex
+	status MigrationStatus
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+func NewMigrator(factory *storage.ConcreteStorageFactory) *Migrator {
+	return &Migrator{factory: factory, status: MigrationStatus{State: MigrationIdle}}
+}
+
+// Start migrates in the background
+func (m *Migrator) Start(req MigrationRequest) (MigrationStatus, error) {
+	storageTypes := storage.StorageTypes()
+	switch {
+	case !slices.Contains(storageTypes, req.From):
+		return MigrationStatus{}, fmt.Errorf("%w: unknown source storage type %q", ErrValidation, req.From)
+	case !slices.Contains(storageTypes, req.To):
+		return MigrationStatus{}, fmt.Errorf("%w: unknown target storage type %q", ErrValidation, req.To)
+	case req.From == req.To:
+		return MigrationStatus{}, fmt.Errorf("%w: source and target are the same storage type", ErrValidation)
+	case req.RateLimit < 0:
+		return MigrationStatus{}, fmt.Errorf("%w: rate_limit must not be negative", ErrValidation)
+	}
+	for _, tenant := range req.Tenants {
+		if err := ValidateTenant(tenant); err != nil {
+			return MigrationStatus{}, fmt.Errorf("%w: %w", ErrValidation, err)
+		}
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.cancel != nil {
+		return m.status, fmt.Errorf("%w: a migration is already running", storage.ErrConflict)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	m.status = MigrationStatus{State: MigrationRunning, Request: &req, StartedAt: time.Now().UTC()}
+	m.cancel = cancel
+	m.done = make(chan struct{})
+	go m.run(ctx, req, m.done)
+	return m.status, nil
+}
+
+func (m *Migrator) run(ctx context.Context, req MigrationRequest, done chan struct{}) {
+	defer close(done)
+	err := m.migrate(ctx, req)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	switch {
+	case err == nil:
+		m.status.State = MigrationCompleted
+	case errors.Is(err, context.Canceled):
+		m.status.State = MigrationCancelled
+	default:
+		logging.Printf("Migration from %s to %s failed: %v", req.From, req.To, err)
+		m.status.State = MigrationFailed
+		m.status.Error = err.Error()
+	}
+	m.status.FinishedAt = time.Now().UTC()
+	m.cancel = nil
+}
+
+func (m *Migrator) migrate(ctx context.Context, req MigrationRequest) error {
+	tenants := req.Tenants
+	if len(tenants) == 0 {
+		var err error
+		if tenants, err = m.factory.Tenants(req.From); err != nil {
+			return err
+		}
+	}
+
+	// Listing first gives the progress a total
+	keys := make(map[string][]string, len(tenants))
+	for _, tenant := range tenants {
+		source, err := m.factory.Sharded(tenant, req.From)
+		if err != nil {
+			return err
+		}
+		if keys[tenant], err = source.List(); err != nil {
+			return fmt.Errorf("failed to list %s of tenant %s: %w", req.From, tenant, err)
+		}
+		m.mu.Lock()
+		m.status.Total += len(keys[tenant])
+		m.mu.Unlock()
+	}
+
+	var throttle <-chan time.Time
+	if req.RateLimit > 0 {
+		ticker := time.NewTicker(time.Second / time.Duration(req.RateLimit))
+		defer ticker.Stop()
+		throttle = ticker.C
+	}
+	for _, tenant := range tenants {
+		source, err := m.factory.Sharded(tenant, req.From)
+		if err != nil {
+			return err
+		}
+		target, err := m.factory.Sharded(tenant, req.To)
+		if err != nil {
+			return err
+		}
+		for _, key := range keys[tenant] {
+			if throttle != nil {
+				select {
+				case <-ctx.Done():
+				case <-throttle:
+				}
+			}
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			copied, err := m.copyKey(source, target, key, req.DryRun)
+			if copied && !req.DryRun {
+				m.factory.Invalidate(tenant, req.To, key)
+			}
+			m.record(tenant, key, copied, err)
+		}
+	}
+	return nil
+}
+
+// copyKey copies one key unless the target already holds its payload, and
+// verifies the copy by checksum
+func (m *Migrator) copyKey(source, target storage.StorageInterface, key string, dryRun bool) (copied bool, err error) {
+	data, err := source.Load(key)
+	if errors.Is(err, storage.ErrNotFound) {
+		// Deleted since it was listed
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to load: %w", err)
+	}
+	checksum := storage.PayloadSHA256(data)
+	existing, err := target.Load(key)
+	switch {
+	case err == nil && storage.PayloadSHA256(existing) == checksum:
+		return false, nil
+	case err != nil && !errors.Is(err, storage.ErrNotFound):
+		return false, fmt.Errorf("failed to check target: %w", err)
+	case dryRun:
+		return true, nil
+	}
+
+	if err := target.Save(key, data); err != nil {
+		return false, fmt.Errorf("failed to save: %w", err)
+	}
+	written, err := target.Load(key)
+	if err != nil {
+		return false, fmt.Errorf("failed to verify: %w", err)
+	}
+	if storage.PayloadSHA256(written) != checksum {
+		return false, fmt.Errorf("%w: copy differs from the source", ErrChecksumMismatch)
+	}
+	return true, nil
+}
+
+func (m *Migrator) record(tenant, key string, copied bool, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.status.Scanned++
+	switch {
+	case err != nil:
+		m.status.Failed++
+		if len(m.status.Failures) < maxMigrationFailures {
+			m.status.Failures = append(m.status.Failures, MigrationFailure{Tenant: tenant, Key: key, Error: err.Error()})
+		}
+		logging.Printf("Failed to migrate %s of tenant %s: %v", key, tenant, err)
+	case copied:
+		m.status.Copied++
+	default:
+		m.status.Skipped++
+	}
+}
+
+func (m *Migrator) Status() MigrationStatus {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	status := m.status
+	status.Failures = slices.Clone(status.Failures)
+	return status
+}
+
+// Cancel stops a running migration. Keys copied so far stay in the target;
+// running the migration again skips them.
+func (m *Migrator) Cancel() (MigrationStatus, error) {
+	m.mu.Lock()
+	cancel, done := m.cancel, m.done
+	m.mu.Unlock()
+	if cancel == nil {
+		return m.Status(), fmt.Errorf("%w: no migration is running", storage.ErrConflict)
+	}
+	cancel()
+	<-done
+	return m.Status(), nil
+}
+
+// Shutdown cancels a running migration
+func (m *Migrator) Shutdown() {
+	m.mu.Lock()
+	cancel, done := m.cancel, m.done
+	m.mu.Unlock()
+	if cancel != nil {
+		cancel()
+		<-done
+	}
+}
@@ -0,0 +1,125 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"interview-task/internal/config"
+	"interview-task/internal/logging"
+	"interview-task/internal/metrics"
+	"interview-task/internal/storage"
+)
+
+// Outbox - IMPLEMENTS the transactional outbox. A save made in a database
+// transaction stages its event in the outbox table before committing, so the
+// event is recorded if and only if the save is. The dispatcher publishes
+// staged events on the event bus, reaching event streams and webhooks, and
+// marks them published. An event published just before a crash may be
+// published again, but none is lost.
+type Outbox struct {
+	config  config.OutboxConfig
+	db      *storage.DatabaseConnection
+	events  *EventBus
+	metrics *metrics.Metrics
+
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+func NewOutbox(config config.OutboxConfig, db *storage.DatabaseConnection, events *EventBus, metrics *metrics.Metrics) *Outbox {
+	o := &Outbox{config: config, db: db, events: events, metrics: metrics}
+	if o.enabled() {
+		metrics.Describe("outbox_published_total", "Staged events published from the outbox")
+		metrics.GaugeFunc("outbox_pending", "Staged events waiting in the outbox", func() int64 {
+			return int64(db.OutboxBacklog())
+		})
+	}
+	return o
+}
+
+func (o *Outbox) enabled() bool {
+	return o != nil && o.config.Enabled && o.db != nil
+}
+
+// stage adds event to the outbox in tx
+func (o *Outbox) stage(tx *storage.StorageTx, event StorageEvent) error {
+	record, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to encode outbox event: %w", err)
+	}
+	return tx.AppendOutbox(record)
+}
+
+// Start publishes staged events every interval until Shutdown
+func (o *Outbox) Start() {
+	if !o.enabled() || o.config.Interval <= 0 {
+		return
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	o.cancel, o.done = cancel, make(chan struct{})
+	go o.run(ctx, o.done)
+}
+
+func (o *Outbox) run(ctx context.Context, done chan struct{}) {
+	defer close(done)
+	ticker := time.NewTicker(time.Duration(o.config.Interval))
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		if err := o.Dispatch(ctx); err != nil && !errors.Is(err, context.Canceled) {
+			logging.Printf("Outbox dispatch paused: %v", err)
+		}
+	}
+}
+
+// Dispatch publishes every staged event, oldest first, in batches
+func (o *Outbox) Dispatch(ctx context.Context) error {
+	if !o.enabled() {
+		return nil
+	}
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		records, err := o.db.PendingOutbox(max(o.config.BatchSize, 1))
+		if err != nil {
+			return err
+		}
+		if len(records) == 0 {
+			return nil
+		}
+		seqs := make([]int64, len(records))
+		for i, record := range records {
+			seqs[i] = record.Seq
+			var event StorageEvent
+			if err := json.Unmarshal(record.Record, &event); err != nil {
+				// Marked anyway, so a corrupt record can't block the outbox
+				logging.Printf("Dropping corrupt outbox event %d: %v", record.Seq, err)
+				continue
+			}
+			o.events.Publish(event)
+		}
+		if err := o.db.MarkPublished(seqs...); err != nil {
+			return fmt.Errorf("failed to mark outbox events published: %w", err)
+		}
+		o.metrics.Add("outbox_published_total", int64(len(records)))
+	}
+}
+
+// Shutdown stops the dispatcher, then publishes what is still staged
+func (o *Outbox) Shutdown() {
+	if o.cancel != nil {
+		o.cancel()
+		<-o.done
+	}
+	if err := o.Dispatch(context.Background()); err != nil {
+		logging.Printf("Failed to publish staged events: %v", err)
+	}
+}
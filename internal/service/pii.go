@@ -0,0 +1,206 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"regexp"
+	"slices"
+	"strings"
+
+	"interview-task/internal/config"
+)
+
+// PIILabel is the label tagging items that contain personal data, listing
+// what was found
+const PIILabel = "pii"
+
+const defaultPIIMask = "[REDACTED]"
+
+// piiDetector finds one kind of personal data. check, when set, weeds out
+// matches of the pattern that aren't real, such as card numbers failing
+// their checksum.
+type piiDetector struct {
+	name    string
+	pattern *regexp.Regexp
+	check   func(match string) bool
+}
+
+// builtinPIIDetectors are the detectors selectable by name
+var builtinPIIDetectors = map[string]*piiDetector{
+	"email": {name: "email", pattern: regexp.MustCompile(`[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}`)},
+	"card_number": {
+		name:    "card_number",
+		pattern: regexp.MustCompile(`\b(?:\d[ -]?){12,18}\d\b`),
+		check:   luhnValid,
+	},
+	// US social security and UK national insurance numbers
+	"national_id": {name: "national_id", pattern: regexp.MustCompile(`\b\d{3}-\d{2}-\d{4}\b|\b[A-CEGHJ-PR-TW-Z]{2}\d{6}[A-D]\b`)},
+}
+
+// luhnValid reports whether the digits of a card number pass the Luhn
+// checksum
+func luhnValid(match string) bool {
+	var digits []int
+	for _, r := range match {
+		if r >= '0' && r <= '9' {
+			digits = append(digits, int(r-'0'))
+		}
+	}
+	if len(digits) < 13 || len(digits) > 19 {
+		return false
+	}
+	sum := 0
+	for i, d := range slices.Backward(digits) {
+		if (len(digits)-1-i)%2 == 1 {
+			if d *= 2; d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+	}
+	return sum%10 == 0
+}
+
+// PIIScanner - IMPLEMENTS personal data detection as a before-save hook.
+// String and number values of JSON payloads are scanned; a payload with
+// matches is rejected, masked or tagged as configured. Other payloads are
+// stored as they are.
+type PIIScanner struct {
+	action    string
+	mask      string
+	detectors []*piiDetector
+}
+
+// NewPIIScanner returns nil when scanning is disabled
+func NewPIIScanner(config config.PIIConfig) (*PIIScanner, error) {
+	switch config.Action {
+	case "":
+		return nil, nil
+	case "reject", "mask", "tag":
+	default:
+		return nil, fmt.Errorf("unsupported pii action: %s", config.Action)
+	}
+	s := &PIIScanner{action: config.Action, mask: config.Mask}
+	if s.mask == "" {
+		s.mask = defaultPIIMask
+	}
+
+	names := config.Detectors
+	if len(names) == 0 {
+		names = slices.Sorted(maps.Keys(builtinPIIDetectors))
+	}
+	for _, name := range names {
+		detector, ok := builtinPIIDetectors[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown pii detector: %s", name)
+		}
+		s.detectors = append(s.detectors, detector)
+	}
+	for _, name := range slices.Sorted(maps.Keys(config.Patterns)) {
+		pattern, err := regexp.Compile(config.Patterns[name])
+		if err != nil {
+			return nil, fmt.Errorf("invalid pii pattern %s: %w", name, err)
+		}
+		s.detectors = append(s.detectors, &piiDetector{name: name, pattern: pattern})
+	}
+	return s, nil
+}
+
+// Hooks scans every save and update
+func (s *PIIScanner) Hooks() Hooks {
+	if s == nil {
+		return Hooks{}
+	}
+	return Hooks{BeforeSave: []BeforeSaveHook{s.beforeSave}}
+}
+
+func (s *PIIScanner) beforeSave(ctx context.Context, action, id string, req *SaveRequest) error {
+	if !json.Valid(req.Data) {
+		return nil
+	}
+	decoder := json.NewDecoder(bytes.NewReader(req.Data))
+	// Numbers are scanned as written, so long card numbers keep every digit
+	decoder.UseNumber()
+	var value any
+	if err := decoder.Decode(&value); err != nil {
+		return nil
+	}
+	found := map[string]bool{}
+	masked := s.walk(value, found)
+	if len(found) == 0 {
+		return nil
+	}
+	kinds := slices.Sorted(maps.Keys(found))
+
+	switch s.action {
+	case "reject":
+		return fmt.Errorf("payload contains personal data: %s", strings.Join(kinds, ", "))
+	case "mask":
+		// Checksums cover the payload as sent, so they are checked before
+		// it is rewritten
+		if err := verifyChecksums(req); err != nil {
+			return err
+		}
+		data, err := json.Marshal(masked)
+		if err != nil {
+			return fmt.Errorf("failed to mask payload: %w", err)
+		}
+		req.Data, req.ExpectedMD5, req.ExpectedSHA256 = data, nil, nil
+	case "tag":
+		labels := maps.Clone(req.Labels)
+		if labels == nil {
+			labels = map[string]string{}
+		}
+		labels[PIILabel] = strings.Join(kinds, ",")
+		req.Labels = labels
+	}
+	return nil
+}
+
+// walk notes the kinds of personal data in value and returns it with every
+// match masked
+func (s *PIIScanner) walk(value any, found map[string]bool) any {
+	switch v := value.(type) {
+	case map[string]any:
+		for key, item := range v {
+			v[key] = s.walk(item, found)
+		}
+	case []any:
+		for i, item := range v {
+			v[i] = s.walk(item, found)
+		}
+	case string:
+		return s.redact(v, found)
+	case json.Number:
+		if masked := s.redact(string(v), found); masked != string(v) {
+			return masked
+		}
+	}
+	return value
+}
+
+// redact masks every match in text, noting the kinds found
+func (s *PIIScanner) redact(text string, found map[string]bool) string {
+	for _, detector := range s.detectors {
+		text = detector.pattern.ReplaceAllStringFunc(text, func(match string) string {
+			if detector.check != nil && !detector.check(match) {
+				return match
+			}
+			found[detector.name] = true
+			return s.mask
+		})
+	}
+	return text
+}
+
+// Redact masks personal data anywhere in data, such as a payload about to
+// be logged. Without a scanner data is returned as it is.
+func (s *PIIScanner) Redact(data []byte) []byte {
+	if s == nil {
+		return data
+	}
+	return []byte(s.redact(string(data), map[string]bool{}))
+}
@@ -0,0 +1,33 @@
+package service
+
+import (
+	"slices"
+)
+
+// Roles granted to API keys
+const (
+	RoleAdmin   = "admin"
+	RoleAuditor = "auditor"
+)
+
+// Principal identifies the caller of a request
+type Principal struct {
+	Name   string
+	Roles  []string
+	Tenant string
+	// RateLimit caps the caller's requests per second, with bursts of up
+	// to Burst; zero is unlimited
+	RateLimit float64 `json:",omitempty"`
+	Burst     int     `json:",omitempty"`
+}
+
+var anonymousPrincipal = &Principal{Name: "anonymous"}
+
+func (p *Principal) HasRole(role string) bool {
+	return slices.Contains(p.Roles, role)
+}
+
+// Authenticated reports whether the caller presented an API key
+func (p *Principal) Authenticated() bool {
+	return p != anonymousPrincipal
+}
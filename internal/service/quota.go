@@ -0,0 +1,303 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"interview-task/internal/config"
+	"interview-task/internal/logging"
+	"interview-task/internal/storage"
+)
+
+var ErrQuotaExceeded = errors.New("quota exceeded")
+
+// Usage is what a tenant or key currently has stored
+type Usage struct {
+	Bytes   int64 `json:"bytes"`
+	Objects int64 `json:"objects"`
+}
+
+// QuotaError reports which limit a save would exceed, with current usage
+type QuotaError struct {
+	Scope string
+	Limit string
+	Max   int64
+	Usage Usage
+}
+
+func (e *QuotaError) Error() string {
+	return fmt.Sprintf("quota exceeded for %s: %s limit %d (current usage: %d bytes, %d objects)",
+		e.Scope, e.Limit, e.Max, e.Usage.Bytes, e.Usage.Objects)
+}
+
+func (e *QuotaError) Unwrap() error {
+	return ErrQuotaExceeded
+}
+
+// QuotaManager - IMPLEMENTS usage tracking and quota enforcement. Usage is
+// persisted to a file so it survives restarts; changes are flushed in the
+// background rather than on every save.
+type QuotaManager struct {
+	config config.QuotaConfig
+
+	mu    sync.Mutex
+	usage map[string]*Usage
+	// dirty is set when usage changed since the last flush
+	dirty bool
+	// managed are the limits of managed API keys, by key name; they take
+	// precedence over the configured ones
+	managed map[string]config.QuotaLimits
+
+	// flushing keeps flushes from writing the file concurrently
+	flushing sync.Mutex
+	cancel   context.CancelFunc
+	done     chan struct{}
+}
+
+func NewQuotaManager(config config.QuotaConfig) (*QuotaManager, error) {
+	q := &QuotaManager{config: config, usage: make(map[string]*Usage)}
+	if config.UsageFile == "" {
+		return q, nil
+	}
+
+	data, err := os.ReadFile(config.UsageFile)
+	if errors.Is(err, os.ErrNotExist) {
+		return q, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read usage file: %w", err)
+	}
+	if err := json.Unmarshal(data, &q.usage); err != nil {
+		return nil, fmt.Errorf("failed to parse usage file: %w", err)
+	}
+	return q, nil
+}
+
+func tenantScope(tenant string) string { return "tenant/" + tenant }
+
+func keyScope(key string) string { return "key/" + key }
+
+// ownerScope tracks what one key stores in one tenant, for metering; it has
+// no limits
+func ownerScope(tenant, key string) string { return "owner/" + tenant + "/" + key }
+
+func (q *QuotaManager) limitsFor(scope string) config.QuotaLimits {
+	if tenant, ok := strings.CutPrefix(scope, "tenant/"); ok {
+		if limits, ok := q.config.Tenants[tenant]; ok {
+			return limits
+		}
+		return q.config.Tenant
+	}
+	key, _ := strings.CutPrefix(scope, "key/")
+	if limits, ok := q.managed[key]; ok {
+		return limits
+	}
+	if limits, ok := q.config.Keys[key]; ok {
+		return limits
+	}
+	return q.config.Key
+}
+
+// SetManagedLimits replaces the limits of managed API keys
+func (q *QuotaManager) SetManagedLimits(limits map[string]config.QuotaLimits) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.managed = limits
+}
+
+// usageLocked returns the mutable usage for scope; callers hold q.mu
+func (q *QuotaManager) usageLocked(scope string) *Usage {
+	usage, ok := q.usage[scope]
+	if !ok {
+		usage = &Usage{}
+		q.usage[scope] = usage
+	}
+	return usage
+}
+
+// Reserve accounts for new bytes and objects against both the tenant and the
+// key, failing without side effects if either would go over quota. A new
+// version of an existing item adds bytes but no object.
+func (q *QuotaManager) Reserve(tenant, key string, bytes, objects int64) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	scopes := []string{tenantScope(tenant), keyScope(key)}
+	if err := q.checkLocked(scopes, bytes, objects); err != nil {
+		return err
+	}
+	for _, scope := range append(scopes, ownerScope(tenant, key)) {
+		usage := q.usageLocked(scope)
+		usage.Bytes += bytes
+		usage.Objects += objects
+	}
+	q.dirty = true
+	return nil
+}
+
+// Check reports whether Reserve would succeed, without reserving anything
+func (q *QuotaManager) Check(tenant, key string, bytes, objects int64) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.checkLocked([]string{tenantScope(tenant), keyScope(key)}, bytes, objects)
+}
+
+// checkLocked fails if the usage would go over quota in any of scopes;
+// callers hold q.mu
+func (q *QuotaManager) checkLocked(scopes []string, bytes, objects int64) error {
+	for _, scope := range scopes {
+		usage := q.usage[scope]
+		if usage == nil {
+			usage = &Usage{}
+		}
+		limits := q.limitsFor(scope)
+		if limits.MaxBytes > 0 && usage.Bytes+bytes > limits.MaxBytes {
+			return &QuotaError{Scope: scope, Limit: "bytes", Max: limits.MaxBytes, Usage: *usage}
+		}
+		if limits.MaxObjects > 0 && usage.Objects+objects > limits.MaxObjects {
+			return &QuotaError{Scope: scope, Limit: "objects", Max: limits.MaxObjects, Usage: *usage}
+		}
+	}
+	return nil
+}
+
+// Release gives back the usage of a deleted object or a failed save. The key
+// is empty for objects whose owner was never recorded.
+func (q *QuotaManager) Release(tenant, key string, bytes, objects int64) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	scopes := []string{tenantScope(tenant)}
+	if key != "" {
+		scopes = append(scopes, keyScope(key), ownerScope(tenant, key))
+	}
+	for _, scope := range scopes {
+		usage := q.usageLocked(scope)
+		usage.Bytes = max(usage.Bytes-bytes, 0)
+		usage.Objects = max(usage.Objects-objects, 0)
+	}
+	q.dirty = true
+}
+
+// Recount replaces the tracked usage, by scope, with a fresh count
+func (q *QuotaManager) Recount(usage map[string]Usage) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.usage = make(map[string]*Usage, len(usage))
+	for scope, current := range usage {
+		q.usage[scope] = &current
+	}
+	q.dirty = true
+}
+
+// Start flushes changed usage to the usage file every flush interval
+func (q *QuotaManager) Start() {
+	if q.config.UsageFile == "" || q.config.FlushInterval <= 0 {
+		return
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	q.cancel, q.done = cancel, make(chan struct{})
+	go q.run(ctx, q.done)
+}
+
+func (q *QuotaManager) run(ctx context.Context, done chan struct{}) {
+	defer close(done)
+	ticker := time.NewTicker(time.Duration(q.config.FlushInterval))
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		q.flush()
+	}
+}
+
+// flush writes usage to the usage file if it changed. Only the snapshot is
+// taken under q.mu, so saves don't wait on the disk.
+func (q *QuotaManager) flush() {
+	if q.config.UsageFile == "" {
+		return
+	}
+	q.flushing.Lock()
+	defer q.flushing.Unlock()
+
+	q.mu.Lock()
+	if !q.dirty {
+		q.mu.Unlock()
+		return
+	}
+	data, err := json.Marshal(q.usage)
+	q.dirty = false
+	q.mu.Unlock()
+
+	if err == nil {
+		err = storage.WriteFileAtomic(q.config.UsageFile, data)
+	}
+	if err != nil {
+		logging.Printf("Failed to persist quota usage: %v", err)
+		// Retried on the next flush
+		q.mu.Lock()
+		q.dirty = true
+		q.mu.Unlock()
+	}
+}
+
+// Shutdown stops flushing, then writes any usage changed since the last flush
+func (q *QuotaManager) Shutdown() {
+	if q.cancel != nil {
+		q.cancel()
+		<-q.done
+	}
+	q.flush()
+}
+
+// UsageReport pairs a scope's usage with its effective limits
+type UsageReport struct {
+	Usage  Usage              `json:"usage"`
+	Limits config.QuotaLimits `json:"limits"`
+}
+
+func (q *QuotaManager) Report() map[string]UsageReport {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	report := make(map[string]UsageReport, len(q.usage))
+	for scope, usage := range q.usage {
+		if strings.HasPrefix(scope, "owner/") {
+			continue
+		}
+		report[scope] = UsageReport{Usage: *usage, Limits: q.limitsFor(scope)}
+	}
+	return report
+}
+
+// OwnerUsage is what one key stores in one tenant
+type OwnerUsage struct {
+	Tenant string
+	Key    string
+	Usage  Usage
+}
+
+// OwnerUsage returns what each key stores in each tenant. Items saved
+// before owners were tracked aren't counted.
+func (q *QuotaManager) OwnerUsage() []OwnerUsage {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	var owners []OwnerUsage
+	for scope, usage := range q.usage {
+		owner, ok := strings.CutPrefix(scope, "owner/")
+		if !ok {
+			continue
+		}
+		tenant, key, _ := strings.Cut(owner, "/")
+		owners = append(owners, OwnerUsage{Tenant: tenant, Key: key, Usage: *usage})
+	}
+	return owners
+}
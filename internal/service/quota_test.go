@@ -0,0 +1,32 @@
+package service_test
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"interview-task/internal/config"
+	"interview-task/internal/service"
+)
+
+func TestQuotaUsageFlushedOnShutdown(t *testing.T) {
+	cfg := config.QuotaConfig{UsageFile: filepath.Join(t.TempDir(), "usage.json"), FlushInterval: config.Duration(time.Hour)}
+	q, err := service.NewQuotaManager(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	q.Start()
+	if err := q.Reserve("acme", "billing", 10, 1); err != nil {
+		t.Fatal(err)
+	}
+	q.Shutdown()
+
+	reloaded, err := service.NewQuotaManager(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := reloaded.Report()["tenant/acme"].Usage
+	if got != (service.Usage{Bytes: 10, Objects: 1}) {
+		t.Fatalf("reloaded usage is %+v, want 10 bytes in 1 object", got)
+	}
+}
@@ -0,0 +1,204 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"interview-task/internal/logging"
+	"interview-task/internal/storage"
+)
+
+// Reindex job states
+const (
+	ReindexIdle      = "idle"
+	ReindexRunning   = "running"
+	ReindexCompleted = "completed"
+	ReindexCancelled = "cancelled"
+)
+
+// ReindexStatus reports progress and doubles as the persisted checkpoint
+type ReindexStatus struct {
+	State        string    `json:"state"`
+	Tenant       string    `json:"tenant,omitempty"`
+	StorageType  string    `json:"storage_type,omitempty"`
+	RulesVersion string    `json:"rules_version,omitempty"`
+	Total        int       `json:"total"`
+	Processed    int       `json:"processed"`
+	Updated      int       `json:"updated"`
+	Failed       int       `json:"failed"`
+	Cursor       string    `json:"cursor,omitempty"`
+	StartedAt    time.Time `json:"started_at"`
+	FinishedAt   time.Time `json:"finished_at"`
+}
+
+// Reindexer - IMPLEMENTS a throttled, resumable background re-index job
+type Reindexer struct {
+	service   *DataService
+	rate      int
+	stateFile string
+
+	mu     sync.Mutex
+	status ReindexStatus
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// NewReindexer restores the last checkpoint from stateFile, if any, so an
+// interrupted job can be resumed after a restart
+func NewReindexer(service *DataService, ratePerSecond int, stateFile string) *Reindexer {
+	r := &Reindexer{
+		service:   service,
+		rate:      ratePerSecond,
+		stateFile: stateFile,
+		status:    ReindexStatus{State: ReindexIdle},
+	}
+
+	if data, err := os.ReadFile(stateFile); err == nil {
+		if err := json.Unmarshal(data, &r.status); err != nil {
+			logging.Printf("Ignoring corrupt reindex checkpoint: %v", err)
+			r.status = ReindexStatus{State: ReindexIdle}
+		}
+		// A job that was running when the process died is resumable
+		if r.status.State == ReindexRunning {
+			r.status.State = ReindexCancelled
+		}
+	}
+	return r
+}
+
+// Start launches a job for the tenant's items in storageType. It resumes
+// from the checkpoint when the previous job for the same tenant, storage and
+// rules did not complete.
+func (r *Reindexer) Start(tenant, storageType string) (ReindexStatus, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.status.State == ReindexRunning {
+		return r.status, fmt.Errorf("reindex already running for %s/%s", r.status.Tenant, r.status.StorageType)
+	}
+	ctx, cancel := context.WithCancel(WithTenant(context.Background(), tenant))
+	ids, err := r.service.ListItems(ctx, storageType)
+	if err != nil {
+		cancel()
+		return r.status, err
+	}
+
+	version := r.service.extractor.Version()
+	resume := r.status.Tenant == tenant &&
+		r.status.StorageType == storageType &&
+		r.status.RulesVersion == version &&
+		r.status.State == ReindexCancelled
+	if !resume {
+		r.status = ReindexStatus{
+			Tenant:       tenant,
+			StorageType:  storageType,
+			RulesVersion: version,
+			StartedAt:    time.Now().UTC(),
+		}
+	}
+	r.status.State = ReindexRunning
+	r.status.Total = len(ids)
+	r.status.FinishedAt = time.Time{}
+
+	// Skip everything up to and including the checkpoint
+	start := sort.SearchStrings(ids, r.status.Cursor)
+	if start < len(ids) && ids[start] == r.status.Cursor {
+		start++
+	}
+
+	r.cancel = cancel
+	r.done = make(chan struct{})
+	go r.run(ctx, r.done, storageType, ids[start:])
+
+	r.checkpoint()
+	return r.status, nil
+}
+
+func (r *Reindexer) run(ctx context.Context, done chan struct{}, storageType string, ids []string) {
+	defer close(done)
+
+	var throttle <-chan time.Time
+	if r.rate > 0 {
+		ticker := time.NewTicker(time.Second / time.Duration(r.rate))
+		defer ticker.Stop()
+		throttle = ticker.C
+	}
+
+	for i, id := range ids {
+		if throttle != nil {
+			select {
+			case <-ctx.Done():
+			case <-throttle:
+			}
+		}
+		if ctx.Err() != nil {
+			r.finish(ReindexCancelled)
+			return
+		}
+
+		updated, err := r.service.ReindexItem(ctx, storageType, id)
+
+		r.mu.Lock()
+		r.status.Processed++
+		r.status.Cursor = id
+		switch {
+		case err != nil && !errors.Is(err, storage.ErrNotFound):
+			// Items deleted since listing are not failures
+			r.status.Failed++
+			logging.Printf("Failed to reindex %s: %v", id, err)
+		case updated:
+			r.status.Updated++
+		}
+		if i%100 == 99 {
+			r.checkpoint()
+		}
+		r.mu.Unlock()
+	}
+	r.finish(ReindexCompleted)
+}
+
+func (r *Reindexer) finish(state string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.status.State = state
+	r.status.FinishedAt = time.Now().UTC()
+	r.checkpoint()
+}
+
+// checkpoint persists the status; callers must hold r.mu
+func (r *Reindexer) checkpoint() {
+	if r.stateFile == "" {
+		return
+	}
+	data, err := json.Marshal(r.status)
+	if err == nil {
+		err = storage.WriteFileAtomic(r.stateFile, data)
+	}
+	if err != nil {
+		logging.Printf("Failed to write reindex checkpoint: %v", err)
+	}
+}
+
+func (r *Reindexer) Status() ReindexStatus {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.status
+}
+
+// Cancel stops a running job and waits for its checkpoint to be written
+func (r *Reindexer) Cancel() {
+	r.mu.Lock()
+	cancel, done := r.cancel, r.done
+	r.mu.Unlock()
+
+	if cancel != nil {
+		cancel()
+		<-done
+	}
+}
@@ -0,0 +1,234 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"interview-task/internal/config"
+	"interview-task/internal/logging"
+	"interview-task/internal/storage"
+)
+
+// Reshard job states
+const (
+	ReshardIdle        = "idle"
+	ReshardRunning     = "running"
+	ReshardCompleted   = "completed"
+	ReshardAborting    = "aborting"
+	ReshardAborted     = "aborted"
+	ReshardInterrupted = "interrupted"
+)
+
+// ReshardStatus reports a migration's progress
+type ReshardStatus struct {
+	State      string                 `json:"state"`
+	From       config.ShardMapConfig  `json:"from"`
+	To         *config.ShardMapConfig `json:"to,omitempty"`
+	Scanned    int                    `json:"scanned"`
+	Moved      int                    `json:"moved"`
+	Failed     int                    `json:"failed"`
+	StartedAt  time.Time              `json:"started_at"`
+	FinishedAt time.Time              `json:"finished_at"`
+}
+
+// Resharder - IMPLEMENTS online resharding. Items are moved to their shard
+// under the new mapping while ShardedStorage reads from both locations;
+// the new mapping becomes current once every item has moved.
+type Resharder struct {
+	factory *storage.ConcreteStorageFactory
+	router  *storage.ShardRouter
+
+	mu       sync.Mutex
+	status   ReshardStatus
+	cancel   context.CancelFunc
+	done     chan struct{}
+	aborting bool
+}
+
+func NewResharder(factory *storage.ConcreteStorageFactory, router *storage.ShardRouter) *Resharder {
+	state := router.State()
+	status := ReshardStatus{State: ReshardIdle, From: state.Current}
+	if state.Next != nil {
+		// The process stopped mid-migration; dual reads stay on until the
+		// migration is resumed or aborted
+		status.State = ReshardInterrupted
+		status.To = state.Next
+	}
+	return &Resharder{factory: factory, router: router, status: status}
+}
+
+// Start migrates every tenant's items to the given mapping. Starting again
+// with the target of an interrupted migration resumes it.
+func (r *Resharder) Start(config config.ShardMapConfig) (ReshardStatus, error) {
+	mapper, err := storage.NewShardMapper(config)
+	if err != nil {
+		return ReshardStatus{}, fmt.Errorf("%w: %w", ErrValidation, err)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.cancel != nil {
+		return r.status, fmt.Errorf("%w: resharding is already %s", storage.ErrConflict, r.status.State)
+	}
+	if err := r.router.Begin(config, mapper); err != nil {
+		return r.status, fmt.Errorf("%w: %w", storage.ErrConflict, err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	r.status = ReshardStatus{
+		State:     ReshardRunning,
+		From:      r.router.State().Current,
+		To:        &config,
+		StartedAt: time.Now().UTC(),
+	}
+	r.cancel = cancel
+	r.done = make(chan struct{})
+	r.aborting = false
+	go r.run(ctx, r.done)
+	return r.status, nil
+}
+
+func (r *Resharder) run(ctx context.Context, done chan struct{}) {
+	defer close(done)
+
+	err := r.migrate(ctx, false)
+	r.mu.Lock()
+	aborting := r.aborting
+	r.mu.Unlock()
+
+	switch {
+	case err == nil:
+		if err := r.router.Cutover(); err != nil {
+			logging.Printf("Resharding cutover failed: %v", err)
+		}
+		r.finish(ReshardCompleted)
+	case aborting:
+		r.rollback()
+	default:
+		// Shut down or failed; the persisted state keeps dual reads on
+		logging.Printf("Resharding stopped: %v", err)
+		r.finish(ReshardInterrupted)
+	}
+}
+
+// rollback moves every item back to the current mapping before dropping the
+// new one. It runs to completion even during shutdown.
+func (r *Resharder) rollback() {
+	r.router.BeginRollback()
+	if err := r.migrate(context.Background(), true); err != nil {
+		logging.Printf("Resharding rollback failed: %v", err)
+		r.finish(ReshardInterrupted)
+		return
+	}
+	if err := r.router.Rollback(); err != nil {
+		logging.Printf("Resharding rollback failed: %v", err)
+	}
+	r.finish(ReshardAborted)
+}
+
+// migrate moves every key that isn't on its shard under the target mapping:
+// the new one, or the current one when rolling back
+func (r *Resharder) migrate(ctx context.Context, rollback bool) error {
+	target := r.router.Target(rollback)
+
+	for _, storageType := range storage.StorageTypes() {
+		tenants, err := r.factory.Tenants(storageType)
+		if err != nil {
+			return err
+		}
+		for _, tenant := range tenants {
+			sharded, err := r.factory.Sharded(tenant, storageType)
+			if err != nil {
+				return err
+			}
+			for _, shard := range r.router.Shards() {
+				keys, err := sharded.Open(shard).List()
+				if err != nil {
+					return err
+				}
+				for _, key := range keys {
+					if err := ctx.Err(); err != nil {
+						return err
+					}
+					r.migrateKey(sharded, key, shard, target.Shard(storage.ShardKey(key)))
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func (r *Resharder) migrateKey(storage *storage.ShardedStorage, key, from, to string) {
+	var err error
+	if from != to {
+		err = storage.Move(key, from, to)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.status.Scanned++
+	switch {
+	case err != nil:
+		r.status.Failed++
+		logging.Printf("Failed to move %s from shard %q to %q: %v", key, from, to, err)
+	case from != to:
+		r.status.Moved++
+	}
+}
+
+func (r *Resharder) finish(state string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.status.State = state
+	r.status.FinishedAt = time.Now().UTC()
+	r.cancel = nil
+}
+
+func (r *Resharder) Status() ReshardStatus {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.status
+}
+
+// Abort stops a running migration and moves migrated items back. An
+// interrupted migration is rolled back as well.
+func (r *Resharder) Abort() (ReshardStatus, error) {
+	r.mu.Lock()
+	if r.cancel == nil {
+		defer r.mu.Unlock()
+		if r.status.State != ReshardInterrupted {
+			return r.status, fmt.Errorf("%w: no migration to abort", storage.ErrConflict)
+		}
+		// Nothing is running, so start the rollback directly
+		done := make(chan struct{})
+		r.cancel, r.done = func() {}, done
+		r.status.State = ReshardAborting
+		go func() {
+			defer close(done)
+			r.rollback()
+		}()
+		return r.status, nil
+	}
+	r.aborting = true
+	r.status.State = ReshardAborting
+	cancel := r.cancel
+	status := r.status
+	r.mu.Unlock()
+
+	cancel()
+	return status, nil
+}
+
+// Shutdown stops a running migration without rolling it back, so it can be
+// resumed after a restart
+func (r *Resharder) Shutdown() {
+	r.mu.Lock()
+	cancel, done := r.cancel, r.done
+	r.mu.Unlock()
+	if cancel != nil {
+		cancel()
+		<-done
+	}
+}
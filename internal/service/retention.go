@@ -0,0 +1,466 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"interview-task/internal/config"
+	"interview-task/internal/logging"
+	"interview-task/internal/metrics"
+	"interview-task/internal/storage"
+)
+
+// AuditActionRetention records items deleted or archived by retention
+// rules; the event's detail names the rule and why it applied
+const AuditActionRetention = "retention"
+
+// Retention rule actions
+const (
+	RetentionDelete  = "delete"
+	RetentionArchive = "archive"
+)
+
+// Retention run states
+const (
+	RetentionIdle      = "idle"
+	RetentionRunning   = "running"
+	RetentionCompleted = "completed"
+	RetentionCancelled = "cancelled"
+	RetentionFailed    = "failed"
+)
+
+// What a retention run did about an item past its rule
+const (
+	RetentionActionDeleted      = "deleted"
+	RetentionActionArchived     = "archived"
+	RetentionActionWouldDelete  = "would_delete"
+	RetentionActionWouldArchive = "would_archive"
+	RetentionActionFailed       = "failed"
+	// RetentionActionHeld is an item kept by a legal hold
+	RetentionActionHeld = "held"
+)
+
+// maxRetentionFindings bounds the findings a report lists
+const maxRetentionFindings = 1000
+
+// retentionPrincipal is the actor recorded for retention deletions
+var retentionPrincipal = &Principal{Name: "retention"}
+
+// RetentionFinding is an item kept longer than its rule allows
+type RetentionFinding struct {
+	StorageType string `json:"storage_type"`
+	Tenant      string `json:"tenant"`
+	ID          string `json:"id"`
+	Rule        string `json:"rule"`
+	Action      string `json:"action"`
+	Reason      string `json:"reason"`
+	Error       string `json:"error,omitempty"`
+}
+
+// RetentionReport reports a retention run's progress. Expired counts the
+// items past their rule, whether or not they were removed.
+type RetentionReport struct {
+	State      string             `json:"state"`
+	DryRun     bool               `json:"dry_run"`
+	Scanned    int                `json:"scanned"`
+	Expired    int                `json:"expired"`
+	Deleted    int                `json:"deleted"`
+	Archived   int                `json:"archived"`
+	Held       int                `json:"held"`
+	Failed     int                `json:"failed"`
+	Findings   []RetentionFinding `json:"findings,omitempty"`
+	Error      string             `json:"error,omitempty"`
+	StartedAt  time.Time          `json:"started_at"`
+	FinishedAt time.Time          `json:"finished_at"`
+}
+
+// retentionRule is a validated rule
+type retentionRule struct {
+	config.RetentionRuleConfig
+	maxAge time.Duration
+}
+
+// matches reports whether the rule selects an item
+func (r *retentionRule) matches(tenant, storageType string, record *ItemMetadata) bool {
+	if r.Tenant != "" && r.Tenant != tenant || r.StorageType != "" && r.StorageType != storageType {
+		return false
+	}
+	for key, value := range r.Tags {
+		if label, ok := record.Labels[key]; ok && label == value {
+			continue
+		}
+		if tag, ok := record.Tags[key]; !ok || tag != value {
+			return false
+		}
+	}
+	return true
+}
+
+// RetentionEnforcer - IMPLEMENTS the job deleting or archiving items kept
+// longer than their retention rules allow. The first rule matching an item
+// decides; items no rule matches are kept. Each run reads the metadata of
+// every item of every tenant; items without metadata are kept, as their
+// age is unknown. Every removal is audited with the rule that caused it.
+type RetentionEnforcer struct {
+	service *DataService
+	factory storage.TenantLister
+	config  config.RetentionConfig
+	rules   []retentionRule
+	metrics *metrics.Metrics
+	leader  *LeaderElector
+
+	mu     sync.Mutex
+	report RetentionReport
+	cancel context.CancelFunc
+	done   chan struct{}
+
+	stop    context.CancelFunc
+	stopped chan struct{}
+}
+
+func NewRetentionEnforcer(service *DataService, factory storage.TenantLister, config config.RetentionConfig, metrics *metrics.Metrics, leader *LeaderElector) (*RetentionEnforcer, error) {
+	rules := make([]retentionRule, 0, len(config.Rules))
+	seen := make(map[string]bool, len(config.Rules))
+	for i, rule := range config.Rules {
+		if rule.Name == "" {
+			return nil, fmt.Errorf("retention rule %d has no name", i)
+		}
+		if seen[rule.Name] {
+			return nil, fmt.Errorf("duplicate retention rule: %q", rule.Name)
+		}
+		seen[rule.Name] = true
+		if rule.MaxAge <= 0 {
+			return nil, fmt.Errorf("retention rule %s: max_age must be positive", rule.Name)
+		}
+		if rule.Tenant != "" {
+			if err := ValidateTenant(rule.Tenant); err != nil {
+				return nil, fmt.Errorf("retention rule %s: %w", rule.Name, err)
+			}
+		}
+		if rule.StorageType != "" && !slices.Contains(storage.StorageTypes(), rule.StorageType) {
+			return nil, fmt.Errorf("retention rule %s: unknown storage type %q", rule.Name, rule.StorageType)
+		}
+		switch rule.Action {
+		case "":
+			rule.Action = RetentionDelete
+		case RetentionDelete:
+		case RetentionArchive:
+			if !slices.Contains(storage.StorageTypes(), rule.ArchiveStorageType) {
+				return nil, fmt.Errorf("retention rule %s: unknown archive storage type %q", rule.Name, rule.ArchiveStorageType)
+			}
+		default:
+			return nil, fmt.Errorf("retention rule %s: unsupported action %q", rule.Name, rule.Action)
+		}
+		rules = append(rules, retentionRule{RetentionRuleConfig: rule, maxAge: time.Duration(rule.MaxAge)})
+	}
+	metrics.Describe("retention_findings_total", "Items past their retention rule by rule and what was done about them")
+	return &RetentionEnforcer{
+		service: service,
+		factory: factory,
+		config:  config,
+		rules:   rules,
+		metrics: metrics,
+		leader:  leader,
+		report:  RetentionReport{State: RetentionIdle},
+	}, nil
+}
+
+// Start enforces the rules every interval until Shutdown
+func (e *RetentionEnforcer) Start() {
+	if len(e.rules) == 0 || e.config.Interval <= 0 {
+		return
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	e.stop, e.stopped = cancel, make(chan struct{})
+	go e.schedule(ctx, e.stopped)
+}
+
+func (e *RetentionEnforcer) schedule(ctx context.Context, done chan struct{}) {
+	defer close(done)
+	ticker := time.NewTicker(time.Duration(e.config.Interval))
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		// Only the elected instance runs scheduled jobs
+		if !e.leader.IsLeader() || e.service.maintenance.Enabled() {
+			continue
+		}
+		if _, err := e.Enforce(e.config.DryRun); err != nil && !errors.Is(err, storage.ErrConflict) {
+			logging.Printf("Retention run failed to start: %v", err)
+		}
+	}
+}
+
+// Enforce starts a retention run in the background. A dry run reports what
+// it would remove without removing anything.
+func (e *RetentionEnforcer) Enforce(dryRun bool) (RetentionReport, error) {
+	if !dryRun {
+		if err := e.service.maintenance.check(); err != nil {
+			return RetentionReport{}, err
+		}
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.cancel != nil {
+		return e.report, fmt.Errorf("%w: a retention run is already in progress", storage.ErrConflict)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	e.report = RetentionReport{State: RetentionRunning, DryRun: dryRun, StartedAt: time.Now().UTC()}
+	e.cancel = cancel
+	e.done = make(chan struct{})
+	go e.run(ctx, dryRun, e.done)
+	return e.report, nil
+}
+
+func (e *RetentionEnforcer) run(ctx context.Context, dryRun bool, done chan struct{}) {
+	defer close(done)
+	err := e.enforce(WithPrincipal(ctx, retentionPrincipal), dryRun)
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	switch {
+	case err == nil:
+		e.report.State = RetentionCompleted
+		logging.Printf("Retention run found %d items past their rules, deleted %d and archived %d",
+			e.report.Expired, e.report.Deleted, e.report.Archived)
+	case errors.Is(err, context.Canceled):
+		e.report.State = RetentionCancelled
+	default:
+		logging.Printf("Retention run failed: %v", err)
+		e.report.State = RetentionFailed
+		e.report.Error = err.Error()
+	}
+	e.report.FinishedAt = time.Now().UTC()
+	e.cancel = nil
+}
+
+// enforce goes through every tenant's items; cold tiers are covered by the
+// storage type whose items they hold
+func (e *RetentionEnforcer) enforce(ctx context.Context, dryRun bool) error {
+	cold := make(map[string]bool)
+	for _, storageType := range storage.StorageTypes() {
+		if tier := storage.ColdTier(e.factory, storageType); tier != "" {
+			cold[tier] = true
+		}
+	}
+	for _, storageType := range storage.StorageTypes() {
+		if cold[storageType] {
+			continue
+		}
+		tenants, err := e.factory.Tenants(storageType)
+		if err != nil {
+			return err
+		}
+		for _, tenant := range tenants {
+			if err := e.enforceTenant(WithTenant(ctx, tenant), tenant, storageType, dryRun); err != nil {
+				return fmt.Errorf("failed to enforce retention on %s of tenant %s: %w", storageType, tenant, err)
+			}
+		}
+	}
+	return nil
+}
+
+func (e *RetentionEnforcer) enforceTenant(ctx context.Context, tenant, storageType string, dryRun bool) error {
+	ids, err := e.service.ListItems(ctx, storageType)
+	if err != nil {
+		return err
+	}
+	store, err := e.factory.CreateStorage(tenant, storageType)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	for _, id := range ids {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		e.mu.Lock()
+		e.report.Scanned++
+		e.mu.Unlock()
+
+		// Soft-deleted items are left to the trash purger
+		record, err := e.service.loadMetadata(store, id)
+		if err != nil || !record.DeletedAt.IsZero() {
+			continue
+		}
+		rule := e.rule(tenant, storageType, record)
+		// Items already in their rule's archive stay there
+		if rule == nil || rule.Action == RetentionArchive && rule.ArchiveStorageType == storageType {
+			continue
+		}
+		saved := e.service.firstSaved(store, id, record)
+		if now.Sub(saved) < rule.maxAge {
+			continue
+		}
+
+		finding := RetentionFinding{
+			StorageType: storageType,
+			Tenant:      tenant,
+			ID:          id,
+			Rule:        rule.Name,
+			Reason:      fmt.Sprintf("saved %s, kept longer than %s", saved.UTC().Format(time.RFC3339), rule.maxAge),
+		}
+		if e.service.legalHold(ctx, record) != nil {
+			e.record(finding, RetentionActionHeld, nil)
+			continue
+		}
+		if dryRun {
+			action := RetentionActionWouldDelete
+			if rule.Action == RetentionArchive {
+				action = RetentionActionWouldArchive
+			}
+			e.record(finding, action, nil)
+			continue
+		}
+
+		ctx := withAuditDetail(ctx, "rule "+rule.Name+": "+finding.Reason)
+		action := RetentionActionDeleted
+		if rule.Action == RetentionArchive {
+			action = RetentionActionArchived
+			err = e.service.archiveItem(ctx, storageType, rule.ArchiveStorageType, id)
+		} else {
+			err = e.service.deleteItem(ctx, AuditActionRetention, storageType, id)
+		}
+		switch {
+		case errors.Is(err, storage.ErrNotFound):
+			// Deleted since it was listed
+		case err != nil:
+			e.record(finding, RetentionActionFailed, err)
+		default:
+			e.record(finding, action, nil)
+		}
+	}
+	return nil
+}
+
+// rule returns the first rule matching an item, or nil
+func (e *RetentionEnforcer) rule(tenant, storageType string, record *ItemMetadata) *retentionRule {
+	for i := range e.rules {
+		if e.rules[i].matches(tenant, storageType, record) {
+			return &e.rules[i]
+		}
+	}
+	return nil
+}
+
+func (e *RetentionEnforcer) record(finding RetentionFinding, action string, err error) {
+	finding.Action = action
+	if err != nil {
+		finding.Error = err.Error()
+		logging.Printf("Retention failed to remove %s of tenant %s from %s: %v", finding.ID, finding.Tenant, finding.StorageType, err)
+	}
+	e.metrics.Add("retention_findings_total", 1, "rule", finding.Rule, "action", action)
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.report.Expired++
+	switch action {
+	case RetentionActionDeleted:
+		e.report.Deleted++
+	case RetentionActionArchived:
+		e.report.Archived++
+	case RetentionActionHeld:
+		e.report.Held++
+	case RetentionActionFailed:
+		e.report.Failed++
+	}
+	if len(e.report.Findings) < maxRetentionFindings {
+		e.report.Findings = append(e.report.Findings, finding)
+	}
+}
+
+// Report returns the running or last run's report
+func (e *RetentionEnforcer) Report() RetentionReport {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	report := e.report
+	report.Findings = slices.Clone(report.Findings)
+	return report
+}
+
+// Cancel stops a running retention run; what it removed stays removed
+func (e *RetentionEnforcer) Cancel() (RetentionReport, error) {
+	e.mu.Lock()
+	cancel, done := e.cancel, e.done
+	e.mu.Unlock()
+	if cancel == nil {
+		return e.Report(), fmt.Errorf("%w: no retention run is in progress", storage.ErrConflict)
+	}
+	cancel()
+	<-done
+	return e.Report(), nil
+}
+
+// Shutdown stops scheduling and cancels a running retention run
+func (e *RetentionEnforcer) Shutdown() {
+	if e.stop != nil {
+		e.stop()
+		<-e.stopped
+	}
+	e.mu.Lock()
+	cancel, done := e.cancel, e.done
+	e.mu.Unlock()
+	if cancel != nil {
+		cancel()
+		<-done
+	}
+}
+
+// firstSaved is when an item was first saved, from its version history or,
+// failing that, its metadata
+func (ds *DataService) firstSaved(store storage.StorageInterface, id string, record *ItemMetadata) time.Time {
+	if versions, err := ds.loadVersions(store, id); err == nil {
+		return versions[0].CreatedAt
+	}
+	if !record.ModifiedAt.IsZero() {
+		return record.ModifiedAt
+	}
+	return record.IndexedAt
+}
+
+// archiveItem copies an item's payload and metadata to the archive storage
+// type, then deletes it. The copy starts a version history of its own and
+// isn't charged to quotas.
+func (ds *DataService) archiveItem(ctx context.Context, storageType, archiveType, id string) error {
+	if err := ds.maintenance.check(); err != nil {
+		return err
+	}
+	ctx, unlock, err := ds.lockItem(ctx, storageType, id)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	source, err := ds.openStorage(ctx, storageType)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrValidation, err)
+	}
+	archive, err := ds.openStorage(ctx, archiveType)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrValidation, err)
+	}
+	data, err := source.Load(id)
+	if err != nil {
+		return fmt.Errorf("failed to load data: %w", err)
+	}
+	record, err := ds.loadMetadata(source, id)
+	if err != nil {
+		return err
+	}
+	if err := archive.Save(id, data); err != nil {
+		return fmt.Errorf("failed to archive data: %w", err)
+	}
+	if err := ds.saveMetadata(ctx, archive, archiveType, id, record); err != nil {
+		return err
+	}
+	return ds.deleteItem(withAuditDetail(ctx, auditDetailFromContext(ctx)+"; archived to "+archiveType), AuditActionRetention, storageType, id)
+}
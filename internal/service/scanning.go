@@ -0,0 +1,365 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"net"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"interview-task/internal/config"
+	"interview-task/internal/logging"
+	"interview-task/internal/metrics"
+	"interview-task/internal/storage"
+)
+
+// Labels recording the outcome of background scans. ScanLabel is
+// "pending" until the scan completes, then "clean", "infected" or "failed";
+// ScanThreatLabel names what an infected payload contains. Clients can't
+// set either.
+const (
+	ScanLabel       = "scan"
+	ScanThreatLabel = "scan.threat"
+)
+
+var (
+	// ErrInfected is returned for a payload the scanner found a threat in
+	ErrInfected = errors.New("payload is infected")
+	// ErrScannerUnavailable fails saves that couldn't be scanned
+	ErrScannerUnavailable = errors.New("scanner unavailable")
+)
+
+// scanEngine scans one payload, returning the name of the threat found in
+// it or "" when it is clean
+type scanEngine interface {
+	Scan(ctx context.Context, data []byte) (threat string, err error)
+}
+
+// clamdEngine streams payloads to clamd with the INSTREAM command
+type clamdEngine struct {
+	network string
+	address string
+}
+
+// clamdChunkSize is the most a payload chunk sent to clamd holds
+const clamdChunkSize = 64 << 10
+
+func (e *clamdEngine) Scan(ctx context.Context, data []byte) (string, error) {
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, e.network, e.address)
+	if err != nil {
+		return "", err
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString("zINSTREAM\x00")
+	for chunk := range slices.Chunk(data, clamdChunkSize) {
+		buf.Write(binary.BigEndian.AppendUint32(nil, uint32(len(chunk))))
+		buf.Write(chunk)
+	}
+	buf.Write(make([]byte, 4))
+	if _, err := conn.Write(buf.Bytes()); err != nil {
+		return "", err
+	}
+	reply, err := io.ReadAll(conn)
+	if err != nil {
+		return "", err
+	}
+
+	result := strings.TrimPrefix(strings.TrimRight(string(reply), "\x00\n"), "stream: ")
+	switch {
+	case result == "OK":
+		return "", nil
+	case strings.HasSuffix(result, " FOUND"):
+		return strings.TrimSuffix(result, " FOUND"), nil
+	default:
+		return "", fmt.Errorf("clamd: %s", result)
+	}
+}
+
+// httpEngine posts payloads to an external scanner, which answers 200 with
+// {"infected": bool, "threat": "name"}
+type httpEngine struct {
+	url    string
+	client *http.Client
+}
+
+func (e *httpEngine) Scan(ctx context.Context, data []byte) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.url, bytes.NewReader(data))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/octet-stream")
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("scanner returned %s", resp.Status)
+	}
+	var result struct {
+		Infected bool   `json:"infected"`
+		Threat   string `json:"threat"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return "", fmt.Errorf("invalid scanner response: %w", err)
+	}
+	if !result.Infected {
+		return "", nil
+	}
+	if result.Threat == "" {
+		return "unknown", nil
+	}
+	return result.Threat, nil
+}
+
+// scanJob is a stored payload waiting for a background scan
+type scanJob struct {
+	tenant      string
+	storageType string
+	id          string
+	data        []byte
+}
+
+// ContentScanner - IMPLEMENTS virus scanning as save hooks. Blocking mode
+// scans payloads before they are stored, so nothing is stored unscanned;
+// flag mode scans them in the background and labels each item with the
+// outcome, for clients to act on.
+type ContentScanner struct {
+	mode    string
+	engine  scanEngine
+	timeout time.Duration
+	metrics *metrics.Metrics
+	service *DataService
+
+	queue  chan scanJob
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// NewContentScanner returns nil when scanning is disabled
+func NewContentScanner(config config.ScanningConfig, metrics *metrics.Metrics) (*ContentScanner, error) {
+	switch config.Mode {
+	case "":
+		return nil, nil
+	case "block", "flag":
+	default:
+		return nil, fmt.Errorf("unsupported scanning mode: %s", config.Mode)
+	}
+	s := &ContentScanner{
+		mode:    config.Mode,
+		timeout: time.Duration(config.Timeout),
+		metrics: metrics,
+		queue:   make(chan scanJob, max(config.QueueSize, 1)),
+	}
+	switch config.Engine {
+	case "clamav":
+		if config.Address == "" {
+			return nil, errors.New("address is required for clamav")
+		}
+		if path, ok := strings.CutPrefix(config.Address, "unix:"); ok {
+			s.engine = &clamdEngine{network: "unix", address: path}
+		} else {
+			s.engine = &clamdEngine{network: "tcp", address: config.Address}
+		}
+	case "http":
+		if config.URL == "" {
+			return nil, errors.New("url is required for http")
+		}
+		s.engine = &httpEngine{url: config.URL, client: &http.Client{}}
+	default:
+		return nil, fmt.Errorf("unsupported scanning engine: %q", config.Engine)
+	}
+	metrics.Describe("content_scans_total", "Payloads scanned by outcome")
+	return s, nil
+}
+
+// Attach gives flag mode the service whose items it labels
+func (s *ContentScanner) Attach(service *DataService) {
+	if s != nil {
+		s.service = service
+	}
+}
+
+// Hooks scans every save and update
+func (s *ContentScanner) Hooks() Hooks {
+	if s == nil {
+		return Hooks{}
+	}
+	if s.mode == "block" {
+		return Hooks{BeforeSave: []BeforeSaveHook{s.scanBeforeSave}}
+	}
+	return Hooks{BeforeSave: []BeforeSaveHook{s.stripLabels}, AfterSave: []AfterSaveHook{s.scanAfterSave}}
+}
+
+// scan runs one scan within the timeout
+func (s *ContentScanner) scan(ctx context.Context, data []byte) (string, error) {
+	if s.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.timeout)
+		defer cancel()
+	}
+	threat, err := s.engine.Scan(ctx, data)
+	outcome := "clean"
+	switch {
+	case err != nil:
+		outcome = "failed"
+	case threat != "":
+		outcome = "infected"
+	}
+	s.metrics.Add("content_scans_total", 1, "outcome", outcome)
+	return threat, err
+}
+
+func (s *ContentScanner) scanBeforeSave(ctx context.Context, action, id string, req *SaveRequest) error {
+	if err := s.stripLabels(ctx, action, id, req); err != nil {
+		return err
+	}
+	threat, err := s.scan(ctx, req.Data)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrScannerUnavailable, err)
+	}
+	if threat != "" {
+		return fmt.Errorf("%w: %s", ErrInfected, threat)
+	}
+	return nil
+}
+
+// stripLabels drops scan labels sent by the client, which would pass the
+// item off as scanned
+func (s *ContentScanner) stripLabels(ctx context.Context, action, id string, req *SaveRequest) error {
+	_, scan := req.Labels[ScanLabel]
+	_, threat := req.Labels[ScanThreatLabel]
+	if scan || threat {
+		req.Labels = maps.Clone(req.Labels)
+		delete(req.Labels, ScanLabel)
+		delete(req.Labels, ScanThreatLabel)
+	}
+	return nil
+}
+
+// scanAfterSave marks a stored item pending and queues its scan. With the
+// queue full the payload is scanned before the save returns.
+func (s *ContentScanner) scanAfterSave(ctx context.Context, action, id string, req *SaveRequest) {
+	job := scanJob{tenant: TenantFromContext(ctx), storageType: req.StorageType, id: id, data: req.Data}
+	s.label(ctx, job, map[string]string{ScanLabel: "pending", ScanThreatLabel: ""})
+	select {
+	case s.queue <- job:
+	default:
+		s.process(ctx, job)
+	}
+}
+
+// process scans a queued payload and labels its item with the outcome
+func (s *ContentScanner) process(ctx context.Context, job scanJob) {
+	labels := map[string]string{ScanLabel: "clean", ScanThreatLabel: ""}
+	threat, err := s.scan(ctx, job.data)
+	switch {
+	case err != nil && ctx.Err() != nil:
+		// Shutting down; the item stays pending
+		return
+	case err != nil:
+		logging.Printf("Failed to scan %s of tenant %s: %v", job.id, job.tenant, err)
+		labels[ScanLabel] = "failed"
+	case threat != "":
+		logging.Printf("Threat %s found in %s of tenant %s", threat, job.id, job.tenant)
+		labels[ScanLabel], labels[ScanThreatLabel] = "infected", truncateLabel(threat)
+	}
+	s.label(ctx, job, labels)
+}
+
+func (s *ContentScanner) label(ctx context.Context, job scanJob, labels map[string]string) {
+	ctx = WithTenant(context.WithoutCancel(ctx), job.tenant)
+	if err := s.service.labelScanned(ctx, job.storageType, job.id, storage.PayloadSHA256(job.data), labels); err != nil {
+		logging.Printf("Failed to label scan of %s of tenant %s: %v", job.id, job.tenant, err)
+	}
+}
+
+func truncateLabel(value string) string {
+	if len(value) > MaxLabelValueSize {
+		return value[:MaxLabelValueSize]
+	}
+	return value
+}
+
+// Start scans queued payloads in the background until Shutdown
+func (s *ContentScanner) Start() {
+	if s == nil || s.mode != "flag" {
+		return
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	s.cancel, s.done = cancel, make(chan struct{})
+	go s.run(ctx, s.done)
+}
+
+func (s *ContentScanner) run(ctx context.Context, done chan struct{}) {
+	defer close(done)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case job := <-s.queue:
+			s.process(ctx, job)
+		}
+	}
+}
+
+// Shutdown stops scanning; items still queued keep their pending label
+func (s *ContentScanner) Shutdown() {
+	if s == nil || s.cancel == nil {
+		return
+	}
+	s.cancel()
+	<-s.done
+}
+
+// labelScanned sets an item's scan labels, unless its payload changed since
+// the one scanned, whose own scan labels it then; empty values remove a
+// label
+func (ds *DataService) labelScanned(ctx context.Context, storageType, id, sha256 string, labels map[string]string) error {
+	ctx, unlock, err := ds.lockItem(ctx, storageType, id)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	store, err := ds.factory.CreateStorage(TenantFromContext(ctx), storageType)
+	if err != nil {
+		return err
+	}
+	record, err := ds.loadMetadata(store, id)
+	if errors.Is(err, storage.ErrNotFound) {
+		// Deleted since
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if record.SHA256 != sha256 {
+		return nil
+	}
+	record.Labels = maps.Clone(record.Labels)
+	if record.Labels == nil {
+		record.Labels = make(map[string]string)
+	}
+	for key, value := range labels {
+		if value == "" {
+			delete(record.Labels, key)
+		} else {
+			record.Labels[key] = value
+		}
+	}
+	return ds.saveMetadata(ctx, store, storageType, id, record)
+}
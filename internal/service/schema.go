@@ -0,0 +1,320 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"interview-task/internal/config"
+)
+
+// FieldError describes one schema violation
+type FieldError struct {
+	Path    string `json:"path"`
+	Message string `json:"message"`
+}
+
+// SchemaValidationError carries every violation found in a payload
+type SchemaValidationError struct {
+	Fields []FieldError
+}
+
+func (e *SchemaValidationError) Error() string {
+	messages := make([]string, len(e.Fields))
+	for i, field := range e.Fields {
+		messages[i] = field.Path + ": " + field.Message
+	}
+	return "payload does not match schema: " + strings.Join(messages, "; ")
+}
+
+// JSONSchema implements the commonly used subset of JSON Schema: type,
+// properties, required, additionalProperties, items, enum, const, numeric
+// and length bounds, and pattern
+type JSONSchema struct {
+	Type                 schemaTypes            `json:"type"`
+	Properties           map[string]*JSONSchema `json:"properties"`
+	Required             []string               `json:"required"`
+	AdditionalProperties *additionalProperties  `json:"additionalProperties"`
+	Items                *JSONSchema            `json:"items"`
+	Enum                 []any                  `json:"enum"`
+	Const                json.RawMessage        `json:"const"`
+	Minimum              *float64               `json:"minimum"`
+	Maximum              *float64               `json:"maximum"`
+	ExclusiveMinimum     *float64               `json:"exclusiveMinimum"`
+	ExclusiveMaximum     *float64               `json:"exclusiveMaximum"`
+	MinLength            *int                   `json:"minLength"`
+	MaxLength            *int                   `json:"maxLength"`
+	Pattern              string                 `json:"pattern"`
+	MinItems             *int                   `json:"minItems"`
+	MaxItems             *int                   `json:"maxItems"`
+
+	pattern *regexp.Regexp
+}
+
+// schemaTypes accepts "type" as either a string or a list of strings
+type schemaTypes []string
+
+func (t *schemaTypes) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*t = schemaTypes{single}
+		return nil
+	}
+	var list []string
+	if err := json.Unmarshal(data, &list); err != nil {
+		return fmt.Errorf("type must be a string or list of strings")
+	}
+	*t = list
+	return nil
+}
+
+// additionalProperties accepts either a boolean or a schema
+type additionalProperties struct {
+	allowed bool
+	schema  *JSONSchema
+}
+
+func (a *additionalProperties) UnmarshalJSON(data []byte) error {
+	if err := json.Unmarshal(data, &a.allowed); err == nil {
+		return nil
+	}
+	a.allowed = true
+	return json.Unmarshal(data, &a.schema)
+}
+
+// ParseJSONSchema parses a schema document and compiles its patterns
+func ParseJSONSchema(data []byte) (*JSONSchema, error) {
+	var schema JSONSchema
+	if err := json.Unmarshal(data, &schema); err != nil {
+		return nil, fmt.Errorf("invalid schema: %w", err)
+	}
+	if err := schema.compile(); err != nil {
+		return nil, err
+	}
+	return &schema, nil
+}
+
+func (s *JSONSchema) compile() error {
+	if s.Pattern != "" {
+		pattern, err := regexp.Compile(s.Pattern)
+		if err != nil {
+			return fmt.Errorf("invalid schema pattern %q: %w", s.Pattern, err)
+		}
+		s.pattern = pattern
+	}
+	for _, property := range s.Properties {
+		if err := property.compile(); err != nil {
+			return err
+		}
+	}
+	if s.Items != nil {
+		if err := s.Items.compile(); err != nil {
+			return err
+		}
+	}
+	if s.AdditionalProperties != nil && s.AdditionalProperties.schema != nil {
+		return s.AdditionalProperties.schema.compile()
+	}
+	return nil
+}
+
+// Validate checks a JSON payload and returns a SchemaValidationError listing
+// every violation
+func (s *JSONSchema) Validate(payload []byte) error {
+	var doc any
+	if err := json.Unmarshal(payload, &doc); err != nil {
+		return &SchemaValidationError{Fields: []FieldError{{Path: "$", Message: "payload is not valid JSON"}}}
+	}
+
+	var fields []FieldError
+	s.validate(doc, "$", &fields)
+	if len(fields) > 0 {
+		return &SchemaValidationError{Fields: fields}
+	}
+	return nil
+}
+
+func (s *JSONSchema) validate(value any, path string, errs *[]FieldError) {
+	fail := func(format string, args ...any) {
+		*errs = append(*errs, FieldError{Path: path, Message: fmt.Sprintf(format, args...)})
+	}
+
+	if len(s.Type) > 0 && !s.matchesType(value) {
+		fail("expected %s, got %s", strings.Join(s.Type, " or "), jsonTypeName(value))
+		return
+	}
+	if len(s.Enum) > 0 && !containsJSONValue(s.Enum, value) {
+		fail("must be one of the allowed values")
+	}
+	if len(s.Const) > 0 {
+		var expected any
+		json.Unmarshal(s.Const, &expected)
+		if !reflect.DeepEqual(expected, value) {
+			fail("must equal %s", string(s.Const))
+		}
+	}
+
+	switch v := value.(type) {
+	case float64:
+		s.validateNumber(v, fail)
+	case string:
+		length := utf8.RuneCountInString(v)
+		if s.MinLength != nil && length < *s.MinLength {
+			fail("must be at least %d characters", *s.MinLength)
+		}
+		if s.MaxLength != nil && length > *s.MaxLength {
+			fail("must be at most %d characters", *s.MaxLength)
+		}
+		if s.pattern != nil && !s.pattern.MatchString(v) {
+			fail("must match pattern %s", s.Pattern)
+		}
+	case []any:
+		if s.MinItems != nil && len(v) < *s.MinItems {
+			fail("must have at least %d items", *s.MinItems)
+		}
+		if s.MaxItems != nil && len(v) > *s.MaxItems {
+			fail("must have at most %d items", *s.MaxItems)
+		}
+		if s.Items != nil {
+			for i, item := range v {
+				s.Items.validate(item, fmt.Sprintf("%s[%d]", path, i), errs)
+			}
+		}
+	case map[string]any:
+		s.validateObject(v, path, errs)
+	}
+}
+
+func (s *JSONSchema) validateNumber(v float64, fail func(string, ...any)) {
+	if s.Minimum != nil && v < *s.Minimum {
+		fail("must be >= %v", *s.Minimum)
+	}
+	if s.Maximum != nil && v > *s.Maximum {
+		fail("must be <= %v", *s.Maximum)
+	}
+	if s.ExclusiveMinimum != nil && v <= *s.ExclusiveMinimum {
+		fail("must be > %v", *s.ExclusiveMinimum)
+	}
+	if s.ExclusiveMaximum != nil && v >= *s.ExclusiveMaximum {
+		fail("must be < %v", *s.ExclusiveMaximum)
+	}
+}
+
+func (s *JSONSchema) validateObject(v map[string]any, path string, errs *[]FieldError) {
+	for _, name := range s.Required {
+		if _, ok := v[name]; !ok {
+			*errs = append(*errs, FieldError{Path: path + "." + name, Message: "is required"})
+		}
+	}
+
+	keys := make([]string, 0, len(v))
+	for key := range v {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		childPath := path + "." + key
+		if property, ok := s.Properties[key]; ok {
+			property.validate(v[key], childPath, errs)
+			continue
+		}
+		if s.AdditionalProperties == nil {
+			continue
+		}
+		if !s.AdditionalProperties.allowed {
+			*errs = append(*errs, FieldError{Path: childPath, Message: "is not allowed"})
+		} else if s.AdditionalProperties.schema != nil {
+			s.AdditionalProperties.schema.validate(v[key], childPath, errs)
+		}
+	}
+}
+
+func (s *JSONSchema) matchesType(value any) bool {
+	actual := jsonTypeName(value)
+	for _, expected := range s.Type {
+		if expected == actual {
+			return true
+		}
+		// Every integer is also a number
+		if expected == "number" && actual == "integer" {
+			return true
+		}
+	}
+	return false
+}
+
+func jsonTypeName(value any) string {
+	switch v := value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case float64:
+		if v == math.Trunc(v) {
+			return "integer"
+		}
+		return "number"
+	case string:
+		return "string"
+	case []any:
+		return "array"
+	case map[string]any:
+		return "object"
+	default:
+		return fmt.Sprintf("%T", v)
+	}
+}
+
+func containsJSONValue(values []any, value any) bool {
+	for _, candidate := range values {
+		if reflect.DeepEqual(candidate, value) {
+			return true
+		}
+	}
+	return false
+}
+
+// payloadSchema is a compiled PayloadSchemaConfig
+type payloadSchema struct {
+	storageType string
+	endpoint    string
+	schema      *JSONSchema
+}
+
+func loadPayloadSchemas(configs []config.PayloadSchemaConfig) ([]payloadSchema, error) {
+	schemas := make([]payloadSchema, 0, len(configs))
+	for _, config := range configs {
+		data := []byte(config.Schema)
+		if config.SchemaFile != "" {
+			var err error
+			if data, err = os.ReadFile(config.SchemaFile); err != nil {
+				return nil, fmt.Errorf("failed to read schema file: %w", err)
+			}
+		}
+		if len(data) == 0 {
+			return nil, fmt.Errorf("payload schema for %q has neither schema nor schema_file", config.StorageType)
+		}
+
+		schema, err := ParseJSONSchema(data)
+		if err != nil {
+			return nil, err
+		}
+		schemas = append(schemas, payloadSchema{
+			storageType: config.StorageType,
+			endpoint:    config.Endpoint,
+			schema:      schema,
+		})
+	}
+	return schemas, nil
+}
+
+func (p *payloadSchema) appliesTo(storageType, endpoint string) bool {
+	return (p.storageType == "" || p.storageType == storageType) &&
+		(p.endpoint == "" || p.endpoint == endpoint)
+}
@@ -0,0 +1,271 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"slices"
+	"strings"
+	"time"
+	"unicode"
+
+	"interview-task/internal/storage"
+)
+
+// Limits on searches
+const (
+	DefaultSearchLimit = 20
+	MaxSearchLimit     = 100
+	// maxSearchContent is the largest payload whose content is searched
+	maxSearchContent = 1 << 20
+	maxSearchTerms   = 16
+)
+
+// Weights of a term matched in each part of an item; the more specific the
+// part, the more relevant the match
+const (
+	searchWeightID      = 4
+	searchWeightField   = 3
+	searchWeightLabel   = 2
+	searchWeightSource  = 2
+	searchWeightType    = 1
+	searchWeightContent = 1
+)
+
+// SearchQuery selects and ranks items. Every term of Text must match the
+// item's ID, metadata fields, labels, source or content type, or with
+// Content its payload; Fields and Filter must match exactly.
+type SearchQuery struct {
+	Text string
+	// Fields are exact values of the fields extracted by the metadata rules
+	Fields map[string]string
+	Filter ItemFilter
+	// Content also searches the payloads of text and JSON items
+	Content bool
+	Limit   int
+	Offset  int
+}
+
+// SearchHit is a matching item and its relevance
+type SearchHit struct {
+	ItemSummary
+	Fields map[string]any `json:"fields,omitempty"`
+	Score  float64        `json:"score"`
+}
+
+// SearchResult is a page of hits, most relevant first
+type SearchResult struct {
+	Hits  []SearchHit `json:"hits"`
+	Total int         `json:"total"`
+	// NextOffset continues the search; zero on the last page
+	NextOffset int `json:"next_offset,omitempty"`
+}
+
+// searchCandidate is an item considered by a search
+type searchCandidate struct {
+	id       string
+	metadata *ItemMetadata
+}
+
+// search ranks the tenant's available items against the query, from the
+// metadata index when it is enabled
+func (ds *DataService) search(ctx context.Context, storageType string, query SearchQuery) (*SearchResult, error) {
+	terms := searchTerms(query.Text)
+	if len(terms) > maxSearchTerms {
+		return nil, fmt.Errorf("%w: at most %d search terms are allowed", ErrValidation, maxSearchTerms)
+	}
+	if query.Limit <= 0 || query.Limit > MaxSearchLimit {
+		return nil, fmt.Errorf("%w: limit must be between 1 and %d", ErrValidation, MaxSearchLimit)
+	}
+	if query.Offset < 0 {
+		return nil, fmt.Errorf("%w: offset must not be negative", ErrValidation)
+	}
+	store, err := ds.openStorage(ctx, storageType)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrValidation, err)
+	}
+	candidates, err := ds.searchCandidates(ctx, store, storageType)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	hits := []SearchHit{}
+	for _, candidate := range candidates {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		record := candidate.metadata
+		if record.unavailable(candidate.id, now) != nil || !query.Filter.Matches(record) || !matchesFields(query.Fields, record.Fields) {
+			continue
+		}
+		score, ok := ds.score(store, candidate.id, record, terms, query.Content)
+		if !ok {
+			continue
+		}
+		hits = append(hits, SearchHit{ItemSummary: summarizeItem(candidate.id, record), Fields: record.Fields, Score: score})
+	}
+	slices.SortStableFunc(hits, func(a, b SearchHit) int {
+		if a.Score != b.Score {
+			if a.Score > b.Score {
+				return -1
+			}
+			return 1
+		}
+		return strings.Compare(a.ID, b.ID)
+	})
+
+	result := &SearchResult{Hits: []SearchHit{}, Total: len(hits)}
+	if query.Offset < len(hits) {
+		end := min(query.Offset+query.Limit, len(hits))
+		result.Hits = hits[query.Offset:end]
+		if end < len(hits) {
+			result.NextOffset = end
+		}
+	}
+	return result, nil
+}
+
+// searchCandidates returns the tenant's items with their metadata, sorted
+// by ID. Items without metadata carry an empty record.
+func (ds *DataService) searchCandidates(ctx context.Context, store storage.StorageInterface, storageType string) ([]searchCandidate, error) {
+	if ds.index != nil {
+		records, err := ds.index.store.List(TenantFromContext(ctx), storageType)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list items: %w", err)
+		}
+		candidates := make([]searchCandidate, len(records))
+		for i, record := range records {
+			candidates[i] = searchCandidate{id: record.ID, metadata: indexedMetadata(record)}
+		}
+		return candidates, nil
+	}
+
+	ids, err := ds.ListItems(ctx, storageType)
+	if err != nil {
+		return nil, err
+	}
+	candidates := make([]searchCandidate, len(ids))
+	for i, id := range ids {
+		record, err := ds.loadMetadata(store, id)
+		if err != nil {
+			record = &ItemMetadata{}
+		}
+		candidates[i] = searchCandidate{id: id, metadata: record}
+	}
+	return candidates, nil
+}
+
+// score rates an item against the terms, or reports that some term matches
+// nowhere. Without terms every item scores zero. The payload is read only
+// when some term doesn't match the metadata.
+func (ds *DataService) score(store storage.StorageInterface, id string, record *ItemMetadata, terms []string, content bool) (float64, bool) {
+	var total float64
+	var unmatched []string
+	for _, term := range terms {
+		score := metadataScore(id, record, term)
+		if score == 0 {
+			unmatched = append(unmatched, term)
+		}
+		total += score
+	}
+	if len(unmatched) == 0 {
+		return total, true
+	}
+	if !content || !searchableContent(record) {
+		return 0, false
+	}
+	data, err := store.Load(id)
+	if err != nil {
+		return 0, false
+	}
+	text := strings.ToLower(string(data))
+	for _, term := range terms {
+		count := strings.Count(text, term)
+		if count == 0 {
+			if slices.Contains(unmatched, term) {
+				return 0, false
+			}
+			continue
+		}
+		// Repeated occurrences count for less than the first
+		total += searchWeightContent * (1 + float64(min(count-1, 9))/10)
+	}
+	return total, true
+}
+
+// metadataScore rates a single term against an item's ID and metadata
+func metadataScore(id string, record *ItemMetadata, term string) float64 {
+	var score float64
+	if strings.Contains(strings.ToLower(id), term) {
+		score += searchWeightID
+	}
+	for name, value := range record.Fields {
+		if strings.Contains(strings.ToLower(name+" "+fieldText(value)), term) {
+			score += searchWeightField
+		}
+	}
+	for key, value := range record.Labels {
+		if strings.Contains(strings.ToLower(key+" "+value), term) {
+			score += searchWeightLabel
+		}
+	}
+	if strings.Contains(strings.ToLower(record.Source), term) {
+		score += searchWeightSource
+	}
+	if strings.Contains(strings.ToLower(record.ContentType), term) {
+		score += searchWeightType
+	}
+	return score
+}
+
+// searchableContent reports whether an item's payload is text or JSON small
+// enough to search. Items without a content type are left out.
+func searchableContent(record *ItemMetadata) bool {
+	if record.Size > maxSearchContent {
+		return false
+	}
+	mediaType, _, err := mime.ParseMediaType(record.ContentType)
+	if err != nil {
+		return false
+	}
+	return strings.HasPrefix(mediaType, "text/") || mediaType == "application/json" || strings.HasSuffix(mediaType, "+json")
+}
+
+// matchesFields reports whether every wanted field has exactly the value
+func matchesFields(want map[string]string, fields map[string]any) bool {
+	for name, value := range want {
+		actual, ok := fields[name]
+		if !ok || fieldText(actual) != value {
+			return false
+		}
+	}
+	return true
+}
+
+// fieldText renders an extracted field's value: strings as they are, other
+// values as JSON
+func fieldText(value any) string {
+	if s, ok := value.(string); ok {
+		return s
+	}
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return ""
+	}
+	return string(encoded)
+}
+
+// searchTerms splits a query into lowercase terms, without duplicates
+func searchTerms(text string) []string {
+	var terms []string
+	for _, term := range strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return unicode.IsSpace(r) || r == ','
+	}) {
+		if !slices.Contains(terms, term) {
+			terms = append(terms, term)
+		}
+	}
+	return terms
+}
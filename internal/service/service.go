@@ -0,0 +1,493 @@
+// Package service implements the data service: validation, saving and
+// reading items with their metadata and versions, and the jobs, audit
+// trail, quotas and events built around it.
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"interview-task/internal/config"
+	"interview-task/internal/logging"
+	"interview-task/internal/storage"
+)
+
+// ErrValidation marks requests rejected by validation, mapped onto 400 Bad
+// Request
+var ErrValidation = errors.New("validation failed")
+
+// SaveRequest - FOLLOWS Single Responsibility
+type SaveRequest struct {
+	Data        []byte `json:"data"`
+	StorageType string `json:"storage_type"`
+	// ContentType is the declared media type; when empty it is sniffed
+	ContentType string `json:"content_type"`
+	// TTL is how long the item lives, such as "24h"; zero applies the
+	// configured default
+	TTL config.Duration `json:"ttl"`
+	// Labels and Source are stored in the item's metadata and can be
+	// filtered on when listing
+	Labels map[string]string `json:"labels"`
+	Source string            `json:"source"`
+	// Tags are set by internal callers such as the data generator
+	Tags map[string]string `json:"-"`
+	// Checksums supplied by the client in request headers
+	ExpectedMD5    []byte `json:"-"`
+	ExpectedSHA256 []byte `json:"-"`
+}
+
+// RequestValidator - IMPLEMENTS Single Responsibility. Structural checks
+// always run first, then the configured validator pipeline in order.
+type RequestValidator struct {
+	contentTypes contentTypePolicy
+	validators   []Validator
+}
+
+func NewRequestValidator(contentTypes config.ContentTypePolicy, validators ...Validator) *RequestValidator {
+	return &RequestValidator{contentTypes: contentTypePolicy(contentTypes), validators: validators}
+}
+
+func (v *RequestValidator) ValidateRequest(ctx context.Context, req *SaveRequest) error {
+	if err := v.validateFields(req); err != nil {
+		return err
+	}
+	for _, validator := range v.validators {
+		if err := validator.Validate(ctx, req); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (v *RequestValidator) validateFields(req *SaveRequest) error {
+	if len(req.Data) == 0 {
+		return fmt.Errorf("data cannot be empty")
+	}
+	if req.StorageType == "" {
+		return fmt.Errorf("storage type cannot be empty")
+	}
+	if !slices.Contains(storage.StorageTypes(), req.StorageType) {
+		return fmt.Errorf("invalid storage type: %s", req.StorageType)
+	}
+	if err := verifyChecksums(req); err != nil {
+		return err
+	}
+	if err := validateLabels(req.Labels, req.Source); err != nil {
+		return err
+	}
+	return v.contentTypes.resolveContentType(req)
+}
+
+// ValidateID rejects anything but the hex IDs generated by newItemID, which
+// also keeps IDs safe to use as file names
+func (v *RequestValidator) ValidateID(id string) error {
+	return ValidateItemID(id)
+}
+
+// ValidateItemID checks that id has the form NewItemID generates
+func ValidateItemID(id string) error {
+	if len(id) != 32 {
+		return fmt.Errorf("invalid id: %s", id)
+	}
+	if _, err := hex.DecodeString(id); err != nil {
+		return fmt.Errorf("invalid id: %s", id)
+	}
+	return nil
+}
+
+// NewItemID generates a random identifier for a stored item
+func NewItemID() (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("failed to generate id: %w", err)
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+// DataService - IMPLEMENTS Single Responsibility and Dependency Injection
+type DataService struct {
+	factory     storage.StorageFactory
+	validator   *RequestValidator
+	audit       AuditSink
+	derivations *DerivationRegistry
+	extractor   *MetadataExtractor
+	quotas      *QuotaManager
+	watermarks  *WatermarkTracker
+	expiry      config.ExpiryConfig
+	softDelete  config.SoftDeleteConfig
+	spool       *Spool
+	events      *EventBus
+	hooks       Hooks
+	maintenance *Maintenance
+	outbox      *Outbox
+	locks       *ItemLocks
+	conditional *ItemLocks
+	fields      *FieldEncryptor
+	access      *StorageAccess
+	budgets     *Budgets
+	index       *ItemIndex
+	holds       *TenantHolds
+}
+
+// Deps are the collaborators of a DataService, set by name as there are too
+// many to pass in order. Each is what its constructor returns for the
+// configuration, which for some disabled features is nil.
+type Deps struct {
+	Factory     storage.StorageFactory
+	Validator   *RequestValidator
+	Audit       AuditSink
+	Derivations *DerivationRegistry
+	Extractor   *MetadataExtractor
+	Quotas      *QuotaManager
+	Watermarks  *WatermarkTracker
+	Expiry      config.ExpiryConfig
+	SoftDelete  config.SoftDeleteConfig
+	Spool       *Spool
+	Events      *EventBus
+	Hooks       Hooks
+	Maintenance *Maintenance
+	Outbox      *Outbox
+	Locks       *ItemLocks
+	Fields      *FieldEncryptor
+	Access      *StorageAccess
+	Budgets     *Budgets
+	Index       *ItemIndex
+	Holds       *TenantHolds
+}
+
+func NewDataService(deps Deps) *DataService {
+	return &DataService{
+		factory:     deps.Factory,
+		validator:   deps.Validator,
+		audit:       deps.Audit,
+		derivations: deps.Derivations,
+		extractor:   deps.Extractor,
+		quotas:      deps.Quotas,
+		watermarks:  deps.Watermarks,
+		expiry:      deps.Expiry,
+		softDelete:  deps.SoftDelete,
+		spool:       deps.Spool,
+		events:      deps.Events,
+		hooks:       deps.Hooks,
+		maintenance: deps.Maintenance,
+		outbox:      deps.Outbox,
+		locks:       deps.Locks,
+		conditional: newConditionalLocks(),
+		fields:      deps.Fields,
+		access:      deps.Access,
+		budgets:     deps.Budgets,
+		index:       deps.Index,
+		holds:       deps.Holds,
+	}
+}
+
+// saveData stores the request payload and returns the generated item ID
+func (ds *DataService) saveData(ctx context.Context, req *SaveRequest) (string, error) {
+	id, err := NewItemID()
+	if err != nil {
+		return "", err
+	}
+	err = ds.createItem(ctx, id, req)
+	if err != nil && !errors.Is(err, ErrSpooled) {
+		return "", err
+	}
+	return id, err
+}
+
+// putData stores the request payload as item id, which the caller derives
+// from its own naming. An existing item gets a new version.
+func (ds *DataService) putData(ctx context.Context, id string, req *SaveRequest) error {
+	if err := ds.validator.ValidateID(id); err != nil {
+		return fmt.Errorf("%w: %w", ErrValidation, err)
+	}
+	// The item stays locked from the existence check to the write
+	ctx, unlock, err := ds.lockItem(ctx, req.StorageType, id)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	store, err := ds.openStorage(ctx, req.StorageType)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrValidation, err)
+	}
+	// Only a missing item is created; on any other failure it may exist,
+	// and creating it would reset its version history
+	switch _, err := store.Load(id); {
+	case err == nil:
+		_, err := ds.updateData(ctx, id, req)
+		return err
+	case !errors.Is(err, storage.ErrNotFound):
+		return fmt.Errorf("failed to load data: %w", err)
+	}
+	return ds.createItem(ctx, id, req)
+}
+
+// createItem validates and stores a new item
+func (ds *DataService) createItem(ctx context.Context, id string, req *SaveRequest) (err error) {
+	if err := ds.maintenance.check(); err != nil {
+		return err
+	}
+	// staged is set once the save's event is staged in the outbox rather
+	// than published directly
+	var staged bool
+	defer func() {
+		// Spooled saves are audited once they are replayed
+		if !errors.Is(err, ErrSpooled) {
+			if staged {
+				ds.appendAudit(ctx, AuditActionSave, req.StorageType, id, req.Data, err)
+			} else {
+				ds.recordAudit(ctx, AuditActionSave, req.StorageType, id, req.Data, err)
+			}
+			ds.hooks.afterSave(ctx, AuditActionSave, id, req, err)
+		}
+	}()
+
+	if err := ds.checkAccess(ctx, req.StorageType); err != nil {
+		return err
+	}
+	if err := ds.hooks.beforeSave(ctx, AuditActionSave, id, req); err != nil {
+		return fmt.Errorf("%w: %w", ErrValidation, err)
+	}
+
+	// Validate request
+	if err := ds.validator.ValidateRequest(ctx, req); err != nil {
+		return fmt.Errorf("%w: %w", ErrValidation, err)
+	}
+	if err := ds.sealFields(req); err != nil {
+		return err
+	}
+
+	// Use factory to create storage
+	storage, tx, err := ds.openForSave(TenantFromContext(ctx), req.StorageType)
+	if err != nil {
+		return fmt.Errorf("failed to create storage: %w", err)
+	}
+
+	if tx != nil {
+		staged = true
+		err = ds.saveStaged(ctx, tx, id, req)
+	} else {
+		err = ds.saveItem(ctx, storage, id, req)
+	}
+	if err != nil {
+		// During a backend outage the item is spooled and written later
+		if isUnavailable(err) && ds.spool.Enqueue(ctx, id, req) == nil {
+			return ErrSpooled
+		}
+		return err
+	}
+	ds.watermarks.Advance(TenantFromContext(ctx), AuditActionSave)
+	return nil
+}
+
+// openForSave opens a tenant's storage for saves. With the outbox enabled,
+// storage types that support transactions are opened in one, also returned
+// as tx.
+func (ds *DataService) openForSave(tenant, storageType string) (store storage.StorageInterface, tx *storage.StorageTx, err error) {
+	if factory, ok := ds.factory.(storage.TransactionalFactory); ok && ds.outbox.enabled() {
+		tx, err = factory.Begin(tenant, storageType)
+		if err == nil {
+			return tx, tx, nil
+		}
+		if !errors.Is(err, storage.ErrNotTransactional) {
+			return nil, nil, err
+		}
+	}
+	store, err = ds.factory.CreateStorage(tenant, storageType)
+	return store, nil, err
+}
+
+// saveStaged saves an item in tx and stages its event in the outbox, so the
+// event is published if and only if the save is committed
+func (ds *DataService) saveStaged(ctx context.Context, tx *storage.StorageTx, id string, req *SaveRequest) error {
+	defer tx.Rollback()
+	if err := ds.saveItem(ctx, tx, id, req); err != nil {
+		return err
+	}
+	event := newAuditEvent(ctx, AuditActionSave, req.StorageType, id, req.Data, nil)
+	err := ds.outbox.stage(tx, event.storageEvent())
+	if err == nil {
+		err = tx.Commit()
+	}
+	if err != nil {
+		ds.quotas.Release(TenantFromContext(ctx), PrincipalFromContext(ctx).Name, int64(len(req.Data)), 1)
+		return err
+	}
+	return nil
+}
+
+// saveItem stores a validated request as the new item id. Auditing and
+// watermarks are left to the caller, which knows when the write is final.
+func (ds *DataService) saveItem(ctx context.Context, storage storage.StorageInterface, id string, req *SaveRequest) error {
+	expiresAt, err := ds.expiry.ExpiresAt(req.TTL, time.Now())
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrValidation, err)
+	}
+
+	// Enforce tenant and key quotas before anything is written
+	tenant, owner := TenantFromContext(ctx), PrincipalFromContext(ctx).Name
+	size := int64(len(req.Data))
+	if err := ds.quotas.Reserve(tenant, owner, size, 1); err != nil {
+		return err
+	}
+
+	// Save data
+	if err := storage.Save(id, req.Data); err != nil {
+		ds.quotas.Release(tenant, owner, size, 1)
+		return fmt.Errorf("failed to save data: %w", err)
+	}
+
+	if err := ds.saveVersions(storage, id, []VersionEntry{newVersionEntry(1, req.Data, req.ContentType)}); err != nil {
+		logging.Printf("Failed to record version history for %s: %v", id, err)
+	}
+
+	base := ItemMetadata{
+		Owner:       owner,
+		Tags:        req.Tags,
+		Labels:      req.Labels,
+		Source:      req.Source,
+		ContentType: req.ContentType,
+		ExpiresAt:   expiresAt,
+		ModifiedAt:  time.Now().UTC(),
+		KeyVersion:  ds.keyVersion(req.StorageType),
+	}
+	if err := ds.indexMetadata(ctx, storage, req.StorageType, id, req.Data, base); err != nil {
+		// The payload is stored; the re-index job can repair the metadata
+		logging.Printf("Failed to index metadata for %s: %v", id, err)
+	}
+	ds.materializeEager(storage, id, req.Data)
+	return nil
+}
+
+// StoredItem is a payload together with what clients need to serve it
+type StoredItem struct {
+	Data        []byte
+	ContentType string
+	SHA256      string
+	// ETag names the version served, for conditional writes
+	ETag string
+	// ModifiedAt is when the payload was saved; zero when unknown
+	ModifiedAt time.Time
+}
+
+// getData loads a previously saved payload from the given storage type
+func (ds *DataService) getData(ctx context.Context, storageType, id string) (*StoredItem, error) {
+	if err := ds.validator.ValidateID(id); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrValidation, err)
+	}
+
+	store, err := ds.openStorage(ctx, storageType)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrValidation, err)
+	}
+
+	data, err := store.Load(id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load data: %w", err)
+	}
+	item := &StoredItem{Data: data, ContentType: "application/octet-stream", SHA256: storage.PayloadSHA256(data)}
+	if record, err := ds.loadMetadata(store, id); err == nil {
+		if err := record.unavailable(id, time.Now()); err != nil {
+			return nil, err
+		}
+		if record.ContentType != "" {
+			item.ContentType = record.ContentType
+		}
+		item.ModifiedAt = record.ModifiedAt
+	}
+	if versions, err := ds.loadVersions(store, id); err == nil {
+		item.ETag = versions[len(versions)-1].ETag()
+	}
+	if err := ds.openFields(ctx, item); err != nil {
+		return nil, err
+	}
+	return item, nil
+}
+
+// deleteData removes a stored item, or moves it to the trash when soft
+// delete is enabled. The payload is read first so the audit trail records a
+// hash of what was deleted.
+func (ds *DataService) deleteData(ctx context.Context, storageType, id string) error {
+	return ds.deleteItem(ctx, AuditActionDelete, storageType, id)
+}
+
+// deleteItem removes an item and everything linked to it, audited as action.
+// Purging applies to items in the trash only, erasure to items in and out
+// of it, and every other action to items outside of it. Items under legal
+// hold are refused to every action.
+func (ds *DataService) deleteItem(ctx context.Context, action, storageType, id string) (err error) {
+	if err := ds.maintenance.check(); err != nil {
+		return err
+	}
+	var data []byte
+	defer func() {
+		ds.recordAudit(ctx, action, storageType, id, data, err)
+	}()
+
+	if err := ds.validator.ValidateID(id); err != nil {
+		return fmt.Errorf("%w: %w", ErrValidation, err)
+	}
+	ctx, unlock, err := ds.lockItem(ctx, storageType, id)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	store, err := ds.openStorage(ctx, storageType)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrValidation, err)
+	}
+
+	data, err = store.Load(id)
+	if err != nil {
+		return fmt.Errorf("failed to load data: %w", err)
+	}
+	record, err := ds.loadMetadata(store, id)
+	if err != nil {
+		record = &ItemMetadata{}
+	}
+	trashed := !record.DeletedAt.IsZero()
+	if action != AuditActionErase && trashed != (action == AuditActionPurge) {
+		if trashed {
+			return fmt.Errorf("%s has been deleted: %w", id, storage.ErrNotFound)
+		}
+		return fmt.Errorf("%s is not in the trash: %w", id, storage.ErrNotFound)
+	}
+	if err := ds.checkLegalHold(ctx, id, record); err != nil {
+		return err
+	}
+	if ifMatch := ifMatchFromContext(ctx); ifMatch != "" {
+		versions, err := ds.loadVersions(store, id)
+		if err != nil {
+			return err
+		}
+		if err := checkIfMatch(ifMatch, id, &versions[len(versions)-1]); err != nil {
+			return err
+		}
+	}
+	if action == AuditActionDelete && ds.softDelete.Enabled {
+		return ds.moveToTrash(ctx, store, storageType, id, record)
+	}
+
+	// Retained versions are released along with the item
+	size := int64(len(data))
+	if versions, err := ds.loadVersions(store, id); err == nil {
+		size = storedBytes(versions)
+	}
+
+	if err := store.Delete(id); err != nil {
+		return fmt.Errorf("failed to delete data: %w", err)
+	}
+	ds.quotas.Release(TenantFromContext(ctx), record.Owner, size, 1)
+	ds.deleteLinkedItems(ctx, store, storageType, id)
+	// Clients saw a trashed item disappear when it was moved to the trash
+	if !trashed {
+		ds.watermarks.Advance(TenantFromContext(ctx), action)
+	}
+	return nil
+}
@@ -0,0 +1,134 @@
+package service_test
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"interview-task/internal/config"
+	"interview-task/internal/metrics"
+	"interview-task/internal/service"
+	"interview-task/internal/storage"
+	"interview-task/pkg/storagetest"
+)
+
+// newTestService builds a data service over factory with cfg's settings,
+// keeping its state files in a temporary directory
+func newTestService(t *testing.T, cfg *config.Configuration, factory storage.StorageFactory) *service.DataService {
+	t.Helper()
+	dir := t.TempDir()
+	t.Chdir(dir)
+	database, err := storage.NewDatabaseConnection(cfg.DatabaseHost, cfg.DatabasePort,
+		cfg.DatabaseUser, cfg.DatabasePass, cfg.DatabaseName, cfg.DatabasePool)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := metrics.NewMetrics()
+	events := service.NewEventBus(cfg.Events, m)
+	deps := service.Deps{
+		Factory:     factory,
+		Validator:   service.NewRequestValidator(cfg.ContentTypePolicy),
+		Audit:       &service.NopAuditSink{},
+		Expiry:      cfg.Expiry,
+		SoftDelete:  cfg.SoftDelete,
+		Events:      events,
+		Maintenance: service.NewMaintenance(cfg.Maintenance),
+		Outbox:      service.NewOutbox(cfg.Outbox, database, events, m),
+	}
+	must := func(err error) {
+		t.Helper()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	deps.Derivations, err = service.NewDerivationRegistryFromConfig(cfg.Derivations)
+	must(err)
+	deps.Extractor, err = service.NewMetadataExtractor(cfg.MetadataRules)
+	must(err)
+	deps.Quotas, err = service.NewQuotaManager(cfg.Quotas)
+	must(err)
+	deps.Watermarks, err = service.NewWatermarkTracker(filepath.Join(dir, "watermarks.json"))
+	must(err)
+	deps.Holds, err = service.NewTenantHolds(filepath.Join(dir, "legal-holds.json"))
+	must(err)
+	deps.Spool, err = service.NewSpool(cfg.Spool)
+	must(err)
+	deps.Locks, err = service.NewItemLocks(cfg.Locking, database)
+	must(err)
+	deps.Fields, err = service.NewFieldEncryptor(cfg.FieldEncryption)
+	must(err)
+	deps.Access, err = service.NewStorageAccess(cfg.StorageAccess)
+	must(err)
+	deps.Budgets, err = service.NewBudgets(cfg.Timeouts)
+	must(err)
+	deps.Index, err = service.NewItemIndex(cfg.Index, database)
+	must(err)
+	return service.NewDataService(deps)
+}
+
+func TestPutDataKeepsItemWhenLoadFails(t *testing.T) {
+	factory := storagetest.NewFakeFactory()
+	ds := newTestService(t, config.NewConfiguration(), factory)
+	ctx := context.Background()
+	const id = "0123456789abcdef0123456789abcdef"
+
+	if err := ds.PutData(ctx, id, &service.SaveRequest{Data: []byte("first"), StorageType: "file"}); err != nil {
+		t.Fatal(err)
+	}
+	injected := errors.New("backend unavailable")
+	factory.Storage(service.DefaultTenant, "file").FailNext(storagetest.OpLoad, 1, injected)
+	err := ds.PutData(ctx, id, &service.SaveRequest{Data: []byte("second"), StorageType: "file"})
+	if !errors.Is(err, injected) {
+		t.Fatalf("PutData returned %v, want the load failure", err)
+	}
+
+	item, err := ds.GetData(ctx, "file", id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(item.Data) != "first" {
+		t.Fatalf("item holds %q after a failed put, want first", item.Data)
+	}
+}
+
+func TestConcurrentConditionalUpdates(t *testing.T) {
+	factory := storagetest.NewFakeFactory()
+	// Locking is disabled by default
+	ds := newTestService(t, config.NewConfiguration(), factory)
+	ctx := context.Background()
+	const id = "0123456789abcdef0123456789abcdef"
+
+	data := []byte("first")
+	if err := ds.PutData(ctx, id, &service.SaveRequest{Data: data, StorageType: "file"}); err != nil {
+		t.Fatal(err)
+	}
+	etag := service.ETag(1, storage.PayloadSHA256(data))
+	// Slow storage widens the window between the If-Match check and the write
+	factory.Storage(service.DefaultTenant, "file").SetLatency(5 * time.Millisecond)
+
+	var wg sync.WaitGroup
+	errs := make([]error, 2)
+	for i := range errs {
+		wg.Go(func() {
+			req := &service.SaveRequest{Data: []byte{byte('a' + i)}, StorageType: "file"}
+			_, errs[i] = ds.UpdateData(service.WithIfMatch(ctx, etag), id, req)
+		})
+	}
+	wg.Wait()
+
+	failed := 0
+	for _, err := range errs {
+		switch {
+		case errors.Is(err, service.ErrPreconditionFailed):
+			failed++
+		case err != nil:
+			t.Fatalf("update returned %v", err)
+		}
+	}
+	if failed != 1 {
+		t.Fatalf("%d of 2 updates with the same If-Match failed the precondition, want 1", failed)
+	}
+}
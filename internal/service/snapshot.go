@@ -0,0 +1,379 @@
+package service
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"interview-task/internal/config"
+	"interview-task/internal/logging"
+	"interview-task/internal/storage"
+)
+
+// A snapshot is a directory holding a backup archive of every storage type,
+// the metadata index as JSON lines, and snapshot.json, written last, which
+// describes them. Snapshots are written under a temporary name and renamed
+// into place, so one that exists is complete.
+const (
+	snapshotArchiveName = "backup.tar.gz"
+	snapshotIndexName   = "index.jsonl"
+	snapshotInfoName    = "snapshot.json"
+)
+
+var snapshotNamePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_.-]{0,63}$`)
+
+// SnapshotInfo describes a snapshot
+type SnapshotInfo struct {
+	Name      string    `json:"name"`
+	CreatedAt time.Time `json:"created_at"`
+	Keys      int       `json:"keys"`
+	Bytes     int64     `json:"bytes"`
+	// IndexRecords is set when the metadata index was enabled
+	IndexRecords *int `json:"index_records,omitempty"`
+}
+
+// snapshotFile is the content of snapshot.json. Entries list the archived
+// keys, so a restore can remove every other one.
+type snapshotFile struct {
+	SnapshotInfo
+	Entries []BackupEntry `json:"entries"`
+}
+
+// SnapshotRestoreReport sums up restoring a snapshot: the keys restored
+// from its archive, the keys removed as they weren't in it, and the index
+// records restored
+type SnapshotRestoreReport struct {
+	RestoreReport
+	Removed      int  `json:"removed"`
+	IndexRecords *int `json:"index_records,omitempty"`
+}
+
+// SnapshotManager - IMPLEMENTS named snapshots of every storage type and the
+// metadata index, and restoring the service to one. Restores need
+// maintenance mode, so nothing writes while keys are replaced.
+type SnapshotManager struct {
+	backups     *BackupManager
+	factory     *storage.ConcreteStorageFactory
+	index       *ItemIndex
+	maintenance *Maintenance
+	dir         string
+
+	mu      sync.Mutex
+	running bool
+}
+
+func NewSnapshotManager(backups *BackupManager, factory *storage.ConcreteStorageFactory, index *ItemIndex, maintenance *Maintenance, config config.SnapshotConfig) *SnapshotManager {
+	return &SnapshotManager{backups: backups, factory: factory, index: index, maintenance: maintenance, dir: config.Dir}
+}
+
+// begin claims the manager for one create or restore at a time
+func (m *SnapshotManager) begin() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.running {
+		return fmt.Errorf("%w: a snapshot is being created or restored", storage.ErrConflict)
+	}
+	m.running = true
+	return nil
+}
+
+func (m *SnapshotManager) end() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.running = false
+}
+
+func validateSnapshotName(name string) error {
+	if !snapshotNamePattern.MatchString(name) {
+		return fmt.Errorf("%w: invalid snapshot name: %q", ErrValidation, name)
+	}
+	return nil
+}
+
+// Create snapshots every storage type and the metadata index under name.
+// Writes during a snapshot may be caught partly; maintenance mode makes it
+// consistent.
+func (m *SnapshotManager) Create(ctx context.Context, name string) (*SnapshotInfo, error) {
+	if err := validateSnapshotName(name); err != nil {
+		return nil, err
+	}
+	if err := m.begin(); err != nil {
+		return nil, err
+	}
+	defer m.end()
+	if _, err := os.Stat(filepath.Join(m.dir, name)); err == nil {
+		return nil, fmt.Errorf("%w: snapshot %s already exists", storage.ErrConflict, name)
+	}
+
+	if err := os.MkdirAll(m.dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create snapshot directory: %w", err)
+	}
+	tmp, err := os.MkdirTemp(m.dir, "."+name+".*.tmp")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create snapshot directory: %w", err)
+	}
+	defer os.RemoveAll(tmp)
+
+	snapshot := snapshotFile{SnapshotInfo: SnapshotInfo{Name: name}}
+	if err := m.writeArchive(ctx, filepath.Join(tmp, snapshotArchiveName), &snapshot); err != nil {
+		return nil, err
+	}
+	if m.index != nil {
+		records, err := m.index.records()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read the index: %w", err)
+		}
+		if err := writeIndexRecords(filepath.Join(tmp, snapshotIndexName), records); err != nil {
+			return nil, err
+		}
+		count := len(records)
+		snapshot.IndexRecords = &count
+	}
+	data, err := json.MarshalIndent(snapshot, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if err := storage.WriteFileAtomic(filepath.Join(tmp, snapshotInfoName), data); err != nil {
+		return nil, err
+	}
+	if err := os.Rename(tmp, filepath.Join(m.dir, name)); err != nil {
+		return nil, fmt.Errorf("failed to save snapshot: %w", err)
+	}
+	logging.Printf("Created snapshot %s of %d keys", name, snapshot.Keys)
+	return &snapshot.SnapshotInfo, nil
+}
+
+// writeArchive backs up every storage type to path
+func (m *SnapshotManager) writeArchive(ctx context.Context, path string, snapshot *snapshotFile) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create archive: %w", err)
+	}
+	defer file.Close()
+	manifest, err := m.backups.Backup(ctx, file, BackupFilter{})
+	if err != nil {
+		return fmt.Errorf("failed to back up: %w", err)
+	}
+	if err := file.Sync(); err != nil {
+		return fmt.Errorf("failed to sync archive: %w", err)
+	}
+	stat, err := file.Stat()
+	if err != nil {
+		return err
+	}
+	snapshot.CreatedAt, snapshot.Entries = manifest.CreatedAt, manifest.Entries
+	snapshot.Keys, snapshot.Bytes = len(manifest.Entries), stat.Size()
+	return file.Close()
+}
+
+func writeIndexRecords(path string, records []storage.IndexRecord) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create index file: %w", err)
+	}
+	defer file.Close()
+	out := bufio.NewWriter(file)
+	encoder := json.NewEncoder(out)
+	for _, record := range records {
+		if err := encoder.Encode(record); err != nil {
+			return err
+		}
+	}
+	if err := out.Flush(); err != nil {
+		return fmt.Errorf("failed to write index file: %w", err)
+	}
+	if err := file.Sync(); err != nil {
+		return fmt.Errorf("failed to sync index file: %w", err)
+	}
+	return file.Close()
+}
+
+func readIndexRecords(path string) ([]storage.IndexRecord, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	var records []storage.IndexRecord
+	decoder := json.NewDecoder(file)
+	for decoder.More() {
+		var record storage.IndexRecord
+		if err := decoder.Decode(&record); err != nil {
+			return nil, fmt.Errorf("invalid index file: %w", err)
+		}
+		records = append(records, record)
+	}
+	return records, nil
+}
+
+// List returns the snapshots, oldest first
+func (m *SnapshotManager) List() ([]SnapshotInfo, error) {
+	entries, err := os.ReadDir(m.dir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return []SnapshotInfo{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to list snapshots: %w", err)
+	}
+	snapshots := []SnapshotInfo{}
+	for _, entry := range entries {
+		if !entry.IsDir() || !snapshotNamePattern.MatchString(entry.Name()) {
+			continue
+		}
+		snapshot, err := m.load(entry.Name())
+		if err != nil {
+			logging.Printf("Skipping snapshot %s: %v", entry.Name(), err)
+			continue
+		}
+		snapshots = append(snapshots, snapshot.SnapshotInfo)
+	}
+	slices.SortFunc(snapshots, func(a, b SnapshotInfo) int {
+		if c := a.CreatedAt.Compare(b.CreatedAt); c != 0 {
+			return c
+		}
+		return strings.Compare(a.Name, b.Name)
+	})
+	return snapshots, nil
+}
+
+// load reads a snapshot's description, or fails with ErrNotFound
+func (m *SnapshotManager) load(name string) (*snapshotFile, error) {
+	if err := validateSnapshotName(name); err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(filepath.Join(m.dir, name, snapshotInfoName))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("snapshot %s: %w", name, storage.ErrNotFound)
+	}
+	if err != nil {
+		return nil, err
+	}
+	snapshot := &snapshotFile{}
+	if err := json.Unmarshal(data, snapshot); err != nil {
+		return nil, fmt.Errorf("invalid snapshot: %w", err)
+	}
+	return snapshot, nil
+}
+
+// Delete removes a snapshot
+func (m *SnapshotManager) Delete(name string) error {
+	if _, err := m.load(name); err != nil {
+		return err
+	}
+	if err := m.begin(); err != nil {
+		return err
+	}
+	defer m.end()
+	return os.RemoveAll(filepath.Join(m.dir, name))
+}
+
+// Restore returns the service to a snapshot. Every archived key is restored
+// and every other key removed, except for scheduled backups, and the index
+// is replaced by the snapshot's. Keys are only removed once the whole
+// archive was restored. A snapshot taken without the index leaves the
+// index to a rebuild.
+func (m *SnapshotManager) Restore(ctx context.Context, name string) (SnapshotRestoreReport, error) {
+	var report SnapshotRestoreReport
+	err := m.restore(ctx, name, &report)
+	if err != nil {
+		report.Error = err.Error()
+	}
+	return report, err
+}
+
+func (m *SnapshotManager) restore(ctx context.Context, name string, report *SnapshotRestoreReport) error {
+	if !m.maintenance.Enabled() {
+		return fmt.Errorf("%w: enable maintenance mode before restoring a snapshot", storage.ErrConflict)
+	}
+	snapshot, err := m.load(name)
+	if err != nil {
+		return err
+	}
+	if err := m.begin(); err != nil {
+		return err
+	}
+	defer m.end()
+
+	var records []storage.IndexRecord
+	if m.index != nil && snapshot.IndexRecords != nil {
+		if records, err = readIndexRecords(filepath.Join(m.dir, name, snapshotIndexName)); err != nil {
+			return err
+		}
+	}
+	archive, err := os.Open(filepath.Join(m.dir, name, snapshotArchiveName))
+	if err != nil {
+		return fmt.Errorf("failed to open archive: %w", err)
+	}
+	defer archive.Close()
+	if err := m.backups.restore(ctx, archive, &report.RestoreReport); err != nil {
+		return err
+	}
+	if !report.Complete {
+		return fmt.Errorf("snapshot %s was only partly restored; no keys were removed", name)
+	}
+
+	keep := make(map[[3]string]bool, len(snapshot.Entries))
+	for _, entry := range snapshot.Entries {
+		keep[[3]string{entry.StorageType, entry.Tenant, entry.Key}] = true
+	}
+	for _, storageType := range storage.StorageTypes() {
+		tenants, err := m.factory.Tenants(storageType)
+		if err != nil {
+			return err
+		}
+		for _, tenant := range tenants {
+			if tenant == m.backups.config.Tenant {
+				continue
+			}
+			if err := m.prune(ctx, storageType, tenant, keep, report); err != nil {
+				return fmt.Errorf("failed to remove keys of %s of tenant %s: %w", storageType, tenant, err)
+			}
+		}
+	}
+
+	if m.index != nil && snapshot.IndexRecords != nil {
+		if err := m.index.replace(records); err != nil {
+			return fmt.Errorf("failed to restore the index: %w", err)
+		}
+		count := len(records)
+		report.IndexRecords = &count
+	}
+	logging.Printf("Restored snapshot %s: %d keys restored, %d removed", name, report.Restored, report.Removed)
+	return nil
+}
+
+// prune removes a tenant's keys that aren't in the snapshot
+func (m *SnapshotManager) prune(ctx context.Context, storageType, tenant string, keep map[[3]string]bool, report *SnapshotRestoreReport) error {
+	store, err := m.factory.Sharded(tenant, storageType)
+	if err != nil {
+		return err
+	}
+	keys, err := store.List()
+	if err != nil {
+		return err
+	}
+	for _, key := range keys {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if keep[[3]string{storageType, tenant, key}] {
+			continue
+		}
+		if err := store.Delete(key); err != nil && !errors.Is(err, storage.ErrNotFound) {
+			return fmt.Errorf("failed to delete %s: %w", key, err)
+		}
+		m.factory.Invalidate(tenant, storageType, key)
+		report.Removed++
+	}
+	return nil
+}
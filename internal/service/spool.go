@@ -0,0 +1,293 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"interview-task/internal/config"
+	"interview-task/internal/logging"
+	"interview-task/internal/metrics"
+	"interview-task/internal/storage"
+)
+
+// ErrSpooled is returned by saves accepted into the spool during a backend
+// outage. The item can't be read until it has been replayed.
+var ErrSpooled = errors.New("backend unavailable, save spooled for replay")
+
+// isUnavailable reports whether err means the backend is down, rather than
+// the request being wrong
+func isUnavailable(err error) bool {
+	return errors.Is(err, storage.ErrStorageUnavailable) || errors.Is(err, storage.ErrPoolTimeout)
+}
+
+// spoolEntry is a save waiting in the spool, with the context it was made in
+type spoolEntry struct {
+	ID        string      `json:"id"`
+	Tenant    string      `json:"tenant"`
+	Principal Principal   `json:"principal"`
+	RequestID string      `json:"request_id,omitempty"`
+	SpooledAt time.Time   `json:"spooled_at"`
+	Request   SaveRequest `json:"request"`
+	// Tags aren't part of the request's JSON
+	Tags map[string]string `json:"tags,omitempty"`
+}
+
+// Spool - IMPLEMENTS the write-ahead spool for backend outages. Each save
+// is a file named so that listing the directory yields them in spool order.
+// Saves that can't be replayed are moved to the failed subdirectory.
+type Spool struct {
+	config config.SpoolConfig
+
+	mu    sync.Mutex
+	bytes int64
+}
+
+func NewSpool(config config.SpoolConfig) (*Spool, error) {
+	s := &Spool{config: config}
+	if !config.Enabled {
+		return s, nil
+	}
+	if err := os.MkdirAll(filepath.Join(config.Dir, "failed"), 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create spool directory: %w", err)
+	}
+	names, err := s.pending()
+	if err != nil {
+		return nil, err
+	}
+	for _, name := range names {
+		if info, err := os.Stat(filepath.Join(config.Dir, name)); err == nil {
+			s.bytes += info.Size()
+		}
+	}
+	if len(names) > 0 {
+		logging.Printf("Spool holds %d saves to replay", len(names))
+	}
+	return s, nil
+}
+
+// Enqueue durably stores a save for replay
+func (s *Spool) Enqueue(ctx context.Context, id string, req *SaveRequest) error {
+	if !s.config.Enabled {
+		return fmt.Errorf("spool is disabled")
+	}
+	entry := spoolEntry{
+		ID:        id,
+		Tenant:    TenantFromContext(ctx),
+		Principal: *PrincipalFromContext(ctx),
+		RequestID: RequestIDFromContext(ctx),
+		SpooledAt: time.Now().UTC(),
+		Request:   *req,
+		Tags:      req.Tags,
+	}
+	encoded, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("failed to encode spool entry: %w", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.config.MaxBytes > 0 && s.bytes+int64(len(encoded)) > s.config.MaxBytes {
+		logging.Printf("Spool is full, rejecting save of %s", id)
+		return fmt.Errorf("spool is full")
+	}
+	name := fmt.Sprintf("%020d-%s.json", entry.SpooledAt.UnixNano(), id)
+	if err := storage.WriteFileAtomic(filepath.Join(s.config.Dir, name), encoded); err != nil {
+		return fmt.Errorf("failed to spool save: %w", err)
+	}
+	s.bytes += int64(len(encoded))
+	return nil
+}
+
+// pending lists the spooled saves, oldest first
+func (s *Spool) pending() ([]string, error) {
+	entries, err := os.ReadDir(s.config.Dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read spool: %w", err)
+	}
+	var names []string
+	for _, entry := range entries {
+		if entry.Type().IsRegular() && strings.HasSuffix(entry.Name(), ".json") {
+			names = append(names, entry.Name())
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// Pending returns how many saves wait for replay
+func (s *Spool) Pending() int {
+	names, err := s.pending()
+	if err != nil {
+		return 0
+	}
+	return len(names)
+}
+
+func (s *Spool) load(name string) (*spoolEntry, error) {
+	encoded, err := os.ReadFile(filepath.Join(s.config.Dir, name))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read spool entry: %w", err)
+	}
+	var entry spoolEntry
+	if err := json.Unmarshal(encoded, &entry); err != nil {
+		return nil, fmt.Errorf("corrupt spool entry %s: %w", name, err)
+	}
+	return &entry, nil
+}
+
+// settle takes a replayed save out of the spool; failed ones are kept in
+// the failed subdirectory for inspection
+func (s *Spool) settle(name string, failed bool) {
+	path := filepath.Join(s.config.Dir, name)
+	info, statErr := os.Stat(path)
+	var err error
+	if failed {
+		err = os.Rename(path, filepath.Join(s.config.Dir, "failed", name))
+	} else {
+		err = os.Remove(path)
+	}
+	if err != nil {
+		logging.Printf("Failed to remove %s from the spool: %v", name, err)
+		return
+	}
+	if statErr == nil {
+		s.mu.Lock()
+		s.bytes -= info.Size()
+		s.mu.Unlock()
+	}
+}
+
+// SpoolReplayer - IMPLEMENTS the background job writing spooled saves once
+// their backend has recovered. Saves are replayed in spool order; a replay
+// round stops at the first save whose backend is still down.
+type SpoolReplayer struct {
+	spool    *Spool
+	service  *DataService
+	factory  storage.StorageFactory
+	interval time.Duration
+	metrics  *metrics.Metrics
+
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+func NewSpoolReplayer(spool *Spool, service *DataService, factory storage.StorageFactory, metrics *metrics.Metrics) *SpoolReplayer {
+	metrics.Describe("spool_replayed_total", "Spooled saves written after their backend recovered")
+	metrics.Describe("spool_failed_total", "Spooled saves that failed for a reason other than an outage")
+	metrics.GaugeFunc("spool_pending", "Saves waiting in the spool", func() int64 {
+		return int64(spool.Pending())
+	})
+	return &SpoolReplayer{
+		spool:    spool,
+		service:  service,
+		factory:  factory,
+		interval: time.Duration(spool.config.ReplayInterval),
+		metrics:  metrics,
+	}
+}
+
+// Start replays the spool every interval until Shutdown
+func (r *SpoolReplayer) Start() {
+	if r.interval <= 0 || !r.spool.config.Enabled {
+		return
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	r.cancel, r.done = cancel, make(chan struct{})
+	go r.run(ctx, r.done)
+}
+
+func (r *SpoolReplayer) run(ctx context.Context, done chan struct{}) {
+	defer close(done)
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		if err := r.Replay(ctx); err != nil && !errors.Is(err, context.Canceled) {
+			logging.Printf("Spool replay paused: %v", err)
+		}
+	}
+}
+
+// Replay writes spooled saves until the spool is empty or a backend is
+// still down
+func (r *SpoolReplayer) Replay(ctx context.Context) error {
+	if r.service.maintenance.Enabled() {
+		// Spooled saves stay queued until maintenance is over
+		return nil
+	}
+	names, err := r.spool.pending()
+	if err != nil {
+		return err
+	}
+	for _, name := range names {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		entry, err := r.spool.load(name)
+		if err != nil {
+			logging.Printf("Dropping unreadable spool entry: %v", err)
+			r.spool.settle(name, true)
+			r.metrics.Add("spool_failed_total", 1)
+			continue
+		}
+		err = r.replay(ctx, entry)
+		if isUnavailable(err) {
+			return err
+		}
+		r.spool.settle(name, err != nil)
+		if err != nil {
+			logging.Printf("Failed to replay spooled save of %s: %v", entry.ID, err)
+			r.metrics.Add("spool_failed_total", 1, "storage_type", entry.Request.StorageType)
+		} else {
+			r.metrics.Add("spool_replayed_total", 1, "storage_type", entry.Request.StorageType)
+		}
+	}
+	return nil
+}
+
+// replay saves a spooled entry as its original caller, auditing the outcome
+// under the original request ID
+func (r *SpoolReplayer) replay(ctx context.Context, entry *spoolEntry) (err error) {
+	principal := entry.Principal
+	ctx = WithPrincipal(WithTenant(ctx, entry.Tenant), &principal)
+	if entry.RequestID != "" {
+		ctx = WithRequestID(ctx, entry.RequestID)
+	}
+	req := &entry.Request
+	req.Tags = entry.Tags
+	defer func() {
+		if !isUnavailable(err) {
+			r.service.recordAudit(ctx, AuditActionSave, req.StorageType, entry.ID, req.Data, err)
+			r.service.hooks.afterSave(ctx, AuditActionSave, entry.ID, req, err)
+		}
+	}()
+
+	storage, err := r.factory.CreateStorage(entry.Tenant, req.StorageType)
+	if err != nil {
+		return fmt.Errorf("failed to create storage: %w", err)
+	}
+	if err := r.service.saveItem(ctx, storage, entry.ID, req); err != nil {
+		return err
+	}
+	r.service.watermarks.Advance(entry.Tenant, AuditActionSave)
+	return nil
+}
+
+func (r *SpoolReplayer) Shutdown() {
+	if r.cancel != nil {
+		r.cancel()
+		<-r.done
+	}
+}
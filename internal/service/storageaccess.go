@@ -0,0 +1,89 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+
+	"interview-task/internal/config"
+	"interview-task/internal/storage"
+)
+
+// ErrStorageTypeForbidden is returned for a storage type the caller may not
+// use
+var ErrStorageTypeForbidden = errors.New("storage type not permitted")
+
+// StorageAccess - IMPLEMENTS per-principal storage type rules. Rules apply
+// to callers presenting an API key; with authentication disabled, and for
+// the service's own background jobs, every storage type is open.
+type StorageAccess struct {
+	rules []config.StorageAccessRule
+}
+
+// NewStorageAccess returns nil without rules
+func NewStorageAccess(rules []config.StorageAccessRule) (*StorageAccess, error) {
+	if len(rules) == 0 {
+		return nil, nil
+	}
+	storageTypes := storage.StorageTypes()
+	for i, rule := range rules {
+		for _, storageType := range slices.Concat(rule.Allow, rule.Deny) {
+			if !slices.Contains(storageTypes, storageType) {
+				return nil, fmt.Errorf("storage access rule %d: unknown storage type %q", i, storageType)
+			}
+		}
+	}
+	return &StorageAccess{rules: rules}, nil
+}
+
+func (a *StorageAccess) Check(principal *Principal, storageType string) error {
+	if a == nil || !principal.Authenticated() {
+		return nil
+	}
+	restricted, allowed := false, false
+	for _, rule := range a.rules {
+		if !ruleMatches(&rule, principal) {
+			continue
+		}
+		if slices.Contains(rule.Deny, storageType) {
+			return fmt.Errorf("%w: %s may not use %s", ErrStorageTypeForbidden, principal.Name, storageType)
+		}
+		if len(rule.Allow) > 0 {
+			restricted = true
+			allowed = allowed || slices.Contains(rule.Allow, storageType)
+		}
+	}
+	if restricted && !allowed {
+		return fmt.Errorf("%w: %s may not use %s", ErrStorageTypeForbidden, principal.Name, storageType)
+	}
+	return nil
+}
+
+func ruleMatches(rule *config.StorageAccessRule, principal *Principal) bool {
+	if len(rule.Keys) == 0 && len(rule.Roles) == 0 {
+		return true
+	}
+	return slices.Contains(rule.Keys, principal.Name) || slices.ContainsFunc(rule.Roles, principal.HasRole)
+}
+
+// checkAccess refuses storage types the caller may not use
+func (ds *DataService) checkAccess(ctx context.Context, storageType string) error {
+	return ds.access.Check(PrincipalFromContext(ctx), storageType)
+}
+
+// openStorage opens the caller's tenant storage of a storage type, once the
+// caller is permitted to use it
+func (ds *DataService) openStorage(ctx context.Context, storageType string) (storage.StorageInterface, error) {
+	if err := ds.checkAccess(ctx, storageType); err != nil {
+		return nil, err
+	}
+	store, err := ds.factory.CreateStorage(TenantFromContext(ctx), storageType)
+	if err != nil {
+		return nil, err
+	}
+	if _, held := ctx.Value(lockedItemKey).(string); held {
+		return &lockedStorage{StorageInterface: store, ctx: ctx}, nil
+	}
+	return store, nil
+}
@@ -0,0 +1,19 @@
+package service
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// DefaultTenant is used when a request doesn't name a tenant
+const DefaultTenant = "default"
+
+// Tenant IDs become directory names and key prefixes, so keep them tame
+var tenantPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,62}$`)
+
+func ValidateTenant(tenant string) error {
+	if !tenantPattern.MatchString(tenant) {
+		return fmt.Errorf("invalid tenant: %q", tenant)
+	}
+	return nil
+}
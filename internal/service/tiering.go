@@ -0,0 +1,169 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"maps"
+	"slices"
+	"time"
+
+	"interview-task/internal/config"
+	"interview-task/internal/logging"
+	"interview-task/internal/metrics"
+	"interview-task/internal/storage"
+)
+
+// TierTransitioner - IMPLEMENTS the background job moving items to their
+// storage type's cold tier once they haven't changed for the configured
+// age. Each run reads the metadata of every hot item of every tenant; items
+// without metadata stay hot.
+type TierTransitioner struct {
+	service *DataService
+	factory storage.TenantLister
+	// tiers moves items; it is nil when the factory has no tiers
+	tiers   storage.TieredFactory
+	config  config.TieringConfig
+	metrics *metrics.Metrics
+	leader  *LeaderElector
+
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+func NewTierTransitioner(service *DataService, factory storage.TenantLister, config config.TieringConfig, metrics *metrics.Metrics, leader *LeaderElector) *TierTransitioner {
+	metrics.Describe("tier_transitions_total", "Items moved to their cold tier by storage type")
+	metrics.Describe("tier_transition_failures_total", "Items that couldn't be moved to their cold tier by storage type")
+	tiers, _ := factory.(storage.TieredFactory)
+	return &TierTransitioner{service: service, factory: factory, tiers: tiers, config: config, metrics: metrics, leader: leader}
+}
+
+// Start runs the transition job every interval until Shutdown
+func (t *TierTransitioner) Start() {
+	if len(t.config.ColdTiers) == 0 || t.config.Interval <= 0 {
+		return
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	t.cancel, t.done = cancel, make(chan struct{})
+	go t.run(ctx, t.done)
+}
+
+func (t *TierTransitioner) run(ctx context.Context, done chan struct{}) {
+	defer close(done)
+	ticker := time.NewTicker(time.Duration(t.config.Interval))
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		// Only the elected instance runs scheduled jobs
+		if !t.leader.IsLeader() {
+			continue
+		}
+		if err := t.Transition(ctx); err != nil && !errors.Is(err, context.Canceled) {
+			logging.Printf("Tier transition failed: %v", err)
+		}
+	}
+}
+
+// Transition moves every item old enough to its cold tier
+func (t *TierTransitioner) Transition(ctx context.Context) error {
+	if t.service.maintenance.Enabled() || t.tiers == nil {
+		return nil
+	}
+	for _, storageType := range storage.StorageTypes() {
+		if t.tiers.ColdTier(storageType) == "" {
+			continue
+		}
+		tenants, err := t.factory.Tenants(storageType)
+		if err != nil {
+			return err
+		}
+		for _, tenant := range tenants {
+			if err := t.transitionTenant(WithTenant(ctx, tenant), tenant, storageType); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (t *TierTransitioner) transitionTenant(ctx context.Context, tenant, storageType string) error {
+	keys, err := t.tiers.HotKeys(tenant, storageType)
+	if err != nil {
+		return err
+	}
+	// An item moves with its linked records
+	items := make(map[string][]string)
+	for _, key := range keys {
+		id := storage.ShardKey(key)
+		items[id] = append(items[id], key)
+	}
+	store, err := t.factory.CreateStorage(tenant, storageType)
+	if err != nil {
+		return err
+	}
+
+	cutoff := time.Now().Add(-time.Duration(t.config.After))
+	for _, id := range slices.Sorted(maps.Keys(items)) {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if !t.due(store, id, cutoff) {
+			continue
+		}
+		moved, err := t.demote(ctx, store, tenant, storageType, id, items[id], cutoff)
+		switch {
+		case err != nil:
+			t.metrics.Add("tier_transition_failures_total", 1, "storage_type", storageType)
+			logging.Printf("Failed to move %s of tenant %s to the cold tier: %v", id, tenant, err)
+		case moved:
+			t.metrics.Add("tier_transitions_total", 1, "storage_type", storageType)
+		}
+	}
+	return nil
+}
+
+// due reports whether an item last changed before cutoff
+func (t *TierTransitioner) due(store storage.StorageInterface, id string, cutoff time.Time) bool {
+	record, err := t.service.loadMetadata(store, id)
+	if err != nil {
+		return false
+	}
+	changed := record.ModifiedAt
+	if changed.IsZero() {
+		changed = record.IndexedAt
+	}
+	return changed.Before(cutoff)
+}
+
+// demote moves an item's keys with the item locked, unless it changed
+// since they were listed
+func (t *TierTransitioner) demote(ctx context.Context, store storage.StorageInterface, tenant, storageType, id string, keys []string, cutoff time.Time) (bool, error) {
+	_, unlock, err := t.service.lockItem(ctx, storageType, id)
+	if err != nil {
+		return false, err
+	}
+	defer unlock()
+	if !t.due(store, id, cutoff) {
+		return false, nil
+	}
+	if err := t.tiers.Demote(tenant, storageType, keys); err != nil {
+		return false, err
+	}
+	if t.service.index != nil {
+		location := t.service.location(t.tiers.ColdTier(storageType), id)
+		if err := t.service.index.relocate(tenant, storageType, id, location); err != nil {
+			logging.Printf("Failed to record the move of %s to the cold tier in the index: %v", id, err)
+		}
+	}
+	return true, nil
+}
+
+func (t *TierTransitioner) Shutdown() {
+	if t.cancel != nil {
+		t.cancel()
+		<-t.done
+	}
+}
@@ -0,0 +1,166 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"interview-task/internal/config"
+	"interview-task/internal/storage"
+)
+
+// ErrTimeout is returned for an operation that ran past its deadline
+var ErrTimeout = errors.New("deadline exceeded")
+
+// Budgets - IMPLEMENTS deadlines for data operations, by operation or by
+// storage type
+type Budgets struct {
+	operations   map[string]time.Duration
+	storageTypes map[string]time.Duration
+}
+
+// NewBudgets returns nil without deadlines
+func NewBudgets(config config.TimeoutConfig) (*Budgets, error) {
+	if len(config.Operations) == 0 && len(config.StorageTypes) == 0 {
+		return nil, nil
+	}
+	b := &Budgets{operations: make(map[string]time.Duration), storageTypes: make(map[string]time.Duration)}
+	for operation, budget := range config.Operations {
+		if !slices.Contains(timedOperations, operation) {
+			return nil, fmt.Errorf("unknown operation: %q", operation)
+		}
+		if budget <= 0 {
+			return nil, fmt.Errorf("timeout of %s must be positive", operation)
+		}
+		b.operations[operation] = time.Duration(budget)
+	}
+	for storageType, budget := range config.StorageTypes {
+		if !slices.Contains(storage.StorageTypes(), storageType) {
+			return nil, fmt.Errorf("unknown storage type %q", storageType)
+		}
+		if budget <= 0 {
+			return nil, fmt.Errorf("timeout of %s must be positive", storageType)
+		}
+		b.storageTypes[storageType] = time.Duration(budget)
+	}
+	return b, nil
+}
+
+// Operations with a deadline of their own
+var timedOperations = []string{"save", "update", "get", "delete", "list", "search"}
+
+// budget is the deadline of an operation on a storage type; zero has none
+func (b *Budgets) budget(operation, storageType string) time.Duration {
+	if b == nil {
+		return 0
+	}
+	if budget, ok := b.storageTypes[storageType]; ok {
+		return budget
+	}
+	return b.operations[operation]
+}
+
+// withinBudget runs fn under the deadline of an operation on a storage
+// type. Storage calls can't be interrupted, so fn is left to finish in the
+// background once the deadline passes; its context is cancelled, stopping
+// what does heed it, such as waiting for a lock. A caller going away
+// doesn't abandon fn, as before deadlines existed.
+func withinBudget[T any](ctx context.Context, ds *DataService, operation, storageType string, fn func(context.Context) (T, error)) (T, error) {
+	noteBackend(ctx, storageType)
+	budget := ds.budgets.budget(operation, storageType)
+	if budget <= 0 {
+		return fn(ctx)
+	}
+	ctx, cancel := context.WithTimeout(ctx, budget)
+	type result struct {
+		value T
+		err   error
+	}
+	done := make(chan result, 1)
+	go func() {
+		defer cancel()
+		value, err := fn(ctx)
+		done <- result{value, err}
+	}()
+
+	select {
+	case r := <-done:
+		// An operation failing as its context expired, such as while
+		// waiting for a lock, ran out of time too
+		if r.err == nil || !errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return r.value, r.err
+		}
+	case <-ctx.Done():
+		if !errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			r := <-done
+			return r.value, r.err
+		}
+	}
+	var zero T
+	return zero, fmt.Errorf("%w: %s on %s took longer than %s", ErrTimeout, operation, storageType, budget)
+}
+
+// SaveData stores the request payload and returns the generated item ID
+func (ds *DataService) SaveData(ctx context.Context, req *SaveRequest) (string, error) {
+	return withinBudget(ctx, ds, "save", req.StorageType, func(ctx context.Context) (string, error) {
+		return ds.saveData(ctx, req)
+	})
+}
+
+// PutData stores the request payload as item id, which the caller derives
+// from its own naming. An existing item gets a new version.
+func (ds *DataService) PutData(ctx context.Context, id string, req *SaveRequest) error {
+	_, err := withinBudget(ctx, ds, "save", req.StorageType, func(ctx context.Context) (struct{}, error) {
+		return struct{}{}, ds.putData(ctx, id, req)
+	})
+	return err
+}
+
+// UpdateData stores a new version of an existing item. The previous payload
+// is retained so earlier versions stay readable.
+func (ds *DataService) UpdateData(ctx context.Context, id string, req *SaveRequest) (int, error) {
+	return withinBudget(ctx, ds, "update", req.StorageType, func(ctx context.Context) (int, error) {
+		return ds.updateData(ctx, id, req)
+	})
+}
+
+// GetData loads a previously saved payload from the given storage type
+func (ds *DataService) GetData(ctx context.Context, storageType, id string) (*StoredItem, error) {
+	return withinBudget(ctx, ds, "get", storageType, func(ctx context.Context) (*StoredItem, error) {
+		return ds.getData(ctx, storageType, id)
+	})
+}
+
+// GetDataAsOf returns the version of an item that was current at asOf
+func (ds *DataService) GetDataAsOf(ctx context.Context, storageType, id string, asOf time.Time) (*StoredItem, error) {
+	return withinBudget(ctx, ds, "get", storageType, func(ctx context.Context) (*StoredItem, error) {
+		return ds.getDataAsOf(ctx, storageType, id, asOf)
+	})
+}
+
+// DeleteData removes a stored item, or moves it to the trash when soft
+// deletes are enabled
+func (ds *DataService) DeleteData(ctx context.Context, storageType, id string) error {
+	_, err := withinBudget(ctx, ds, "delete", storageType, func(ctx context.Context) (struct{}, error) {
+		return struct{}{}, ds.deleteData(ctx, storageType, id)
+	})
+	return err
+}
+
+// Search ranks the tenant's available items against the query, most
+// relevant first
+func (ds *DataService) Search(ctx context.Context, storageType string, query SearchQuery) (*SearchResult, error) {
+	return withinBudget(ctx, ds, "search", storageType, func(ctx context.Context) (*SearchResult, error) {
+		return ds.search(ctx, storageType, query)
+	})
+}
+
+// FindItems returns the tenant's available items whose metadata matches the
+// filter, sorted by ID
+func (ds *DataService) FindItems(ctx context.Context, storageType string, filter ItemFilter) ([]ItemSummary, error) {
+	return withinBudget(ctx, ds, "list", storageType, func(ctx context.Context) ([]ItemSummary, error) {
+		return ds.findItems(ctx, storageType, filter)
+	})
+}
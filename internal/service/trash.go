@@ -0,0 +1,243 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"interview-task/internal/logging"
+	"interview-task/internal/metrics"
+	"interview-task/internal/storage"
+)
+
+// Audit actions recorded for the trash
+const (
+	AuditActionUndelete = "undelete"
+	AuditActionPurge    = "purge"
+)
+
+// TrashEntry describes a soft-deleted item
+type TrashEntry struct {
+	ID         string    `json:"id"`
+	Size       int       `json:"size"`
+	DeletedAt  time.Time `json:"deleted_at"`
+	DeletedBy  string    `json:"deleted_by"`
+	PurgeAfter time.Time `json:"purge_after"`
+	// LegalHold, on the item or its tenant, keeps it from being purged
+	LegalHold *LegalHold `json:"legal_hold,omitempty"`
+}
+
+// moveToTrash tombstones an item in its metadata; the payload, versions and
+// derivations stay in place until it is purged
+func (ds *DataService) moveToTrash(ctx context.Context, storage storage.StorageInterface, storageType, id string, record *ItemMetadata) error {
+	record.DeletedAt = time.Now().UTC()
+	record.DeletedBy = PrincipalFromContext(ctx).Name
+	if err := ds.saveMetadata(ctx, storage, storageType, id, record); err != nil {
+		return fmt.Errorf("failed to move %s to the trash: %w", id, err)
+	}
+	ds.watermarks.Advance(TenantFromContext(ctx), AuditActionDelete)
+	return nil
+}
+
+// trashed loads the metadata of an item in the trash
+func (ds *DataService) trashed(store storage.StorageInterface, id string) (*ItemMetadata, error) {
+	record, err := ds.loadMetadata(store, id)
+	if errors.Is(err, storage.ErrNotFound) || (err == nil && record.DeletedAt.IsZero()) {
+		return nil, fmt.Errorf("%s is not in the trash: %w", id, storage.ErrNotFound)
+	}
+	return record, err
+}
+
+// RestoreItem takes an item out of the trash within the grace period
+func (ds *DataService) RestoreItem(ctx context.Context, storageType, id string) (err error) {
+	if err := ds.maintenance.check(); err != nil {
+		return err
+	}
+	defer func() {
+		ds.recordAudit(ctx, AuditActionUndelete, storageType, id, nil, err)
+	}()
+
+	if err := ds.validator.ValidateID(id); err != nil {
+		return fmt.Errorf("%w: %w", ErrValidation, err)
+	}
+	ctx, unlock, err := ds.lockItem(ctx, storageType, id)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	store, err := ds.openStorage(ctx, storageType)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrValidation, err)
+	}
+
+	record, err := ds.trashed(store, id)
+	if err != nil {
+		return err
+	}
+	if ds.purgeAfter(record).Before(time.Now()) {
+		return fmt.Errorf("%w: the grace period for %s has passed", storage.ErrConflict, id)
+	}
+	record.DeletedAt = time.Time{}
+	record.DeletedBy = ""
+	if err := ds.saveMetadata(ctx, store, storageType, id, record); err != nil {
+		return err
+	}
+	ds.watermarks.Advance(TenantFromContext(ctx), AuditActionUndelete)
+	return nil
+}
+
+func (ds *DataService) purgeAfter(record *ItemMetadata) time.Time {
+	return record.DeletedAt.Add(time.Duration(ds.softDelete.GracePeriod))
+}
+
+// ListTrash returns the tenant's soft-deleted items, sorted by ID
+func (ds *DataService) ListTrash(ctx context.Context, storageType string) ([]TrashEntry, error) {
+	ids, err := ds.ListItems(ctx, storageType)
+	if err != nil {
+		return nil, err
+	}
+	storage, err := ds.openStorage(ctx, storageType)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrValidation, err)
+	}
+
+	entries := []TrashEntry{}
+	for _, id := range ids {
+		record, err := ds.loadMetadata(storage, id)
+		if err != nil || record.DeletedAt.IsZero() {
+			continue
+		}
+		entries = append(entries, TrashEntry{
+			ID:         id,
+			Size:       record.Size,
+			DeletedAt:  record.DeletedAt,
+			DeletedBy:  record.DeletedBy,
+			PurgeAfter: ds.purgeAfter(record),
+			LegalHold:  ds.legalHold(ctx, record),
+		})
+	}
+	return entries, nil
+}
+
+// PurgeItem permanently removes an item from the trash
+func (ds *DataService) PurgeItem(ctx context.Context, storageType, id string) error {
+	return ds.deleteItem(ctx, AuditActionPurge, storageType, id)
+}
+
+// PurgeTrashOperation permanently removes trashed items: the comma-separated
+// ids, or the tenant's whole trash in the storage type when none are given
+func PurgeTrashOperation(service *DataService) AdminOperation {
+	return func(ctx context.Context, params map[string]string) (any, error) {
+		storageType := params["storage_type"]
+		var ids []string
+		if params["ids"] != "" {
+			ids = strings.Split(params["ids"], ",")
+		} else {
+			entries, err := service.ListTrash(ctx, storageType)
+			if err != nil {
+				return nil, err
+			}
+			for _, entry := range entries {
+				ids = append(ids, entry.ID)
+			}
+		}
+		return applyToItems(ids, "purges", func(id string) error {
+			return service.PurgeItem(ctx, storageType, id)
+		})
+	}
+}
+
+// purgerPrincipal is the actor recorded for scheduled purges
+var purgerPrincipal = &Principal{Name: "trash-purger"}
+
+// TrashPurger - IMPLEMENTS the scheduled purge of trashed items whose grace
+// period has passed
+type TrashPurger struct {
+	service  *DataService
+	factory  storage.TenantLister
+	interval time.Duration
+	metrics  *metrics.Metrics
+	leader   *LeaderElector
+
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+func NewTrashPurger(service *DataService, factory storage.TenantLister, interval time.Duration, metrics *metrics.Metrics, leader *LeaderElector) *TrashPurger {
+	metrics.Describe("items_purged_total", "Trashed items purged after their grace period")
+	return &TrashPurger{service: service, factory: factory, interval: interval, metrics: metrics, leader: leader}
+}
+
+// Start runs the purge job every interval until Shutdown
+func (p *TrashPurger) Start() {
+	if p.interval <= 0 || !p.service.softDelete.Enabled {
+		return
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	p.cancel, p.done = cancel, make(chan struct{})
+	go p.run(ctx, p.done)
+}
+
+func (p *TrashPurger) run(ctx context.Context, done chan struct{}) {
+	defer close(done)
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		// Only the elected instance runs scheduled jobs
+		if !p.leader.IsLeader() {
+			continue
+		}
+		if err := p.Purge(ctx); err != nil && !errors.Is(err, context.Canceled) {
+			logging.Printf("Trash purge failed: %v", err)
+		}
+	}
+}
+
+// Purge removes every trashed item past its grace period
+func (p *TrashPurger) Purge(ctx context.Context) error {
+	if p.service.maintenance.Enabled() {
+		// Purging waits until maintenance is over
+		return nil
+	}
+	ctx = WithPrincipal(ctx, purgerPrincipal)
+	return storage.EachTenant(p.factory, func(tenant, storageType string) error {
+		ctx := WithTenant(ctx, tenant)
+		entries, err := p.service.ListTrash(ctx, storageType)
+		if err != nil {
+			return err
+		}
+		now := time.Now()
+		for _, entry := range entries {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			// Held items are purged once the hold is lifted
+			if entry.PurgeAfter.After(now) || entry.LegalHold != nil {
+				continue
+			}
+			err := p.service.PurgeItem(ctx, storageType, entry.ID)
+			switch {
+			case errors.Is(err, storage.ErrNotFound):
+			case err != nil:
+				logging.Printf("Failed to purge %s: %v", entry.ID, err)
+			default:
+				p.metrics.Add("items_purged_total", 1, "tenant", tenant, "storage_type", storageType)
+			}
+		}
+		return nil
+	})
+}
+
+func (p *TrashPurger) Shutdown() {
+	if p.cancel != nil {
+		p.cancel()
+		<-p.done
+	}
+}
@@ -0,0 +1,147 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+
+	"interview-task/internal/config"
+)
+
+// Validator checks a save request before anything is stored
+type Validator interface {
+	Validate(ctx context.Context, req *SaveRequest) error
+}
+
+// ValidatorFunc adapts a plain function to the Validator interface
+type ValidatorFunc func(ctx context.Context, req *SaveRequest) error
+
+func (f ValidatorFunc) Validate(ctx context.Context, req *SaveRequest) error {
+	return f(ctx, req)
+}
+
+// ValidatorFactory builds a validator from its config entry
+type ValidatorFactory func(config config.ValidatorConfig) (Validator, error)
+
+var (
+	validatorsMu       sync.RWMutex
+	validatorFactories = map[string]ValidatorFactory{
+		"size":         newSizeValidator,
+		"schema":       newSchemaValidator,
+		"content_type": newContentTypeValidator,
+	}
+)
+
+// RegisterValidator makes a validator type available to the config. Call it
+// before the server is created; registering a name twice replaces it.
+func RegisterValidator(name string, factory ValidatorFactory) {
+	validatorsMu.Lock()
+	defer validatorsMu.Unlock()
+	validatorFactories[name] = factory
+}
+
+// NewValidatorsFromConfig assembles the pipeline in config order. Without
+// explicit config, payload schemas are still enforced.
+func NewValidatorsFromConfig(configs []config.ValidatorConfig, schemas []config.PayloadSchemaConfig) ([]Validator, error) {
+	if len(configs) == 0 {
+		configs = []config.ValidatorConfig{{Type: "schema"}}
+	}
+
+	validators := make([]Validator, 0, len(configs))
+	for _, config := range configs {
+		if config.Type == "schema" && len(config.Schemas) == 0 {
+			config.Schemas = schemas
+		}
+
+		validatorsMu.RLock()
+		factory, ok := validatorFactories[config.Type]
+		validatorsMu.RUnlock()
+		if !ok {
+			return nil, fmt.Errorf("unknown validator type: %q", config.Type)
+		}
+		validator, err := factory(config)
+		if err != nil {
+			return nil, fmt.Errorf("validator %s: %w", config.Type, err)
+		}
+		if len(config.StorageTypes) > 0 {
+			validator = scopedValidator{storageTypes: config.StorageTypes, next: validator}
+		}
+		validators = append(validators, validator)
+	}
+	return validators, nil
+}
+
+// scopedValidator only runs for the listed storage types
+type scopedValidator struct {
+	storageTypes []string
+	next         Validator
+}
+
+func (v scopedValidator) Validate(ctx context.Context, req *SaveRequest) error {
+	if !slices.Contains(v.storageTypes, req.StorageType) {
+		return nil
+	}
+	return v.next.Validate(ctx, req)
+}
+
+func newSizeValidator(config config.ValidatorConfig) (Validator, error) {
+	if config.MaxBytes <= 0 {
+		return nil, errors.New("max_bytes must be positive")
+	}
+	return ValidatorFunc(func(ctx context.Context, req *SaveRequest) error {
+		if len(req.Data) > config.MaxBytes {
+			return fmt.Errorf("payload is %d bytes, limit is %d", len(req.Data), config.MaxBytes)
+		}
+		return nil
+	}), nil
+}
+
+// schemaValidator checks the payload against every schema configured for its
+// storage type and endpoint, reporting all violations together
+type schemaValidator struct {
+	schemas []payloadSchema
+}
+
+func newSchemaValidator(config config.ValidatorConfig) (Validator, error) {
+	compiled, err := loadPayloadSchemas(config.Schemas)
+	if err != nil {
+		return nil, err
+	}
+	return &schemaValidator{schemas: compiled}, nil
+}
+
+func (v *schemaValidator) Validate(ctx context.Context, req *SaveRequest) error {
+	endpoint := endpointFromContext(ctx)
+	var fields []FieldError
+	for i := range v.schemas {
+		if !v.schemas[i].appliesTo(req.StorageType, endpoint) {
+			continue
+		}
+		var schemaErr *SchemaValidationError
+		if err := v.schemas[i].schema.Validate(req.Data); errors.As(err, &schemaErr) {
+			fields = append(fields, schemaErr.Fields...)
+		}
+	}
+	if len(fields) > 0 {
+		return &SchemaValidationError{Fields: fields}
+	}
+	return nil
+}
+
+func newContentTypeValidator(config config.ValidatorConfig) (Validator, error) {
+	if len(config.ContentTypes) == 0 {
+		return nil, errors.New("content_types cannot be empty")
+	}
+	// The structural checks have already resolved req.ContentType
+	return ValidatorFunc(func(ctx context.Context, req *SaveRequest) error {
+		allowed := slices.ContainsFunc(config.ContentTypes, func(pattern string) bool {
+			return matchesMediaType(pattern, req.ContentType)
+		})
+		if !allowed {
+			return fmt.Errorf("content type %s is not allowed", req.ContentType)
+		}
+		return nil
+	}), nil
+}
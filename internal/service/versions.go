@@ -0,0 +1,266 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"interview-task/internal/logging"
+	"interview-task/internal/storage"
+)
+
+// VersionEntry describes one stored version of an item
+type VersionEntry struct {
+	Version     int       `json:"version"`
+	CreatedAt   time.Time `json:"created_at"`
+	Size        int       `json:"size"`
+	SHA256      string    `json:"sha256"`
+	ContentType string    `json:"content_type,omitempty"`
+}
+
+func newVersionEntry(version int, payload []byte, contentType string) VersionEntry {
+	return VersionEntry{
+		Version:     version,
+		CreatedAt:   time.Now().UTC(),
+		Size:        len(payload),
+		SHA256:      storage.PayloadSHA256(payload),
+		ContentType: contentType,
+	}
+}
+
+// The current payload stays under the item ID; earlier versions and the
+// history are linked records next to it
+func versionsItemID(id string) string {
+	return id + "~versions"
+}
+
+func versionPayloadID(id string, version int) string {
+	return fmt.Sprintf("%s~v%d", id, version)
+}
+
+// isReservedLinkName reports whether a linked record suffix is used by the
+// service itself and therefore unavailable to derivations
+func isReservedLinkName(name string) bool {
+	return name == "metadata" || name == "versions" || name == "blob" || storage.VersionLinkName.MatchString(name)
+}
+
+// loadVersions returns an item's history, oldest first. Items stored before
+// versioning existed are reported as a single version.
+func (ds *DataService) loadVersions(store storage.StorageInterface, id string) ([]VersionEntry, error) {
+	encoded, err := store.Load(versionsItemID(id))
+	if err == nil {
+		var versions []VersionEntry
+		if err := json.Unmarshal(encoded, &versions); err != nil {
+			return nil, fmt.Errorf("corrupt version history for %s: %w", id, err)
+		}
+		return versions, nil
+	}
+	if !errors.Is(err, storage.ErrNotFound) {
+		return nil, fmt.Errorf("failed to load version history: %w", err)
+	}
+
+	payload, err := store.Load(id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load data: %w", err)
+	}
+	entry := newVersionEntry(1, payload, "")
+	entry.CreatedAt = time.Time{}
+	if record, err := ds.loadMetadata(store, id); err == nil {
+		entry.CreatedAt = record.IndexedAt
+		entry.ContentType = record.ContentType
+	}
+	return []VersionEntry{entry}, nil
+}
+
+func (ds *DataService) saveVersions(storage storage.StorageInterface, id string, versions []VersionEntry) error {
+	encoded, err := json.Marshal(versions)
+	if err != nil {
+		return fmt.Errorf("failed to encode version history: %w", err)
+	}
+	if err := storage.Save(versionsItemID(id), encoded); err != nil {
+		return fmt.Errorf("failed to save version history: %w", err)
+	}
+	return nil
+}
+
+// deleteVersions removes every retained version and the history itself
+func (ds *DataService) deleteVersions(store storage.StorageInterface, id string) {
+	encoded, err := store.Load(versionsItemID(id))
+	if err != nil {
+		return
+	}
+	var versions []VersionEntry
+	json.Unmarshal(encoded, &versions)
+
+	keys := []string{versionsItemID(id)}
+	for _, version := range versions {
+		keys = append(keys, versionPayloadID(id, version.Version))
+	}
+	for _, key := range keys {
+		if err := store.Delete(key); err != nil && !errors.Is(err, storage.ErrNotFound) {
+			logging.Printf("Failed to delete %s: %v", key, err)
+		}
+	}
+}
+
+// storedBytes is what all versions of an item occupy
+func storedBytes(versions []VersionEntry) int64 {
+	var total int64
+	for _, version := range versions {
+		total += int64(version.Size)
+	}
+	return total
+}
+
+// updateData stores a new version of an existing item. The previous payload
+// is retained so earlier versions stay readable.
+func (ds *DataService) updateData(ctx context.Context, id string, req *SaveRequest) (version int, err error) {
+	if err := ds.maintenance.check(); err != nil {
+		return 0, err
+	}
+	defer func() {
+		ds.recordAudit(ctx, AuditActionUpdate, req.StorageType, id, req.Data, err)
+		ds.hooks.afterSave(ctx, AuditActionUpdate, id, req, err)
+	}()
+
+	if err := ds.validator.ValidateID(id); err != nil {
+		return 0, fmt.Errorf("%w: %w", ErrValidation, err)
+	}
+	if err := ds.checkAccess(ctx, req.StorageType); err != nil {
+		return 0, err
+	}
+	if err := ds.hooks.beforeSave(ctx, AuditActionUpdate, id, req); err != nil {
+		return 0, fmt.Errorf("%w: %w", ErrValidation, err)
+	}
+	if err := ds.validator.ValidateRequest(ctx, req); err != nil {
+		return 0, fmt.Errorf("%w: %w", ErrValidation, err)
+	}
+	if err := ds.sealFields(req); err != nil {
+		return 0, err
+	}
+	ctx, unlock, err := ds.lockItem(ctx, req.StorageType, id)
+	if err != nil {
+		return 0, err
+	}
+	defer unlock()
+
+	storage, err := ds.openStorage(ctx, req.StorageType)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create storage: %w", err)
+	}
+
+	versions, err := ds.loadVersions(storage, id)
+	if err != nil {
+		return 0, err
+	}
+	current, err := storage.Load(id)
+	if err != nil {
+		return 0, fmt.Errorf("failed to load data: %w", err)
+	}
+	base := ItemMetadata{}
+	if record, err := ds.loadMetadata(storage, id); err == nil {
+		base = *record
+	}
+	if err := base.unavailable(id, time.Now()); err != nil {
+		return 0, err
+	}
+	if err := checkIfMatch(ifMatchFromContext(ctx), id, &versions[len(versions)-1]); err != nil {
+		return 0, err
+	}
+	// The expiry is kept unless the update asks for a new TTL
+	if req.TTL != 0 {
+		if base.ExpiresAt, err = ds.expiry.ExpiresAt(req.TTL, time.Now()); err != nil {
+			return 0, fmt.Errorf("%w: %w", ErrValidation, err)
+		}
+	}
+	if req.Labels != nil {
+		base.Labels = req.Labels
+	}
+	if req.Source != "" {
+		base.Source = req.Source
+	}
+	owner := base.Owner
+	base.ContentType = req.ContentType
+	base.ModifiedAt = time.Now().UTC()
+	base.KeyVersion = ds.keyVersion(req.StorageType)
+
+	// Retained versions count against quota, so only bytes are added
+	tenant, size := TenantFromContext(ctx), int64(len(req.Data))
+	if err := ds.quotas.Reserve(tenant, owner, size, 0); err != nil {
+		return 0, err
+	}
+
+	latest := versions[len(versions)-1].Version
+	if err := storage.Save(versionPayloadID(id, latest), current); err != nil {
+		ds.quotas.Release(tenant, owner, size, 0)
+		return 0, fmt.Errorf("failed to retain version %d: %w", latest, err)
+	}
+	if err := storage.Save(id, req.Data); err != nil {
+		ds.quotas.Release(tenant, owner, size, 0)
+		return 0, fmt.Errorf("failed to save data: %w", err)
+	}
+
+	versions = append(versions, newVersionEntry(latest+1, req.Data, req.ContentType))
+	if err := ds.saveVersions(storage, id, versions); err != nil {
+		logging.Printf("Failed to record version %d of %s: %v", latest+1, id, err)
+	}
+	if err := ds.indexMetadata(ctx, storage, req.StorageType, id, req.Data, base); err != nil {
+		logging.Printf("Failed to index metadata for %s: %v", id, err)
+	}
+
+	// Derivations of the old payload are stale now
+	ds.deleteDerived(storage, id)
+	ds.materializeEager(storage, id, req.Data)
+	ds.watermarks.Advance(tenant, AuditActionUpdate)
+	return latest + 1, nil
+}
+
+// getDataAsOf returns the version of an item that was current at asOf
+func (ds *DataService) getDataAsOf(ctx context.Context, storageType, id string, asOf time.Time) (*StoredItem, error) {
+	if err := ds.validator.ValidateID(id); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrValidation, err)
+	}
+
+	store, err := ds.openStorage(ctx, storageType)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrValidation, err)
+	}
+	if err := ds.checkAvailable(store, id); err != nil {
+		return nil, err
+	}
+
+	versions, err := ds.loadVersions(store, id)
+	if err != nil {
+		return nil, err
+	}
+
+	current := -1
+	for i, version := range versions {
+		if version.CreatedAt.After(asOf) {
+			break
+		}
+		current = i
+	}
+	if current == -1 {
+		return nil, fmt.Errorf("no version of %s existed at %s: %w", id, asOf.Format(time.RFC3339), storage.ErrNotFound)
+	}
+
+	key := id
+	if current < len(versions)-1 {
+		key = versionPayloadID(id, versions[current].Version)
+	}
+	data, err := store.Load(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load version %d: %w", versions[current].Version, err)
+	}
+	item := &StoredItem{Data: data, ContentType: versions[current].ContentType, SHA256: versions[current].SHA256, ETag: versions[current].ETag()}
+	if item.ContentType == "" {
+		item.ContentType = "application/octet-stream"
+	}
+	if err := ds.openFields(ctx, item); err != nil {
+		return nil, err
+	}
+	return item, nil
+}
@@ -0,0 +1,109 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"interview-task/internal/logging"
+	"interview-task/internal/storage"
+)
+
+// Watermark is a tenant's materialized change counter. Sync jobs compare
+// Sequence with the value from their last pull to decide whether anything
+// changed at all.
+type Watermark struct {
+	Tenant       string    `json:"tenant"`
+	Sequence     uint64    `json:"sequence"`
+	LastChangeAt time.Time `json:"last_change_at"`
+	// LastItemAt is when an item was last saved or updated
+	LastItemAt time.Time `json:"last_item_at"`
+	Items      int64     `json:"items"`
+	Saves      int64     `json:"saves"`
+	Updates    int64     `json:"updates"`
+	Deletes    int64     `json:"deletes"`
+}
+
+// WatermarkTracker - IMPLEMENTS per-tenant change counters, persisted to a
+// file so sequences never go backwards across restarts
+type WatermarkTracker struct {
+	file string
+
+	mu    sync.Mutex
+	marks map[string]*Watermark
+}
+
+func NewWatermarkTracker(file string) (*WatermarkTracker, error) {
+	t := &WatermarkTracker{file: file, marks: make(map[string]*Watermark)}
+	if file == "" {
+		return t, nil
+	}
+
+	data, err := os.ReadFile(file)
+	if errors.Is(err, os.ErrNotExist) {
+		return t, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read watermark file: %w", err)
+	}
+	if err := json.Unmarshal(data, &t.marks); err != nil {
+		return nil, fmt.Errorf("failed to parse watermark file: %w", err)
+	}
+	return t, nil
+}
+
+// Advance records a successful mutation of one of the tenant's items
+func (t *WatermarkTracker) Advance(tenant, action string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	mark, ok := t.marks[tenant]
+	if !ok {
+		mark = &Watermark{Tenant: tenant}
+		t.marks[tenant] = mark
+	}
+	now := time.Now().UTC()
+	mark.Sequence++
+	mark.LastChangeAt = now
+	switch action {
+	case AuditActionSave:
+		mark.Saves++
+		mark.Items++
+		mark.LastItemAt = now
+	case AuditActionUpdate:
+		mark.Updates++
+		mark.LastItemAt = now
+	case AuditActionUndelete:
+		mark.Items++
+	case AuditActionDelete, AuditActionExpire, AuditActionRetention, AuditActionErase:
+		mark.Deletes++
+		mark.Items = max(mark.Items-1, 0)
+	}
+	t.persistLocked()
+}
+
+// Get returns the tenant's current watermark; unknown tenants are at zero
+func (t *WatermarkTracker) Get(tenant string) Watermark {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if mark, ok := t.marks[tenant]; ok {
+		return *mark
+	}
+	return Watermark{Tenant: tenant}
+}
+
+func (t *WatermarkTracker) persistLocked() {
+	if t.file == "" {
+		return
+	}
+	data, err := json.Marshal(t.marks)
+	if err == nil {
+		err = storage.WriteFileAtomic(t.file, data)
+	}
+	if err != nil {
+		logging.Printf("Failed to persist watermarks: %v", err)
+	}
+}
@@ -0,0 +1,335 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"os"
+	"slices"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"interview-task/internal/config"
+	"interview-task/internal/logging"
+	"interview-task/internal/metrics"
+)
+
+func endpointMatches(e *config.WebhookEndpoint, event *StorageEvent) bool {
+	return (len(e.Tenants) == 0 || slices.Contains(e.Tenants, event.Tenant)) &&
+		(len(e.StorageTypes) == 0 || slices.Contains(e.StorageTypes, event.StorageType))
+}
+
+// WebhookPayload is the body POSTed to webhook endpoints
+type WebhookPayload struct {
+	// ID identifies the delivery; it is the same on every attempt, so
+	// receivers can discard duplicates
+	ID          string    `json:"id"`
+	Event       string    `json:"event"`
+	Time        time.Time `json:"time"`
+	Tenant      string    `json:"tenant"`
+	StorageType string    `json:"storage_type"`
+	ItemID      string    `json:"item_id"`
+	Size        int       `json:"size"`
+	SHA256      string    `json:"sha256"`
+}
+
+// webhookDelivery is a payload on its way to one endpoint
+type webhookDelivery struct {
+	endpoint *config.WebhookEndpoint
+	payload  WebhookPayload
+	attempts int
+	lastErr  string
+}
+
+// deadLetter is a delivery that failed for good, as written to the
+// dead-letter file
+type deadLetter struct {
+	Time     time.Time      `json:"time"`
+	URL      string         `json:"url"`
+	Attempts int            `json:"attempts"`
+	Error    string         `json:"error"`
+	Payload  WebhookPayload `json:"payload"`
+}
+
+// WebhookDispatcher - IMPLEMENTS signed, retried delivery of save events to
+// the configured endpoints. Deliveries are queued from the event bus
+// without blocking saves, retried with exponential backoff and jitter, and
+// dead-lettered once attempts run out, the queue is full or the server
+// shuts down with deliveries outstanding.
+type WebhookDispatcher struct {
+	config  config.WebhookConfig
+	metrics *metrics.Metrics
+	client  *http.Client
+	queue   chan *webhookDelivery
+
+	// outstanding counts deliveries not yet made or dead-lettered
+	outstanding atomic.Int64
+
+	mu       sync.Mutex
+	retrying map[*webhookDelivery]*time.Timer
+	stopped  bool
+	cancel   context.CancelFunc
+	workers  sync.WaitGroup
+}
+
+func NewWebhookDispatcher(config config.WebhookConfig, bus *EventBus, metrics *metrics.Metrics) *WebhookDispatcher {
+	metrics.Describe("webhook_deliveries_total", "Webhook delivery attempts by outcome")
+	metrics.Describe("webhook_dead_letters_total", "Webhook deliveries that failed for good")
+	d := &WebhookDispatcher{
+		config:   config,
+		metrics:  metrics,
+		client:   &http.Client{Timeout: time.Duration(config.Timeout)},
+		queue:    make(chan *webhookDelivery, max(config.QueueSize, 1)),
+		retrying: make(map[*webhookDelivery]*time.Timer),
+	}
+	metrics.GaugeFunc("webhook_queue_length", "Webhook deliveries waiting for a worker", func() int64 {
+		return int64(len(d.queue))
+	})
+	if len(config.Endpoints) > 0 {
+		bus.Listen(d.notify)
+	}
+	return d
+}
+
+// Start runs the delivery workers
+func (d *WebhookDispatcher) Start() {
+	if len(d.config.Endpoints) == 0 {
+		return
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	d.cancel = cancel
+	for range d.config.Workers {
+		d.workers.Add(1)
+		go d.run(ctx)
+	}
+}
+
+// notify queues a delivery of a save to every matching endpoint
+func (d *WebhookDispatcher) notify(event StorageEvent) {
+	if event.Type != AuditActionSave {
+		return
+	}
+	for i := range d.config.Endpoints {
+		endpoint := &d.config.Endpoints[i]
+		if !endpointMatches(endpoint, &event) {
+			continue
+		}
+		id, _ := NewItemID()
+		d.outstanding.Add(1)
+		d.enqueue(&webhookDelivery{endpoint: endpoint, payload: WebhookPayload{
+			ID:          id,
+			Event:       event.Type,
+			Time:        event.Time,
+			Tenant:      event.Tenant,
+			StorageType: event.StorageType,
+			ItemID:      event.ItemID,
+			Size:        event.Size,
+			SHA256:      event.PayloadSHA256,
+		}})
+	}
+}
+
+func (d *WebhookDispatcher) enqueue(delivery *webhookDelivery) {
+	// Queueing under the lock keeps deliveries from arriving after
+	// Shutdown has drained the queue
+	d.mu.Lock()
+	reason := ""
+	if d.stopped {
+		reason = "server shut down before delivery"
+	} else {
+		select {
+		case d.queue <- delivery:
+		default:
+			reason = "delivery queue is full"
+		}
+	}
+	d.mu.Unlock()
+	if reason != "" {
+		d.deadLetter(delivery, reason)
+	}
+}
+
+func (d *WebhookDispatcher) run(ctx context.Context) {
+	defer d.workers.Done()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case delivery := <-d.queue:
+			d.attempt(ctx, delivery)
+		}
+	}
+}
+
+// attempt makes one delivery attempt and schedules a retry if it may
+// still succeed
+func (d *WebhookDispatcher) attempt(ctx context.Context, delivery *webhookDelivery) {
+	delivery.attempts++
+	retryable, err := d.deliver(ctx, delivery)
+	if err == nil {
+		d.metrics.Add("webhook_deliveries_total", 1, "outcome", "success")
+		d.outstanding.Add(-1)
+		return
+	}
+	delivery.lastErr = err.Error()
+	if !retryable || delivery.attempts >= d.config.MaxAttempts {
+		d.metrics.Add("webhook_deliveries_total", 1, "outcome", "failure")
+		d.deadLetter(delivery, delivery.lastErr)
+		return
+	}
+	d.metrics.Add("webhook_deliveries_total", 1, "outcome", "retry")
+
+	d.mu.Lock()
+	stopped := d.stopped
+	if !stopped {
+		d.retrying[delivery] = time.AfterFunc(d.backoff(delivery.attempts), func() {
+			d.mu.Lock()
+			_, pending := d.retrying[delivery]
+			delete(d.retrying, delivery)
+			d.mu.Unlock()
+			if pending {
+				d.enqueue(delivery)
+			}
+		})
+	}
+	d.mu.Unlock()
+	if stopped {
+		// Shutdown has already collected the outstanding deliveries
+		d.deadLetter(delivery, "server shut down before delivery")
+	}
+}
+
+// backoff doubles from InitialBackoff per attempt, with up to 50% jitter so
+// retries of a burst don't arrive together
+func (d *WebhookDispatcher) backoff(attempts int) time.Duration {
+	delay := time.Duration(d.config.InitialBackoff)
+	for i := 1; i < attempts && delay < time.Duration(d.config.MaxBackoff); i++ {
+		delay *= 2
+	}
+	delay = min(delay, time.Duration(d.config.MaxBackoff))
+	return delay/2 + rand.N(delay/2+1)
+}
+
+// deliver POSTs the payload. Network errors, 5xx, 408 and 429 are
+// retryable; other statuses mean the endpoint rejected the delivery.
+func (d *WebhookDispatcher) deliver(ctx context.Context, delivery *webhookDelivery) (retryable bool, err error) {
+	body, err := json.Marshal(delivery.payload)
+	if err != nil {
+		return false, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, delivery.endpoint.URL, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "interview-task-webhooks")
+	req.Header.Set("X-Webhook-ID", delivery.payload.ID)
+	req.Header.Set("X-Webhook-Event", delivery.payload.Event)
+	req.Header.Set("X-Webhook-Signature", "t="+timestamp+",v1="+signWebhook(delivery.endpoint.Secret, timestamp, body))
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return true, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode < 300:
+		return false, nil
+	case resp.StatusCode >= 500, resp.StatusCode == http.StatusRequestTimeout, resp.StatusCode == http.StatusTooManyRequests:
+		return true, fmt.Errorf("endpoint returned %s", resp.Status)
+	default:
+		return false, fmt.Errorf("endpoint returned %s", resp.Status)
+	}
+}
+
+// signWebhook returns the hex HMAC-SHA256 of "timestamp.body". Receivers
+// recompute it and reject old timestamps to prevent replays.
+func signWebhook(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func (d *WebhookDispatcher) deadLetter(delivery *webhookDelivery, reason string) {
+	d.outstanding.Add(-1)
+	d.metrics.Add("webhook_dead_letters_total", 1)
+	logging.Printf("Webhook delivery %s to %s failed after %d attempts: %s", delivery.payload.ID, delivery.endpoint.URL, delivery.attempts, reason)
+	if d.config.DeadLetterFile == "" {
+		return
+	}
+	line, err := json.Marshal(deadLetter{
+		Time:     time.Now().UTC(),
+		URL:      delivery.endpoint.URL,
+		Attempts: delivery.attempts,
+		Error:    reason,
+		Payload:  delivery.payload,
+	})
+	if err != nil {
+		return
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	file, err := os.OpenFile(d.config.DeadLetterFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		logging.Printf("Failed to open webhook dead-letter file: %v", err)
+		return
+	}
+	defer file.Close()
+	if _, err := file.Write(append(line, '\n')); err != nil {
+		logging.Printf("Failed to write webhook dead letter: %v", err)
+	}
+}
+
+// Drain waits until every delivery has been made or dead-lettered, retries
+// included, or until ctx is done. Deliveries still outstanding are left to
+// Shutdown.
+func (d *WebhookDispatcher) Drain(ctx context.Context) error {
+	ticker := time.NewTicker(100 * time.Millisecond)
+	defer ticker.Stop()
+	for d.outstanding.Load() > 0 {
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("%d webhook deliveries outstanding: %w", d.outstanding.Load(), ctx.Err())
+		case <-ticker.C:
+		}
+	}
+	return nil
+}
+
+// Shutdown stops the workers and dead-letters every delivery not yet made,
+// so none is lost silently
+func (d *WebhookDispatcher) Shutdown() {
+	d.mu.Lock()
+	d.stopped = true
+	var outstanding []*webhookDelivery
+	for delivery, timer := range d.retrying {
+		timer.Stop()
+		outstanding = append(outstanding, delivery)
+	}
+	clear(d.retrying)
+	d.mu.Unlock()
+
+	if d.cancel != nil {
+		d.cancel()
+	}
+	d.workers.Wait()
+	for len(d.queue) > 0 {
+		outstanding = append(outstanding, <-d.queue)
+	}
+	for _, delivery := range outstanding {
+		d.deadLetter(delivery, "server shut down before delivery")
+	}
+}
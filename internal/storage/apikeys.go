@@ -0,0 +1,110 @@
+package storage
+
+import (
+	"crypto/sha256"
+	"errors"
+	"maps"
+	"slices"
+	"strings"
+	"time"
+)
+
+// ErrKeyConflict is returned when a key's name or secret is already taken
+var ErrKeyConflict = errors.New("api key already exists")
+
+// APIKeyRecord is a row of the api_keys table. The key itself is never
+// stored, only its SHA-256.
+type APIKeyRecord struct {
+	Name      string
+	KeySHA256 [sha256.Size]byte
+	Roles     []string
+	Tenant    string
+	// RateLimit is in requests per second; zero is unlimited
+	RateLimit  float64
+	Burst      int
+	MaxBytes   int64
+	MaxObjects int64
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+}
+
+// ListAPIKeys returns every managed key ordered by name
+func (db *DatabaseConnection) ListAPIKeys() ([]APIKeyRecord, error) {
+	var records []APIKeyRecord
+	err := db.do(func() error {
+		db.mu.RLock()
+		defer db.mu.RUnlock()
+		records = slices.SortedFunc(maps.Values(db.apiKeys), func(a, b APIKeyRecord) int {
+			return strings.Compare(a.Name, b.Name)
+		})
+		return nil
+	})
+	return records, err
+}
+
+// GetAPIKey returns the key named name, or ErrNotFound
+func (db *DatabaseConnection) GetAPIKey(name string) (*APIKeyRecord, error) {
+	var record *APIKeyRecord
+	err := db.do(func() error {
+		db.mu.RLock()
+		defer db.mu.RUnlock()
+		current, ok := db.apiKeys[name]
+		if !ok {
+			return ErrNotFound
+		}
+		record = &current
+		return nil
+	})
+	return record, err
+}
+
+// InsertAPIKey adds a key, failing with ErrKeyConflict if its name or hash
+// is taken
+func (db *DatabaseConnection) InsertAPIKey(record APIKeyRecord) error {
+	return db.do(func() error {
+		db.mu.Lock()
+		defer db.mu.Unlock()
+		for _, current := range db.apiKeys {
+			if current.Name == record.Name || current.KeySHA256 == record.KeySHA256 {
+				return ErrKeyConflict
+			}
+		}
+		if db.apiKeys == nil {
+			db.apiKeys = make(map[string]APIKeyRecord)
+		}
+		db.apiKeys[record.Name] = record
+		return nil
+	})
+}
+
+// UpdateAPIKey replaces a key's roles, tenant and limits, keeping its hash
+// and creation time, or fails with ErrNotFound
+func (db *DatabaseConnection) UpdateAPIKey(record APIKeyRecord) (*APIKeyRecord, error) {
+	var updated *APIKeyRecord
+	err := db.do(func() error {
+		db.mu.Lock()
+		defer db.mu.Unlock()
+		current, ok := db.apiKeys[record.Name]
+		if !ok {
+			return ErrNotFound
+		}
+		record.KeySHA256, record.CreatedAt = current.KeySHA256, current.CreatedAt
+		db.apiKeys[record.Name] = record
+		updated = &record
+		return nil
+	})
+	return updated, err
+}
+
+// DeleteAPIKey removes the key named name, or fails with ErrNotFound
+func (db *DatabaseConnection) DeleteAPIKey(name string) error {
+	return db.do(func() error {
+		db.mu.Lock()
+		defer db.mu.Unlock()
+		if _, ok := db.apiKeys[name]; !ok {
+			return ErrNotFound
+		}
+		delete(db.apiKeys, name)
+		return nil
+	})
+}
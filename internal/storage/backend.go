@@ -0,0 +1,235 @@
+package storage
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+
+	"interview-task/internal/config"
+)
+
+// Backend serves one storage type. Open returns the storage holding one
+// shard of a tenant's items, the root shard "" being the unsharded layout.
+type Backend interface {
+	Open(tenant, shard string) (StorageInterface, error)
+	// Tenants lists every tenant with data, sorted
+	Tenants() ([]string, error)
+}
+
+// BackendEnv is what a factory shares with the backends it builds
+type BackendEnv struct {
+	// DataDir is where backends keep their files, each under its own
+	// subdirectory
+	DataDir  string
+	Layout   config.FileLayout
+	Logs     *LogBackend
+	Disk     *DiskChecker
+	Database *DatabaseConnection
+	Batcher  *WriteBatcher
+}
+
+// BackendConstructor builds a backend from its section of the
+// storage_backends config, nil when it has none
+type BackendConstructor func(env BackendEnv, settings json.RawMessage) (Backend, error)
+
+var (
+	backendsMu sync.RWMutex
+	backends   = map[string]BackendConstructor{
+		"file":     newFileBackend,
+		"database": newDatabaseBackend,
+		"log":      newLogStorageBackend,
+	}
+	// backendNames lists storage types in the order they were registered
+	backendNames = []string{"file", "database", "log"}
+)
+
+// Register makes a storage type available to factories created afterwards
+func Register(name string, constructor BackendConstructor) {
+	backendsMu.Lock()
+	defer backendsMu.Unlock()
+	if _, ok := backends[name]; !ok {
+		backendNames = append(backendNames, name)
+	}
+	backends[name] = constructor
+}
+
+// StorageTypes lists the storage types clients can choose from
+func StorageTypes() []string {
+	backendsMu.RLock()
+	defer backendsMu.RUnlock()
+	return slices.Clone(backendNames)
+}
+
+// newBackends builds every registered backend, handing each its settings.
+// Settings for a storage type that isn't registered are an error, so a
+// misspelt section isn't silently ignored.
+func newBackends(env BackendEnv, settings map[string]json.RawMessage) (map[string]Backend, []string, error) {
+	backendsMu.RLock()
+	defer backendsMu.RUnlock()
+	for name := range settings {
+		if _, ok := backends[name]; !ok {
+			return nil, nil, fmt.Errorf("unknown storage backend: %q", name)
+		}
+	}
+	built := make(map[string]Backend, len(backends))
+	for _, name := range backendNames {
+		backend, err := backends[name](env, settings[name])
+		if err != nil {
+			return nil, nil, fmt.Errorf("storage backend %q: %w", name, err)
+		}
+		built[name] = backend
+	}
+	return built, slices.Clone(backendNames), nil
+}
+
+// shardDir returns the directory of one shard of a tenant's items below root
+func shardDir(root, tenant, shard string) string {
+	dir := filepath.Join(root, tenant)
+	if shard != "" {
+		dir = filepath.Join(dir, "shards", shard)
+	}
+	return dir
+}
+
+// dirTenants lists the tenant directories below root
+func dirTenants(root string) ([]string, error) {
+	entries, err := os.ReadDir(root)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("failed to list tenants: %w", err)
+	}
+	var tenants []string
+	for _, entry := range entries {
+		if entry.IsDir() {
+			tenants = append(tenants, entry.Name())
+		}
+	}
+	return tenants, nil
+}
+
+// fileBackend keeps every item in a file of its own
+type fileBackend struct {
+	root   string
+	layout fileLayout
+	disk   *DiskChecker
+	// volumes are the directories shards kept off the data directory are
+	// rooted in, each with disk checks of its own
+	volumes map[string]fileVolume
+}
+
+type fileVolume struct {
+	root string
+	disk *DiskChecker
+}
+
+func newFileBackend(env BackendEnv, settings json.RawMessage) (Backend, error) {
+	var cfg config.FileBackendConfig
+	if settings != nil {
+		if err := json.Unmarshal(settings, &cfg); err != nil {
+			return nil, fmt.Errorf("invalid settings: %w", err)
+		}
+	}
+	root := filepath.Join(env.DataDir, "tenants")
+	b := &fileBackend{root: root, layout: fileLayout(env.Layout), disk: env.Disk, volumes: make(map[string]fileVolume)}
+	for shard, dir := range cfg.Volumes {
+		if err := validateShardNames([]string{shard}); err != nil {
+			return nil, err
+		}
+		if !filepath.IsAbs(dir) {
+			return nil, fmt.Errorf("volume of shard %s must be an absolute path: %q", shard, dir)
+		}
+		b.volumes[shard] = fileVolume{root: filepath.Join(dir, "tenants"), disk: env.Disk.forDir(dir)}
+	}
+	return b, nil
+}
+
+func (b *fileBackend) Open(tenant, shard string) (StorageInterface, error) {
+	if volume, ok := b.volumes[shard]; ok {
+		return volume.disk.wrap(&FileStorage{dir: shardDir(volume.root, tenant, shard), layout: b.layout}), nil
+	}
+	return b.disk.wrap(&FileStorage{dir: shardDir(b.root, tenant, shard), layout: b.layout}), nil
+}
+
+// Tenants lists the tenants of the data directory and every volume
+func (b *fileBackend) Tenants() ([]string, error) {
+	tenants, err := dirTenants(b.root)
+	if err != nil {
+		return nil, err
+	}
+	for _, volume := range b.volumes {
+		more, err := dirTenants(volume.root)
+		if err != nil {
+			return nil, err
+		}
+		tenants = append(tenants, more...)
+	}
+	slices.Sort(tenants)
+	return slices.Compact(tenants), nil
+}
+
+// logStorageBackend appends items to rotating log segments
+type logStorageBackend struct {
+	root string
+	logs *LogBackend
+	disk *DiskChecker
+}
+
+func newLogStorageBackend(env BackendEnv, _ json.RawMessage) (Backend, error) {
+	return &logStorageBackend{root: filepath.Join(env.DataDir, "logs"), logs: env.Logs, disk: env.Disk}, nil
+}
+
+func (b *logStorageBackend) Open(tenant, shard string) (StorageInterface, error) {
+	return b.disk.wrap(b.logs.storage(shardDir(b.root, tenant, shard))), nil
+}
+
+func (b *logStorageBackend) Tenants() ([]string, error) {
+	return dirTenants(b.root)
+}
+
+// databaseBackend keeps items as rows of the database, prefixed by shard
+// and tenant
+type databaseBackend struct {
+	database *DatabaseConnection
+	batcher  *WriteBatcher
+}
+
+func newDatabaseBackend(env BackendEnv, _ json.RawMessage) (Backend, error) {
+	return &databaseBackend{database: env.Database, batcher: env.Batcher}, nil
+}
+
+func (b *databaseBackend) Open(tenant, shard string) (StorageInterface, error) {
+	if b.database == nil {
+		return nil, fmt.Errorf("database connection not available")
+	}
+	return databaseShard(b.batcher.wrap(b.database), tenant, shard), nil
+}
+
+func (b *databaseBackend) Tenants() ([]string, error) {
+	if b.database == nil {
+		return nil, nil
+	}
+	keys, err := b.database.List()
+	if err != nil {
+		return nil, err
+	}
+	seen := make(map[string]bool)
+	for _, key := range keys {
+		if strings.HasPrefix(key, "shard:") {
+			_, key, _ = strings.Cut(key, "/")
+		}
+		if tenant, _, ok := strings.Cut(key, "/"); ok {
+			seen[tenant] = true
+		}
+	}
+	tenants := make([]string, 0, len(seen))
+	for tenant := range seen {
+		tenants = append(tenants, tenant)
+	}
+	sort.Strings(tenants)
+	return tenants, nil
+}
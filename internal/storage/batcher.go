@@ -0,0 +1,110 @@
+package storage
+
+import (
+	"sync"
+	"time"
+
+	"interview-task/internal/config"
+	"interview-task/internal/metrics"
+)
+
+// batchedRow is a save waiting for its batch to be flushed
+type batchedRow struct {
+	key  string
+	data []byte
+	done chan error
+}
+
+// WriteBatcher - IMPLEMENTS group commit for database saves. Every save
+// still waits for the bulk insert holding it, so errors reach the caller and
+// a saved item can be read right away; what is saved is the per-insert
+// overhead of many small payloads.
+type WriteBatcher struct {
+	db      *DatabaseConnection
+	config  config.WriteBatchingConfig
+	metrics *metrics.Metrics
+
+	mu      sync.Mutex
+	pending []batchedRow
+	bytes   int
+	timer   *time.Timer
+}
+
+func NewWriteBatcher(db *DatabaseConnection, config config.WriteBatchingConfig, metrics *metrics.Metrics) *WriteBatcher {
+	metrics.Describe("db_batch_flushes_total", "Bulk inserts written by the write batcher")
+	metrics.Describe("db_batched_rows_total", "Rows written through the write batcher")
+	return &WriteBatcher{db: db, config: config, metrics: metrics}
+}
+
+// wrap routes the saves of db through the batcher when batching is enabled
+func (b *WriteBatcher) wrap(db *DatabaseConnection) StorageInterface {
+	if b == nil || !b.config.Enabled || db == nil {
+		return db
+	}
+	return &batchedDatabase{DatabaseConnection: db, batcher: b}
+}
+
+// batchedDatabase is the database with saves going through the batcher
+type batchedDatabase struct {
+	*DatabaseConnection
+	batcher *WriteBatcher
+}
+
+func (d *batchedDatabase) Save(id string, data []byte) error {
+	return d.batcher.Save(id, data)
+}
+
+// Save adds a row to the current batch and waits until it has been written
+func (b *WriteBatcher) Save(key string, data []byte) error {
+	row := batchedRow{key: key, data: append([]byte(nil), data...), done: make(chan error, 1)}
+
+	b.mu.Lock()
+	b.pending = append(b.pending, row)
+	b.bytes += len(data)
+	var batch []batchedRow
+	if len(b.pending) >= b.config.MaxItems || b.bytes >= b.config.MaxBytes {
+		batch = b.take()
+	} else if b.timer == nil {
+		b.timer = time.AfterFunc(time.Duration(b.config.FlushInterval), b.Flush)
+	}
+	b.mu.Unlock()
+
+	if batch != nil {
+		b.write(batch)
+	}
+	return <-row.done
+}
+
+// take removes the current batch; the caller must hold mu
+func (b *WriteBatcher) take() []batchedRow {
+	batch := b.pending
+	b.pending, b.bytes = nil, 0
+	if b.timer != nil {
+		b.timer.Stop()
+		b.timer = nil
+	}
+	return batch
+}
+
+// Flush writes the current batch
+func (b *WriteBatcher) Flush() {
+	b.mu.Lock()
+	batch := b.take()
+	b.mu.Unlock()
+	if len(batch) > 0 {
+		b.write(batch)
+	}
+}
+
+func (b *WriteBatcher) write(batch []batchedRow) {
+	rows := make([]DatabaseRow, len(batch))
+	for i, row := range batch {
+		rows[i] = DatabaseRow{Key: row.key, Data: row.data}
+	}
+	err := b.db.SaveMany(rows)
+	for _, row := range batch {
+		row.done <- err
+	}
+	b.metrics.Add("db_batch_flushes_total", 1)
+	b.metrics.Add("db_batched_rows_total", int64(len(batch)))
+}
@@ -0,0 +1,202 @@
+package storage
+
+import (
+	"container/list"
+	"errors"
+	"sync"
+	"time"
+
+	"interview-task/internal/config"
+	"interview-task/internal/metrics"
+)
+
+type cacheEntry struct {
+	key       string
+	data      []byte
+	expiresAt time.Time
+}
+
+// cacheFill tracks loads of a key in flight, so one that raced with a write
+// doesn't cache what it read before the write
+type cacheFill struct {
+	refs  int
+	stale bool
+}
+
+// ReadCache - IMPLEMENTS a read-through LRU cache in front of storage.
+// Writes and deletes made through the cache invalidate the key.
+type ReadCache struct {
+	config  config.CacheConfig
+	metrics *metrics.Metrics
+
+	mu      sync.Mutex
+	lru     *list.List
+	entries map[string]*list.Element
+	fills   map[string]*cacheFill
+	bytes   int64
+}
+
+func NewReadCache(config config.CacheConfig, metrics *metrics.Metrics) *ReadCache {
+	c := &ReadCache{
+		config:  config,
+		metrics: metrics,
+		lru:     list.New(),
+		entries: make(map[string]*list.Element),
+		fills:   make(map[string]*cacheFill),
+	}
+	metrics.Describe("cache_hits_total", "Reads served from the cache")
+	metrics.Describe("cache_misses_total", "Reads that went to storage")
+	metrics.Describe("cache_evictions_total", "Cached payloads evicted to stay within max_bytes")
+	metrics.GaugeFunc("cache_bytes", "Bytes of payloads in the cache", func() int64 {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		return c.bytes
+	})
+	return c
+}
+
+// wrap puts the cache in front of a tenant's storage when caching is enabled
+func (c *ReadCache) wrap(tenant, storageType string, storage StorageInterface) StorageInterface {
+	if c == nil || !c.config.Enabled {
+		return storage
+	}
+	return &CachedStorage{inner: storage, cache: c, prefix: storageType + "/" + tenant + "/"}
+}
+
+func (c *ReadCache) get(key string) ([]byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	element, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	entry := element.Value.(*cacheEntry)
+	if time.Now().After(entry.expiresAt) {
+		c.remove(element)
+		return nil, false
+	}
+	c.lru.MoveToFront(element)
+	return append([]byte(nil), entry.data...), true
+}
+
+func (c *ReadCache) beginFill(key string) *cacheFill {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	fill := c.fills[key]
+	if fill == nil {
+		fill = &cacheFill{}
+		c.fills[key] = fill
+	}
+	fill.refs++
+	return fill
+}
+
+// endFill caches data unless the key was written while it was loaded
+func (c *ReadCache) endFill(key string, fill *cacheFill, data []byte, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if fill.refs--; fill.refs == 0 {
+		delete(c.fills, key)
+	}
+	if err != nil || fill.stale || int64(len(data)) > c.config.MaxItemBytes {
+		return
+	}
+	if element, ok := c.entries[key]; ok {
+		c.remove(element)
+	}
+	entry := &cacheEntry{key: key, data: append([]byte(nil), data...), expiresAt: time.Now().Add(time.Duration(c.config.TTL))}
+	c.entries[key] = c.lru.PushFront(entry)
+	c.bytes += int64(len(entry.data))
+	for c.bytes > c.config.MaxBytes {
+		c.remove(c.lru.Back())
+		c.metrics.Add("cache_evictions_total", 1)
+	}
+}
+
+func (c *ReadCache) invalidate(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if element, ok := c.entries[key]; ok {
+		c.remove(element)
+	}
+	if fill := c.fills[key]; fill != nil {
+		fill.stale = true
+	}
+}
+
+// remove drops an entry; the caller must hold mu
+func (c *ReadCache) remove(element *list.Element) {
+	entry := c.lru.Remove(element).(*cacheEntry)
+	delete(c.entries, entry.key)
+	c.bytes -= int64(len(entry.data))
+}
+
+// CachedStorage is a tenant's storage behind the read cache
+type CachedStorage struct {
+	inner  StorageInterface
+	cache  *ReadCache
+	prefix string
+	// written collects the keys written inside a transaction, which are
+	// invalidated once it commits
+	written *[]string
+}
+
+func (s *CachedStorage) Save(id string, data []byte) error {
+	err := s.inner.Save(id, data)
+	s.invalidate(id)
+	return err
+}
+
+func (s *CachedStorage) Load(id string) ([]byte, error) {
+	if s.written != nil {
+		// Reads inside a transaction see its uncommitted writes, which
+		// must neither come from nor reach the cache
+		return s.inner.Load(id)
+	}
+	key := s.prefix + id
+	if data, ok := s.cache.get(key); ok {
+		s.cache.metrics.Add("cache_hits_total", 1)
+		return data, nil
+	}
+	s.cache.metrics.Add("cache_misses_total", 1)
+	fill := s.cache.beginFill(key)
+	data, err := s.inner.Load(id)
+	s.cache.endFill(key, fill, data, err)
+	return data, err
+}
+
+func (s *CachedStorage) Delete(id string) error {
+	err := s.inner.Delete(id)
+	if err == nil || !errors.Is(err, ErrNotFound) {
+		s.invalidate(id)
+	}
+	return err
+}
+
+func (s *CachedStorage) List() ([]string, error) {
+	return s.inner.List()
+}
+
+func (s *CachedStorage) invalidate(id string) {
+	if s.written != nil {
+		*s.written = append(*s.written, id)
+		return
+	}
+	s.cache.invalidate(s.prefix + id)
+}
+
+// wrapTx puts the cache in front of a transaction's storage. The returned
+// function invalidates what the transaction wrote and must be called once
+// it has committed.
+func (c *ReadCache) wrapTx(tenant, storageType string, storage StorageInterface) (StorageInterface, func()) {
+	if c == nil || !c.config.Enabled {
+		return storage, func() {}
+	}
+	written := []string{}
+	cached := &CachedStorage{inner: storage, cache: c, prefix: storageType + "/" + tenant + "/", written: &written}
+	return cached, func() {
+		for _, id := range written {
+			c.invalidate(cached.prefix + id)
+		}
+	}
+}
@@ -0,0 +1,106 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"time"
+
+	"interview-task/internal/config"
+	"interview-task/internal/logging"
+	"interview-task/internal/metrics"
+)
+
+// ChaosStorage injects faults for resilience testing: calls fail as if the
+// backend were unavailable, are delayed, or save a truncated payload and
+// then fail. Injected failures wrap ErrStorageUnavailable, so retries,
+// circuit breakers and the spool treat them as outages.
+type ChaosStorage struct {
+	next        StorageInterface
+	storageType string
+	config      config.DecoratorConfig
+	metrics     *metrics.Metrics
+}
+
+func newChaosDecorator(storageType string, config config.DecoratorConfig, metrics *metrics.Metrics) (Decorator, error) {
+	for name, rate := range map[string]float64{"failure_rate": config.FailureRate, "delay_rate": config.DelayRate, "partial_write_rate": config.PartialWriteRate} {
+		if rate < 0 || rate > 1 {
+			return nil, fmt.Errorf("%s must be between 0 and 1", name)
+		}
+	}
+	if config.DelayRate > 0 && config.MaxDelay <= 0 {
+		return nil, errors.New("delay_rate needs a max_delay")
+	}
+	if metrics == nil {
+		return nil, errors.New("metrics are not available")
+	}
+	metrics.Describe("chaos_faults_total", "Faults injected by chaos decorators by storage type, operation and fault")
+	logging.Printf("Fault injection is enabled for %s storage", storageType)
+	return func(next StorageInterface) StorageInterface {
+		return &ChaosStorage{next: next, storageType: storageType, config: config, metrics: metrics}
+	}, nil
+}
+
+// inject delays and fails a call by chance
+func (s *ChaosStorage) inject(operation string) error {
+	if chance(s.config.DelayRate) {
+		s.metrics.Add("chaos_faults_total", 1, "storage_type", s.storageType, "operation", operation, "fault", "delay")
+		time.Sleep(rand.N(time.Duration(s.config.MaxDelay)))
+	}
+	if chance(s.config.FailureRate) {
+		s.metrics.Add("chaos_faults_total", 1, "storage_type", s.storageType, "operation", operation, "fault", "failure")
+		return fmt.Errorf("%w: %s failure injected by chaos", ErrStorageUnavailable, operation)
+	}
+	return nil
+}
+
+func (s *ChaosStorage) Save(id string, data []byte) error {
+	if err := s.inject("save"); err != nil {
+		return err
+	}
+	if len(data) > 0 && chance(s.config.PartialWriteRate) {
+		s.metrics.Add("chaos_faults_total", 1, "storage_type", s.storageType, "operation", "save", "fault", "partial_write")
+		if err := s.next.Save(id, data[:rand.N(len(data))]); err != nil {
+			return err
+		}
+		return fmt.Errorf("%w: %w injected by chaos", ErrStorageUnavailable, io.ErrShortWrite)
+	}
+	return s.next.Save(id, data)
+}
+
+func (s *ChaosStorage) Load(id string) ([]byte, error) {
+	if err := s.inject("load"); err != nil {
+		return nil, err
+	}
+	return s.next.Load(id)
+}
+
+func (s *ChaosStorage) Delete(id string) error {
+	if err := s.inject("delete"); err != nil {
+		return err
+	}
+	return s.next.Delete(id)
+}
+
+func (s *ChaosStorage) List() ([]string, error) {
+	if err := s.inject("list"); err != nil {
+		return nil, err
+	}
+	return s.next.List()
+}
+
+func (s *ChaosStorage) Rewrap(id string) (bool, error) {
+	if err := s.inject("rewrap"); err != nil {
+		return false, err
+	}
+	return Rewrap(s.next, id)
+}
+
+func isChaos(config config.DecoratorConfig) bool {
+	return config.Type == "chaos" && !config.Disabled
+}
+
+func chance(rate float64) bool {
+	return rate > 0 && rand.Float64() < rate
+}
@@ -0,0 +1,13 @@
+package storage
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+)
+
+// PayloadSHA256 returns the hex SHA-256 of a payload, the checksum recorded
+// for every item, version and audit event
+func PayloadSHA256(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,34 @@
+package storage_test
+
+import (
+	"testing"
+
+	"interview-task/internal/config"
+	"interview-task/internal/storage"
+	"interview-task/pkg/storagetest"
+)
+
+// TestBackendConformance runs the storage contract against every built-in
+// backend, each on a factory of its own
+func TestBackendConformance(t *testing.T) {
+	for _, storageType := range []string{"file", "database", "log"} {
+		t.Run(storageType, func(t *testing.T) {
+			storagetest.TestStorage(t, func(t *testing.T) storage.StorageInterface {
+				pool := config.NewConfiguration().DatabasePool
+				database, err := storage.NewDatabaseConnection("localhost", 5432, "test", "test", "test", pool)
+				if err != nil {
+					t.Fatal(err)
+				}
+				factory, err := storage.NewStorageFactory(t.TempDir(), storage.WithDatabase(database, nil))
+				if err != nil {
+					t.Fatal(err)
+				}
+				s, err := factory.CreateStorage("tenant", storageType)
+				if err != nil {
+					t.Fatal(err)
+				}
+				return s
+			})
+		})
+	}
+}
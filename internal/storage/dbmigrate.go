@@ -0,0 +1,117 @@
+package storage
+
+import (
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"regexp"
+	"slices"
+	"strconv"
+
+	"interview-task/internal/logging"
+)
+
+// Schema migrations are SQL files named <version>_<title>.up.sql, applied in
+// version order. The database records the version it is at and whether the
+// migration to it failed halfway, like golang-migrate's schema_migrations.
+//
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+// ErrSchemaDirty is returned while a failed migration is left half applied;
+// the schema has to be repaired by hand before migrating again
+var ErrSchemaDirty = errors.New("database schema is dirty")
+
+var migrationName = regexp.MustCompile(`^(\d+)_(\w+)\.up\.sql$`)
+
+// SchemaMigration is one versioned change to the database schema
+type SchemaMigration struct {
+	Version int
+	Name    string
+	SQL     string
+}
+
+// SchemaMigrations returns the embedded migrations in version order
+func SchemaMigrations() ([]SchemaMigration, error) {
+	entries, err := fs.ReadDir(migrationFiles, "migrations")
+	if err != nil {
+		return nil, err
+	}
+	var migrations []SchemaMigration
+	for _, entry := range entries {
+		match := migrationName.FindStringSubmatch(entry.Name())
+		if match == nil {
+			continue
+		}
+		version, err := strconv.Atoi(match[1])
+		if err != nil {
+			return nil, fmt.Errorf("migration %s: %w", entry.Name(), err)
+		}
+		sql, err := migrationFiles.ReadFile("migrations/" + entry.Name())
+		if err != nil {
+			return nil, err
+		}
+		migrations = append(migrations, SchemaMigration{Version: version, Name: match[2], SQL: string(sql)})
+	}
+	slices.SortFunc(migrations, func(a, b SchemaMigration) int { return a.Version - b.Version })
+	for i := 1; i < len(migrations); i++ {
+		if migrations[i].Version == migrations[i-1].Version {
+			return nil, fmt.Errorf("duplicate migration version %d", migrations[i].Version)
+		}
+	}
+	return migrations, nil
+}
+
+// SchemaVersion returns the version the schema is at, zero before the first
+// migration, and whether the migration to it failed halfway
+func (db *DatabaseConnection) SchemaVersion() (version int, dirty bool, err error) {
+	err = db.do(func() error {
+		db.mu.RLock()
+		defer db.mu.RUnlock()
+		version, dirty = db.schemaVersion, db.schemaDirty
+		return nil
+	})
+	return version, dirty, err
+}
+
+// Migrate applies every pending migration in order and returns the version
+// the schema is at. A failed migration leaves the schema dirty at its
+// version.
+func (db *DatabaseConnection) Migrate() (int, error) {
+	migrations, err := SchemaMigrations()
+	if err != nil {
+		return 0, err
+	}
+	version, dirty, err := db.SchemaVersion()
+	if err != nil {
+		return 0, err
+	}
+	if dirty {
+		return version, fmt.Errorf("%w at version %d", ErrSchemaDirty, version)
+	}
+	for _, migration := range migrations {
+		if migration.Version <= version {
+			continue
+		}
+		if err := db.applyMigration(migration); err != nil {
+			return version, fmt.Errorf("migration %d_%s failed: %w", migration.Version, migration.Name, err)
+		}
+		version = migration.Version
+		logging.Printf("Applied database migration %d_%s", migration.Version, migration.Name)
+	}
+	return version, nil
+}
+
+// applyMigration marks the schema dirty at the migration's version, runs it
+// and marks it clean again
+func (db *DatabaseConnection) applyMigration(migration SchemaMigration) error {
+	return db.do(func() error {
+		db.mu.Lock()
+		defer db.mu.Unlock()
+		db.schemaVersion, db.schemaDirty = migration.Version, true
+		fmt.Printf("Executing on database %s:\n%s", db.DBName, migration.SQL)
+		db.schemaDirty = false
+		return nil
+	})
+}
@@ -0,0 +1,232 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"interview-task/internal/config"
+	"interview-task/internal/metrics"
+)
+
+var (
+	ErrPoolClosed  = errors.New("database connection pool is closed")
+	ErrPoolTimeout = errors.New("timed out waiting for a database connection")
+	// errBadConn is returned by queries whose connection turned out to be
+	// dead; the connection is discarded and the query retried on a new one
+	errBadConn = errors.New("bad database connection")
+)
+
+// Reconnection backoff after a failed dial. Dials are only attempted when a
+// query needs a connection, and fail fast until the backoff has passed.
+const (
+	minDialBackoff = 100 * time.Millisecond
+	maxDialBackoff = 10 * time.Second
+)
+
+// dbConn is one pooled connection. The mock tables need no network
+// connection, so it only carries what the pool needs to retire it.
+type dbConn struct {
+	createdAt  time.Time
+	returnedAt time.Time
+}
+
+// PoolStats is a snapshot of the pool, exported as metrics
+type PoolStats struct {
+	Open              int
+	InUse             int
+	Idle              int
+	WaitCount         int64
+	Dials             int64
+	DialFailures      int64
+	MaxLifetimeClosed int64
+	MaxIdleTimeClosed int64
+}
+
+// connPool - IMPLEMENTS connection pooling with lazy reconnection. A
+// semaphore bounds the connections handed out; idle ones are reused before
+// dialling, so open connections never exceed MaxOpenConns.
+type connPool struct {
+	config config.DatabasePoolConfig
+	dial   func() (*dbConn, error)
+	slots  chan struct{}
+
+	mu      sync.Mutex
+	idle    []*dbConn
+	open    int
+	closed  bool
+	stats   PoolStats
+	retryAt time.Time
+	backoff time.Duration
+	lastErr error
+}
+
+func newConnPool(config config.DatabasePoolConfig, dial func() (*dbConn, error)) *connPool {
+	return &connPool{
+		config: config,
+		dial:   dial,
+		slots:  make(chan struct{}, max(config.MaxOpenConns, 1)),
+	}
+}
+
+func (p *connPool) acquire() (*dbConn, error) {
+	select {
+	case p.slots <- struct{}{}:
+	default:
+		ctx := context.Background()
+		if p.config.WaitTimeout > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, time.Duration(p.config.WaitTimeout))
+			defer cancel()
+		}
+		select {
+		case p.slots <- struct{}{}:
+		case <-ctx.Done():
+			return nil, ErrPoolTimeout
+		}
+		p.mu.Lock()
+		p.stats.WaitCount++
+		p.mu.Unlock()
+	}
+
+	conn, err := p.take()
+	if err != nil {
+		<-p.slots
+		return nil, err
+	}
+	return conn, nil
+}
+
+// take reuses an idle connection that hasn't expired, or dials a new one
+func (p *connPool) take() (*dbConn, error) {
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return nil, ErrPoolClosed
+	}
+	now := time.Now()
+	for len(p.idle) > 0 {
+		conn := p.idle[len(p.idle)-1]
+		p.idle = p.idle[:len(p.idle)-1]
+		if p.retire(conn, now) {
+			continue
+		}
+		p.stats.InUse++
+		p.mu.Unlock()
+		return conn, nil
+	}
+	if now.Before(p.retryAt) {
+		err := fmt.Errorf("%w: database unreachable, retrying in %s: %w", ErrStorageUnavailable, p.retryAt.Sub(now).Round(time.Millisecond), p.lastErr)
+		p.mu.Unlock()
+		return nil, err
+	}
+	p.stats.Dials++
+	p.mu.Unlock()
+
+	conn, err := p.dial()
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if err != nil {
+		p.stats.DialFailures++
+		p.backoff = min(max(2*p.backoff, minDialBackoff), maxDialBackoff)
+		p.retryAt, p.lastErr = time.Now().Add(p.backoff), err
+		return nil, fmt.Errorf("%w: %w", ErrStorageUnavailable, err)
+	}
+	p.backoff, p.retryAt, p.lastErr = 0, time.Time{}, nil
+	p.open++
+	p.stats.InUse++
+	return conn, nil
+}
+
+// retire closes conn when it has outlived its lifetime or idle time
+func (p *connPool) retire(conn *dbConn, now time.Time) bool {
+	switch {
+	case p.config.ConnMaxLifetime > 0 && now.Sub(conn.createdAt) >= time.Duration(p.config.ConnMaxLifetime):
+		p.stats.MaxLifetimeClosed++
+	case p.config.ConnMaxIdleTime > 0 && now.Sub(conn.returnedAt) >= time.Duration(p.config.ConnMaxIdleTime):
+		p.stats.MaxIdleTimeClosed++
+	default:
+		return false
+	}
+	p.open--
+	return true
+}
+
+// release returns conn to the pool, or closes it when it is broken or the
+// idle pool is full
+func (p *connPool) release(conn *dbConn, broken bool) {
+	p.mu.Lock()
+	p.stats.InUse--
+	conn.returnedAt = time.Now()
+	switch {
+	case broken, p.closed, len(p.idle) >= p.config.MaxIdleConns:
+		p.open--
+	case p.retire(conn, conn.returnedAt):
+	default:
+		p.idle = append(p.idle, conn)
+	}
+	p.mu.Unlock()
+	<-p.slots
+}
+
+func (p *connPool) Stats() PoolStats {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	stats := p.stats
+	stats.Open, stats.Idle = p.open, len(p.idle)
+	return stats
+}
+
+// Close closes idle connections; those in use are closed when released
+func (p *connPool) Close() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.closed = true
+	p.open -= len(p.idle)
+	p.idle = nil
+}
+
+// do runs a query on a pooled connection, retrying once on a new connection
+// when the pooled one turns out to be dead
+func (db *DatabaseConnection) do(query func() error) error {
+	for attempt := 0; ; attempt++ {
+		conn, err := db.pool.acquire()
+		if err != nil {
+			return err
+		}
+		err = query()
+		db.pool.release(conn, errors.Is(err, errBadConn))
+		if !errors.Is(err, errBadConn) || attempt > 0 {
+			return err
+		}
+	}
+}
+
+// ExportMetrics publishes the pool's stats
+func (db *DatabaseConnection) ExportMetrics(metrics *metrics.Metrics) {
+	metrics.GaugeFunc("db_pool_open_connections", "Open database connections", func() int64 {
+		return int64(db.pool.Stats().Open)
+	})
+	metrics.GaugeFunc("db_pool_in_use_connections", "Database connections running a query", func() int64 {
+		return int64(db.pool.Stats().InUse)
+	})
+	metrics.GaugeFunc("db_pool_idle_connections", "Database connections kept for reuse", func() int64 {
+		return int64(db.pool.Stats().Idle)
+	})
+	metrics.CounterFunc("db_pool_waits_total", "Queries that waited for a free connection", func() int64 {
+		return db.pool.Stats().WaitCount
+	})
+	metrics.CounterFunc("db_pool_dials_total", "Database connections established or attempted", func() int64 {
+		return db.pool.Stats().Dials
+	})
+	metrics.CounterFunc("db_pool_dial_failures_total", "Failed attempts to connect to the database", func() int64 {
+		return db.pool.Stats().DialFailures
+	})
+	metrics.CounterFunc("db_pool_expired_connections_total", "Connections closed for reaching their lifetime or idle time", func() int64 {
+		stats := db.pool.Stats()
+		return stats.MaxLifetimeClosed + stats.MaxIdleTimeClosed
+	})
+}
@@ -0,0 +1,469 @@
+package storage
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/cipher"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"sync"
+	"time"
+
+	"interview-task/internal/config"
+	"interview-task/internal/logging"
+	"interview-task/internal/metrics"
+)
+
+// Decorator wraps storage in behavior of its own, such as retries or
+// encryption
+type Decorator func(next StorageInterface) StorageInterface
+
+// DecoratorFactory builds a decorator for a storage type from its config
+// entry. It is called once per storage type, so state such as a circuit
+// breaker's is shared by all of the storage type's tenants and shards.
+type DecoratorFactory func(storageType string, config config.DecoratorConfig, metrics *metrics.Metrics) (Decorator, error)
+
+var (
+	decoratorsMu       sync.RWMutex
+	decoratorFactories = map[string]DecoratorFactory{
+		"metrics":         newMetricsDecorator,
+		"retry":           newRetryDecorator,
+		"circuit_breaker": newCircuitBreakerDecorator,
+		"encryption":      newEncryptionDecorator,
+		"compression":     newCompressionDecorator,
+		"chaos":           newChaosDecorator,
+	}
+)
+
+// RegisterDecorator makes a decorator type available to the config. Call it
+// before the server is created; registering a name twice replaces it.
+func RegisterDecorator(name string, factory DecoratorFactory) {
+	decoratorsMu.Lock()
+	defer decoratorsMu.Unlock()
+	decoratorFactories[name] = factory
+}
+
+// newDecoratorChain assembles a storage type's chain in config order, the
+// first entry outermost and so the first to see every call
+func newDecoratorChain(storageType string, configs []config.DecoratorConfig, metrics *metrics.Metrics) (Decorator, error) {
+	var chain []Decorator
+	for _, config := range configs {
+		if config.Disabled {
+			continue
+		}
+		decoratorsMu.RLock()
+		factory, ok := decoratorFactories[config.Type]
+		decoratorsMu.RUnlock()
+		if !ok {
+			return nil, fmt.Errorf("unknown decorator type: %q", config.Type)
+		}
+		decorator, err := factory(storageType, config, metrics)
+		if err != nil {
+			return nil, fmt.Errorf("decorator %s: %w", config.Type, err)
+		}
+		chain = append(chain, decorator)
+	}
+	return func(storage StorageInterface) StorageInterface {
+		for _, decorator := range slices.Backward(chain) {
+			storage = decorator(storage)
+		}
+		return storage
+	}, nil
+}
+
+// permanent reports errors that retrying can't fix and that say nothing
+// about the health of the backend
+func permanent(err error) bool {
+	return errors.Is(err, ErrNotFound) || errors.Is(err, ErrConflict) ||
+		errors.Is(err, ErrInsufficientStorage) || errors.Is(err, errCircuitOpen)
+}
+
+// newMetricsDecorator is kept so configs listing a "metrics" decorator
+// still load, with a deprecation warning. Every backend call is counted in
+// storage_operations_total without it, so it wraps nothing.
+func newMetricsDecorator(storageType string, _ config.DecoratorConfig, _ *metrics.Metrics) (Decorator, error) {
+	logging.Printf("The metrics decorator of storage type %s is deprecated and does nothing, as every backend call is counted; remove it from storage_decorators", storageType)
+	return func(next StorageInterface) StorageInterface { return next }, nil
+}
+
+// RetryingStorage retries calls that fail for reasons other than the
+// permanent ones, backing off exponentially between attempts
+type RetryingStorage struct {
+	next     StorageInterface
+	attempts int
+	backoff  time.Duration
+}
+
+func newRetryDecorator(_ string, config config.DecoratorConfig, _ *metrics.Metrics) (Decorator, error) {
+	attempts, backoff := config.MaxAttempts, time.Duration(config.Backoff)
+	if attempts < 0 || backoff < 0 {
+		return nil, errors.New("max_attempts and backoff must not be negative")
+	}
+	if attempts == 0 {
+		attempts = 3
+	}
+	if backoff == 0 {
+		backoff = 100 * time.Millisecond
+	}
+	return func(next StorageInterface) StorageInterface {
+		return &RetryingStorage{next: next, attempts: attempts, backoff: backoff}
+	}, nil
+}
+
+func (s *RetryingStorage) retry(fn func() error) error {
+	backoff := s.backoff
+	for attempt := 1; ; attempt++ {
+		err := fn()
+		if err == nil || permanent(err) || attempt == s.attempts {
+			return err
+		}
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+}
+
+func (s *RetryingStorage) Save(id string, data []byte) error {
+	return s.retry(func() error { return s.next.Save(id, data) })
+}
+
+func (s *RetryingStorage) Load(id string) (data []byte, err error) {
+	err = s.retry(func() error {
+		data, err = s.next.Load(id)
+		return err
+	})
+	return data, err
+}
+
+func (s *RetryingStorage) Delete(id string) error {
+	return s.retry(func() error { return s.next.Delete(id) })
+}
+
+func (s *RetryingStorage) List() (ids []string, err error) {
+	err = s.retry(func() error {
+		ids, err = s.next.List()
+		return err
+	})
+	return ids, err
+}
+
+func (s *RetryingStorage) Rewrap(id string) (rewrapped bool, err error) {
+	err = s.retry(func() error {
+		rewrapped, err = Rewrap(s.next, id)
+		return err
+	})
+	return rewrapped, err
+}
+
+var errCircuitOpen = errors.New("circuit breaker open")
+
+// circuitBreaker fails calls fast once a storage type keeps failing, so a
+// struggling backend isn't buried under more calls. After the open period
+// one trial call is let through; its outcome closes or reopens the circuit.
+type circuitBreaker struct {
+	threshold int
+	openFor   time.Duration
+
+	mu        sync.Mutex
+	failures  int
+	openUntil time.Time
+	// trial is set while the trial call of a half-open circuit is running
+	trial bool
+}
+
+// BreakerStorage guards a storage type's calls with its circuit breaker.
+// While the circuit is open calls fail with ErrStorageUnavailable, so saves
+// are spooled.
+type BreakerStorage struct {
+	next    StorageInterface
+	breaker *circuitBreaker
+}
+
+func newCircuitBreakerDecorator(_ string, config config.DecoratorConfig, _ *metrics.Metrics) (Decorator, error) {
+	threshold, openFor := config.FailureThreshold, time.Duration(config.OpenFor)
+	if threshold < 0 || openFor < 0 {
+		return nil, errors.New("failure_threshold and open_for must not be negative")
+	}
+	if threshold == 0 {
+		threshold = 5
+	}
+	if openFor == 0 {
+		openFor = 30 * time.Second
+	}
+	breaker := &circuitBreaker{threshold: threshold, openFor: openFor}
+	return func(next StorageInterface) StorageInterface {
+		return &BreakerStorage{next: next, breaker: breaker}
+	}, nil
+}
+
+func (b *circuitBreaker) allow() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.failures < b.threshold {
+		return nil
+	}
+	if b.trial || time.Now().Before(b.openUntil) {
+		return fmt.Errorf("%w: %w", ErrStorageUnavailable, errCircuitOpen)
+	}
+	b.trial = true
+	return nil
+}
+
+func (b *circuitBreaker) record(err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.trial = false
+	if err == nil || permanent(err) {
+		b.failures = 0
+		return
+	}
+	b.failures++
+	if b.failures >= b.threshold {
+		b.openUntil = time.Now().Add(b.openFor)
+	}
+}
+
+func (s *BreakerStorage) call(fn func() error) error {
+	if err := s.breaker.allow(); err != nil {
+		return err
+	}
+	err := fn()
+	s.breaker.record(err)
+	return err
+}
+
+func (s *BreakerStorage) Save(id string, data []byte) error {
+	return s.call(func() error { return s.next.Save(id, data) })
+}
+
+func (s *BreakerStorage) Load(id string) (data []byte, err error) {
+	err = s.call(func() error {
+		data, err = s.next.Load(id)
+		return err
+	})
+	return data, err
+}
+
+func (s *BreakerStorage) Delete(id string) error {
+	return s.call(func() error { return s.next.Delete(id) })
+}
+
+func (s *BreakerStorage) List() (ids []string, err error) {
+	err = s.call(func() error {
+		ids, err = s.next.List()
+		return err
+	})
+	return ids, err
+}
+
+func (s *BreakerStorage) Rewrap(id string) (rewrapped bool, err error) {
+	err = s.call(func() error {
+		rewrapped, err = Rewrap(s.next, id)
+		return err
+	})
+	return rewrapped, err
+}
+
+// encryptedMagic prefixes payloads sealed with the key_file before envelope
+// encryption. Payloads with neither magic were stored before encryption was
+// enabled and are read as they are.
+var encryptedMagic = []byte("\x00enc1")
+
+// EncryptedStorage seals payloads with AES-256-GCM before they reach the
+// backend, each under a data key of its own wrapped by the current master
+// key. Keys are left readable so items can still be listed.
+type EncryptedStorage struct {
+	StorageInterface
+	keys *masterKeys
+}
+
+func newEncryptionDecorator(_ string, config config.DecoratorConfig, _ *metrics.Metrics) (Decorator, error) {
+	keys, err := newMasterKeys(config)
+	if err != nil {
+		return nil, err
+	}
+	return func(next StorageInterface) StorageInterface {
+		return &EncryptedStorage{StorageInterface: next, keys: keys}
+	}, nil
+}
+
+func (s *EncryptedStorage) Save(id string, data []byte) error {
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return fmt.Errorf("failed to generate data key: %w", err)
+	}
+	aead, err := newGCM(key)
+	if err != nil {
+		return err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	e := &envelope{version: s.keys.current, sealed: aead.Seal(nonce, nonce, data, nil)}
+	if e.wrapped, err = s.keys.wrappers[e.version].WrapKey(key); err != nil {
+		return fmt.Errorf("failed to wrap data key of %s: %w", id, err)
+	}
+	return s.StorageInterface.Save(id, e.encode())
+}
+
+func (s *EncryptedStorage) Load(id string) ([]byte, error) {
+	data, err := s.StorageInterface.Load(id)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case bytes.HasPrefix(data, envelopeMagic):
+		data, err = s.open(data)
+	case bytes.HasPrefix(data, encryptedMagic):
+		data, err = s.openLegacy(data)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt %s: %w", id, err)
+	}
+	return data, nil
+}
+
+func (s *EncryptedStorage) open(data []byte) ([]byte, error) {
+	e, err := parseEnvelope(data)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := s.dataKey(e)
+	if err != nil {
+		return nil, err
+	}
+	if len(e.sealed) < aead.NonceSize() {
+		return nil, errors.New("payload truncated")
+	}
+	return aead.Open(nil, e.sealed[:aead.NonceSize()], e.sealed[aead.NonceSize():], nil)
+}
+
+// dataKey unwraps the data key of an envelope
+func (s *EncryptedStorage) dataKey(e *envelope) (cipher.AEAD, error) {
+	wrapper, ok := s.keys.wrappers[e.version]
+	if !ok {
+		return nil, fmt.Errorf("unknown master key version %q", e.version)
+	}
+	key, err := wrapper.UnwrapKey(e.wrapped)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unwrap data key: %w", err)
+	}
+	return newGCM(key)
+}
+
+func (s *EncryptedStorage) openLegacy(data []byte) ([]byte, error) {
+	if s.keys.legacy == nil {
+		return nil, errors.New("sealed with a key_file, which isn't configured")
+	}
+	sealed := data[len(encryptedMagic):]
+	if len(sealed) < s.keys.legacy.NonceSize() {
+		return nil, errors.New("payload truncated")
+	}
+	return s.keys.legacy.Open(nil, sealed[:s.keys.legacy.NonceSize()], sealed[s.keys.legacy.NonceSize():], nil)
+}
+
+// Rewrap wraps the data key of a payload sealed under an older master key
+// version with the current one. Payloads sealed before envelope
+// encryption, or stored before encryption was enabled, are sealed anew.
+func (s *EncryptedStorage) Rewrap(id string) (bool, error) {
+	data, err := s.StorageInterface.Load(id)
+	if err != nil {
+		return false, err
+	}
+	if !bytes.HasPrefix(data, envelopeMagic) {
+		if bytes.HasPrefix(data, encryptedMagic) {
+			if data, err = s.openLegacy(data); err != nil {
+				return false, fmt.Errorf("failed to decrypt %s: %w", id, err)
+			}
+		}
+		return true, s.Save(id, data)
+	}
+
+	e, err := parseEnvelope(data)
+	if err != nil {
+		return false, fmt.Errorf("failed to decrypt %s: %w", id, err)
+	}
+	if e.version == s.keys.current {
+		return false, nil
+	}
+	wrapper, ok := s.keys.wrappers[e.version]
+	if !ok {
+		return false, fmt.Errorf("failed to decrypt %s: unknown master key version %q", id, e.version)
+	}
+	key, err := wrapper.UnwrapKey(e.wrapped)
+	if err != nil {
+		return false, fmt.Errorf("failed to unwrap data key of %s: %w", id, err)
+	}
+	e.version = s.keys.current
+	if e.wrapped, err = s.keys.wrappers[e.version].WrapKey(key); err != nil {
+		return false, fmt.Errorf("failed to wrap data key of %s: %w", id, err)
+	}
+	return true, s.StorageInterface.Save(id, e.encode())
+}
+
+// compressedMagic prefixes payloads gzipped by the compression decorator
+var compressedMagic = []byte("\x00gz1")
+
+// CompressedStorage gzips payloads of at least minBytes when that makes
+// them smaller. Payloads that happen to start with the magic are always
+// compressed, so they can't be mistaken for compressed ones.
+type CompressedStorage struct {
+	StorageInterface
+	minBytes int
+}
+
+func newCompressionDecorator(_ string, config config.DecoratorConfig, _ *metrics.Metrics) (Decorator, error) {
+	minBytes := config.MinBytes
+	if minBytes < 0 {
+		return nil, errors.New("min_bytes must not be negative")
+	}
+	if minBytes == 0 {
+		minBytes = 1024
+	}
+	return func(next StorageInterface) StorageInterface {
+		return &CompressedStorage{StorageInterface: next, minBytes: minBytes}
+	}, nil
+}
+
+func (s *CompressedStorage) Save(id string, data []byte) error {
+	ambiguous := bytes.HasPrefix(data, compressedMagic)
+	if len(data) < s.minBytes && !ambiguous {
+		return s.StorageInterface.Save(id, data)
+	}
+	var buf bytes.Buffer
+	buf.Write(compressedMagic)
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write(data); err != nil {
+		return fmt.Errorf("failed to compress %s: %w", id, err)
+	}
+	if err := zw.Close(); err != nil {
+		return fmt.Errorf("failed to compress %s: %w", id, err)
+	}
+	if buf.Len() >= len(data) && !ambiguous {
+		return s.StorageInterface.Save(id, data)
+	}
+	return s.StorageInterface.Save(id, buf.Bytes())
+}
+
+func (s *CompressedStorage) Load(id string) ([]byte, error) {
+	data, err := s.StorageInterface.Load(id)
+	if err != nil || !bytes.HasPrefix(data, compressedMagic) {
+		return data, err
+	}
+	zr, err := gzip.NewReader(bytes.NewReader(data[len(compressedMagic):]))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decompress %s: %w", id, err)
+	}
+	data, err = io.ReadAll(zr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decompress %s: %w", id, err)
+	}
+	return data, nil
+}
+
+func (s *CompressedStorage) Rewrap(id string) (bool, error) {
+	return Rewrap(s.StorageInterface, id)
+}
@@ -0,0 +1,30 @@
+package storage
+
+import (
+	"bytes"
+	"log"
+	"strings"
+	"testing"
+
+	"interview-task/internal/config"
+	"interview-task/internal/logging"
+	"interview-task/internal/metrics"
+)
+
+func TestMetricsDecoratorDeprecated(t *testing.T) {
+	var logged bytes.Buffer
+	logging.SetLogger(log.New(&logged, "", 0))
+	t.Cleanup(func() { logging.SetLogger(log.Default()) })
+
+	chain, err := newDecoratorChain("file", []config.DecoratorConfig{{Type: "metrics"}}, metrics.NewMetrics())
+	if err != nil {
+		t.Fatal(err)
+	}
+	inner := &copyStub{}
+	if got := chain(inner); got != inner {
+		t.Fatalf("metrics decorator wrapped the storage in %T", got)
+	}
+	if !strings.Contains(logged.String(), "deprecated") {
+		t.Fatalf("metrics decorator logged %q, want a deprecation warning", logged.String())
+	}
+}
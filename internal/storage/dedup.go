@@ -0,0 +1,318 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"interview-task/internal/config"
+	"interview-task/internal/logging"
+)
+
+// dedupRefPrefix starts a record that points at a shared blob rather than
+// holding the payload itself
+var dedupRefPrefix = []byte("\x00dedup:sha256:")
+
+// blobItemID is where a payload with the given SHA-256 is stored once per
+// tenant and storage type
+func blobItemID(sum string) string {
+	return sum + "~blob"
+}
+
+// DedupGCStats reports a garbage collection run
+type DedupGCStats struct {
+	StartedAt  time.Time `json:"started_at"`
+	FinishedAt time.Time `json:"finished_at"`
+	Scanned    int       `json:"scanned"`
+	Blobs      int       `json:"blobs"`
+	Removed    int       `json:"removed"`
+	Failed     int       `json:"failed"`
+}
+
+// Deduplicator - IMPLEMENTS payload deduplication. Storage wrapped by it
+// keeps one blob per distinct payload and stores references under item
+// keys; a mark-and-sweep collector removes blobs nothing references.
+type Deduplicator struct {
+	config config.DedupConfig
+
+	mu sync.Mutex
+	// writing counts saves in flight per blob, and touched records every
+	// blob saved while sweeps run, so a sweep never removes a blob whose
+	// reference it couldn't have seen
+	writing    map[string]int
+	touched    map[string]bool
+	sweeps     int
+	collecting bool
+	last       DedupGCStats
+
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+func NewDeduplicator(config config.DedupConfig) *Deduplicator {
+	return &Deduplicator{config: config, writing: make(map[string]int)}
+}
+
+// wrap resolves references on every storage, even with deduplication
+// disabled, so items saved while it was enabled stay readable
+func (d *Deduplicator) wrap(storage StorageInterface) StorageInterface {
+	return &DedupStorage{inner: storage, dedup: d}
+}
+
+func (d *Deduplicator) beginWrite(sum string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.writing[sum]++
+	if d.sweeps > 0 {
+		d.touched[sum] = true
+	}
+}
+
+func (d *Deduplicator) endWrite(sum string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.writing[sum]--; d.writing[sum] == 0 {
+		delete(d.writing, sum)
+	}
+	if d.sweeps > 0 {
+		d.touched[sum] = true
+	}
+}
+
+// BeginSweep starts tracking blob saves for a sweep removing blobs with
+// RemoveBlob; EndSweep ends it. Sweeps may overlap.
+func (d *Deduplicator) BeginSweep() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.sweeps++; d.sweeps == 1 {
+		d.touched = make(map[string]bool)
+	}
+}
+
+func (d *Deduplicator) EndSweep() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.sweeps--; d.sweeps == 0 {
+		d.touched = nil
+	}
+}
+
+// RemoveBlob deletes a blob from storage below deduplication, unless a
+// save has written it since the sweep began
+func (d *Deduplicator) RemoveBlob(storage StorageInterface, sum string) (bool, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.writing[sum] > 0 || d.touched[sum] {
+		return false, nil
+	}
+	err := storage.Delete(blobItemID(sum))
+	if errors.Is(err, ErrNotFound) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// BlobSum returns the SHA-256 of the payload a blob key holds, if it is one
+func BlobSum(key string) (string, bool) {
+	return strings.CutSuffix(key, "~blob")
+}
+
+// BlobRef returns the SHA-256 of the blob a stored record references, if it
+// is a reference
+func BlobRef(data []byte) (string, bool) {
+	sum, ok := bytes.CutPrefix(data, dedupRefPrefix)
+	return string(sum), ok
+}
+
+// Start runs garbage collection every GCInterval until Shutdown
+func (d *Deduplicator) Start(factory *ConcreteStorageFactory) {
+	interval := time.Duration(d.config.GCInterval)
+	if interval <= 0 {
+		return
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	d.cancel, d.done = cancel, make(chan struct{})
+	go d.run(ctx, factory, interval, d.done)
+}
+
+func (d *Deduplicator) run(ctx context.Context, factory *ConcreteStorageFactory, interval time.Duration, done chan struct{}) {
+	defer close(done)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		if _, err := d.Collect(ctx, factory); err != nil && !errors.Is(err, context.Canceled) {
+			logging.Printf("Blob garbage collection failed: %v", err)
+		}
+	}
+}
+
+func (d *Deduplicator) Shutdown() {
+	if d.cancel != nil {
+		d.cancel()
+		<-d.done
+	}
+}
+
+// Collect removes every blob no item or version references, across all
+// tenants and storage types
+func (d *Deduplicator) Collect(ctx context.Context, factory *ConcreteStorageFactory) (DedupGCStats, error) {
+	d.mu.Lock()
+	if d.collecting {
+		d.mu.Unlock()
+		return DedupGCStats{}, fmt.Errorf("%w: garbage collection is already running", ErrConflict)
+	}
+	d.collecting = true
+	d.mu.Unlock()
+
+	d.BeginSweep()
+	stats := DedupGCStats{StartedAt: time.Now().UTC()}
+	err := d.collectAll(ctx, factory, &stats)
+	stats.FinishedAt = time.Now().UTC()
+	d.EndSweep()
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.collecting = false
+	d.last = stats
+	return stats, err
+}
+
+func (d *Deduplicator) collectAll(ctx context.Context, factory *ConcreteStorageFactory, stats *DedupGCStats) error {
+	return factory.EachTenant(func(tenant, storageType string) error {
+		sharded, err := factory.Sharded(tenant, storageType)
+		if err != nil {
+			return err
+		}
+		storage := &DedupStorage{inner: sharded, dedup: d}
+		return storage.collect(ctx, stats)
+	})
+}
+
+// LastCollection returns the stats of the most recent garbage collection
+func (d *Deduplicator) LastCollection() DedupGCStats {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.last
+}
+
+// DedupStorage stores payloads of at least MinSize as shared blobs
+type DedupStorage struct {
+	inner StorageInterface
+	dedup *Deduplicator
+}
+
+// isPayloadKey reports whether a key holds an item's payload or a retained
+// version of it, the only records worth deduplicating
+func isPayloadKey(key string) bool {
+	_, link, linked := strings.Cut(key, "~")
+	return !linked || VersionLinkName.MatchString(link)
+}
+
+func (s *DedupStorage) Save(id string, data []byte) error {
+	config := s.dedup.config
+	if !config.Enabled || len(data) < config.MinSize || !isPayloadKey(id) {
+		return s.inner.Save(id, data)
+	}
+
+	sum := PayloadSHA256(data)
+	s.dedup.beginWrite(sum)
+	defer s.dedup.endWrite(sum)
+
+	_, err := s.inner.Load(blobItemID(sum))
+	if errors.Is(err, ErrNotFound) {
+		err = s.inner.Save(blobItemID(sum), data)
+	}
+	if err != nil {
+		return err
+	}
+	return s.inner.Save(id, append(bytes.Clone(dedupRefPrefix), sum...))
+}
+
+func (s *DedupStorage) Load(id string) ([]byte, error) {
+	data, err := s.inner.Load(id)
+	if err != nil {
+		return nil, err
+	}
+	sum, ok := bytes.CutPrefix(data, dedupRefPrefix)
+	if !ok {
+		return data, nil
+	}
+	blob, err := s.inner.Load(blobItemID(string(sum)))
+	if errors.Is(err, ErrNotFound) {
+		return nil, fmt.Errorf("blob %s referenced by %s is missing", sum, id)
+	}
+	return blob, err
+}
+
+// Delete removes the reference only; the blob is left to the collector
+func (s *DedupStorage) Delete(id string) error {
+	return s.inner.Delete(id)
+}
+
+func (s *DedupStorage) List() ([]string, error) {
+	return s.inner.List()
+}
+
+// collect marks the blobs referenced by this storage's keys and removes the
+// rest
+func (s *DedupStorage) collect(ctx context.Context, stats *DedupGCStats) error {
+	keys, err := s.inner.List()
+	if err != nil {
+		return err
+	}
+
+	referenced := make(map[string]bool)
+	var blobs []string
+	for _, key := range keys {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		stats.Scanned++
+		if sum, ok := BlobSum(key); ok {
+			blobs = append(blobs, sum)
+			continue
+		}
+		data, err := s.inner.Load(key)
+		if errors.Is(err, ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			// An unreadable key may still reference a blob, so nothing is
+			// removed from this storage
+			return fmt.Errorf("failed to read %s: %w", key, err)
+		}
+		if sum, ok := BlobRef(data); ok {
+			referenced[sum] = true
+		}
+	}
+
+	stats.Blobs += len(blobs)
+	for _, sum := range blobs {
+		if referenced[sum] {
+			continue
+		}
+		removed, err := s.dedup.RemoveBlob(s.inner, sum)
+		if err != nil {
+			stats.Failed++
+			logging.Printf("Failed to remove blob %s: %v", sum, err)
+			continue
+		}
+		if removed {
+			stats.Removed++
+		}
+	}
+	return nil
+}
+
+// VersionLinkName matches the link names of an item's retained versions
+var VersionLinkName = regexp.MustCompile(`^v[0-9]+$`)
@@ -0,0 +1,111 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+var (
+	ErrInsufficientStorage = errors.New("insufficient storage")
+	ErrStorageUnavailable  = errors.New("storage unavailable")
+)
+
+// diskCheckInterval is how long a disk check result is reused, so writes
+// don't each probe the disk
+const diskCheckInterval = time.Second
+
+// DiskChecker - IMPLEMENTS the pre-write checks of the file-based storage
+// types: the data directory exists, is writable and has free space left
+type DiskChecker struct {
+	dir string
+	// minFree is the free space in bytes writes must leave; zero disables
+	// the free space check
+	minFree uint64
+
+	mu        sync.Mutex
+	checkedAt time.Time
+	last      error
+}
+
+func NewDiskChecker(dir string, minFree int64) *DiskChecker {
+	return &DiskChecker{dir: dir, minFree: uint64(max(minFree, 0))}
+}
+
+// Check returns ErrStorageUnavailable when the directory can't be written
+// and ErrInsufficientStorage when it is short of space
+func (c *DiskChecker) Check() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if time.Since(c.checkedAt) < diskCheckInterval {
+		return c.last
+	}
+	c.last, c.checkedAt = c.check(), time.Now()
+	return c.last
+}
+
+func (c *DiskChecker) check() error {
+	info, err := os.Stat(c.dir)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrStorageUnavailable, err)
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("%w: %s is not a directory", ErrStorageUnavailable, c.dir)
+	}
+	probe, err := os.CreateTemp(c.dir, ".write-check-*")
+	if err != nil {
+		return fmt.Errorf("%w: %s is not writable: %w", ErrStorageUnavailable, c.dir, err)
+	}
+	probe.Close()
+	os.Remove(probe.Name())
+
+	if c.minFree == 0 {
+		return nil
+	}
+	free, ok, err := freeSpace(c.dir)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrStorageUnavailable, err)
+	}
+	if ok && free < c.minFree {
+		return fmt.Errorf("%w: %d bytes free in %s, %d required", ErrInsufficientStorage, free, c.dir, c.minFree)
+	}
+	return nil
+}
+
+// FreeSpace returns the free bytes of the data directory's filesystem; ok is
+// false on platforms where it can't be read
+func (c *DiskChecker) FreeSpace() (free uint64, ok bool, err error) {
+	return freeSpace(c.dir)
+}
+
+// forDir returns a checker of another directory with the same free space
+// requirement; nil stays nil
+func (c *DiskChecker) forDir(dir string) *DiskChecker {
+	if c == nil {
+		return nil
+	}
+	return NewDiskChecker(dir, int64(c.minFree))
+}
+
+func (c *DiskChecker) wrap(storage StorageInterface) StorageInterface {
+	if c == nil {
+		return storage
+	}
+	return &DiskCheckedStorage{StorageInterface: storage, disk: c}
+}
+
+// DiskCheckedStorage runs the disk checks before every save. Deletes go
+// through regardless, since they are how a full disk gets space back.
+type DiskCheckedStorage struct {
+	StorageInterface
+	disk *DiskChecker
+}
+
+func (s *DiskCheckedStorage) Save(id string, data []byte) error {
+	if err := s.disk.Check(); err != nil {
+		return err
+	}
+	return s.StorageInterface.Save(id, data)
+}
@@ -0,0 +1,18 @@
+//go:build linux || darwin
+
+package storage
+
+import (
+	"fmt"
+	"syscall"
+)
+
+// freeSpace returns the bytes available to unprivileged users on the
+// filesystem holding dir
+func freeSpace(dir string) (uint64, bool, error) {
+	var stat syscall.Statfs_t
+	if err := syscall.Statfs(dir, &stat); err != nil {
+		return 0, false, fmt.Errorf("failed to stat filesystem: %w", err)
+	}
+	return uint64(stat.Bavail) * uint64(stat.Bsize), true, nil
+}
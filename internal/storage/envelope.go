@@ -0,0 +1,260 @@
+package storage
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+
+	"interview-task/internal/config"
+)
+
+// legacyKeyVersion is the master key version of a key_file
+const legacyKeyVersion = "1"
+
+// KeyWrapper wraps the data keys of envelope encryption with a master key.
+// Wrapped keys are stored with every payload, so they must stay unwrappable
+// for as long as the master key version is configured.
+type KeyWrapper interface {
+	WrapKey(key []byte) ([]byte, error)
+	UnwrapKey(wrapped []byte) ([]byte, error)
+}
+
+// KeyProviderFactory opens the master key a reference names, the part of
+// a master_keys entry after its scheme
+type KeyProviderFactory func(ref string) (KeyWrapper, error)
+
+var (
+	keyProvidersMu sync.RWMutex
+	keyProviders   = map[string]KeyProviderFactory{
+		"env":  envKeyProvider,
+		"file": fileKeyProvider,
+	}
+)
+
+// RegisterKeyProvider makes a master key scheme available to master_keys,
+// such as one wrapping data keys with a KMS so the master key never leaves
+// it. Call it before the server is created; registering a scheme twice
+// replaces it.
+func RegisterKeyProvider(scheme string, factory KeyProviderFactory) {
+	keyProvidersMu.Lock()
+	defer keyProvidersMu.Unlock()
+	keyProviders[scheme] = factory
+}
+
+func openMasterKey(reference string) (KeyWrapper, error) {
+	scheme, ref, ok := strings.Cut(reference, ":")
+	if !ok {
+		return nil, fmt.Errorf("master key %q has no scheme", reference)
+	}
+	keyProvidersMu.RLock()
+	factory, ok := keyProviders[scheme]
+	keyProvidersMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown master key scheme: %q", scheme)
+	}
+	return factory(ref)
+}
+
+func envKeyProvider(name string) (KeyWrapper, error) {
+	encoded, ok := os.LookupEnv(name)
+	if !ok {
+		return nil, fmt.Errorf("environment variable %s is not set", name)
+	}
+	return newAEADKeyWrapper(encoded)
+}
+
+func fileKeyProvider(path string) (KeyWrapper, error) {
+	encoded, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read key: %w", err)
+	}
+	return newAEADKeyWrapper(string(encoded))
+}
+
+// decodeKey decodes a hex-encoded 32-byte AES-256 key
+func decodeKey(encoded string) ([]byte, error) {
+	key, err := hex.DecodeString(strings.TrimSpace(encoded))
+	if err != nil || len(key) != 32 {
+		return nil, errors.New("key must be a hex-encoded 32-byte key")
+	}
+	return key, nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// aeadKeyWrapper wraps data keys with a local master key, the nonce
+// preceding the sealed key
+type aeadKeyWrapper struct {
+	aead cipher.AEAD
+}
+
+func newAEADKeyWrapper(encoded string) (KeyWrapper, error) {
+	key, err := decodeKey(encoded)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	return aeadKeyWrapper{aead: aead}, nil
+}
+
+func (w aeadKeyWrapper) WrapKey(key []byte) ([]byte, error) {
+	nonce := make([]byte, w.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	return w.aead.Seal(nonce, nonce, key, nil), nil
+}
+
+func (w aeadKeyWrapper) UnwrapKey(wrapped []byte) ([]byte, error) {
+	if len(wrapped) < w.aead.NonceSize() {
+		return nil, errors.New("wrapped key truncated")
+	}
+	return w.aead.Open(nil, wrapped[:w.aead.NonceSize()], wrapped[w.aead.NonceSize():], nil)
+}
+
+// masterKeys are the master key versions of a storage type's encryption
+type masterKeys struct {
+	current  string
+	wrappers map[string]KeyWrapper
+	// legacy reads payloads sealed with the key_file before envelope
+	// encryption; nil without one
+	legacy cipher.AEAD
+}
+
+// currentKeyVersion is the master key version an encryption config seals
+// new payloads with
+func currentKeyVersion(config config.DecoratorConfig) string {
+	if config.KeyVersion == "" && len(config.MasterKeys) == 0 {
+		return legacyKeyVersion
+	}
+	return config.KeyVersion
+}
+
+func newMasterKeys(config config.DecoratorConfig) (*masterKeys, error) {
+	keys := &masterKeys{current: currentKeyVersion(config), wrappers: make(map[string]KeyWrapper)}
+	if config.KeyFile != "" {
+		encoded, err := os.ReadFile(config.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read key: %w", err)
+		}
+		key, err := decodeKey(string(encoded))
+		if err != nil {
+			return nil, fmt.Errorf("key_file: %w", err)
+		}
+		if keys.legacy, err = newGCM(key); err != nil {
+			return nil, err
+		}
+		keys.wrappers[legacyKeyVersion] = aeadKeyWrapper{aead: keys.legacy}
+	}
+	for version, reference := range config.MasterKeys {
+		if version == "" || len(version) > 255 {
+			return nil, fmt.Errorf("invalid master key version: %q", version)
+		}
+		if _, ok := keys.wrappers[version]; ok {
+			return nil, fmt.Errorf("master key version %q is the key_file", version)
+		}
+		wrapper, err := openMasterKey(reference)
+		if err != nil {
+			return nil, fmt.Errorf("master key %s: %w", version, err)
+		}
+		keys.wrappers[version] = wrapper
+	}
+	switch {
+	case len(keys.wrappers) == 0:
+		return nil, errors.New("master_keys or key_file is required")
+	case keys.current == "":
+		return nil, errors.New("key_version is required with master_keys")
+	case keys.wrappers[keys.current] == nil:
+		return nil, fmt.Errorf("key_version %q is not a master key", keys.current)
+	}
+	return keys, nil
+}
+
+// envelopeMagic prefixes payloads sealed by envelope encryption. It is
+// followed by the master key version and the wrapped data key, each
+// length-prefixed, then the nonce and the ciphertext.
+var envelopeMagic = []byte("\x00enc2")
+
+// envelope is a parsed envelope-encrypted payload
+type envelope struct {
+	version string
+	wrapped []byte
+	// sealed is the nonce followed by the ciphertext
+	sealed []byte
+}
+
+func parseEnvelope(data []byte) (*envelope, error) {
+	rest := data[len(envelopeMagic):]
+	if len(rest) < 1 || len(rest) < 1+int(rest[0])+2 {
+		return nil, errors.New("payload truncated")
+	}
+	e := &envelope{version: string(rest[1 : 1+rest[0]])}
+	rest = rest[1+rest[0]:]
+	size := int(binary.BigEndian.Uint16(rest))
+	if len(rest) < 2+size {
+		return nil, errors.New("payload truncated")
+	}
+	e.wrapped, e.sealed = rest[2:2+size], rest[2+size:]
+	return e, nil
+}
+
+func (e *envelope) encode() []byte {
+	var buf bytes.Buffer
+	buf.Write(envelopeMagic)
+	buf.WriteByte(byte(len(e.version)))
+	buf.WriteString(e.version)
+	buf.Write(binary.BigEndian.AppendUint16(nil, uint16(len(e.wrapped))))
+	buf.Write(e.wrapped)
+	buf.Write(e.sealed)
+	return buf.Bytes()
+}
+
+// rewrapper is storage that can wrap a payload's data key with the current
+// master key without decrypting the payload
+type rewrapper interface {
+	Rewrap(id string) (bool, error)
+}
+
+// Rewrap brings a stored payload to the current master key version of its
+// storage type, reporting whether it had to. Storage without encryption has
+// nothing to rewrap.
+func Rewrap(storage StorageInterface, id string) (bool, error) {
+	if r, ok := storage.(rewrapper); ok {
+		return r.Rewrap(id)
+	}
+	return false, nil
+}
+
+// KeyVersioner is a factory that reports the master key version its
+// storage types encrypt payloads with
+type KeyVersioner interface {
+	KeyVersion(storageType string) string
+}
+
+// KeyVersion is the master key version new payloads of a storage type are
+// encrypted with, empty when its chain has no encryption
+func (f *ConcreteStorageFactory) KeyVersion(storageType string) string {
+	for _, config := range f.decorators[storageType] {
+		if config.Type == "encryption" && !config.Disabled {
+			return currentKeyVersion(config)
+		}
+	}
+	return ""
+}
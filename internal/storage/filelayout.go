@@ -0,0 +1,52 @@
+package storage
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"interview-task/internal/config"
+)
+
+// fileLayout places files according to a FileLayout
+type fileLayout config.FileLayout
+
+// hashDirs are the subdirectories for a key. Linked records hash like their
+// item so they land next to it.
+func (l fileLayout) hashDirs(key string) []string {
+	sum := sha256.Sum256([]byte(ShardKey(key)))
+	digits := hex.EncodeToString(sum[:l.HashLevels])
+	dirs := make([]string, l.HashLevels)
+	for i := range dirs {
+		dirs[i] = digits[2*i : 2*i+2]
+	}
+	return dirs
+}
+
+// path is where a new key is written at time now
+func (l fileLayout) path(dir, key string, now time.Time) string {
+	parts := []string{dir}
+	if layout, ok := config.RotationLayouts[l.Rotation]; ok {
+		parts = append(parts, filepath.FromSlash(now.UTC().Format(layout)))
+	}
+	parts = append(parts, l.hashDirs(key)...)
+	return filepath.Join(append(parts, key+".dat")...)
+}
+
+// candidates are the files that may hold key under this layout. With
+// rotation the dated folder is unknown, so every one is searched.
+func (l fileLayout) candidates(dir, key string) []string {
+	layout, rotated := config.RotationLayouts[l.Rotation]
+	if !rotated {
+		return []string{l.path(dir, key, time.Time{})}
+	}
+	parts := []string{dir}
+	for range strings.Split(layout, "/") {
+		parts = append(parts, "*")
+	}
+	parts = append(parts, l.hashDirs(key)...)
+	matches, _ := filepath.Glob(filepath.Join(append(parts, key+".dat")...))
+	return matches
+}
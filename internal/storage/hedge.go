@@ -0,0 +1,117 @@
+package storage
+
+import (
+	"errors"
+	"time"
+
+	"interview-task/internal/config"
+	"interview-task/internal/metrics"
+)
+
+// Hedger - IMPLEMENTS hedged reads of replicated storage types. Instead of
+// asking every copy of a key at once, a load goes to the first copy that
+// isn't stale and is repeated on the next one when it hasn't been answered
+// within the delay; a copy failing or missing the key hedges to the next
+// one at once. The first copy holding the key wins. Only the primary's
+// ErrNotFound ends the read, as a replica missing the key may be behind.
+type Hedger struct {
+	config  config.HedgingConfig
+	metrics *metrics.Metrics
+}
+
+func NewHedger(config config.HedgingConfig, metrics *metrics.Metrics) *Hedger {
+	metrics.Describe("hedged_reads_total", "Reads hedged to a replica by storage type and the backend that answered")
+	return &Hedger{config: config, metrics: metrics}
+}
+
+func (h *Hedger) enabled() bool {
+	return h != nil && h.config.Enabled
+}
+
+func (h *Hedger) validate() error {
+	if h.enabled() && h.config.Delay <= 0 {
+		return errors.New("hedging delay must be positive")
+	}
+	return nil
+}
+
+// final reports whether a copy's answer ends a hedged read: it holds the
+// key, or it is the primary and doesn't
+func (r replicaResult) final() bool {
+	return r.err == nil || r.i == 0 && errors.Is(r.err, ErrNotFound)
+}
+
+// hedgedLoad loads id from the asked copies in order, hedging to the next
+// one when a copy is slow or can't answer. The copies still loading when it
+// returns are compared with the answer in the background, as every read of
+// replicated storage is.
+func (s *ReplicatedStorage) hedgedLoad(id string, asked []int) ([]byte, error) {
+	h := s.replicator.hedger
+	copies := s.replicator.copies(s.storageType)
+	// Buffered for every copy, so the losers don't block
+	results := make(chan replicaResult, len(asked))
+	load := func(i int) {
+		storage, err := s.open(i, copies[i])
+		var data []byte
+		if err == nil {
+			data, err = storage.Load(id)
+		}
+		results <- replicaResult{i: i, data: data, err: err}
+	}
+
+	answer := make(chan replicaResult, 1)
+	go func() {
+		go load(asked[0])
+		timer := time.NewTimer(time.Duration(h.config.Delay))
+		defer timer.Stop()
+		var answers []replicaResult
+		var failure *replicaResult
+		sent := false
+		pending, next := 1, 1
+		for pending > 0 {
+			hedge := false
+			select {
+			case result := <-results:
+				pending--
+				switch {
+				case result.final():
+					if !sent {
+						if next > 1 {
+							h.metrics.Add("hedged_reads_total", 1, "storage_type", s.storageType, "backend", copies[result.i])
+						}
+						answer <- result
+						sent = true
+					}
+					answers = append(answers, result)
+					continue
+				case errors.Is(result.err, ErrNotFound):
+					// A replica missing the key may be behind; the key is
+					// only missing if every copy asked says so
+					failure = &result
+					answers = append(answers, result)
+				case failure == nil:
+					failure = &result
+				}
+				hedge = true
+			case <-timer.C:
+				hedge = true
+			}
+			if hedge && !sent && next < len(asked) {
+				go load(asked[next])
+				next++
+				pending++
+				timer.Reset(time.Duration(h.config.Delay))
+			}
+		}
+		if !sent {
+			if next > 1 {
+				// Every copy asked failed; the failure is reported
+				h.metrics.Add("hedged_reads_total", 1, "storage_type", s.storageType, "backend", "none")
+			}
+			answer <- *failure
+		}
+		s.readRepair(id, answers)
+	}()
+	result := <-answer
+	return result.data, result.err
+}
@@ -0,0 +1,85 @@
+package storage
+
+import (
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"interview-task/internal/config"
+	"interview-task/internal/metrics"
+)
+
+// copyStub is one copy of a replicated storage type answering every load
+// the same way after a delay
+type copyStub struct {
+	data  []byte
+	err   error
+	delay time.Duration
+	loads atomic.Int32
+}
+
+func (c *copyStub) Load(string) ([]byte, error) {
+	c.loads.Add(1)
+	time.Sleep(c.delay)
+	return c.data, c.err
+}
+
+func (c *copyStub) Save(string, []byte) error { return nil }
+func (c *copyStub) Delete(string) error       { return nil }
+func (c *copyStub) List() ([]string, error)   { return nil, nil }
+
+// newHedgedStorage replicates "file" to "database" and "log", hedging
+// reads after delay
+func newHedgedStorage(primary, database, log *copyStub, delay time.Duration) *ReplicatedStorage {
+	m := metrics.NewMetrics()
+	r := NewReplicator(config.ReplicationConfig{Replicas: map[string][]string{"file": {"database", "log"}}}, m)
+	r.hedger = NewHedger(config.HedgingConfig{Enabled: true, Delay: config.Duration(delay)}, m)
+	replicas := map[string]*copyStub{"database": database, "log": log}
+	r.open = func(tenant, storageType string) (StorageInterface, error) { return replicas[storageType], nil }
+	r.invalidate = func(tenant, storageType, key string) {}
+	return &ReplicatedStorage{primary: primary, replicator: r, tenant: "tenant", storageType: "file"}
+}
+
+func TestHedgedLoadSkipsReplicaMissingKey(t *testing.T) {
+	primary := &copyStub{data: []byte("x"), delay: time.Second}
+	database := &copyStub{err: ErrNotFound}
+	log := &copyStub{data: []byte("x")}
+	s := newHedgedStorage(primary, database, log, 10*time.Millisecond)
+
+	start := time.Now()
+	data, err := s.Load("id")
+	if err != nil || string(data) != "x" {
+		t.Fatalf("Load = %q, %v, want the log replica's copy", data, err)
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Fatalf("Load waited %v for the slow primary", elapsed)
+	}
+}
+
+func TestHedgedLoadPrimaryNotFoundIsFinal(t *testing.T) {
+	primary := &copyStub{err: ErrNotFound}
+	database := &copyStub{data: []byte("x")}
+	log := &copyStub{data: []byte("x")}
+	s := newHedgedStorage(primary, database, log, time.Second)
+
+	if _, err := s.Load("id"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Load returned %v, want ErrNotFound", err)
+	}
+	if database.loads.Load() != 0 || log.loads.Load() != 0 {
+		t.Fatal("replicas were asked after the primary found no key")
+	}
+}
+
+func TestHedgedLoadEveryCopyFailing(t *testing.T) {
+	failed := errors.New("connection reset")
+	primary := &copyStub{err: failed}
+	database := &copyStub{err: ErrNotFound}
+	log := &copyStub{err: failed}
+	s := newHedgedStorage(primary, database, log, time.Second)
+
+	// A replica missing the key says more than a failure
+	if _, err := s.Load("id"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Load returned %v, want ErrNotFound", err)
+	}
+}
@@ -0,0 +1,115 @@
+package storage
+
+import (
+	"maps"
+	"slices"
+	"strings"
+	"time"
+)
+
+// IndexRecord is a row of the item_index table, describing an item kept in
+// a storage backend
+type IndexRecord struct {
+	Tenant      string `json:"tenant"`
+	StorageType string `json:"storage_type"`
+	ID          string `json:"id"`
+	// Size is the current payload's; StoredBytes adds retained versions
+	Size        int64             `json:"size"`
+	StoredBytes int64             `json:"stored_bytes"`
+	SHA256      string            `json:"sha256"`
+	ContentType string            `json:"content_type,omitempty"`
+	Owner       string            `json:"owner,omitempty"`
+	Labels      map[string]string `json:"labels,omitempty"`
+	Source      string            `json:"source,omitempty"`
+	// Fields are the values extracted by the metadata rules
+	Fields map[string]any `json:"fields,omitempty"`
+	// Location is the storage type and shard holding the item's records
+	Location   string    `json:"location"`
+	CreatedAt  time.Time `json:"created_at,omitzero"`
+	ModifiedAt time.Time `json:"modified_at,omitzero"`
+	ExpiresAt  time.Time `json:"expires_at,omitzero"`
+	DeletedAt  time.Time `json:"deleted_at,omitzero"`
+}
+
+// indexKey is the primary key of the item_index table
+type indexKey struct {
+	tenant, storageType, id string
+}
+
+// PutIndexRecord inserts or replaces an item's row
+func (db *DatabaseConnection) PutIndexRecord(record IndexRecord) error {
+	return db.do(func() error {
+		db.mu.Lock()
+		defer db.mu.Unlock()
+		if db.index == nil {
+			db.index = make(map[indexKey]IndexRecord)
+		}
+		db.index[indexKey{record.Tenant, record.StorageType, record.ID}] = record
+		return nil
+	})
+}
+
+// DeleteIndexRecord removes an item's row, or fails with ErrNotFound
+func (db *DatabaseConnection) DeleteIndexRecord(tenant, storageType, id string) error {
+	return db.do(func() error {
+		db.mu.Lock()
+		defer db.mu.Unlock()
+		key := indexKey{tenant, storageType, id}
+		if _, ok := db.index[key]; !ok {
+			return ErrNotFound
+		}
+		delete(db.index, key)
+		return nil
+	})
+}
+
+// GetIndexRecord returns an item's row, or ErrNotFound
+func (db *DatabaseConnection) GetIndexRecord(tenant, storageType, id string) (*IndexRecord, error) {
+	var record *IndexRecord
+	err := db.do(func() error {
+		db.mu.RLock()
+		defer db.mu.RUnlock()
+		current, ok := db.index[indexKey{tenant, storageType, id}]
+		if !ok {
+			return ErrNotFound
+		}
+		record = &current
+		return nil
+	})
+	return record, err
+}
+
+// ListIndexRecords returns the rows of a tenant's storage type ordered by ID
+func (db *DatabaseConnection) ListIndexRecords(tenant, storageType string) ([]IndexRecord, error) {
+	var records []IndexRecord
+	err := db.do(func() error {
+		db.mu.RLock()
+		defer db.mu.RUnlock()
+		for key, record := range db.index {
+			if key.tenant == tenant && key.storageType == storageType {
+				records = append(records, record)
+			}
+		}
+		slices.SortFunc(records, func(a, b IndexRecord) int { return strings.Compare(a.ID, b.ID) })
+		return nil
+	})
+	return records, err
+}
+
+// IndexedTenants returns the tenants with rows in a storage type, sorted
+func (db *DatabaseConnection) IndexedTenants(storageType string) ([]string, error) {
+	var tenants []string
+	err := db.do(func() error {
+		db.mu.RLock()
+		defer db.mu.RUnlock()
+		seen := make(map[string]bool)
+		for key := range db.index {
+			if key.storageType == storageType {
+				seen[key.tenant] = true
+			}
+		}
+		tenants = slices.Sorted(maps.Keys(seen))
+		return nil
+	})
+	return tenants, err
+}
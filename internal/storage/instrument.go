@@ -0,0 +1,100 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"net"
+	"os"
+	"syscall"
+	"time"
+
+	"interview-task/internal/metrics"
+)
+
+// Classes of failed storage calls, so dashboards can tell a full disk from
+// a rejected password
+const (
+	ErrorClassTimeout  = "timeout"
+	ErrorClassAuth     = "auth"
+	ErrorClassCapacity = "capacity"
+	ErrorClassUnknown  = "unknown"
+)
+
+// ErrUnauthorized is what backends wrap when their store rejects the
+// configured credentials
+var ErrUnauthorized = errors.New("storage credentials rejected")
+
+// ClassifyError returns the class of a failed storage call's error
+func ClassifyError(err error) string {
+	var netErr net.Error
+	switch {
+	case errors.Is(err, context.DeadlineExceeded), errors.Is(err, os.ErrDeadlineExceeded),
+		errors.Is(err, ErrPoolTimeout), errors.As(err, &netErr) && netErr.Timeout():
+		return ErrorClassTimeout
+	case errors.Is(err, ErrUnauthorized), errors.Is(err, os.ErrPermission):
+		return ErrorClassAuth
+	case errors.Is(err, ErrInsufficientStorage), errors.Is(err, syscall.ENOSPC), errors.Is(err, syscall.EFBIG):
+		return ErrorClassCapacity
+	}
+	return ErrorClassUnknown
+}
+
+// callOutcome labels a storage call: success, not_found and conflict, which
+// are answers rather than failures, or the class of its error
+func callOutcome(err error) string {
+	switch {
+	case err == nil:
+		return "success"
+	case errors.Is(err, ErrNotFound):
+		return "not_found"
+	case errors.Is(err, ErrConflict):
+		return "conflict"
+	}
+	return ClassifyError(err)
+}
+
+// describeBackendMetrics documents the metrics of instrumentedStorage
+func describeBackendMetrics(metrics *metrics.Metrics) {
+	metrics.Describe("storage_operations_total", "Backend calls by storage type, operation, tenant and outcome, failures by error class")
+	metrics.Describe("storage_operation_microseconds_total", "Time spent in backend calls by storage type, operation and tenant")
+}
+
+// Answered reports outcomes of storage_operations_total that are answers
+// rather than failures of the backend
+func Answered(outcome string) bool {
+	return outcome == "success" || outcome == "not_found" || outcome == "conflict"
+}
+
+// instrumentedStorage counts every call to one tenant's backend, below the
+// decorator chain, so each retry attempt and every shard is counted
+type instrumentedStorage struct {
+	next        StorageInterface
+	storageType string
+	tenant      string
+	metrics     *metrics.Metrics
+}
+
+func (s *instrumentedStorage) observe(operation string, start time.Time, err error) {
+	s.metrics.Add("storage_operations_total", 1, "storage_type", s.storageType, "operation", operation, "tenant", s.tenant, "outcome", callOutcome(err))
+	s.metrics.Add("storage_operation_microseconds_total", time.Since(start).Microseconds(), "storage_type", s.storageType, "operation", operation, "tenant", s.tenant)
+}
+
+func (s *instrumentedStorage) Save(id string, data []byte) (err error) {
+	defer func(start time.Time) { s.observe("save", start, err) }(time.Now())
+	return s.next.Save(id, data)
+}
+
+func (s *instrumentedStorage) Load(id string) (data []byte, err error) {
+	defer func(start time.Time) { s.observe("load", start, err) }(time.Now())
+	return s.next.Load(id)
+}
+
+func (s *instrumentedStorage) Delete(id string) (err error) {
+	defer func(start time.Time) { s.observe("delete", start, err) }(time.Now())
+	return s.next.Delete(id)
+}
+
+func (s *instrumentedStorage) List() (ids []string, err error) {
+	defer func(start time.Time) { s.observe("list", start, err) }(time.Now())
+	return s.next.List()
+}
@@ -0,0 +1,35 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"syscall"
+	"testing"
+)
+
+func TestClassifyError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want string
+	}{
+		{"disk full", &fs.PathError{Op: "write", Path: "item", Err: syscall.ENOSPC}, ErrorClassCapacity},
+		{"min free disk", fmt.Errorf("save: %w", ErrInsufficientStorage), ErrorClassCapacity},
+		{"deadline exceeded", fmt.Errorf("load: %w", context.DeadlineExceeded), ErrorClassTimeout},
+		{"file deadline", &fs.PathError{Op: "read", Path: "item", Err: os.ErrDeadlineExceeded}, ErrorClassTimeout},
+		{"pool timeout", ErrPoolTimeout, ErrorClassTimeout},
+		{"unauthorized", fmt.Errorf("connect: %w", ErrUnauthorized), ErrorClassAuth},
+		{"permission denied", &fs.PathError{Op: "open", Path: "item", Err: os.ErrPermission}, ErrorClassAuth},
+		{"other", errors.New("connection reset"), ErrorClassUnknown},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ClassifyError(tt.err); got != tt.want {
+				t.Errorf("ClassifyError(%v) = %q, want %q", tt.err, got, tt.want)
+			}
+		})
+	}
+}
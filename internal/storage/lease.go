@@ -0,0 +1,42 @@
+package storage
+
+import "time"
+
+// lease is a row of the mock leases table
+type lease struct {
+	holder    string
+	expiresAt time.Time
+}
+
+// AcquireLease takes or renews the lease name for holder until ttl from
+// now. It reports false while another holder's lease hasn't expired.
+func (db *DatabaseConnection) AcquireLease(name, holder string, ttl time.Duration) (bool, error) {
+	var acquired bool
+	err := db.do(func() error {
+		db.mu.Lock()
+		defer db.mu.Unlock()
+		now := time.Now()
+		if current, ok := db.leases[name]; ok && current.holder != holder && now.Before(current.expiresAt) {
+			return nil
+		}
+		if db.leases == nil {
+			db.leases = make(map[string]lease)
+		}
+		db.leases[name] = lease{holder: holder, expiresAt: now.Add(ttl)}
+		acquired = true
+		return nil
+	})
+	return acquired, err
+}
+
+// ReleaseLease gives up the lease name if holder has it
+func (db *DatabaseConnection) ReleaseLease(name, holder string) error {
+	return db.do(func() error {
+		db.mu.Lock()
+		defer db.mu.Unlock()
+		if current, ok := db.leases[name]; ok && current.holder == holder {
+			delete(db.leases, name)
+		}
+		return nil
+	})
+}
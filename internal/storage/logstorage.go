@@ -0,0 +1,404 @@
+package storage
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"interview-task/internal/config"
+	"interview-task/internal/logging"
+)
+
+// Segment files are named after the time they were opened, so they sort in
+// write order
+const (
+	logSegmentPrefix = "segment-"
+	logSegmentSuffix = ".ndjson"
+	logSegmentTime   = "20060102T150405.000000000Z"
+)
+
+// logRecord is one line of a segment. JSON payloads are embedded as is so
+// the log can be read with ordinary tools; anything else is base64.
+type logRecord struct {
+	Time    time.Time       `json:"ts"`
+	ID      string          `json:"id"`
+	Data    json.RawMessage `json:"data,omitempty"`
+	Binary  []byte          `json:"data_base64,omitempty"`
+	Deleted bool            `json:"deleted,omitempty"`
+}
+
+func newLogRecord(id string, data []byte) logRecord {
+	record := logRecord{Time: time.Now().UTC(), ID: id}
+	// Marshalling compacts embedded JSON, which must not change the bytes
+	// that are loaded back, and a null would read back as no payload
+	var compacted bytes.Buffer
+	if json.Compact(&compacted, data) == nil && bytes.Equal(compacted.Bytes(), data) && string(data) != "null" {
+		record.Data = data
+	} else {
+		record.Binary = data
+	}
+	return record
+}
+
+func (r *logRecord) payload() []byte {
+	if r.Data != nil {
+		return r.Data
+	}
+	return r.Binary
+}
+
+// LogBackend keeps one LogStorage per directory, so every request appending
+// to a tenant's log shares its open segment
+type LogBackend struct {
+	config config.LogStorageConfig
+
+	mu   sync.Mutex
+	logs map[string]*LogStorage
+}
+
+func NewLogBackend(config config.LogStorageConfig) *LogBackend {
+	return &LogBackend{config: config, logs: make(map[string]*LogStorage)}
+}
+
+func (b *LogBackend) storage(dir string) *LogStorage {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	storage, ok := b.logs[dir]
+	if !ok {
+		storage = &LogStorage{dir: dir, config: b.config}
+		b.logs[dir] = storage
+	}
+	return storage
+}
+
+// Close closes every open segment
+func (b *LogBackend) Close() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	var errs []error
+	for _, storage := range b.logs {
+		errs = append(errs, storage.Close())
+	}
+	return errors.Join(errs...)
+}
+
+// LogStorage implements Storage interface as an append-only NDJSON log,
+// suited to capturing events. Saves and deletes append a line; loads read the
+// segment holding the key's latest line, found through an index built from
+// every segment on first use.
+type LogStorage struct {
+	dir    string
+	config config.LogStorageConfig
+
+	mu sync.Mutex
+	// index maps each live key to the segment holding its latest record
+	index    map[string]string
+	active   *os.File
+	name     string
+	size     int64
+	openedAt time.Time
+}
+
+func (ls *LogStorage) Save(id string, data []byte) error {
+	return ls.append(newLogRecord(id, data))
+}
+
+func (ls *LogStorage) Load(id string) ([]byte, error) {
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+	if err := ls.loadIndex(); err != nil {
+		return nil, err
+	}
+	segment, ok := ls.index[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+
+	var latest *logRecord
+	err := ls.scan(segment, func(record *logRecord) {
+		if record.ID == id {
+			latest = record
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	if latest == nil || latest.Deleted {
+		return nil, ErrNotFound
+	}
+	return latest.payload(), nil
+}
+
+// Delete appends a tombstone; the deleted payload stays in its segment
+func (ls *LogStorage) Delete(id string) error {
+	ls.mu.Lock()
+	if err := ls.loadIndex(); err != nil {
+		ls.mu.Unlock()
+		return err
+	}
+	_, ok := ls.index[id]
+	ls.mu.Unlock()
+	if !ok {
+		return ErrNotFound
+	}
+	return ls.append(logRecord{Time: time.Now().UTC(), ID: id, Deleted: true})
+}
+
+func (ls *LogStorage) List() ([]string, error) {
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+	if err := ls.loadIndex(); err != nil {
+		return nil, err
+	}
+	ids := make([]string, 0, len(ls.index))
+	for id := range ls.index {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids, nil
+}
+
+// Close closes the open segment. The next append reopens it.
+func (ls *LogStorage) Close() error {
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+	if ls.active == nil {
+		return nil
+	}
+	err := ls.active.Close()
+	ls.active = nil
+	return err
+}
+
+func (ls *LogStorage) append(record logRecord) error {
+	line, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("failed to encode log record: %w", err)
+	}
+	line = append(line, '\n')
+
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+	if err := ls.loadIndex(); err != nil {
+		return err
+	}
+	if err := ls.prepareSegment(int64(len(line))); err != nil {
+		return err
+	}
+	if _, err := ls.active.Write(line); err != nil {
+		return fmt.Errorf("failed to append to log: %w", err)
+	}
+	if err := ls.active.Sync(); err != nil {
+		return fmt.Errorf("failed to sync log: %w", err)
+	}
+	ls.size += int64(len(line))
+	if record.Deleted {
+		delete(ls.index, record.ID)
+	} else {
+		ls.index[record.ID] = ls.name
+	}
+	return nil
+}
+
+// prepareSegment makes sure a segment with room for n more bytes is open,
+// rotating the current one when it is full or too old
+func (ls *LogStorage) prepareSegment(n int64) error {
+	if ls.active == nil {
+		if err := ls.openSegment(true); err != nil {
+			return err
+		}
+	}
+	full := ls.config.MaxSegmentSize > 0 && ls.size > 0 && ls.size+n > ls.config.MaxSegmentSize
+	old := ls.config.MaxSegmentAge > 0 && time.Since(ls.openedAt) >= time.Duration(ls.config.MaxSegmentAge)
+	if !full && !old {
+		return nil
+	}
+	if err := ls.rotate(); err != nil {
+		return err
+	}
+	return ls.openSegment(false)
+}
+
+// openSegment starts a new segment, or with resume reopens the newest
+// uncompressed one left by an earlier run
+func (ls *LogStorage) openSegment(resume bool) error {
+	if err := os.MkdirAll(ls.dir, 0o755); err != nil {
+		return fmt.Errorf("failed to create log directory: %w", err)
+	}
+	now := time.Now().UTC()
+	name := logSegmentPrefix + now.Format(logSegmentTime) + logSegmentSuffix
+	openedAt := now
+	if segments, err := ls.segments(); err != nil {
+		return err
+	} else if n := len(segments); resume && n > 0 && strings.HasSuffix(segments[n-1], logSegmentSuffix) {
+		name = segments[n-1]
+		stamp := strings.TrimSuffix(strings.TrimPrefix(name, logSegmentPrefix), logSegmentSuffix)
+		if openedAt, err = time.Parse(logSegmentTime, stamp); err != nil {
+			return fmt.Errorf("invalid log segment name %s: %w", name, err)
+		}
+	}
+
+	file, err := os.OpenFile(filepath.Join(ls.dir, name), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return fmt.Errorf("failed to open log segment: %w", err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return fmt.Errorf("failed to stat log segment: %w", err)
+	}
+	ls.active, ls.name, ls.size, ls.openedAt = file, name, info.Size(), openedAt
+	return nil
+}
+
+// rotate closes the active segment, compressing it when configured
+func (ls *LogStorage) rotate() error {
+	if err := ls.active.Close(); err != nil {
+		return fmt.Errorf("failed to close log segment: %w", err)
+	}
+	ls.active = nil
+	if !ls.config.Compress {
+		return nil
+	}
+	compressed := ls.name + ".gz"
+	if err := compressSegment(filepath.Join(ls.dir, ls.name), filepath.Join(ls.dir, compressed)); err != nil {
+		// The segment stays readable uncompressed
+		logging.Printf("Failed to compress log segment %s: %v", ls.name, err)
+		return nil
+	}
+	for id, segment := range ls.index {
+		if segment == ls.name {
+			ls.index[id] = compressed
+		}
+	}
+	return nil
+}
+
+// compressSegment replaces src with its gzipped copy dst
+func compressSegment(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.CreateTemp(filepath.Dir(dst), filepath.Base(dst)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(out.Name())
+	defer out.Close()
+
+	zw := gzip.NewWriter(out)
+	if _, err := io.Copy(zw, in); err != nil {
+		return err
+	}
+	if err := zw.Close(); err != nil {
+		return err
+	}
+	if err := out.Sync(); err != nil {
+		return err
+	}
+	if err := out.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(out.Name(), dst); err != nil {
+		return err
+	}
+	return os.Remove(src)
+}
+
+// segments lists the segment files, oldest first
+func (ls *LogStorage) segments() ([]string, error) {
+	entries, err := os.ReadDir(ls.dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read log directory: %w", err)
+	}
+	var names []string
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.Type().IsRegular() && strings.HasPrefix(name, logSegmentPrefix) &&
+			(strings.HasSuffix(name, logSegmentSuffix) || strings.HasSuffix(name, logSegmentSuffix+".gz")) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// loadIndex replays every segment the first time the log is used
+func (ls *LogStorage) loadIndex() error {
+	if ls.index != nil {
+		return nil
+	}
+	segments, err := ls.segments()
+	if err != nil {
+		return err
+	}
+	index := make(map[string]string)
+	for _, segment := range segments {
+		err := ls.scan(segment, func(record *logRecord) {
+			if record.Deleted {
+				delete(index, record.ID)
+			} else {
+				index[record.ID] = segment
+			}
+		})
+		if err != nil {
+			return err
+		}
+	}
+	ls.index = index
+	return nil
+}
+
+// scan calls fn for every record of a segment. A torn last line, left by a
+// crash mid-append, is skipped.
+func (ls *LogStorage) scan(segment string, fn func(*logRecord)) error {
+	file, err := os.Open(filepath.Join(ls.dir, segment))
+	if err != nil {
+		return fmt.Errorf("failed to open log segment: %w", err)
+	}
+	defer file.Close()
+
+	var reader io.Reader = file
+	if strings.HasSuffix(segment, ".gz") {
+		zr, err := gzip.NewReader(file)
+		if err != nil {
+			return fmt.Errorf("failed to read log segment %s: %w", segment, err)
+		}
+		defer zr.Close()
+		reader = zr
+	}
+
+	lines := bufio.NewReader(reader)
+	for {
+		line, err := lines.ReadBytes('\n')
+		if len(line) > 0 && line[len(line)-1] == '\n' {
+			var record logRecord
+			if err := json.Unmarshal(line, &record); err != nil {
+				logging.Printf("Skipping corrupt record in log segment %s: %v", segment, err)
+			} else {
+				fn(&record)
+			}
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read log segment %s: %w", segment, err)
+		}
+	}
+}
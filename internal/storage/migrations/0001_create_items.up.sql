@@ -0,0 +1,5 @@
+-- Rows of the database storage type, keyed by tenant, shard and item ID
+CREATE TABLE items (
+    key  TEXT PRIMARY KEY,
+    data BYTEA NOT NULL
+);
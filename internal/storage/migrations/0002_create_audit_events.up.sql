@@ -0,0 +1,5 @@
+-- Audit events of the database audit sink, in the order they were appended
+CREATE TABLE audit_events (
+    seq    BIGSERIAL PRIMARY KEY,
+    record JSONB NOT NULL
+);
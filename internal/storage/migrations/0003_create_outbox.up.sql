@@ -0,0 +1,9 @@
+-- Events staged in the same transaction as the writes they describe, until
+-- the outbox dispatcher has published them
+CREATE TABLE outbox (
+    seq          BIGSERIAL PRIMARY KEY,
+    record       JSONB NOT NULL,
+    created_at   TIMESTAMPTZ NOT NULL DEFAULT now(),
+    published_at TIMESTAMPTZ
+);
+CREATE INDEX outbox_pending ON outbox (seq) WHERE published_at IS NULL;
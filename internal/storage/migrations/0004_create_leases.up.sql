@@ -0,0 +1,6 @@
+-- Leases of the leader election; a lease past expires_at is free to take
+CREATE TABLE leases (
+    name       TEXT PRIMARY KEY,
+    holder     TEXT NOT NULL,
+    expires_at TIMESTAMPTZ NOT NULL
+);
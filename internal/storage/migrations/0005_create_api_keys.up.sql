@@ -0,0 +1,13 @@
+-- API keys managed through the admin API; only a hash of each key is kept
+CREATE TABLE api_keys (
+    name        TEXT PRIMARY KEY,
+    key_sha256  BYTEA NOT NULL UNIQUE,
+    roles       TEXT[] NOT NULL DEFAULT '{}',
+    tenant      TEXT NOT NULL DEFAULT '',
+    rate_limit  DOUBLE PRECISION NOT NULL DEFAULT 0,
+    burst       INTEGER NOT NULL DEFAULT 0,
+    max_bytes   BIGINT NOT NULL DEFAULT 0,
+    max_objects BIGINT NOT NULL DEFAULT 0,
+    created_at  TIMESTAMPTZ NOT NULL,
+    updated_at  TIMESTAMPTZ NOT NULL
+);
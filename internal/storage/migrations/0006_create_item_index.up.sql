@@ -0,0 +1,22 @@
+-- The metadata index, describing every item independently of the backend
+-- storing it
+CREATE TABLE item_index (
+    tenant       TEXT NOT NULL,
+    storage_type TEXT NOT NULL,
+    id           TEXT NOT NULL,
+    size         BIGINT NOT NULL,
+    stored_bytes BIGINT NOT NULL,
+    sha256       TEXT NOT NULL,
+    content_type TEXT NOT NULL DEFAULT '',
+    owner        TEXT NOT NULL DEFAULT '',
+    labels       JSONB NOT NULL DEFAULT '{}',
+    source       TEXT NOT NULL DEFAULT '',
+    location     TEXT NOT NULL,
+    created_at   TIMESTAMPTZ,
+    modified_at  TIMESTAMPTZ,
+    expires_at   TIMESTAMPTZ,
+    deleted_at   TIMESTAMPTZ,
+    PRIMARY KEY (tenant, storage_type, id)
+);
+
+CREATE INDEX item_index_owner ON item_index (tenant, owner);
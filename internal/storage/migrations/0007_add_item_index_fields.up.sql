@@ -0,0 +1,2 @@
+-- Extracted metadata fields, so searches over them are served by the index
+ALTER TABLE item_index ADD COLUMN fields JSONB NOT NULL DEFAULT '{}';
@@ -0,0 +1,86 @@
+package storage
+
+import (
+	"time"
+)
+
+// OutboxRecord is an event staged in the outbox table
+type OutboxRecord struct {
+	Seq       int64
+	Record    []byte
+	CreatedAt time.Time
+}
+
+// outboxRow is a row of the mock outbox table; published is zero until the
+// record has been published
+type outboxRow struct {
+	OutboxRecord
+	published time.Time
+}
+
+// AppendOutbox stages a record in the outbox; it is written together with
+// the transaction's other writes on Commit
+func (tx *DatabaseTx) AppendOutbox(record []byte) error {
+	tx.mu.Lock()
+	defer tx.mu.Unlock()
+	if tx.done {
+		return ErrTxDone
+	}
+	tx.outbox = append(tx.outbox, append([]byte(nil), record...))
+	return nil
+}
+
+func (s *StorageTx) AppendOutbox(record []byte) error {
+	return s.tx.AppendOutbox(record)
+}
+
+// PendingOutbox returns up to limit unpublished records, oldest first
+func (db *DatabaseConnection) PendingOutbox(limit int) ([]OutboxRecord, error) {
+	var records []OutboxRecord
+	err := db.do(func() error {
+		db.mu.RLock()
+		defer db.mu.RUnlock()
+		for _, row := range db.outbox {
+			if len(records) == limit {
+				break
+			}
+			if row.published.IsZero() {
+				records = append(records, row.OutboxRecord)
+			}
+		}
+		return nil
+	})
+	return records, err
+}
+
+// MarkPublished records that the outbox records seqs have been published
+func (db *DatabaseConnection) MarkPublished(seqs ...int64) error {
+	return db.do(func() error {
+		db.mu.Lock()
+		defer db.mu.Unlock()
+		now := time.Now().UTC()
+		marked := make(map[int64]bool, len(seqs))
+		for _, seq := range seqs {
+			marked[seq] = true
+		}
+		for i := range db.outbox {
+			if marked[db.outbox[i].Seq] {
+				db.outbox[i].published = now
+			}
+		}
+		return nil
+	})
+}
+
+// OutboxBacklog counts the records waiting to be published
+func (db *DatabaseConnection) OutboxBacklog() int {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+	backlog := 0
+	for _, row := range db.outbox {
+		if row.published.IsZero() {
+			backlog++
+		}
+	}
+	return backlog
+}
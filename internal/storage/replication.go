@@ -0,0 +1,541 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"interview-task/internal/config"
+	"interview-task/internal/logging"
+	"interview-task/internal/metrics"
+)
+
+// repairRetryDelay is how long a failed repair waits before it is retried
+const repairRetryDelay = 5 * time.Second
+
+// staleCopy marks a copy of a key as behind since a write. gen identifies
+// the write, so a slower earlier write doesn't mark a later one done.
+type staleCopy struct {
+	since time.Time
+	gen   uint64
+}
+
+// replicaRepair brings one copy of a key in line with another
+type replicaRepair struct {
+	tenant      string
+	storageType string
+	key         string
+	// source and target are indexes into the storage type's copies, the
+	// primary being 0
+	source, target int
+	gen            uint64
+	// reason is "write" for a copy a write failed on, "read" for one a read
+	// found diverging
+	reason string
+}
+
+// Replicator - IMPLEMENTS replication across backends. Writes of a storage
+// type with replicas go to every copy at once and succeed when a quorum of
+// them did; copies still writing or failed are marked stale until they
+// catch up, failed ones being repaired from a copy that succeeded. Reads
+// go to every copy that isn't stale and take the fastest one holding the
+// key, then compare the others with it in the background: copies
+// disagreeing with the majority are repaired. Stale marks are kept in memory, so after a
+// restart divergence is only found by reads.
+type Replicator struct {
+	config  config.ReplicationConfig
+	metrics *metrics.Metrics
+	// open returns a tenant's replica storage and invalidate drops a key
+	// the repairs wrote from the read cache; the factory sets them
+	open       func(tenant, storageType string) (StorageInterface, error)
+	invalidate func(tenant, storageType, key string)
+	// hedger, when enabled, hedges reads across the copies instead of
+	// asking all of them; the factory sets it
+	hedger *Hedger
+	queue  chan replicaRepair
+
+	mu    sync.Mutex
+	stale map[string]staleCopy
+	gen   uint64
+
+	cancel  context.CancelFunc
+	workers sync.WaitGroup
+}
+
+func NewReplicator(config config.ReplicationConfig, metrics *metrics.Metrics) *Replicator {
+	r := &Replicator{
+		config:  config,
+		metrics: metrics,
+		queue:   make(chan replicaRepair, max(config.QueueSize, 1)),
+		stale:   make(map[string]staleCopy),
+	}
+	metrics.Describe("replication_writes_total", "Replicated writes by storage type and whether they reached a quorum")
+	metrics.Describe("replication_repairs_total", "Copies repaired by storage type, replica, reason and outcome")
+	metrics.GaugeFunc("replication_stale_copies", "Copies of keys behind their latest write", func() int64 {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		return int64(len(r.stale))
+	})
+	metrics.GaugeFunc("replication_lag_seconds", "How long the copy furthest behind has been stale", func() int64 {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		var oldest time.Time
+		for _, mark := range r.stale {
+			if oldest.IsZero() || mark.since.Before(oldest) {
+				oldest = mark.since
+			}
+		}
+		if oldest.IsZero() {
+			return 0
+		}
+		return int64(time.Since(oldest).Seconds())
+	})
+	return r
+}
+
+// validate checks that every storage type and replica is a distinct
+// storage type among backends, and that the quorum can be reached
+func (r *Replicator) validate(backends map[string]Backend) error {
+	for primary, replicas := range r.config.Replicas {
+		if _, ok := backends[primary]; !ok {
+			return fmt.Errorf("replication of unknown storage type: %q", primary)
+		}
+		for i, replica := range replicas {
+			if _, ok := backends[replica]; !ok {
+				return fmt.Errorf("unknown replica of %s: %q", primary, replica)
+			}
+			if replica == primary || slices.Contains(replicas[:i], replica) {
+				return fmt.Errorf("storage type %s replicates to %s twice", primary, replica)
+			}
+		}
+		if r.config.WriteQuorum < 0 || r.config.WriteQuorum > 1+len(replicas) {
+			return fmt.Errorf("write_quorum of %s must be between 1 and %d", primary, 1+len(replicas))
+		}
+	}
+	return nil
+}
+
+// wrap replicates a tenant's storage type to its replicas, if it has any
+func (r *Replicator) wrap(tenant, storageType string, storage StorageInterface) StorageInterface {
+	if r == nil || len(r.config.Replicas[storageType]) == 0 {
+		return storage
+	}
+	return &ReplicatedStorage{primary: storage, replicator: r, tenant: tenant, storageType: storageType}
+}
+
+// copies lists the storage types holding a storage type's items, the
+// primary first
+func (r *Replicator) copies(storageType string) []string {
+	return append([]string{storageType}, r.config.Replicas[storageType]...)
+}
+
+func (r *Replicator) quorum(copies int) int {
+	if r.config.WriteQuorum > 0 {
+		return r.config.WriteQuorum
+	}
+	return copies/2 + 1
+}
+
+func staleKey(tenant, storageType, key string) string {
+	return storageType + "/" + tenant + "/" + key
+}
+
+// markStale marks copies of a key as behind a new write, returning the
+// write's generation. Copies behind already keep how long they have been.
+func (r *Replicator) markStale(tenant, key string, storageTypes []string) uint64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.gen++
+	for _, storageType := range storageTypes {
+		k := staleKey(tenant, storageType, key)
+		mark, ok := r.stale[k]
+		if !ok {
+			mark.since = time.Now()
+		}
+		mark.gen = r.gen
+		r.stale[k] = mark
+	}
+	return r.gen
+}
+
+// markFresh clears a copy's stale mark, unless a later write set it
+func (r *Replicator) markFresh(tenant, storageType, key string, gen uint64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	k := staleKey(tenant, storageType, key)
+	if mark, ok := r.stale[k]; ok && mark.gen == gen {
+		delete(r.stale, k)
+	}
+}
+
+// fresh returns the indexes of the copies of a key that aren't stale, or
+// of every copy when all are
+func (r *Replicator) fresh(tenant, key string, storageTypes []string) []int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var fresh, all []int
+	for i, storageType := range storageTypes {
+		all = append(all, i)
+		if _, ok := r.stale[staleKey(tenant, storageType, key)]; !ok {
+			fresh = append(fresh, i)
+		}
+	}
+	if len(fresh) == 0 {
+		return all
+	}
+	return fresh
+}
+
+// markDiverged marks the targets among a key's copies stale for read
+// repair, returning the repair's generation. ok is false if any copy is
+// stale already: a write is under way or being repaired, and the answers
+// compared may predate it.
+func (r *Replicator) markDiverged(tenant, key string, storageTypes []string, targets []int) (gen uint64, ok bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, storageType := range storageTypes {
+		if _, stale := r.stale[staleKey(tenant, storageType, key)]; stale {
+			return 0, false
+		}
+	}
+	r.gen++
+	for _, target := range targets {
+		r.stale[staleKey(tenant, storageTypes[target], key)] = staleCopy{since: time.Now(), gen: r.gen}
+	}
+	return r.gen, true
+}
+
+func (r *Replicator) staleGen(tenant, storageType, key string) (uint64, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	mark, ok := r.stale[staleKey(tenant, storageType, key)]
+	return mark.gen, ok
+}
+
+func (r *Replicator) Start() {
+	if len(r.config.Replicas) == 0 {
+		return
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	r.cancel = cancel
+	for range max(r.config.Workers, 1) {
+		r.workers.Go(func() {
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case repair := <-r.queue:
+					r.repair(repair)
+				}
+			}
+		})
+	}
+}
+
+func (r *Replicator) enqueue(repair replicaRepair) {
+	select {
+	case r.queue <- repair:
+	default:
+		r.observe(repair, "dropped")
+	}
+}
+
+// repair copies a key from the source copy to the target, unless a later
+// write has marked the target since. Failed repairs are retried.
+func (r *Replicator) repair(repair replicaRepair) {
+	copies := r.copies(repair.storageType)
+	if gen, ok := r.staleGen(repair.tenant, copies[repair.target], repair.key); !ok || gen != repair.gen {
+		return
+	}
+	err := r.copyKey(repair.tenant, copies[repair.source], copies[repair.target], repair.key)
+	if err != nil {
+		logging.Printf("Failed to repair %s/%s/%s on %s: %v", repair.storageType, repair.tenant, repair.key, copies[repair.target], err)
+		r.observe(repair, "failed")
+		time.AfterFunc(repairRetryDelay, func() { r.enqueue(repair) })
+		return
+	}
+	r.markFresh(repair.tenant, copies[repair.target], repair.key, repair.gen)
+	r.observe(repair, "repaired")
+}
+
+func (r *Replicator) copyKey(tenant, source, target, key string) error {
+	from, err := r.open(tenant, source)
+	if err != nil {
+		return err
+	}
+	to, err := r.open(tenant, target)
+	if err != nil {
+		return err
+	}
+	defer r.invalidate(tenant, target, key)
+	data, err := from.Load(key)
+	switch {
+	case errors.Is(err, ErrNotFound):
+		if err := to.Delete(key); err != nil && !errors.Is(err, ErrNotFound) {
+			return err
+		}
+		return nil
+	case err != nil:
+		return fmt.Errorf("failed to read %s: %w", source, err)
+	}
+	return to.Save(key, data)
+}
+
+func (r *Replicator) observe(repair replicaRepair, outcome string) {
+	replica := r.copies(repair.storageType)[repair.target]
+	r.metrics.Add("replication_repairs_total", 1, "storage_type", repair.storageType, "replica", replica, "reason", repair.reason, "outcome", outcome)
+}
+
+func (r *Replicator) Shutdown() {
+	if r.cancel != nil {
+		r.cancel()
+		r.workers.Wait()
+	}
+}
+
+// ReplicatedStorage writes a tenant's keys to every copy of its storage
+// type with quorum and reads them from the fastest
+type ReplicatedStorage struct {
+	primary     StorageInterface
+	replicator  *Replicator
+	tenant      string
+	storageType string
+}
+
+// open returns copy i, the primary being 0
+func (s *ReplicatedStorage) open(i int, storageType string) (StorageInterface, error) {
+	if i == 0 {
+		return s.primary, nil
+	}
+	return s.replicator.open(s.tenant, storageType)
+}
+
+// replicaResult is the outcome of an operation on copy i
+type replicaResult struct {
+	i    int
+	data []byte
+	err  error
+}
+
+func (s *ReplicatedStorage) Save(id string, data []byte) error {
+	// Copies still writing after the quorum is reached outlive the call
+	data = slices.Clone(data)
+	return s.write(id, func(storage StorageInterface) error {
+		return storage.Save(id, data)
+	})
+}
+
+func (s *ReplicatedStorage) Delete(id string) error {
+	return s.write(id, func(storage StorageInterface) error {
+		return storage.Delete(id)
+	})
+}
+
+// write applies a write to every copy, returning once a quorum of them
+// succeeded or too many failed for one. A delete missing on a copy
+// succeeded there; it fails with ErrNotFound only if it was missing on
+// every copy that answered.
+func (s *ReplicatedStorage) write(id string, fn func(StorageInterface) error) error {
+	r := s.replicator
+	copies := r.copies(s.storageType)
+	quorum := r.quorum(len(copies))
+	gen := r.markStale(s.tenant, id, copies)
+
+	results := make(chan replicaResult, len(copies))
+	for i, storageType := range copies {
+		go func() {
+			storage, err := s.open(i, storageType)
+			if err == nil {
+				err = fn(storage)
+			}
+			results <- replicaResult{i: i, err: err}
+		}()
+	}
+
+	decided := make(chan error, 1)
+	go func() {
+		acked, found, source := 0, false, -1
+		var failed []int
+		var firstErr error
+		for range copies {
+			result := <-results
+			switch {
+			case result.err == nil || errors.Is(result.err, ErrNotFound):
+				r.markFresh(s.tenant, copies[result.i], id, gen)
+				acked++
+				found = found || result.err == nil
+				if source == -1 {
+					source = result.i
+				}
+				if acked == quorum {
+					if !found {
+						decided <- ErrNotFound
+					} else {
+						decided <- nil
+					}
+					r.metrics.Add("replication_writes_total", 1, "storage_type", s.storageType, "outcome", "quorum")
+				}
+			default:
+				logging.Printf("Failed to write %s/%s/%s to %s: %v", s.storageType, s.tenant, id, copies[result.i], result.err)
+				failed = append(failed, result.i)
+				if firstErr == nil {
+					firstErr = result.err
+				}
+				if len(failed) == len(copies)-quorum+1 {
+					decided <- firstErr
+					r.metrics.Add("replication_writes_total", 1, "storage_type", s.storageType, "outcome", "failed")
+				}
+			}
+		}
+		for _, target := range failed {
+			if source == -1 {
+				// Nothing was written anywhere, so no copy is behind
+				r.markFresh(s.tenant, copies[target], id, gen)
+				continue
+			}
+			r.enqueue(replicaRepair{tenant: s.tenant, storageType: s.storageType, key: id, source: source, target: target, gen: gen, reason: "write"})
+		}
+	}()
+	return <-decided
+}
+
+// Load answers with the fastest copy holding the key that isn't stale, then
+// compares the others with the majority in the background. With hedging the
+// copies are asked one after another instead.
+func (s *ReplicatedStorage) Load(id string) ([]byte, error) {
+	r := s.replicator
+	copies := r.copies(s.storageType)
+	asked := r.fresh(s.tenant, id, copies)
+	if r.hedger.enabled() {
+		return s.hedgedLoad(id, asked)
+	}
+
+	results := make(chan replicaResult, len(asked))
+	for _, i := range asked {
+		go func() {
+			storage, err := s.open(i, copies[i])
+			var data []byte
+			if err == nil {
+				data, err = storage.Load(id)
+			}
+			results <- replicaResult{i: i, data: data, err: err}
+		}()
+	}
+
+	answer := make(chan replicaResult, 1)
+	go func() {
+		var answers []replicaResult
+		var failure *replicaResult
+		sent := false
+		for range asked {
+			result := <-results
+			switch {
+			case result.err == nil:
+				if !sent {
+					answer <- result
+					sent = true
+				}
+			case errors.Is(result.err, ErrNotFound):
+				// A copy missing the key may be behind; the key is only
+				// missing if every copy says so
+				failure = &result
+			default:
+				if failure == nil {
+					failure = &result
+				}
+				continue
+			}
+			answers = append(answers, result)
+		}
+		if !sent {
+			answer <- *failure
+		}
+		s.readRepair(id, answers)
+	}()
+	result := <-answer
+	return result.data, result.err
+}
+
+// readRepair queues repairs of the copies whose answers disagree with the
+// majority's; a tie goes to the primary, then to the fastest
+func (s *ReplicatedStorage) readRepair(id string, answers []replicaResult) {
+	if len(answers) < 2 {
+		return
+	}
+	groups := make(map[string][]int)
+	var order []string
+	for _, answer := range answers {
+		digest := "missing"
+		if answer.err == nil {
+			digest = PayloadSHA256(answer.data)
+		}
+		if groups[digest] == nil {
+			order = append(order, digest)
+		}
+		groups[digest] = append(groups[digest], answer.i)
+	}
+	if len(groups) == 1 {
+		return
+	}
+	winner := order[0]
+	for _, digest := range order {
+		switch {
+		case len(groups[digest]) > len(groups[winner]):
+			winner = digest
+		case len(groups[digest]) == len(groups[winner]) && slices.Contains(groups[digest], 0):
+			winner = digest
+		}
+	}
+	var targets []int
+	for digest, copies := range groups {
+		if digest != winner {
+			targets = append(targets, copies...)
+		}
+	}
+	r := s.replicator
+	copies := r.copies(s.storageType)
+	gen, ok := r.markDiverged(s.tenant, id, copies, targets)
+	if !ok {
+		return
+	}
+	for _, target := range targets {
+		r.enqueue(replicaRepair{tenant: s.tenant, storageType: s.storageType, key: id, source: groups[winner][0], target: target, gen: gen, reason: "read"})
+	}
+}
+
+// List returns the keys of every copy that could be listed, so keys a
+// write reached only some copies with are included
+func (s *ReplicatedStorage) List() ([]string, error) {
+	copies := s.replicator.copies(s.storageType)
+	seen := make(map[string]bool)
+	var keys []string
+	var firstErr error
+	listed := false
+	for i, storageType := range copies {
+		storage, err := s.open(i, storageType)
+		var copyKeys []string
+		if err == nil {
+			copyKeys, err = storage.List()
+		}
+		if err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		listed = true
+		for _, key := range copyKeys {
+			if !seen[key] {
+				seen[key] = true
+				keys = append(keys, key)
+			}
+		}
+	}
+	if !listed {
+		return nil, firstErr
+	}
+	return keys, nil
+}
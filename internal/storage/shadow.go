@@ -0,0 +1,235 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"interview-task/internal/config"
+	"interview-task/internal/logging"
+	"interview-task/internal/metrics"
+)
+
+// Outcomes of a shadow write, compared with the primary write
+const (
+	shadowMatch           = "match"
+	shadowCandidateFailed = "candidate_failed"
+	shadowPrimaryFailed   = "primary_failed"
+	shadowBothFailed      = "both_failed"
+	shadowMismatch        = "checksum_mismatch"
+	shadowDropped         = "dropped"
+)
+
+// shadowWrite is a primary write waiting to be repeated on a candidate
+type shadowWrite struct {
+	tenant      string
+	storageType string
+	operation   string
+	key         string
+	data        []byte
+	primaryErr  error
+	primaryTime time.Duration
+}
+
+// ShadowWriter - IMPLEMENTS shadow writes to candidate backends. Every save
+// and delete on a storage type with a candidate is repeated on the
+// candidate in the background, and the two compared: whether both
+// succeeded, how long each took, and whether the candidate reads back the
+// payload's checksum. Results are only reported in metrics; the candidate
+// never affects the primary's outcome, and writes still queued at shutdown
+// are dropped.
+type ShadowWriter struct {
+	config  config.ShadowConfig
+	metrics *metrics.Metrics
+	// open returns a tenant's candidate storage; the factory sets it
+	open  func(tenant, storageType string) (StorageInterface, error)
+	queue chan *shadowWrite
+
+	cancel  context.CancelFunc
+	workers sync.WaitGroup
+}
+
+func NewShadowWriter(config config.ShadowConfig, metrics *metrics.Metrics) *ShadowWriter {
+	metrics.Describe("shadow_writes_total", "Writes repeated on a candidate backend by storage type, candidate, operation and outcome")
+	metrics.Describe("shadow_write_microseconds_total", "Time spent in shadowed writes by storage type, candidate, operation and backend")
+	return &ShadowWriter{
+		config:  config,
+		metrics: metrics,
+		queue:   make(chan *shadowWrite, max(config.QueueSize, 1)),
+	}
+}
+
+// validate checks that every primary and candidate is a distinct storage
+// type among backends
+func (w *ShadowWriter) validate(backends map[string]Backend) error {
+	for primary, candidate := range w.config.Targets {
+		if _, ok := backends[primary]; !ok {
+			return fmt.Errorf("shadow writes for unknown storage type: %q", primary)
+		}
+		if _, ok := backends[candidate]; !ok {
+			return fmt.Errorf("unknown shadow candidate for %s: %q", primary, candidate)
+		}
+		if primary == candidate {
+			return fmt.Errorf("storage type %s can't shadow itself", primary)
+		}
+	}
+	return nil
+}
+
+// wrap mirrors a tenant's writes to the storage type's candidate, if it has
+// one
+func (w *ShadowWriter) wrap(tenant, storageType string, storage StorageInterface) StorageInterface {
+	if w == nil {
+		return storage
+	}
+	if _, ok := w.config.Targets[storageType]; !ok {
+		return storage
+	}
+	return &ShadowedStorage{next: storage, writer: w, tenant: tenant, storageType: storageType}
+}
+
+func (w *ShadowWriter) Start() {
+	if len(w.config.Targets) == 0 {
+		return
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	w.cancel = cancel
+	for range max(w.config.Workers, 1) {
+		w.workers.Go(func() {
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case write := <-w.queue:
+					w.repeat(write)
+				}
+			}
+		})
+	}
+}
+
+func (w *ShadowWriter) enqueue(write *shadowWrite) {
+	select {
+	case w.queue <- write:
+	default:
+		w.observe(write, shadowDropped)
+	}
+}
+
+// repeat makes a write on the candidate and records how it compares
+func (w *ShadowWriter) repeat(write *shadowWrite) {
+	candidate := w.config.Targets[write.storageType]
+	storage, err := w.open(write.tenant, candidate)
+	if err != nil {
+		w.report(write, compareShadow(write.primaryErr, err), err)
+		return
+	}
+	start := time.Now()
+	switch write.operation {
+	case "save":
+		err = storage.Save(write.key, write.data)
+	case "delete":
+		err = storage.Delete(write.key)
+	}
+	w.metrics.Add("shadow_write_microseconds_total", write.primaryTime.Microseconds(), "storage_type", write.storageType, "candidate", candidate, "operation", write.operation, "backend", "primary")
+	w.metrics.Add("shadow_write_microseconds_total", time.Since(start).Microseconds(), "storage_type", write.storageType, "candidate", candidate, "operation", write.operation, "backend", "candidate")
+
+	outcome := compareShadow(write.primaryErr, err)
+	if outcome == shadowMatch && write.operation == "save" {
+		// A save only matches if the candidate gives the payload back
+		var stored []byte
+		if stored, err = storage.Load(write.key); err != nil {
+			outcome = shadowCandidateFailed
+		} else if PayloadSHA256(stored) != PayloadSHA256(write.data) {
+			outcome = shadowMismatch
+		}
+	}
+	w.report(write, outcome, err)
+}
+
+func (w *ShadowWriter) report(write *shadowWrite, outcome string, candidateErr error) {
+	w.observe(write, outcome)
+	if outcome == shadowMatch {
+		return
+	}
+	logging.Printf("Shadow %s of %s/%s/%s on %s: %s (primary: %v, candidate: %v)",
+		write.operation, write.storageType, write.tenant, write.key, w.config.Targets[write.storageType], outcome, write.primaryErr, candidateErr)
+}
+
+func (w *ShadowWriter) observe(write *shadowWrite, outcome string) {
+	w.metrics.Add("shadow_writes_total", 1, "storage_type", write.storageType, "candidate", w.config.Targets[write.storageType], "operation", write.operation, "outcome", outcome)
+}
+
+func (w *ShadowWriter) Shutdown() {
+	if w.cancel != nil {
+		w.cancel()
+		w.workers.Wait()
+	}
+}
+
+// compareShadow classifies the results of a write on the primary and the
+// candidate. Both reporting ErrNotFound, as for a delete of a missing item,
+// counts as a match.
+func compareShadow(primaryErr, candidateErr error) string {
+	switch {
+	case primaryErr == nil && candidateErr == nil:
+		return shadowMatch
+	case errors.Is(primaryErr, ErrNotFound) && errors.Is(candidateErr, ErrNotFound):
+		return shadowMatch
+	case primaryErr == nil:
+		return shadowCandidateFailed
+	case candidateErr == nil:
+		return shadowPrimaryFailed
+	default:
+		return shadowBothFailed
+	}
+}
+
+// ShadowedStorage hands each write to the ShadowWriter once the primary has
+// made it. Reads only go to the primary.
+type ShadowedStorage struct {
+	next        StorageInterface
+	writer      *ShadowWriter
+	tenant      string
+	storageType string
+}
+
+func (s *ShadowedStorage) Save(id string, data []byte) error {
+	start := time.Now()
+	err := s.next.Save(id, data)
+	s.writer.enqueue(&shadowWrite{
+		tenant:      s.tenant,
+		storageType: s.storageType,
+		operation:   "save",
+		key:         id,
+		data:        slices.Clone(data),
+		primaryErr:  err,
+		primaryTime: time.Since(start),
+	})
+	return err
+}
+
+func (s *ShadowedStorage) Load(id string) ([]byte, error) {
+	return s.next.Load(id)
+}
+
+func (s *ShadowedStorage) Delete(id string) error {
+	start := time.Now()
+	err := s.next.Delete(id)
+	s.writer.enqueue(&shadowWrite{
+		tenant:      s.tenant,
+		storageType: s.storageType,
+		operation:   "delete",
+		key:         id,
+		primaryErr:  err,
+		primaryTime: time.Since(start),
+	})
+	return err
+}
+
+func (s *ShadowedStorage) List() ([]string, error) {
+	return s.next.List()
+}
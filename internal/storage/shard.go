@@ -0,0 +1,446 @@
+package storage
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"os"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+
+	"interview-task/internal/config"
+	"interview-task/internal/logging"
+)
+
+// ShardMapper assigns item IDs to shards
+type ShardMapper interface {
+	Shard(id string) string
+	// Shards lists every shard the mapper can return
+	Shards() []string
+}
+
+// ShardMapperFactory builds a mapper from its config
+type ShardMapperFactory func(config config.ShardMapConfig) (ShardMapper, error)
+
+var (
+	shardMappersMu sync.RWMutex
+	shardMappers   = map[string]ShardMapperFactory{
+		"single":    func(config.ShardMapConfig) (ShardMapper, error) { return singleShard{}, nil },
+		"hash_ring": newHashRing,
+		"range":     newRangeMap,
+	}
+)
+
+// RegisterShardMapper makes a mapper type available to resharding
+func RegisterShardMapper(name string, factory ShardMapperFactory) {
+	shardMappersMu.Lock()
+	defer shardMappersMu.Unlock()
+	shardMappers[name] = factory
+}
+
+func NewShardMapper(config config.ShardMapConfig) (ShardMapper, error) {
+	name := config.Type
+	if name == "" {
+		name = "single"
+	}
+	shardMappersMu.RLock()
+	factory, ok := shardMappers[name]
+	shardMappersMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown shard mapper: %q", config.Type)
+	}
+	return factory(config)
+}
+
+var shardName = regexp.MustCompile(`^[a-z0-9_-]{1,32}$`)
+
+func validateShardNames(shards []string) error {
+	if len(shards) == 0 {
+		return errors.New("at least one shard is required")
+	}
+	for _, shard := range shards {
+		if !shardName.MatchString(shard) {
+			return fmt.Errorf("invalid shard name: %q", shard)
+		}
+	}
+	return nil
+}
+
+// singleShard keeps every item in the root shard
+type singleShard struct{}
+
+func (singleShard) Shard(string) string { return "" }
+
+func (singleShard) Shards() []string { return []string{""} }
+
+// hashRing - IMPLEMENTS consistent hashing, so adding a shard only moves
+// the IDs that now land on it
+type hashRing struct {
+	shards []string
+	points []uint64
+	owners map[uint64]string
+}
+
+func newHashRing(config config.ShardMapConfig) (ShardMapper, error) {
+	if err := validateShardNames(config.Shards); err != nil {
+		return nil, err
+	}
+	vnodes := config.VirtualNodes
+	if vnodes <= 0 {
+		vnodes = 128
+	}
+
+	ring := &hashRing{shards: config.Shards, owners: make(map[uint64]string)}
+	for _, shard := range config.Shards {
+		for i := range vnodes {
+			point := ringHash(fmt.Sprintf("%s#%d", shard, i))
+			if _, taken := ring.owners[point]; taken {
+				continue
+			}
+			ring.owners[point] = shard
+			ring.points = append(ring.points, point)
+		}
+	}
+	sort.Slice(ring.points, func(i, j int) bool { return ring.points[i] < ring.points[j] })
+	return ring, nil
+}
+
+func ringHash(key string) uint64 {
+	sum := sha256.Sum256([]byte(key))
+	return binary.BigEndian.Uint64(sum[:8])
+}
+
+func (r *hashRing) Shard(id string) string {
+	point := ringHash(id)
+	i := sort.Search(len(r.points), func(i int) bool { return r.points[i] >= point })
+	if i == len(r.points) {
+		i = 0
+	}
+	return r.owners[r.points[i]]
+}
+
+func (r *hashRing) Shards() []string { return r.shards }
+
+// rangeMap assigns IDs by comparing them with range start keys
+type rangeMap struct {
+	ranges []config.ShardRange
+}
+
+func newRangeMap(cfg config.ShardMapConfig) (ShardMapper, error) {
+	if len(cfg.Ranges) == 0 {
+		return nil, errors.New("range mapper needs at least one range")
+	}
+	ranges := append([]config.ShardRange(nil), cfg.Ranges...)
+	sort.Slice(ranges, func(i, j int) bool { return ranges[i].Start < ranges[j].Start })
+	if ranges[0].Start != "" {
+		return nil, errors.New("the first range must start at \"\" to cover every ID")
+	}
+	var shards []string
+	for i, r := range ranges {
+		if i > 0 && r.Start == ranges[i-1].Start {
+			return nil, fmt.Errorf("duplicate range start: %q", r.Start)
+		}
+		shards = append(shards, r.Shard)
+	}
+	if err := validateShardNames(shards); err != nil {
+		return nil, err
+	}
+	return &rangeMap{ranges: ranges}, nil
+}
+
+func (m *rangeMap) Shard(id string) string {
+	i := sort.Search(len(m.ranges), func(i int) bool { return m.ranges[i].Start > id })
+	return m.ranges[i-1].Shard
+}
+
+func (m *rangeMap) Shards() []string {
+	seen := make(map[string]bool)
+	var shards []string
+	for _, r := range m.ranges {
+		if !seen[r.Shard] {
+			seen[r.Shard] = true
+			shards = append(shards, r.Shard)
+		}
+	}
+	return shards
+}
+
+// ShardKey is the ID a key is placed by. Linked records follow their item
+// so an item and its metadata, versions and derivations share a shard.
+func ShardKey(key string) string {
+	id, _, _ := strings.Cut(key, "~")
+	return id
+}
+
+// ShardState is the persisted mapping. Next is set while a resharding
+// migration is in progress.
+type ShardState struct {
+	Current config.ShardMapConfig  `json:"current"`
+	Next    *config.ShardMapConfig `json:"next,omitempty"`
+}
+
+// ShardRouter - IMPLEMENTS the active ID-to-shard mapping. During a
+// migration it knows both the old and the new mapping so reads can be served
+// from either location until cutover.
+type ShardRouter struct {
+	stateFile string
+
+	mu      sync.RWMutex
+	state   ShardState
+	current ShardMapper
+	next    ShardMapper
+	// rollingBack prefers the current mapping while an aborted migration
+	// moves items back
+	rollingBack bool
+
+	// Writes and migration moves of the same item are serialized
+	stripes [64]sync.Mutex
+}
+
+func NewShardRouter(stateFile string) (*ShardRouter, error) {
+	r := &ShardRouter{stateFile: stateFile, current: singleShard{}}
+	if stateFile == "" {
+		return r, nil
+	}
+
+	data, err := os.ReadFile(stateFile)
+	if errors.Is(err, os.ErrNotExist) {
+		return r, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read shard state: %w", err)
+	}
+	if err := json.Unmarshal(data, &r.state); err != nil {
+		return nil, fmt.Errorf("failed to parse shard state: %w", err)
+	}
+	if r.current, err = NewShardMapper(r.state.Current); err != nil {
+		return nil, err
+	}
+	if r.state.Next != nil {
+		if r.next, err = NewShardMapper(*r.state.Next); err != nil {
+			return nil, err
+		}
+	}
+	return r, nil
+}
+
+// locations returns where a key may live: its target under the newest
+// mapping first, then its old location if a migration is in progress
+func (r *ShardRouter) locations(key string) []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	id := ShardKey(key)
+	if r.next == nil {
+		return []string{r.current.Shard(id)}
+	}
+	target, old := r.next.Shard(id), r.current.Shard(id)
+	switch {
+	case target == old:
+		return []string{target}
+	case r.rollingBack:
+		return []string{old, target}
+	default:
+		return []string{target, old}
+	}
+}
+
+// Shards returns every shard of the current and next mapping
+func (r *ShardRouter) Shards() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	shards := r.current.Shards()
+	if r.next != nil {
+		for _, shard := range r.next.Shards() {
+			if !slices.Contains(shards, shard) {
+				shards = append(shards, shard)
+			}
+		}
+	}
+	return shards
+}
+
+func (r *ShardRouter) lock(key string) func() {
+	h := fnv.New32a()
+	h.Write([]byte(ShardKey(key)))
+	stripe := &r.stripes[h.Sum32()%uint32(len(r.stripes))]
+	stripe.Lock()
+	return stripe.Unlock
+}
+
+func (r *ShardRouter) State() ShardState {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.state
+}
+
+// Target is the mapping a migration moves keys to: the next one, or the
+// current one when rolling back
+func (r *ShardRouter) Target(rollback bool) ShardMapper {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if rollback {
+		return r.current
+	}
+	return r.next
+}
+
+// Begin starts dual reads towards a new mapping. Resuming an interrupted
+// migration to the same mapping is allowed; switching targets is not.
+func (r *ShardRouter) Begin(config config.ShardMapConfig, mapper ShardMapper) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.state.Next != nil {
+		current, _ := json.Marshal(r.state.Next)
+		requested, _ := json.Marshal(config)
+		if string(current) != string(requested) {
+			return fmt.Errorf("a migration to another mapping is in progress; abort it first")
+		}
+	}
+	r.state.Next = &config
+	r.next = mapper
+	r.rollingBack = false
+	return r.persistLocked()
+}
+
+// BeginRollback sends writes back to the current mapping
+func (r *ShardRouter) BeginRollback() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.rollingBack = true
+}
+
+// Cutover makes the new mapping current and ends dual reads
+func (r *ShardRouter) Cutover() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.state.Current, r.state.Next = *r.state.Next, nil
+	r.current, r.next = r.next, nil
+	return r.persistLocked()
+}
+
+// Rollback drops the new mapping once every item is back in place
+func (r *ShardRouter) Rollback() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.state.Next = nil
+	r.next = nil
+	r.rollingBack = false
+	return r.persistLocked()
+}
+
+func (r *ShardRouter) persistLocked() error {
+	if r.stateFile == "" {
+		return nil
+	}
+	data, err := json.Marshal(r.state)
+	if err != nil {
+		return err
+	}
+	if err := WriteFileAtomic(r.stateFile, data); err != nil {
+		return fmt.Errorf("failed to persist shard state: %w", err)
+	}
+	return nil
+}
+
+// ShardedStorage spreads one tenant's keys over shard backends
+type ShardedStorage struct {
+	router *ShardRouter
+	Open   func(shard string) StorageInterface
+}
+
+// Save writes to the key's target shard and removes any copy left at its
+// old location, so there is only ever one copy
+func (s *ShardedStorage) Save(id string, data []byte) error {
+	defer s.router.lock(id)()
+	locations := s.router.locations(id)
+	if err := s.Open(locations[0]).Save(id, data); err != nil {
+		return err
+	}
+	for _, shard := range locations[1:] {
+		if err := s.Open(shard).Delete(id); err != nil && !errors.Is(err, ErrNotFound) {
+			logging.Printf("Failed to remove stale copy of %s from shard %q: %v", id, shard, err)
+		}
+	}
+	return nil
+}
+
+func (s *ShardedStorage) Load(id string) ([]byte, error) {
+	for _, shard := range s.router.locations(id) {
+		data, err := s.Open(shard).Load(id)
+		if !errors.Is(err, ErrNotFound) {
+			return data, err
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func (s *ShardedStorage) Delete(id string) error {
+	defer s.router.lock(id)()
+	deleted := false
+	for _, shard := range s.router.locations(id) {
+		err := s.Open(shard).Delete(id)
+		switch {
+		case err == nil:
+			deleted = true
+		case !errors.Is(err, ErrNotFound):
+			return err
+		}
+	}
+	if !deleted {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (s *ShardedStorage) List() ([]string, error) {
+	seen := make(map[string]bool)
+	var keys []string
+	for _, shard := range s.router.Shards() {
+		shardKeys, err := s.Open(shard).List()
+		if err != nil {
+			return nil, err
+		}
+		for _, key := range shardKeys {
+			if !seen[key] {
+				seen[key] = true
+				keys = append(keys, key)
+			}
+		}
+	}
+	return keys, nil
+}
+
+// Rewrap brings a key to the current master key version wherever it is
+func (s *ShardedStorage) Rewrap(id string) (bool, error) {
+	defer s.router.lock(id)()
+	for _, shard := range s.router.locations(id) {
+		rewrapped, err := Rewrap(s.Open(shard), id)
+		if !errors.Is(err, ErrNotFound) {
+			return rewrapped, err
+		}
+	}
+	return false, ErrNotFound
+}
+
+// Move relocates one key between shards unless it was rewritten or deleted
+// in the meantime
+func (s *ShardedStorage) Move(key, from, to string) error {
+	defer s.router.lock(key)()
+	data, err := s.Open(from).Load(key)
+	if errors.Is(err, ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if err := s.Open(to).Save(key, data); err != nil {
+		return err
+	}
+	return s.Open(from).Delete(key)
+}
@@ -0,0 +1,775 @@
+// Package storage implements the storage backends items are kept in and
+// the decorators layered over them: sharding, deduplication, caching,
+// write batching and free disk space checks.
+package storage
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"interview-task/internal/config"
+	"interview-task/internal/logging"
+	"interview-task/internal/metrics"
+)
+
+// Sentinel errors used to map failures onto HTTP status codes
+var (
+	ErrNotFound = errors.New("item not found")
+	ErrConflict = errors.New("conflict")
+)
+
+// Storage interface - IMPLEMENTS Polymorphism and Strategy Pattern
+type StorageInterface interface {
+	Save(id string, data []byte) error
+	// Load and Delete return ErrNotFound when no item is stored under id
+	Load(id string) ([]byte, error)
+	Delete(id string) error
+	// List returns every stored key, including linked records
+	List() ([]string, error)
+}
+
+// FileStorage implements Storage interface. The layout decides which
+// subdirectory each key lives in.
+type FileStorage struct {
+	dir    string
+	layout fileLayout
+}
+
+// locate finds the file holding id: where the layout puts it, or in the
+// flat directory where files lived before a layout was configured
+func (fs *FileStorage) locate(id string) (string, bool) {
+	candidates := fs.layout.candidates(fs.dir, id)
+	candidates = append(candidates, filepath.Join(fs.dir, id+".dat"))
+	for _, candidate := range candidates {
+		if _, err := os.Lstat(candidate); err == nil {
+			return candidate, true
+		}
+	}
+	return "", false
+}
+
+// target returns where id is written: over its existing file, next to its
+// item for linked records, or where the layout puts new keys
+func (fs *FileStorage) target(id string) string {
+	if path, ok := fs.locate(id); ok {
+		return path
+	}
+	if item := ShardKey(id); item != id {
+		if path, ok := fs.locate(item); ok {
+			return filepath.Join(filepath.Dir(path), id+".dat")
+		}
+	}
+	return fs.layout.path(fs.dir, id, time.Now())
+}
+
+func (fs *FileStorage) Save(id string, data []byte) error {
+	path := fs.target(id)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("failed to create directory: %w", err)
+	}
+	if err := WriteFileAtomic(path, data); err != nil {
+		return err
+	}
+	fmt.Println("Data saved to file")
+	return nil
+}
+
+// WriteFileAtomic writes to a temporary file and renames it into place, so
+// a crash mid-write leaves either the old file or the new one, never a
+// truncated one
+func WriteFileAtomic(path string, data []byte) error {
+	dir := filepath.Dir(path)
+	file, err := os.CreateTemp(dir, filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to create file: %w", err)
+	}
+	defer os.Remove(file.Name())
+	defer file.Close()
+
+	if _, err := file.Write(data); err != nil {
+		return fmt.Errorf("failed to write to file: %w", err)
+	}
+	if err := file.Sync(); err != nil {
+		return fmt.Errorf("failed to sync file: %w", err)
+	}
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("failed to close file: %w", err)
+	}
+	if err := os.Rename(file.Name(), path); err != nil {
+		return fmt.Errorf("failed to rename file: %w", err)
+	}
+	return syncDir(dir)
+}
+
+// syncDir makes a rename in dir durable
+func syncDir(path string) error {
+	dir, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open directory: %w", err)
+	}
+	defer dir.Close()
+	if err := dir.Sync(); err != nil {
+		return fmt.Errorf("failed to sync directory: %w", err)
+	}
+	return nil
+}
+
+func (fs *FileStorage) Load(id string) ([]byte, error) {
+	path, ok := fs.locate(id)
+	if !ok {
+		return nil, ErrNotFound
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file: %w", err)
+	}
+	return data, nil
+}
+
+func (fs *FileStorage) Delete(id string) error {
+	path, ok := fs.locate(id)
+	if !ok {
+		return ErrNotFound
+	}
+	err := os.Remove(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return ErrNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("failed to remove file: %w", err)
+	}
+	return nil
+}
+
+// List walks every layout directory. The shards directory at the top holds
+// other shards' storage and is skipped.
+func (fs *FileStorage) List() ([]string, error) {
+	var ids []string
+	err := filepath.WalkDir(fs.dir, func(path string, entry os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if entry.IsDir() {
+			if entry.Name() == "shards" && filepath.Dir(path) == fs.dir {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if id, ok := strings.CutSuffix(entry.Name(), ".dat"); ok && entry.Type().IsRegular() {
+			ids = append(ids, id)
+		}
+		return nil
+	})
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read directory: %w", err)
+	}
+	return ids, nil
+}
+
+// DatabaseStorage implements Storage interface. Keys are prefixed so each
+// tenant only sees its own rows.
+type DatabaseStorage struct {
+	// db is the database or a transaction on it
+	db     StorageInterface
+	prefix string
+}
+
+func (ds *DatabaseStorage) Save(id string, data []byte) error {
+	return ds.db.Save(ds.prefix+id, data)
+}
+
+func (ds *DatabaseStorage) Load(id string) ([]byte, error) {
+	return ds.db.Load(ds.prefix + id)
+}
+
+func (ds *DatabaseStorage) Delete(id string) error {
+	return ds.db.Delete(ds.prefix + id)
+}
+
+func (ds *DatabaseStorage) List() ([]string, error) {
+	keys, err := ds.db.List()
+	if err != nil {
+		return nil, err
+	}
+
+	var ids []string
+	for _, key := range keys {
+		if id, ok := strings.CutPrefix(key, ds.prefix); ok {
+			ids = append(ids, id)
+		}
+	}
+	return ids, nil
+}
+
+// DatabaseConnection - properly structured with dependency injection.
+// Queries run on connections from a pool, which reconnects on demand after
+// the database restarts.
+type DatabaseConnection struct {
+	Host     string
+	Port     int
+	Username string
+	Password string
+	DBName   string
+	pool     *connPool
+	// redact masks personal data in payloads before they are logged
+	redact func([]byte) []byte
+
+	// Mock tables standing in for the real database
+	mu    sync.RWMutex
+	rows  map[string][]byte
+	audit [][]byte
+	// outbox stands in for the outbox table, in seq order
+	outbox    []outboxRow
+	outboxSeq int64
+	// leases stands in for the leases table, by name
+	leases map[string]lease
+	// apiKeys stands in for the api_keys table, by name
+	apiKeys map[string]APIKeyRecord
+	// index stands in for the item_index table
+	index map[indexKey]IndexRecord
+	// schemaVersion and schemaDirty stand in for schema_migrations
+	schemaVersion int
+	schemaDirty   bool
+}
+
+// NewDatabaseConnection creates a new database connection - PROPER INITIALIZATION.
+// A database that can't be reached yet doesn't stop the server; queries
+// keep reconnecting until it is back.
+func NewDatabaseConnection(host string, port int, username, password, dbName string, pool config.DatabasePoolConfig) (*DatabaseConnection, error) {
+	db := &DatabaseConnection{
+		Host:     host,
+		Port:     port,
+		Username: username,
+		Password: password,
+		DBName:   dbName,
+		rows:     make(map[string][]byte),
+	}
+	db.pool = newConnPool(pool, db.dial)
+
+	if err := db.do(func() error { return nil }); err != nil {
+		logging.Printf("Database %s not reachable yet, will reconnect on demand: %v", dbName, err)
+	} else {
+		fmt.Printf("Successfully connected to database: %s\n", dbName)
+	}
+	return db, nil
+}
+
+// RedactLogs passes payloads through redact before they are logged. Call it
+// before the connection is used.
+func (db *DatabaseConnection) RedactLogs(redact func([]byte) []byte) {
+	db.redact = redact
+}
+
+func (db *DatabaseConnection) redactForLog(data []byte) []byte {
+	if db.redact == nil {
+		return data
+	}
+	return db.redact(data)
+}
+
+func (db *DatabaseConnection) dial() (*dbConn, error) {
+	fmt.Printf("Establishing database connection to %s:%d...\n", db.Host, db.Port)
+	return &dbConn{createdAt: time.Now()}, nil
+}
+
+func (db *DatabaseConnection) Save(id string, data []byte) error {
+	return db.do(func() error {
+		fmt.Printf("Saving data to database %s: %s\n", db.DBName, string(db.redactForLog(data)))
+
+		db.mu.Lock()
+		defer db.mu.Unlock()
+		db.rows[id] = append([]byte(nil), data...)
+		return nil
+	})
+}
+
+// DatabaseRow is one row of a bulk insert
+type DatabaseRow struct {
+	Key  string
+	Data []byte
+}
+
+// SaveMany writes rows in one bulk insert, in order
+func (db *DatabaseConnection) SaveMany(rows []DatabaseRow) error {
+	return db.do(func() error {
+		fmt.Printf("Saving %d rows to database %s\n", len(rows), db.DBName)
+
+		db.mu.Lock()
+		defer db.mu.Unlock()
+		for _, row := range rows {
+			db.rows[row.Key] = append([]byte(nil), row.Data...)
+		}
+		return nil
+	})
+}
+
+func (db *DatabaseConnection) Load(id string) ([]byte, error) {
+	var data []byte
+	err := db.do(func() error {
+		db.mu.RLock()
+		defer db.mu.RUnlock()
+		row, ok := db.rows[id]
+		if !ok {
+			return ErrNotFound
+		}
+		data = append([]byte(nil), row...)
+		return nil
+	})
+	return data, err
+}
+
+func (db *DatabaseConnection) Delete(id string) error {
+	return db.do(func() error {
+		db.mu.Lock()
+		defer db.mu.Unlock()
+		if _, ok := db.rows[id]; !ok {
+			return ErrNotFound
+		}
+		delete(db.rows, id)
+		return nil
+	})
+}
+
+func (db *DatabaseConnection) List() ([]string, error) {
+	var ids []string
+	err := db.do(func() error {
+		db.mu.RLock()
+		defer db.mu.RUnlock()
+		ids = make([]string, 0, len(db.rows))
+		for id := range db.rows {
+			ids = append(ids, id)
+		}
+		return nil
+	})
+	return ids, err
+}
+
+func (db *DatabaseConnection) Close() error {
+	fmt.Printf("Closing database connection to %s\n", db.DBName)
+	db.pool.Close()
+	return nil
+}
+
+// AppendAudit adds an encoded event to the audit table
+func (db *DatabaseConnection) AppendAudit(record []byte) error {
+	return db.do(func() error {
+		db.mu.Lock()
+		defer db.mu.Unlock()
+		db.audit = append(db.audit, record)
+		return nil
+	})
+}
+
+// AuditRecords returns the audit table in the order events were appended
+func (db *DatabaseConnection) AuditRecords() ([][]byte, error) {
+	var records [][]byte
+	err := db.do(func() error {
+		db.mu.RLock()
+		defer db.mu.RUnlock()
+		records = slices.Clone(db.audit)
+		return nil
+	})
+	return records, err
+}
+
+// StorageFactory - IMPLEMENTS Factory Pattern. Storage is created per
+// tenant so every backend isolates tenants from each other.
+type StorageFactory interface {
+	CreateStorage(tenant, storageType string) (StorageInterface, error)
+}
+
+// Locator is implemented by storage factories that can tell where a key is
+// kept
+type Locator interface {
+	Location(storageType, key string) string
+}
+
+// TenantLister is implemented by storage factories that can list the
+// tenants with data in a storage type, which background jobs walking every
+// item need
+type TenantLister interface {
+	StorageFactory
+	Tenants(storageType string) ([]string, error)
+}
+
+// TieredFactory is implemented by storage factories that keep storage
+// types in a hot and a cold tier
+type TieredFactory interface {
+	ColdTier(storageType string) string
+	HotKeys(tenant, storageType string) ([]string, error)
+	Demote(tenant, storageType string, keys []string) error
+}
+
+// EachTenant calls fn for every tenant with data in factory, in every
+// storage type, stopping at the first error
+func EachTenant(factory TenantLister, fn func(tenant, storageType string) error) error {
+	for _, storageType := range StorageTypes() {
+		tenants, err := factory.Tenants(storageType)
+		if err != nil {
+			return err
+		}
+		for _, tenant := range tenants {
+			if err := fn(tenant, storageType); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// ColdTier returns the cold tier of a storage type, or "" if factory has
+// no tiers
+func ColdTier(factory StorageFactory, storageType string) string {
+	if tiered, ok := factory.(TieredFactory); ok {
+		return tiered.ColdTier(storageType)
+	}
+	return ""
+}
+
+// RawStorage returns a tenant's storage as stored, below deduplication
+// for the configured backends
+func RawStorage(factory StorageFactory, tenant, storageType string) (StorageInterface, error) {
+	if concrete, ok := factory.(*ConcreteStorageFactory); ok {
+		sharded, err := concrete.Sharded(tenant, storageType)
+		if err != nil {
+			return nil, err
+		}
+		return sharded, nil
+	}
+	return factory.CreateStorage(tenant, storageType)
+}
+
+// Invalidate drops a key written through RawStorage from factory's read
+// cache, if it has one
+func Invalidate(factory StorageFactory, tenant, storageType, key string) {
+	if concrete, ok := factory.(*ConcreteStorageFactory); ok {
+		concrete.Invalidate(tenant, storageType, key)
+	}
+}
+
+// ConcreteStorageFactory implements StorageFactory. Every backend is split
+// into shards by the router; the root shard "" is the unsharded layout.
+// Deduplication sits above sharding, so blobs are sharded like items.
+type ConcreteStorageFactory struct {
+	// dataDir holds the tenants directory of file storage
+	dataDir  string
+	layout   config.FileLayout
+	logs     *LogBackend
+	disk     *DiskChecker
+	database *DatabaseConnection
+	batcher  *WriteBatcher
+	shards   *ShardRouter
+	dedup    *Deduplicator
+	cache    *ReadCache
+	shadow   *ShadowWriter
+	hedger   *Hedger
+	replicas *Replicator
+	tiers    *Tiering
+	// settings are the config sections of the storage backends
+	settings map[string]json.RawMessage
+	backends map[string]Backend
+	// decorators configures each storage type's decorator chain, built
+	// into chains
+	decorators map[string][]config.DecoratorConfig
+	metrics    *metrics.Metrics
+	chains     map[string]Decorator
+	// faultInjection permits chaos decorators
+	faultInjection bool
+	// types lists the backends' storage types in registration order
+	types []string
+}
+
+// FactoryOption customizes a ConcreteStorageFactory built by
+// NewStorageFactory
+type FactoryOption func(*ConcreteStorageFactory)
+
+// WithFileLayout spreads file storage over subdirectories
+func WithFileLayout(layout config.FileLayout) FactoryOption {
+	return func(f *ConcreteStorageFactory) { f.layout = layout }
+}
+
+// WithLogBackend serves the "log" storage type from logs
+func WithLogBackend(logs *LogBackend) FactoryOption {
+	return func(f *ConcreteStorageFactory) { f.logs = logs }
+}
+
+// WithDiskChecker refuses file and log saves while disk fails its checks
+func WithDiskChecker(disk *DiskChecker) FactoryOption {
+	return func(f *ConcreteStorageFactory) { f.disk = disk }
+}
+
+// WithDatabase serves the "database" storage type from database, through
+// batcher when it isn't nil
+func WithDatabase(database *DatabaseConnection, batcher *WriteBatcher) FactoryOption {
+	return func(f *ConcreteStorageFactory) { f.database, f.batcher = database, batcher }
+}
+
+// WithBackendSettings hands each storage backend its config section
+func WithBackendSettings(settings map[string]json.RawMessage) FactoryOption {
+	return func(f *ConcreteStorageFactory) { f.settings = settings }
+}
+
+// WithDecorators wraps each storage type's backend in its configured
+// decorator chain; metrics serves the metrics decorator and counts every
+// backend call
+func WithDecorators(decorators map[string][]config.DecoratorConfig, metrics *metrics.Metrics) FactoryOption {
+	return func(f *ConcreteStorageFactory) { f.decorators, f.metrics = decorators, metrics }
+}
+
+// WithShardRouter splits every backend into the router's shards
+func WithShardRouter(shards *ShardRouter) FactoryOption {
+	return func(f *ConcreteStorageFactory) { f.shards = shards }
+}
+
+// WithDeduplicator stores identical payloads once
+func WithDeduplicator(dedup *Deduplicator) FactoryOption {
+	return func(f *ConcreteStorageFactory) { f.dedup = dedup }
+}
+
+// WithReadCache serves repeated reads from cache
+func WithReadCache(cache *ReadCache) FactoryOption {
+	return func(f *ConcreteStorageFactory) { f.cache = cache }
+}
+
+// WithFaultInjection permits chaos decorators when allowed; without it a
+// chain with one is refused, so test settings can't reach production by
+// accident
+func WithFaultInjection(allowed bool) FactoryOption {
+	return func(f *ConcreteStorageFactory) { f.faultInjection = allowed }
+}
+
+// WithShadowWriter repeats writes on the candidate backends shadow is
+// configured with
+func WithShadowWriter(shadow *ShadowWriter) FactoryOption {
+	return func(f *ConcreteStorageFactory) { f.shadow = shadow }
+}
+
+// WithHedger hedges slow reads of replicated storage types across their
+// copies
+func WithHedger(hedger *Hedger) FactoryOption {
+	return func(f *ConcreteStorageFactory) { f.hedger = hedger }
+}
+
+// WithReplicator writes storage types with replicas to every copy
+func WithReplicator(replicator *Replicator) FactoryOption {
+	return func(f *ConcreteStorageFactory) { f.replicas = replicator }
+}
+
+// WithTiering moves old items of storage types with a cold tier there
+func WithTiering(tiers *Tiering) FactoryOption {
+	return func(f *ConcreteStorageFactory) { f.tiers = tiers }
+}
+
+// NewStorageFactory builds a factory keeping files under dataDir, serving
+// every storage type registered so far. Without options it serves file and
+// log storage unsharded, with the default log segment settings, and has no
+// database.
+func NewStorageFactory(dataDir string, opts ...FactoryOption) (*ConcreteStorageFactory, error) {
+	f := &ConcreteStorageFactory{dataDir: dataDir}
+	for _, opt := range opts {
+		opt(f)
+	}
+	if f.logs == nil {
+		f.logs = NewLogBackend(config.NewConfiguration().LogStorage)
+	}
+	if f.shards == nil {
+		f.shards = &ShardRouter{current: singleShard{}}
+	}
+	if f.dedup == nil {
+		f.dedup = NewDeduplicator(config.DedupConfig{})
+	}
+	env := BackendEnv{
+		DataDir:  dataDir,
+		Layout:   f.layout,
+		Logs:     f.logs,
+		Disk:     f.disk,
+		Database: f.database,
+		Batcher:  f.batcher,
+	}
+	var err error
+	if f.backends, f.types, err = newBackends(env, f.settings); err != nil {
+		return nil, err
+	}
+	if f.shadow != nil {
+		if err := f.shadow.validate(f.backends); err != nil {
+			return nil, err
+		}
+		f.shadow.open = f.candidate
+	}
+	if f.hedger != nil {
+		if err := f.hedger.validate(); err != nil {
+			return nil, err
+		}
+	}
+	if f.replicas != nil {
+		if err := f.replicas.validate(f.backends); err != nil {
+			return nil, err
+		}
+		f.replicas.open, f.replicas.invalidate = f.candidate, f.Invalidate
+		f.replicas.hedger = f.hedger
+	}
+	if f.tiers != nil {
+		if err := f.tiers.validate(f.backends); err != nil {
+			return nil, err
+		}
+		f.tiers.open = f.candidate
+	}
+	if f.metrics != nil {
+		describeBackendMetrics(f.metrics)
+	}
+	f.chains = make(map[string]Decorator, len(f.decorators))
+	for storageType, configs := range f.decorators {
+		if _, ok := f.backends[storageType]; !ok {
+			return nil, fmt.Errorf("decorators for unknown storage type: %q", storageType)
+		}
+		if !f.faultInjection && slices.ContainsFunc(configs, isChaos) {
+			return nil, fmt.Errorf("storage type %s: chaos decorators need allow_fault_injection", storageType)
+		}
+		if f.chains[storageType], err = newDecoratorChain(storageType, configs, f.metrics); err != nil {
+			return nil, fmt.Errorf("storage type %s: %w", storageType, err)
+		}
+	}
+	return f, nil
+}
+
+func (f *ConcreteStorageFactory) CreateStorage(tenant, storageType string) (StorageInterface, error) {
+	sharded, err := f.Sharded(tenant, storageType)
+	if err != nil {
+		return nil, err
+	}
+	cached := f.tiers.wrap(tenant, storageType, f.cache.wrap(tenant, storageType, f.dedup.wrap(sharded)))
+	replicated := f.replicas.wrap(tenant, storageType, cached)
+	return f.shadow.wrap(tenant, storageType, replicated), nil
+}
+
+// candidate returns a tenant's storage of a shadow candidate or replica, as
+// saves would reach it without the read cache
+func (f *ConcreteStorageFactory) candidate(tenant, storageType string) (StorageInterface, error) {
+	sharded, err := f.Sharded(tenant, storageType)
+	if err != nil {
+		return nil, err
+	}
+	return f.dedup.wrap(sharded), nil
+}
+
+// Sharded returns a tenant's storage below deduplication, as stored
+func (f *ConcreteStorageFactory) Sharded(tenant, storageType string) (*ShardedStorage, error) {
+	if _, err := f.openShard(tenant, storageType, ""); err != nil {
+		return nil, err
+	}
+	return &ShardedStorage{
+		router: f.shards,
+		Open: func(shard string) StorageInterface {
+			storage, _ := f.openShard(tenant, storageType, shard)
+			return storage
+		},
+	}, nil
+}
+
+// Location names where a storage type keeps a key: the storage type, and
+// the shard when items are sharded
+func (f *ConcreteStorageFactory) Location(storageType, key string) string {
+	if shard := f.shards.locations(key)[0]; shard != "" {
+		return storageType + "/" + shard
+	}
+	return storageType
+}
+
+// Invalidate drops a key of a tenant's storage type from the read cache,
+// for keys written through Sharded
+func (f *ConcreteStorageFactory) Invalidate(tenant, storageType, key string) {
+	if f.cache == nil || !f.cache.config.Enabled {
+		return
+	}
+	f.cache.invalidate(storageType + "/" + tenant + "/" + key)
+}
+
+// openShard returns the backend holding one shard of a tenant's items
+func (f *ConcreteStorageFactory) openShard(tenant, storageType, shard string) (StorageInterface, error) {
+	backend, ok := f.backends[storageType]
+	if !ok {
+		return nil, fmt.Errorf("unsupported storage type: %s", storageType)
+	}
+	storage, err := backend.Open(tenant, shard)
+	if err != nil {
+		return nil, err
+	}
+	return f.decorate(tenant, storageType, storage), nil
+}
+
+// decorate wraps a tenant's shard of a storage type in the type's
+// decorator chain, over the instrumentation of its calls
+func (f *ConcreteStorageFactory) decorate(tenant, storageType string, storage StorageInterface) StorageInterface {
+	if f.metrics != nil {
+		storage = &instrumentedStorage{next: storage, storageType: storageType, tenant: tenant, metrics: f.metrics}
+	}
+	if chain, ok := f.chains[storageType]; ok {
+		return chain(storage)
+	}
+	return storage
+}
+
+// databaseShard returns one shard of a tenant's rows in db, the database or
+// a transaction on it
+func databaseShard(db StorageInterface, tenant, shard string) *DatabaseStorage {
+	// Shard prefixes can't collide with tenant prefixes, which never
+	// contain a colon
+	prefix := tenant + "/"
+	if shard != "" {
+		prefix = "shard:" + shard + "/" + prefix
+	}
+	return &DatabaseStorage{db: db, prefix: prefix}
+}
+
+// EachTenant calls fn for every tenant with data, in every storage type,
+// stopping at the first error
+func (f *ConcreteStorageFactory) EachTenant(fn func(tenant, storageType string) error) error {
+	for _, storageType := range f.types {
+		tenants, err := f.Tenants(storageType)
+		if err != nil {
+			return err
+		}
+		for _, tenant := range tenants {
+			if err := fn(tenant, storageType); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Tenants lists every tenant with data in a storage type, its cold tier
+// included
+func (f *ConcreteStorageFactory) Tenants(storageType string) ([]string, error) {
+	backend, ok := f.backends[storageType]
+	if !ok {
+		return nil, nil
+	}
+	tenants, err := backend.Tenants()
+	if err != nil {
+		return nil, err
+	}
+	if coldTier := f.ColdTier(storageType); coldTier != "" {
+		cold, err := f.backends[coldTier].Tenants()
+		if err != nil {
+			return nil, err
+		}
+		tenants = append(tenants, cold...)
+		slices.Sort(tenants)
+		tenants = slices.Compact(tenants)
+	}
+	return tenants, nil
+}
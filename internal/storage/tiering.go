@@ -0,0 +1,185 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+
+	"interview-task/internal/config"
+	"interview-task/internal/logging"
+)
+
+// Tiering - IMPLEMENTS hot and cold tiers. Keys of a storage type with a
+// cold tier are written to the storage type itself, and read from it or
+// else from the cold tier, where the transition job moves items once they
+// are old enough.
+type Tiering struct {
+	config config.TieringConfig
+	// open returns a tenant's storage of a tier, below the read cache; the
+	// factory sets it
+	open func(tenant, storageType string) (StorageInterface, error)
+}
+
+func NewTiering(config config.TieringConfig) *Tiering {
+	return &Tiering{config: config}
+}
+
+// validate checks that every hot and cold tier is a distinct storage type
+// among backends
+func (t *Tiering) validate(backends map[string]Backend) error {
+	for hot, cold := range t.config.ColdTiers {
+		if _, ok := backends[hot]; !ok {
+			return fmt.Errorf("cold tier for unknown storage type: %q", hot)
+		}
+		if _, ok := backends[cold]; !ok {
+			return fmt.Errorf("unknown cold tier of %s: %q", hot, cold)
+		}
+		if hot == cold {
+			return fmt.Errorf("storage type %s can't be its own cold tier", hot)
+		}
+		if _, ok := t.config.ColdTiers[cold]; ok {
+			return fmt.Errorf("cold tier %s of %s has a cold tier itself", cold, hot)
+		}
+	}
+	return nil
+}
+
+// wrap reads a tenant's keys from the storage type's cold tier too, if it
+// has one
+func (t *Tiering) wrap(tenant, storageType string, storage StorageInterface) StorageInterface {
+	if t == nil || t.config.ColdTiers[storageType] == "" {
+		return storage
+	}
+	return &TieredStorage{hot: storage, tiering: t, tenant: tenant, storageType: storageType}
+}
+
+// TieredStorage serves a tenant's keys from the hot tier, falling back to
+// the cold tier. Writes go to the hot tier, so an item written after it was
+// moved is hot again; the copies it leaves in the cold tier are hidden by
+// the hot ones, and replaced when it is moved again.
+type TieredStorage struct {
+	hot         StorageInterface
+	tiering     *Tiering
+	tenant      string
+	storageType string
+}
+
+func (s *TieredStorage) cold() (StorageInterface, error) {
+	return s.tiering.open(s.tenant, s.tiering.config.ColdTiers[s.storageType])
+}
+
+func (s *TieredStorage) Save(id string, data []byte) error {
+	return s.hot.Save(id, data)
+}
+
+func (s *TieredStorage) Load(id string) ([]byte, error) {
+	data, err := s.hot.Load(id)
+	if !errors.Is(err, ErrNotFound) {
+		return data, err
+	}
+	cold, err := s.cold()
+	if err != nil {
+		return nil, err
+	}
+	return cold.Load(id)
+}
+
+// Delete removes a key from both tiers
+func (s *TieredStorage) Delete(id string) error {
+	hotErr := s.hot.Delete(id)
+	if hotErr != nil && !errors.Is(hotErr, ErrNotFound) {
+		return hotErr
+	}
+	cold, err := s.cold()
+	if err != nil {
+		return err
+	}
+	coldErr := cold.Delete(id)
+	if coldErr != nil && !errors.Is(coldErr, ErrNotFound) {
+		return coldErr
+	}
+	if hotErr != nil && coldErr != nil {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (s *TieredStorage) List() ([]string, error) {
+	keys, err := s.hot.List()
+	if err != nil {
+		return nil, err
+	}
+	cold, err := s.cold()
+	if err != nil {
+		return nil, err
+	}
+	coldKeys, err := cold.List()
+	if err != nil {
+		return nil, err
+	}
+	seen := make(map[string]bool, len(keys))
+	for _, key := range keys {
+		seen[key] = true
+	}
+	for _, key := range coldKeys {
+		if !seen[key] {
+			keys = append(keys, key)
+		}
+	}
+	return keys, nil
+}
+
+// ColdTier is the storage type a storage type's old items are moved to,
+// empty without one
+func (f *ConcreteStorageFactory) ColdTier(storageType string) string {
+	if f.tiers == nil {
+		return ""
+	}
+	return f.tiers.config.ColdTiers[storageType]
+}
+
+// HotKeys lists the keys of a tenant's storage type that are in its hot
+// tier
+func (f *ConcreteStorageFactory) HotKeys(tenant, storageType string) ([]string, error) {
+	hot, err := f.candidate(tenant, storageType)
+	if err != nil {
+		return nil, err
+	}
+	return hot.List()
+}
+
+// Demote moves keys of a tenant's storage type from its hot tier to its
+// cold tier. Every key is copied before any is deleted, so a failed move
+// leaves the keys hot.
+func (f *ConcreteStorageFactory) Demote(tenant, storageType string, keys []string) error {
+	coldTier := f.ColdTier(storageType)
+	if coldTier == "" {
+		return fmt.Errorf("storage type %s has no cold tier", storageType)
+	}
+	hot, err := f.candidate(tenant, storageType)
+	if err != nil {
+		return err
+	}
+	cold, err := f.candidate(tenant, coldTier)
+	if err != nil {
+		return err
+	}
+	for _, key := range keys {
+		data, err := hot.Load(key)
+		if errors.Is(err, ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", key, err)
+		}
+		if err := cold.Save(key, data); err != nil {
+			return fmt.Errorf("failed to copy %s to %s: %w", key, coldTier, err)
+		}
+	}
+	for _, key := range keys {
+		if err := hot.Delete(key); err != nil && !errors.Is(err, ErrNotFound) {
+			logging.Printf("Failed to remove %s of tenant %s from %s after moving it to %s: %v", key, tenant, storageType, coldTier, err)
+		}
+		f.Invalidate(tenant, storageType, key)
+	}
+	return nil
+}
@@ -0,0 +1,198 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+var (
+	ErrTxDone = errors.New("transaction has already been committed or rolled back")
+	// ErrNotTransactional is returned for storage types that can't roll
+	// back writes
+	ErrNotTransactional = errors.New("storage type does not support transactions")
+)
+
+// DatabaseTx - IMPLEMENTS transactions on the database. Writes are staged
+// and applied together on Commit; reads see the staged writes.
+type DatabaseTx struct {
+	db *DatabaseConnection
+
+	mu sync.Mutex
+	// writes holds staged rows by key, nil for a deleted row
+	writes map[string][]byte
+	// outbox holds staged outbox records
+	outbox [][]byte
+	done   bool
+}
+
+func (db *DatabaseConnection) Begin() *DatabaseTx {
+	return &DatabaseTx{db: db, writes: make(map[string][]byte)}
+}
+
+func (tx *DatabaseTx) Save(id string, data []byte) error {
+	tx.mu.Lock()
+	defer tx.mu.Unlock()
+	if tx.done {
+		return ErrTxDone
+	}
+	tx.writes[id] = append([]byte{}, data...)
+	return nil
+}
+
+func (tx *DatabaseTx) Load(id string) ([]byte, error) {
+	tx.mu.Lock()
+	defer tx.mu.Unlock()
+	if tx.done {
+		return nil, ErrTxDone
+	}
+	if data, ok := tx.writes[id]; ok {
+		if data == nil {
+			return nil, ErrNotFound
+		}
+		return append([]byte(nil), data...), nil
+	}
+	return tx.db.Load(id)
+}
+
+func (tx *DatabaseTx) Delete(id string) error {
+	tx.mu.Lock()
+	defer tx.mu.Unlock()
+	if tx.done {
+		return ErrTxDone
+	}
+	if data, ok := tx.writes[id]; ok {
+		if data == nil {
+			return ErrNotFound
+		}
+	} else if _, err := tx.db.Load(id); err != nil {
+		return err
+	}
+	tx.writes[id] = nil
+	return nil
+}
+
+func (tx *DatabaseTx) List() ([]string, error) {
+	tx.mu.Lock()
+	defer tx.mu.Unlock()
+	if tx.done {
+		return nil, ErrTxDone
+	}
+	keys, err := tx.db.List()
+	if err != nil {
+		return nil, err
+	}
+	live := make(map[string]bool, len(keys))
+	for _, key := range keys {
+		live[key] = true
+	}
+	for key, data := range tx.writes {
+		live[key] = data != nil
+	}
+	ids := make([]string, 0, len(live))
+	for key, ok := range live {
+		if ok {
+			ids = append(ids, key)
+		}
+	}
+	sort.Strings(ids)
+	return ids, nil
+}
+
+// Commit applies every staged write at once
+func (tx *DatabaseTx) Commit() error {
+	tx.mu.Lock()
+	defer tx.mu.Unlock()
+	if tx.done {
+		return ErrTxDone
+	}
+	tx.done = true
+	db := tx.db
+	return db.do(func() error {
+		db.mu.Lock()
+		defer db.mu.Unlock()
+		for key, data := range tx.writes {
+			if data == nil {
+				delete(db.rows, key)
+			} else {
+				db.rows[key] = data
+			}
+		}
+		now := time.Now().UTC()
+		for _, record := range tx.outbox {
+			db.outboxSeq++
+			db.outbox = append(db.outbox, outboxRow{OutboxRecord: OutboxRecord{Seq: db.outboxSeq, Record: record, CreatedAt: now}})
+		}
+		return nil
+	})
+}
+
+// Rollback discards the staged writes. It is a no-op after Commit, so it
+// can be deferred.
+func (tx *DatabaseTx) Rollback() error {
+	tx.mu.Lock()
+	defer tx.mu.Unlock()
+	tx.done = true
+	tx.writes = nil
+	tx.outbox = nil
+	return nil
+}
+
+// StorageTx is a tenant's storage whose writes only take effect on Commit
+type StorageTx struct {
+	StorageInterface
+	tx *DatabaseTx
+	// committed runs after a successful commit
+	committed func()
+	// after holds what AfterCommit registered
+	after []func()
+}
+
+// AfterCommit registers fn to run after a successful commit, for effects
+// outside the database that must not happen if the transaction rolls back
+func (s *StorageTx) AfterCommit(fn func()) {
+	s.after = append(s.after, fn)
+}
+
+func (s *StorageTx) Commit() error {
+	if err := s.tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	s.committed()
+	for _, fn := range s.after {
+		fn()
+	}
+	return nil
+}
+
+func (s *StorageTx) Rollback() error {
+	return s.tx.Rollback()
+}
+
+// TransactionalFactory is implemented by storage factories that can open
+// transactions
+type TransactionalFactory interface {
+	Begin(tenant, storageType string) (*StorageTx, error)
+}
+
+// Begin opens a transaction on a tenant's storage. Only the database storage
+// type supports them; others return ErrNotTransactional.
+func (f *ConcreteStorageFactory) Begin(tenant, storageType string) (*StorageTx, error) {
+	if storageType != "database" {
+		return nil, fmt.Errorf("%w: %s", ErrNotTransactional, storageType)
+	}
+	if f.database == nil {
+		return nil, fmt.Errorf("database connection not available")
+	}
+	tx := f.database.Begin()
+	sharded := &ShardedStorage{
+		router: f.shards,
+		Open: func(shard string) StorageInterface {
+			return f.decorate(tenant, storageType, databaseShard(tx, tenant, shard))
+		},
+	}
+	storage, invalidate := f.cache.wrapTx(tenant, storageType, f.dedup.wrap(sharded))
+	return &StorageTx{StorageInterface: storage, tx: tx, committed: invalidate}, nil
+}
@@ -0,0 +1,604 @@
+// Package client is a typed Go client for the data service's HTTP API. It
+// talks to the /v1 routes, retries transient failures and authenticates
+// with an API key.
+package client
+
+import (
+	"bytes"
+	"context"
+	crand "crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RetryPolicy controls how requests that failed transiently are retried:
+// network errors and 429, 502, 503 and 504 responses
+type RetryPolicy struct {
+	// MaxAttempts includes the first try; 1 disables retries
+	MaxAttempts    int
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+}
+
+// DefaultRetryPolicy is used unless WithRetry replaces it
+var DefaultRetryPolicy = RetryPolicy{MaxAttempts: 4, InitialBackoff: 200 * time.Millisecond, MaxBackoff: 5 * time.Second}
+
+// Client - IMPLEMENTS calls to the data service. It is safe for concurrent
+// use.
+type Client struct {
+	baseURL    *url.URL
+	httpClient *http.Client
+	auth       func(*http.Request)
+	tenant     string
+	retry      RetryPolicy
+	timeout    time.Duration
+}
+
+// Option configures a Client
+type Option func(*Client)
+
+// WithAPIKey authenticates with an X-API-Key header
+func WithAPIKey(key string) Option {
+	return func(c *Client) {
+		c.auth = func(r *http.Request) { r.Header.Set("X-API-Key", key) }
+	}
+}
+
+// WithBearerToken authenticates with an Authorization: Bearer header
+func WithBearerToken(token string) Option {
+	return func(c *Client) {
+		c.auth = func(r *http.Request) { r.Header.Set("Authorization", "Bearer "+token) }
+	}
+}
+
+// WithTenant addresses every call to tenant; by default the server picks
+// the tenant bound to the key, or its default tenant
+func WithTenant(tenant string) Option {
+	return func(c *Client) { c.tenant = tenant }
+}
+
+// WithHTTPClient sends requests through httpClient instead of a default one
+func WithHTTPClient(httpClient *http.Client) Option {
+	return func(c *Client) { c.httpClient = httpClient }
+}
+
+// WithTimeout bounds each attempt of a call; zero leaves attempts bounded
+// only by the caller's context
+func WithTimeout(timeout time.Duration) Option {
+	return func(c *Client) { c.timeout = timeout }
+}
+
+// WithRetry replaces DefaultRetryPolicy
+func WithRetry(policy RetryPolicy) Option {
+	return func(c *Client) { c.retry = policy }
+}
+
+// New returns a client for the server at baseURL, such as
+// "http://localhost:8080"
+func New(baseURL string, opts ...Option) (*Client, error) {
+	parsed, err := url.Parse(baseURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid base URL: %w", err)
+	}
+	if parsed.Scheme != "http" && parsed.Scheme != "https" {
+		return nil, fmt.Errorf("base URL must be http or https, got %q", baseURL)
+	}
+	c := &Client{
+		baseURL:    parsed,
+		httpClient: &http.Client{},
+		auth:       func(*http.Request) {},
+		retry:      DefaultRetryPolicy,
+		timeout:    30 * time.Second,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	c.retry.MaxAttempts = max(c.retry.MaxAttempts, 1)
+	return c, nil
+}
+
+// APIError is a response the server answered with an error status
+type APIError struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("server returned %d: %s", e.StatusCode, e.Message)
+}
+
+// IsNotFound reports whether err is the server saying an item doesn't exist
+func IsNotFound(err error) bool {
+	var apiErr *APIError
+	return errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusNotFound
+}
+
+// SaveRequest is an item to store
+type SaveRequest struct {
+	Data        []byte
+	StorageType string
+	// ContentType is sniffed by the server when empty
+	ContentType string
+	// TTL is how long the item lives; zero applies the server's default
+	TTL    time.Duration
+	Labels map[string]string
+	Source string
+}
+
+func (r *SaveRequest) wire() map[string]any {
+	body := map[string]any{"data": r.Data, "storage_type": r.StorageType}
+	if r.ContentType != "" {
+		body["content_type"] = r.ContentType
+	}
+	if r.TTL != 0 {
+		body["ttl"] = r.TTL.String()
+	}
+	if len(r.Labels) > 0 {
+		body["labels"] = r.Labels
+	}
+	if r.Source != "" {
+		body["source"] = r.Source
+	}
+	return body
+}
+
+// SaveResult identifies a saved item
+type SaveResult struct {
+	ID     string `json:"id"`
+	SHA256 string `json:"sha256"`
+	// Accepted is set when the server spooled the item because its backend
+	// was unavailable; it is saved once the backend recovers
+	Accepted bool `json:"-"`
+}
+
+// Item is a stored payload
+type Item struct {
+	Data        []byte
+	ContentType string
+	SHA256      string
+	// ETag names the item's version, for an If-Match on a later write
+	ETag string
+}
+
+// ItemSummary describes an item without its payload
+type ItemSummary struct {
+	ID          string            `json:"id"`
+	Size        int               `json:"size"`
+	ContentType string            `json:"content_type,omitempty"`
+	SHA256      string            `json:"sha256,omitempty"`
+	Labels      map[string]string `json:"labels,omitempty"`
+	Source      string            `json:"source,omitempty"`
+	ExpiresAt   time.Time         `json:"expires_at,omitzero"`
+	ModifiedAt  time.Time         `json:"modified_at,omitzero"`
+}
+
+// ListOptions filters List; every set field must match
+type ListOptions struct {
+	Labels map[string]string
+	Source string
+	// ContentType is a media type pattern such as "image/*"
+	ContentType string
+}
+
+// BatchResult reports one item of a batch, in request order
+type BatchResult struct {
+	ID      string `json:"id,omitempty"`
+	SHA256  string `json:"sha256,omitempty"`
+	Spooled bool   `json:"spooled,omitempty"`
+	Error   string `json:"error,omitempty"`
+}
+
+// Save stores an item. Saves carry an idempotency key, so a retried save
+// stores the item once.
+func (c *Client) Save(ctx context.Context, req *SaveRequest) (*SaveResult, error) {
+	var result SaveResult
+	resp, err := c.call(ctx, http.MethodPost, "/v1/save-data", nil, req.wire(), &result)
+	if err != nil {
+		return nil, err
+	}
+	result.Accepted = resp.StatusCode == http.StatusAccepted
+	return &result, nil
+}
+
+// SavePlan is what a save would store, as reported by PlanSave
+type SavePlan struct {
+	ID          string    `json:"id"`
+	Tenant      string    `json:"tenant"`
+	StorageType string    `json:"storage_type"`
+	ContentType string    `json:"content_type"`
+	Size        int64     `json:"size"`
+	SHA256      string    `json:"sha256"`
+	ExpiresAt   time.Time `json:"expires_at,omitzero"`
+}
+
+// PlanSave runs every check of Save, failing as Save would, and reports
+// what would be stored without storing it
+func (c *Client) PlanSave(ctx context.Context, req *SaveRequest) (*SavePlan, error) {
+	var plan SavePlan
+	query := url.Values{"dry_run": {"true"}}
+	if _, err := c.call(ctx, http.MethodPost, "/v1/save-data", query, req.wire(), &plan); err != nil {
+		return nil, err
+	}
+	return &plan, nil
+}
+
+// SaveBatch stores several items in one call. Items without a storage type
+// use storageType. Atomic batches store every item or none and fail with
+// the first item's error; otherwise each result reports its own outcome.
+func (c *Client) SaveBatch(ctx context.Context, storageType string, items []*SaveRequest, atomic bool) ([]BatchResult, error) {
+	wireItems := make([]map[string]any, len(items))
+	for i, item := range items {
+		wireItems[i] = item.wire()
+	}
+	var response struct {
+		Items []BatchResult `json:"items"`
+	}
+	body := map[string]any{"storage_type": storageType, "atomic": atomic, "items": wireItems}
+	if _, err := c.call(ctx, http.MethodPost, "/v1/save-data/batch", nil, body, &response); err != nil {
+		return nil, err
+	}
+	return response.Items, nil
+}
+
+// Get loads an item's payload
+func (c *Client) Get(ctx context.Context, storageType, id string) (*Item, error) {
+	query := url.Values{"storage_type": {storageType}}
+	var data []byte
+	resp, err := c.call(ctx, http.MethodGet, "/v1/data/"+url.PathEscape(id), query, nil, &data)
+	if err != nil {
+		return nil, err
+	}
+	return &Item{Data: data, ContentType: resp.Header.Get("Content-Type"), SHA256: resp.Header.Get("X-Content-SHA256"), ETag: resp.Header.Get("ETag")}, nil
+}
+
+// List returns the items of a storage type matching opts, which may be nil
+func (c *Client) List(ctx context.Context, storageType string, opts *ListOptions) ([]ItemSummary, error) {
+	query := url.Values{"storage_type": {storageType}}
+	if opts != nil {
+		for key, value := range opts.Labels {
+			query.Add("label", key+":"+value)
+		}
+		if opts.Source != "" {
+			query.Set("source", opts.Source)
+		}
+		if opts.ContentType != "" {
+			query.Set("content_type", opts.ContentType)
+		}
+	}
+	var response struct {
+		Items []ItemSummary `json:"items"`
+	}
+	if _, err := c.call(ctx, http.MethodGet, "/v1/data", query, nil, &response); err != nil {
+		return nil, err
+	}
+	return response.Items, nil
+}
+
+// Delete removes an item
+func (c *Client) Delete(ctx context.Context, storageType, id string) error {
+	query := url.Values{"storage_type": {storageType}}
+	_, err := c.call(ctx, http.MethodDelete, "/v1/data/"+url.PathEscape(id), query, nil, nil)
+	return err
+}
+
+// Health returns nil when the server reports itself healthy
+func (c *Client) Health(ctx context.Context) error {
+	_, err := c.call(ctx, http.MethodGet, "/health", nil, nil, nil)
+	return err
+}
+
+// MigrationRequest copies every item of one storage type to another
+type MigrationRequest struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+	// Tenants restricts the migration; empty migrates every tenant
+	Tenants []string `json:"tenants,omitempty"`
+	// RateLimit is the most keys copied per second; zero doesn't throttle
+	RateLimit int `json:"rate_limit,omitempty"`
+	// DryRun counts what would be copied without writing anything
+	DryRun bool `json:"dry_run,omitempty"`
+}
+
+// MigrationStatus reports a migration's progress. State is idle, running,
+// completed, cancelled or failed.
+type MigrationStatus struct {
+	State   string            `json:"state"`
+	Request *MigrationRequest `json:"request,omitempty"`
+	Total   int               `json:"total"`
+	Scanned int               `json:"scanned"`
+	Copied  int               `json:"copied"`
+	Skipped int               `json:"skipped"`
+	Failed  int               `json:"failed"`
+	// Failures lists the first keys that failed
+	Failures   []MigrationFailure `json:"failures,omitempty"`
+	Error      string             `json:"error,omitempty"`
+	StartedAt  time.Time          `json:"started_at"`
+	FinishedAt time.Time          `json:"finished_at"`
+}
+
+// MigrationFailure is a key that couldn't be copied or verified
+type MigrationFailure struct {
+	Tenant string `json:"tenant"`
+	Key    string `json:"key"`
+	Error  string `json:"error"`
+}
+
+// Running reports whether the migration is still in progress
+func (s *MigrationStatus) Running() bool {
+	return s.State == "running"
+}
+
+// StartMigration starts copying items between storage types in the
+// background. It needs an admin key not bound to a tenant.
+func (c *Client) StartMigration(ctx context.Context, req *MigrationRequest) (*MigrationStatus, error) {
+	var status MigrationStatus
+	if _, err := c.call(ctx, http.MethodPost, "/v1/admin/migrate", nil, req, &status); err != nil {
+		return nil, err
+	}
+	return &status, nil
+}
+
+// Migration reports the progress of the running or last migration
+func (c *Client) Migration(ctx context.Context) (*MigrationStatus, error) {
+	var status MigrationStatus
+	if _, err := c.call(ctx, http.MethodGet, "/v1/admin/migrate", nil, nil, &status); err != nil {
+		return nil, err
+	}
+	return &status, nil
+}
+
+// CancelMigration stops the running migration
+func (c *Client) CancelMigration(ctx context.Context) (*MigrationStatus, error) {
+	var status MigrationStatus
+	if _, err := c.call(ctx, http.MethodDelete, "/v1/admin/migrate", nil, nil, &status); err != nil {
+		return nil, err
+	}
+	return &status, nil
+}
+
+// JournalReplayRequest selects the journaled writes to replay
+type JournalReplayRequest struct {
+	From time.Time `json:"from"`
+	To   time.Time `json:"to"`
+	// Tenants restricts the replay; empty replays every tenant
+	Tenants []string `json:"tenants,omitempty"`
+	// DryRun counts what would be replayed without writing anything
+	DryRun bool `json:"dry_run,omitempty"`
+}
+
+// JournalReplayReport is the outcome of a replay. Present items were still
+// stored; Removed items were deleted, expired or purged since.
+type JournalReplayReport struct {
+	Request  *JournalReplayRequest `json:"request"`
+	Scanned  int                   `json:"scanned"`
+	Replayed int                   `json:"replayed"`
+	Present  int                   `json:"present"`
+	Removed  int                   `json:"removed"`
+	Failed   int                   `json:"failed"`
+	Failures []ReplayFailure       `json:"failures,omitempty"`
+}
+
+// ReplayFailure is an item that couldn't be checked or written back
+type ReplayFailure struct {
+	Tenant      string `json:"tenant"`
+	StorageType string `json:"storage_type"`
+	ItemID      string `json:"item_id"`
+	Error       string `json:"error"`
+}
+
+// ReplayJournal writes back the items saved in a time range that the
+// server no longer holds. It needs an admin key not bound to a tenant.
+func (c *Client) ReplayJournal(ctx context.Context, req *JournalReplayRequest) (*JournalReplayReport, error) {
+	var report JournalReplayReport
+	if _, err := c.call(ctx, http.MethodPost, "/v1/admin/journal/replay", nil, req, &report); err != nil {
+		return nil, err
+	}
+	return &report, nil
+}
+
+// UsageRecord is one API key's usage of one tenant on one UTC day
+type UsageRecord struct {
+	Date        string `json:"date"`
+	Tenant      string `json:"tenant"`
+	Key         string `json:"key"`
+	Requests    int64  `json:"requests"`
+	BytesIn     int64  `json:"bytes_in"`
+	BytesOut    int64  `json:"bytes_out"`
+	BytesStored int64  `json:"bytes_stored"`
+}
+
+// Usage returns the caller's metered usage between two dates such as
+// "2026-01-31", inclusive; either may be empty. Admin keys get every key's.
+func (c *Client) Usage(ctx context.Context, from, to string) ([]UsageRecord, error) {
+	query := url.Values{}
+	if from != "" {
+		query.Set("from", from)
+	}
+	if to != "" {
+		query.Set("to", to)
+	}
+	var out struct {
+		Usage []UsageRecord `json:"usage"`
+	}
+	if _, err := c.call(ctx, http.MethodGet, "/v1/usage", query, nil, &out); err != nil {
+		return nil, err
+	}
+	return out.Usage, nil
+}
+
+// SaveOutcome is the result of an asynchronous save
+type SaveOutcome struct {
+	Result *SaveResult
+	Err    error
+}
+
+// SaveAsync saves req in the background. The channel receives the outcome
+// and is then closed.
+func (c *Client) SaveAsync(ctx context.Context, req *SaveRequest) <-chan SaveOutcome {
+	outcome := make(chan SaveOutcome, 1)
+	go func() {
+		defer close(outcome)
+		result, err := c.Save(ctx, req)
+		outcome <- SaveOutcome{Result: result, Err: err}
+	}()
+	return outcome
+}
+
+// SaveAll saves every request with at most concurrency saves in flight,
+// returning the outcomes in request order
+func (c *Client) SaveAll(ctx context.Context, reqs []*SaveRequest, concurrency int) []SaveOutcome {
+	outcomes := make([]SaveOutcome, len(reqs))
+	slots := make(chan struct{}, max(concurrency, 1))
+	var wg sync.WaitGroup
+	for i, req := range reqs {
+		slots <- struct{}{}
+		wg.Go(func() {
+			defer func() { <-slots }()
+			result, err := c.Save(ctx, req)
+			outcomes[i] = SaveOutcome{Result: result, Err: err}
+		})
+	}
+	wg.Wait()
+	return outcomes
+}
+
+// call sends a request, retrying transient failures, and decodes a
+// successful response into out: raw bytes for a *[]byte, JSON otherwise
+func (c *Client) call(ctx context.Context, method, path string, query url.Values, body, out any) (*http.Response, error) {
+	var payload []byte
+	if body != nil {
+		var err error
+		if payload, err = json.Marshal(body); err != nil {
+			return nil, fmt.Errorf("encoding request: %w", err)
+		}
+	}
+	idempotencyKey := ""
+	if method == http.MethodPost && body != nil {
+		idempotencyKey = newIdempotencyKey()
+	}
+
+	backoff := c.retry.InitialBackoff
+	for attempt := 1; ; attempt++ {
+		resp, data, err := c.attempt(ctx, method, path, query, payload, idempotencyKey)
+		retryAfter := time.Duration(0)
+		switch {
+		case err != nil:
+			if ctx.Err() != nil {
+				return nil, err
+			}
+		case retryable(resp.StatusCode):
+			err = apiError(resp, data)
+			if seconds, parseErr := strconv.Atoi(resp.Header.Get("Retry-After")); parseErr == nil {
+				retryAfter = time.Duration(seconds) * time.Second
+			}
+		case resp.StatusCode >= 400:
+			return resp, apiError(resp, data)
+		default:
+			return resp, decode(data, out)
+		}
+		if attempt >= c.retry.MaxAttempts {
+			return nil, err
+		}
+
+		// Full jitter keeps clients that failed together from retrying together
+		wait := max(retryAfter, time.Duration(rand.Int64N(int64(backoff)+1)))
+		backoff = min(backoff*2, c.retry.MaxBackoff)
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(wait):
+		}
+	}
+}
+
+func (c *Client) attempt(ctx context.Context, method, path string, query url.Values, payload []byte, idempotencyKey string) (*http.Response, []byte, error) {
+	if c.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.timeout)
+		defer cancel()
+	}
+	target := c.baseURL.JoinPath(c.tenantPrefix(), path)
+	target.RawQuery = query.Encode()
+	req, err := http.NewRequestWithContext(ctx, method, target.String(), bytes.NewReader(payload))
+	if err != nil {
+		return nil, nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	if payload != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if idempotencyKey != "" {
+		req.Header.Set("Idempotency-Key", idempotencyKey)
+	}
+	c.auth(req)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, nil, fmt.Errorf("reading response: %w", err)
+	}
+	return resp, data, nil
+}
+
+func (c *Client) tenantPrefix() string {
+	if c.tenant == "" {
+		return ""
+	}
+	return "/tenants/" + url.PathEscape(c.tenant)
+}
+
+func retryable(status int) bool {
+	switch status {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+func decode(data []byte, out any) error {
+	switch out := out.(type) {
+	case nil:
+		return nil
+	case *[]byte:
+		*out = data
+		return nil
+	default:
+		if err := json.Unmarshal(data, out); err != nil {
+			return fmt.Errorf("decoding response: %w", err)
+		}
+		return nil
+	}
+}
+
+// apiError reads the message of an error response, which is plain text or
+// a JSON object with an error field
+func apiError(resp *http.Response, data []byte) error {
+	message := strings.TrimSpace(string(data))
+	var structured struct {
+		Error string `json:"error"`
+	}
+	if json.Unmarshal(data, &structured) == nil && structured.Error != "" {
+		message = structured.Error
+	}
+	return &APIError{StatusCode: resp.StatusCode, Message: message}
+}
+
+func newIdempotencyKey() string {
+	b := make([]byte, 16)
+	crand.Read(b)
+	return hex.EncodeToString(b)
+}
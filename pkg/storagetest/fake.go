@@ -0,0 +1,193 @@
+package storagetest
+
+import (
+	"maps"
+	"slices"
+	"sync"
+	"time"
+
+	"interview-task/server"
+)
+
+// Op names a Storage operation, for injecting failures into it
+type Op string
+
+const (
+	OpSave   Op = "save"
+	OpLoad   Op = "load"
+	OpDelete Op = "delete"
+	OpList   Op = "list"
+)
+
+// Fake - IMPLEMENTS an in-memory server.Storage for tests, with knobs to
+// make operations slow or fail. It is safe for concurrent use.
+type Fake struct {
+	mu      sync.Mutex
+	items   map[string][]byte
+	calls   map[Op]int
+	latency time.Duration
+	// failures are returned by every call of an operation
+	failures map[Op]error
+	// next are returned by the next calls of an operation, before failures
+	next map[Op][]error
+}
+
+func NewFake() *Fake {
+	return &Fake{
+		items:    make(map[string][]byte),
+		calls:    make(map[Op]int),
+		failures: make(map[Op]error),
+		next:     make(map[Op][]error),
+	}
+}
+
+// FailWith makes every call of op fail with err; a nil err clears it
+func (f *Fake) FailWith(op Op, err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err == nil {
+		delete(f.failures, op)
+		return
+	}
+	f.failures[op] = err
+}
+
+// FailNext makes the next n calls of op fail with err, such as a backend
+// recovering after a few errors
+func (f *Fake) FailNext(op Op, n int, err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for range n {
+		f.next[op] = append(f.next[op], err)
+	}
+}
+
+// SetLatency delays every call by d, such as to exercise timeouts
+func (f *Fake) SetLatency(d time.Duration) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.latency = d
+}
+
+// Calls returns how often op was called, failed calls included
+func (f *Fake) Calls(op Op) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.calls[op]
+}
+
+// Items returns a copy of everything stored
+func (f *Fake) Items() map[string][]byte {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	items := make(map[string][]byte, len(f.items))
+	for id, data := range f.items {
+		items[id] = slices.Clone(data)
+	}
+	return items
+}
+
+// begin counts a call of op and returns its injected failure, if any. The
+// latency is slept without holding the lock.
+func (f *Fake) begin(op Op) error {
+	f.mu.Lock()
+	f.calls[op]++
+	latency := f.latency
+	err := f.failures[op]
+	if queued := f.next[op]; len(queued) > 0 {
+		err, f.next[op] = queued[0], queued[1:]
+	}
+	f.mu.Unlock()
+	if latency > 0 {
+		time.Sleep(latency)
+	}
+	return err
+}
+
+func (f *Fake) Save(id string, data []byte) error {
+	if err := f.begin(OpSave); err != nil {
+		return err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.items[id] = slices.Clone(data)
+	return nil
+}
+
+func (f *Fake) Load(id string) ([]byte, error) {
+	if err := f.begin(OpLoad); err != nil {
+		return nil, err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	data, ok := f.items[id]
+	if !ok {
+		return nil, server.ErrNotFound
+	}
+	return slices.Clone(data), nil
+}
+
+func (f *Fake) Delete(id string) error {
+	if err := f.begin(OpDelete); err != nil {
+		return err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if _, ok := f.items[id]; !ok {
+		return server.ErrNotFound
+	}
+	delete(f.items, id)
+	return nil
+}
+
+func (f *Fake) List() ([]string, error) {
+	if err := f.begin(OpList); err != nil {
+		return nil, err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return slices.Sorted(maps.Keys(f.items)), nil
+}
+
+// FakeFactory - IMPLEMENTS server.TenantLister over Fakes, one per tenant
+// and storage type, for server.WithStorageFactory
+type FakeFactory struct {
+	mu    sync.Mutex
+	fakes map[[2]string]*Fake
+}
+
+func NewFakeFactory() *FakeFactory {
+	return &FakeFactory{fakes: make(map[[2]string]*Fake)}
+}
+
+// Storage returns the Fake serving a tenant's storage type, creating it on
+// first use, so tests can inspect it or inject failures
+func (f *FakeFactory) Storage(tenant, storageType string) *Fake {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	key := [2]string{tenant, storageType}
+	fake, ok := f.fakes[key]
+	if !ok {
+		fake = NewFake()
+		f.fakes[key] = fake
+	}
+	return fake
+}
+
+func (f *FakeFactory) CreateStorage(tenant, storageType string) (server.Storage, error) {
+	return f.Storage(tenant, storageType), nil
+}
+
+// Tenants lists the tenants whose storage of storageType was used
+func (f *FakeFactory) Tenants(storageType string) ([]string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var tenants []string
+	for key := range f.fakes {
+		if key[1] == storageType {
+			tenants = append(tenants, key[0])
+		}
+	}
+	slices.Sort(tenants)
+	return tenants, nil
+}
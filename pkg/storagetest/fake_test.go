@@ -0,0 +1,87 @@
+package storagetest
+
+import (
+	"errors"
+	"testing"
+
+	"interview-task/server"
+)
+
+func TestFake(t *testing.T) {
+	TestStorage(t, func(t *testing.T) server.Storage { return NewFake() })
+}
+
+// call runs op once against fake and returns its error
+func call(fake *Fake, op Op) error {
+	switch op {
+	case OpSave:
+		return fake.Save("a", []byte("x"))
+	case OpLoad:
+		_, err := fake.Load("a")
+		return err
+	case OpDelete:
+		return fake.Delete("a")
+	case OpList:
+		_, err := fake.List()
+		return err
+	}
+	panic("unknown op " + op)
+}
+
+func TestFakeFailWith(t *testing.T) {
+	injected := errors.New("injected")
+	for _, op := range []Op{OpSave, OpLoad, OpDelete, OpList} {
+		t.Run(string(op), func(t *testing.T) {
+			fake := NewFake()
+			if err := fake.Save("a", []byte("x")); err != nil {
+				t.Fatal(err)
+			}
+			fake.FailWith(op, injected)
+			for range 2 {
+				if err := call(fake, op); !errors.Is(err, injected) {
+					t.Fatalf("%s returned %v, want the injected error", op, err)
+				}
+			}
+			fake.FailWith(op, nil)
+			if err := call(fake, op); err != nil {
+				t.Fatalf("%s returned %v after the failure was cleared", op, err)
+			}
+		})
+	}
+}
+
+func TestFakeFailNext(t *testing.T) {
+	injected := errors.New("injected")
+	for _, op := range []Op{OpSave, OpLoad, OpDelete, OpList} {
+		t.Run(string(op), func(t *testing.T) {
+			fake := NewFake()
+			if err := fake.Save("a", []byte("x")); err != nil {
+				t.Fatal(err)
+			}
+			before := fake.Calls(op)
+			fake.FailNext(op, 2, injected)
+			for i := range 2 {
+				if err := call(fake, op); !errors.Is(err, injected) {
+					t.Fatalf("call %d of %s returned %v, want the injected error", i+1, op, err)
+				}
+			}
+			if err := call(fake, op); err != nil {
+				t.Fatalf("%s returned %v once the queued failures were used", op, err)
+			}
+			if got := fake.Calls(op) - before; got != 3 {
+				t.Fatalf("%s was called %d times, want 3", op, got)
+			}
+		})
+	}
+}
+
+func TestFakeFailedSaveStoresNothing(t *testing.T) {
+	fake := NewFake()
+	fake.FailNext(OpSave, 1, errors.New("injected"))
+	if err := fake.Save("a", []byte("x")); err == nil {
+		t.Fatal("Save succeeded despite the injected failure")
+	}
+	if items := fake.Items(); len(items) != 0 {
+		t.Fatalf("failed Save stored %v", items)
+	}
+}
@@ -0,0 +1,195 @@
+// Package storagetest helps test code built on the data service's storage:
+// TestStorage checks a backend implements the server.Storage contract, and
+// Fake is an in-memory storage with failure injection for tests of code
+// using one.
+package storagetest
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"testing"
+
+	"interview-task/server"
+)
+
+// TestStorage - IMPLEMENTS a conformance suite for server.Storage
+// implementations. newStorage must return empty storage, not shared with
+// storage returned by other calls; each check runs as a subtest on storage
+// of its own.
+//
+// The contract checked: Save stores a copy of the payload, replacing any
+// item stored under the id; Load returns it, or ErrNotFound; Delete removes
+// it, or returns ErrNotFound; List returns every stored id, in any order.
+// IDs are the service's, such as hex item IDs and linked records like
+// "<id>~metadata".
+func TestStorage(t *testing.T, newStorage func(t *testing.T) server.Storage) {
+	checks := []struct {
+		name  string
+		check func(t *testing.T, storage server.Storage)
+	}{
+		{"SaveLoad", testSaveLoad},
+		{"EmptyPayload", testEmptyPayload},
+		{"Overwrite", testOverwrite},
+		{"LoadMissing", testLoadMissing},
+		{"Delete", testDelete},
+		{"DeleteMissing", testDeleteMissing},
+		{"List", testList},
+		{"ListEmpty", testListEmpty},
+		{"Copies", testCopies},
+		{"Concurrent", testConcurrent},
+	}
+	for _, c := range checks {
+		t.Run(c.name, func(t *testing.T) {
+			c.check(t, newStorage(t))
+		})
+	}
+}
+
+func mustSave(t *testing.T, storage server.Storage, id string, data []byte) {
+	t.Helper()
+	if err := storage.Save(id, data); err != nil {
+		t.Fatalf("Save(%q): %v", id, err)
+	}
+}
+
+func wantLoad(t *testing.T, storage server.Storage, id string, want []byte) {
+	t.Helper()
+	got, err := storage.Load(id)
+	if err != nil {
+		t.Fatalf("Load(%q): %v", id, err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Fatalf("Load(%q) = %q, want %q", id, got, want)
+	}
+}
+
+func wantList(t *testing.T, storage server.Storage, want ...string) {
+	t.Helper()
+	got, err := storage.List()
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	slices.Sort(got)
+	want = slices.Sorted(slices.Values(want))
+	if !slices.Equal(got, want) {
+		t.Fatalf("List = %q, want %q", got, want)
+	}
+}
+
+func testSaveLoad(t *testing.T, storage server.Storage) {
+	payloads := map[string][]byte{
+		"9f86d081884c7d659a2feaa0c55ad015":          []byte("hello"),
+		"9f86d081884c7d659a2feaa0c55ad015~metadata": []byte(`{"owner":"alice"}`),
+		"binary": {0x00, 0xff, 0x1f, 0x8b, '\n', '\r'},
+	}
+	for id, data := range payloads {
+		mustSave(t, storage, id, data)
+	}
+	for id, data := range payloads {
+		wantLoad(t, storage, id, data)
+	}
+}
+
+func testEmptyPayload(t *testing.T, storage server.Storage) {
+	mustSave(t, storage, "empty", []byte{})
+	wantLoad(t, storage, "empty", nil)
+}
+
+func testOverwrite(t *testing.T, storage server.Storage) {
+	mustSave(t, storage, "item", []byte("first"))
+	mustSave(t, storage, "item", []byte("second"))
+	wantLoad(t, storage, "item", []byte("second"))
+	wantList(t, storage, "item")
+}
+
+func testLoadMissing(t *testing.T, storage server.Storage) {
+	if _, err := storage.Load("missing"); !errors.Is(err, server.ErrNotFound) {
+		t.Fatalf("Load of a missing item: got %v, want ErrNotFound", err)
+	}
+}
+
+func testDelete(t *testing.T, storage server.Storage) {
+	mustSave(t, storage, "kept", []byte("kept"))
+	mustSave(t, storage, "deleted", []byte("deleted"))
+	if err := storage.Delete("deleted"); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if _, err := storage.Load("deleted"); !errors.Is(err, server.ErrNotFound) {
+		t.Fatalf("Load after Delete: got %v, want ErrNotFound", err)
+	}
+	wantLoad(t, storage, "kept", []byte("kept"))
+	wantList(t, storage, "kept")
+}
+
+func testDeleteMissing(t *testing.T, storage server.Storage) {
+	if err := storage.Delete("missing"); !errors.Is(err, server.ErrNotFound) {
+		t.Fatalf("Delete of a missing item: got %v, want ErrNotFound", err)
+	}
+}
+
+func testList(t *testing.T, storage server.Storage) {
+	var ids []string
+	for i := range 20 {
+		id := fmt.Sprintf("item-%02d", i)
+		mustSave(t, storage, id, []byte(id))
+		ids = append(ids, id)
+	}
+	ids = append(ids, "item-00~metadata")
+	mustSave(t, storage, "item-00~metadata", []byte("{}"))
+	wantList(t, storage, ids...)
+}
+
+func testListEmpty(t *testing.T, storage server.Storage) {
+	wantList(t, storage)
+}
+
+// testCopies checks storage doesn't share memory with its callers, which
+// reuse and modify buffers
+func testCopies(t *testing.T, storage server.Storage) {
+	data := []byte("original")
+	mustSave(t, storage, "item", data)
+	copy(data, "modified")
+	wantLoad(t, storage, "item", []byte("original"))
+
+	loaded, err := storage.Load("item")
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	copy(loaded, "modified")
+	wantLoad(t, storage, "item", []byte("original"))
+}
+
+func testConcurrent(t *testing.T, storage server.Storage) {
+	const workers, items = 8, 10
+	var wg sync.WaitGroup
+	errs := make(chan error, workers*items)
+	for w := range workers {
+		wg.Go(func() {
+			for i := range items {
+				id := fmt.Sprintf("w%d-%d", w, i)
+				if err := storage.Save(id, []byte(id)); err != nil {
+					errs <- fmt.Errorf("Save(%q): %w", id, err)
+					continue
+				}
+				if data, err := storage.Load(id); err != nil || string(data) != id {
+					errs <- fmt.Errorf("Load(%q) = %q, %v", id, data, err)
+				}
+			}
+		})
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+	got, err := storage.List()
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if len(got) != workers*items {
+		t.Fatalf("List returned %d ids, want %d", len(got), workers*items)
+	}
+}
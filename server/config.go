@@ -0,0 +1,19 @@
+package server
+
+import "interview-task/internal/config"
+
+// Config configures a server. It is read from JSON by LoadConfig; see the
+// field tags for the keys.
+type Config = config.Configuration
+
+// DefaultConfig returns the configuration a server runs with when no config
+// file is given
+func DefaultConfig() *Config {
+	return config.NewConfiguration()
+}
+
+// LoadConfig reads a JSON config file on top of the defaults. An empty path
+// returns the defaults unchanged.
+func LoadConfig(path string) (*Config, error) {
+	return config.LoadConfiguration(path)
+}
@@ -0,0 +1,56 @@
+package server_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"interview-task/server"
+)
+
+// loadConfig loads settings, as JSON, on top of the defaults
+func loadConfig(t *testing.T, settings string) (*server.Config, error) {
+	t.Helper()
+	file := filepath.Join(t.TempDir(), "config.json")
+	if err := os.WriteFile(file, []byte(settings), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return server.LoadConfig(file)
+}
+
+func TestHedgingReplicasRejected(t *testing.T) {
+	_, err := loadConfig(t, `{"hedging": {"replicas": {"file": ["database"]}}}`)
+	if err == nil || !strings.Contains(err.Error(), "hedging.replicas") {
+		t.Fatalf("loading hedging.replicas returned %v, want it rejected", err)
+	}
+
+	cfg, err := loadConfig(t, `{"hedging": {"enabled": true}}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !cfg.Hedging.Enabled || time.Duration(cfg.Hedging.Delay) != 50*time.Millisecond {
+		t.Fatalf("hedging loaded as %+v, want enabled with the default delay", cfg.Hedging)
+	}
+}
+
+func TestStatePathsResolvedAgainstDataDir(t *testing.T) {
+	dir := t.TempDir()
+	cfg, err := loadConfig(t, `{"data_dir": "`+dir+`", "watermark_file": "/var/lib/marks.json"}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, got := range map[string]string{
+		"audit_file":        cfg.AuditFile,
+		"quotas.usage_file": cfg.Quotas.UsageFile,
+		"spool.dir":         cfg.Spool.Dir,
+	} {
+		if filepath.Dir(got) != dir {
+			t.Errorf("%s is %q, want it in the data directory", name, got)
+		}
+	}
+	if cfg.WatermarkFile != "/var/lib/marks.json" {
+		t.Errorf("watermark_file is %q, want the absolute path kept", cfg.WatermarkFile)
+	}
+}
@@ -0,0 +1,84 @@
+package server
+
+import (
+	"context"
+
+	"interview-task/internal/api"
+	"interview-task/internal/service"
+	"interview-task/internal/storage"
+)
+
+// recentSaves is how many saves the dashboard lists
+const recentSaves = 20
+
+// storageStats is the dashboard's row for a storage type. Operations are
+// backend calls, each retry attempt and shard counted; failures are those
+// that weren't answers such as not_found.
+type storageStats struct {
+	StorageType string `json:"storage_type"`
+	Tenants     int    `json:"tenants"`
+	Operations  int64  `json:"operations"`
+	Failures    int64  `json:"failures"`
+}
+
+// dashboardPanels reads the admin dashboard from the server's components
+func (s *Server) dashboardPanels(quotas *service.QuotaManager, shedder *api.LoadShedder) map[string]api.StatusSection {
+	return map[string]api.StatusSection{
+		"health": func(ctx context.Context) (any, error) { return s.health(), nil },
+		"load":   func(ctx context.Context) (any, error) { return shedder.Stats(), nil },
+		"storage": func(ctx context.Context) (any, error) {
+			return s.storageStats()
+		},
+		"recent_saves": func(ctx context.Context) (any, error) {
+			return s.auditSink.Query(service.AuditQuery{Action: service.AuditActionSave, Limit: recentSaves})
+		},
+		"quotas": func(ctx context.Context) (any, error) { return quotas.Report(), nil },
+		"jobs": func(ctx context.Context) (any, error) {
+			return map[string]any{
+				"reindex":   s.reindexer.Status(),
+				"reshard":   s.resharder.Status(),
+				"migration": s.migrator.Status(),
+				"dedup_gc":  s.dedup.LastCollection(),
+			}, nil
+		},
+	}
+}
+
+func (s *Server) storageStats() ([]storageStats, error) {
+	stats := make(map[string]*storageStats)
+	var rows []storageStats
+	for _, storageType := range storage.StorageTypes() {
+		tenants, err := s.factory.Tenants(storageType)
+		if err != nil {
+			return nil, err
+		}
+		rows = append(rows, storageStats{StorageType: storageType, Tenants: len(tenants)})
+	}
+	for i := range rows {
+		stats[rows[i].StorageType] = &rows[i]
+	}
+	for _, sample := range s.metrics.Samples("storage_operations_total") {
+		row, ok := stats[sample.Labels["storage_type"]]
+		if !ok {
+			continue
+		}
+		row.Operations += sample.Value
+		if !storage.Answered(sample.Labels["outcome"]) {
+			row.Failures += sample.Value
+		}
+	}
+	return rows, nil
+}
+
+// health is the body of /health
+func (s *Server) health() map[string]any {
+	response := map[string]any{"status": "healthy"}
+	if status := s.maintenance.Status(); status.Enabled {
+		response["maintenance"] = status
+	}
+	if version, dirty, err := s.database.SchemaVersion(); err == nil {
+		response["database_schema_version"] = version
+		response["database_schema_dirty"] = dirty
+	}
+	return response
+}
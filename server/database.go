@@ -0,0 +1,22 @@
+package server
+
+import "interview-task/internal/storage"
+
+// MigrateDatabase applies the pending schema migrations to the configured
+// database and returns the schema version it is at. It lets deployments
+// migrate ahead of starting servers with database_auto_migrate off.
+func MigrateDatabase(config *Config) (int, error) {
+	database, err := storage.NewDatabaseConnection(
+		config.DatabaseHost,
+		config.DatabasePort,
+		config.DatabaseUser,
+		config.DatabasePass,
+		config.DatabaseName,
+		config.DatabasePool,
+	)
+	if err != nil {
+		return 0, err
+	}
+	defer database.Close()
+	return database.Migrate()
+}
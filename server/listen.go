@@ -0,0 +1,133 @@
+package server
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"os/user"
+	"strconv"
+
+	"interview-task/internal/config"
+)
+
+// systemdFirstFD is the first file descriptor systemd passes sockets on
+const systemdFirstFD = 3
+
+// listen opens the HTTP listener: the one given to New, the socket passed
+// by systemd, the Unix socket, or the TCP port, in that order
+func (s *Server) listen() (net.Listener, error) {
+	if s.listener != nil {
+		return s.listener, nil
+	}
+	if s.config.Listen.Systemd {
+		listener, err := systemdListener()
+		if err != nil || listener != nil {
+			return listener, err
+		}
+		s.logger.Println("No socket passed by systemd, listening on the configured address")
+	}
+	if s.config.Listen.UnixSocket != "" {
+		return listenUnix(s.config.Listen)
+	}
+	return net.Listen("tcp", ":"+s.config.Port)
+}
+
+// systemdListener returns the first socket passed by systemd socket
+// activation, or nil when the process wasn't started with one. The
+// environment is cleared so child processes don't take the socket too.
+func systemdListener() (net.Listener, error) {
+	defer func() {
+		os.Unsetenv("LISTEN_PID")
+		os.Unsetenv("LISTEN_FDS")
+		os.Unsetenv("LISTEN_FDNAMES")
+	}()
+	if pid, err := strconv.Atoi(os.Getenv("LISTEN_PID")); err != nil || pid != os.Getpid() {
+		return nil, nil
+	}
+	count, err := strconv.Atoi(os.Getenv("LISTEN_FDS"))
+	if err != nil || count < 1 {
+		return nil, nil
+	}
+	file := os.NewFile(systemdFirstFD, "systemd-socket")
+	defer file.Close()
+	listener, err := net.FileListener(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to use the socket passed by systemd: %w", err)
+	}
+	return listener, nil
+}
+
+// listenUnix listens on a Unix domain socket with the configured mode and
+// ownership. Closing the listener removes the socket.
+func listenUnix(config config.ListenConfig) (net.Listener, error) {
+	path := config.UnixSocket
+	// A socket left by a previous run would make the listen fail
+	if info, err := os.Lstat(path); err == nil {
+		if info.Mode()&os.ModeSocket == 0 {
+			return nil, fmt.Errorf("%s exists and is not a socket", path)
+		}
+		if err := os.Remove(path); err != nil {
+			return nil, fmt.Errorf("failed to remove stale socket: %w", err)
+		}
+	}
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	if err := configureSocket(path, config); err != nil {
+		listener.Close()
+		return nil, err
+	}
+	return listener, nil
+}
+
+func configureSocket(path string, config config.ListenConfig) error {
+	if config.SocketMode != "" {
+		mode, err := strconv.ParseUint(config.SocketMode, 8, 32)
+		if err != nil || mode > 0o777 {
+			return fmt.Errorf("invalid socket mode %q", config.SocketMode)
+		}
+		if err := os.Chmod(path, os.FileMode(mode)); err != nil {
+			return fmt.Errorf("failed to set socket mode: %w", err)
+		}
+	}
+	if config.SocketUser == "" && config.SocketGroup == "" {
+		return nil
+	}
+	// -1 leaves the user or group unchanged
+	uid, gid := -1, -1
+	var err error
+	if config.SocketUser != "" {
+		if uid, err = lookupID(config.SocketUser, false); err != nil {
+			return fmt.Errorf("socket user: %w", err)
+		}
+	}
+	if config.SocketGroup != "" {
+		if gid, err = lookupID(config.SocketGroup, true); err != nil {
+			return fmt.Errorf("socket group: %w", err)
+		}
+	}
+	if err := os.Chown(path, uid, gid); err != nil {
+		return fmt.Errorf("failed to set socket owner: %w", err)
+	}
+	return nil
+}
+
+// lookupID resolves a user, or a group, given by name or numeric ID
+func lookupID(nameOrID string, group bool) (int, error) {
+	if id, err := strconv.Atoi(nameOrID); err == nil {
+		return id, nil
+	}
+	if group {
+		g, err := user.LookupGroup(nameOrID)
+		if err != nil {
+			return -1, err
+		}
+		return strconv.Atoi(g.Gid)
+	}
+	u, err := user.Lookup(nameOrID)
+	if err != nil {
+		return -1, err
+	}
+	return strconv.Atoi(u.Uid)
+}
@@ -0,0 +1,173 @@
+package server
+
+import (
+	"log"
+	"net"
+
+	"interview-task/internal/api"
+	"interview-task/internal/config"
+	"interview-task/internal/metrics"
+	"interview-task/internal/service"
+	"interview-task/internal/storage"
+)
+
+// Middleware wraps a handler; see WithMiddleware
+type Middleware = api.Middleware
+
+// Storage and StorageFactory are what embedders implement to serve the data
+// service from storage of their own; see WithStorageFactory
+type (
+	Storage        = storage.StorageInterface
+	StorageFactory = storage.StorageFactory
+	// TenantLister is a StorageFactory that lists the tenants with data in
+	// a storage type, so background jobs can walk its items
+	TenantLister = storage.TenantLister
+)
+
+// ErrNotFound is what Storage implementations return from Load and Delete
+// when no item is stored under the id
+var ErrNotFound = storage.ErrNotFound
+
+// ErrUnauthorized is what Storage implementations wrap when their store
+// rejects the configured credentials, so failed calls are counted as auth
+// errors in storage_operations_total
+var ErrUnauthorized = storage.ErrUnauthorized
+
+// Backend, BackendEnv and BackendConstructor are what embedders implement
+// to add a storage type; see RegisterStorage
+type (
+	Backend            = storage.Backend
+	BackendEnv         = storage.BackendEnv
+	BackendConstructor = storage.BackendConstructor
+)
+
+// RegisterStorage adds a storage type served by the backends constructor
+// builds, alongside file, database and log. Servers created afterwards
+// offer it to clients and hand constructor the section under its name in
+// the storage_backends config. Registering a name again replaces it.
+func RegisterStorage(name string, constructor BackendConstructor) {
+	storage.Register(name, constructor)
+}
+
+// Decorator, DecoratorConfig, DecoratorFactory and Metrics are what
+// embedders implement to add a decorator type; see RegisterStorageDecorator
+type (
+	Decorator        = storage.Decorator
+	DecoratorConfig  = config.DecoratorConfig
+	DecoratorFactory = storage.DecoratorFactory
+	Metrics          = metrics.Metrics
+)
+
+// RegisterStorageDecorator makes a decorator type available to the
+// storage_decorators config, alongside metrics, retry, circuit_breaker,
+// encryption and compression. Registering a name again replaces it.
+func RegisterStorageDecorator(name string, factory DecoratorFactory) {
+	storage.RegisterDecorator(name, factory)
+}
+
+// KeyWrapper and KeyProviderFactory are what embedders implement to keep
+// encryption master keys elsewhere, such as in a KMS; see
+// RegisterKeyProvider
+type (
+	KeyWrapper         = storage.KeyWrapper
+	KeyProviderFactory = storage.KeyProviderFactory
+)
+
+// RegisterKeyProvider makes a master key scheme available to the
+// master_keys of encryption decorators, alongside env and file. Registering
+// a scheme again replaces it.
+func RegisterKeyProvider(scheme string, factory KeyProviderFactory) {
+	storage.RegisterKeyProvider(scheme, factory)
+}
+
+// ErrorReporter, ErrorEvent and ErrorReporterFactory are what embedders
+// implement to send server errors to a tracker of their own; see
+// RegisterErrorReporter
+type (
+	ErrorReporter        = api.ErrorReporter
+	ErrorEvent           = api.ErrorEvent
+	ErrorReporterFactory = api.ErrorReporterFactory
+)
+
+// RegisterErrorReporter makes a reporter available to the error_reporting
+// config, alongside sentry and http. Registering a name again replaces it.
+func RegisterErrorReporter(name string, factory ErrorReporterFactory) {
+	api.RegisterErrorReporter(name, factory)
+}
+
+// SaveHooks and SaveRequest are what embedders use to run logic of their
+// own around saves; see WithSaveHooks
+type (
+	SaveHooks      = service.Hooks
+	SaveRequest    = service.SaveRequest
+	BeforeSaveHook = service.BeforeSaveHook
+	AfterSaveHook  = service.AfterSaveHook
+	ErrorHook      = service.ErrorHook
+)
+
+// Option customizes a server built by New
+type Option func(*options)
+
+type options struct {
+	logger     *log.Logger
+	factory    storage.StorageFactory
+	middleware []api.Middleware
+	listener   net.Listener
+	hooks      service.Hooks
+}
+
+// WithLogger sets the logger the server and its components report to, such
+// as listeners starting and stopping and background job failures, instead
+// of the standard logger. Components share one logger per process, so the
+// last server created sets it.
+func WithLogger(logger *log.Logger) Option {
+	return func(o *options) { o.logger = logger }
+}
+
+// WithStorageFactory makes the data service save and read items through
+// factory rather than the configured backends, such as a fake in tests.
+// Expiry, trash purges, retention, erasure, garbage collection and index
+// rebuilds walk factory's items too, if it lists its tenants as a
+// TenantLister; otherwise they find none. Jobs that work on the configured
+// backends' layout (tiering, deduplication, replication, resharding,
+// migrations, backups and key rotation) have nothing of factory's to do.
+func WithStorageFactory(factory StorageFactory) Option {
+	return func(o *options) { o.factory = factory }
+}
+
+// listTenants lets background jobs walk factory, which lists no tenants
+// unless it is a TenantLister
+func listTenants(factory StorageFactory) TenantLister {
+	if lister, ok := factory.(TenantLister); ok {
+		return lister
+	}
+	return noTenants{factory}
+}
+
+// noTenants is a storage factory that can't list its tenants
+type noTenants struct{ StorageFactory }
+
+func (noTenants) Tenants(string) ([]string, error) { return nil, nil }
+
+// WithMiddleware adds middleware to every HTTP, gRPC and S3 request. It runs
+// after the built-in middleware, so requests are authenticated and carry
+// their tenant by then.
+func WithMiddleware(middleware ...Middleware) Option {
+	return func(o *options) { o.middleware = append(o.middleware, middleware...) }
+}
+
+// WithSaveHooks runs hooks around every save and update, after the hooks
+// of earlier WithSaveHooks options
+func WithSaveHooks(hooks SaveHooks) Option {
+	return func(o *options) {
+		o.hooks.BeforeSave = append(o.hooks.BeforeSave, hooks.BeforeSave...)
+		o.hooks.AfterSave = append(o.hooks.AfterSave, hooks.AfterSave...)
+		o.hooks.OnError = append(o.hooks.OnError, hooks.OnError...)
+	}
+}
+
+// WithListener serves the HTTP API on listener instead of the configured
+// port, such as one on a random port in tests. It is closed on shutdown.
+func WithListener(listener net.Listener) Option {
+	return func(o *options) { o.listener = listener }
+}
@@ -0,0 +1,755 @@
+// Package server assembles the data service from a Configuration: storage
+// backends, the data service itself, its HTTP, gRPC and S3 APIs, ingestion
+// from queues and the background jobs. New builds a server and Run serves
+// it until its context is cancelled.
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"interview-task/internal/api"
+	"interview-task/internal/config"
+	"interview-task/internal/logging"
+	"interview-task/internal/metrics"
+	"interview-task/internal/service"
+	"interview-task/internal/storage"
+)
+
+// Server - IMPLEMENTS Proper Server Structure
+type Server struct {
+	config           *Config
+	mux              *http.ServeMux
+	bulkheads        *api.Bulkheads
+	errorReports     *api.ErrorReports
+	captures         *api.BodyCapture
+	logger           *log.Logger
+	listener         net.Listener
+	handler          *api.HTTPHandler
+	idempotency      *api.IdempotencyStore
+	auditHandler     *api.AuditHandler
+	reindexHandler   *api.ReindexHandler
+	indexHandler     *api.IndexHandler
+	approvalHandler  *api.ApprovalHandler
+	quotaHandler     *api.QuotaHandler
+	watermarkHandler *api.WatermarkHandler
+	generatorHandler *api.GeneratorHandler
+	reshardHandler   *api.ReshardHandler
+	migrationHandler *api.MigrationHandler
+	importHandler    *api.ImportHandler
+	backupHandler    *api.BackupHandler
+	snapshotHandler  *api.SnapshotHandler
+	dedupHandler     *api.DedupHandler
+	gcHandler        *api.GCHandler
+	retentionHandler *api.RetentionHandler
+	erasureHandler   *api.ErasureHandler
+	reportHandler    *api.ClientReportHandler
+	trashHandler     *api.TrashHandler
+	legalHoldHandler *api.LegalHoldHandler
+	presignHandler   *api.PresignHandler
+	objectHandler    *api.ObjectHandler
+	graphqlHandler   *api.GraphQLHandler
+	eventHandler     *api.EventHandler
+	readyHandler     *api.ReadinessHandler
+	statsHandler     *api.StatsHandler
+	dashboardHandler *api.DashboardHandler
+	metrics          *metrics.Metrics
+	database         *storage.DatabaseConnection
+	auditSink        service.AuditSink
+	index            *service.ItemIndex
+	reindexer        *service.Reindexer
+	generator        *service.Generator
+	resharder        *service.Resharder
+	migrator         *service.Migrator
+	backups          *service.BackupManager
+	dedup            *storage.Deduplicator
+	reaper           *service.ExpiryReaper
+	purger           *service.TrashPurger
+	transitioner     *service.TierTransitioner
+	collector        *service.GarbageCollector
+	retention        *service.RetentionEnforcer
+	replayer         *service.SpoolReplayer
+	shadow           *storage.ShadowWriter
+	replicator       *storage.Replicator
+	drainer          *api.Drainer
+	batcher          *storage.WriteBatcher
+	factory          *storage.ConcreteStorageFactory
+	logs             *storage.LogBackend
+	grpc             *api.GRPCServer
+	s3               *api.S3Server
+	diagnostics      *api.DiagnosticsServer
+	events           *service.EventBus
+	webhooks         *service.WebhookDispatcher
+	queueConsumer    *api.NATSConsumer
+	mqttBridge       *api.MQTTBridge
+	middleware       []api.Middleware
+	// httpHandler is mux behind the middleware, as served
+	httpHandler http.Handler
+
+	maintenance        *service.Maintenance
+	maintenanceHandler *api.MaintenanceHandler
+
+	journal         *service.Journal
+	journalHandler  *api.JournalHandler
+	outbox          *service.Outbox
+	leader          *service.LeaderElector
+	quotas          *service.QuotaManager
+	meter           *service.Meter
+	usageHandler    *api.UsageHandler
+	keys            *service.KeyManager
+	keyHandler      *api.KeyHandler
+	rotator         *service.KeyRotator
+	rotationHandler *api.KeyRotationHandler
+	scanner         *service.ContentScanner
+}
+
+// New builds a server and every component it depends on. Nothing listens or
+// runs in the background until Run is called.
+func New(config *Config, opts ...Option) (*Server, error) {
+	o := options{logger: log.Default()}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	logging.SetLogger(o.logger)
+
+	// Initialize database connection ONCE at startup
+	database, err := storage.NewDatabaseConnection(
+		config.DatabaseHost,
+		config.DatabasePort,
+		config.DatabaseUser,
+		config.DatabasePass,
+		config.DatabaseName,
+		config.DatabasePool,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize database: %w", err)
+	}
+	if config.DatabaseAutoMigrate {
+		if _, err := database.Migrate(); err != nil {
+			return nil, fmt.Errorf("failed to migrate database schema: %w", err)
+		}
+	}
+
+	auditSink, err := service.NewAuditSink(config, database)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize audit sink: %w", err)
+	}
+	index, err := service.NewItemIndex(config.Index, database)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize metadata index: %w", err)
+	}
+
+	derivations, err := service.NewDerivationRegistryFromConfig(config.Derivations)
+	if err != nil {
+		return nil, fmt.Errorf("failed to configure derivations: %w", err)
+	}
+
+	extractor, err := service.NewMetadataExtractor(config.MetadataRules)
+	if err != nil {
+		return nil, fmt.Errorf("failed to configure metadata rules: %w", err)
+	}
+
+	quotas, err := service.NewQuotaManager(config.Quotas)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize quotas: %w", err)
+	}
+	meter, err := service.NewMeter(config.Metering, quotas)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize metering: %w", err)
+	}
+
+	watermarks, err := service.NewWatermarkTracker(config.WatermarkFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize watermarks: %w", err)
+	}
+	holds, err := service.NewTenantHolds(config.LegalHoldFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize legal holds: %w", err)
+	}
+
+	// Create dependencies using dependency injection
+	validators, err := service.NewValidatorsFromConfig(config.Validators, config.PayloadSchemas)
+	if err != nil {
+		return nil, fmt.Errorf("failed to configure validators: %w", err)
+	}
+	validator := service.NewRequestValidator(config.ContentTypePolicy, validators...)
+	shards, err := storage.NewShardRouter(config.ShardStateFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize shard router: %w", err)
+	}
+	if err := config.FileLayout.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid file layout: %w", err)
+	}
+	if err := config.Compression.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid compression settings: %w", err)
+	}
+	if err := config.Webhooks.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid webhook settings: %w", err)
+	}
+	if err := config.QueueIngest.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid queue ingest settings: %w", err)
+	}
+	if err := config.MQTT.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid MQTT settings: %w", err)
+	}
+	if err := config.S3.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid S3 settings: %w", err)
+	}
+	if err := config.APIVersions.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid API version settings: %w", err)
+	}
+	serverMetrics := metrics.NewMetrics()
+	database.ExportMetrics(serverMetrics)
+	dedup := storage.NewDeduplicator(config.Dedup)
+	logs := storage.NewLogBackend(config.LogStorage)
+	disk := storage.NewDiskChecker(config.DataDir, config.MinFreeDiskBytes)
+	batcher := storage.NewWriteBatcher(database, config.WriteBatching, serverMetrics)
+	cache := storage.NewReadCache(config.Cache, serverMetrics)
+	shadow := storage.NewShadowWriter(config.Shadow, serverMetrics)
+	hedger := storage.NewHedger(config.Hedging, serverMetrics)
+	replicator := storage.NewReplicator(config.Replication, serverMetrics)
+	tiers := storage.NewTiering(config.Tiering)
+	factory, err := storage.NewStorageFactory(config.DataDir,
+		storage.WithFileLayout(config.FileLayout),
+		storage.WithLogBackend(logs),
+		storage.WithDiskChecker(disk),
+		storage.WithDatabase(database, batcher),
+		storage.WithShardRouter(shards),
+		storage.WithDeduplicator(dedup),
+		storage.WithReadCache(cache),
+		storage.WithShadowWriter(shadow),
+		storage.WithHedger(hedger),
+		storage.WithReplicator(replicator),
+		storage.WithTiering(tiers),
+		storage.WithBackendSettings(config.StorageBackends),
+		storage.WithDecorators(config.StorageDecorators, serverMetrics),
+		storage.WithFaultInjection(config.AllowFaultInjection),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("invalid storage settings: %w", err)
+	}
+	var dataFactory storage.StorageFactory = factory
+	if o.factory != nil {
+		dataFactory = o.factory
+	}
+	spool, err := service.NewSpool(config.Spool)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize spool: %w", err)
+	}
+	events := service.NewEventBus(config.Events, serverMetrics)
+	maintenance := service.NewMaintenance(config.Maintenance)
+	pii, err := service.NewPIIScanner(config.PII)
+	if err != nil {
+		return nil, fmt.Errorf("failed to configure pii scanning: %w", err)
+	}
+	database.RedactLogs(pii.Redact)
+	// Personal data is handled before any other hook sees the payload
+	o.hooks.BeforeSave = append(pii.Hooks().BeforeSave, o.hooks.BeforeSave...)
+	scanner, err := service.NewContentScanner(config.Scanning, serverMetrics)
+	if err != nil {
+		return nil, fmt.Errorf("failed to configure scanning: %w", err)
+	}
+	// Payloads are scanned as they are stored, after every other hook
+	// changed them
+	scanHooks := scanner.Hooks()
+	o.hooks.BeforeSave = append(o.hooks.BeforeSave, scanHooks.BeforeSave...)
+	o.hooks.AfterSave = append(o.hooks.AfterSave, scanHooks.AfterSave...)
+	journal, err := service.NewJournal(config.JournalFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize journal: %w", err)
+	}
+	journal.Listen(events)
+	// The journal records a save's outcome after every other hook has run
+	journalHooks := journal.Hooks()
+	o.hooks.AfterSave = append(o.hooks.AfterSave, journalHooks.AfterSave...)
+	o.hooks.OnError = append(o.hooks.OnError, journalHooks.OnError...)
+	leader, err := service.NewLeaderElector(config.LeaderElection, database, serverMetrics)
+	if err != nil {
+		return nil, fmt.Errorf("failed to configure leader election: %w", err)
+	}
+	outbox := service.NewOutbox(config.Outbox, database, events, serverMetrics)
+	locks, err := service.NewItemLocks(config.Locking, database)
+	if err != nil {
+		return nil, fmt.Errorf("failed to configure locking: %w", err)
+	}
+	fields, err := service.NewFieldEncryptor(config.FieldEncryption)
+	if err != nil {
+		return nil, fmt.Errorf("failed to configure field encryption: %w", err)
+	}
+	access, err := service.NewStorageAccess(config.StorageAccess)
+	if err != nil {
+		return nil, fmt.Errorf("failed to configure storage access: %w", err)
+	}
+	budgets, err := service.NewBudgets(config.Timeouts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to configure timeouts: %w", err)
+	}
+	dataService := service.NewDataService(service.Deps{
+		Factory:     dataFactory,
+		Validator:   validator,
+		Audit:       auditSink,
+		Derivations: derivations,
+		Extractor:   extractor,
+		Quotas:      quotas,
+		Watermarks:  watermarks,
+		Expiry:      config.Expiry,
+		SoftDelete:  config.SoftDelete,
+		Spool:       spool,
+		Events:      events,
+		Hooks:       o.hooks,
+		Maintenance: maintenance,
+		Outbox:      outbox,
+		Locks:       locks,
+		Fields:      fields,
+		Access:      access,
+		Budgets:     budgets,
+		Index:       index,
+		Holds:       holds,
+	})
+	scanner.Attach(dataService)
+	ingester := api.NewIngester(dataService, serverMetrics)
+	// Background jobs walk the items of the factory the data service uses
+	jobFactory := listTenants(dataFactory)
+	reaper := service.NewExpiryReaper(dataService, jobFactory, time.Duration(config.Expiry.ReapInterval), serverMetrics, leader)
+	purger := service.NewTrashPurger(dataService, jobFactory, time.Duration(config.SoftDelete.PurgeInterval), serverMetrics, leader)
+	transitioner := service.NewTierTransitioner(dataService, jobFactory, config.Tiering, serverMetrics, leader)
+	collector := service.NewGarbageCollector(dataService, jobFactory, dedup, config.GC, serverMetrics, leader)
+	retention, err := service.NewRetentionEnforcer(dataService, jobFactory, config.Retention, serverMetrics, leader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to configure retention: %w", err)
+	}
+	eraser, err := service.NewSubjectEraser(dataService, jobFactory, config.Erasure)
+	if err != nil {
+		return nil, fmt.Errorf("failed to configure erasure: %w", err)
+	}
+	replayer := service.NewSpoolReplayer(spool, dataService, dataFactory, serverMetrics)
+	shedder := api.NewLoadShedder(config.LoadShedding, serverMetrics)
+	bulkheads, err := api.NewBulkheads(config.LoadShedding, serverMetrics)
+	if err != nil {
+		return nil, fmt.Errorf("failed to configure bulkheads: %w", err)
+	}
+	errorReports, err := api.NewErrorReports(config.ErrorReporting, serverMetrics)
+	if err != nil {
+		return nil, fmt.Errorf("failed to configure error reporting: %w", err)
+	}
+	// Captured bodies are masked whether or not saves are scanned
+	capturePII, err := service.NewPIIScanner(config.PII.MaskingConfig())
+	if err != nil {
+		return nil, fmt.Errorf("failed to configure pii scanning: %w", err)
+	}
+	captures, err := api.NewBodyCapture(config.BodyCapture, capturePII)
+	if err != nil {
+		return nil, fmt.Errorf("failed to configure body capture: %w", err)
+	}
+	drainer := api.NewDrainer(config.Drain)
+	handler := api.NewHTTPHandler(dataService)
+	reindexer := service.NewReindexer(dataService, config.ReindexRatePerSecond, config.ReindexStateFile)
+
+	generator, err := service.NewGenerator(dataService, config.Generator)
+	if err != nil {
+		return nil, fmt.Errorf("failed to configure generator: %w", err)
+	}
+
+	resharder := service.NewResharder(factory, shards)
+	migrator := service.NewMigrator(factory)
+	backups, err := service.NewBackupManager(factory, config.Backup, serverMetrics, leader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to configure backups: %w", err)
+	}
+
+	approvals := service.NewApprovalManager(time.Duration(config.ApprovalTTL), auditSink)
+	approvals.Register("bulk_delete", service.BulkDeleteOperation(dataService))
+	approvals.Register("delete_tenant", service.DeleteTenantOperation(dataService))
+	approvals.Register("purge_trash", service.PurgeTrashOperation(dataService))
+
+	ipFilter, err := api.NewIPFilter(config.AllowedCIDRs, config.DeniedCIDRs, config.TrustedProxies, config.ClientIPHeader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to configure IP filter: %w", err)
+	}
+	keys, err := service.NewKeyManager(config.KeyManagement, database, quotas, config.APIKeys)
+	if err != nil {
+		return nil, fmt.Errorf("failed to configure key management: %w", err)
+	}
+	presigner, err := api.NewPresigner(config.Presign)
+	if err != nil {
+		return nil, fmt.Errorf("failed to configure pre-signed URLs: %w", err)
+	}
+	authenticator, err := api.NewAuthenticator(config.APIKeys, keys, presigner)
+	if err != nil {
+		return nil, fmt.Errorf("failed to configure authentication: %w", err)
+	}
+	if config.Objects.Enabled && config.Objects.Public {
+		authenticator.ServeObjectsPublicly()
+	}
+	tenantResolver := api.NewTenantResolver(config.TenantHeader, config.RequireTenant)
+	rotator := service.NewKeyRotator(factory, dataService)
+
+	s := &Server{
+		config:           config,
+		mux:              http.NewServeMux(),
+		bulkheads:        bulkheads,
+		errorReports:     errorReports,
+		captures:         captures,
+		logger:           o.logger,
+		listener:         o.listener,
+		handler:          handler,
+		idempotency:      api.NewIdempotencyStore(time.Duration(config.IdempotencyWindow)),
+		auditHandler:     api.NewAuditHandler(auditSink),
+		reindexHandler:   api.NewReindexHandler(reindexer),
+		indexHandler:     api.NewIndexHandler(service.NewIndexRebuilder(dataService, jobFactory), dataService),
+		approvalHandler:  api.NewApprovalHandler(approvals),
+		quotaHandler:     api.NewQuotaHandler(quotas),
+		watermarkHandler: api.NewWatermarkHandler(watermarks),
+		generatorHandler: api.NewGeneratorHandler(generator),
+		reshardHandler:   api.NewReshardHandler(resharder),
+		migrationHandler: api.NewMigrationHandler(migrator),
+		importHandler:    api.NewImportHandler(dataService, config.ImportConcurrency),
+		backupHandler:    api.NewBackupHandler(backups),
+		snapshotHandler:  api.NewSnapshotHandler(service.NewSnapshotManager(backups, factory, index, maintenance, config.Snapshots)),
+		dedupHandler:     api.NewDedupHandler(dedup, factory),
+		gcHandler:        api.NewGCHandler(collector),
+		retentionHandler: api.NewRetentionHandler(retention),
+		erasureHandler:   api.NewErasureHandler(eraser),
+		reportHandler:    api.NewClientReportHandler(api.NewClientReportCollector(auditSink, config.ClientReportRetention)),
+		trashHandler:     api.NewTrashHandler(dataService),
+		legalHoldHandler: api.NewLegalHoldHandler(dataService, holds),
+		presignHandler:   api.NewPresignHandler(presigner, dataService, config.Presign),
+		objectHandler:    api.NewObjectHandler(dataService, config.Objects),
+		graphqlHandler:   api.NewGraphQLHandler(dataService),
+		eventHandler:     api.NewEventHandler(events),
+		readyHandler:     api.NewReadinessHandler(disk, drainer),
+		metrics:          serverMetrics,
+		database:         database,
+		auditSink:        auditSink,
+		index:            index,
+		reindexer:        reindexer,
+		generator:        generator,
+		resharder:        resharder,
+		migrator:         migrator,
+		backups:          backups,
+		dedup:            dedup,
+		reaper:           reaper,
+		purger:           purger,
+		transitioner:     transitioner,
+		collector:        collector,
+		retention:        retention,
+		replayer:         replayer,
+		shadow:           shadow,
+		replicator:       replicator,
+		drainer:          drainer,
+		batcher:          batcher,
+		factory:          factory,
+		logs:             logs,
+		grpc:             api.NewGRPCServer(dataService, config.GRPC, serverMetrics),
+		s3:               api.NewS3Server(dataService, config.S3),
+		diagnostics:      api.NewDiagnosticsServer(config.Diagnostics),
+		events:           events,
+		webhooks:         service.NewWebhookDispatcher(config.Webhooks, events, serverMetrics),
+		queueConsumer:    api.NewNATSConsumer(config.QueueIngest, config.TenantHeader, ingester),
+		mqttBridge:       api.NewMQTTBridge(config.MQTT, ingester),
+		quotas:           quotas,
+		middleware:       append([]api.Middleware{api.CountRequests(serverMetrics), api.RequestIDMiddleware, api.NewCompression(config.Compression).Middleware, shedder.Middleware, ipFilter.Middleware, authenticator.Middleware, api.NewRateLimiter(serverMetrics, config.AnonymousRateLimit, config.AnonymousBurst).Middleware, tenantResolver.Middleware, api.MeterUsage(meter), api.SlowLog(config.SlowLog), captures.Middleware, errorReports.Middleware}, o.middleware...),
+
+		maintenance:        maintenance,
+		maintenanceHandler: api.NewMaintenanceHandler(maintenance),
+		journal:            journal,
+		journalHandler:     api.NewJournalHandler(journal, dataService),
+		outbox:             outbox,
+		leader:             leader,
+		meter:              meter,
+		usageHandler:       api.NewUsageHandler(meter),
+		keys:               keys,
+		keyHandler:         api.NewKeyHandler(keys),
+		rotator:            rotator,
+		rotationHandler:    api.NewKeyRotationHandler(rotator),
+		scanner:            scanner,
+	}
+	s.statsHandler = api.NewStatsHandler(s.statsSections(quotas, shedder, disk))
+	s.dashboardHandler = api.NewDashboardHandler(s.dashboardPanels(quotas, shedder))
+	s.routes()
+	if unmatched := bulkheads.Unmatched(); len(unmatched) > 0 {
+		return nil, fmt.Errorf("bulkheads configured for unknown routes: %s", strings.Join(unmatched, ", "))
+	}
+	return s, nil
+}
+
+// Handler returns the HTTP API with its middleware, such as for
+// httptest.NewServer. Requests are served without Run; the background jobs
+// and the gRPC and S3 listeners only run under it.
+func (s *Server) Handler() http.Handler {
+	return s.httpHandler
+}
+
+// Run serves the APIs and runs the background jobs until ctx is cancelled or
+// the HTTP listener fails, then shuts the server down
+func (s *Server) Run(ctx context.Context) error {
+	s.start()
+	httpServer := &http.Server{Handler: s.httpHandler}
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- s.serve(httpServer)
+	}()
+
+	var err error
+	select {
+	case err = <-serveErr:
+	case <-ctx.Done():
+	case <-s.drainer.Done():
+		s.logger.Println("Drain delay passed")
+	}
+	return errors.Join(err, s.shutdown(httpServer))
+}
+
+// serve accepts HTTP connections on the listener given to New, or on the
+// configured address without one
+func (s *Server) serve(httpServer *http.Server) error {
+	listener, err := s.listen()
+	if err != nil {
+		return err
+	}
+	s.logger.Printf("Server starting on %s", listener.Addr())
+	return httpServer.Serve(listener)
+}
+
+// routes registers the HTTP API on the server's own mux
+func (s *Server) routes() {
+	// Every route is served under /v1 and, until it is sunset, at its
+	// legacy unprefixed path
+	v1 := api.NewAPIVersion(s.mux, config.APIVersionV1, s.config.APIVersions[config.APIVersionV1], nil, s.bulkheads)
+	legacy := api.NewAPIVersion(s.mux, config.APIVersionLegacy, s.config.APIVersions[config.APIVersionLegacy], v1, s.bulkheads)
+	for _, version := range []*api.APIVersion{v1, legacy} {
+		version.HandleFunc("/save-data", s.idempotency.Wrap(s.handler.HandleSaveData))
+		version.HandleFunc("POST /save-data/batch", s.idempotency.Wrap(s.handler.HandleSaveBatch))
+		version.HandleFunc("POST /import", s.importHandler.HandleImport)
+		version.HandleFunc("GET /export", s.handler.HandleExport)
+		version.HandleFunc("GET /data", s.handler.HandleListData)
+		version.HandleFunc("GET /search", s.handler.HandleSearch)
+		version.HandleFunc("GET /data/{id}", s.handler.HandleGetData)
+		version.HandleFunc("GET /graphql", s.graphqlHandler.HandleQuery)
+		version.HandleFunc("POST /graphql", s.graphqlHandler.HandleQuery)
+		version.HandleFunc("GET /graphql/schema", s.graphqlHandler.HandleSchema)
+		version.HandleFunc("GET /events", s.eventHandler.HandleStream)
+		version.HandleFunc("PUT /data/{id}", s.idempotency.Wrap(s.handler.HandleUpdateData))
+		version.HandleFunc("DELETE /data/{id}", s.handler.HandleDeleteData)
+		version.HandleFunc("PUT /data/{id}/content", s.presignHandler.HandleUpload)
+		version.HandleFunc("POST /presign", s.presignHandler.HandlePresign)
+		version.HandleFunc("GET /data/{id}/derived/{name}", s.handler.HandleGetDerived)
+		version.HandleFunc("POST /data/{id}/verify", s.handler.HandleVerify)
+		version.HandleFunc("GET /watermarks", s.watermarkHandler.HandleGet)
+		version.HandleFunc("GET /usage", s.usageHandler.HandleUsage)
+		version.HandleFunc("POST /erasure", api.RequireRole(service.RoleAdmin, s.erasureHandler.HandleErase))
+		version.HandleFunc("GET /erasure/key", api.RequireRole(service.RoleAdmin, s.erasureHandler.HandleKey))
+		version.HandleFunc("POST /client-reports", s.reportHandler.HandleReport)
+		version.HandleFunc("GET /admin/client-reports", api.RequireRole(service.RoleAdmin, s.reportHandler.HandleSummary))
+		version.HandleFunc("GET /admin/captures", api.RequireRole(service.RoleAdmin, s.captures.HandleList))
+		version.HandleFunc("DELETE /admin/captures", api.RequireRole(service.RoleAdmin, s.captures.HandleClear))
+		version.HandleFunc("GET /audit", api.RequireRole(service.RoleAuditor, s.auditHandler.HandleQuery))
+		version.HandleFunc("POST /admin/reindex", api.RequireRole(service.RoleAdmin, s.reindexHandler.HandleStart))
+		version.HandleFunc("GET /admin/reindex", api.RequireRole(service.RoleAdmin, s.reindexHandler.HandleStatus))
+		version.HandleFunc("DELETE /admin/reindex", api.RequireRole(service.RoleAdmin, s.reindexHandler.HandleCancel))
+		version.HandleFunc("POST /admin/operations", api.RequireRole(service.RoleAdmin, s.approvalHandler.HandleRequest))
+		version.HandleFunc("GET /admin/operations", api.RequireRole(service.RoleAdmin, s.approvalHandler.HandleList))
+		version.HandleFunc("POST /admin/operations/{id}/approve", api.RequireRole(service.RoleAdmin, s.approvalHandler.HandleApprove))
+		version.HandleFunc("POST /admin/operations/{id}/reject", api.RequireRole(service.RoleAdmin, s.approvalHandler.HandleReject))
+		version.HandleFunc("GET /admin/usage", api.RequireRole(service.RoleAdmin, s.quotaHandler.HandleUsage))
+		version.HandleFunc("POST /admin/usage/recount", api.RequireRole(service.RoleAdmin, s.indexHandler.HandleRecount))
+		version.HandleFunc("POST /admin/index/rebuild", api.RequireRole(service.RoleAdmin, s.indexHandler.HandleRebuild))
+		version.HandleFunc("GET /admin/keys", api.RequireRole(service.RoleAdmin, s.keyHandler.HandleList))
+		version.HandleFunc("POST /admin/keys", api.RequireRole(service.RoleAdmin, s.keyHandler.HandleCreate))
+		version.HandleFunc("GET /admin/keys/{name}", api.RequireRole(service.RoleAdmin, s.keyHandler.HandleGet))
+		version.HandleFunc("PUT /admin/keys/{name}", api.RequireRole(service.RoleAdmin, s.keyHandler.HandleUpdate))
+		version.HandleFunc("DELETE /admin/keys/{name}", api.RequireRole(service.RoleAdmin, s.keyHandler.HandleDelete))
+		version.HandleFunc("POST /admin/encryption/rotate", api.RequireRole(service.RoleAdmin, s.rotationHandler.HandleStart))
+		version.HandleFunc("GET /admin/encryption/rotate", api.RequireRole(service.RoleAdmin, s.rotationHandler.HandleStatus))
+		version.HandleFunc("DELETE /admin/encryption/rotate", api.RequireRole(service.RoleAdmin, s.rotationHandler.HandleCancel))
+		version.HandleFunc("POST /admin/generate", api.RequireRole(service.RoleAdmin, s.generatorHandler.HandleStart))
+		version.HandleFunc("GET /admin/generate/{batch}", api.RequireRole(service.RoleAdmin, s.generatorHandler.HandleStatus))
+		version.HandleFunc("DELETE /admin/generate/{batch}", api.RequireRole(service.RoleAdmin, s.generatorHandler.HandlePurge))
+		version.HandleFunc("POST /admin/reshard", api.RequireRole(service.RoleAdmin, s.reshardHandler.HandleStart))
+		version.HandleFunc("GET /admin/reshard", api.RequireRole(service.RoleAdmin, s.reshardHandler.HandleStatus))
+		version.HandleFunc("DELETE /admin/reshard", api.RequireRole(service.RoleAdmin, s.reshardHandler.HandleAbort))
+		version.HandleFunc("POST /admin/migrate", api.RequireRole(service.RoleAdmin, s.migrationHandler.HandleStart))
+		version.HandleFunc("GET /admin/migrate", api.RequireRole(service.RoleAdmin, s.migrationHandler.HandleStatus))
+		version.HandleFunc("DELETE /admin/migrate", api.RequireRole(service.RoleAdmin, s.migrationHandler.HandleCancel))
+		version.HandleFunc("GET /admin/maintenance", api.RequireRole(service.RoleAdmin, s.maintenanceHandler.HandleStatus))
+		version.HandleFunc("PUT /admin/maintenance", api.RequireRole(service.RoleAdmin, s.maintenanceHandler.HandleSet))
+		version.HandleFunc("POST /admin/drain", api.RequireRole(service.RoleAdmin, s.drainer.HandleDrain))
+		version.HandleFunc("GET /admin/drain", api.RequireRole(service.RoleAdmin, s.drainer.HandleStatus))
+		version.HandleFunc("POST /admin/journal/replay", api.RequireRole(service.RoleAdmin, s.journalHandler.HandleReplay))
+		version.HandleFunc("GET /admin/backup", api.RequireRole(service.RoleAdmin, s.backupHandler.HandleBackup))
+		version.HandleFunc("POST /admin/restore", api.RequireRole(service.RoleAdmin, s.backupHandler.HandleRestore))
+		version.HandleFunc("GET /admin/snapshots", api.RequireRole(service.RoleAdmin, s.snapshotHandler.HandleList))
+		version.HandleFunc("POST /admin/snapshots", api.RequireRole(service.RoleAdmin, s.snapshotHandler.HandleCreate))
+		version.HandleFunc("DELETE /admin/snapshots/{name}", api.RequireRole(service.RoleAdmin, s.snapshotHandler.HandleDelete))
+		version.HandleFunc("POST /admin/snapshots/{name}/restore", api.RequireRole(service.RoleAdmin, s.snapshotHandler.HandleRestore))
+		version.HandleFunc("POST /admin/dedup/gc", api.RequireRole(service.RoleAdmin, s.dedupHandler.HandleCollect))
+		version.HandleFunc("GET /admin/dedup/gc", api.RequireRole(service.RoleAdmin, s.dedupHandler.HandleStatus))
+		version.HandleFunc("POST /admin/gc", api.RequireRole(service.RoleAdmin, s.gcHandler.HandleStart))
+		version.HandleFunc("GET /admin/gc", api.RequireRole(service.RoleAdmin, s.gcHandler.HandleReport))
+		version.HandleFunc("DELETE /admin/gc", api.RequireRole(service.RoleAdmin, s.gcHandler.HandleCancel))
+		version.HandleFunc("POST /admin/retention", api.RequireRole(service.RoleAdmin, s.retentionHandler.HandleStart))
+		version.HandleFunc("GET /admin/retention", api.RequireRole(service.RoleAdmin, s.retentionHandler.HandleReport))
+		version.HandleFunc("DELETE /admin/retention", api.RequireRole(service.RoleAdmin, s.retentionHandler.HandleCancel))
+		version.HandleFunc("GET /admin/trash", api.RequireRole(service.RoleAdmin, s.trashHandler.HandleList))
+		version.HandleFunc("POST /admin/trash/{id}/restore", api.RequireRole(service.RoleAdmin, s.trashHandler.HandleRestore))
+		version.HandleFunc("GET /admin/legal-holds", api.RequireRole(service.RoleAdmin, s.legalHoldHandler.HandleList))
+		version.HandleFunc("GET /admin/legal-holds/tenant", api.RequireRole(service.RoleAdmin, s.legalHoldHandler.HandleTenantStatus))
+		version.HandleFunc("PUT /admin/legal-holds/tenant", api.RequireRole(service.RoleAdmin, s.legalHoldHandler.HandlePlaceTenant))
+		version.HandleFunc("DELETE /admin/legal-holds/tenant", api.RequireRole(service.RoleAdmin, s.legalHoldHandler.HandleLiftTenant))
+		version.HandleFunc("PUT /admin/legal-holds/items/{id}", api.RequireRole(service.RoleAdmin, s.legalHoldHandler.HandlePlaceItem))
+		version.HandleFunc("DELETE /admin/legal-holds/items/{id}", api.RequireRole(service.RoleAdmin, s.legalHoldHandler.HandleLiftItem))
+		version.HandleFunc("GET /stats", api.RequireRole(service.RoleAdmin, s.statsHandler.HandleStats))
+		version.HandleFunc("GET /admin", func(w http.ResponseWriter, r *http.Request) {
+			http.Redirect(w, r, "admin/", http.StatusMovedPermanently)
+		})
+		version.HandleFunc("GET /admin/{$}", api.RequireRole(service.RoleAdmin, s.dashboardHandler.HandlePage))
+		version.HandleFunc("GET /admin/dashboard", api.RequireRole(service.RoleAdmin, s.dashboardHandler.HandleData))
+	}
+
+	// Add health check endpoint
+	s.mux.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(s.health())
+	})
+	s.mux.HandleFunc("GET /readyz", s.readyHandler.HandleReady)
+	// Objects keep plain URLs for CDNs to pull from
+	if s.config.Objects.Enabled {
+		s.mux.HandleFunc("GET /objects/{id}", s.objectHandler.HandleGet)
+	}
+	// Profiles on the API need an admin key; on their own port they rely
+	// on it being unreachable from outside
+	if s.config.Diagnostics.Enabled && s.config.Diagnostics.Port == "" {
+		s.mux.Handle("/debug/", api.RequireRole(service.RoleAdmin, s.diagnostics.ServeHTTP))
+	}
+	// Probes and scrapes stay unversioned
+	s.mux.HandleFunc("GET /metrics", api.RequireRole(service.RoleAdmin, s.metrics.HandleMetrics))
+	s.httpHandler = api.ChainMiddleware(s.mux, s.middleware...)
+}
+
+// start starts the background jobs and the gRPC and S3 listeners
+func (s *Server) start() {
+	s.dedup.Start(s.factory)
+	s.keys.Start()
+	s.leader.Start()
+	s.reaper.Start()
+	s.purger.Start()
+	s.transitioner.Start()
+	s.collector.Start()
+	s.retention.Start()
+	s.backups.Start()
+	s.replayer.Start()
+	s.shadow.Start()
+	s.replicator.Start()
+	s.webhooks.Start()
+	s.outbox.Start()
+	s.quotas.Start()
+	s.meter.Start()
+	s.scanner.Start()
+	s.queueConsumer.Start()
+	s.mqttBridge.Start()
+
+	if s.config.GRPC.Port != "" {
+		go func() {
+			if err := s.grpc.Serve(api.ChainMiddleware(s.grpc, s.middleware...)); err != nil {
+				s.logger.Printf("gRPC server stopped: %v", err)
+			}
+		}()
+	}
+	if s.config.S3.Port != "" {
+		go func() {
+			if err := s.s3.Serve(api.ChainMiddleware(s.s3, s.middleware...)); err != nil {
+				s.logger.Printf("S3 API stopped: %v", err)
+			}
+		}()
+	}
+	if s.config.Diagnostics.Port != "" {
+		go func() {
+			if err := s.diagnostics.Serve(); err != nil {
+				s.logger.Printf("Diagnostics stopped: %v", err)
+			}
+		}()
+	}
+}
+
+// shutdown stops accepting requests, waits for those in flight and stops
+// every component
+func (s *Server) shutdown(httpServer *http.Server) error {
+	s.logger.Println("Shutting down server...")
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	// Event streams only end when the bus closes
+	s.events.Close()
+	if err := httpServer.Shutdown(ctx); err != nil {
+		s.logger.Printf("Error stopping HTTP server: %v", err)
+	}
+	if err := s.grpc.Shutdown(ctx); err != nil {
+		s.logger.Printf("Error stopping gRPC server: %v", err)
+	}
+	if err := s.s3.Shutdown(ctx); err != nil {
+		s.logger.Printf("Error stopping S3 API: %v", err)
+	}
+	if err := s.diagnostics.Shutdown(ctx); err != nil {
+		s.logger.Printf("Error stopping diagnostics: %v", err)
+	}
+	s.queueConsumer.Shutdown()
+	s.mqttBridge.Shutdown()
+	s.errorReports.Shutdown()
+	// Requests have stopped, so the last flush holds all of them
+	s.meter.Shutdown()
+	s.scanner.Shutdown()
+	// Staged events are published while webhooks still deliver them
+	s.outbox.Shutdown()
+	if s.drainer.Draining() {
+		// A planned shutdown delivers queued webhooks rather than
+		// dead-lettering them
+		drainCtx, cancel := context.WithTimeout(context.Background(), time.Duration(s.config.Drain.Timeout))
+		if err := s.webhooks.Drain(drainCtx); err != nil {
+			s.logger.Printf("Error draining webhooks: %v", err)
+		}
+		cancel()
+	}
+	// Cancelling leaves a checkpoint the next run resumes from
+	s.reindexer.Cancel()
+	s.generator.Shutdown()
+	// An unfinished migration keeps dual reads on until it is resumed
+	s.resharder.Shutdown()
+	s.migrator.Shutdown()
+	s.rotator.Shutdown()
+	s.backups.Shutdown()
+	s.dedup.Shutdown()
+	s.reaper.Shutdown()
+	s.purger.Shutdown()
+	s.transitioner.Shutdown()
+	s.collector.Shutdown()
+	s.retention.Shutdown()
+	// The jobs have stopped, so another instance can take over
+	s.leader.Shutdown()
+	s.replayer.Shutdown()
+	s.webhooks.Shutdown()
+	s.keys.Shutdown()
+	s.shadow.Shutdown()
+	s.replicator.Shutdown()
+	// Requests and jobs have stopped, so no usage changes after this flush
+	s.quotas.Shutdown()
+	s.batcher.Flush()
+	if err := s.logs.Close(); err != nil {
+		s.logger.Printf("Error closing log storage: %v", err)
+	}
+	if err := s.auditSink.Close(); err != nil {
+		s.logger.Printf("Error closing audit sink: %v", err)
+	}
+	if err := s.index.Close(); err != nil {
+		s.logger.Printf("Error closing metadata index: %v", err)
+	}
+	if err := s.journal.Close(); err != nil {
+		s.logger.Printf("Error closing journal: %v", err)
+	}
+	return s.database.Close()
+}
@@ -0,0 +1,160 @@
+package server_test
+
+import (
+	"encoding/json"
+	"io"
+	"maps"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"interview-task/server"
+)
+
+// newTestServer serves a server whose files all live in a temporary
+// directory, configured with settings on top of the defaults
+func newTestServer(t *testing.T, settings map[string]any) *httptest.Server {
+	t.Helper()
+	dir := t.TempDir()
+	file := filepath.Join(dir, "config.json")
+	config := map[string]any{"data_dir": dir}
+	maps.Copy(config, settings)
+	data, err := json.Marshal(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(file, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := server.LoadConfig(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := server.New(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts := httptest.NewServer(s.Handler())
+	t.Cleanup(ts.Close)
+	return ts
+}
+
+// call sends a request and returns the response with its body read
+func call(t *testing.T, method, url, body string, header http.Header) (*http.Response, string) {
+	t.Helper()
+	req, err := http.NewRequest(method, url, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp, string(data)
+}
+
+// save stores payload, base64 encoded, through path and returns its id
+func save(t *testing.T, ts *httptest.Server, path, payload string) (id, etag string) {
+	t.Helper()
+	resp, body := call(t, http.MethodPost, ts.URL+path, `{"data":"`+payload+`","storage_type":"file"}`, nil)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("save through %s returned %d: %s", path, resp.StatusCode, body)
+	}
+	var saved struct {
+		ID string `json:"id"`
+	}
+	if err := json.Unmarshal([]byte(body), &saved); err != nil || saved.ID == "" {
+		t.Fatalf("save through %s returned no id: %s", path, body)
+	}
+	return saved.ID, resp.Header.Get("ETag")
+}
+
+func TestDataLifecycle(t *testing.T) {
+	ts := newTestServer(t, nil)
+	item := ts.URL + "/v1/data/"
+
+	// "hello" and "world", base64 encoded
+	id, etag := save(t, ts, "/v1/save-data", "aGVsbG8=")
+	if etag == "" {
+		t.Fatal("save returned no ETag")
+	}
+
+	resp, body := call(t, http.MethodGet, item+id+"?storage_type=file", "", nil)
+	if resp.StatusCode != http.StatusOK || body != "hello" {
+		t.Fatalf("get returned %d %q, want hello", resp.StatusCode, body)
+	}
+
+	update := `{"data":"d29ybGQ=","storage_type":"file"}`
+	resp, body = call(t, http.MethodPut, item+id, update, http.Header{"If-Match": {`"stale"`}})
+	if resp.StatusCode != http.StatusPreconditionFailed {
+		t.Fatalf("update with a stale If-Match returned %d: %s", resp.StatusCode, body)
+	}
+	resp, body = call(t, http.MethodPut, item+id, update, http.Header{"If-Match": {etag}})
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("update returned %d: %s", resp.StatusCode, body)
+	}
+	updated := resp.Header.Get("ETag")
+	if updated == "" || updated == etag {
+		t.Fatalf("update returned ETag %q after %q", updated, etag)
+	}
+
+	resp, body = call(t, http.MethodGet, item+id+"?storage_type=file", "", nil)
+	if resp.StatusCode != http.StatusOK || body != "world" {
+		t.Fatalf("get after update returned %d %q, want world", resp.StatusCode, body)
+	}
+
+	resp, body = call(t, http.MethodDelete, item+id+"?storage_type=file", "", http.Header{"If-Match": {updated}})
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("delete returned %d: %s", resp.StatusCode, body)
+	}
+	resp, _ = call(t, http.MethodGet, item+id+"?storage_type=file", "", nil)
+	if resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("get after delete returned %d, want 404", resp.StatusCode)
+	}
+}
+
+func TestLegacySaveAlias(t *testing.T) {
+	ts := newTestServer(t, nil)
+
+	id, _ := save(t, ts, "/save-data", "aGVsbG8=")
+	resp, body := call(t, http.MethodGet, ts.URL+"/v1/data/"+id+"?storage_type=file", "", nil)
+	if resp.StatusCode != http.StatusOK || body != "hello" {
+		t.Fatalf("get of a legacy save returned %d %q, want hello", resp.StatusCode, body)
+	}
+	// The legacy API isn't deprecated unless the config says so
+	resp, _ = call(t, http.MethodGet, ts.URL+"/data/"+id+"?storage_type=file", "", nil)
+	if resp.Header.Get("Deprecation") != "" || resp.Header.Get("Sunset") != "" {
+		t.Fatalf("legacy get carried Deprecation %q and Sunset %q by default", resp.Header.Get("Deprecation"), resp.Header.Get("Sunset"))
+	}
+}
+
+func TestLegacyRetirement(t *testing.T) {
+	ts := newTestServer(t, map[string]any{"api_versions": map[string]any{
+		"legacy": map[string]any{"deprecated": "2020-01-01T00:00:00Z", "sunset": "2999-01-01T00:00:00Z"},
+	}})
+
+	id, _ := save(t, ts, "/v1/save-data", "aGVsbG8=")
+	resp, _ := call(t, http.MethodGet, ts.URL+"/data/"+id+"?storage_type=file", "", nil)
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Deprecation") == "" || resp.Header.Get("Sunset") == "" {
+		t.Fatalf("deprecated legacy get returned %d with Deprecation %q and Sunset %q", resp.StatusCode, resp.Header.Get("Deprecation"), resp.Header.Get("Sunset"))
+	}
+	if link := resp.Header.Get("Link"); !strings.Contains(link, "/v1/data/"+id) {
+		t.Fatalf("deprecated legacy get linked to %q, want the /v1 path", link)
+	}
+	resp, _ = call(t, http.MethodGet, ts.URL+"/v1/data/"+id+"?storage_type=file", "", nil)
+	if resp.Header.Get("Deprecation") != "" {
+		t.Fatal("v1 get carried the legacy API's Deprecation header")
+	}
+}
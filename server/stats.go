@@ -0,0 +1,108 @@
+package server
+
+import (
+	"context"
+	"strconv"
+	"strings"
+
+	"interview-task/internal/api"
+	"interview-task/internal/service"
+	"interview-task/internal/storage"
+)
+
+// statsQueues are the gauges /stats reports as queue depths. Ones whose
+// component is disabled are left out.
+var statsQueues = []string{"queued_requests", "in_flight_requests", "webhook_queue_length", "spool_pending", "event_subscribers"}
+
+// requestStats is the requests section of /stats
+type requestStats struct {
+	Total     int64            `json:"total"`
+	ByStatus  map[string]int64 `json:"by_status"`
+	ErrorRate float64          `json:"error_rate"`
+}
+
+// backendStats is a storage type's row in /stats
+type backendStats struct {
+	storageStats
+	ErrorRate float64 `json:"error_rate"`
+}
+
+// storageUsage is the storage_usage section of /stats. Stored totals are
+// those the quota manager tracks; free disk space is that of the data
+// directory, when the platform reports it.
+type storageUsage struct {
+	Bytes         int64   `json:"bytes"`
+	Objects       int64   `json:"objects"`
+	DiskFreeBytes *uint64 `json:"disk_free_bytes,omitempty"`
+}
+
+// statsSections reads /stats from the server's components
+func (s *Server) statsSections(quotas *service.QuotaManager, shedder *api.LoadShedder, disk *storage.DiskChecker) map[string]api.StatusSection {
+	return map[string]api.StatusSection{
+		"requests": func(ctx context.Context) (any, error) { return s.requestStats(), nil },
+		"storage":  func(ctx context.Context) (any, error) { return s.backendStats() },
+		"queues": func(ctx context.Context) (any, error) {
+			queues := make(map[string]int64)
+			for _, name := range statsQueues {
+				for _, sample := range s.metrics.Samples(name) {
+					queues[name] += sample.Value
+				}
+			}
+			return queues, nil
+		},
+		"storage_usage": func(ctx context.Context) (any, error) {
+			var usage storageUsage
+			for scope, report := range quotas.Report() {
+				if strings.HasPrefix(scope, "tenant/") {
+					usage.Bytes += report.Usage.Bytes
+					usage.Objects += report.Usage.Objects
+				}
+			}
+			free, ok, err := disk.FreeSpace()
+			if err != nil {
+				return nil, err
+			}
+			if ok {
+				usage.DiskFreeBytes = &free
+			}
+			return usage, nil
+		},
+		"load": func(ctx context.Context) (any, error) { return shedder.Stats(), nil },
+	}
+}
+
+// requestStats counts responses by status; the error rate is the share
+// that were server errors
+func (s *Server) requestStats() requestStats {
+	stats := requestStats{ByStatus: make(map[string]int64)}
+	var failed int64
+	for _, sample := range s.metrics.Samples("http_requests_total") {
+		code := sample.Labels["code"]
+		stats.ByStatus[code] += sample.Value
+		stats.Total += sample.Value
+		if status, _ := strconv.Atoi(code); status >= 500 {
+			failed += sample.Value
+		}
+	}
+	stats.ErrorRate = rate(failed, stats.Total)
+	return stats
+}
+
+func (s *Server) backendStats() ([]backendStats, error) {
+	rows, err := s.storageStats()
+	if err != nil {
+		return nil, err
+	}
+	stats := make([]backendStats, len(rows))
+	for i, row := range rows {
+		stats[i] = backendStats{storageStats: row, ErrorRate: rate(row.Failures, row.Operations)}
+	}
+	return stats, nil
+}
+
+func rate(part, total int64) float64 {
+	if total == 0 {
+		return 0
+	}
+	return float64(part) / float64(total)
+}